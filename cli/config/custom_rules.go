@@ -0,0 +1,112 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+)
+
+const DefaultScanRulesEnvName = "INFISICAL_SCAN_RULES_PATH"
+
+// LoadCustomRules reads a file of rules written like the rules of the scan config, e.g. to detect the
+// token formats of an organization. Its rules are checked up front, so a bad pattern fails with the rule
+// it belongs to rather than in the middle of a scan.
+func LoadCustomRules(path string) (Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("toml")
+	if err := v.ReadInConfig(); err != nil {
+		return Config{}, fmt.Errorf("unable to read the rules file %s: %w", path, err)
+	}
+
+	var vc ViperConfig
+	if err := v.Unmarshal(&vc); err != nil {
+		return Config{}, fmt.Errorf("unable to parse the rules file %s: %w", path, err)
+	}
+
+	if vc.Extend.Path != "" || vc.Extend.URL != "" || vc.Extend.UseDefault {
+		return Config{}, fmt.Errorf("the rules file %s can't use [extend], its rules are always added to the built-in rules", path)
+	}
+
+	if err := validateCustomRules(vc); err != nil {
+		return Config{}, fmt.Errorf("invalid rules file %s: %w", path, err)
+	}
+
+	return vc.Translate()
+}
+
+func validateCustomRules(vc ViperConfig) error {
+	ruleIDs := map[string]bool{}
+	for i, rule := range vc.Rules {
+		if rule.ID == "" {
+			return fmt.Errorf("rule %d has no id", i+1)
+		}
+		if ruleIDs[rule.ID] {
+			return fmt.Errorf("rule %s is defined more than once", rule.ID)
+		}
+		ruleIDs[rule.ID] = true
+
+		if rule.Regex == "" && rule.Path == "" {
+			return fmt.Errorf("rule %s needs a regex, a path or both", rule.ID)
+		}
+		if rule.Entropy < 0 {
+			return fmt.Errorf("rule %s has a negative entropy", rule.ID)
+		}
+
+		patterns := []string{rule.Regex, rule.Path}
+		patterns = append(patterns, rule.Allowlist.Regexes...)
+		patterns = append(patterns, rule.Allowlist.Paths...)
+		if err := compilesAll(patterns); err != nil {
+			return fmt.Errorf("rule %s: %w", rule.ID, err)
+		}
+	}
+
+	patterns := append([]string{}, vc.Allowlist.Regexes...)
+	patterns = append(patterns, vc.Allowlist.Paths...)
+	if err := compilesAll(patterns); err != nil {
+		return fmt.Errorf("allowlist: %w", err)
+	}
+
+	return nil
+}
+
+func compilesAll(patterns []string) error {
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid regular expression %s: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// AddCustomRules merges the rules and allowlist of a rules file into the config. A custom rule replaces
+// the rule of the same id, so a built-in rule can be tuned without copying the whole config.
+func (c *Config) AddCustomRules(custom Config) {
+	if c.Rules == nil {
+		c.Rules = make(map[string]Rule)
+	}
+
+	for _, rule := range custom.OrderedRules() {
+		if _, ok := c.Rules[rule.RuleID]; ok {
+			log.Debug().Msgf("custom rule %s replaces the rule of the same id", rule.RuleID)
+		} else {
+			c.orderedRules = append(c.orderedRules, rule.RuleID)
+		}
+
+		c.Rules[rule.RuleID] = rule
+		for _, keyword := range rule.Keywords {
+			c.Keywords = append(c.Keywords, strings.ToLower(keyword))
+		}
+	}
+
+	c.Allowlist.Commits = append(c.Allowlist.Commits, custom.Allowlist.Commits...)
+	c.Allowlist.Paths = append(c.Allowlist.Paths, custom.Allowlist.Paths...)
+	c.Allowlist.Regexes = append(c.Allowlist.Regexes, custom.Allowlist.Regexes...)
+	c.Allowlist.StopWords = append(c.Allowlist.StopWords, custom.Allowlist.StopWords...)
+}
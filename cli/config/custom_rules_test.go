@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddCustomRules(t *testing.T) {
+	rulesPath := filepath.Join(t.TempDir(), "rules.toml")
+	err := os.WriteFile(rulesPath, []byte(`
+[[rules]]
+id = "acme-token"
+description = "Acme internal token"
+regex = '''acme_[a-z0-9]{32}'''
+keywords = ["ACME_"]
+path = '''services/'''
+
+[[rules]]
+id = "aws-access-key"
+description = "AWS Access Key, tuned"
+regex = '''AKIA[A-Z0-9]{16}'''
+entropy = 3.5
+`), 0600)
+	assert.NoError(t, err)
+
+	customRules, err := LoadCustomRules(rulesPath)
+	assert.NoError(t, err)
+
+	cfg := Config{
+		Rules:        map[string]Rule{"aws-access-key": {RuleID: "aws-access-key", Description: "AWS Access Key"}},
+		orderedRules: []string{"aws-access-key"},
+	}
+	cfg.AddCustomRules(customRules)
+
+	assert.Equal(t, []string{"aws-access-key", "acme-token"}, cfg.orderedRules)
+	assert.Equal(t, "AWS Access Key, tuned", cfg.Rules["aws-access-key"].Description)
+	assert.Equal(t, "services/", cfg.Rules["acme-token"].Path.String())
+	assert.Contains(t, cfg.Keywords, "acme_")
+
+	err = os.WriteFile(rulesPath, []byte("[[rules]]\nid = \"broken\"\nregex = '''acme_[a-z'''\n"), 0600)
+	assert.NoError(t, err)
+
+	_, err = LoadCustomRules(rulesPath)
+	assert.ErrorContains(t, err, "rule broken: invalid regular expression")
+}
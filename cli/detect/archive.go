@@ -0,0 +1,258 @@
+package detect
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/h2non/filetype"
+	"github.com/rs/zerolog/log"
+
+	"github.com/Infisical/infisical-merge/report"
+)
+
+// ArchivePathSeparator separates the path of an archive from the path of a file in it in the file of
+// findings, e.g. image.tar!layer.tar!etc/app.env
+const ArchivePathSeparator = "!"
+
+// archives nested deeper than this are scanned as regular files
+const maxArchiveDepth = 4
+
+// files in archives larger than this are skipped when --max-target-megabytes isn't set, so that a small
+// archive that unpacks to a huge file can't exhaust memory
+const defaultMaxArchiveEntryBytes = 100 * 1000 * 1000
+
+// maxArchiveUnpackedBytes bounds what all the compressed files of an archive unpack to together, so that
+// many layers or entries of a small archive can't make the scan decompress without end
+var maxArchiveUnpackedBytes int64 = 50 * 1000 * 1000 * 1000
+
+// errArchiveTooLarge is returned once an archive unpacks to more than maxArchiveUnpackedBytes
+var errArchiveTooLarge = errors.New("the archive unpacks to more data than can be scanned")
+
+type archiveType int
+
+const (
+	notAnArchive archiveType = iota
+	gzipArchive
+	tarArchive
+	zipArchive
+)
+
+// archiveScan is the scan of one archive, which tracks how many bytes its compressed files unpacked to
+type archiveScan struct {
+	detector *Detector
+	// remaining is how many more bytes can be decompressed
+	remaining int64
+}
+
+// unpackedReader counts the bytes read from a decompressed stream against the scan
+type unpackedReader struct {
+	reader io.Reader
+	scan   *archiveScan
+}
+
+func (r *unpackedReader) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+	r.scan.remaining -= int64(n)
+	if r.scan.remaining < 0 {
+		return n, errArchiveTooLarge
+	}
+	return n, err
+}
+
+// DetectArchive scans the files in a tar, gzipped tar or zip archive. Archives in it are unpacked and
+// scanned as well, so the layers of a saved container image are scanned like any other archive.
+func (d *Detector) DetectArchive(archivePath string, name string) ([]report.Finding, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return d.findings, err
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return d.findings, err
+	}
+
+	scan := &archiveScan{detector: d, remaining: maxArchiveUnpackedBytes}
+	reader := bufio.NewReader(file)
+	kind := sniffArchive(reader)
+	if kind == notAnArchive {
+		return d.findings, fmt.Errorf("%s is not a tar, gzipped tar or zip archive", archivePath)
+	}
+
+	// zip archives need random access, which the file itself provides
+	if kind == zipArchive {
+		zipReader, err := zip.NewReader(file, fileInfo.Size())
+		if err != nil {
+			return d.findings, err
+		}
+		return d.findings, scan.detectZip(zipReader, name, 0)
+	}
+
+	return d.findings, scan.detectArchiveStream(reader, kind, name, 0)
+}
+
+func (s *archiveScan) detectArchiveStream(reader *bufio.Reader, kind archiveType, name string, depth int) error {
+	switch kind {
+	case gzipArchive:
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("unable to decompress %s: %w", name, err)
+		}
+		defer gzipReader.Close()
+
+		// every unpacked layer counts against the depth, so nested gzip layers end like other archives
+		return s.detectArchiveEntry(&unpackedReader{reader: gzipReader, scan: s}, name, -1, depth+1)
+	case zipArchive:
+		// zip archives in other archives are read into memory for random access
+		content, withinLimit, err := readArchiveEntry(reader, s.detector.maxArchiveEntryBytes())
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", name, err)
+		}
+		if !withinLimit {
+			log.Debug().Msgf("skipping archive: %s scan due to size", name)
+			return nil
+		}
+		zipReader, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", name, err)
+		}
+		return s.detectZip(zipReader, name, depth)
+	case tarArchive:
+		tarReader := tar.NewReader(reader)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("unable to read %s: %w", name, err)
+			}
+
+			// links, directories and the whiteouts of image layers have nothing to scan
+			if header.Typeflag != tar.TypeReg || header.Size == 0 {
+				continue
+			}
+
+			if err := s.detectArchiveEntry(tarReader, joinArchivePath(name, header.Name), header.Size, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *archiveScan) detectZip(zipReader *zip.Reader, name string, depth int) error {
+	for _, zipFile := range zipReader.File {
+		if zipFile.FileInfo().IsDir() || zipFile.UncompressedSize64 == 0 {
+			continue
+		}
+
+		entry, err := zipFile.Open()
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %w", joinArchivePath(name, zipFile.Name), err)
+		}
+		err = s.detectArchiveEntry(&unpackedReader{reader: entry, scan: s}, joinArchivePath(name, zipFile.Name), int64(zipFile.UncompressedSize64), depth+1)
+		entry.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// detectArchiveEntry scans a file of an archive, or unpacks it if it's an archive itself. The size is -1
+// when it's unknown.
+func (s *archiveScan) detectArchiveEntry(entry io.Reader, name string, size int64, depth int) error {
+	d := s.detector
+	reader := bufio.NewReader(entry)
+
+	if depth < maxArchiveDepth {
+		if kind := sniffArchive(reader); kind != notAnArchive {
+			log.Debug().Msgf("unpacking %s", name)
+			return s.detectArchiveStream(reader, kind, name, depth)
+		}
+	}
+
+	// the size in the header of the archive saves reading files that are too large, but isn't trusted
+	maxBytes := d.maxArchiveEntryBytes()
+	if size > maxBytes {
+		log.Debug().Msgf("skipping file: %s scan due to size: %d", name, size/1000000)
+		return nil
+	}
+
+	content, withinLimit, err := readArchiveEntry(reader, maxBytes)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %w", name, err)
+	}
+	if !withinLimit {
+		log.Debug().Msgf("skipping file: %s scan due to size", name)
+		return nil
+	}
+
+	mimetype, err := filetype.Match(content)
+	if err != nil {
+		return err
+	}
+	if mimetype.MIME.Type == "application" {
+		return nil // skip binary files
+	}
+
+	fragment := Fragment{
+		Raw:      string(content),
+		FilePath: name,
+	}
+	for _, finding := range d.Detect(fragment) {
+		// need to add 1 since line counting starts at 1
+		finding.EndLine++
+		finding.StartLine++
+		d.addFinding(finding)
+	}
+
+	return nil
+}
+
+func (d *Detector) maxArchiveEntryBytes() int64 {
+	if d.MaxTargetMegaBytes > 0 {
+		return int64(d.MaxTargetMegaBytes) * 1000000
+	}
+	return defaultMaxArchiveEntryBytes
+}
+
+// readArchiveEntry reads a file of an archive unless more than maxBytes can be read from it, whatever size
+// the archive claims it has. It reports false when the file is larger.
+func readArchiveEntry(reader io.Reader, maxBytes int64) ([]byte, bool, error) {
+	content, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if int64(len(content)) > maxBytes {
+		return nil, false, nil
+	}
+	return content, true, nil
+}
+
+func sniffArchive(reader *bufio.Reader) archiveType {
+	magic, _ := reader.Peek(262)
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzipArchive
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")):
+		return zipArchive
+	case len(magic) >= 262 && bytes.Equal(magic[257:262], []byte("ustar")):
+		return tarArchive
+	}
+	return notAnArchive
+}
+
+func joinArchivePath(archive string, name string) string {
+	return archive + ArchivePathSeparator + path.Clean("/" + name)[1:]
+}
@@ -34,9 +34,19 @@ import (
 )
 
 func IsNew(finding report.Finding, baseline []report.Finding) bool {
+	hasStableFingerprint := hasSecret(finding)
+	stableFingerprint := ""
+	if hasStableFingerprint {
+		stableFingerprint = finding.StableFingerprint()
+	}
+
 	// Explicitly testing each property as it gives significantly better performance in comparison to cmp.Equal(). Drawback is that
 	// the code requires maintanance if/when the Finding struct changes
 	for _, b := range baseline {
+		// the same secret of the same rule in the same file, even if it moved to another line or commit
+		if hasStableFingerprint && hasSecret(b) && stableFingerprint == b.StableFingerprint() {
+			return false
+		}
 
 		if finding.Author == b.Author &&
 			finding.Commit == b.Commit &&
@@ -60,6 +70,11 @@ func IsNew(finding report.Finding, baseline []report.Finding) bool {
 	return true
 }
 
+// hasSecret reports whether the finding has a secret to match it by. Findings of path only rules don't.
+func hasSecret(finding report.Finding) bool {
+	return finding.RuleID != "" && (finding.Secret != "" || finding.SecretHash != "")
+}
+
 func LoadBaseline(baselinePath string) ([]report.Finding, error) {
 	var previousFindings []report.Finding
 	jsonFile, err := os.Open(baselinePath)
@@ -85,3 +100,29 @@ func LoadBaseline(baselinePath string) ([]report.Finding, error) {
 
 	return previousFindings, nil
 }
+
+// WriteBaseline records findings in a baseline for later scans to ignore. The secrets are redacted, as
+// the baseline is usually committed along with the code, and the findings are matched by their hash.
+func WriteBaseline(findings []report.Finding, baselinePath string) error {
+	baseline := make([]report.Finding, 0, len(findings))
+	for _, finding := range findings {
+		if finding.Secret != "" {
+			finding.Redact()
+		}
+		baseline = append(baseline, finding)
+	}
+
+	jsonFile, err := os.Create(baselinePath)
+	if err != nil {
+		return fmt.Errorf("could not create %s", baselinePath)
+	}
+	defer jsonFile.Close()
+
+	encoder := json.NewEncoder(jsonFile)
+	encoder.SetIndent("", " ")
+	if err := encoder.Encode(baseline); err != nil {
+		return fmt.Errorf("could not write data to the file %s", baselinePath)
+	}
+
+	return nil
+}
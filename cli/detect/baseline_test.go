@@ -78,6 +78,54 @@ func TestIsNew(t *testing.T) {
 			},
 			expect: false, // Updated tags doesn't make it a new finding
 		},
+		{
+			findings: report.Finding{
+				RuleID:    "a",
+				File:      "config.py",
+				Secret:    "secret",
+				StartLine: 10,
+			},
+			baseline: []report.Finding{
+				{
+					RuleID:    "a",
+					File:      "config.py",
+					Secret:    "secret",
+					StartLine: 2,
+				},
+			},
+			expect: false, // Moving the secret to another line doesn't make it a new finding
+		},
+		{
+			findings: report.Finding{
+				RuleID: "a",
+				File:   "config.py",
+				Secret: "secret",
+			},
+			baseline: []report.Finding{
+				{
+					RuleID:     "a",
+					File:       "config.py",
+					Secret:     "REDACTED",
+					SecretHash: "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b",
+				},
+			},
+			expect: false, // Redacted baselines are matched by the hash of the secret
+		},
+		{
+			findings: report.Finding{
+				RuleID: "a",
+				File:   "config.py",
+				Secret: "other secret",
+			},
+			baseline: []report.Finding{
+				{
+					RuleID: "a",
+					File:   "config.py",
+					Secret: "secret",
+				},
+			},
+			expect: true,
+		},
 	}
 	for _, test := range tests {
 		assert.Equal(t, test.expect, IsNew(test.findings, test.baseline))
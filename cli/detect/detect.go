@@ -193,29 +193,37 @@ func (d *Detector) AddGitleaksIgnore(gitleaksIgnorePath string) error {
 
 func (d *Detector) AddBaseline(baselinePath string, source string) error {
 	if baselinePath != "" {
-		absoluteSource, err := filepath.Abs(source)
+		baseline, err := LoadBaseline(baselinePath)
 		if err != nil {
 			return err
 		}
 
-		absoluteBaseline, err := filepath.Abs(baselinePath)
+		d.baseline = baseline
+	}
+
+	return d.SetBaselinePath(baselinePath, source)
+}
+
+// SetBaselinePath keeps the baseline file out of the scan without loading it, for scans that record a
+// new baseline
+func (d *Detector) SetBaselinePath(baselinePath string, source string) error {
+	if baselinePath != "" {
+		absoluteSource, err := filepath.Abs(source)
 		if err != nil {
 			return err
 		}
 
-		relativeBaseline, err := filepath.Rel(absoluteSource, absoluteBaseline)
+		absoluteBaseline, err := filepath.Abs(baselinePath)
 		if err != nil {
 			return err
 		}
 
-		baseline, err := LoadBaseline(baselinePath)
+		relativeBaseline, err := filepath.Rel(absoluteSource, absoluteBaseline)
 		if err != nil {
 			return err
 		}
 
-		d.baseline = baseline
 		baselinePath = relativeBaseline
-
 	}
 
 	d.baselinePath = baselinePath
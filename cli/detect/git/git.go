@@ -24,9 +24,12 @@ package git
 
 import (
 	"bufio"
+	"fmt"
 	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -141,3 +144,30 @@ func listenForStdErr(stderr io.ReadCloser) {
 		}
 	}
 }
+
+// CloneRemote fetches the history of a remote repository into dir as a bare repository, which is all
+// git log needs. A depth of 0 fetches the whole history, and an empty branch fetches every branch.
+func CloneRemote(url string, dir string, depth int, branch string) error {
+	args := []string{"clone", "--bare", "--no-tags", "--quiet"}
+	if depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(depth))
+	}
+	if branch != "" {
+		args = append(args, "--single-branch", "--branch", branch)
+	} else if depth > 0 {
+		// shallow clones only fetch the default branch unless told otherwise
+		args = append(args, "--no-single-branch")
+	}
+	args = append(args, "--", url, dir)
+
+	cmd := exec.Command("git", args...)
+	// fail rather than wait for credentials nobody will type
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	log.Debug().Msgf("executing: git clone into %s", dir)
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git clone failed: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
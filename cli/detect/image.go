@@ -0,0 +1,53 @@
+package detect
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/Infisical/infisical-merge/report"
+)
+
+// DetectImage scans every layer of a container image, so secrets removed by a later layer are still
+// found. The image is exported with docker, or podman when docker isn't installed, and pulled first if
+// it isn't available locally.
+func (d *Detector) DetectImage(imageRef string) ([]report.Finding, error) {
+	runtime, err := containerRuntime()
+	if err != nil {
+		return d.findings, err
+	}
+
+	if err := exec.Command(runtime, "image", "inspect", imageRef).Run(); err != nil {
+		log.Info().Msgf("pulling %s", imageRef)
+		pull := exec.Command(runtime, "pull", imageRef)
+		pull.Stderr = os.Stderr
+		if err := pull.Run(); err != nil {
+			return d.findings, fmt.Errorf("unable to pull %s: %w", imageRef, err)
+		}
+	}
+
+	imageFile, err := os.CreateTemp("", "infisical-scan-image-*.tar")
+	if err != nil {
+		return d.findings, err
+	}
+	imageFile.Close()
+	defer os.Remove(imageFile.Name())
+
+	if output, err := exec.Command(runtime, "save", "-o", imageFile.Name(), imageRef).CombinedOutput(); err != nil {
+		return d.findings, fmt.Errorf("unable to export %s: %s", imageRef, strings.TrimSpace(string(output)))
+	}
+
+	return d.DetectArchive(imageFile.Name(), imageRef)
+}
+
+func containerRuntime() (string, error) {
+	for _, runtime := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(runtime); err == nil {
+			return runtime, nil
+		}
+	}
+	return "", fmt.Errorf("scanning images needs docker or podman. Alternatively, export the image to a tar archive and scan it with --archive")
+}
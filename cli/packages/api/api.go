@@ -1,9 +1,13 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/go-resty/resty/v2"
@@ -22,11 +26,11 @@ func CallGetEncryptedWorkspaceKey(httpClient *resty.Client, request GetEncrypted
 		Get(endpoint)
 
 	if err != nil {
-		return GetEncryptedWorkspaceKeyResponse{}, fmt.Errorf("CallGetEncryptedWorkspaceKey: Unable to complete api request [err=%s]", err)
+		return GetEncryptedWorkspaceKeyResponse{}, fmt.Errorf("CallGetEncryptedWorkspaceKey: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return GetEncryptedWorkspaceKeyResponse{}, fmt.Errorf("CallGetEncryptedWorkspaceKey: Unsuccessful response [%v %v] [status-code=%v]", response.Request.Method, response.Request.URL, response.StatusCode())
+		return GetEncryptedWorkspaceKeyResponse{}, NewAPIError("CallGetEncryptedWorkspaceKey", response)
 	}
 
 	return result, nil
@@ -41,11 +45,11 @@ func CallGetServiceTokenDetailsV2(httpClient *resty.Client) (GetServiceTokenDeta
 		Get(fmt.Sprintf("%v/v2/service-token", config.INFISICAL_URL))
 
 	if err != nil {
-		return GetServiceTokenDetailsResponse{}, fmt.Errorf("CallGetServiceTokenDetails: Unable to complete api request [err=%s]", err)
+		return GetServiceTokenDetailsResponse{}, fmt.Errorf("CallGetServiceTokenDetails: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return GetServiceTokenDetailsResponse{}, fmt.Errorf("CallGetServiceTokenDetails: Unsuccessful response: [response=%s]", response)
+		return GetServiceTokenDetailsResponse{}, NewAPIError("CallGetServiceTokenDetails", response)
 	}
 
 	return tokenDetailsResponse, nil
@@ -61,11 +65,11 @@ func CallLogin1V2(httpClient *resty.Client, request GetLoginOneV2Request) (GetLo
 		Post(fmt.Sprintf("%v/v3/auth/login1", config.INFISICAL_URL))
 
 	if err != nil {
-		return GetLoginOneV2Response{}, fmt.Errorf("CallLogin1V3: Unable to complete api request [err=%s]", err)
+		return GetLoginOneV2Response{}, fmt.Errorf("CallLogin1V3: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return GetLoginOneV2Response{}, fmt.Errorf("CallLogin1V3: Unsuccessful response: [response=%s]", response)
+		return GetLoginOneV2Response{}, NewAPIError("CallLogin1V3", response)
 	}
 
 	return loginOneV2Response, nil
@@ -99,7 +103,7 @@ func CallVerifyMfaToken(httpClient *resty.Client, request VerifyMfaTokenRequest)
 	}
 
 	if err != nil {
-		return nil, nil, fmt.Errorf("CallVerifyMfaToken: Unable to complete api request [err=%s]", err)
+		return nil, nil, fmt.Errorf("CallVerifyMfaToken: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
@@ -135,11 +139,11 @@ func CallLogin2V2(httpClient *resty.Client, request GetLoginTwoV2Request) (GetLo
 	}
 
 	if err != nil {
-		return GetLoginTwoV2Response{}, fmt.Errorf("CallLogin2V3: Unable to complete api request [err=%s]", err)
+		return GetLoginTwoV2Response{}, fmt.Errorf("CallLogin2V3: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return GetLoginTwoV2Response{}, fmt.Errorf("CallLogin2V3: Unsuccessful response: [response=%s]", response)
+		return GetLoginTwoV2Response{}, NewAPIError("CallLogin2V3", response)
 	}
 
 	return loginTwoV2Response, nil
@@ -158,12 +162,115 @@ func CallGetAllOrganizations(httpClient *resty.Client) (GetOrganizationsResponse
 	}
 
 	if response.IsError() {
-		return GetOrganizationsResponse{}, fmt.Errorf("CallGetAllOrganizations: Unsuccessful response: [response=%v]", response)
+		return GetOrganizationsResponse{}, NewAPIError("CallGetAllOrganizations", response)
 	}
 
 	return orgResponse, nil
 }
 
+// CallLdapLoginV1 checks the LDAP credentials and returns the provider auth token Infisical redirects to
+// the web app with, which is exchanged for a session with CallSsoTokenExchangeV1. It stops httpClient
+// from following redirects to read the token.
+func CallLdapLoginV1(httpClient *resty.Client, request LdapLoginV1Request) (string, error) {
+	response, err := httpClient.
+		SetRedirectPolicy(resty.NoRedirectPolicy()).
+		R().
+		SetBody(request).
+		SetHeader("User-Agent", USER_AGENT).
+		Post(fmt.Sprintf("%v/v1/ldap/login", config.INFISICAL_URL))
+
+	if response == nil || response.StatusCode() < 300 || response.StatusCode() >= 400 {
+		if err != nil {
+			return "", fmt.Errorf("CallLdapLoginV1: Unable to complete api request [err=%w]", err)
+		}
+		return "", NewAPIError("CallLdapLoginV1", response)
+	}
+
+	redirectUrl, err := url.Parse(response.Header().Get("Location"))
+	if err != nil {
+		return "", fmt.Errorf("CallLdapLoginV1: Unable to parse the redirect [err=%w]", err)
+	}
+
+	if strings.Contains(redirectUrl.Path, "signup") {
+		return "", fmt.Errorf("CallLdapLoginV1: your account isn't set up yet, log in through the web app once to finish signing up")
+	}
+
+	providerAuthToken := redirectUrl.Query().Get("token")
+	if providerAuthToken == "" {
+		return "", fmt.Errorf("CallLdapLoginV1: Infisical didn't return a login token, check the organization slug and your credentials")
+	}
+
+	return providerAuthToken, nil
+}
+
+func CallSsoTokenExchangeV1(httpClient *resty.Client, request SsoTokenExchangeV1Request) (SsoTokenExchangeV1Response, error) {
+	var tokenExchangeResponse SsoTokenExchangeV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&tokenExchangeResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/sso/token-exchange", config.INFISICAL_URL))
+
+	if err != nil {
+		return SsoTokenExchangeV1Response{}, fmt.Errorf("CallSsoTokenExchangeV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return SsoTokenExchangeV1Response{}, NewAPIError("CallSsoTokenExchangeV1", response)
+	}
+
+	for _, cookie := range response.Cookies() {
+		if cookie.Name == "jid" {
+			tokenExchangeResponse.RefreshToken = cookie.Value
+		}
+	}
+
+	return tokenExchangeResponse, nil
+}
+
+// CallCreateCliDeviceCodeV1 starts a device code login, which the user approves in a browser on any device
+func CallCreateCliDeviceCodeV1(httpClient *resty.Client) (CreateCliDeviceCodeV1Response, error) {
+	var deviceCodeResponse CreateCliDeviceCodeV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&deviceCodeResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Post(fmt.Sprintf("%v/v1/auth/cli/device-code", config.INFISICAL_URL))
+
+	if err != nil {
+		return CreateCliDeviceCodeV1Response{}, fmt.Errorf("CallCreateCliDeviceCodeV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return CreateCliDeviceCodeV1Response{}, NewAPIError("CallCreateCliDeviceCodeV1", response)
+	}
+
+	return deviceCodeResponse, nil
+}
+
+// CallGetCliDeviceTokenV1 returns the credentials of a device code login once the user approved it. Until
+// then it fails with the error code DEVICE_CODE_AUTHORIZATION_PENDING.
+func CallGetCliDeviceTokenV1(httpClient *resty.Client, request GetCliDeviceTokenV1Request) (GetCliDeviceTokenV1Response, error) {
+	var deviceTokenResponse GetCliDeviceTokenV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&deviceTokenResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/auth/cli/device-token", config.INFISICAL_URL))
+
+	if err != nil {
+		return GetCliDeviceTokenV1Response{}, fmt.Errorf("CallGetCliDeviceTokenV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetCliDeviceTokenV1Response{}, NewAPIError("CallGetCliDeviceTokenV1", response)
+	}
+
+	return deviceTokenResponse, nil
+}
+
 func CallSelectOrganization(httpClient *resty.Client, request SelectOrganizationRequest) (SelectOrganizationResponse, error) {
 	var selectOrgResponse SelectOrganizationResponse
 
@@ -179,7 +286,7 @@ func CallSelectOrganization(httpClient *resty.Client, request SelectOrganization
 	}
 
 	if response.IsError() {
-		return SelectOrganizationResponse{}, fmt.Errorf("CallSelectOrganization: Unsuccessful response: [response=%v]", response)
+		return SelectOrganizationResponse{}, NewAPIError("CallSelectOrganization", response)
 	}
 
 	return selectOrgResponse, nil
@@ -199,7 +306,7 @@ func CallGetAllWorkSpacesUserBelongsTo(httpClient *resty.Client) (GetWorkSpacesR
 	}
 
 	if response.IsError() {
-		return GetWorkSpacesResponse{}, fmt.Errorf("CallGetAllWorkSpacesUserBelongsTo: Unsuccessful response:  [response=%v]", response)
+		return GetWorkSpacesResponse{}, NewAPIError("CallGetAllWorkSpacesUserBelongsTo", response)
 	}
 
 	return workSpacesResponse, nil
@@ -218,7 +325,7 @@ func CallGetProjectById(httpClient *resty.Client, id string) (Project, error) {
 	}
 
 	if response.IsError() {
-		return Project{}, fmt.Errorf("CallGetProjectById: Unsuccessful response:  [response=%v]", response)
+		return Project{}, NewAPIError("CallGetProjectById", response)
 	}
 
 	return projectResponse.Project, nil
@@ -261,7 +368,7 @@ func CallGetNewAccessTokenWithRefreshToken(httpClient *resty.Client, refreshToke
 	}
 
 	if response.IsError() {
-		return GetNewAccessTokenWithRefreshTokenResponse{}, fmt.Errorf("CallGetNewAccessTokenWithRefreshToken: Unsuccessful response:  [response=%v]", response)
+		return GetNewAccessTokenWithRefreshTokenResponse{}, NewAPIError("CallGetNewAccessTokenWithRefreshToken", response)
 	}
 
 	return newAccessToken, nil
@@ -284,7 +391,7 @@ func CallGetFoldersV1(httpClient *resty.Client, request GetFoldersV1Request) (Ge
 	}
 
 	if response.IsError() {
-		return GetFoldersV1Response{}, fmt.Errorf("CallGetFoldersV1: Unsuccessful [response=%s]", response)
+		return GetFoldersV1Response{}, NewAPIError("CallGetFoldersV1", response)
 	}
 
 	return foldersResponse, nil
@@ -300,11 +407,11 @@ func CallCreateFolderV1(httpClient *resty.Client, request CreateFolderV1Request)
 
 	response, err := httpRequest.Post(fmt.Sprintf("%v/v1/folders", config.INFISICAL_URL))
 	if err != nil {
-		return CreateFolderV1Response{}, fmt.Errorf("CallCreateFolderV1: Unable to complete api request [err=%s]", err)
+		return CreateFolderV1Response{}, fmt.Errorf("CallCreateFolderV1: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return CreateFolderV1Response{}, fmt.Errorf("CallCreateFolderV1: Unsuccessful [response=%s]", response.String())
+		return CreateFolderV1Response{}, NewAPIError("CallCreateFolderV1", response)
 	}
 
 	return folderResponse, nil
@@ -321,11 +428,11 @@ func CallDeleteFolderV1(httpClient *resty.Client, request DeleteFolderV1Request)
 
 	response, err := httpRequest.Delete(fmt.Sprintf("%v/v1/folders/%v", config.INFISICAL_URL, request.FolderName))
 	if err != nil {
-		return DeleteFolderV1Response{}, fmt.Errorf("CallDeleteFolderV1: Unable to complete api request [err=%s]", err)
+		return DeleteFolderV1Response{}, fmt.Errorf("CallDeleteFolderV1: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return DeleteFolderV1Response{}, fmt.Errorf("CallDeleteFolderV1: Unsuccessful [response=%s]", response.String())
+		return DeleteFolderV1Response{}, NewAPIError("CallDeleteFolderV1", response)
 	}
 
 	return folderResponse, nil
@@ -342,11 +449,11 @@ func CallDeleteSecretsRawV3(httpClient *resty.Client, request DeleteSecretV3Requ
 		Delete(fmt.Sprintf("%v/v3/secrets/raw/%s", config.INFISICAL_URL, request.SecretName))
 
 	if err != nil {
-		return fmt.Errorf("CallDeleteSecretsV3: Unable to complete api request [err=%s]", err)
+		return fmt.Errorf("CallDeleteSecretsV3: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return fmt.Errorf("CallDeleteSecretsV3: Unsuccessful response. Please make sure your secret path, workspace and environment name are all correct [response=%s]", response)
+		return fmt.Errorf("%w. Please make sure your secret path, workspace and environment name are all correct", NewAPIError("CallDeleteSecretsV3", response))
 	}
 
 	return nil
@@ -362,11 +469,11 @@ func CallCreateServiceToken(httpClient *resty.Client, request CreateServiceToken
 		Post(fmt.Sprintf("%v/v2/service-token/", config.INFISICAL_URL))
 
 	if err != nil {
-		return CreateServiceTokenResponse{}, fmt.Errorf("CallCreateServiceToken: Unable to complete api request [err=%s]", err)
+		return CreateServiceTokenResponse{}, fmt.Errorf("CallCreateServiceToken: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return CreateServiceTokenResponse{}, fmt.Errorf("CallCreateServiceToken: Unsuccessful response [%v %v] [status-code=%v]", response.Request.Method, response.Request.URL, response.StatusCode())
+		return CreateServiceTokenResponse{}, NewAPIError("CallCreateServiceToken", response)
 	}
 
 	return createServiceTokenResponse, nil
@@ -382,16 +489,61 @@ func CallUniversalAuthLogin(httpClient *resty.Client, request UniversalAuthLogin
 		Post(fmt.Sprintf("%v/v1/auth/universal-auth/login/", config.INFISICAL_URL))
 
 	if err != nil {
-		return UniversalAuthLoginResponse{}, fmt.Errorf("CallUniversalAuthLogin: Unable to complete api request [err=%s]", err)
+		return UniversalAuthLoginResponse{}, fmt.Errorf("CallUniversalAuthLogin: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return UniversalAuthLoginResponse{}, fmt.Errorf("CallUniversalAuthLogin: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return UniversalAuthLoginResponse{}, NewAPIError("CallUniversalAuthLogin", response)
 	}
 
 	return universalAuthLoginResponse, nil
 }
 
+// CallTlsCertAuthLoginV1 logs a machine identity in with the client certificate of the mutual TLS
+// connection, so httpClient must be set up with one. The response has the shape of every machine
+// identity login.
+func CallTlsCertAuthLoginV1(httpClient *resty.Client, request TlsCertAuthLoginV1Request) (UniversalAuthLoginResponse, error) {
+	var tlsCertAuthLoginResponse UniversalAuthLoginResponse
+	response, err := httpClient.
+		R().
+		SetResult(&tlsCertAuthLoginResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/auth/tls-cert-auth/login", config.INFISICAL_URL))
+
+	if err != nil {
+		return UniversalAuthLoginResponse{}, fmt.Errorf("CallTlsCertAuthLoginV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return UniversalAuthLoginResponse{}, NewAPIError("CallTlsCertAuthLoginV1", response)
+	}
+
+	return tlsCertAuthLoginResponse, nil
+}
+
+// CallAwsIamAuthLoginV1 logs a machine identity in with a signed STS GetCallerIdentity request, which
+// Infisical replays to learn the AWS principal of the caller
+func CallAwsIamAuthLoginV1(httpClient *resty.Client, request AwsIamAuthLoginV1Request) (UniversalAuthLoginResponse, error) {
+	var awsIamAuthLoginResponse UniversalAuthLoginResponse
+	response, err := httpClient.
+		R().
+		SetResult(&awsIamAuthLoginResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/auth/aws-auth/login", config.INFISICAL_URL))
+
+	if err != nil {
+		return UniversalAuthLoginResponse{}, fmt.Errorf("CallAwsIamAuthLoginV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return UniversalAuthLoginResponse{}, NewAPIError("CallAwsIamAuthLoginV1", response)
+	}
+
+	return awsIamAuthLoginResponse, nil
+}
+
 func CallMachineIdentityRefreshAccessToken(httpClient *resty.Client, request UniversalAuthRefreshRequest) (UniversalAuthRefreshResponse, error) {
 	var universalAuthRefreshResponse UniversalAuthRefreshResponse
 	response, err := httpClient.
@@ -402,11 +554,11 @@ func CallMachineIdentityRefreshAccessToken(httpClient *resty.Client, request Uni
 		Post(fmt.Sprintf("%v/v1/auth/token/renew", config.INFISICAL_URL))
 
 	if err != nil {
-		return UniversalAuthRefreshResponse{}, fmt.Errorf("CallMachineIdentityRefreshAccessToken: Unable to complete api request [err=%s]", err)
+		return UniversalAuthRefreshResponse{}, fmt.Errorf("CallMachineIdentityRefreshAccessToken: Unable to complete api request [err=%w]", err)
 	}
 
 	if response.IsError() {
-		return UniversalAuthRefreshResponse{}, fmt.Errorf("CallMachineIdentityRefreshAccessToken: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return UniversalAuthRefreshResponse{}, NewAPIError("CallMachineIdentityRefreshAccessToken", response)
 	}
 
 	return universalAuthRefreshResponse, nil
@@ -453,7 +605,7 @@ func CallGetRawSecretsV3(httpClient *resty.Client, request GetRawSecretsV3Reques
 	}
 
 	if response.IsError() {
-		return GetRawSecretsV3Response{}, fmt.Errorf("CallGetRawSecretsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return GetRawSecretsV3Response{}, NewAPIError("CallGetRawSecretsV3", response)
 	}
 
 	getRawSecretsV3Response.ETag = response.Header().Get(("etag"))
@@ -481,7 +633,7 @@ func CallFetchSingleSecretByName(httpClient *resty.Client, request GetRawSecretV
 	}
 
 	if response.IsError() {
-		return GetRawSecretV3ByNameResponse{}, fmt.Errorf("CallFetchSingleSecretByName: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return GetRawSecretV3ByNameResponse{}, NewAPIError("CallFetchSingleSecretByName", response)
 	}
 
 	getRawSecretV3ByNameResponse.ETag = response.Header().Get(("etag"))
@@ -503,12 +655,96 @@ func CallCreateDynamicSecretLeaseV1(httpClient *resty.Client, request CreateDyna
 	}
 
 	if response.IsError() {
-		return CreateDynamicSecretLeaseV1Response{}, fmt.Errorf("CreateDynamicSecretLeaseV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return CreateDynamicSecretLeaseV1Response{}, NewAPIError("CreateDynamicSecretLeaseV1", response)
 	}
 
 	return createDynamicSecretLeaseResponse, nil
 }
 
+func CallIssueCertificateV1(httpClient *resty.Client, request IssueCertificateV1Request) (IssueCertificateV1Response, error) {
+	var issueCertificateResponse IssueCertificateV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&issueCertificateResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/pki/certificates/issue-certificate", config.INFISICAL_URL))
+
+	if err != nil {
+		return IssueCertificateV1Response{}, fmt.Errorf("CallIssueCertificateV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return IssueCertificateV1Response{}, NewAPIError("CallIssueCertificateV1", response)
+	}
+
+	return issueCertificateResponse, nil
+}
+
+// CallSignCertificateV1 issues a certificate for the key of a certificate signing request
+func CallSignCertificateV1(httpClient *resty.Client, request SignCertificateV1Request) (SignCertificateV1Response, error) {
+	var signCertificateResponse SignCertificateV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&signCertificateResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/pki/certificates/sign-certificate", config.INFISICAL_URL))
+
+	if err != nil {
+		return SignCertificateV1Response{}, fmt.Errorf("CallSignCertificateV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return SignCertificateV1Response{}, NewAPIError("CallSignCertificateV1", response)
+	}
+
+	return signCertificateResponse, nil
+}
+
+// CallGetSecretSnapshotsV1 lists the snapshots of a folder, newest first
+func CallGetSecretSnapshotsV1(httpClient *resty.Client, request GetSecretSnapshotsV1Request) (GetSecretSnapshotsV1Response, error) {
+	var getSecretSnapshotsResponse GetSecretSnapshotsV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&getSecretSnapshotsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("environment", request.Environment).
+		SetQueryParam("path", request.SecretPath).
+		SetQueryParam("offset", strconv.Itoa(request.Offset)).
+		SetQueryParam("limit", strconv.Itoa(request.Limit)).
+		Get(fmt.Sprintf("%v/v1/workspace/%s/secret-snapshots", config.INFISICAL_URL, request.WorkspaceId))
+
+	if err != nil {
+		return GetSecretSnapshotsV1Response{}, fmt.Errorf("CallGetSecretSnapshotsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretSnapshotsV1Response{}, NewAPIError("CallGetSecretSnapshotsV1", response)
+	}
+
+	return getSecretSnapshotsResponse, nil
+}
+
+func CallGetSecretSnapshotV1(httpClient *resty.Client, snapshotId string) (GetSecretSnapshotV1Response, error) {
+	var getSecretSnapshotResponse GetSecretSnapshotV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&getSecretSnapshotResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/secret-snapshot/%s", config.INFISICAL_URL, snapshotId))
+
+	if err != nil {
+		return GetSecretSnapshotV1Response{}, fmt.Errorf("CallGetSecretSnapshotV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretSnapshotV1Response{}, NewAPIError("CallGetSecretSnapshotV1", response)
+	}
+
+	return getSecretSnapshotResponse, nil
+}
+
 func CallCreateRawSecretsV3(httpClient *resty.Client, request CreateRawSecretV3Request) error {
 	response, err := httpClient.
 		R().
@@ -521,7 +757,7 @@ func CallCreateRawSecretsV3(httpClient *resty.Client, request CreateRawSecretV3R
 	}
 
 	if response.IsError() {
-		return fmt.Errorf("CallCreateRawSecretsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return NewAPIError("CallCreateRawSecretsV3", response)
 	}
 
 	return nil
@@ -539,16 +775,17 @@ func CallUpdateRawSecretsV3(httpClient *resty.Client, request UpdateRawSecretByN
 	}
 
 	if response.IsError() {
-		return fmt.Errorf("CallUpdateRawSecretsV3: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return NewAPIError("CallUpdateRawSecretsV3", response)
 	}
 
 	return nil
 }
 
-func CallRegisterGatewayIdentityV1(httpClient *resty.Client) (*GetRelayCredentialsResponseV1, error) {
+func CallRegisterGatewayIdentityV1(ctx context.Context, httpClient *resty.Client) (*GetRelayCredentialsResponseV1, error) {
 	var resBody GetRelayCredentialsResponseV1
 	response, err := httpClient.
 		R().
+		SetContext(ctx).
 		SetResult(&resBody).
 		SetHeader("User-Agent", USER_AGENT).
 		Post(fmt.Sprintf("%v/v1/gateways/register-identity", config.INFISICAL_URL))
@@ -558,16 +795,17 @@ func CallRegisterGatewayIdentityV1(httpClient *resty.Client) (*GetRelayCredentia
 	}
 
 	if response.IsError() {
-		return nil, fmt.Errorf("CallRegisterGatewayIdentityV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return nil, NewAPIError("CallRegisterGatewayIdentityV1", response)
 	}
 
 	return &resBody, nil
 }
 
-func CallExchangeRelayCertV1(httpClient *resty.Client, request ExchangeRelayCertRequestV1) (*ExchangeRelayCertResponseV1, error) {
+func CallExchangeRelayCertV1(ctx context.Context, httpClient *resty.Client, request ExchangeRelayCertRequestV1) (*ExchangeRelayCertResponseV1, error) {
 	var resBody ExchangeRelayCertResponseV1
 	response, err := httpClient.
 		R().
+		SetContext(ctx).
 		SetResult(&resBody).
 		SetBody(request).
 		SetHeader("User-Agent", USER_AGENT).
@@ -578,15 +816,16 @@ func CallExchangeRelayCertV1(httpClient *resty.Client, request ExchangeRelayCert
 	}
 
 	if response.IsError() {
-		return nil, fmt.Errorf("CallExchangeRelayCertV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return nil, NewAPIError("CallExchangeRelayCertV1", response)
 	}
 
 	return &resBody, nil
 }
 
-func CallGatewayHeartBeatV1(httpClient *resty.Client) error {
+func CallGatewayHeartBeatV1(ctx context.Context, httpClient *resty.Client) error {
 	response, err := httpClient.
 		R().
+		SetContext(ctx).
 		SetHeader("User-Agent", USER_AGENT).
 		Post(fmt.Sprintf("%v/v1/gateways/heartbeat", config.INFISICAL_URL))
 
@@ -595,8 +834,594 @@ func CallGatewayHeartBeatV1(httpClient *resty.Client) error {
 	}
 
 	if response.IsError() {
-		return fmt.Errorf("CallGatewayHeartBeatV1: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", response.Request.Method, response.Request.URL, response.StatusCode(), response.String())
+		return NewAPIError("CallGatewayHeartBeatV1", response)
+	}
+
+	return nil
+}
+
+func callSecretsBatchRawV3(httpClient *resty.Client, operation string, method string, request SecretBatchRawV3Request) (SecretBatchRawV3Response, error) {
+	var batchResponse SecretBatchRawV3Response
+	response, err := httpClient.
+		R().
+		SetResult(&batchResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Execute(method, fmt.Sprintf("%v/v3/secrets/batch/raw", config.INFISICAL_URL))
+
+	if err != nil {
+		return SecretBatchRawV3Response{}, fmt.Errorf("%s: Unable to complete api request [err=%w]", operation, err)
+	}
+
+	if response.IsError() {
+		return SecretBatchRawV3Response{}, NewAPIError(operation, response)
+	}
+
+	return batchResponse, nil
+}
+
+func CallCreateSecretsBatchRawV3(httpClient *resty.Client, request SecretBatchRawV3Request) (SecretBatchRawV3Response, error) {
+	return callSecretsBatchRawV3(httpClient, "CallCreateSecretsBatchRawV3", resty.MethodPost, request)
+}
+
+func CallUpdateSecretsBatchRawV3(httpClient *resty.Client, request SecretBatchRawV3Request) (SecretBatchRawV3Response, error) {
+	return callSecretsBatchRawV3(httpClient, "CallUpdateSecretsBatchRawV3", resty.MethodPatch, request)
+}
+
+func CallDeleteSecretsBatchRawV3(httpClient *resty.Client, request SecretBatchRawV3Request) (SecretBatchRawV3Response, error) {
+	return callSecretsBatchRawV3(httpClient, "CallDeleteSecretsBatchRawV3", resty.MethodDelete, request)
+}
+
+func CallGetSecretApprovalRequestsV1(httpClient *resty.Client, request GetSecretApprovalRequestsV1Request) (GetSecretApprovalRequestsV1Response, error) {
+	var approvalsResponse GetSecretApprovalRequestsV1Response
+	httpRequest := httpClient.
+		R().
+		SetResult(&approvalsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("workspaceId", request.WorkspaceId).
+		SetQueryParam("offset", strconv.Itoa(request.Offset)).
+		SetQueryParam("limit", strconv.Itoa(request.Limit))
+
+	if request.Environment != "" {
+		httpRequest.SetQueryParam("environment", request.Environment)
+	}
+	if request.Status != "" {
+		httpRequest.SetQueryParam("status", request.Status)
+	}
+
+	response, err := httpRequest.Get(fmt.Sprintf("%v/v1/secret-approval-requests", config.INFISICAL_URL))
+	if err != nil {
+		return GetSecretApprovalRequestsV1Response{}, fmt.Errorf("CallGetSecretApprovalRequestsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretApprovalRequestsV1Response{}, NewAPIError("CallGetSecretApprovalRequestsV1", response)
+	}
+
+	return approvalsResponse, nil
+}
+
+// CallReviewSecretApprovalRequestV1 approves or rejects a change request as the caller
+func CallReviewSecretApprovalRequestV1(httpClient *resty.Client, request ReviewSecretApprovalRequestV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/secret-approval-requests/%s/review", config.INFISICAL_URL, request.ApprovalId))
+
+	if err != nil {
+		return fmt.Errorf("CallReviewSecretApprovalRequestV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallReviewSecretApprovalRequestV1", response)
+	}
+
+	return nil
+}
+
+// CallMergeSecretApprovalRequestV1 applies the changes of a change request that has enough approvals
+func CallMergeSecretApprovalRequestV1(httpClient *resty.Client, approvalId string) (SecretApprovalRequestV1Response, error) {
+	var mergeResponse SecretApprovalRequestV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&mergeResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Post(fmt.Sprintf("%v/v1/secret-approval-requests/%s/merge", config.INFISICAL_URL, approvalId))
+
+	if err != nil {
+		return SecretApprovalRequestV1Response{}, fmt.Errorf("CallMergeSecretApprovalRequestV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return SecretApprovalRequestV1Response{}, NewAPIError("CallMergeSecretApprovalRequestV1", response)
+	}
+
+	return mergeResponse, nil
+}
+
+// CallGetAuditLogsV1 queries the audit logs of the organization of the caller, newest first
+func CallGetAuditLogsV1(httpClient *resty.Client, request GetAuditLogsV1Request) (GetAuditLogsV1Response, error) {
+	var auditLogsResponse GetAuditLogsV1Response
+	httpRequest := httpClient.
+		R().
+		SetResult(&auditLogsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("offset", strconv.Itoa(request.Offset)).
+		SetQueryParam("limit", strconv.Itoa(request.Limit))
+
+	optionalParams := map[string]string{
+		"projectId":   request.ProjectId,
+		"actorType":   request.ActorType,
+		"actor":       request.Actor,
+		"eventType":   request.EventType,
+		"environment": request.Environment,
+		"secretPath":  request.SecretPath,
+	}
+	for name, value := range optionalParams {
+		if value != "" {
+			httpRequest.SetQueryParam(name, value)
+		}
+	}
+	if request.StartDate != nil {
+		httpRequest.SetQueryParam("startDate", request.StartDate.UTC().Format(time.RFC3339))
+	}
+	if request.EndDate != nil {
+		httpRequest.SetQueryParam("endDate", request.EndDate.UTC().Format(time.RFC3339))
+	}
+
+	response, err := httpRequest.Get(fmt.Sprintf("%v/v1/organization/audit-logs", config.INFISICAL_URL))
+	if err != nil {
+		return GetAuditLogsV1Response{}, fmt.Errorf("CallGetAuditLogsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetAuditLogsV1Response{}, NewAPIError("CallGetAuditLogsV1", response)
+	}
+
+	return auditLogsResponse, nil
+}
+
+func CallGetServiceTokensV2(httpClient *resty.Client, workspaceId string) (GetServiceTokensResponse, error) {
+	var serviceTokensResponse GetServiceTokensResponse
+	response, err := httpClient.
+		R().
+		SetResult(&serviceTokensResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v2/workspace/%s/service-token-data", config.INFISICAL_URL, workspaceId))
+
+	if err != nil {
+		return GetServiceTokensResponse{}, fmt.Errorf("CallGetServiceTokensV2: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetServiceTokensResponse{}, NewAPIError("CallGetServiceTokensV2", response)
+	}
+
+	return serviceTokensResponse, nil
+}
+
+func CallDeleteServiceTokenV2(httpClient *resty.Client, serviceTokenId string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		Delete(fmt.Sprintf("%v/v2/service-token/%s", config.INFISICAL_URL, serviceTokenId))
+
+	if err != nil {
+		return fmt.Errorf("CallDeleteServiceTokenV2: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallDeleteServiceTokenV2", response)
+	}
+
+	return nil
+}
+
+func CallGetUniversalAuthV1(httpClient *resty.Client, identityId string) (GetUniversalAuthResponse, error) {
+	var universalAuthResponse GetUniversalAuthResponse
+	response, err := httpClient.
+		R().
+		SetResult(&universalAuthResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/auth/universal-auth/identities/%s", config.INFISICAL_URL, identityId))
+
+	if err != nil {
+		return GetUniversalAuthResponse{}, fmt.Errorf("CallGetUniversalAuthV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetUniversalAuthResponse{}, NewAPIError("CallGetUniversalAuthV1", response)
+	}
+
+	return universalAuthResponse, nil
+}
+
+func CallCreateUniversalAuthClientSecretV1(httpClient *resty.Client, request CreateUniversalAuthClientSecretRequest) (CreateUniversalAuthClientSecretResponse, error) {
+	var clientSecretResponse CreateUniversalAuthClientSecretResponse
+	response, err := httpClient.
+		R().
+		SetResult(&clientSecretResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/auth/universal-auth/identities/%s/client-secrets", config.INFISICAL_URL, request.IdentityId))
+
+	if err != nil {
+		return CreateUniversalAuthClientSecretResponse{}, fmt.Errorf("CallCreateUniversalAuthClientSecretV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return CreateUniversalAuthClientSecretResponse{}, NewAPIError("CallCreateUniversalAuthClientSecretV1", response)
+	}
+
+	return clientSecretResponse, nil
+}
+
+func CallGetUniversalAuthClientSecretsV1(httpClient *resty.Client, identityId string) (GetUniversalAuthClientSecretsResponse, error) {
+	var clientSecretsResponse GetUniversalAuthClientSecretsResponse
+	response, err := httpClient.
+		R().
+		SetResult(&clientSecretsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/auth/universal-auth/identities/%s/client-secrets", config.INFISICAL_URL, identityId))
+
+	if err != nil {
+		return GetUniversalAuthClientSecretsResponse{}, fmt.Errorf("CallGetUniversalAuthClientSecretsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetUniversalAuthClientSecretsResponse{}, NewAPIError("CallGetUniversalAuthClientSecretsV1", response)
+	}
+
+	return clientSecretsResponse, nil
+}
+
+func CallRevokeUniversalAuthClientSecretV1(httpClient *resty.Client, identityId string, clientSecretId string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		Post(fmt.Sprintf("%v/v1/auth/universal-auth/identities/%s/client-secrets/%s/revoke", config.INFISICAL_URL, identityId, clientSecretId))
+
+	if err != nil {
+		return fmt.Errorf("CallRevokeUniversalAuthClientSecretV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallRevokeUniversalAuthClientSecretV1", response)
+	}
+
+	return nil
+}
+
+func CallCreateIdentityV1(httpClient *resty.Client, request CreateIdentityV1Request) (CreateIdentityV1Response, error) {
+	var identityResponse CreateIdentityV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&identityResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/identities", config.INFISICAL_URL))
+
+	if err != nil {
+		return CreateIdentityV1Response{}, fmt.Errorf("CallCreateIdentityV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return CreateIdentityV1Response{}, NewAPIError("CallCreateIdentityV1", response)
+	}
+
+	return identityResponse, nil
+}
+
+func CallGetIdentitiesV1(httpClient *resty.Client, organizationId string) (GetIdentitiesV1Response, error) {
+	var identitiesResponse GetIdentitiesV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&identitiesResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("orgId", organizationId).
+		Get(fmt.Sprintf("%v/v1/identities", config.INFISICAL_URL))
+
+	if err != nil {
+		return GetIdentitiesV1Response{}, fmt.Errorf("CallGetIdentitiesV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetIdentitiesV1Response{}, NewAPIError("CallGetIdentitiesV1", response)
+	}
+
+	return identitiesResponse, nil
+}
+
+// CallUpdateIdentityV1 changes the organization role of an identity
+func CallUpdateIdentityV1(httpClient *resty.Client, request UpdateIdentityV1Request) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Patch(fmt.Sprintf("%v/v1/identities/%s", config.INFISICAL_URL, request.IdentityId))
+
+	if err != nil {
+		return fmt.Errorf("CallUpdateIdentityV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallUpdateIdentityV1", response)
+	}
+
+	return nil
+}
+
+func CallDeleteIdentityV1(httpClient *resty.Client, identityId string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		Delete(fmt.Sprintf("%v/v1/identities/%s", config.INFISICAL_URL, identityId))
+
+	if err != nil {
+		return fmt.Errorf("CallDeleteIdentityV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallDeleteIdentityV1", response)
+	}
+
+	return nil
+}
+
+// CallAttachIdentityAuthV1 adds an auth method to an identity. authMethod is the path of the method,
+// e.g. universal-auth, and request one of the Attach*AuthRequest types.
+func CallAttachIdentityAuthV1(httpClient *resty.Client, identityId string, authMethod string, request interface{}) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/auth/%s/identities/%s", config.INFISICAL_URL, authMethod, identityId))
+
+	if err != nil {
+		return fmt.Errorf("CallAttachIdentityAuthV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallAttachIdentityAuthV1", response)
+	}
+
+	return nil
+}
+
+func CallDetachIdentityAuthV1(httpClient *resty.Client, identityId string, authMethod string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		Delete(fmt.Sprintf("%v/v1/auth/%s/identities/%s", config.INFISICAL_URL, authMethod, identityId))
+
+	if err != nil {
+		return fmt.Errorf("CallDetachIdentityAuthV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallDetachIdentityAuthV1", response)
+	}
+
+	return nil
+}
+
+// CallAddProjectIdentityMembershipV2 adds an identity to a project with the given role
+func CallAddProjectIdentityMembershipV2(httpClient *resty.Client, projectId string, identityId string, role string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(ProjectIdentityRole{Role: role}).
+		Post(fmt.Sprintf("%v/v2/workspace/%s/identity-memberships/%s", config.INFISICAL_URL, projectId, identityId))
+
+	if err != nil {
+		return fmt.Errorf("CallAddProjectIdentityMembershipV2: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallAddProjectIdentityMembershipV2", response)
+	}
+
+	return nil
+}
+
+func CallUpdateProjectIdentityMembershipV2(httpClient *resty.Client, request UpdateProjectIdentityMembershipRequest) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Patch(fmt.Sprintf("%v/v2/workspace/%s/identity-memberships/%s", config.INFISICAL_URL, request.ProjectId, request.IdentityId))
+
+	if err != nil {
+		return fmt.Errorf("CallUpdateProjectIdentityMembershipV2: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallUpdateProjectIdentityMembershipV2", response)
+	}
+
+	return nil
+}
+
+func CallDeleteProjectIdentityMembershipV2(httpClient *resty.Client, projectId string, identityId string) error {
+	response, err := httpClient.
+		R().
+		SetHeader("User-Agent", USER_AGENT).
+		Delete(fmt.Sprintf("%v/v2/workspace/%s/identity-memberships/%s", config.INFISICAL_URL, projectId, identityId))
+
+	if err != nil {
+		return fmt.Errorf("CallDeleteProjectIdentityMembershipV2: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return NewAPIError("CallDeleteProjectIdentityMembershipV2", response)
 	}
 
 	return nil
 }
+
+// CallGetKmsKeyByNameV1 returns the KMS key of a project by name
+func CallGetKmsKeyByNameV1(httpClient *resty.Client, projectId string, name string) (KmsKey, error) {
+	var keyResponse GetKmsKeyResponse
+	response, err := httpClient.
+		R().
+		SetResult(&keyResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("projectId", projectId).
+		Get(fmt.Sprintf("%v/v1/kms/keys/key-name/%s", config.INFISICAL_URL, url.PathEscape(name)))
+
+	if err != nil {
+		return KmsKey{}, fmt.Errorf("CallGetKmsKeyByNameV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return KmsKey{}, NewAPIError("CallGetKmsKeyByNameV1", response)
+	}
+
+	return keyResponse.Key, nil
+}
+
+// CallKmsEncryptV1 encrypts base64 encoded data with a KMS key
+func CallKmsEncryptV1(httpClient *resty.Client, keyId string, request KmsEncryptRequest) (KmsEncryptResponse, error) {
+	var encryptResponse KmsEncryptResponse
+	response, err := httpClient.
+		R().
+		SetResult(&encryptResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/kms/keys/%s/encrypt", config.INFISICAL_URL, keyId))
+
+	if err != nil {
+		return KmsEncryptResponse{}, fmt.Errorf("CallKmsEncryptV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return KmsEncryptResponse{}, NewAPIError("CallKmsEncryptV1", response)
+	}
+
+	return encryptResponse, nil
+}
+
+// CallKmsDecryptV1 decrypts a ciphertext of a KMS key to base64 encoded data
+func CallKmsDecryptV1(httpClient *resty.Client, keyId string, request KmsDecryptRequest) (KmsDecryptResponse, error) {
+	var decryptResponse KmsDecryptResponse
+	response, err := httpClient.
+		R().
+		SetResult(&decryptResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/kms/keys/%s/decrypt", config.INFISICAL_URL, keyId))
+
+	if err != nil {
+		return KmsDecryptResponse{}, fmt.Errorf("CallKmsDecryptV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return KmsDecryptResponse{}, NewAPIError("CallKmsDecryptV1", response)
+	}
+
+	return decryptResponse, nil
+}
+
+// CallKmsSignV1 signs base64 encoded data, or its digest, with a KMS key
+func CallKmsSignV1(httpClient *resty.Client, keyId string, request KmsSignRequest) (KmsSignResponse, error) {
+	var signResponse KmsSignResponse
+	response, err := httpClient.
+		R().
+		SetResult(&signResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/kms/keys/%s/sign", config.INFISICAL_URL, keyId))
+
+	if err != nil {
+		return KmsSignResponse{}, fmt.Errorf("CallKmsSignV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return KmsSignResponse{}, NewAPIError("CallKmsSignV1", response)
+	}
+
+	return signResponse, nil
+}
+
+// CallKmsVerifyV1 verifies a signature of base64 encoded data, or of its digest, with a KMS key
+func CallKmsVerifyV1(httpClient *resty.Client, keyId string, request KmsVerifyRequest) (KmsVerifyResponse, error) {
+	var verifyResponse KmsVerifyResponse
+	response, err := httpClient.
+		R().
+		SetResult(&verifyResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/kms/keys/%s/verify", config.INFISICAL_URL, keyId))
+
+	if err != nil {
+		return KmsVerifyResponse{}, fmt.Errorf("CallKmsVerifyV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return KmsVerifyResponse{}, NewAPIError("CallKmsVerifyV1", response)
+	}
+
+	return verifyResponse, nil
+}
+
+// CallGetKmsSigningAlgorithmsV1 returns the algorithms a KMS signing key can sign with
+func CallGetKmsSigningAlgorithmsV1(httpClient *resty.Client, keyId string) ([]string, error) {
+	var algorithmsResponse GetKmsSigningAlgorithmsResponse
+	response, err := httpClient.
+		R().
+		SetResult(&algorithmsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		Get(fmt.Sprintf("%v/v1/kms/keys/%s/signing-algorithms", config.INFISICAL_URL, keyId))
+
+	if err != nil {
+		return nil, fmt.Errorf("CallGetKmsSigningAlgorithmsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return nil, NewAPIError("CallGetKmsSigningAlgorithmsV1", response)
+	}
+
+	return algorithmsResponse.SigningAlgorithms, nil
+}
+
+func CallGetSecretRotationsV1(httpClient *resty.Client, workspaceId string) (GetSecretRotationsV1Response, error) {
+	var rotationsResponse GetSecretRotationsV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&rotationsResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetQueryParam("workspaceId", workspaceId).
+		Get(fmt.Sprintf("%v/v1/secret-rotations", config.INFISICAL_URL))
+
+	if err != nil {
+		return GetSecretRotationsV1Response{}, fmt.Errorf("CallGetSecretRotationsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return GetSecretRotationsV1Response{}, NewAPIError("CallGetSecretRotationsV1", response)
+	}
+
+	return rotationsResponse, nil
+}
+
+// CallRestartSecretRotationV1 rotates the secrets of a rotation now, in the background
+func CallRestartSecretRotationV1(httpClient *resty.Client, request RestartSecretRotationV1Request) (RestartSecretRotationV1Response, error) {
+	var restartResponse RestartSecretRotationV1Response
+	response, err := httpClient.
+		R().
+		SetResult(&restartResponse).
+		SetHeader("User-Agent", USER_AGENT).
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/secret-rotations/restart", config.INFISICAL_URL))
+
+	if err != nil {
+		return RestartSecretRotationV1Response{}, fmt.Errorf("CallRestartSecretRotationV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		return RestartSecretRotationV1Response{}, NewAPIError("CallRestartSecretRotationV1", response)
+	}
+
+	return restartResponse, nil
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// ErrCircuitOpen is returned without contacting Infisical while the circuit breaker is open
+var ErrCircuitOpen = errors.New("the Infisical API is unavailable, requests are paused while it recovers")
+
+// CircuitBreaker stops sending requests after FailureThreshold consecutive failures. Once
+// OpenDuration has passed a single probe request is let through; if it succeeds the breaker closes,
+// otherwise it stays open for another OpenDuration. Only transport errors and 5xx responses count
+// as failures, since anything else means the API is up.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenDuration     time.Duration
+
+	mutex               sync.Mutex
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	// probe is the request let through while half-open. Resty runs the before request hooks again
+	// for every retry, so its retries are let through too.
+	probe          *resty.Request
+	listeners      map[int]func(CircuitState)
+	nextListenerID int
+}
+
+// DefaultCircuitBreaker is shared by every client created with NewHTTPClient, so all commands and
+// the gateway back off together when the API is degraded.
+var DefaultCircuitBreaker = NewCircuitBreaker(5, 30*time.Second)
+
+func NewCircuitBreaker(failureThreshold int, openDuration time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenDuration:     openDuration,
+		state:            CircuitClosed,
+		listeners:        map[int]func(CircuitState){},
+	}
+}
+
+func (b *CircuitBreaker) State() CircuitState {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// OnStateChange registers fn to be called after every state transition. Call the returned function to
+// unregister it.
+func (b *CircuitBreaker) OnStateChange(fn func(CircuitState)) func() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	id := b.nextListenerID
+	b.nextListenerID++
+	b.listeners[id] = fn
+
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		delete(b.listeners, id)
+	}
+}
+
+func (b *CircuitBreaker) allow(request *resty.Request) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.OpenDuration {
+			return ErrCircuitOpen
+		}
+		b.setState(CircuitHalfOpen)
+		b.probe = request
+		return nil
+	case CircuitHalfOpen:
+		if b.probe != nil && b.probe != request {
+			return ErrCircuitOpen
+		}
+		b.probe = request
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record counts the outcome of a request once resty is done retrying it
+func (b *CircuitBreaker) record(request *resty.Request, success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.probe == request {
+		b.probe = nil
+	}
+
+	if success {
+		b.consecutiveFailures = 0
+		if b.state != CircuitClosed {
+			b.setState(CircuitClosed)
+		}
+		return
+	}
+
+	b.consecutiveFailures++
+	if b.state == CircuitHalfOpen || (b.state == CircuitClosed && b.consecutiveFailures >= b.FailureThreshold) {
+		b.openedAt = time.Now()
+		b.setState(CircuitOpen)
+	}
+}
+
+// release lets another request probe the API when request was the probe but didn't reach it, e.g.
+// because its caller cancelled it
+func (b *CircuitBreaker) release(request *resty.Request) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.probe == request {
+		b.probe = nil
+	}
+}
+
+// setState must be called with the mutex held
+func (b *CircuitBreaker) setState(state CircuitState) {
+	b.state = state
+	log.Debug().Msgf("Infisical API circuit breaker is now %s", state)
+	for _, listener := range b.listeners {
+		go listener(state)
+	}
+}
+
+func (b *CircuitBreaker) attach(httpClient *resty.Client) {
+	httpClient.
+		OnBeforeRequest(func(_ *resty.Client, request *resty.Request) error {
+			return b.allow(request)
+		}).
+		OnSuccess(func(_ *resty.Client, response *resty.Response) {
+			b.record(response.Request, response.StatusCode() < http.StatusInternalServerError)
+		}).
+		OnError(func(request *resty.Request, err error) {
+			// requests refused by the breaker or cancelled by their caller say nothing about the API
+			if errors.Is(err, ErrCircuitOpen) || errors.Is(err, context.Canceled) {
+				b.release(request)
+				return
+			}
+			b.record(request, false)
+		})
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// expireOpenDuration makes an open breaker let the next request probe the API
+func expireOpenDuration(b *CircuitBreaker) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.openedAt = time.Now().Add(-b.OpenDuration)
+}
+
+func TestCircuitBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	breaker := NewCircuitBreaker(3, time.Minute)
+	request := &resty.Request{}
+
+	breaker.record(request, false)
+	breaker.record(request, false)
+	breaker.record(request, true)
+	breaker.record(request, false)
+	breaker.record(request, false)
+	assert.Equal(t, CircuitClosed, breaker.State(), "a success resets the count")
+
+	breaker.record(request, false)
+	assert.Equal(t, CircuitOpen, breaker.State())
+	assert.ErrorIs(t, breaker.allow(&resty.Request{}), ErrCircuitOpen)
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name         string
+		probeSuccess bool
+		want         CircuitState
+	}{
+		{"probe succeeds", true, CircuitClosed},
+		{"probe fails", false, CircuitOpen},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			breaker := NewCircuitBreaker(1, time.Minute)
+			breaker.record(&resty.Request{}, false)
+			expireOpenDuration(breaker)
+
+			probe, other := &resty.Request{}, &resty.Request{}
+			assert.NoError(t, breaker.allow(probe))
+			assert.Equal(t, CircuitHalfOpen, breaker.State())
+			assert.ErrorIs(t, breaker.allow(other), ErrCircuitOpen, "only one probe at a time")
+			assert.NoError(t, breaker.allow(probe), "retries of the probe are let through")
+
+			breaker.record(probe, test.probeSuccess)
+			assert.Equal(t, test.want, breaker.State())
+		})
+	}
+}
+
+func TestCircuitBreakerReleasesAbandonedProbe(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute)
+	breaker.record(&resty.Request{}, false)
+	expireOpenDuration(breaker)
+
+	probe, other := &resty.Request{}, &resty.Request{}
+	assert.NoError(t, breaker.allow(probe))
+
+	breaker.release(other)
+	assert.ErrorIs(t, breaker.allow(other), ErrCircuitOpen, "only the probe releases itself")
+
+	breaker.release(probe)
+	assert.NoError(t, breaker.allow(other))
+	assert.Equal(t, CircuitHalfOpen, breaker.State())
+}
+
+func TestCircuitBreakerWithRetryingClient(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	breaker := NewCircuitBreaker(1, time.Minute)
+	httpClient := ApplyRetryPolicy(resty.New(), RetryPolicy{MaxRetries: 2, WaitTime: time.Millisecond, MaxWaitTime: time.Millisecond})
+	breaker.attach(httpClient)
+
+	_, err := httpClient.R().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	_, err = httpClient.R().Get(server.URL)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	// the retries of a failing probe are let through, and the breaker opens again once it gives up
+	expireOpenDuration(breaker)
+	response, err := httpClient.R().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode())
+	assert.Equal(t, 3, response.Request.Attempt)
+	assert.Equal(t, CircuitOpen, breaker.State())
+
+	_, err = httpClient.R().Get(server.URL)
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+
+	failing.Store(false)
+	expireOpenDuration(breaker)
+	response, err = httpClient.R().Get(server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode())
+	assert.Equal(t, CircuitClosed, breaker.State())
+}
+
+func TestCircuitBreakerRemoveListener(t *testing.T) {
+	breaker := NewCircuitBreaker(1, time.Minute)
+
+	states := make(chan CircuitState, 4)
+	remove := breaker.OnStateChange(func(state CircuitState) { states <- state })
+
+	breaker.record(&resty.Request{}, false)
+	select {
+	case state := <-states:
+		assert.Equal(t, CircuitOpen, state)
+	case <-time.After(time.Second):
+		t.Fatal("the listener wasn't called")
+	}
+
+	remove()
+	breaker.record(&resty.Request{}, true)
+	assert.Equal(t, CircuitClosed, breaker.State())
+
+	breaker.mutex.Lock()
+	assert.Empty(t, breaker.listeners)
+	breaker.mutex.Unlock()
+}
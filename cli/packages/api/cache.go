@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+)
+
+const (
+	maxCachedResponses    = 256
+	maxCachedResponseSize = 5 << 20
+)
+
+// DisableResponseCache turns off conditional GET requests. Mainly useful to rule the cache out
+// while debugging.
+var DisableResponseCache bool
+
+type cachedResponse struct {
+	key    string
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// responseCache remembers the last response with an ETag for each GET, keyed by URL and
+// credentials so different identities never share entries, and evicts the least recently used.
+type responseCache struct {
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+var defaultResponseCache = &responseCache{
+	entries: map[string]*list.Element{},
+	order:   list.New(),
+}
+
+func (c *responseCache) get(key string) *cachedResponse {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cachedResponse)
+}
+
+func (c *responseCache) put(entry *cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[entry.key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > maxCachedResponses {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedResponse).key)
+	}
+}
+
+// cachingTransport sends If-None-Match for GETs it has seen before and turns a 304 back into the
+// cached 200, so callers and resty's result parsing never see the difference.
+type cachingTransport struct {
+	next  http.RoundTripper
+	cache *responseCache
+}
+
+func (t *cachingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Method != http.MethodGet || DisableResponseCache {
+		return t.next.RoundTrip(request)
+	}
+
+	key := responseCacheKey(request)
+	cached := t.cache.get(key)
+	if cached != nil {
+		request = request.Clone(request.Context())
+		request.Header.Set("If-None-Match", cached.etag)
+	}
+
+	response, err := t.next.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		response.Body.Close()
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         response.Proto,
+			ProtoMajor:    response.ProtoMajor,
+			ProtoMinor:    response.ProtoMinor,
+			Header:        cached.header.Clone(),
+			Body:          io.NopCloser(bytes.NewReader(cached.body)),
+			ContentLength: int64(len(cached.body)),
+			Request:       request,
+		}, nil
+	}
+
+	etag := response.Header.Get("ETag")
+	if response.StatusCode != http.StatusOK || etag == "" || response.ContentLength > maxCachedResponseSize {
+		return response, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxCachedResponseSize+1))
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(body) <= maxCachedResponseSize {
+		t.cache.put(&cachedResponse{key: key, etag: etag, header: response.Header.Clone(), body: body})
+	}
+
+	response.Body = io.NopCloser(bytes.NewReader(body))
+	return response, nil
+}
+
+func responseCacheKey(request *http.Request) string {
+	hash := sha256.New()
+	hash.Write([]byte(request.URL.String()))
+	hash.Write([]byte{0})
+	hash.Write([]byte(request.Header.Get("Authorization")))
+	return hex.EncodeToString(hash.Sum(nil))
+}
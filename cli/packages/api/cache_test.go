@@ -0,0 +1,87 @@
+package api
+
+import (
+	"container/list"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCachingTransportRevalidates(t *testing.T) {
+	var requests, notModified atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		etag := `"` + r.Header.Get("Authorization") + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `{"identity":%q}`, r.Header.Get("Authorization"))
+	}))
+	defer server.Close()
+
+	transport := &cachingTransport{next: http.DefaultTransport, cache: &responseCache{entries: map[string]*list.Element{}, order: list.New()}}
+	httpClient := resty.New().SetTransport(transport)
+
+	get := func(token string) string {
+		response, err := httpClient.R().SetAuthToken(token).Get(server.URL + "/v3/secrets/raw")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode(), "304s are turned back into the cached 200")
+		return response.String()
+	}
+
+	assert.Equal(t, `{"identity":"Bearer a"}`, get("a"))
+	assert.Equal(t, `{"identity":"Bearer a"}`, get("a"))
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// another identity never gets the cached response of the first
+	assert.Equal(t, `{"identity":"Bearer b"}`, get("b"))
+	assert.Equal(t, int32(1), notModified.Load())
+	assert.Equal(t, int32(3), requests.Load())
+}
+
+func TestCachingTransportSkipsWrites(t *testing.T) {
+	var conditional atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") != "" {
+			conditional.Add(1)
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, "{}")
+	}))
+	defer server.Close()
+
+	transport := &cachingTransport{next: http.DefaultTransport, cache: &responseCache{entries: map[string]*list.Element{}, order: list.New()}}
+	httpClient := resty.New().SetTransport(transport)
+
+	for i := 0; i < 2; i++ {
+		_, err := httpClient.R().SetBody(map[string]string{}).Post(server.URL + "/v3/secrets/raw/A")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(0), conditional.Load())
+	assert.Equal(t, 0, transport.cache.order.Len())
+}
+
+func TestResponseCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := &responseCache{entries: map[string]*list.Element{}, order: list.New()}
+
+	for i := 0; i < maxCachedResponses; i++ {
+		cache.put(&cachedResponse{key: fmt.Sprint(i), etag: fmt.Sprint(i)})
+	}
+
+	// touching the oldest entry makes the second oldest the one evicted
+	assert.NotNil(t, cache.get("0"))
+	cache.put(&cachedResponse{key: "new"})
+
+	assert.Equal(t, maxCachedResponses, cache.order.Len())
+	assert.NotNil(t, cache.get("0"))
+	assert.Nil(t, cache.get("1"))
+	assert.NotNil(t, cache.get("new"))
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// RetryPolicy controls how API calls are retried after transport errors and transient server
+// errors. Waits grow exponentially from WaitTime up to MaxWaitTime, with jitter.
+type RetryPolicy struct {
+	MaxRetries  int
+	WaitTime    time.Duration
+	MaxWaitTime time.Duration
+	// MaxRetryAfter bounds how long a rate limited request waits for its Retry-After. Requests asked
+	// to wait longer fail straight away.
+	MaxRetryAfter time.Duration
+	// RetryNonIdempotent also retries POST and PATCH requests that may have reached the server.
+	// Only enable it for clients whose calls are safe to repeat.
+	RetryNonIdempotent bool
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:    3,
+	WaitTime:      500 * time.Millisecond,
+	MaxWaitTime:   10 * time.Second,
+	MaxRetryAfter: 60 * time.Second,
+}
+
+// Timeouts bound the phases of a call to Infisical. Request covers a single attempt end to end,
+// retries get a fresh budget. Zero disables a timeout.
+type Timeouts struct {
+	Connect        time.Duration
+	TLSHandshake   time.Duration
+	ResponseHeader time.Duration
+	Request        time.Duration
+}
+
+var DefaultTimeouts = Timeouts{
+	Connect:        10 * time.Second,
+	TLSHandshake:   10 * time.Second,
+	ResponseHeader: 30 * time.Second,
+	Request:        2 * time.Minute,
+}
+
+// NewHTTPClient returns a resty client configured with the default retry policy and timeouts. Use it
+// instead of resty.New() for calls to Infisical.
+func NewHTTPClient() *resty.Client {
+	return NewHTTPClientWithRetryPolicy(DefaultRetryPolicy)
+}
+
+func NewHTTPClientWithRetryPolicy(policy RetryPolicy) *resty.Client {
+	return NewHTTPClientWithCircuitBreaker(policy, DefaultCircuitBreaker)
+}
+
+// NewHTTPClientWithCircuitBreaker returns a client that backs off with breaker instead of the breaker
+// shared by the other clients of the process
+func NewHTTPClientWithCircuitBreaker(policy RetryPolicy, breaker *CircuitBreaker) *resty.Client {
+	httpClient := ApplyTransport(ApplyRetryPolicy(resty.New(), policy), DefaultTimeouts)
+	breaker.attach(httpClient)
+	if DebugHTTP {
+		applyDebugLogging(httpClient)
+	}
+	return httpClient
+}
+
+// ApplyTransport sets up the client's transport with the given timeouts and the process wide
+// connection settings loaded by ConfigureConnection. GETs that return an ETag are cached in memory
+// and revalidated with If-None-Match.
+func ApplyTransport(httpClient *resty.Client, timeouts Timeouts) *resty.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if connectionTLSConfig != nil {
+		transport.TLSClientConfig = connectionTLSConfig.Clone()
+	}
+	if connectionProxy != nil {
+		transport.Proxy = connectionProxy
+	}
+	transport.DialContext = (&net.Dialer{
+		Timeout:   timeouts.Connect,
+		KeepAlive: 30 * time.Second,
+	}).DialContext
+	transport.TLSHandshakeTimeout = timeouts.TLSHandshake
+	transport.ResponseHeaderTimeout = timeouts.ResponseHeader
+
+	return httpClient.
+		SetTransport(&cachingTransport{next: transport, cache: defaultResponseCache}).
+		SetTimeout(timeouts.Request)
+}
+
+func ApplyRetryPolicy(httpClient *resty.Client, policy RetryPolicy) *resty.Client {
+	return httpClient.
+		SetLogger(restyLogger{}).
+		SetRetryCount(policy.MaxRetries).
+		SetRetryWaitTime(policy.WaitTime).
+		SetRetryMaxWaitTime(maxDuration(policy.MaxWaitTime, policy.MaxRetryAfter)).
+		SetRetryAfter(func(_ *resty.Client, response *resty.Response) (time.Duration, error) {
+			return retryWaitTime(response, policy)
+		}).
+		AddRetryCondition(func(response *resty.Response, err error) bool {
+			return shouldRetry(response, err, policy.RetryNonIdempotent)
+		}).
+		AddRetryHook(func(response *resty.Response, err error) {
+			if response != nil && response.Request != nil {
+				log.Debug().Msgf("Retrying %s %s [attempt=%d] [err=%v]", response.Request.Method, response.Request.URL, response.Request.Attempt, err)
+			}
+		})
+}
+
+func shouldRetry(response *resty.Response, err error, retryNonIdempotent bool) bool {
+	if response == nil || response.Request == nil {
+		return err != nil && isConnectionRefusedBeforeSend(err)
+	}
+
+	idempotent := retryNonIdempotent || isIdempotentMethod(response.Request.Method)
+
+	if err != nil {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return false
+		}
+		// Dial failures never reached the server, so even a POST is safe to send again
+		return idempotent || isConnectionRefusedBeforeSend(err)
+	}
+
+	switch response.StatusCode() {
+	case http.StatusTooManyRequests:
+		// Rate limited requests were rejected before being processed
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+
+	return false
+}
+
+// retryWaitTime honors Retry-After on rate limited responses and otherwise backs off exponentially
+// with jitter, capped at the policy's MaxWaitTime.
+func retryWaitTime(response *resty.Response, policy RetryPolicy) (time.Duration, error) {
+	attempt := 1
+	if response.Request != nil {
+		attempt = response.Request.Attempt
+	}
+
+	if response.StatusCode() == http.StatusTooManyRequests {
+		if waitTime, ok := parseRetryAfter(response.Header().Get("Retry-After")); ok {
+			if waitTime > policy.MaxRetryAfter {
+				return 0, fmt.Errorf("rate limited by Infisical, retry after %s exceeds the %s limit", waitTime, policy.MaxRetryAfter)
+			}
+			log.Warn().Msgf("Rate limited by Infisical, retrying in %s [attempt=%d/%d]", waitTime.Round(time.Second), attempt, policy.MaxRetries)
+			// Resty treats a zero wait as "use the default backoff"
+			return maxDuration(waitTime, time.Millisecond), nil
+		}
+	}
+
+	backoff := policy.WaitTime << uint(attempt-1)
+	if backoff <= 0 || backoff > policy.MaxWaitTime {
+		backoff = policy.MaxWaitTime
+	}
+	waitTime := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+	if response.StatusCode() == http.StatusTooManyRequests {
+		log.Warn().Msgf("Rate limited by Infisical, retrying in %s [attempt=%d/%d]", waitTime.Round(time.Millisecond), attempt, policy.MaxRetries)
+	}
+
+	return waitTime, nil
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if retryAt, err := http.ParseTime(value); err == nil {
+		waitTime := time.Until(retryAt)
+		if waitTime < 0 {
+			waitTime = 0
+		}
+		return waitTime, true
+	}
+
+	return 0, false
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isConnectionRefusedBeforeSend(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsTemporary
+}
+
+// restyLogger sends resty's internal logging to the CLI's debug output instead of stderr
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...interface{}) {
+	log.Debug().Msgf(format, v...)
+}
+
+func (restyLogger) Warnf(format string, v ...interface{}) {
+	log.Debug().Msgf(format, v...)
+}
+
+func (restyLogger) Debugf(format string, v ...interface{}) {
+	log.Debug().Msgf(format, v...)
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResponse(method string, statusCode int, attempt int) *resty.Response {
+	return &resty.Response{
+		Request:     &resty.Request{Method: method, Attempt: attempt},
+		RawResponse: &http.Response{StatusCode: statusCode, Header: http.Header{}},
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name               string
+		response           *resty.Response
+		err                error
+		retryNonIdempotent bool
+		want               bool
+	}{
+		{"GET on 503", testResponse(http.MethodGet, http.StatusServiceUnavailable, 1), nil, false, true},
+		{"GET on 502", testResponse(http.MethodGet, http.StatusBadGateway, 1), nil, false, true},
+		{"GET on 500", testResponse(http.MethodGet, http.StatusInternalServerError, 1), nil, false, false},
+		{"GET on 404", testResponse(http.MethodGet, http.StatusNotFound, 1), nil, false, false},
+		{"POST on 503", testResponse(http.MethodPost, http.StatusServiceUnavailable, 1), nil, false, false},
+		{"POST on 503 when safe to repeat", testResponse(http.MethodPost, http.StatusServiceUnavailable, 1), nil, true, true},
+		{"GET on a reset connection", testResponse(http.MethodGet, 0, 1), readErr, false, true},
+		{"POST on a reset connection", testResponse(http.MethodPost, 0, 1), readErr, false, false},
+		{"POST that failed to dial", testResponse(http.MethodPost, 0, 1), dialErr, false, true},
+		{"cancelled GET", testResponse(http.MethodGet, 0, 1), context.Canceled, false, false},
+		{"GET past its deadline", testResponse(http.MethodGet, 0, 1), context.DeadlineExceeded, false, false},
+		{"no response after a dial failure", nil, dialErr, false, true},
+		{"no response after another failure", nil, readErr, false, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, shouldRetry(test.response, test.err, test.retryNonIdempotent))
+		})
+	}
+}
+
+func TestRetryWaitTimeBacksOffExponentially(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 5, WaitTime: 100 * time.Millisecond, MaxWaitTime: time.Second, MaxRetryAfter: time.Minute}
+
+	tests := []struct {
+		attempt int
+		backoff time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{40, time.Second},
+	}
+
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			waitTime, err := retryWaitTime(testResponse(http.MethodGet, http.StatusServiceUnavailable, test.attempt), policy)
+			assert.NoError(t, err)
+			// jitter keeps the wait between half the backoff and the backoff
+			assert.GreaterOrEqual(t, waitTime, test.backoff/2, "attempt %d", test.attempt)
+			assert.LessOrEqual(t, waitTime, test.backoff, "attempt %d", test.attempt)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOk bool
+		approx bool
+	}{
+		{"", 0, false, false},
+		{"120", 2 * time.Minute, true, false},
+		{"0", 0, true, false},
+		{"-3", 0, false, false},
+		{"soon", 0, false, false},
+		{time.Now().Add(90 * time.Second).UTC().Format(http.TimeFormat), 90 * time.Second, true, true},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true, false},
+	}
+
+	for _, test := range tests {
+		waitTime, ok := parseRetryAfter(test.value)
+		assert.Equal(t, test.wantOk, ok, test.value)
+		if test.approx {
+			assert.InDelta(t, test.want.Seconds(), waitTime.Seconds(), 2, test.value)
+		} else {
+			assert.Equal(t, test.want, waitTime, test.value)
+		}
+	}
+}
+
+func TestRetryRateLimited(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, WaitTime: 100 * time.Millisecond, MaxWaitTime: time.Second, MaxRetryAfter: time.Minute}
+
+	// rate limited requests were never processed, so even a POST is retried
+	assert.True(t, shouldRetry(testResponse(http.MethodPost, http.StatusTooManyRequests, 1), nil, false))
+
+	rateLimited := testResponse(http.MethodGet, http.StatusTooManyRequests, 1)
+	rateLimited.RawResponse.Header.Set("Retry-After", "30")
+	waitTime, err := retryWaitTime(rateLimited, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, waitTime)
+
+	rateLimited.RawResponse.Header.Set("Retry-After", "0")
+	waitTime, err = retryWaitTime(rateLimited, policy)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Millisecond, waitTime, "a zero wait would make resty use its own backoff")
+
+	rateLimited.RawResponse.Header.Set("Retry-After", "3600")
+	_, err = retryWaitTime(rateLimited, policy)
+	assert.ErrorContains(t, err, "exceeds the 1m0s limit")
+
+	// without Retry-After, rate limited requests back off like any other
+	rateLimited.RawResponse.Header.Del("Retry-After")
+	waitTime, err = retryWaitTime(rateLimited, policy)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, waitTime, policy.WaitTime)
+}
+
+func TestApplyTransportTimeouts(t *testing.T) {
+	timeouts := Timeouts{
+		Connect:        time.Second,
+		TLSHandshake:   2 * time.Second,
+		ResponseHeader: 3 * time.Second,
+		Request:        4 * time.Second,
+	}
+	httpClient := ApplyTransport(resty.New(), timeouts)
+
+	caching, ok := httpClient.GetClient().Transport.(*cachingTransport)
+	assert.True(t, ok)
+	transport, ok := caching.next.(*http.Transport)
+	assert.True(t, ok)
+
+	assert.Equal(t, timeouts.TLSHandshake, transport.TLSHandshakeTimeout)
+	assert.Equal(t, timeouts.ResponseHeader, transport.ResponseHeaderTimeout)
+	assert.Equal(t, timeouts.Request, httpClient.GetClient().Timeout)
+}
+
+func TestGatewayCallsHonorTheirContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	previousURL := config.INFISICAL_URL
+	config.INFISICAL_URL = server.URL
+	defer func() { config.INFISICAL_URL = previousURL }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := CallGatewayHeartBeatV1(ctx, ApplyTransport(resty.New(), DefaultTimeouts))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), DefaultTimeouts.ResponseHeader)
+}
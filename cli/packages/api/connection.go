@@ -0,0 +1,118 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// ConnectionSettings customise how the CLI reaches Infisical, e.g. behind a corporate proxy or
+// when a self-hosted instance uses a private CA. Empty fields keep Go's defaults, which already
+// honor HTTPS_PROXY and SSL_CERT_FILE.
+type ConnectionSettings struct {
+	// CACertPath is a PEM bundle trusted in addition to the system roots.
+	CACertPath string
+	// ClientCertPath and ClientKeyPath enable mutual TLS with the API.
+	ClientCertPath string
+	ClientKeyPath  string
+	// ProxyURL overrides the proxy from the environment. Only HTTP(S) proxies with basic
+	// authentication are supported; NTLM and Negotiate are not.
+	ProxyURL      string
+	ProxyUsername string
+	ProxyPassword string
+}
+
+var (
+	connectionTLSConfig *tls.Config
+	connectionProxy     func(*http.Request) (*url.URL, error)
+)
+
+// ConfigureConnection loads the given settings and applies them to every client created afterwards
+// by NewHTTPClient or ApplyTransport.
+func ConfigureConnection(settings ConnectionSettings) error {
+	tlsConfig, err := buildConnectionTLSConfig(settings)
+	if err != nil {
+		return err
+	}
+
+	proxy, err := buildConnectionProxy(settings)
+	if err != nil {
+		return err
+	}
+
+	connectionTLSConfig = tlsConfig
+	connectionProxy = proxy
+	return nil
+}
+
+// HasClientCertificate reports whether a client certificate was configured for mutual TLS
+func HasClientCertificate() bool {
+	return connectionTLSConfig != nil && len(connectionTLSConfig.Certificates) > 0
+}
+
+func buildConnectionTLSConfig(settings ConnectionSettings) (*tls.Config, error) {
+	if settings.CACertPath == "" && settings.ClientCertPath == "" && settings.ClientKeyPath == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if settings.CACertPath != "" {
+		caCertPEM, err := os.ReadFile(settings.CACertPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA certificate: %w", err)
+		}
+
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil || rootCAs == nil {
+			rootCAs = x509.NewCertPool()
+		}
+
+		if !rootCAs.AppendCertsFromPEM(caCertPEM) {
+			return nil, fmt.Errorf("no valid certificates found in %s", settings.CACertPath)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if settings.ClientCertPath != "" || settings.ClientKeyPath != "" {
+		if settings.ClientCertPath == "" || settings.ClientKeyPath == "" {
+			return nil, fmt.Errorf("both a client certificate and a client key are required for mutual TLS")
+		}
+
+		clientCert, err := tls.LoadX509KeyPair(settings.ClientCertPath, settings.ClientKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	return tlsConfig, nil
+}
+
+func buildConnectionProxy(settings ConnectionSettings) (func(*http.Request) (*url.URL, error), error) {
+	if settings.ProxyURL == "" {
+		if settings.ProxyUsername != "" {
+			return nil, fmt.Errorf("proxy credentials were provided without a proxy URL")
+		}
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(settings.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected http or https", proxyURL.Scheme)
+	}
+
+	// Go sends URL credentials as Proxy-Authorization: Basic, both for plain requests and CONNECT
+	if settings.ProxyUsername != "" {
+		proxyURL.User = url.UserPassword(settings.ProxyUsername, settings.ProxyPassword)
+	}
+
+	return http.ProxyURL(proxyURL), nil
+}
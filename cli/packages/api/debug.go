@@ -0,0 +1,142 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// DebugHTTP logs every API request and response to stderr with credentials and secret values redacted
+var DebugHTTP bool
+
+const (
+	redactedValue      = "[REDACTED]"
+	maxDebugBodyLength = 4096
+)
+
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+}
+
+// Keys are compared lowercased with - and _ removed
+var sensitiveKeyFragments = []string{"token", "password", "passphrase", "privatekey", "clientsecret", "secretvalue", "apikey", "jwt", "credential", "encryptionkey", "mfa"}
+
+var nonSensitiveKeys = map[string]bool{"secretkey": true, "secretname": true, "secretpath": true, "tokentype": true}
+
+var (
+	privateKeyPattern = regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)
+	jwtPattern        = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+)
+
+func applyDebugLogging(httpClient *resty.Client) {
+	httpClient.OnBeforeRequest(func(_ *resty.Client, request *resty.Request) error {
+		var body string
+		if request.Body != nil {
+			if encoded, err := json.Marshal(request.Body); err == nil {
+				body = string(encoded)
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "[http] --> %s %s\n%s%s", request.Method, request.URL, formatDebugHeaders(request.Header), formatDebugBody(body))
+		return nil
+	})
+
+	httpClient.OnAfterResponse(func(_ *resty.Client, response *resty.Response) error {
+		fmt.Fprintf(os.Stderr, "[http] <-- %d %s %s (%s)\n%s%s", response.StatusCode(), response.Request.Method, response.Request.URL, response.Time().Round(time.Millisecond), formatDebugHeaders(response.Header()), formatDebugBody(response.String()))
+		return nil
+	})
+
+	httpClient.OnError(func(request *resty.Request, err error) {
+		fmt.Fprintf(os.Stderr, "[http] <-- error %s %s: %s\n", request.Method, request.URL, RedactString(err.Error()))
+	})
+}
+
+func formatDebugHeaders(headers http.Header) string {
+	var builder strings.Builder
+	for name, values := range headers {
+		value := strings.Join(values, ", ")
+		if sensitiveHeaders[strings.ToLower(name)] {
+			value = redactedValue
+		}
+		fmt.Fprintf(&builder, "    %s: %s\n", name, value)
+	}
+	return builder.String()
+}
+
+func formatDebugBody(body string) string {
+	if body == "" {
+		return ""
+	}
+
+	body = RedactBody(body)
+	if len(body) > maxDebugBodyLength {
+		body = body[:maxDebugBodyLength] + fmt.Sprintf("... (%d bytes truncated)", len(body)-maxDebugBodyLength)
+	}
+	return fmt.Sprintf("    %s\n", body)
+}
+
+// RedactBody removes credentials and secret values from a request or response body. JSON bodies
+// are redacted by key; anything else falls back to pattern matching.
+func RedactBody(body string) string {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(body), &decoded); err != nil {
+		return RedactString(body)
+	}
+
+	encoded, err := json.Marshal(redactJSONValue(decoded))
+	if err != nil {
+		return RedactString(body)
+	}
+	return string(encoded)
+}
+
+func RedactString(value string) string {
+	value = privateKeyPattern.ReplaceAllString(value, redactedValue)
+	return jwtPattern.ReplaceAllString(value, redactedValue)
+}
+
+func redactJSONValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, nested := range typed {
+			if isSensitiveKey(key) {
+				typed[key] = redactedValue
+				continue
+			}
+			typed[key] = redactJSONValue(nested)
+		}
+		return typed
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = redactJSONValue(nested)
+		}
+		return typed
+	case string:
+		return RedactString(typed)
+	default:
+		return typed
+	}
+}
+
+func isSensitiveKey(key string) bool {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(key))
+	if nonSensitiveKeys[normalized] {
+		return false
+	}
+
+	for _, fragment := range sensitiveKeyFragments {
+		if strings.Contains(normalized, fragment) {
+			return true
+		}
+	}
+	return false
+}
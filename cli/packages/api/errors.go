@@ -0,0 +1,119 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// APIError is returned by the Call* helpers when Infisical responds with an error status. Use
+// errors.As to inspect it; RequestID is what support needs to trace the failing request.
+type APIError struct {
+	Operation  string
+	Method     string
+	URL        string
+	StatusCode int
+	// ErrorCode is Infisical's error class, e.g. "NotFound" or "PermissionDenied"
+	ErrorCode string
+	Message   string
+	RequestID string
+	Body      string
+}
+
+// The error codes of a pending device code login, as in RFC 8628
+const (
+	DEVICE_CODE_AUTHORIZATION_PENDING = "authorization_pending"
+	DEVICE_CODE_SLOW_DOWN             = "slow_down"
+	DEVICE_CODE_ACCESS_DENIED         = "access_denied"
+	DEVICE_CODE_EXPIRED_TOKEN         = "expired_token"
+)
+
+type apiErrorBody struct {
+	RequestID  string      `json:"reqId"`
+	StatusCode int         `json:"statusCode"`
+	Message    interface{} `json:"message"`
+	Error      string      `json:"error"`
+}
+
+func NewAPIError(operation string, response *resty.Response) *APIError {
+	apiErr := &APIError{
+		Operation:  operation,
+		StatusCode: response.StatusCode(),
+		RequestID:  response.Header().Get("x-request-id"),
+		Body:       response.String(),
+	}
+
+	if response.Request != nil {
+		apiErr.Method = response.Request.Method
+		apiErr.URL = response.Request.URL
+	}
+
+	var body apiErrorBody
+	if err := json.Unmarshal(response.Body(), &body); err == nil {
+		apiErr.ErrorCode = body.Error
+		if apiErr.RequestID == "" {
+			apiErr.RequestID = body.RequestID
+		}
+		switch message := body.Message.(type) {
+		case string:
+			apiErr.Message = message
+		case nil:
+		default:
+			// Validation errors come back as a list of issues
+			if encoded, err := json.Marshal(message); err == nil {
+				apiErr.Message = string(encoded)
+			}
+		}
+	}
+
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	details := e.Message
+	if details == "" {
+		details = e.Body
+	}
+
+	msg := fmt.Sprintf("%s: Unsuccessful response [%v %v] [status-code=%v] [response=%v]", e.Operation, e.Method, e.URL, e.StatusCode, details)
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" [request-id=%s]", e.RequestID)
+	}
+	return msg
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+// HasErrorCode reports whether Infisical rejected the request with the given error class
+func HasErrorCode(err error, errorCode string) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode == errorCode
+}
+
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsServerError reports whether the API failed on its side, as opposed to rejecting the request
+func IsServerError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode >= http.StatusInternalServerError
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name          string
+		header        string
+		statusCode    int
+		body          string
+		wantCode      string
+		wantMessage   string
+		wantRequestID string
+	}{
+		{
+			name:          "message",
+			statusCode:    http.StatusNotFound,
+			body:          `{"reqId":"req-1","statusCode":404,"message":"Folder not found","error":"NotFound"}`,
+			wantCode:      "NotFound",
+			wantMessage:   "Folder not found",
+			wantRequestID: "req-1",
+		},
+		{
+			name:          "validation issues",
+			statusCode:    http.StatusUnprocessableEntity,
+			body:          `{"reqId":"req-2","message":[{"path":["secretName"],"message":"Required"}],"error":"ValidationFailure"}`,
+			wantCode:      "ValidationFailure",
+			wantMessage:   `[{"message":"Required","path":["secretName"]}]`,
+			wantRequestID: "req-2",
+		},
+		{
+			name:          "request ID header wins",
+			header:        "req-header",
+			statusCode:    http.StatusForbidden,
+			body:          `{"reqId":"req-body","message":"Forbidden","error":"PermissionDenied"}`,
+			wantCode:      "PermissionDenied",
+			wantMessage:   "Forbidden",
+			wantRequestID: "req-header",
+		},
+		{
+			name:       "not JSON",
+			statusCode: http.StatusBadGateway,
+			body:       "<html>Bad Gateway</html>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.header != "" {
+					w.Header().Set("x-request-id", test.header)
+				}
+				w.WriteHeader(test.statusCode)
+				fmt.Fprint(w, test.body)
+			}))
+			defer server.Close()
+
+			response, err := resty.New().R().Get(server.URL + "/v1/folders")
+			assert.NoError(t, err)
+
+			apiErr := NewAPIError("CallGetFoldersV1", response)
+			assert.Equal(t, test.statusCode, apiErr.StatusCode)
+			assert.Equal(t, test.wantCode, apiErr.ErrorCode)
+			assert.Equal(t, test.wantMessage, apiErr.Message)
+			assert.Equal(t, test.wantRequestID, apiErr.RequestID)
+			assert.Equal(t, http.MethodGet, apiErr.Method)
+			assert.Equal(t, test.body, apiErr.Body)
+
+			if test.wantMessage == "" {
+				assert.Contains(t, apiErr.Error(), test.body, "the body stands in for a missing message")
+			}
+			if test.wantRequestID != "" {
+				assert.Contains(t, apiErr.Error(), "[request-id="+test.wantRequestID+"]")
+			}
+		})
+	}
+}
+
+func TestAPIErrorClassification(t *testing.T) {
+	wrap := func(statusCode int, errorCode string) error {
+		return fmt.Errorf("unable to fetch secrets: %w", &APIError{StatusCode: statusCode, ErrorCode: errorCode})
+	}
+
+	assert.True(t, IsUnauthorized(wrap(http.StatusUnauthorized, "")))
+	assert.True(t, IsForbidden(wrap(http.StatusForbidden, "PermissionDenied")))
+	assert.True(t, IsNotFound(wrap(http.StatusNotFound, "NotFound")))
+	assert.True(t, IsRateLimited(wrap(http.StatusTooManyRequests, "")))
+	assert.True(t, IsServerError(wrap(http.StatusServiceUnavailable, "")))
+	assert.False(t, IsServerError(wrap(http.StatusBadRequest, "")))
+	assert.True(t, HasErrorCode(wrap(http.StatusForbidden, "PermissionDenied"), "PermissionDenied"))
+	assert.False(t, HasErrorCode(wrap(http.StatusForbidden, "PermissionDenied"), "NotFound"))
+	assert.False(t, IsNotFound(fmt.Errorf("not an API error")))
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	ProjectEventSecretCreate       = "secret:create"
+	ProjectEventSecretUpdate       = "secret:update"
+	ProjectEventSecretDelete       = "secret:delete"
+	ProjectEventSecretImportChange = "secret:import-mutation"
+
+	maxServerSentEventSize = 1 << 20
+)
+
+var SecretProjectEvents = []string{ProjectEventSecretCreate, ProjectEventSecretUpdate, ProjectEventSecretDelete, ProjectEventSecretImportChange}
+
+type ProjectEventCondition struct {
+	EnvironmentSlug string `json:"environmentSlug,omitempty"`
+	SecretPath      string `json:"secretPath,omitempty"`
+}
+
+type ProjectEventRegistration struct {
+	Event      string                 `json:"event"`
+	Conditions *ProjectEventCondition `json:"conditions,omitempty"`
+}
+
+type SubscribeProjectEventsRequest struct {
+	ProjectID string                     `json:"projectId"`
+	Register  []ProjectEventRegistration `json:"register"`
+}
+
+// ServerSentEvent is a single event of a text/event-stream response
+type ServerSentEvent struct {
+	ID    string
+	Event string
+	Data  []byte
+}
+
+// CallSubscribeProjectEventsV1 opens a server-sent event stream of project changes. The caller owns
+// the returned body and must close it; cancelling ctx also ends the stream.
+func CallSubscribeProjectEventsV1(ctx context.Context, httpClient *resty.Client, request SubscribeProjectEventsRequest) (io.ReadCloser, error) {
+	response, err := httpClient.
+		R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		SetHeader("User-Agent", USER_AGENT).
+		SetHeader("Accept", "text/event-stream").
+		SetBody(request).
+		Post(fmt.Sprintf("%v/v1/events/subscribe/project-events", config.INFISICAL_URL))
+
+	if err != nil {
+		return nil, fmt.Errorf("CallSubscribeProjectEventsV1: Unable to complete api request [err=%w]", err)
+	}
+
+	if response.IsError() {
+		body, _ := io.ReadAll(io.LimitReader(response.RawBody(), maxServerSentEventSize))
+		response.RawBody().Close()
+		return nil, NewAPIError("CallSubscribeProjectEventsV1", response.SetBody(body))
+	}
+
+	return response.RawBody(), nil
+}
+
+// ReadServerSentEvents calls fn for every event in stream until fn returns an error. Comments, which
+// servers send as keep-alives, are skipped. Event streams are not expected to end, so a stream that
+// does returns io.ErrUnexpectedEOF.
+func ReadServerSentEvents(stream io.Reader, fn func(event ServerSentEvent) error) error {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxServerSentEventSize)
+
+	var event ServerSentEvent
+	var data bytes.Buffer
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if data.Len() > 0 || event.Event != "" {
+				event.Data = bytes.TrimSuffix(data.Bytes(), []byte("\n"))
+				if err := fn(event); err != nil {
+					return err
+				}
+			}
+			event = ServerSentEvent{}
+			data = bytes.Buffer{}
+			continue
+		}
+
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
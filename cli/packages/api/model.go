@@ -168,6 +168,14 @@ type Secret struct {
 }
 
 type Project struct {
+	ID             string               `json:"id"`
+	Name           string               `json:"name"`
+	Slug           string               `json:"slug"`
+	OrganizationId string               `json:"orgId"`
+	Environments   []ProjectEnvironment `json:"environments"`
+}
+
+type ProjectEnvironment struct {
 	ID   string `json:"id"`
 	Name string `json:"name"`
 	Slug string `json:"slug"`
@@ -270,6 +278,43 @@ type GetLoginTwoV2Response struct {
 	RefreshToken        string `json:"RefreshToken"`
 }
 
+type LdapLoginV1Request struct {
+	OrganizationSlug string `json:"organizationSlug"`
+	Username         string `json:"username"`
+	Password         string `json:"password"`
+}
+
+type SsoTokenExchangeV1Request struct {
+	ProviderAuthToken string `json:"providerAuthToken"`
+	Email             string `json:"email"`
+}
+
+type SsoTokenExchangeV1Response struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"-"`
+}
+
+type CreateCliDeviceCodeV1Response struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationUri         string `json:"verificationUri"`
+	VerificationUriComplete string `json:"verificationUriComplete"`
+	ExpiresIn               int    `json:"expiresIn"`
+	Interval                int    `json:"interval"`
+}
+
+type GetCliDeviceTokenV1Request struct {
+	DeviceCode string `json:"deviceCode"`
+}
+
+// GetCliDeviceTokenV1Response holds the same credentials the web app sends to the CLI after a browser login
+type GetCliDeviceTokenV1Response struct {
+	Email        string `json:"email"`
+	PrivateKey   string `json:"privateKey"`
+	JTWToken     string `json:"JTWToken"`
+	RefreshToken string `json:"RefreshToken"`
+}
+
 type VerifyMfaTokenRequest struct {
 	Email     string `json:"email"`
 	MFAToken  string `json:"mfaToken"`
@@ -406,6 +451,12 @@ type ImportedRawSecretV3 struct {
 		SecretKey     string `json:"secretKey"`
 		SecretValue   string `json:"secretValue"`
 		SecretComment string `json:"secretComment"`
+		Tags          []struct {
+			ID        string `json:"_id"`
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			Workspace string `json:"workspace"`
+		} `json:"tags"`
 	} `json:"secrets"`
 }
 
@@ -461,12 +512,13 @@ type UpdateSecretByNameV3Request struct {
 }
 
 type UpdateRawSecretByNameV3Request struct {
-	SecretName  string `json:"-"`
-	WorkspaceID string `json:"workspaceId"`
-	Environment string `json:"environment"`
-	SecretPath  string `json:"secretPath,omitempty"`
-	SecretValue string `json:"secretValue"`
-	Type        string `json:"type,omitempty"`
+	SecretName    string `json:"-"`
+	WorkspaceID   string `json:"workspaceId"`
+	Environment   string `json:"environment"`
+	SecretPath    string `json:"secretPath,omitempty"`
+	SecretValue   string `json:"secretValue"`
+	SecretComment string `json:"secretComment,omitempty"`
+	Type          string `json:"type,omitempty"`
 }
 
 type GetSingleSecretByNameV3Request struct {
@@ -520,15 +572,20 @@ type CreateServiceTokenRequest struct {
 }
 
 type ServiceTokenData struct {
-	ID          string        `json:"_id"`
-	Name        string        `json:"name"`
-	Workspace   string        `json:"workspace"`
-	Scopes      []interface{} `json:"scopes"`
-	User        string        `json:"user"`
-	LastUsed    time.Time     `json:"lastUsed"`
-	Permissions []string      `json:"permissions"`
-	CreatedAt   time.Time     `json:"createdAt"`
-	UpdatedAt   time.Time     `json:"updatedAt"`
+	ID          string            `json:"_id"`
+	Name        string            `json:"name"`
+	Workspace   string            `json:"workspace"`
+	Scopes      []ScopePermission `json:"scopes"`
+	User        string            `json:"user"`
+	LastUsed    time.Time         `json:"lastUsed"`
+	ExpiresAt   *time.Time        `json:"expiresAt"`
+	Permissions []string          `json:"permissions"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+}
+
+type GetServiceTokensResponse struct {
+	ServiceTokenData []ServiceTokenData `json:"serviceTokenData"`
 }
 
 type CreateServiceTokenResponse struct {
@@ -548,6 +605,19 @@ type UniversalAuthLoginResponse struct {
 	AccessTokenMaxTTL int    `json:"accessTokenMaxTTL"`
 }
 
+type TlsCertAuthLoginV1Request struct {
+	IdentityId string `json:"identityId"`
+}
+
+// AwsIamAuthLoginV1Request holds a signed STS GetCallerIdentity request, with the body and the JSON
+// encoded headers base64 encoded
+type AwsIamAuthLoginV1Request struct {
+	IdentityId        string `json:"identityId"`
+	HTTPRequestMethod string `json:"iamHttpRequestMethod"`
+	IamRequestBody    string `json:"iamRequestBody"`
+	IamRequestHeaders string `json:"iamRequestHeaders"`
+}
+
 type UniversalAuthRefreshRequest struct {
 	AccessToken string `json:"accessToken"`
 }
@@ -602,6 +672,12 @@ type GetRawSecretsV3Response struct {
 		SecretValue   string `json:"secretValue"`
 		SecretComment string `json:"secretComment"`
 		SecretPath    string `json:"secretPath"`
+		Tags          []struct {
+			ID        string `json:"_id"`
+			Name      string `json:"name"`
+			Slug      string `json:"slug"`
+			Workspace string `json:"workspace"`
+		} `json:"tags"`
 	} `json:"secrets"`
 	Imports []ImportedRawSecretV3 `json:"imports"`
 	ETag    string
@@ -648,3 +724,382 @@ type ExchangeRelayCertResponseV1 struct {
 	Certificate      string `json:"certificate"`
 	CertificateChain string `json:"certificateChain"`
 }
+
+type IssueCertificateV1Request struct {
+	CaID                  string   `json:"caId,omitempty"`
+	CertificateTemplateID string   `json:"certificateTemplateId,omitempty"`
+	PkiCollectionID       string   `json:"pkiCollectionId,omitempty"`
+	FriendlyName          string   `json:"friendlyName,omitempty"`
+	CommonName            string   `json:"commonName"`
+	AltNames              string   `json:"altNames,omitempty"`
+	TTL                   string   `json:"ttl"`
+	KeyUsages             []string `json:"keyUsages,omitempty"`
+	ExtendedKeyUsages     []string `json:"extendedKeyUsages,omitempty"`
+}
+
+type IssueCertificateV1Response struct {
+	Certificate          string `json:"certificate"`
+	IssuingCaCertificate string `json:"issuingCaCertificate"`
+	CertificateChain     string `json:"certificateChain"`
+	PrivateKey           string `json:"privateKey"`
+	SerialNumber         string `json:"serialNumber"`
+}
+
+type SignCertificateV1Request struct {
+	CaID                  string   `json:"caId,omitempty"`
+	CertificateTemplateID string   `json:"certificateTemplateId,omitempty"`
+	Csr                   string   `json:"csr"`
+	CommonName            string   `json:"commonName,omitempty"`
+	AltNames              string   `json:"altNames,omitempty"`
+	TTL                   string   `json:"ttl"`
+	KeyUsages             []string `json:"keyUsages,omitempty"`
+	ExtendedKeyUsages     []string `json:"extendedKeyUsages,omitempty"`
+}
+
+type SignCertificateV1Response struct {
+	Certificate          string `json:"certificate"`
+	IssuingCaCertificate string `json:"issuingCaCertificate"`
+	CertificateChain     string `json:"certificateChain"`
+	SerialNumber         string `json:"serialNumber"`
+}
+
+type GetSecretSnapshotsV1Request struct {
+	WorkspaceId string
+	Environment string
+	SecretPath  string
+	Offset      int
+	Limit       int
+}
+
+type SecretSnapshot struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type GetSecretSnapshotsV1Response struct {
+	SecretSnapshots []SecretSnapshot `json:"secretSnapshots"`
+}
+
+type GetSecretSnapshotV1Response struct {
+	SecretSnapshot struct {
+		ID             string    `json:"id"`
+		CreatedAt      time.Time `json:"createdAt"`
+		SecretVersions []struct {
+			Type        string `json:"type"`
+			SecretKey   string `json:"secretKey"`
+			SecretValue string `json:"secretValue"`
+		} `json:"secretVersions"`
+	} `json:"secretSnapshot"`
+}
+
+type SecretBatchRawV3Request struct {
+	WorkspaceId string                   `json:"workspaceId"`
+	Environment string                   `json:"environment"`
+	SecretPath  string                   `json:"secretPath"`
+	Secrets     []SecretBatchRawV3Secret `json:"secrets"`
+}
+
+// SecretBatchRawV3Secret is a secret of a batch, its value is sent even when empty
+type SecretBatchRawV3Secret struct {
+	SecretKey     string `json:"secretKey"`
+	SecretValue   string `json:"secretValue"`
+	SecretComment string `json:"secretComment,omitempty"`
+}
+
+type SecretBatchRawV3Response struct {
+	// Approval is set instead of the secrets being changed when the folder is protected by an approval policy
+	Approval *SecretApprovalRequest `json:"approval"`
+}
+
+type SecretApprovalRequest struct {
+	ID          string    `json:"id"`
+	Slug        string    `json:"slug"`
+	Status      string    `json:"status"`
+	HasMerged   bool      `json:"hasMerged"`
+	Environment string    `json:"environment"`
+	SecretPath  string    `json:"secretPath"`
+	CreatedAt   time.Time `json:"createdAt"`
+	Committer   struct {
+		Email string `json:"email"`
+	} `json:"committerUser"`
+	Policy struct {
+		Name      string `json:"name"`
+		Approvals int    `json:"approvals"`
+	} `json:"policy"`
+	Reviewers []struct {
+		UserID string `json:"userId"`
+		Status string `json:"status"`
+	} `json:"reviewers"`
+	Commits []struct {
+		Op        string `json:"op"`
+		SecretKey string `json:"secretKey"`
+	} `json:"commits"`
+}
+
+type GetSecretApprovalRequestsV1Request struct {
+	WorkspaceId string
+	Environment string
+	Status      string
+	Offset      int
+	Limit       int
+}
+
+type GetSecretApprovalRequestsV1Response struct {
+	Approvals []SecretApprovalRequest `json:"approvals"`
+}
+
+type ReviewSecretApprovalRequestV1Request struct {
+	ApprovalId string `json:"-"`
+	Status     string `json:"status"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+type SecretApprovalRequestV1Response struct {
+	Approval SecretApprovalRequest `json:"approval"`
+}
+
+type GetAuditLogsV1Request struct {
+	ProjectId   string
+	ActorType   string
+	Actor       string
+	EventType   string
+	Environment string
+	SecretPath  string
+	StartDate   *time.Time
+	EndDate     *time.Time
+	Offset      int
+	Limit       int
+}
+
+type AuditLog struct {
+	ID    string `json:"id"`
+	Actor struct {
+		Type     string                 `json:"type"`
+		Metadata map[string]interface{} `json:"metadata"`
+	} `json:"actor"`
+	Event struct {
+		Type     string                 `json:"type"`
+		Metadata map[string]interface{} `json:"metadata"`
+	} `json:"event"`
+	ProjectId     string    `json:"projectId,omitempty"`
+	IpAddress     string    `json:"ipAddress"`
+	UserAgent     string    `json:"userAgent"`
+	UserAgentType string    `json:"userAgentType"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type GetAuditLogsV1Response struct {
+	AuditLogs []AuditLog `json:"auditLogs"`
+}
+
+type UniversalAuthClientSecret struct {
+	ID                       string    `json:"id"`
+	Description              string    `json:"description"`
+	ClientSecretPrefix       string    `json:"clientSecretPrefix"`
+	ClientSecretNumUses      int       `json:"clientSecretNumUses"`
+	ClientSecretNumUsesLimit int       `json:"clientSecretNumUsesLimit"`
+	ClientSecretTTL          int       `json:"clientSecretTTL"`
+	IsClientSecretRevoked    bool      `json:"isClientSecretRevoked"`
+	CreatedAt                time.Time `json:"createdAt"`
+}
+
+type CreateUniversalAuthClientSecretRequest struct {
+	IdentityId   string `json:"-"`
+	Description  string `json:"description"`
+	NumUsesLimit int    `json:"numUsesLimit"`
+	// TTL is in seconds, 0 never expires
+	TTL int `json:"ttl"`
+}
+
+type CreateUniversalAuthClientSecretResponse struct {
+	ClientSecret     string                    `json:"clientSecret"`
+	ClientSecretData UniversalAuthClientSecret `json:"clientSecretData"`
+}
+
+type GetUniversalAuthClientSecretsResponse struct {
+	ClientSecretData []UniversalAuthClientSecret `json:"clientSecretData"`
+}
+
+type GetUniversalAuthResponse struct {
+	IdentityUniversalAuth struct {
+		ClientId string `json:"clientId"`
+	} `json:"identityUniversalAuth"`
+}
+
+type Identity struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	AuthMethods []string `json:"authMethods"`
+}
+
+type CreateIdentityV1Request struct {
+	Name           string `json:"name"`
+	OrganizationId string `json:"organizationId"`
+	Role           string `json:"role"`
+}
+
+type CreateIdentityV1Response struct {
+	Identity Identity `json:"identity"`
+}
+
+type IdentityOrganizationMembership struct {
+	Role     string   `json:"role"`
+	Identity Identity `json:"identity"`
+}
+
+type GetIdentitiesV1Response struct {
+	Identities []IdentityOrganizationMembership `json:"identities"`
+}
+
+type UpdateIdentityV1Request struct {
+	IdentityId string `json:"-"`
+	Role       string `json:"role"`
+}
+
+type IdentityTrustedIp struct {
+	IpAddress string `json:"ipAddress"`
+}
+
+// IdentityAuthTokenSettings are the access token settings every auth method of an identity has
+type IdentityAuthTokenSettings struct {
+	AccessTokenTTL        int                 `json:"accessTokenTTL,omitempty"`
+	AccessTokenMaxTTL     int                 `json:"accessTokenMaxTTL,omitempty"`
+	AccessTokenTrustedIps []IdentityTrustedIp `json:"accessTokenTrustedIps,omitempty"`
+}
+
+type AttachUniversalAuthRequest struct {
+	IdentityAuthTokenSettings
+	ClientSecretTrustedIps []IdentityTrustedIp `json:"clientSecretTrustedIps,omitempty"`
+}
+
+type AttachOidcAuthRequest struct {
+	IdentityAuthTokenSettings
+	OidcDiscoveryUrl string            `json:"oidcDiscoveryUrl"`
+	BoundIssuer      string            `json:"boundIssuer"`
+	BoundAudiences   string            `json:"boundAudiences,omitempty"`
+	BoundClaims      map[string]string `json:"boundClaims,omitempty"`
+	BoundSubject     string            `json:"boundSubject,omitempty"`
+	CaCert           string            `json:"caCert,omitempty"`
+}
+
+type AttachAwsAuthRequest struct {
+	IdentityAuthTokenSettings
+	StsEndpoint          string `json:"stsEndpoint,omitempty"`
+	AllowedPrincipalArns string `json:"allowedPrincipalArns,omitempty"`
+	AllowedAccountIds    string `json:"allowedAccountIds,omitempty"`
+}
+
+type AttachKubernetesAuthRequest struct {
+	IdentityAuthTokenSettings
+	KubernetesHost    string `json:"kubernetesHost"`
+	TokenReviewerJwt  string `json:"tokenReviewerJwt,omitempty"`
+	AllowedNamespaces string `json:"allowedNamespaces,omitempty"`
+	AllowedNames      string `json:"allowedNames,omitempty"`
+	AllowedAudience   string `json:"allowedAudience,omitempty"`
+	CaCert            string `json:"caCert,omitempty"`
+}
+
+type AttachTlsCertAuthRequest struct {
+	IdentityAuthTokenSettings
+	CaCertificate      string `json:"caCertificate"`
+	AllowedCommonNames string `json:"allowedCommonNames,omitempty"`
+}
+
+type ProjectIdentityRole struct {
+	Role string `json:"role"`
+}
+
+type UpdateProjectIdentityMembershipRequest struct {
+	ProjectId  string                `json:"-"`
+	IdentityId string                `json:"-"`
+	Roles      []ProjectIdentityRole `json:"roles"`
+}
+
+type KmsKey struct {
+	Id                  string `json:"id"`
+	Name                string `json:"name"`
+	ProjectId           string `json:"projectId"`
+	KeyUsage            string `json:"keyUsage"`
+	EncryptionAlgorithm string `json:"encryptionAlgorithm"`
+}
+
+type GetKmsKeyResponse struct {
+	Key KmsKey `json:"key"`
+}
+
+type KmsEncryptRequest struct {
+	Plaintext string `json:"plaintext"`
+}
+
+type KmsEncryptResponse struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type KmsDecryptRequest struct {
+	Ciphertext string `json:"ciphertext"`
+}
+
+type KmsDecryptResponse struct {
+	Plaintext string `json:"plaintext"`
+}
+
+type KmsSignRequest struct {
+	Data             string `json:"data"`
+	SigningAlgorithm string `json:"signingAlgorithm"`
+	IsDigest         bool   `json:"isDigest"`
+}
+
+type KmsSignResponse struct {
+	Signature        string `json:"signature"`
+	KeyId            string `json:"keyId"`
+	SigningAlgorithm string `json:"signingAlgorithm"`
+}
+
+type KmsVerifyRequest struct {
+	Data             string `json:"data"`
+	Signature        string `json:"signature"`
+	SigningAlgorithm string `json:"signingAlgorithm"`
+	IsDigest         bool   `json:"isDigest"`
+}
+
+type KmsVerifyResponse struct {
+	SignatureValid   bool   `json:"signatureValid"`
+	KeyId            string `json:"keyId"`
+	SigningAlgorithm string `json:"signingAlgorithm"`
+}
+
+type GetKmsSigningAlgorithmsResponse struct {
+	SigningAlgorithms []string `json:"signingAlgorithms"`
+}
+
+type SecretRotation struct {
+	ID            string     `json:"id"`
+	Provider      string     `json:"provider"`
+	Interval      int        `json:"interval"` // days
+	SecretPath    string     `json:"secretPath"`
+	Status        string     `json:"status"`
+	StatusMessage string     `json:"statusMessage"`
+	LastRotatedAt *time.Time `json:"lastRotatedAt"`
+	Environment   struct {
+		Name string `json:"name"`
+		Slug string `json:"slug"`
+	} `json:"environment"`
+	Outputs []struct {
+		Key    string `json:"key"`
+		Secret struct {
+			SecretKey string `json:"secretKey"`
+		} `json:"secret"`
+	} `json:"outputs"`
+}
+
+type GetSecretRotationsV1Response struct {
+	SecretRotations []SecretRotation `json:"secretRotations"`
+}
+
+type RestartSecretRotationV1Request struct {
+	ID string `json:"id"`
+}
+
+type RestartSecretRotationV1Response struct {
+	SecretRotation SecretRotation `json:"secretRotation"`
+}
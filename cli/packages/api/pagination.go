@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+)
+
+const DefaultPageSize = 100
+
+// ErrResultsTruncated is returned by CollectAll alongside the items it gathered when MaxItems was
+// reached before the last page.
+var ErrResultsTruncated = errors.New("results truncated")
+
+// PageRequest describes the page a PageFetcher should return. Offset based endpoints use Offset and
+// Limit, cursor based endpoints use Cursor and Limit.
+type PageRequest struct {
+	Offset int
+	Limit  int
+	Cursor string
+}
+
+// Page is a single page of results. Set NextCursor for cursor based endpoints and Total for offset
+// based endpoints that report it; when neither is set, an empty page marks the end of the list, since
+// endpoints may return fewer items than the limit before the last page.
+type Page[T any] struct {
+	Items      []T
+	NextCursor string
+	Total      int
+}
+
+type PageFetcher[T any] func(request PageRequest) (Page[T], error)
+
+type CollectOptions struct {
+	PageSize int
+	// MaxItems stops collecting after this many items. Zero collects everything.
+	MaxItems int
+}
+
+// Iterate calls fn for every item of a paginated list, fetching pages of pageSize as needed.
+// Returning false from fn stops the iteration early.
+func Iterate[T any](fetch PageFetcher[T], pageSize int, fn func(item T) (bool, error)) error {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+
+	request := PageRequest{Limit: pageSize}
+	for {
+		page, err := fetch(request)
+		if err != nil {
+			return fmt.Errorf("unable to fetch page at offset %d: %w", request.Offset, err)
+		}
+
+		for _, item := range page.Items {
+			keepGoing, err := fn(item)
+			if err != nil {
+				return err
+			}
+			if !keepGoing {
+				return nil
+			}
+		}
+
+		if !hasNextPage(request, page) {
+			return nil
+		}
+
+		request.Offset += len(page.Items)
+		request.Cursor = page.NextCursor
+	}
+}
+
+// CollectAll gathers every item of a paginated list. When options.MaxItems cuts the list short the
+// items collected so far are returned together with ErrResultsTruncated.
+func CollectAll[T any](fetch PageFetcher[T], options CollectOptions) ([]T, error) {
+	var items []T
+	truncated := false
+
+	err := Iterate(fetch, options.PageSize, func(item T) (bool, error) {
+		if options.MaxItems > 0 && len(items) >= options.MaxItems {
+			truncated = true
+			return false, nil
+		}
+		items = append(items, item)
+		return true, nil
+	})
+	if err != nil {
+		return items, err
+	}
+
+	if truncated {
+		return items, fmt.Errorf("%w: stopped after %d items", ErrResultsTruncated, options.MaxItems)
+	}
+
+	return items, nil
+}
+
+func hasNextPage[T any](request PageRequest, page Page[T]) bool {
+	if len(page.Items) == 0 {
+		return false
+	}
+
+	if page.NextCursor != "" {
+		return page.NextCursor != request.Cursor
+	}
+
+	// A cursor based endpoint signals its last page with an empty cursor
+	if request.Cursor != "" {
+		return false
+	}
+
+	if page.Total > 0 {
+		return request.Offset+len(page.Items) < page.Total
+	}
+
+	return true
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// offsetFetcher serves items from pages of the given sizes, ignoring the limit asked for, as endpoints
+// that cap their page size do
+func offsetFetcher(pageSizes []int, withTotal bool, requests *[]PageRequest) PageFetcher[int] {
+	total := 0
+	for _, size := range pageSizes {
+		total += size
+	}
+
+	return func(request PageRequest) (Page[int], error) {
+		*requests = append(*requests, request)
+
+		page := Page[int]{}
+		if withTotal {
+			page.Total = total
+		}
+
+		served := 0
+		for _, size := range pageSizes {
+			if served == request.Offset {
+				for i := 0; i < size; i++ {
+					page.Items = append(page.Items, served+i)
+				}
+				return page, nil
+			}
+			served += size
+		}
+		return page, nil
+	}
+}
+
+func TestCollectAll(t *testing.T) {
+	tests := []struct {
+		name         string
+		pageSizes    []int
+		withTotal    bool
+		wantItems    int
+		wantRequests int
+	}{
+		{"stops on an empty page", []int{10, 10, 3}, false, 23, 4},
+		{"keeps going after a short page", []int{10, 4, 10, 2}, false, 26, 5},
+		{"stops at the total", []int{10, 4, 10, 2}, true, 26, 4},
+		{"empty list", nil, false, 0, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests []PageRequest
+			items, err := CollectAll(offsetFetcher(test.pageSizes, test.withTotal, &requests), CollectOptions{PageSize: 10})
+			assert.NoError(t, err)
+			assert.Len(t, items, test.wantItems)
+			assert.Len(t, requests, test.wantRequests)
+
+			for i, item := range items {
+				assert.Equal(t, i, item)
+			}
+		})
+	}
+}
+
+func TestCollectAllCursor(t *testing.T) {
+	pages := map[string]Page[string]{
+		"":   {Items: []string{"a", "b"}, NextCursor: "c1"},
+		"c1": {Items: []string{"c"}, NextCursor: "c2"},
+		"c2": {Items: []string{"d", "e"}},
+	}
+
+	var cursors []string
+	items, err := CollectAll(func(request PageRequest) (Page[string], error) {
+		cursors = append(cursors, request.Cursor)
+		return pages[request.Cursor], nil
+	}, CollectOptions{PageSize: 2})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, items)
+	assert.Equal(t, []string{"", "c1", "c2"}, cursors)
+}
+
+func TestCollectAllRepeatedCursor(t *testing.T) {
+	requests := 0
+	items, err := CollectAll(func(request PageRequest) (Page[int], error) {
+		requests++
+		return Page[int]{Items: []int{requests}, NextCursor: "same"}, nil
+	}, CollectOptions{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 2}, items)
+}
+
+func TestCollectAllMaxItems(t *testing.T) {
+	var requests []PageRequest
+	items, err := CollectAll(offsetFetcher([]int{10, 10, 10}, false, &requests), CollectOptions{PageSize: 10, MaxItems: 15})
+
+	assert.ErrorIs(t, err, ErrResultsTruncated)
+	assert.Len(t, items, 15)
+	assert.Len(t, requests, 2)
+}
+
+func TestIterateError(t *testing.T) {
+	fetchErr := errors.New("unavailable")
+	err := Iterate(func(request PageRequest) (Page[string], error) {
+		if request.Offset > 0 {
+			return Page[string]{}, fetchErr
+		}
+		return Page[string]{Items: []string{strconv.Itoa(request.Offset)}}, nil
+	}, 1, func(item string) (bool, error) { return true, nil })
+
+	assert.ErrorIs(t, err, fetchErr)
+	assert.Contains(t, err.Error(), "offset 1")
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"path"
 	"runtime"
 	"slices"
+	"strings"
 	"sync"
 	"syscall"
 	"text/template"
@@ -29,7 +31,6 @@ import (
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
-	"github.com/go-resty/resty/v2"
 	"github.com/spf13/cobra"
 )
 
@@ -43,11 +44,19 @@ type Config struct {
 	Auth      AuthConfig      `yaml:"auth"`
 	Sinks     []Sink          `yaml:"sinks"`
 	Templates []Template      `yaml:"templates"`
+	Exec      *ExecConfig     `yaml:"exec"`
+	Cache     CacheConfig     `yaml:"cache"`
+	Health    *HealthConfig   `yaml:"health"`
+	Metrics   *MetricsConfig  `yaml:"metrics"`
+
+	Certificates []CertificateConfig `yaml:"certificates"`
 }
 
 type InfisicalConfig struct {
 	Address       string `yaml:"address"`
 	ExitAfterAuth bool   `yaml:"exit-after-auth"`
+	// Templates re-render as soon as Infisical reports a change unless this is set, then they only poll
+	DisableSecretEvents bool `yaml:"disable-secret-events"`
 }
 
 type AuthConfig struct {
@@ -68,6 +77,9 @@ type KubernetesAuth struct {
 
 type AzureAuth struct {
 	IdentityID string `yaml:"identity-id"`
+	// Resource the managed identity token is requested for, which must match the identity's allowed
+	// audience. Defaults to https://management.azure.com/
+	Resource string `yaml:"resource"`
 }
 
 type GcpIdTokenAuth struct {
@@ -81,6 +93,9 @@ type GcpIamAuth struct {
 
 type AwsIamAuth struct {
 	IdentityID string `yaml:"identity-id"`
+	// Region of the STS endpoint the GetCallerIdentity request is signed for, instead of the one found
+	// in AWS_REGION or the instance metadata. Needed when neither has it, e.g. on ECS with IMDS disabled.
+	Region string `yaml:"region"`
 }
 
 type Sink struct {
@@ -89,7 +104,8 @@ type Sink struct {
 }
 
 type SinkDetails struct {
-	Path string `yaml:"path"`
+	Path          string `yaml:"path"`
+	FileOwnership `yaml:",inline"`
 }
 
 type Template struct {
@@ -98,12 +114,20 @@ type Template struct {
 	DestinationPath       string `yaml:"destination-path"`
 	TemplateContent       string `yaml:"template-content"`
 
+	DestinationKubernetes *KubernetesDestination `yaml:"destination-kubernetes"` // Apply the rendered template to a Kubernetes Secret or ConfigMap
+	DestinationAWS        *AWSDestination        `yaml:"destination-aws"`        // Push the rendered template to an AWS SSM parameter or Secrets Manager secret
+
 	Config struct { // Configurations for the template
 		PollingInterval string `yaml:"polling-interval"` // How often to poll for changes in the secret
 		Execute         struct {
 			Command string `yaml:"command"` // Command to execute once the template has been rendered
 			Timeout int64  `yaml:"timeout"` // Timeout for the command
 		} `yaml:"execute"` // Command to execute once the template has been rendered
+		ExecEnv       bool             `yaml:"exec-env"` // Pass the rendered KEY=VALUE lines to the exec process as environment variables
+		FileOwnership `yaml:",inline"` // Mode, owner and group of the rendered file
+
+		Syntax         string                `yaml:"syntax"`          // Set to consul-template to render templates written for consul-template or Vault Agent
+		ConsulTemplate *ConsulTemplateConfig `yaml:"consul-template"` // Maps the Vault paths of a consul-template syntax template onto Infisical
 	} `yaml:"config"`
 }
 
@@ -231,6 +255,7 @@ func ExecuteCommandWithTimeout(command string, timeout int64) error {
 	}
 
 	cmd := exec.CommandContext(ctx, shell[0], shell[1], command)
+	cmd.Env = util.ChildProcessEnvironment(os.Environ())
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -282,8 +307,9 @@ func ParseAgentConfig(configFile []byte) (*Config, error) {
 			Type   string                 `yaml:"type"`
 			Config map[string]interface{} `yaml:"config"`
 		} `yaml:"auth"`
-		Sinks     []Sink     `yaml:"sinks"`
-		Templates []Template `yaml:"templates"`
+		Sinks     []Sink      `yaml:"sinks"`
+		Templates []Template  `yaml:"templates"`
+		Exec      *ExecConfig `yaml:"exec"`
 	}
 
 	if err := yaml.Unmarshal(configFile, &rawConfig); err != nil {
@@ -292,7 +318,11 @@ func ParseAgentConfig(configFile []byte) (*Config, error) {
 
 	// Set defaults
 	if rawConfig.Infisical.Address == "" {
-		rawConfig.Infisical.Address = DEFAULT_INFISICAL_CLOUD_URL
+		if config.INFISICAL_PROFILE != "" {
+			rawConfig.Infisical.Address = strings.TrimSuffix(config.INFISICAL_URL, "/api")
+		} else {
+			rawConfig.Infisical.Address = DEFAULT_INFISICAL_CLOUD_URL
+		}
 	}
 
 	config.INFISICAL_URL = util.AppendAPIEndpoint(rawConfig.Infisical.Address)
@@ -307,11 +337,94 @@ func ParseAgentConfig(configFile []byte) (*Config, error) {
 		},
 		Sinks:     rawConfig.Sinks,
 		Templates: rawConfig.Templates,
+		Exec:      rawConfig.Exec,
+	}
+
+	if err := validateAgentOutputs(config); err != nil {
+		return nil, err
 	}
 
 	return config, nil
 }
 
+// validateAgentOutputs catches mistakes in the sinks and templates at startup instead of on their first write
+func validateAgentOutputs(agentConfig *Config) error {
+	destinations := map[string]int{}
+
+	for i, template := range agentConfig.Templates {
+		if template.Config.PollingInterval != "" {
+			if _, err := util.ConvertPollingIntervalToTime(template.Config.PollingInterval); err != nil {
+				return fmt.Errorf("template %d: invalid polling-interval: %v", i+1, err)
+			}
+		}
+
+		if _, err := template.Config.FileOwnership.resolve(); err != nil {
+			return fmt.Errorf("template %d: %v", i+1, err)
+		}
+
+		switch template.Config.Syntax {
+		case "":
+		case TEMPLATE_SYNTAX_CONSUL_TEMPLATE:
+			if err := template.Config.ConsulTemplate.validate(); err != nil {
+				return fmt.Errorf("template %d: %v", i+1, err)
+			}
+		default:
+			return fmt.Errorf("template %d: unsupported syntax %s, expected %s", i+1, template.Config.Syntax, TEMPLATE_SYNTAX_CONSUL_TEMPLATE)
+		}
+
+		if template.DestinationKubernetes != nil {
+			if err := template.DestinationKubernetes.validate(); err != nil {
+				return fmt.Errorf("template %d: %v", i+1, err)
+			}
+		}
+
+		if template.DestinationAWS != nil {
+			if err := template.DestinationAWS.validate(); err != nil {
+				return fmt.Errorf("template %d: %v", i+1, err)
+			}
+		}
+
+		if template.DestinationPath == "" {
+			if !template.Config.ExecEnv && template.DestinationKubernetes == nil && template.DestinationAWS == nil {
+				return fmt.Errorf("template %d: destination-path, destination-kubernetes or destination-aws is required", i+1)
+			}
+			continue
+		}
+
+		destination := path.Clean(template.DestinationPath)
+		if other, ok := destinations[destination]; ok {
+			return fmt.Errorf("templates %d and %d both render to %s", other, i+1, template.DestinationPath)
+		}
+		destinations[destination] = i + 1
+	}
+
+	for i, certificate := range agentConfig.Certificates {
+		if err := certificate.validate(); err != nil {
+			return fmt.Errorf("certificate %d: %v", i+1, err)
+		}
+
+		for _, destinationPath := range []string{certificate.Destination.Certificate, certificate.Destination.PrivateKey, certificate.Destination.Chain} {
+			if destinationPath == "" {
+				continue
+			}
+
+			destination := path.Clean(destinationPath)
+			if _, ok := destinations[destination]; ok {
+				return fmt.Errorf("certificate %d: %s is already rendered to by another template or certificate", i+1, destinationPath)
+			}
+			destinations[destination] = -1
+		}
+	}
+
+	for i, sink := range agentConfig.Sinks {
+		if _, err := sink.Config.FileOwnership.resolve(); err != nil {
+			return fmt.Errorf("sink %d: %v", i+1, err)
+		}
+	}
+
+	return nil
+}
+
 type secretArguments struct {
 	IsRecursive                  bool  `json:"recursive"`
 	ShouldExpandSecretReferences *bool `json:"expandSecretReferences,omitempty"`
@@ -324,7 +437,7 @@ func (s *secretArguments) SetDefaults() {
 	}
 }
 
-func secretTemplateFunction(accessToken string, existingEtag string, currentEtag *string) func(string, string, string, ...string) ([]models.SingleEnvironmentVariable, error) {
+func secretTemplateFunction(accessToken string, existingEtag string, currentEtag *string, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics, templateId int) func(string, string, string, ...string) ([]models.SingleEnvironmentVariable, error) {
 	// ...string is because golang doesn't have optional arguments.
 	// thus we make it slice and pick it only first element
 	return func(projectID, envSlug, secretPath string, args ...string) ([]models.SingleEnvironmentVariable, error) {
@@ -339,7 +452,9 @@ func secretTemplateFunction(accessToken string, existingEtag string, currentEtag
 
 		parsedArguments.SetDefaults()
 
+		fetchStartedAt := time.Now()
 		res, err := util.GetPlainTextSecretsV3(accessToken, projectID, envSlug, secretPath, false, parsedArguments.IsRecursive, "", *parsedArguments.ShouldExpandSecretReferences)
+		metrics.ObserveFetch(templateId, time.Since(fetchStartedAt), err)
 		if err != nil {
 			return nil, err
 		}
@@ -348,13 +463,17 @@ func secretTemplateFunction(accessToken string, existingEtag string, currentEtag
 			*currentEtag = res.Etag
 		}
 
+		secretChangeWatcher.Watch(templateId, projectID, envSlug, secretPath)
+
 		return res.Secrets, nil
 	}
 }
 
-func getSingleSecretTemplateFunction(accessToken string, existingEtag string, currentEtag *string) func(string, string, string, string) (models.SingleEnvironmentVariable, error) {
+func getSingleSecretTemplateFunction(accessToken string, existingEtag string, currentEtag *string, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics, templateId int) func(string, string, string, string) (models.SingleEnvironmentVariable, error) {
 	return func(projectID, envSlug, secretPath, secretName string) (models.SingleEnvironmentVariable, error) {
+		fetchStartedAt := time.Now()
 		secret, requestEtag, err := util.GetSinglePlainTextSecretByNameV3(accessToken, projectID, envSlug, secretPath, secretName)
+		metrics.ObserveFetch(templateId, time.Since(fetchStartedAt), err)
 		if err != nil {
 			return models.SingleEnvironmentVariable{}, err
 		}
@@ -363,6 +482,8 @@ func getSingleSecretTemplateFunction(accessToken string, existingEtag string, cu
 			*currentEtag = requestEtag
 		}
 
+		secretChangeWatcher.Watch(templateId, projectID, envSlug, secretPath)
+
 		return secret, nil
 	}
 }
@@ -394,11 +515,11 @@ func dynamicSecretTemplateFunction(accessToken string, dynamicSecretManager *Dyn
 	}
 }
 
-func ProcessTemplate(templateId int, templatePath string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretManager *DynamicSecretLeaseManager) (*bytes.Buffer, error) {
+func ProcessTemplate(templateId int, templatePath string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretManager *DynamicSecretLeaseManager, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics) (*bytes.Buffer, error) {
 	// custom template function to fetch secrets from Infisical
-	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag)
+	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag, secretChangeWatcher, metrics, templateId)
 	dynamicSecretFunction := dynamicSecretTemplateFunction(accessToken, dynamicSecretManager, templateId)
-	getSingleSecretFunction := getSingleSecretTemplateFunction(accessToken, existingEtag, currentEtag)
+	getSingleSecretFunction := getSingleSecretTemplateFunction(accessToken, existingEtag, currentEtag, secretChangeWatcher, metrics, templateId)
 	funcs := template.FuncMap{
 		"secret":          secretFunction, // depreciated
 		"listSecrets":     secretFunction,
@@ -413,7 +534,7 @@ func ProcessTemplate(templateId int, templatePath string, data interface{}, acce
 	}
 
 	templateName := path.Base(templatePath)
-	tmpl, err := template.New(templateName).Funcs(funcs).ParseFiles(templatePath)
+	tmpl, err := template.New(templateName).Funcs(withAgentTemplateHelpers(funcs)).ParseFiles(templatePath)
 	if err != nil {
 		return nil, err
 	}
@@ -426,7 +547,7 @@ func ProcessTemplate(templateId int, templatePath string, data interface{}, acce
 	return &buf, nil
 }
 
-func ProcessBase64Template(templateId int, encodedTemplate string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretLeaser *DynamicSecretLeaseManager) (*bytes.Buffer, error) {
+func ProcessBase64Template(templateId int, encodedTemplate string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretLeaser *DynamicSecretLeaseManager, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics) (*bytes.Buffer, error) {
 	// custom template function to fetch secrets from Infisical
 	decoded, err := base64.StdEncoding.DecodeString(encodedTemplate)
 	if err != nil {
@@ -435,7 +556,7 @@ func ProcessBase64Template(templateId int, encodedTemplate string, data interfac
 
 	templateString := string(decoded)
 
-	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag) // TODO: Fix this
+	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag, secretChangeWatcher, metrics, templateId) // TODO: Fix this
 	dynamicSecretFunction := dynamicSecretTemplateFunction(accessToken, dynamicSecretLeaser, templateId)
 	funcs := template.FuncMap{
 		"secret":         secretFunction,
@@ -444,7 +565,7 @@ func ProcessBase64Template(templateId int, encodedTemplate string, data interfac
 
 	templateName := "base64Template"
 
-	tmpl, err := template.New(templateName).Funcs(funcs).Parse(templateString)
+	tmpl, err := template.New(templateName).Funcs(withAgentTemplateHelpers(funcs)).Parse(templateString)
 	if err != nil {
 		return nil, err
 	}
@@ -457,8 +578,8 @@ func ProcessBase64Template(templateId int, encodedTemplate string, data interfac
 	return &buf, nil
 }
 
-func ProcessLiteralTemplate(templateId int, templateString string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretLeaser *DynamicSecretLeaseManager) (*bytes.Buffer, error) {
-	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag) // TODO: Fix this
+func ProcessLiteralTemplate(templateId int, templateString string, data interface{}, accessToken string, existingEtag string, currentEtag *string, dynamicSecretLeaser *DynamicSecretLeaseManager, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics) (*bytes.Buffer, error) {
+	secretFunction := secretTemplateFunction(accessToken, existingEtag, currentEtag, secretChangeWatcher, metrics, templateId) // TODO: Fix this
 	dynamicSecretFunction := dynamicSecretTemplateFunction(accessToken, dynamicSecretLeaser, templateId)
 	funcs := template.FuncMap{
 		"secret":         secretFunction,
@@ -467,7 +588,7 @@ func ProcessLiteralTemplate(templateId int, templateString string, data interfac
 
 	templateName := "literalTemplate"
 
-	tmpl, err := template.New(templateName).Funcs(funcs).Parse(templateString)
+	tmpl, err := template.New(templateName).Funcs(withAgentTemplateHelpers(funcs)).Parse(templateString)
 	if err != nil {
 		return nil, err
 	}
@@ -490,6 +611,12 @@ type AgentManager struct {
 	filePaths                []Sink // Store file paths if needed
 	templates                []Template
 	dynamicSecretLeases      *DynamicSecretLeaseManager
+	secretChangeWatcher      *SecretChangeWatcher
+	execSupervisor           *execSupervisor
+	persistentCache          *persistentCache
+	status                   *agentStatus
+	metrics                  *agentMetrics
+	forceRenderChans         []chan struct{}
 
 	authConfigBytes []byte
 	authStrategy    util.AuthStrategyType
@@ -515,10 +642,17 @@ type NewAgentMangerOptions struct {
 
 func NewAgentManager(options NewAgentMangerOptions) *AgentManager {
 
+	forceRenderChans := make([]chan struct{}, len(options.Templates))
+	for i := range forceRenderChans {
+		forceRenderChans[i] = make(chan struct{}, 1)
+	}
+
 	return &AgentManager{
 		filePaths: options.FileDeposits,
 		templates: options.Templates,
 
+		forceRenderChans: forceRenderChans,
+
 		authConfigBytes: options.AuthConfigBytes,
 		authStrategy:    options.AuthStrategy,
 
@@ -534,6 +668,18 @@ func NewAgentManager(options NewAgentMangerOptions) *AgentManager {
 
 }
 
+// ForceRender makes every template fetch its secrets and render right away instead of waiting for
+// the next polling interval
+func (tm *AgentManager) ForceRender() {
+	for _, forceRenderChan := range tm.forceRenderChans {
+		select {
+		case forceRenderChan <- struct{}{}:
+		default:
+			// a render is already pending
+		}
+	}
+}
+
 func (tm *AgentManager) SetToken(token string, accessTokenTTL time.Duration, accessTokenMaxTTL time.Duration) {
 	tm.mutex.Lock()
 	defer tm.mutex.Unlock()
@@ -601,6 +747,16 @@ func (tm *AgentManager) FetchKubernetesAuthAccessToken() (credential infisicalSd
 		}
 	}
 
+	// the sdk reads the token from this path on every login, which picks up the tokens the kubelet
+	// rotates. It's checked here first to fail with a clearer error than the sdk's.
+	serviceAccountToken, err := os.ReadFile(serviceAccountTokenPath)
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to read service account token at %s, make sure the token is mounted into the pod: %v", serviceAccountTokenPath, err)
+	}
+	if len(strings.TrimSpace(string(serviceAccountToken))) == 0 {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("service account token at %s is empty", serviceAccountTokenPath)
+	}
+
 	return tm.infisicalClient.Auth().KubernetesAuthLogin(identityId, serviceAccountTokenPath)
 
 }
@@ -617,7 +773,7 @@ func (tm *AgentManager) FetchAzureAuthAccessToken() (credential infisicalSdk.Mac
 		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to get identity id: %v", err)
 	}
 
-	return tm.infisicalClient.Auth().AzureAuthLogin(identityId, "")
+	return tm.infisicalClient.Auth().AzureAuthLogin(identityId, azureAuthConfig.Resource)
 
 }
 
@@ -675,7 +831,17 @@ func (tm *AgentManager) FetchAwsIamAuthAccessToken() (credential infisicalSdk.Ma
 		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to get identity id: %v", err)
 	}
 
-	return tm.infisicalClient.Auth().AwsIamAuthLogin(identityId)
+	// the sdk always signs for the region of the environment, so a pinned region is signed for here
+	if awsIamAuthConfig.Region != "" {
+		credential, err = awsIamAuthLogin(identityId, awsIamAuthConfig.Region)
+	} else {
+		credential, err = tm.infisicalClient.Auth().AwsIamAuthLogin(identityId)
+	}
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to authenticate with the AWS role of this instance or task, make sure a role is attached and the identity allows it: %v", err)
+	}
+
+	return credential, nil
 
 }
 
@@ -714,10 +880,27 @@ func (tm *AgentManager) FetchNewAccessToken() error {
 	return nil
 }
 
+// canReauthenticate reports whether the auth method can fetch fresh platform credentials at any
+// time, such as an ID token from the metadata server or a managed identity token from IMDS. Those
+// agents log in again when a refresh fails instead of retrying it.
+func (tm *AgentManager) canReauthenticate() bool {
+	switch tm.authStrategy {
+	case util.AuthStrategy.GCP_ID_TOKEN_AUTH, util.AuthStrategy.GCP_IAM_AUTH, util.AuthStrategy.AZURE_AUTH, util.AuthStrategy.KUBERNETES_AUTH:
+		return true
+	default:
+		return false
+	}
+}
+
 // Refreshes the existing access token
 func (tm *AgentManager) RefreshAccessToken() error {
-	httpClient := resty.New()
-	httpClient.SetRetryCount(10000).
+	retryCount := 10000
+	if tm.canReauthenticate() {
+		retryCount = 3
+	}
+
+	httpClient := api.NewHTTPClient()
+	httpClient.SetRetryCount(retryCount).
 		SetRetryMaxWaitTime(20 * time.Second).
 		SetRetryWaitTime(5 * time.Second)
 
@@ -750,9 +933,12 @@ func (tm *AgentManager) ManageTokenLifecycle() {
 		if tm.accessTokenFetchedTime.IsZero() && tm.accessTokenRefreshedTime.IsZero() {
 			// case: init login to get access token
 			log.Info().Msg("attempting to authenticate...")
+			renewalStartedAt := time.Now()
 			err := tm.FetchNewAccessToken()
+			tm.metrics.TokenRenewed("login", time.Since(renewalStartedAt), err)
 			if err != nil {
 				log.Error().Msgf("unable to authenticate because %v. Will retry in 30 seconds", err)
+				tm.status.AuthFailed(err)
 
 				// wait a bit before trying again
 				time.Sleep((30 * time.Second))
@@ -761,9 +947,12 @@ func (tm *AgentManager) ManageTokenLifecycle() {
 		} else if time.Now().After(accessTokenMaxTTLExpiresInTime) {
 			// case: token has reached max ttl and we should re-authenticate entirely (cannot refresh)
 			log.Info().Msgf("token has reached max ttl, attempting to re authenticate...")
+			renewalStartedAt := time.Now()
 			err := tm.FetchNewAccessToken()
+			tm.metrics.TokenRenewed("login", time.Since(renewalStartedAt), err)
 			if err != nil {
 				log.Error().Msgf("unable to authenticate because %v. Will retry in 30 seconds", err)
+				tm.status.AuthFailed(err)
 
 				// wait a bit before trying again
 				time.Sleep((30 * time.Second))
@@ -772,9 +961,18 @@ func (tm *AgentManager) ManageTokenLifecycle() {
 		} else {
 			// case: token ttl has expired, but the token is still within max ttl, so we can refresh
 			log.Info().Msgf("attempting to refresh existing token...")
+			renewalStartedAt := time.Now()
 			err := tm.RefreshAccessToken()
+			tm.metrics.TokenRenewed("refresh", time.Since(renewalStartedAt), err)
+			if err != nil && tm.canReauthenticate() {
+				log.Warn().Msgf("unable to refresh token because %v, attempting to re authenticate...", err)
+				renewalStartedAt = time.Now()
+				err = tm.FetchNewAccessToken()
+				tm.metrics.TokenRenewed("login", time.Since(renewalStartedAt), err)
+			}
 			if err != nil {
 				log.Error().Msgf("unable to refresh token because %v. Will retry in 30 seconds", err)
+				tm.status.AuthFailed(err)
 
 				// wait a bit before trying again
 				time.Sleep((30 * time.Second))
@@ -782,6 +980,8 @@ func (tm *AgentManager) ManageTokenLifecycle() {
 			}
 		}
 
+		tm.status.AuthSucceeded()
+
 		if tm.exitAfterAuth {
 			time.Sleep(25 * time.Second)
 			os.Exit(0)
@@ -810,9 +1010,10 @@ func (tm *AgentManager) WriteTokenToFiles() {
 	token := tm.GetToken()
 	for _, sinkFile := range tm.filePaths {
 		if sinkFile.Type == "file" {
-			err := ioutil.WriteFile(sinkFile.Config.Path, []byte(token), 0644)
+			err := writeFileAtomically(sinkFile.Config.Path, []byte(token), sinkFile.Config.FileOwnership)
 			if err != nil {
 				log.Error().Msgf("unable to write file sink to path '%s' because %v", sinkFile.Config.Path, err)
+				continue
 			}
 
 			log.Info().Msgf("new access token saved to file at path '%s'", sinkFile.Config.Path)
@@ -824,7 +1025,30 @@ func (tm *AgentManager) WriteTokenToFiles() {
 }
 
 func (tm *AgentManager) WriteTemplateToFile(bytes *bytes.Buffer, template *Template) {
-	if err := WriteBytesToFile(bytes, template.DestinationPath); err != nil {
+	if template.DestinationKubernetes != nil {
+		object, err := applyTemplateToKubernetes(bytes.Bytes(), template.DestinationKubernetes)
+		if err != nil {
+			log.Error().Msgf("template engine: unable to apply secrets to Kubernetes because %s. Will try again on next cycle", err)
+		} else {
+			log.Info().Msgf("template engine: secret template at path %s has been rendered and applied to %s", template.SourcePath, object)
+		}
+	}
+
+	if template.DestinationAWS != nil {
+		destination, err := applyTemplateToAWS(bytes.Bytes(), template.DestinationAWS)
+		if err != nil {
+			log.Error().Msgf("template engine: unable to push secrets to AWS because %s. Will try again on next cycle", err)
+		} else {
+			log.Info().Msgf("template engine: secret template at path %s has been rendered and pushed to %s", template.SourcePath, destination)
+		}
+	}
+
+	if template.DestinationPath == "" {
+		// only used as the environment of the exec process or applied to Kubernetes or AWS
+		return
+	}
+
+	if err := writeFileAtomically(template.DestinationPath, bytes.Bytes(), template.Config.FileOwnership); err != nil {
 		log.Error().Msgf("template engine: unable to write secrets to path because %s. Will try again on next cycle", err)
 		return
 	}
@@ -851,10 +1075,16 @@ func (tm *AgentManager) MonitorSecretChanges(secretTemplate Template, templateId
 	var existingEtag string
 	var currentEtag string
 	var firstRun = true
+	var renderedFromCache = false
+	startedAt := time.Now()
 
 	execTimeout := secretTemplate.Config.Execute.Timeout
 	execCommand := secretTemplate.Config.Execute.Command
 
+	refreshChan := tm.secretChangeWatcher.RefreshChan(templateId)
+	forceRenderChan := tm.forceRenderChans[templateId]
+	forceRender := false
+
 	for {
 		select {
 		case <-sigChan:
@@ -866,24 +1096,52 @@ func (tm *AgentManager) MonitorSecretChanges(secretTemplate Template, templateId
 				if token != "" {
 					var processedTemplate *bytes.Buffer
 					var err error
+					renderStartedAt := time.Now()
 
-					if secretTemplate.SourcePath != "" {
-						processedTemplate, err = ProcessTemplate(templateId, secretTemplate.SourcePath, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases)
+					if secretTemplate.Config.Syntax == TEMPLATE_SYNTAX_CONSUL_TEMPLATE {
+						processedTemplate, err = ProcessConsulTemplate(templateId, &secretTemplate, token, existingEtag, &currentEtag, tm.secretChangeWatcher, tm.metrics)
+					} else if secretTemplate.SourcePath != "" {
+						processedTemplate, err = ProcessTemplate(templateId, secretTemplate.SourcePath, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases, tm.secretChangeWatcher, tm.metrics)
 					} else if secretTemplate.TemplateContent != "" {
-						processedTemplate, err = ProcessLiteralTemplate(templateId, secretTemplate.TemplateContent, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases)
+						processedTemplate, err = ProcessLiteralTemplate(templateId, secretTemplate.TemplateContent, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases, tm.secretChangeWatcher, tm.metrics)
 					} else {
-						processedTemplate, err = ProcessBase64Template(templateId, secretTemplate.Base64TemplateContent, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases)
+						processedTemplate, err = ProcessBase64Template(templateId, secretTemplate.Base64TemplateContent, nil, token, existingEtag, &currentEtag, tm.dynamicSecretLeases, tm.secretChangeWatcher, tm.metrics)
+					}
+
+					if err == nil {
+						tm.metrics.ObserveRender(templateId, time.Since(renderStartedAt))
+					}
+
+					if err != nil && firstRun && !renderedFromCache {
+						renderedFromCache = tm.renderTemplateFromCache(&secretTemplate, templateId)
 					}
 
 					if err != nil {
+						tm.status.FetchFailed(templateId, err)
+					} else {
+						tm.status.FetchSucceeded(templateId)
+					}
+
+					if errors.Is(err, api.ErrCircuitOpen) {
+						log.Warn().Msgf("Infisical API is unavailable, keeping the previously rendered secrets at %s", secretTemplate.DestinationPath)
+					} else if err != nil {
 						log.Error().Msgf("unable to process template because %v", err)
 					} else {
 						if (existingEtag != currentEtag) || firstRun {
 
 							tm.WriteTemplateToFile(processedTemplate, &secretTemplate)
+							tm.execSupervisor.TemplateRendered(templateId, processedTemplate.Bytes(), secretTemplate.Config.ExecEnv)
+							tm.persistentCache.Store(&secretTemplate, templateId, processedTemplate.Bytes())
+							tm.status.TemplateRendered(templateId, false)
+							tm.metrics.TemplateRendered(templateId, false)
 							existingEtag = currentEtag
 
-							if !firstRun && execCommand != "" {
+							if renderedFromCache {
+								setStaleMarker(&secretTemplate, false, time.Time{})
+								log.Info().Msgf("template %d has been rendered with fresh secrets, replacing the cached render", templateId+1)
+							}
+
+							if (!firstRun || renderedFromCache) && execCommand != "" {
 								log.Info().Msgf("executing command: %s", execCommand)
 								err := ExecuteCommandWithTimeout(execCommand, execTimeout)
 
@@ -892,11 +1150,14 @@ func (tm *AgentManager) MonitorSecretChanges(secretTemplate Template, templateId
 								}
 
 							}
-							if firstRun {
-								firstRun = false
-							}
+							firstRun = false
+							renderedFromCache = false
+						} else if forceRender {
+							// nothing changed, but restore the destination in case it was modified
+							tm.WriteTemplateToFile(processedTemplate, &secretTemplate)
 						}
 					}
+					forceRender = false
 
 					// now the idea is we pick the next sleep time in which the one shorter out of
 					// - polling time
@@ -906,8 +1167,18 @@ func (tm *AgentManager) MonitorSecretChanges(secretTemplate Template, templateId
 					if isValid && firstLeaseExpiry.Sub(time.Now()) < pollingInterval {
 						waitTime = firstLeaseExpiry.Sub(time.Now())
 					}
-					time.Sleep(waitTime)
+
+					select {
+					case <-time.After(waitTime):
+					case <-refreshChan:
+					case <-forceRenderChan:
+						forceRender = true
+					}
 				} else {
+					if firstRun && !renderedFromCache && time.Since(startedAt) > persistentCacheFallbackDelay {
+						renderedFromCache = tm.renderTemplateFromCache(&secretTemplate, templateId)
+					}
+
 					// It fails to get the access token. So we will re-try in 3 seconds. We do this because if we don't, the user will have to wait for the next polling interval to get the first secret render.
 					time.Sleep(3 * time.Second)
 				}
@@ -916,6 +1187,26 @@ func (tm *AgentManager) MonitorSecretChanges(secretTemplate Template, templateId
 	}
 }
 
+// renderTemplateFromCache writes the last known good render of a template while Infisical can't be
+// reached, marking the destination as stale until fresh secrets are rendered
+func (tm *AgentManager) renderTemplateFromCache(secretTemplate *Template, templateId int) bool {
+	entry, ok := tm.persistentCache.Load(secretTemplate, templateId)
+	if tm.persistentCache != nil {
+		tm.metrics.CacheLookup(ok)
+	}
+	if !ok {
+		return false
+	}
+
+	log.Warn().Msgf("unable to fetch secrets for template %d, rendering the cached values from %s until Infisical is reachable", templateId+1, entry.RenderedAt.Format(time.RFC3339))
+	tm.WriteTemplateToFile(bytes.NewBuffer(entry.Content), secretTemplate)
+	setStaleMarker(secretTemplate, true, entry.RenderedAt)
+	tm.execSupervisor.TemplateRendered(templateId, entry.Content, secretTemplate.Config.ExecEnv)
+	tm.status.TemplateRendered(templateId, true)
+	tm.metrics.TemplateRendered(templateId, true)
+	return true
+}
+
 // runCmd represents the run command
 var agentCmd = &cobra.Command{
 	Example: `
@@ -979,6 +1270,9 @@ var agentCmd = &cobra.Command{
 		sigChan := make(chan os.Signal, 1)
 		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+		reloadChan := make(chan os.Signal, 1)
+		signal.Notify(reloadChan, syscall.SIGHUP)
+
 		filePaths := agentConfig.Sinks
 
 		configBytes, err := yaml.Marshal(agentConfig.Auth.Config)
@@ -997,6 +1291,50 @@ var agentCmd = &cobra.Command{
 		})
 
 		tm.dynamicSecretLeases = NewDynamicSecretLeaseManager(sigChan)
+		if !agentConfig.Infisical.DisableSecretEvents {
+			tm.secretChangeWatcher = NewSecretChangeWatcher(tm.GetToken)
+		}
+
+		if agentConfig.Cache.Persistent != nil {
+			tm.persistentCache, err = newPersistentCache(*agentConfig.Cache.Persistent)
+			if err != nil {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid cache config: %v", err))
+			}
+		}
+
+		if agentConfig.Metrics != nil {
+			if agentConfig.Metrics.ListenAddress == "" {
+				util.PrintErrorMessageAndExit("Invalid metrics config: metrics.listen-address is required")
+			}
+			tm.metrics = newAgentMetrics()
+			go tm.metrics.Serve(agentConfig.Metrics.ListenAddress)
+		}
+
+		if agentConfig.Health != nil {
+			tm.status, err = newAgentStatus(*agentConfig.Health, agentConfig.Templates)
+			if err != nil {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid health config: %v", err))
+			}
+			if agentConfig.Health.ListenAddress != "" {
+				go tm.status.Serve(agentConfig.Health.ListenAddress)
+			}
+		}
+
+		var execExitCodes chan int
+		if agentConfig.Exec != nil {
+			templateIds := []int{}
+			for i := range agentConfig.Templates {
+				templateIds = append(templateIds, i)
+			}
+
+			tm.execSupervisor, err = newExecSupervisor(*agentConfig.Exec, templateIds)
+			if err != nil {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid exec config: %v", err))
+			}
+
+			execExitCodes = make(chan int, 1)
+			go tm.execSupervisor.Run(execExitCodes)
+		}
 
 		go tm.ManageTokenLifecycle()
 
@@ -1005,12 +1343,23 @@ var agentCmd = &cobra.Command{
 			go tm.MonitorSecretChanges(template, i, sigChan)
 		}
 
+		for i, certificate := range agentConfig.Certificates {
+			log.Info().Msgf("certificate manager started for certificate %v...", i+1)
+			go tm.ManageCertificate(certificate, i)
+		}
+
 		for {
 			select {
 			case <-tokenRefreshNotifier:
 				go tm.WriteTokenToFiles()
+			case <-reloadChan:
+				log.Info().Msg("received SIGHUP, fetching secrets and rendering all templates...")
+				tm.ForceRender()
+			case exitCode := <-execExitCodes:
+				os.Exit(exitCode)
 			case <-sigChan:
 				log.Info().Msg("agent is gracefully shutting...")
+				tm.execSupervisor.Shutdown()
 				// TODO: check if we are in the middle of writing files to disk
 				os.Exit(1)
 			}
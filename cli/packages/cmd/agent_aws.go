@@ -0,0 +1,308 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsManagerTypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	infisicalSdk "github.com/infisical/go-sdk"
+)
+
+const (
+	AWS_DESTINATION_SSM             = "ssm"
+	AWS_DESTINATION_SECRETS_MANAGER = "secrets-manager"
+
+	awsRequestTimeout = 30 * time.Second
+	awsMaxTags        = 50
+	// awsSSMStandardMaxValueSize is the size of the largest value of a standard SSM parameter
+	awsSSMStandardMaxValueSize = 4096
+)
+
+// AWSDestination pushes a rendered template to an SSM parameter or a Secrets Manager secret, for AWS
+// native consumers that can't call Infisical. The whole output is the value, so render JSON for the
+// consumers that read a Secrets Manager secret as key/value pairs. Credentials are those of the AWS
+// environment of the agent: environment variables, shared config, or the role of the instance or task.
+// SSM values over 4KB are stored as advanced parameters.
+type AWSDestination struct {
+	Service string `yaml:"service"` // ssm or secrets-manager
+	Name    string `yaml:"name"`    // Parameter name, e.g. /app/config, or secret name
+	Region  string `yaml:"region"`  // Defaults to the region of the AWS environment
+	// KmsKeyID encrypts the value with this KMS key instead of the AWS managed one. Secrets Manager
+	// only uses it when the agent creates the secret.
+	KmsKeyID string            `yaml:"kms-key-id"`
+	Tags     map[string]string `yaml:"tags"`
+}
+
+func (d *AWSDestination) validate() error {
+	if d.Name == "" {
+		return errors.New("destination-aws.name is required")
+	}
+
+	switch d.Service {
+	case AWS_DESTINATION_SSM, AWS_DESTINATION_SECRETS_MANAGER:
+	default:
+		return fmt.Errorf("destination-aws.service must be %s or %s, got '%s'", AWS_DESTINATION_SSM, AWS_DESTINATION_SECRETS_MANAGER, d.Service)
+	}
+
+	if len(d.Tags) > awsMaxTags {
+		return fmt.Errorf("destination-aws.tags can't hold more than %d tags", awsMaxTags)
+	}
+
+	return nil
+}
+
+// changedTags returns the keys of the tags of the destination that existing lacks or holds another
+// value for, sorted, so tags are only written when they change
+func (d *AWSDestination) changedTags(existing map[string]string) []string {
+	var keys []string
+	for key, value := range d.Tags {
+		if existingValue, ok := existing[key]; !ok || existingValue != value {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ssmTier returns the tier a value must be stored in, as standard parameters hold at most 4KB. It is
+// left to the default tier of the account otherwise.
+func ssmTier(value string) ssmTypes.ParameterTier {
+	if len(value) > awsSSMStandardMaxValueSize {
+		return ssmTypes.ParameterTierAdvanced
+	}
+	return ""
+}
+
+// awsSSMClient is the part of the SSM client the agent uses
+type awsSSMClient interface {
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	ListTagsForResource(ctx context.Context, params *ssm.ListTagsForResourceInput, optFns ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error)
+	AddTagsToResource(ctx context.Context, params *ssm.AddTagsToResourceInput, optFns ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error)
+}
+
+// awsSecretsManagerClient is the part of the Secrets Manager client the agent uses
+type awsSecretsManagerClient interface {
+	PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error)
+	CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error)
+	DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	TagResource(ctx context.Context, params *secretsmanager.TagResourceInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error)
+}
+
+// loadAWSConfig loads the config of the AWS environment, with region when it is set
+func loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	var options []func(*awsConfig.LoadOptions) error
+	if region != "" {
+		options = append(options, awsConfig.WithRegion(region))
+	}
+
+	config, err := awsConfig.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("unable to load the AWS config: %w", err)
+	}
+	if config.Region == "" {
+		return aws.Config{}, errors.New("no AWS region found, set destination-aws.region or AWS_REGION")
+	}
+	return config, nil
+}
+
+func putParameter(ctx context.Context, client awsSSMClient, destination *AWSDestination, value string) error {
+	input := &ssm.PutParameterInput{
+		Name:      aws.String(destination.Name),
+		Value:     aws.String(value),
+		Type:      ssmTypes.ParameterTypeSecureString,
+		Overwrite: aws.Bool(true),
+		Tier:      ssmTier(value),
+	}
+	if destination.KmsKeyID != "" {
+		input.KeyId = aws.String(destination.KmsKeyID)
+	}
+
+	if _, err := client.PutParameter(ctx, input); err != nil {
+		return err
+	}
+
+	// tags can't be passed along with Overwrite
+	if len(destination.Tags) == 0 {
+		return nil
+	}
+
+	existingTags, err := client.ListTagsForResource(ctx, &ssm.ListTagsForResourceInput{
+		ResourceType: ssmTypes.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String(destination.Name),
+	})
+	if err != nil {
+		return err
+	}
+	existing := map[string]string{}
+	for _, tag := range existingTags.TagList {
+		existing[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	changed := destination.changedTags(existing)
+	if len(changed) == 0 {
+		return nil
+	}
+	tags := make([]ssmTypes.Tag, 0, len(changed))
+	for _, key := range changed {
+		tags = append(tags, ssmTypes.Tag{Key: aws.String(key), Value: aws.String(destination.Tags[key])})
+	}
+	_, err = client.AddTagsToResource(ctx, &ssm.AddTagsToResourceInput{
+		ResourceType: ssmTypes.ResourceTypeForTaggingParameter,
+		ResourceId:   aws.String(destination.Name),
+		Tags:         tags,
+	})
+	return err
+}
+
+func putSecretValue(ctx context.Context, client awsSecretsManagerClient, destination *AWSDestination, value string) error {
+	_, err := client.PutSecretValue(ctx, &secretsmanager.PutSecretValueInput{
+		SecretId:     aws.String(destination.Name),
+		SecretString: aws.String(value),
+	})
+
+	var notFound *secretsManagerTypes.ResourceNotFoundException
+	if errors.As(err, &notFound) {
+		input := &secretsmanager.CreateSecretInput{
+			Name:         aws.String(destination.Name),
+			SecretString: aws.String(value),
+		}
+		if destination.KmsKeyID != "" {
+			input.KmsKeyId = aws.String(destination.KmsKeyID)
+		}
+		for _, key := range destination.changedTags(nil) {
+			input.Tags = append(input.Tags, secretsManagerTypes.Tag{Key: aws.String(key), Value: aws.String(destination.Tags[key])})
+		}
+		_, err = client.CreateSecret(ctx, input)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(destination.Tags) == 0 {
+		return nil
+	}
+
+	secret, err := client.DescribeSecret(ctx, &secretsmanager.DescribeSecretInput{SecretId: aws.String(destination.Name)})
+	if err != nil {
+		return err
+	}
+	existing := map[string]string{}
+	for _, tag := range secret.Tags {
+		existing[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	changed := destination.changedTags(existing)
+	if len(changed) == 0 {
+		return nil
+	}
+	tags := make([]secretsManagerTypes.Tag, 0, len(changed))
+	for _, key := range changed {
+		tags = append(tags, secretsManagerTypes.Tag{Key: aws.String(key), Value: aws.String(destination.Tags[key])})
+	}
+	_, err = client.TagResource(ctx, &secretsmanager.TagResourceInput{SecretId: aws.String(destination.Name), Tags: tags})
+	return err
+}
+
+// applyTemplateToAWS pushes the rendered template to its parameter or secret, and returns a description
+// of it for the logs. Endpoints, including AWS_ENDPOINT_URL, and retries are those of the AWS SDK.
+func applyTemplateToAWS(rendered []byte, destination *AWSDestination) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	config, err := loadAWSConfig(ctx, destination.Region)
+	if err != nil {
+		return "", err
+	}
+
+	if destination.Service == AWS_DESTINATION_SSM {
+		if err := putParameter(ctx, ssm.NewFromConfig(config), destination, string(rendered)); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("SSM parameter %s in %s", destination.Name, config.Region), nil
+	}
+
+	if err := putSecretValue(ctx, secretsmanager.NewFromConfig(config), destination, string(rendered)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Secrets Manager secret %s in %s", destination.Name, config.Region), nil
+}
+
+// awsIamAuthLogin logs in like the sdk does, but signs the GetCallerIdentity request for region
+// instead of the region the sdk discovers from the environment
+func awsIamAuthLogin(identityId string, region string) (infisicalSdk.MachineIdentityCredential, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsRequestTimeout)
+	defer cancel()
+
+	config, err := awsConfig.LoadDefaultConfig(ctx, awsConfig.WithRegion(region))
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to load the AWS config: %w", err)
+	}
+
+	credentials, err := config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to get AWS credentials: %w", err)
+	}
+
+	body := "Action=GetCallerIdentity&Version=2011-06-15"
+	host := fmt.Sprintf("sts.%s.amazonaws.com", region)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", strings.NewReader(body))
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, err
+	}
+
+	payloadHash := sha256.Sum256([]byte(body))
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, request, hex.EncodeToString(payloadHash[:]), "sts", region, time.Now()); err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("unable to sign the GetCallerIdentity request: %w", err)
+	}
+
+	headers := map[string]string{}
+	for name, values := range request.Header {
+		headers[name] = values[0]
+	}
+	headers["Host"] = host
+	headers["Content-Type"] = "application/x-www-form-urlencoded; charset=utf-8"
+	headers["Content-Length"] = strconv.Itoa(len(body))
+
+	encodedHeaders, err := json.Marshal(headers)
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, err
+	}
+
+	loginResponse, err := api.CallAwsIamAuthLoginV1(api.NewHTTPClient(), api.AwsIamAuthLoginV1Request{
+		IdentityId:        identityId,
+		HTTPRequestMethod: request.Method,
+		IamRequestBody:    base64.StdEncoding.EncodeToString([]byte(body)),
+		IamRequestHeaders: base64.StdEncoding.EncodeToString(encodedHeaders),
+	})
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, err
+	}
+
+	return infisicalSdk.MachineIdentityCredential{
+		AccessToken:       loginResponse.AccessToken,
+		ExpiresIn:         int64(loginResponse.AccessTokenTTL),
+		AccessTokenMaxTTL: int64(loginResponse.AccessTokenMaxTTL),
+		TokenType:         loginResponse.TokenType,
+	}, nil
+}
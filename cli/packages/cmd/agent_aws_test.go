@@ -0,0 +1,293 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	secretsManagerTypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwsIamAuthLoginSignsForRegion(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var loginRequest api.AwsIamAuthLoginV1Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/auth/aws-auth/login", r.URL.Path)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&loginRequest))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"accessToken":"token","expiresIn":600,"accessTokenMaxTTL":3600,"tokenType":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	previousURL := config.INFISICAL_URL
+	defer func() { config.INFISICAL_URL = previousURL }()
+	config.INFISICAL_URL = server.URL
+
+	credential, err := awsIamAuthLogin("identity", "eu-west-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "token", credential.AccessToken)
+	assert.Equal(t, int64(600), credential.ExpiresIn)
+	assert.Equal(t, int64(3600), credential.AccessTokenMaxTTL)
+
+	assert.Equal(t, "identity", loginRequest.IdentityId)
+	assert.Equal(t, http.MethodPost, loginRequest.HTTPRequestMethod)
+
+	body, err := base64.StdEncoding.DecodeString(loginRequest.IamRequestBody)
+	assert.NoError(t, err)
+	assert.Equal(t, "Action=GetCallerIdentity&Version=2011-06-15", string(body))
+
+	encodedHeaders, err := base64.StdEncoding.DecodeString(loginRequest.IamRequestHeaders)
+	assert.NoError(t, err)
+	var headers map[string]string
+	assert.NoError(t, json.Unmarshal(encodedHeaders, &headers))
+	assert.Equal(t, "sts.eu-west-1.amazonaws.com", headers["Host"])
+	assert.Contains(t, headers["Authorization"], "/eu-west-1/sts/aws4_request")
+
+	// the region is only used for signing and never leaks into the environment of child processes
+	assert.Empty(t, os.Getenv("AWS_REGION"))
+}
+
+// fakeSSMClient stores the parameters put and the tags of each parameter
+type fakeSSMClient struct {
+	puts      []*ssm.PutParameterInput
+	tags      map[string]string
+	tagWrites [][]ssmTypes.Tag
+}
+
+func (c *fakeSSMClient) PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	c.puts = append(c.puts, params)
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (c *fakeSSMClient) ListTagsForResource(ctx context.Context, params *ssm.ListTagsForResourceInput, optFns ...func(*ssm.Options)) (*ssm.ListTagsForResourceOutput, error) {
+	output := &ssm.ListTagsForResourceOutput{}
+	for key, value := range c.tags {
+		output.TagList = append(output.TagList, ssmTypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (c *fakeSSMClient) AddTagsToResource(ctx context.Context, params *ssm.AddTagsToResourceInput, optFns ...func(*ssm.Options)) (*ssm.AddTagsToResourceOutput, error) {
+	c.tagWrites = append(c.tagWrites, params.Tags)
+	for _, tag := range params.Tags {
+		c.tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return &ssm.AddTagsToResourceOutput{}, nil
+}
+
+func TestPutParameter(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		kmsKeyID      string
+		tags          map[string]string
+		existingTags  map[string]string
+		wantTier      ssmTypes.ParameterTier
+		wantTagWrites [][]ssmTypes.Tag
+	}{
+		{name: "standard value", value: "DB_PASSWORD=hunter2", wantTier: ""},
+		{name: "value over 4KB", value: strings.Repeat("a", 4097), wantTier: ssmTypes.ParameterTierAdvanced},
+		{name: "value of 4KB", value: strings.Repeat("a", 4096), kmsKeyID: "alias/app", wantTier: ""},
+		{
+			name:          "new tags",
+			value:         "value",
+			tags:          map[string]string{"team": "payments", "env": "prod"},
+			existingTags:  map[string]string{},
+			wantTagWrites: [][]ssmTypes.Tag{{{Key: aws.String("env"), Value: aws.String("prod")}, {Key: aws.String("team"), Value: aws.String("payments")}}},
+		},
+		{
+			name:          "changed tag",
+			value:         "value",
+			tags:          map[string]string{"team": "payments", "env": "prod"},
+			existingTags:  map[string]string{"team": "billing", "env": "prod", "owner": "console"},
+			wantTagWrites: [][]ssmTypes.Tag{{{Key: aws.String("team"), Value: aws.String("payments")}}},
+		},
+		{
+			name:         "tags already set",
+			value:        "value",
+			tags:         map[string]string{"team": "payments"},
+			existingTags: map[string]string{"team": "payments"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &fakeSSMClient{tags: test.existingTags}
+			destination := &AWSDestination{Service: AWS_DESTINATION_SSM, Name: "/app/config", KmsKeyID: test.kmsKeyID, Tags: test.tags}
+
+			assert.NoError(t, putParameter(context.Background(), client, destination, test.value))
+
+			assert.Len(t, client.puts, 1)
+			put := client.puts[0]
+			assert.Equal(t, "/app/config", aws.ToString(put.Name))
+			assert.Equal(t, test.value, aws.ToString(put.Value))
+			assert.Equal(t, ssmTypes.ParameterTypeSecureString, put.Type)
+			assert.True(t, aws.ToBool(put.Overwrite))
+			assert.Equal(t, test.wantTier, put.Tier)
+			if test.kmsKeyID == "" {
+				assert.Nil(t, put.KeyId)
+			} else {
+				assert.Equal(t, test.kmsKeyID, aws.ToString(put.KeyId))
+			}
+			assert.Equal(t, test.wantTagWrites, client.tagWrites)
+		})
+	}
+}
+
+// fakeSecretsManagerClient holds the value and tags of one secret, which exists once it has a value
+type fakeSecretsManagerClient struct {
+	value     *string
+	tags      map[string]string
+	created   *secretsmanager.CreateSecretInput
+	tagWrites [][]secretsManagerTypes.Tag
+}
+
+func (c *fakeSecretsManagerClient) PutSecretValue(ctx context.Context, params *secretsmanager.PutSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.PutSecretValueOutput, error) {
+	if c.value == nil {
+		return nil, &secretsManagerTypes.ResourceNotFoundException{Message: aws.String("Secrets Manager can't find the specified secret.")}
+	}
+	c.value = params.SecretString
+	return &secretsmanager.PutSecretValueOutput{}, nil
+}
+
+func (c *fakeSecretsManagerClient) CreateSecret(ctx context.Context, params *secretsmanager.CreateSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.CreateSecretOutput, error) {
+	c.created = params
+	c.value = params.SecretString
+	return &secretsmanager.CreateSecretOutput{}, nil
+}
+
+func (c *fakeSecretsManagerClient) DescribeSecret(ctx context.Context, params *secretsmanager.DescribeSecretInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error) {
+	output := &secretsmanager.DescribeSecretOutput{Name: params.SecretId}
+	for key, value := range c.tags {
+		output.Tags = append(output.Tags, secretsManagerTypes.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+	return output, nil
+}
+
+func (c *fakeSecretsManagerClient) TagResource(ctx context.Context, params *secretsmanager.TagResourceInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.TagResourceOutput, error) {
+	c.tagWrites = append(c.tagWrites, params.Tags)
+	return &secretsmanager.TagResourceOutput{}, nil
+}
+
+func TestPutSecretValue(t *testing.T) {
+	tests := []struct {
+		name          string
+		existingValue *string
+		existingTags  map[string]string
+		tags          map[string]string
+		wantCreated   bool
+		wantTagWrites [][]secretsManagerTypes.Tag
+	}{
+		{name: "new secret", tags: map[string]string{"team": "payments"}, wantCreated: true},
+		{name: "existing secret", existingValue: aws.String("previous")},
+		{
+			name:          "existing secret with changed tags",
+			existingValue: aws.String("previous"),
+			existingTags:  map[string]string{"team": "billing"},
+			tags:          map[string]string{"team": "payments"},
+			wantTagWrites: [][]secretsManagerTypes.Tag{{{Key: aws.String("team"), Value: aws.String("payments")}}},
+		},
+		{
+			name:          "existing secret with its tags",
+			existingValue: aws.String("previous"),
+			existingTags:  map[string]string{"team": "payments"},
+			tags:          map[string]string{"team": "payments"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := &fakeSecretsManagerClient{value: test.existingValue, tags: test.existingTags}
+			destination := &AWSDestination{Service: AWS_DESTINATION_SECRETS_MANAGER, Name: "app/config", KmsKeyID: "alias/app", Tags: test.tags}
+
+			assert.NoError(t, putSecretValue(context.Background(), client, destination, `{"DB_PASSWORD":"hunter2"}`))
+			assert.Equal(t, `{"DB_PASSWORD":"hunter2"}`, aws.ToString(client.value))
+
+			if test.wantCreated {
+				assert.NotNil(t, client.created)
+				assert.Equal(t, "app/config", aws.ToString(client.created.Name))
+				assert.Equal(t, "alias/app", aws.ToString(client.created.KmsKeyId))
+				assert.Equal(t, []secretsManagerTypes.Tag{{Key: aws.String("team"), Value: aws.String("payments")}}, client.created.Tags)
+			} else {
+				assert.Nil(t, client.created)
+			}
+			assert.Equal(t, test.wantTagWrites, client.tagWrites)
+		})
+	}
+}
+
+func TestAWSDestinationValidate(t *testing.T) {
+	tooManyTags := map[string]string{}
+	for i := 0; i <= awsMaxTags; i++ {
+		tooManyTags[fmt.Sprintf("tag-%d", i)] = "value"
+	}
+
+	tests := []struct {
+		name        string
+		destination AWSDestination
+		wantErr     bool
+	}{
+		{name: "ssm", destination: AWSDestination{Service: AWS_DESTINATION_SSM, Name: "/app/config"}},
+		{name: "secrets manager", destination: AWSDestination{Service: AWS_DESTINATION_SECRETS_MANAGER, Name: "app/config"}},
+		{name: "no name", destination: AWSDestination{Service: AWS_DESTINATION_SSM}, wantErr: true},
+		{name: "unknown service", destination: AWSDestination{Service: "s3", Name: "bucket"}, wantErr: true},
+		{name: "too many tags", destination: AWSDestination{Service: AWS_DESTINATION_SSM, Name: "/app/config", Tags: tooManyTags}, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.destination.validate()
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestApplyTemplateToAWSUsesEndpointOfEnvironment(t *testing.T) {
+	var targets []string
+	var putInput map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.Header.Get("X-Amz-Target")
+		targets = append(targets, target)
+		if target == "AmazonSSM.PutParameter" {
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&putInput))
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+	t.Setenv("AWS_ENDPOINT_URL", server.URL)
+
+	description, err := applyTemplateToAWS([]byte(strings.Repeat("a", 5000)), &AWSDestination{Service: AWS_DESTINATION_SSM, Name: "/app/config", Region: "eu-west-1"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SSM parameter /app/config in eu-west-1", description)
+	assert.Equal(t, []string{"AmazonSSM.PutParameter"}, targets)
+	assert.Equal(t, "Advanced", putInput["Tier"])
+}
@@ -0,0 +1,195 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultPersistentCacheMaxStaleness = 24 * time.Hour
+	// how long a template waits for its first render before falling back to the cache
+	persistentCacheFallbackDelay = 15 * time.Second
+	staleMarkerSuffix            = ".stale"
+)
+
+type CacheConfig struct {
+	Persistent *PersistentCacheConfig `yaml:"persistent"`
+}
+
+// PersistentCacheConfig keeps the last rendered output of every template encrypted on disk, so the
+// agent can still render templates when Infisical is unreachable while it starts
+type PersistentCacheConfig struct {
+	Path string `yaml:"path"` // directory holding the encrypted cache
+	// File holding the hex encoded AES-256 key, generated when missing. Keep it outside of the cache
+	// directory, e.g. in a Kubernetes Secret, so a copy of the cache alone reveals nothing.
+	KeyFile string `yaml:"key-file"`
+	// Cached renders older than this are not used, defaults to 24h
+	MaxStaleness string `yaml:"max-staleness"`
+}
+
+type persistentCache struct {
+	dir          string
+	key          []byte
+	maxStaleness time.Duration
+}
+
+type persistentCacheEntry struct {
+	RenderedAt time.Time `json:"renderedAt"`
+	Content    []byte    `json:"content"`
+}
+
+func newPersistentCache(cacheConfig PersistentCacheConfig) (*persistentCache, error) {
+	if cacheConfig.Path == "" {
+		return nil, errors.New("cache.persistent.path is required")
+	}
+	if cacheConfig.KeyFile == "" {
+		return nil, errors.New("cache.persistent.key-file is required")
+	}
+
+	cache := &persistentCache{dir: cacheConfig.Path, maxStaleness: defaultPersistentCacheMaxStaleness}
+
+	if cacheConfig.MaxStaleness != "" {
+		maxStaleness, err := time.ParseDuration(cacheConfig.MaxStaleness)
+		if err != nil || maxStaleness <= 0 {
+			return nil, fmt.Errorf("cache.persistent.max-staleness must be a positive duration such as 24h")
+		}
+		cache.maxStaleness = maxStaleness
+	}
+
+	if err := os.MkdirAll(cache.dir, 0700); err != nil {
+		return nil, fmt.Errorf("unable to create cache directory: %w", err)
+	}
+
+	key, err := readOrCreateCacheKey(cacheConfig.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	cache.key = key
+
+	return cache, nil
+}
+
+func readOrCreateCacheKey(keyFile string) ([]byte, error) {
+	encodedKey, err := os.ReadFile(keyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(keyFile, []byte(hex.EncodeToString(key)), 0600); err != nil {
+			return nil, fmt.Errorf("unable to write cache key: %w", err)
+		}
+		log.Info().Msgf("generated a new cache encryption key at %s", keyFile)
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cache key: %w", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(encodedKey)))
+	if err != nil || len(key) != 32 {
+		return nil, fmt.Errorf("cache key in %s must be 32 hex encoded bytes", keyFile)
+	}
+	return key, nil
+}
+
+// entryPath derives the file name from the template definition, so editing a template doesn't
+// bring back output rendered from its old version
+func (c *persistentCache) entryPath(template *Template, templateId int) string {
+	definition, _ := json.Marshal([]interface{}{templateId, template.SourcePath, template.TemplateContent, template.Base64TemplateContent, template.DestinationPath})
+	hash := sha256.Sum256(definition)
+	return filepath.Join(c.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+func (c *persistentCache) Store(template *Template, templateId int, content []byte) {
+	if c == nil {
+		return
+	}
+
+	plainText, _ := json.Marshal(persistentCacheEntry{RenderedAt: time.Now(), Content: content})
+	encrypted, err := crypto.EncryptSymmetric(plainText, c.key)
+	if err != nil {
+		log.Error().Msgf("cache: unable to encrypt template %d because %v", templateId+1, err)
+		return
+	}
+
+	encoded, _ := json.Marshal(encrypted)
+	if err := writeFileAtomically(c.entryPath(template, templateId), encoded, FileOwnership{Permissions: "0600"}); err != nil {
+		log.Error().Msgf("cache: unable to save template %d because %v", templateId+1, err)
+	}
+}
+
+// Load returns the last rendered output of a template unless it is older than the staleness limit
+func (c *persistentCache) Load(template *Template, templateId int) (persistentCacheEntry, bool) {
+	if c == nil {
+		return persistentCacheEntry{}, false
+	}
+
+	encoded, err := os.ReadFile(c.entryPath(template, templateId))
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Error().Msgf("cache: unable to read template %d because %v", templateId+1, err)
+		}
+		return persistentCacheEntry{}, false
+	}
+
+	var encrypted models.SymmetricEncryptionResult
+	if err := json.Unmarshal(encoded, &encrypted); err != nil {
+		log.Error().Msgf("cache: entry of template %d is malformed", templateId+1)
+		return persistentCacheEntry{}, false
+	}
+
+	plainText, err := crypto.DecryptSymmetric(c.key, encrypted.CipherText, encrypted.AuthTag, encrypted.Nonce)
+	if err != nil {
+		log.Error().Msgf("cache: unable to decrypt template %d, was the key changed? %v", templateId+1, err)
+		return persistentCacheEntry{}, false
+	}
+
+	var entry persistentCacheEntry
+	if err := json.Unmarshal(plainText, &entry); err != nil {
+		log.Error().Msgf("cache: entry of template %d is malformed", templateId+1)
+		return persistentCacheEntry{}, false
+	}
+
+	if age := time.Since(entry.RenderedAt); age > c.maxStaleness {
+		log.Warn().Msgf("cache: entry of template %d is %s old, which exceeds the staleness limit of %s", templateId+1, age.Round(time.Second), c.maxStaleness)
+		return persistentCacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+// setStaleMarker creates or removes <destination-path>.stale, which holds the time the cached
+// values were fetched while the destination is rendered from the cache
+func setStaleMarker(template *Template, stale bool, renderedAt time.Time) {
+	if template.DestinationPath == "" {
+		return
+	}
+
+	markerPath := template.DestinationPath + staleMarkerSuffix
+	if !stale {
+		if err := os.Remove(markerPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Error().Msgf("cache: unable to remove stale marker %s because %v", markerPath, err)
+		}
+		return
+	}
+
+	if err := writeFileAtomically(markerPath, []byte(renderedAt.UTC().Format(time.RFC3339)+"\n"), template.Config.FileOwnership); err != nil {
+		log.Error().Msgf("cache: unable to write stale marker %s because %v", markerPath, err)
+	}
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPersistentCache(t *testing.T) {
+	dir := t.TempDir()
+	invalidKeyFile := filepath.Join(dir, "invalid.key")
+	assert.NoError(t, os.WriteFile(invalidKeyFile, []byte("not hex"), 0600))
+	shortKeyFile := filepath.Join(dir, "short.key")
+	assert.NoError(t, os.WriteFile(shortKeyFile, []byte(hex.EncodeToString(make([]byte, 16))), 0600))
+
+	tests := []struct {
+		name             string
+		config           PersistentCacheConfig
+		wantErr          string
+		wantMaxStaleness time.Duration
+	}{
+		{name: "defaults", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: filepath.Join(dir, "new.key")}, wantMaxStaleness: defaultPersistentCacheMaxStaleness},
+		{name: "max staleness", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: filepath.Join(dir, "new.key"), MaxStaleness: "1h"}, wantMaxStaleness: time.Hour},
+		{name: "no path", config: PersistentCacheConfig{KeyFile: filepath.Join(dir, "new.key")}, wantErr: "path is required"},
+		{name: "no key file", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache")}, wantErr: "key-file is required"},
+		{name: "invalid max staleness", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: filepath.Join(dir, "new.key"), MaxStaleness: "-1h"}, wantErr: "positive duration"},
+		{name: "key that isn't hex", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: invalidKeyFile}, wantErr: "32 hex encoded bytes"},
+		{name: "short key", config: PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: shortKeyFile}, wantErr: "32 hex encoded bytes"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cache, err := newPersistentCache(test.config)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantMaxStaleness, cache.maxStaleness)
+			assert.Len(t, cache.key, 32)
+		})
+	}
+}
+
+func TestPersistentCacheKeyIsReused(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "cache.key")
+
+	first, err := newPersistentCache(PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: keyFile})
+	assert.NoError(t, err)
+	second, err := newPersistentCache(PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: keyFile})
+	assert.NoError(t, err)
+	assert.Equal(t, first.key, second.key)
+
+	info, err := os.Stat(keyFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "the generated key is only readable by its owner")
+}
+
+func TestPersistentCacheLoad(t *testing.T) {
+	template := &Template{TemplateContent: "{{ .A }}", DestinationPath: "/etc/app/config"}
+	editedTemplate := &Template{TemplateContent: "{{ .B }}", DestinationPath: "/etc/app/config"}
+
+	tests := []struct {
+		name      string
+		store     func(cache *persistentCache)
+		load      *Template
+		loadKey   []byte
+		wantFound bool
+	}{
+		{
+			name:      "cached render",
+			store:     func(cache *persistentCache) { cache.Store(template, 0, []byte("rendered")) },
+			load:      template,
+			wantFound: true,
+		},
+		{
+			name:  "nothing cached",
+			store: func(cache *persistentCache) {},
+			load:  template,
+		},
+		{
+			name:  "edited template",
+			store: func(cache *persistentCache) { cache.Store(template, 0, []byte("rendered")) },
+			load:  editedTemplate,
+		},
+		{
+			name: "stale render",
+			store: func(cache *persistentCache) {
+				cache.maxStaleness = time.Nanosecond
+				cache.Store(template, 0, []byte("rendered"))
+				time.Sleep(time.Millisecond)
+			},
+			load: template,
+		},
+		{
+			name:    "another key",
+			store:   func(cache *persistentCache) { cache.Store(template, 0, []byte("rendered")) },
+			load:    template,
+			loadKey: make([]byte, 32),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			cache, err := newPersistentCache(PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: filepath.Join(dir, "cache.key")})
+			assert.NoError(t, err)
+
+			test.store(cache)
+			if test.loadKey != nil {
+				cache.key = test.loadKey
+			}
+
+			entry, found := cache.Load(test.load, 0)
+			assert.Equal(t, test.wantFound, found)
+			if test.wantFound {
+				assert.Equal(t, "rendered", string(entry.Content))
+			}
+		})
+	}
+}
+
+func TestPersistentCacheIsEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := newPersistentCache(PersistentCacheConfig{Path: filepath.Join(dir, "cache"), KeyFile: filepath.Join(dir, "cache.key")})
+	assert.NoError(t, err)
+
+	template := &Template{TemplateContent: "{{ .A }}"}
+	cache.Store(template, 0, []byte("DB_PASSWORD=hunter2"))
+
+	content, err := os.ReadFile(cache.entryPath(template, 0))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "hunter2")
+}
+
+func TestSetStaleMarker(t *testing.T) {
+	destination := filepath.Join(t.TempDir(), "config")
+	template := &Template{DestinationPath: destination}
+	renderedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	setStaleMarker(template, true, renderedAt)
+	content, err := os.ReadFile(destination + staleMarkerSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-05-01T12:00:00Z\n", string(content))
+
+	setStaleMarker(template, false, time.Time{})
+	_, err = os.Stat(destination + staleMarkerSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
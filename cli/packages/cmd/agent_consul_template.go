@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// TEMPLATE_SYNTAX_CONSUL_TEMPLATE renders templates written for consul-template and Vault Agent
+const TEMPLATE_SYNTAX_CONSUL_TEMPLATE = "consul-template"
+
+// ConsulTemplateConfig maps the Vault paths read by a consul-template file onto Infisical, so templates
+// migrated from Vault Agent can be reused with few edits
+type ConsulTemplateConfig struct {
+	Mounts map[string]ConsulTemplateMount `yaml:"mounts"` // Vault KV mounts, e.g. secret, keyed by their name
+}
+
+type ConsulTemplateMount struct {
+	ProjectID   string `yaml:"project-id"`
+	Environment string `yaml:"environment"`
+	SecretPath  string `yaml:"secret-path"` // Infisical folder the root of the mount maps to, / by default
+	KVVersion   int    `yaml:"kv-version"`  // Version of the Vault KV engine the templates were written for, 2 by default
+}
+
+// consulTemplateSecret is the result of {{ with secret "..." }}, shaped like the Vault secret that
+// consul-template returns: .Data.data.KEY for KV version 2, and .Data.KEY for KV version 1
+type consulTemplateSecret struct {
+	LeaseID       string
+	LeaseDuration int
+	Renewable     bool
+	Data          map[string]interface{}
+}
+
+func (c *ConsulTemplateConfig) validate() error {
+	if c == nil || len(c.Mounts) == 0 {
+		return fmt.Errorf("consul-template syntax requires consul-template.mounts to map Vault paths onto Infisical")
+	}
+
+	for name, mount := range c.Mounts {
+		if mount.ProjectID == "" || mount.Environment == "" {
+			return fmt.Errorf("consul-template mount %s: project-id and environment are required", name)
+		}
+		if mount.KVVersion != 0 && mount.KVVersion != 1 && mount.KVVersion != 2 {
+			return fmt.Errorf("consul-template mount %s: kv-version must be 1 or 2", name)
+		}
+	}
+	return nil
+}
+
+// resolve returns the mount and the Infisical folder of a Vault path such as secret/data/myapp/config
+func (c *ConsulTemplateConfig) resolve(vaultPath string) (ConsulTemplateMount, string, error) {
+	mountName, rest, _ := strings.Cut(strings.Trim(vaultPath, "/"), "/")
+	mount, ok := c.Mounts[mountName]
+	if !ok {
+		return ConsulTemplateMount{}, "", fmt.Errorf("no consul-template mount configured for %s", vaultPath)
+	}
+
+	if mount.KVVersion != 1 {
+		if rest == "data" {
+			rest = ""
+		}
+		rest = strings.TrimPrefix(rest, "data/")
+	}
+
+	return mount, path.Join("/", mount.SecretPath, rest), nil
+}
+
+// consulTemplateFunctions replaces the secret function with the one of consul-template, and adds the
+// aliases consul-template uses for the common helpers
+func consulTemplateFunctions(consulTemplate *ConsulTemplateConfig, listSecrets func(string, string, string, ...string) ([]models.SingleEnvironmentVariable, error)) template.FuncMap {
+	funcs := withAgentTemplateHelpers(template.FuncMap{})
+
+	// a template often reads the same secret more than once
+	secrets := map[string]*consulTemplateSecret{}
+	funcs["secret"] = func(vaultPath string, args ...string) (*consulTemplateSecret, error) {
+		if len(args) > 0 {
+			return nil, fmt.Errorf("secret %s: only reading KV secrets is supported", vaultPath)
+		}
+		if secret, ok := secrets[vaultPath]; ok {
+			return secret, nil
+		}
+
+		mount, secretPath, err := consulTemplate.resolve(vaultPath)
+		if err != nil {
+			return nil, err
+		}
+
+		environmentVariables, err := listSecrets(mount.ProjectID, mount.Environment, secretPath)
+		if err != nil {
+			return nil, err
+		}
+
+		data := map[string]interface{}{}
+		for _, environmentVariable := range environmentVariables {
+			data[environmentVariable.Key] = environmentVariable.Value
+		}
+
+		secret := &consulTemplateSecret{Data: data}
+		if mount.KVVersion != 1 {
+			secret.Data = map[string]interface{}{"data": data, "metadata": map[string]interface{}{}}
+		}
+		secrets[vaultPath] = secret
+		return secret, nil
+	}
+
+	funcs["env"] = os.Getenv
+	funcs["toJSON"] = templateToJSON
+	funcs["toJSONPretty"] = templateToPrettyJSON
+	funcs["parseJSON"] = templateFromJSON
+	funcs["toYAML"] = templateToYAML
+	funcs["base64Encode"] = templateBase64Encode
+	funcs["base64Decode"] = templateBase64Decode
+	funcs["toUpper"] = strings.ToUpper
+	funcs["toLower"] = strings.ToLower
+	funcs["toTitle"] = templateTitleCase
+	funcs["trimSpace"] = strings.TrimSpace
+	funcs["split"] = func(separator string, value string) []string { return strings.Split(value, separator) }
+	funcs["join"] = func(separator string, values []string) string { return strings.Join(values, separator) }
+	return funcs
+}
+
+// ProcessConsulTemplate renders a template written in consul-template syntax, from its source path or
+// content
+func ProcessConsulTemplate(templateId int, secretTemplate *Template, accessToken string, existingEtag string, currentEtag *string, secretChangeWatcher *SecretChangeWatcher, metrics *agentMetrics) (*bytes.Buffer, error) {
+	var templateString string
+	switch {
+	case secretTemplate.SourcePath != "":
+		content, err := os.ReadFile(secretTemplate.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		templateString = string(content)
+	case secretTemplate.TemplateContent != "":
+		templateString = secretTemplate.TemplateContent
+	default:
+		decoded, err := base64.StdEncoding.DecodeString(secretTemplate.Base64TemplateContent)
+		if err != nil {
+			return nil, err
+		}
+		templateString = string(decoded)
+	}
+
+	listSecrets := secretTemplateFunction(accessToken, existingEtag, currentEtag, secretChangeWatcher, metrics, templateId)
+
+	tmpl, err := template.New("consulTemplate").Funcs(consulTemplateFunctions(secretTemplate.Config.ConsulTemplate, listSecrets)).Parse(templateString)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, nil); err != nil {
+		return nil, err
+	}
+
+	return &buf, nil
+}
@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"text/template"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsulTemplateConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *ConsulTemplateConfig
+		wantErr string
+	}{
+		{name: "no config", config: nil, wantErr: "requires consul-template.mounts"},
+		{name: "no mounts", config: &ConsulTemplateConfig{}, wantErr: "requires consul-template.mounts"},
+		{
+			name:   "valid",
+			config: &ConsulTemplateConfig{Mounts: map[string]ConsulTemplateMount{"secret": {ProjectID: "project", Environment: "prod"}, "kv": {ProjectID: "project", Environment: "prod", KVVersion: 1}}},
+		},
+		{
+			name:    "no environment",
+			config:  &ConsulTemplateConfig{Mounts: map[string]ConsulTemplateMount{"secret": {ProjectID: "project"}}},
+			wantErr: "consul-template mount secret: project-id and environment are required",
+		},
+		{
+			name:    "unknown KV version",
+			config:  &ConsulTemplateConfig{Mounts: map[string]ConsulTemplateMount{"secret": {ProjectID: "project", Environment: "prod", KVVersion: 3}}},
+			wantErr: "consul-template mount secret: kv-version must be 1 or 2",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.config.validate()
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConsulTemplateConfigResolve(t *testing.T) {
+	config := &ConsulTemplateConfig{Mounts: map[string]ConsulTemplateMount{
+		"secret": {ProjectID: "project", Environment: "prod"},
+		"apps":   {ProjectID: "project", Environment: "prod", SecretPath: "/platform", KVVersion: 2},
+		"kv":     {ProjectID: "legacy", Environment: "dev", KVVersion: 1},
+	}}
+
+	tests := []struct {
+		name        string
+		vaultPath   string
+		wantProject string
+		wantPath    string
+		wantErr     bool
+	}{
+		{name: "KV v2 by default", vaultPath: "secret/data/myapp/config", wantProject: "project", wantPath: "/myapp/config"},
+		{name: "KV v2 mount root", vaultPath: "secret/data", wantProject: "project", wantPath: "/"},
+		{name: "KV v2 without data", vaultPath: "secret/myapp", wantProject: "project", wantPath: "/myapp"},
+		{name: "KV v2 surrounding slashes", vaultPath: "/secret/data/myapp/", wantProject: "project", wantPath: "/myapp"},
+		{name: "KV v2 secret path", vaultPath: "apps/data/billing", wantProject: "project", wantPath: "/platform/billing"},
+		{name: "KV v1 keeps data", vaultPath: "kv/data/myapp", wantProject: "legacy", wantPath: "/data/myapp"},
+		{name: "KV v1 mount root", vaultPath: "kv", wantProject: "legacy", wantPath: "/"},
+		{name: "unknown mount", vaultPath: "database/creds/readonly", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mount, secretPath, err := config.resolve(test.vaultPath)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantProject, mount.ProjectID)
+			assert.Equal(t, test.wantPath, secretPath)
+		})
+	}
+}
+
+func TestConsulTemplateFunctions(t *testing.T) {
+	config := &ConsulTemplateConfig{Mounts: map[string]ConsulTemplateMount{
+		"secret": {ProjectID: "project", Environment: "prod"},
+		"kv":     {ProjectID: "legacy", Environment: "dev", KVVersion: 1},
+	}}
+
+	tests := []struct {
+		name      string
+		template  string
+		want      string
+		wantErr   string
+		wantCalls []string
+	}{
+		{
+			name:      "KV v2",
+			template:  `{{ with secret "secret/data/myapp" }}{{ .Data.data.DB_USER }}:{{ .Data.data.DB_PASSWORD }}{{ end }}`,
+			want:      "app:hunter2",
+			wantCalls: []string{"project prod /myapp"},
+		},
+		{
+			name:      "KV v1",
+			template:  `{{ with secret "kv/myapp" }}{{ .Data.DB_USER }}{{ end }}`,
+			want:      "app",
+			wantCalls: []string{"legacy dev /myapp"},
+		},
+		{
+			name:      "secret read once",
+			template:  `{{ with secret "secret/data/myapp" }}{{ .Data.data.DB_USER }}{{ end }} {{ with secret "secret/data/myapp" }}{{ .Data.data.DB_USER | toUpper }}{{ end }}`,
+			want:      "app APP",
+			wantCalls: []string{"project prod /myapp"},
+		},
+		{
+			name:     "consul-template helpers",
+			template: `{{ "a,b" | split "," | join "-" }} {{ "  x " | trimSpace }} {{ "dmFsdWU=" | base64Decode }}`,
+			want:     "a-b x value",
+		},
+		{
+			name:     "dynamic secrets",
+			template: `{{ with secret "secret/creds/readonly" "ttl=1h" }}{{ end }}`,
+			wantErr:  "only reading KV secrets is supported",
+		},
+		{
+			name:     "unknown mount",
+			template: `{{ with secret "database/creds/readonly" }}{{ end }}`,
+			wantErr:  "no consul-template mount configured for database/creds/readonly",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			listSecrets := func(projectID string, environment string, secretPath string, _ ...string) ([]models.SingleEnvironmentVariable, error) {
+				calls = append(calls, fmt.Sprintf("%s %s %s", projectID, environment, secretPath))
+				return []models.SingleEnvironmentVariable{{Key: "DB_USER", Value: "app"}, {Key: "DB_PASSWORD", Value: "hunter2"}}, nil
+			}
+
+			tmpl, err := template.New("test").Funcs(consulTemplateFunctions(config, listSecrets)).Parse(test.template)
+			assert.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = tmpl.Execute(&buf, nil)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, buf.String())
+			assert.Equal(t, test.wantCalls, calls)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	secretEventsMinBackoff = 2 * time.Second
+	secretEventsMaxBackoff = time.Minute
+	// Instances without the event stream are only checked again this often, templates keep polling meanwhile
+	secretEventsUnavailableBackoff = 10 * time.Minute
+)
+
+type secretEventScope struct {
+	environment string
+	secretPath  string
+}
+
+type projectEventSubscription struct {
+	scopes map[secretEventScope]map[int]bool
+	cancel context.CancelFunc
+}
+
+type projectEventPayload struct {
+	Type string `json:"type"`
+	Data struct {
+		Environment string `json:"environment"`
+		SecretPath  string `json:"secretPath"`
+	} `json:"data"`
+}
+
+// SecretChangeWatcher subscribes to the project event stream for every secret path the templates
+// read, and wakes the affected templates as soon as one of them changes. Polling remains in place
+// as a fallback, so templates still refresh when the stream is unavailable or misses an event.
+type SecretChangeWatcher struct {
+	mutex         sync.Mutex
+	getToken      func() string
+	subscriptions map[string]*projectEventSubscription
+	refreshChans  map[int]chan struct{}
+}
+
+func NewSecretChangeWatcher(getToken func() string) *SecretChangeWatcher {
+	return &SecretChangeWatcher{
+		getToken:      getToken,
+		subscriptions: map[string]*projectEventSubscription{},
+		refreshChans:  map[int]chan struct{}{},
+	}
+}
+
+// RefreshChan returns the channel that receives a value whenever secrets used by the template change
+func (w *SecretChangeWatcher) RefreshChan(templateId int) chan struct{} {
+	if w == nil {
+		return nil
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	refreshChan, ok := w.refreshChans[templateId]
+	if !ok {
+		refreshChan = make(chan struct{}, 1)
+		w.refreshChans[templateId] = refreshChan
+	}
+	return refreshChan
+}
+
+// Watch records that the template read secrets from the given path. The project's subscription is
+// (re)started when the path was not watched before.
+func (w *SecretChangeWatcher) Watch(templateId int, projectId, environment, secretPath string) {
+	if w == nil {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	scope := secretEventScope{environment: environment, secretPath: secretPath}
+
+	subscription, ok := w.subscriptions[projectId]
+	if !ok {
+		subscription = &projectEventSubscription{scopes: map[secretEventScope]map[int]bool{}}
+		w.subscriptions[projectId] = subscription
+	}
+
+	templates, ok := subscription.scopes[scope]
+	if !ok {
+		templates = map[int]bool{}
+		subscription.scopes[scope] = templates
+	}
+
+	if templates[templateId] {
+		return
+	}
+	templates[templateId] = true
+
+	if subscription.cancel != nil {
+		subscription.cancel()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	subscription.cancel = cancel
+
+	registrations := []api.ProjectEventRegistration{}
+	for scope := range subscription.scopes {
+		for _, event := range api.SecretProjectEvents {
+			registrations = append(registrations, api.ProjectEventRegistration{
+				Event:      event,
+				Conditions: &api.ProjectEventCondition{EnvironmentSlug: scope.environment, SecretPath: scope.secretPath},
+			})
+		}
+	}
+
+	go w.subscribe(ctx, api.SubscribeProjectEventsRequest{ProjectID: projectId, Register: registrations})
+}
+
+func (w *SecretChangeWatcher) subscribe(ctx context.Context, request api.SubscribeProjectEventsRequest) {
+	backoff := secretEventsMinBackoff
+
+	for ctx.Err() == nil {
+		token := w.getToken()
+		if token == "" {
+			sleepWithContext(ctx, 3*time.Second)
+			continue
+		}
+
+		// the stream stays open indefinitely, so it can't share the default request timeout
+		httpClient := api.NewHTTPClient().SetTimeout(0).SetAuthToken(token)
+
+		stream, err := api.CallSubscribeProjectEventsV1(ctx, httpClient, request)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			if api.IsNotFound(err) || hasStatusCode(err, 405) {
+				log.Info().Msgf("secret change events are not available on this Infisical instance, changes to project %s will be picked up by polling", request.ProjectID)
+				sleepWithContext(ctx, secretEventsUnavailableBackoff)
+				continue
+			}
+
+			log.Warn().Msgf("unable to subscribe to secret changes for project %s because %v. Retrying in %s", request.ProjectID, err, backoff)
+			sleepWithContext(ctx, backoff)
+			backoff = min(backoff*2, secretEventsMaxBackoff)
+			continue
+		}
+
+		log.Debug().Msgf("subscribed to secret changes for project %s", request.ProjectID)
+		backoff = secretEventsMinBackoff
+
+		err = api.ReadServerSentEvents(stream, func(event api.ServerSentEvent) error {
+			w.handleEvent(request.ProjectID, event)
+			return nil
+		})
+		stream.Close()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Debug().Msgf("secret change stream for project %s ended because %v, reconnecting", request.ProjectID, err)
+		sleepWithContext(ctx, backoff)
+	}
+}
+
+func (w *SecretChangeWatcher) handleEvent(projectId string, event api.ServerSentEvent) {
+	var payload projectEventPayload
+	if len(event.Data) > 0 {
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			log.Debug().Msgf("ignoring malformed secret change event: %v", err)
+			return
+		}
+	}
+
+	eventType := payload.Type
+	if eventType == "" {
+		eventType = event.Event
+	}
+	if !strings.HasPrefix(eventType, "secret:") {
+		return
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	subscription, ok := w.subscriptions[projectId]
+	if !ok {
+		return
+	}
+
+	for scope, templates := range subscription.scopes {
+		if !scopeMatchesEvent(scope, payload) {
+			continue
+		}
+
+		for templateId := range templates {
+			log.Debug().Msgf("template %d: secrets changed in %s:%s, refreshing", templateId+1, scope.environment, scope.secretPath)
+			select {
+			case w.refreshChans[templateId] <- struct{}{}:
+			default:
+				// a refresh is already pending
+			}
+		}
+	}
+}
+
+// Events without scope details wake every template of the project. Paths match by prefix since
+// templates may read folders recursively.
+func scopeMatchesEvent(scope secretEventScope, payload projectEventPayload) bool {
+	if payload.Data.Environment != "" && payload.Data.Environment != scope.environment {
+		return false
+	}
+
+	if payload.Data.SecretPath == "" {
+		return true
+	}
+
+	scopePath := strings.TrimSuffix(scope.secretPath, "/")
+	return payload.Data.SecretPath == scope.secretPath || strings.HasPrefix(payload.Data.SecretPath, scopePath+"/")
+}
+
+func hasStatusCode(err error, statusCode int) bool {
+	var apiErr *api.APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == statusCode
+}
+
+func sleepWithContext(ctx context.Context, duration time.Duration) {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,372 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	EXEC_ON_SECRET_CHANGE_RESTART = "restart"
+	EXEC_ON_SECRET_CHANGE_SIGNAL  = "signal"
+	EXEC_ON_SECRET_CHANGE_NONE    = "none"
+
+	EXEC_RESTART_POLICY_NEVER      = "never"
+	EXEC_RESTART_POLICY_ON_FAILURE = "on-failure"
+	EXEC_RESTART_POLICY_ALWAYS     = "always"
+
+	defaultExecKillTimeout = 30 * time.Second
+	execCrashRestartDelay  = time.Second
+)
+
+type ExecConfig struct {
+	Command []string `yaml:"command"`
+	// What to do when a rendered template changes: restart (default), signal or none
+	OnSecretChange string `yaml:"on-secret-change"`
+	// Signal sent to stop the process before a restart (default SIGTERM), or to notify it when
+	// on-secret-change is signal (default SIGHUP)
+	Signal string `yaml:"signal"`
+	// Upper bound of a random delay before acting on a change, so replicas don't restart at once
+	Splay string `yaml:"splay"`
+	// How long to wait for the process to exit after the stop signal before killing it
+	KillTimeout string `yaml:"kill-timeout"`
+	// What to do when the process exits on its own: never (default) stops the agent with the same
+	// exit code, on-failure restarts it after non-zero exits, always restarts it
+	RestartPolicy string `yaml:"restart-policy"`
+}
+
+// execSupervisor runs the exec command once every template has rendered, passes it the variables of
+// templates marked exec-env and restarts or signals it when secrets change.
+type execSupervisor struct {
+	command        []string
+	onSecretChange string
+	signal         os.Signal
+	splay          time.Duration
+	killTimeout    time.Duration
+	restartPolicy  string
+
+	mutex            sync.Mutex
+	pendingTemplates map[int]bool
+	env              map[int][]string
+	ready            chan struct{}
+	changed          chan struct{}
+	exited           chan int
+	shutdown         chan struct{}
+	done             chan struct{}
+	cmd              *exec.Cmd
+}
+
+func newExecSupervisor(execConfig ExecConfig, templateIds []int) (*execSupervisor, error) {
+	if len(execConfig.Command) == 0 {
+		return nil, errors.New("exec.command must contain the program to run")
+	}
+
+	supervisor := &execSupervisor{
+		command:          execConfig.Command,
+		onSecretChange:   execConfig.OnSecretChange,
+		restartPolicy:    execConfig.RestartPolicy,
+		killTimeout:      defaultExecKillTimeout,
+		pendingTemplates: map[int]bool{},
+		env:              map[int][]string{},
+		ready:            make(chan struct{}),
+		changed:          make(chan struct{}, 1),
+		exited:           make(chan int, 1),
+		shutdown:         make(chan struct{}),
+		done:             make(chan struct{}),
+	}
+
+	if supervisor.onSecretChange == "" {
+		supervisor.onSecretChange = EXEC_ON_SECRET_CHANGE_RESTART
+	}
+	if supervisor.onSecretChange != EXEC_ON_SECRET_CHANGE_RESTART && supervisor.onSecretChange != EXEC_ON_SECRET_CHANGE_SIGNAL && supervisor.onSecretChange != EXEC_ON_SECRET_CHANGE_NONE {
+		return nil, fmt.Errorf("exec.on-secret-change must be one of restart, signal or none, got '%s'", supervisor.onSecretChange)
+	}
+
+	if supervisor.restartPolicy == "" {
+		supervisor.restartPolicy = EXEC_RESTART_POLICY_NEVER
+	}
+	if supervisor.restartPolicy != EXEC_RESTART_POLICY_NEVER && supervisor.restartPolicy != EXEC_RESTART_POLICY_ON_FAILURE && supervisor.restartPolicy != EXEC_RESTART_POLICY_ALWAYS {
+		return nil, fmt.Errorf("exec.restart-policy must be one of never, on-failure or always, got '%s'", supervisor.restartPolicy)
+	}
+
+	signalName := execConfig.Signal
+	if signalName == "" {
+		signalName = "SIGTERM"
+		if supervisor.onSecretChange == EXEC_ON_SECRET_CHANGE_SIGNAL {
+			signalName = "SIGHUP"
+		}
+	}
+	signalName = strings.ToUpper(signalName)
+	if !strings.HasPrefix(signalName, "SIG") {
+		signalName = "SIG" + signalName
+	}
+	signal, ok := agentExecSignals[signalName]
+	if !ok {
+		return nil, fmt.Errorf("exec.signal '%s' is not supported on this platform", execConfig.Signal)
+	}
+	supervisor.signal = signal
+
+	if execConfig.Splay != "" {
+		splay, err := time.ParseDuration(execConfig.Splay)
+		if err != nil || splay < 0 {
+			return nil, fmt.Errorf("exec.splay must be a duration such as 10s")
+		}
+		supervisor.splay = splay
+	}
+
+	if execConfig.KillTimeout != "" {
+		killTimeout, err := time.ParseDuration(execConfig.KillTimeout)
+		if err != nil || killTimeout <= 0 {
+			return nil, fmt.Errorf("exec.kill-timeout must be a positive duration such as 30s")
+		}
+		supervisor.killTimeout = killTimeout
+	}
+
+	for _, templateId := range templateIds {
+		supervisor.pendingTemplates[templateId] = true
+	}
+	if len(supervisor.pendingTemplates) == 0 {
+		close(supervisor.ready)
+	}
+
+	return supervisor, nil
+}
+
+// TemplateRendered records a rendered template. The process starts once every template rendered
+// for the first time; later renders count as secret changes.
+func (s *execSupervisor) TemplateRendered(templateId int, content []byte, execEnv bool) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if execEnv {
+		s.env[templateId] = parseDotEnv(content)
+	}
+
+	if s.pendingTemplates[templateId] {
+		delete(s.pendingTemplates, templateId)
+		if len(s.pendingTemplates) == 0 {
+			close(s.ready)
+		}
+		return
+	}
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+		// a change is already pending
+	}
+}
+
+// Run supervises the process until it exits for good, in which case its exit code is sent to
+// exitCodes, or until Shutdown is called.
+func (s *execSupervisor) Run(exitCodes chan<- int) {
+	defer close(s.done)
+
+	select {
+	case <-s.ready:
+	case <-s.shutdown:
+		return
+	}
+
+	if err := s.start(); err != nil {
+		log.Error().Msgf("exec: unable to start %s because %v", s.command[0], err)
+		exitCodes <- 1
+		return
+	}
+
+	for {
+		select {
+		case <-s.shutdown:
+			s.stop()
+			return
+
+		case <-s.changed:
+			if s.onSecretChange == EXEC_ON_SECRET_CHANGE_NONE {
+				continue
+			}
+
+			if s.splay > 0 {
+				delay := time.Duration(rand.Int63n(int64(s.splay)))
+				log.Info().Msgf("exec: secrets changed, waiting %s before applying the change", delay.Round(time.Millisecond))
+				select {
+				case <-time.After(delay):
+				case <-s.shutdown:
+					s.stop()
+					return
+				}
+			}
+
+			if s.onSecretChange == EXEC_ON_SECRET_CHANGE_SIGNAL {
+				log.Info().Msgf("exec: secrets changed, sending %s to process %d", s.signal, s.cmd.Process.Pid)
+				if err := s.cmd.Process.Signal(s.signal); err != nil {
+					log.Error().Msgf("exec: unable to signal process because %v", err)
+				}
+				continue
+			}
+
+			log.Info().Msgf("exec: secrets changed, restarting process %d", s.cmd.Process.Pid)
+			s.stop()
+			if err := s.start(); err != nil {
+				log.Error().Msgf("exec: unable to restart %s because %v", s.command[0], err)
+				exitCodes <- 1
+				return
+			}
+
+		case exitCode := <-s.exited:
+			if s.restartPolicy == EXEC_RESTART_POLICY_NEVER || (s.restartPolicy == EXEC_RESTART_POLICY_ON_FAILURE && exitCode == 0) {
+				log.Info().Msgf("exec: process exited with code %d", exitCode)
+				exitCodes <- exitCode
+				return
+			}
+
+			log.Warn().Msgf("exec: process exited with code %d, restarting in %s", exitCode, execCrashRestartDelay)
+			select {
+			case <-time.After(execCrashRestartDelay):
+			case <-s.shutdown:
+				return
+			}
+
+			if err := s.start(); err != nil {
+				log.Error().Msgf("exec: unable to restart %s because %v", s.command[0], err)
+				exitCodes <- 1
+				return
+			}
+		}
+	}
+}
+
+// Shutdown stops the process and waits until it has exited
+func (s *execSupervisor) Shutdown() {
+	if s == nil {
+		return
+	}
+
+	close(s.shutdown)
+	<-s.done
+}
+
+func (s *execSupervisor) start() error {
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(util.ChildProcessEnvironment(os.Environ()), s.environment()...)
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("exec: started %s with process id %d", s.command[0], cmd.Process.Pid)
+	s.cmd = cmd
+
+	go func() {
+		s.exited <- exitCodeOf(cmd.Wait())
+	}()
+
+	return nil
+}
+
+// stop signals the process and kills it if it is still running after the kill timeout
+func (s *execSupervisor) stop() {
+	if err := s.cmd.Process.Signal(s.stopSignal()); err != nil {
+		log.Debug().Msgf("exec: unable to signal process because %v", err)
+	}
+
+	select {
+	case <-s.exited:
+		return
+	case <-time.After(s.killTimeout):
+	}
+
+	log.Warn().Msgf("exec: process %d did not exit within %s, killing it", s.cmd.Process.Pid, s.killTimeout)
+	if err := s.cmd.Process.Kill(); err != nil {
+		log.Error().Msgf("exec: unable to kill process because %v", err)
+	}
+	<-s.exited
+}
+
+func (s *execSupervisor) stopSignal() os.Signal {
+	// the configured signal only notifies the process in signal mode
+	if s.onSecretChange == EXEC_ON_SECRET_CHANGE_SIGNAL {
+		return syscall.SIGTERM
+	}
+	return s.signal
+}
+
+// environment returns the variables of all exec-env templates, later templates winning
+func (s *execSupervisor) environment() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	templateIds := make([]int, 0, len(s.env))
+	for templateId := range s.env {
+		templateIds = append(templateIds, templateId)
+	}
+	sort.Ints(templateIds)
+
+	env := []string{}
+	for _, templateId := range templateIds {
+		env = append(env, s.env[templateId]...)
+	}
+	return env
+}
+
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
+
+// parseDotEnv reads KEY=VALUE lines as written by the dotenv and dotenv-export formats
+func parseDotEnv(content []byte) []string {
+	env := []string{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '\'' || value[0] == '"') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env = append(env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return env
+}
@@ -0,0 +1,146 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+)
+
+const defaultAgentFilePermissions = os.FileMode(0644)
+
+// FileOwnership controls the mode and owner of files the agent writes. Owner and group accept
+// names or numeric ids.
+type FileOwnership struct {
+	Permissions string `yaml:"permissions"`
+	Owner       string `yaml:"owner"`
+	Group       string `yaml:"group"`
+}
+
+type resolvedFileOwnership struct {
+	mode     os.FileMode
+	modeSet  bool
+	uid, gid int
+}
+
+// renameFile is swapped out in tests to simulate destinations that can't be replaced
+var renameFile = os.Rename
+
+func (f FileOwnership) resolve() (resolvedFileOwnership, error) {
+	resolved := resolvedFileOwnership{mode: defaultAgentFilePermissions, uid: -1, gid: -1}
+
+	if f.Permissions != "" {
+		mode, err := strconv.ParseUint(f.Permissions, 8, 32)
+		if err != nil || mode > 0777 {
+			return resolved, fmt.Errorf("permissions must be an octal file mode such as 0600, got '%s'", f.Permissions)
+		}
+		resolved.mode = os.FileMode(mode)
+		resolved.modeSet = true
+	}
+
+	if (f.Owner != "" || f.Group != "") && runtime.GOOS == "windows" {
+		return resolved, fmt.Errorf("owner and group are not supported on Windows")
+	}
+
+	if f.Owner != "" {
+		uid, err := strconv.Atoi(f.Owner)
+		if err != nil {
+			owner, err := user.Lookup(f.Owner)
+			if err != nil {
+				return resolved, fmt.Errorf("unable to find owner '%s': %w", f.Owner, err)
+			}
+			uid, _ = strconv.Atoi(owner.Uid)
+		}
+		resolved.uid = uid
+	}
+
+	if f.Group != "" {
+		gid, err := strconv.Atoi(f.Group)
+		if err != nil {
+			group, err := user.LookupGroup(f.Group)
+			if err != nil {
+				return resolved, fmt.Errorf("unable to find group '%s': %w", f.Group, err)
+			}
+			gid, _ = strconv.Atoi(group.Gid)
+		}
+		resolved.gid = gid
+	}
+
+	return resolved, nil
+}
+
+// writeFileAtomically writes data next to path and renames it into place, so readers never see a
+// partially written file and the mode and owner are set before the content becomes visible. Without
+// configured permissions an existing file keeps its mode. Files that can't be replaced, such as
+// bind mounts into containers, are written in place instead.
+func writeFileAtomically(path string, data []byte, ownership FileOwnership) error {
+	resolved, err := ownership.resolve()
+	if err != nil {
+		return err
+	}
+
+	if !resolved.modeSet {
+		if info, err := os.Stat(path); err == nil {
+			resolved.mode = info.Mode().Perm()
+		}
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+
+	if _, err := tempFile.Write(data); err != nil {
+		tempFile.Close()
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tempPath, resolved.mode); err != nil {
+		return err
+	}
+
+	if resolved.uid != -1 || resolved.gid != -1 {
+		if err := os.Chown(tempPath, resolved.uid, resolved.gid); err != nil {
+			return fmt.Errorf("unable to change owner of %s: %w", path, err)
+		}
+	}
+
+	err = renameFile(tempPath, path)
+	if errors.Is(err, syscall.EBUSY) {
+		return writeFileInPlace(path, data, resolved)
+	}
+	return err
+}
+
+// writeFileInPlace truncates and rewrites path for destinations that can't be renamed over
+func writeFileInPlace(path string, data []byte, resolved resolvedFileOwnership) error {
+	if err := os.WriteFile(path, data, resolved.mode); err != nil {
+		return err
+	}
+
+	if resolved.modeSet {
+		if err := os.Chmod(path, resolved.mode); err != nil {
+			return err
+		}
+	}
+
+	if resolved.uid != -1 || resolved.gid != -1 {
+		if err := os.Chown(path, resolved.uid, resolved.gid); err != nil {
+			return fmt.Errorf("unable to change owner of %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
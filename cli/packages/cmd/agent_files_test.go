@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomically(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+
+	tests := []struct {
+		name         string
+		existingMode os.FileMode
+		ownership    FileOwnership
+		wantMode     os.FileMode
+		wantErr      string
+	}{
+		{name: "new file", wantMode: defaultAgentFilePermissions},
+		{name: "new file with permissions", ownership: FileOwnership{Permissions: "0600"}, wantMode: 0600},
+		{name: "existing file keeps its mode", existingMode: 0640, wantMode: 0640},
+		{name: "permissions override an existing file", existingMode: 0640, ownership: FileOwnership{Permissions: "0400"}, wantMode: 0400},
+		{name: "invalid permissions", ownership: FileOwnership{Permissions: "rw-r--r--"}, wantErr: "octal file mode"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "secrets.env")
+			if test.existingMode != 0 {
+				assert.NoError(t, os.WriteFile(path, []byte("old"), test.existingMode))
+				assert.NoError(t, os.Chmod(path, test.existingMode))
+			}
+
+			err := writeFileAtomically(path, []byte("new"), test.ownership)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			content, err := os.ReadFile(path)
+			assert.NoError(t, err)
+			assert.Equal(t, "new", string(content))
+
+			info, err := os.Stat(path)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantMode, info.Mode().Perm())
+
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			assert.Len(t, entries, 1, "the temporary file is removed")
+		})
+	}
+}
+
+func TestWriteFileAtomicallyFallsBackOnBusyDestination(t *testing.T) {
+	defer func(previous func(string, string) error) { renameFile = previous }(renameFile)
+	renameFile = func(string, string) error {
+		return &os.LinkError{Op: "rename", Err: syscall.EBUSY}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secrets.env")
+	assert.NoError(t, os.WriteFile(path, []byte("old content"), 0600))
+
+	assert.NoError(t, writeFileAtomically(path, []byte("new"), FileOwnership{}))
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "new", string(content))
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1, "the temporary file is removed")
+}
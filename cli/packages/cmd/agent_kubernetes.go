@@ -0,0 +1,421 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesFieldManager      = "infisical-agent"
+	kubernetesRequestTimeout    = 30 * time.Second
+)
+
+// KubernetesDestination applies a rendered template to a Secret or ConfigMap. By default every
+// KEY=VALUE line of the template becomes a key of the object; set Key to store the whole output
+// under a single key instead, e.g. for a config file.
+type KubernetesDestination struct {
+	Kind        string            `yaml:"kind"` // Secret (default) or ConfigMap
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace"` // defaults to the namespace of the service account or kubeconfig context
+	Type        string            `yaml:"type"`      // Secret type, defaults to Opaque
+	Key         string            `yaml:"key"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+	// Kubeconfig is only needed outside of a cluster. Defaults to $KUBECONFIG, then ~/.kube/config.
+	Kubeconfig string `yaml:"kubeconfig"`
+	Context    string `yaml:"context"`
+}
+
+func (d *KubernetesDestination) validate() error {
+	if d.Name == "" {
+		return errors.New("destination-kubernetes.name is required")
+	}
+
+	switch d.Kind {
+	case "", "Secret", "ConfigMap":
+	default:
+		return fmt.Errorf("destination-kubernetes.kind must be Secret or ConfigMap, got '%s'", d.Kind)
+	}
+
+	if d.Kind == "ConfigMap" && d.Type != "" {
+		return errors.New("destination-kubernetes.type only applies to Secrets")
+	}
+
+	return nil
+}
+
+// manifest builds the object to apply from the rendered template
+func (d *KubernetesDestination) manifest(namespace string, rendered []byte) map[string]interface{} {
+	values := map[string]string{}
+	if d.Key != "" {
+		values[d.Key] = string(rendered)
+	} else {
+		for _, variable := range parseDotEnv(rendered) {
+			key, value, _ := strings.Cut(variable, "=")
+			values[key] = value
+		}
+	}
+
+	return d.object(namespace, values)
+}
+
+// object builds the Secret or ConfigMap holding values, without a namespace when it's empty
+func (d *KubernetesDestination) object(namespace string, values map[string]string) map[string]interface{} {
+	metadata := map[string]interface{}{
+		"name": d.Name,
+	}
+	if namespace != "" {
+		metadata["namespace"] = namespace
+	}
+	if len(d.Labels) > 0 {
+		metadata["labels"] = d.Labels
+	}
+	if len(d.Annotations) > 0 {
+		metadata["annotations"] = d.Annotations
+	}
+
+	if d.Kind == "ConfigMap" {
+		return map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "ConfigMap",
+			"metadata":   metadata,
+			"data":       values,
+		}
+	}
+
+	data := map[string]string{}
+	for key, value := range values {
+		data[key] = base64.StdEncoding.EncodeToString([]byte(value))
+	}
+
+	secretType := d.Type
+	if secretType == "" {
+		secretType = "Opaque"
+	}
+
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+		"type":       secretType,
+		"data":       data,
+	}
+}
+
+type kubernetesClient struct {
+	httpClient       *http.Client
+	server           string
+	token            string
+	tokenFile        string
+	defaultNamespace string
+}
+
+var kubernetesClients sync.Map
+
+// getKubernetesClient reuses one client per kubeconfig and context so connections are kept alive
+// between renders
+func getKubernetesClient(kubeconfigPath, contextName string) (*kubernetesClient, error) {
+	cacheKey := kubeconfigPath + "\x00" + contextName
+	if client, ok := kubernetesClients.Load(cacheKey); ok {
+		return client.(*kubernetesClient), nil
+	}
+
+	var client *kubernetesClient
+	var err error
+	if kubeconfigPath == "" && contextName == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		client, err = newInClusterKubernetesClient()
+	} else {
+		client, err = newKubeconfigKubernetesClient(kubeconfigPath, contextName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kubernetesClients.Store(cacheKey, client)
+	return client, nil
+}
+
+func newInClusterKubernetesClient() (*kubernetesClient, error) {
+	caCert, err := os.ReadFile(filepath.Join(kubernetesServiceAccountDir, "ca.crt"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the service account CA: %w", err)
+	}
+
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caCert) {
+		return nil, errors.New("the service account CA contains no certificates")
+	}
+
+	namespace, err := os.ReadFile(filepath.Join(kubernetesServiceAccountDir, "namespace"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the service account namespace: %w", err)
+	}
+
+	host := net.JoinHostPort(os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"))
+
+	return &kubernetesClient{
+		httpClient:       newKubernetesHTTPClient(&tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12}),
+		server:           "https://" + host,
+		tokenFile:        filepath.Join(kubernetesServiceAccountDir, "token"),
+		defaultNamespace: strings.TrimSpace(string(namespace)),
+	}, nil
+}
+
+type kubeconfigFile struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string `yaml:"name"`
+		Cluster struct {
+			Server                   string `yaml:"server"`
+			CertificateAuthority     string `yaml:"certificate-authority"`
+			CertificateAuthorityData string `yaml:"certificate-authority-data"`
+			InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+		} `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Contexts []struct {
+		Name    string `yaml:"name"`
+		Context struct {
+			Cluster   string `yaml:"cluster"`
+			User      string `yaml:"user"`
+			Namespace string `yaml:"namespace"`
+		} `yaml:"context"`
+	} `yaml:"contexts"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User struct {
+			Token                 string      `yaml:"token"`
+			TokenFile             string      `yaml:"tokenFile"`
+			ClientCertificate     string      `yaml:"client-certificate"`
+			ClientCertificateData string      `yaml:"client-certificate-data"`
+			ClientKey             string      `yaml:"client-key"`
+			ClientKeyData         string      `yaml:"client-key-data"`
+			Exec                  interface{} `yaml:"exec"`
+		} `yaml:"user"`
+	} `yaml:"users"`
+}
+
+func newKubeconfigKubernetesClient(kubeconfigPath, contextName string) (*kubernetesClient, error) {
+	if kubeconfigPath == "" {
+		kubeconfigPath = strings.Split(os.Getenv("KUBECONFIG"), string(os.PathListSeparator))[0]
+	}
+	if kubeconfigPath == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		kubeconfigPath = filepath.Join(homeDir, ".kube", "config")
+	}
+
+	content, err := os.ReadFile(kubeconfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read kubeconfig: %w", err)
+	}
+
+	var kubeconfig kubeconfigFile
+	if err := yaml.Unmarshal(content, &kubeconfig); err != nil {
+		return nil, fmt.Errorf("unable to parse kubeconfig %s: %w", kubeconfigPath, err)
+	}
+
+	if contextName == "" {
+		contextName = kubeconfig.CurrentContext
+	}
+
+	// relative paths in a kubeconfig are relative to the kubeconfig itself
+	resolve := func(path string) string {
+		if path == "" || filepath.IsAbs(path) {
+			return path
+		}
+		return filepath.Join(filepath.Dir(kubeconfigPath), path)
+	}
+
+	client := &kubernetesClient{defaultNamespace: "default"}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	contextFound := false
+	for _, kubeContext := range kubeconfig.Contexts {
+		if kubeContext.Name != contextName {
+			continue
+		}
+		contextFound = true
+
+		if kubeContext.Context.Namespace != "" {
+			client.defaultNamespace = kubeContext.Context.Namespace
+		}
+
+		for _, cluster := range kubeconfig.Clusters {
+			if cluster.Name != kubeContext.Context.Cluster {
+				continue
+			}
+
+			client.server = strings.TrimSuffix(cluster.Cluster.Server, "/")
+			tlsConfig.InsecureSkipVerify = cluster.Cluster.InsecureSkipTLSVerify
+
+			caCert, err := readKubeconfigData(cluster.Cluster.CertificateAuthorityData, resolve(cluster.Cluster.CertificateAuthority))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read cluster CA: %w", err)
+			}
+			if len(caCert) > 0 {
+				rootCAs := x509.NewCertPool()
+				if !rootCAs.AppendCertsFromPEM(caCert) {
+					return nil, errors.New("the cluster CA in the kubeconfig contains no certificates")
+				}
+				tlsConfig.RootCAs = rootCAs
+			}
+		}
+
+		for _, user := range kubeconfig.Users {
+			if user.Name != kubeContext.Context.User {
+				continue
+			}
+
+			client.token = user.User.Token
+			client.tokenFile = resolve(user.User.TokenFile)
+
+			clientCert, err := readKubeconfigData(user.User.ClientCertificateData, resolve(user.User.ClientCertificate))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read client certificate: %w", err)
+			}
+			clientKey, err := readKubeconfigData(user.User.ClientKeyData, resolve(user.User.ClientKey))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read client key: %w", err)
+			}
+			if len(clientCert) > 0 {
+				certificate, err := tls.X509KeyPair(clientCert, clientKey)
+				if err != nil {
+					return nil, fmt.Errorf("unable to load client certificate: %w", err)
+				}
+				tlsConfig.Certificates = []tls.Certificate{certificate}
+			}
+
+			if user.User.Exec != nil && client.token == "" && client.tokenFile == "" && len(clientCert) == 0 {
+				return nil, fmt.Errorf("user '%s' uses an exec credential plugin, which the agent does not support. Use a token or client certificate instead", user.Name)
+			}
+		}
+	}
+
+	if !contextFound {
+		return nil, fmt.Errorf("context '%s' not found in kubeconfig %s", contextName, kubeconfigPath)
+	}
+	if client.server == "" {
+		return nil, fmt.Errorf("no cluster server found for context '%s'", contextName)
+	}
+
+	client.httpClient = newKubernetesHTTPClient(tlsConfig)
+	return client, nil
+}
+
+func readKubeconfigData(inlineData, path string) ([]byte, error) {
+	if inlineData != "" {
+		return base64.StdEncoding.DecodeString(inlineData)
+	}
+	if path != "" {
+		return os.ReadFile(path)
+	}
+	return nil, nil
+}
+
+func newKubernetesHTTPClient(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport, Timeout: kubernetesRequestTimeout}
+}
+
+// apply creates or updates the object with server-side apply, so fields owned by other managers,
+// such as labels added by other tools, are left alone
+func (c *kubernetesClient) apply(ctx context.Context, object map[string]interface{}) error {
+	metadata := object["metadata"].(map[string]interface{})
+	resource := "secrets"
+	if object["kind"] == "ConfigMap" {
+		resource = "configmaps"
+	}
+
+	body, err := json.Marshal(object)
+	if err != nil {
+		return err
+	}
+
+	requestURL := fmt.Sprintf("%s/api/v1/namespaces/%s/%s/%s?fieldManager=%s&force=true",
+		c.server, url.PathEscape(metadata["namespace"].(string)), resource, url.PathEscape(metadata["name"].(string)), kubernetesFieldManager)
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPatch, requestURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/apply-patch+yaml")
+	request.Header.Set("Accept", "application/json")
+
+	token := c.token
+	if c.tokenFile != "" {
+		// service account tokens are rotated on disk, so always use the current one
+		tokenFromFile, err := os.ReadFile(c.tokenFile)
+		if err != nil {
+			return fmt.Errorf("unable to read token: %w", err)
+		}
+		token = strings.TrimSpace(string(tokenFromFile))
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 64*1024))
+		var status struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(responseBody, &status) == nil && status.Message != "" {
+			return fmt.Errorf("kubernetes API responded with %d: %s", response.StatusCode, status.Message)
+		}
+		return fmt.Errorf("kubernetes API responded with %d: %s", response.StatusCode, string(responseBody))
+	}
+
+	return nil
+}
+
+func applyTemplateToKubernetes(rendered []byte, destination *KubernetesDestination) (string, error) {
+	client, err := getKubernetesClient(destination.Kubeconfig, destination.Context)
+	if err != nil {
+		return "", err
+	}
+
+	namespace := destination.Namespace
+	if namespace == "" {
+		namespace = client.defaultNamespace
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), kubernetesRequestTimeout)
+	defer cancel()
+
+	object := destination.manifest(namespace, rendered)
+	if err := client.apply(ctx, object); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %s/%s", object["kind"], namespace, destination.Name), nil
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type kubernetesTestRequest struct {
+	method      string
+	path        string
+	query       string
+	contentType string
+	auth        string
+	body        map[string]interface{}
+}
+
+// newKubernetesTestServer records the apply requests it receives and answers them with status
+func newKubernetesTestServer(t *testing.T, status int, responseBody string) (*httptest.Server, *[]kubernetesTestRequest) {
+	var requests []kubernetesTestRequest
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		request := kubernetesTestRequest{
+			method:      r.Method,
+			path:        r.URL.Path,
+			query:       r.URL.RawQuery,
+			contentType: r.Header.Get("Content-Type"),
+			auth:        r.Header.Get("Authorization"),
+		}
+		assert.NoError(t, json.Unmarshal(content, &request.body))
+		requests = append(requests, request)
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, responseBody)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+// writeTestKubeconfig writes a kubeconfig for server whose user authenticates with user
+func writeTestKubeconfig(t *testing.T, server *httptest.Server, user string) string {
+	caData := base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	kubeconfig := fmt.Sprintf(`
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s/
+    certificate-authority-data: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+    namespace: apps
+users:
+- name: test
+  user:
+%s
+`, server.URL, caData, user)
+
+	path := filepath.Join(t.TempDir(), "config")
+	assert.NoError(t, os.WriteFile(path, []byte(kubeconfig), 0600))
+	return path
+}
+
+func TestKubernetesDestinationManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		destination KubernetesDestination
+		rendered    string
+		wantKind    string
+		wantType    interface{}
+		wantData    map[string]string
+	}{
+		{
+			name:        "secret from dotenv",
+			destination: KubernetesDestination{Name: "app"},
+			rendered:    "DB_USER=app\nDB_PASSWORD=p=ss\n",
+			wantKind:    "Secret",
+			wantType:    "Opaque",
+			wantData: map[string]string{
+				"DB_USER":     base64.StdEncoding.EncodeToString([]byte("app")),
+				"DB_PASSWORD": base64.StdEncoding.EncodeToString([]byte("p=ss")),
+			},
+		},
+		{
+			name:        "secret under one key",
+			destination: KubernetesDestination{Name: "tls", Type: "kubernetes.io/tls", Key: "tls.crt"},
+			rendered:    "-----BEGIN CERTIFICATE-----\n",
+			wantKind:    "Secret",
+			wantType:    "kubernetes.io/tls",
+			wantData:    map[string]string{"tls.crt": base64.StdEncoding.EncodeToString([]byte("-----BEGIN CERTIFICATE-----\n"))},
+		},
+		{
+			name:        "config map",
+			destination: KubernetesDestination{Name: "app", Kind: "ConfigMap"},
+			rendered:    "LOG_LEVEL=debug\n",
+			wantKind:    "ConfigMap",
+			wantData:    map[string]string{"LOG_LEVEL": "debug"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			object := test.destination.manifest("apps", []byte(test.rendered))
+			assert.Equal(t, test.wantKind, object["kind"])
+			assert.Equal(t, test.wantType, object["type"])
+			assert.Equal(t, test.wantData, object["data"])
+			assert.Equal(t, map[string]interface{}{"name": test.destination.Name, "namespace": "apps"}, object["metadata"])
+		})
+	}
+}
+
+func TestKubernetesClientApply(t *testing.T) {
+	server, requests := newKubernetesTestServer(t, http.StatusCreated, "{}")
+	kubeconfig := writeTestKubeconfig(t, server, "    token: static-token")
+
+	client, err := newKubeconfigKubernetesClient(kubeconfig, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "apps", client.defaultNamespace)
+
+	secret := (&KubernetesDestination{Name: "app"}).manifest("apps", []byte("A=1\n"))
+	configMap := (&KubernetesDestination{Name: "app-config", Kind: "ConfigMap"}).manifest("apps", []byte("B=2\n"))
+
+	// the first apply creates the objects and later ones update them, both through the same patch
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, client.apply(context.Background(), secret))
+		assert.NoError(t, client.apply(context.Background(), configMap))
+	}
+
+	if assert.Len(t, *requests, 4) {
+		for i, request := range *requests {
+			wantPath := "/api/v1/namespaces/apps/secrets/app"
+			if i%2 == 1 {
+				wantPath = "/api/v1/namespaces/apps/configmaps/app-config"
+			}
+			assert.Equal(t, http.MethodPatch, request.method)
+			assert.Equal(t, wantPath, request.path)
+			assert.Equal(t, "fieldManager=infisical-agent&force=true", request.query)
+			assert.Equal(t, "application/apply-patch+yaml", request.contentType)
+			assert.Equal(t, "Bearer static-token", request.auth)
+		}
+		assert.Equal(t, map[string]interface{}{"A": base64.StdEncoding.EncodeToString([]byte("1"))}, (*requests)[0].body["data"])
+		assert.Equal(t, map[string]interface{}{"B": "2"}, (*requests)[1].body["data"])
+	}
+}
+
+func TestKubernetesClientRereadsTokenFile(t *testing.T) {
+	server, requests := newKubernetesTestServer(t, http.StatusOK, "{}")
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("first\n"), 0600))
+	kubeconfig := writeTestKubeconfig(t, server, "    tokenFile: "+tokenFile)
+
+	client, err := newKubeconfigKubernetesClient(kubeconfig, "")
+	assert.NoError(t, err)
+
+	secret := (&KubernetesDestination{Name: "app"}).manifest("apps", []byte("A=1\n"))
+	assert.NoError(t, client.apply(context.Background(), secret))
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("rotated\n"), 0600))
+	assert.NoError(t, client.apply(context.Background(), secret))
+
+	if assert.Len(t, *requests, 2) {
+		assert.Equal(t, "Bearer first", (*requests)[0].auth)
+		assert.Equal(t, "Bearer rotated", (*requests)[1].auth)
+	}
+}
+
+func TestKubernetesClientApplyErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		responseBody string
+		wantErr      string
+	}{
+		{
+			name:         "status message",
+			status:       http.StatusForbidden,
+			responseBody: `{"kind":"Status","message":"secrets \"app\" is forbidden: User \"agent\" cannot patch resource"}`,
+			wantErr:      `kubernetes API responded with 403: secrets "app" is forbidden`,
+		},
+		{
+			name:         "not a status",
+			status:       http.StatusBadGateway,
+			responseBody: "upstream unavailable",
+			wantErr:      "kubernetes API responded with 502: upstream unavailable",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server, _ := newKubernetesTestServer(t, test.status, test.responseBody)
+			client, err := newKubeconfigKubernetesClient(writeTestKubeconfig(t, server, "    token: static-token"), "")
+			assert.NoError(t, err)
+
+			err = client.apply(context.Background(), (&KubernetesDestination{Name: "app"}).manifest("apps", []byte("A=1\n")))
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
+
+func TestNewKubeconfigKubernetesClientErrors(t *testing.T) {
+	server, _ := newKubernetesTestServer(t, http.StatusOK, "{}")
+
+	_, err := newKubeconfigKubernetesClient(writeTestKubeconfig(t, server, "    token: static-token"), "missing")
+	assert.ErrorContains(t, err, "context 'missing' not found")
+
+	_, err = newKubeconfigKubernetesClient(writeTestKubeconfig(t, server, "    exec:\n      command: aws"), "")
+	assert.ErrorContains(t, err, "exec credential plugin")
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+)
+
+type MetricsConfig struct {
+	// Address of the local listener serving /metrics in the Prometheus text format, e.g. 127.0.0.1:9099
+	ListenAddress string `yaml:"listen-address"`
+}
+
+var defaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// agentMetrics counts what the agent does. Every method is safe to call on a nil *agentMetrics, which
+// is what the agent uses when metrics aren't enabled.
+type agentMetrics struct {
+	registry             *prometheus.Registry
+	fetchDuration        prometheus.Histogram
+	fetchErrors          *prometheus.CounterVec   // by template and cause
+	renders              *prometheus.CounterVec   // by template and source
+	renderDuration       *prometheus.HistogramVec // by template
+	tokenRenewals        *prometheus.CounterVec   // by type and result
+	tokenRenewalDuration *prometheus.HistogramVec // by type
+	cacheLookups         *prometheus.CounterVec   // by result
+}
+
+func newAgentMetrics() *agentMetrics {
+	m := &agentMetrics{
+		registry: prometheus.NewRegistry(),
+		fetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "infisical_agent_secret_fetch_duration_seconds",
+			Help:    "Latency of requests for secrets made while rendering templates.",
+			Buckets: defaultLatencyBuckets,
+		}),
+		fetchErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "infisical_agent_secret_fetch_errors_total",
+			Help: "Failed requests for secrets by template and cause.",
+		}, []string{"template", "cause"}),
+		renders: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "infisical_agent_template_renders_total",
+			Help: "Rendered templates by template and source of the secrets.",
+		}, []string{"template", "source"}),
+		renderDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "infisical_agent_template_render_duration_seconds",
+			Help:    "Time taken to render templates from Infisical, by template.",
+			Buckets: defaultLatencyBuckets,
+		}, []string{"template"}),
+		tokenRenewals: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "infisical_agent_token_renewals_total",
+			Help: "Logins and token refreshes by result.",
+		}, []string{"type", "result"}),
+		tokenRenewalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "infisical_agent_token_renewal_duration_seconds",
+			Help:    "Latency of logins and token refreshes.",
+			Buckets: defaultLatencyBuckets,
+		}, []string{"type"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "infisical_agent_cache_lookups_total",
+			Help: "Lookups in the persistent cache by result.",
+		}, []string{"result"}),
+	}
+
+	m.registry.MustRegister(m.fetchDuration, m.fetchErrors, m.renders, m.renderDuration, m.tokenRenewals, m.tokenRenewalDuration, m.cacheLookups)
+	return m
+}
+
+// ObserveFetch records one request for secrets made while rendering a template
+func (m *agentMetrics) ObserveFetch(templateId int, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.fetchDuration.Observe(duration.Seconds())
+	if err != nil {
+		m.fetchErrors.WithLabelValues(strconv.Itoa(templateId+1), fetchErrorCause(err)).Inc()
+	}
+}
+
+// fetchErrorCause classifies a failed request for secrets, so alerts can tell an outage of Infisical
+// from a revoked identity or a deleted secret
+func fetchErrorCause(err error) string {
+	var apiError *api.APIError
+	var netError net.Error
+	switch {
+	case errors.Is(err, api.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netError) && netError.Timeout():
+		return "timeout"
+	case errors.As(err, &apiError):
+		switch {
+		case apiError.StatusCode == http.StatusUnauthorized || apiError.StatusCode == http.StatusForbidden:
+			return "unauthorized"
+		case apiError.StatusCode == http.StatusNotFound:
+			return "not_found"
+		case apiError.StatusCode == http.StatusTooManyRequests:
+			return "rate_limited"
+		case apiError.StatusCode >= 500:
+			return "server_error"
+		default:
+			return "client_error"
+		}
+	case errors.As(err, &netError):
+		return "network"
+	default:
+		return "other"
+	}
+}
+
+// ObserveRender records how long rendering a template took, including its requests for secrets
+func (m *agentMetrics) ObserveRender(templateId int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	m.renderDuration.WithLabelValues(strconv.Itoa(templateId + 1)).Observe(duration.Seconds())
+}
+
+func (m *agentMetrics) TemplateRendered(templateId int, fromCache bool) {
+	if m == nil {
+		return
+	}
+
+	source := "api"
+	if fromCache {
+		source = "cache"
+	}
+
+	m.renders.WithLabelValues(strconv.Itoa(templateId+1), source).Inc()
+}
+
+// TokenRenewed records a login ("login") or token refresh ("refresh") and how long it took
+func (m *agentMetrics) TokenRenewed(kind string, duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	m.tokenRenewals.WithLabelValues(kind, result).Inc()
+	m.tokenRenewalDuration.WithLabelValues(kind).Observe(duration.Seconds())
+}
+
+func (m *agentMetrics) CacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+
+	m.cacheLookups.WithLabelValues(result).Inc()
+}
+
+func (m *agentMetrics) Serve(listenAddress string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+
+	server := &http.Server{Addr: listenAddress, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	log.Info().Msgf("metrics listening on %s", listenAddress)
+	if err := server.ListenAndServe(); err != nil {
+		log.Error().Msgf("metrics listener stopped because %v", err)
+	}
+}
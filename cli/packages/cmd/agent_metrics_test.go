@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchErrorCause(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("CallGetRawSecretsV3: %w", api.ErrCircuitOpen), "circuit_open"},
+		{context.DeadlineExceeded, "timeout"},
+		{&net.DNSError{IsTimeout: true}, "timeout"},
+		{&api.APIError{StatusCode: http.StatusUnauthorized}, "unauthorized"},
+		{&api.APIError{StatusCode: http.StatusForbidden}, "unauthorized"},
+		{&api.APIError{StatusCode: http.StatusNotFound}, "not_found"},
+		{&api.APIError{StatusCode: http.StatusTooManyRequests}, "rate_limited"},
+		{&api.APIError{StatusCode: http.StatusBadGateway}, "server_error"},
+		{&api.APIError{StatusCode: http.StatusBadRequest}, "client_error"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "network"},
+		{errors.New("template: unexpected EOF"), "other"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, fetchErrorCause(test.err), test.err.Error())
+	}
+}
+
+func TestAgentMetricsNil(t *testing.T) {
+	var metrics *agentMetrics
+	assert.NotPanics(t, func() {
+		metrics.ObserveFetch(0, time.Second, errors.New("failed"))
+		metrics.ObserveRender(0, time.Second)
+		metrics.TemplateRendered(0, true)
+		metrics.TokenRenewed("login", time.Second, nil)
+		metrics.CacheLookup(false)
+	})
+}
+
+func TestAgentMetricsExposition(t *testing.T) {
+	metrics := newAgentMetrics()
+	metrics.ObserveFetch(0, 20*time.Millisecond, nil)
+	metrics.ObserveFetch(1, 3*time.Second, &api.APIError{StatusCode: http.StatusNotFound})
+	metrics.ObserveRender(0, 40*time.Millisecond)
+	metrics.TemplateRendered(0, false)
+	metrics.TemplateRendered(0, true)
+	metrics.TokenRenewed("refresh", 200*time.Millisecond, nil)
+	metrics.TokenRenewed("login", time.Second, errors.New("invalid credentials"))
+	metrics.CacheLookup(true)
+
+	server := httptest.NewServer(promhttp.HandlerFor(metrics.registry, promhttp.HandlerOpts{}))
+	defer server.Close()
+
+	response, err := http.Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+
+	for _, line := range []string{
+		`infisical_agent_secret_fetch_duration_seconds_bucket{le="0.025"} 1`,
+		`infisical_agent_secret_fetch_duration_seconds_bucket{le="5"} 2`,
+		`infisical_agent_secret_fetch_duration_seconds_count 2`,
+		`infisical_agent_secret_fetch_errors_total{cause="not_found",template="2"} 1`,
+		`infisical_agent_template_render_duration_seconds_bucket{template="1",le="0.05"} 1`,
+		`infisical_agent_template_renders_total{source="api",template="1"} 1`,
+		`infisical_agent_template_renders_total{source="cache",template="1"} 1`,
+		`infisical_agent_token_renewals_total{result="failure",type="login"} 1`,
+		`infisical_agent_token_renewals_total{result="success",type="refresh"} 1`,
+		`infisical_agent_token_renewal_duration_seconds_count{type="login"} 1`,
+		`infisical_agent_cache_lookups_total{result="hit"} 1`,
+	} {
+		assert.Contains(t, string(body), line)
+	}
+}
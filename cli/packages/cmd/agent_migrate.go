@@ -0,0 +1,478 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	MIGRATE_FROM_VAULT_AGENT = "vault-agent"
+
+	migratePlaceholderIdentityID  = "<identity-id>"
+	migratePlaceholderProjectID   = "<project-id>"
+	migratePlaceholderEnvironment = "<environment>"
+)
+
+var agentMigrateCmd = &cobra.Command{
+	Example: `infisical agent migrate --from vault-agent vault-agent.hcl > agent-config.yaml
+infisical agent migrate --from vault-agent vault-agent.hcl --output-file agent-config.yaml`,
+	Short: "Convert the config of another agent into an Infisical agent config",
+	Long: `Convert the config of a Vault Agent into an Infisical agent config: auto_auth becomes auth and sinks, and
+templates keep their consul-template syntax, with their Vault mounts mapped onto Infisical projects.
+
+Everything that can't be converted, and every value that must be filled in such as the ID of the machine
+identity, is reported on stderr. Values to fill in are written as <placeholders>.`,
+	Use:                   "migrate [config-file]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		from, err := cmd.Flags().GetString("from")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if from != MIGRATE_FROM_VAULT_AGENT {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("unsupported --from %s, expected %s", from, MIGRATE_FROM_VAULT_AGENT))
+		}
+
+		output, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to read the Vault Agent config")
+		}
+
+		agentConfig, notes, err := migrateVaultAgentConfig(content)
+		if err != nil {
+			util.HandleError(err, "Unable to convert the Vault Agent config")
+		}
+
+		agentConfigYaml, err := yaml.Marshal(agentConfig)
+		if err != nil {
+			util.HandleError(err, "Unable to write the agent config")
+		}
+
+		if output == "" {
+			fmt.Print(string(agentConfigYaml))
+		} else if err := os.WriteFile(output, agentConfigYaml, 0600); err != nil {
+			util.HandleError(err, "Unable to write the agent config")
+		}
+
+		for _, note := range notes {
+			util.PrintWarning(note)
+		}
+		if output != "" {
+			util.PrintSuccessMessage(fmt.Sprintf("Wrote the agent config to %s with %d item(s) to review", output, len(notes)))
+		}
+
+		Telemetry.CaptureEvent("cli-command:agent migrate", posthog.NewProperties().Set("from", from).Set("notes", len(notes)).Set("version", util.CLI_VERSION))
+	},
+}
+
+type vaultAgentAuthMethod struct {
+	Type      string                 `hcl:"type"`
+	MountPath string                 `hcl:"mount_path"`
+	Config    map[string]interface{} `hcl:"config"`
+}
+
+type vaultAgentSink struct {
+	Type   string                 `hcl:"type"`
+	Config map[string]interface{} `hcl:"config"`
+}
+
+type vaultAgentTemplate struct {
+	Source      string      `hcl:"source"`
+	Contents    string      `hcl:"contents"`
+	Destination string      `hcl:"destination"`
+	Command     string      `hcl:"command"`
+	Perms       interface{} `hcl:"perms"`
+}
+
+type vaultAgentTemplateExec struct {
+	Command interface{} `hcl:"command"`
+	Timeout string      `hcl:"timeout"`
+}
+
+// vaultAgentSecretPath matches the paths read with {{ secret "mount/path" }} in a consul-template file
+var vaultAgentSecretPath = regexp.MustCompile(`secret\s+"([^"]+)"(\s+"[^"]*")*`)
+
+// vaultAgentUnsupportedFunctions are the consul-template functions that read from Consul or Vault engines
+// other than KV
+var vaultAgentUnsupportedFunctions = regexp.MustCompile(`(?:\{\{-?|\(|\|)\s*(?:with\s+|range\s+)?(pkiCert|key|keyOrDefault|keyExists|ls|safeLs|tree|safeTree|service|services|node|nodes|connect|caLeaf|caRoots|writeToFile)\s`)
+
+// migrateVaultAgentConfig converts a Vault Agent config into an Infisical agent config, and returns what
+// must be reviewed by hand
+func migrateVaultAgentConfig(content []byte) (yaml.MapSlice, []string, error) {
+	file, err := hcl.ParseBytes(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, ok := file.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, nil, fmt.Errorf("unexpected Vault Agent config")
+	}
+
+	var notes []string
+	note := func(node ast.Node, format string, args ...interface{}) {
+		notes = append(notes, fmt.Sprintf("line %d: ", node.Pos().Line)+fmt.Sprintf(format, args...))
+	}
+
+	infisicalConfig := yaml.MapSlice{{Key: "address", Value: DEFAULT_INFISICAL_CLOUD_URL}}
+	agentConfig := yaml.MapSlice{}
+	var sinks []yaml.MapSlice
+	var templates []yaml.MapSlice
+	pollingInterval := ""
+
+	for _, item := range root.Filter("template_config").Items {
+		var templateConfig struct {
+			StaticSecretRenderInterval string `hcl:"static_secret_render_interval"`
+		}
+		if err := hcl.DecodeObject(&templateConfig, item.Val); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", item.Pos().Line, err)
+		}
+		if templateConfig.StaticSecretRenderInterval != "" {
+			pollingInterval = migratePollingInterval(templateConfig.StaticSecretRenderInterval)
+			if pollingInterval == "" {
+				note(item, "invalid static_secret_render_interval %s, templates use the default polling interval", templateConfig.StaticSecretRenderInterval)
+			}
+		}
+		noteUnsupportedKeys(item, note, "static_secret_render_interval")
+	}
+
+	for _, item := range root.Items {
+		key := item.Keys[0].Token.Value().(string)
+		switch key {
+		case "vault":
+			note(item, "set infisical.address to your Infisical instance if it is self-hosted, the vault block was dropped")
+		case "exit_after_auth":
+			var exitAfterAuth bool
+			if err := hcl.DecodeObject(&exitAfterAuth, item.Val); err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", item.Pos().Line, err)
+			}
+			infisicalConfig = append(infisicalConfig, yaml.MapItem{Key: "exit-after-auth", Value: exitAfterAuth})
+		case "pid_file":
+			note(item, "pid_file has no equivalent and was dropped")
+		case "template_config":
+		case "auto_auth":
+			autoAuth, ok := item.Val.(*ast.ObjectType)
+			if !ok {
+				return nil, nil, fmt.Errorf("line %d: auto_auth must be a block", item.Pos().Line)
+			}
+
+			for _, methodItem := range autoAuth.List.Filter("method").Items {
+				auth, err := migrateVaultAgentAuthMethod(methodItem, note)
+				if err != nil {
+					return nil, nil, err
+				}
+				if auth != nil {
+					agentConfig = append(agentConfig, yaml.MapItem{Key: "auth", Value: auth})
+				}
+			}
+
+			for _, sinkItem := range autoAuth.List.Filter("sink").Items {
+				sink, err := migrateVaultAgentSink(sinkItem, note)
+				if err != nil {
+					return nil, nil, err
+				}
+				if sink != nil {
+					sinks = append(sinks, sink)
+				}
+			}
+
+			noteUnsupportedKeys(item, note, "method", "sink")
+		case "template":
+			template, err := migrateVaultAgentTemplate(item, pollingInterval, note)
+			if err != nil {
+				return nil, nil, err
+			}
+			templates = append(templates, template)
+		default:
+			note(item, "%s is not supported and was dropped", key)
+		}
+	}
+
+	agentConfig = append(yaml.MapSlice{{Key: "infisical", Value: infisicalConfig}}, agentConfig...)
+	if len(sinks) > 0 {
+		agentConfig = append(agentConfig, yaml.MapItem{Key: "sinks", Value: sinks})
+	}
+	if len(templates) > 0 {
+		agentConfig = append(agentConfig, yaml.MapItem{Key: "templates", Value: templates})
+	}
+
+	return agentConfig, notes, nil
+}
+
+func migrateVaultAgentAuthMethod(item *ast.ObjectItem, note func(ast.Node, string, ...interface{})) (yaml.MapSlice, error) {
+	var method vaultAgentAuthMethod
+	if err := hcl.DecodeObject(&method, item.Val); err != nil {
+		return nil, fmt.Errorf("line %d: %w", item.Pos().Line, err)
+	}
+	if len(item.Keys) > 0 {
+		method.Type = item.Keys[0].Token.Value().(string)
+	}
+
+	config := yaml.MapSlice{}
+	set := func(key string, value interface{}) {
+		if value != nil && value != "" {
+			config = append(config, yaml.MapItem{Key: key, Value: value})
+		}
+	}
+	needsIdentity := func() {
+		set("identity-id", migratePlaceholderIdentityID)
+		if role, ok := method.Config["role"]; ok {
+			note(item, "set auth.config.identity-id to the ID of the machine identity that replaces the Vault role %v", role)
+		} else {
+			note(item, "set auth.config.identity-id to the ID of the machine identity to authenticate as")
+		}
+	}
+
+	var authType util.AuthStrategyType
+	switch method.Type {
+	case "approle":
+		authType = util.AuthStrategy.UNIVERSAL_AUTH
+		set("client-id", method.Config["role_id_file_path"])
+		set("client-secret", method.Config["secret_id_file_path"])
+		// Vault removes the secret ID file after reading it unless told otherwise
+		removeSecret, ok := method.Config["remove_secret_id_file_after_reading"].(bool)
+		set("remove_client_secret_on_read", !ok || removeSecret)
+		note(item, "write the client ID and client secret of a universal auth identity to the files of auth.config, in place of the AppRole role ID and secret ID")
+	case "kubernetes":
+		authType = util.AuthStrategy.KUBERNETES_AUTH
+		needsIdentity()
+		set("service-account-token", method.Config["token_path"])
+	case "aws":
+		authType = util.AuthStrategy.AWS_IAM_AUTH
+		needsIdentity()
+		set("region", method.Config["region"])
+		if method.Config["type"] == "ec2" {
+			note(item, "the AWS ec2 login type is replaced by AWS IAM auth")
+		}
+	case "azure":
+		authType = util.AuthStrategy.AZURE_AUTH
+		needsIdentity()
+		set("resource", method.Config["resource"])
+	case "gcp":
+		authType = util.AuthStrategy.GCP_ID_TOKEN_AUTH
+		if method.Config["type"] == "iam" {
+			authType = util.AuthStrategy.GCP_IAM_AUTH
+		}
+		needsIdentity()
+		if authType == util.AuthStrategy.GCP_IAM_AUTH {
+			set("service-account-key", method.Config["credentials"])
+		}
+	default:
+		note(item, "the %s auth method has no equivalent, configure the auth section by hand", method.Type)
+		return nil, nil
+	}
+
+	return yaml.MapSlice{{Key: "type", Value: string(authType)}, {Key: "config", Value: config}}, nil
+}
+
+func migrateVaultAgentSink(item *ast.ObjectItem, note func(ast.Node, string, ...interface{})) (yaml.MapSlice, error) {
+	var sink vaultAgentSink
+	if err := hcl.DecodeObject(&sink, item.Val); err != nil {
+		return nil, fmt.Errorf("line %d: %w", item.Pos().Line, err)
+	}
+	if len(item.Keys) > 0 {
+		sink.Type = item.Keys[0].Token.Value().(string)
+	}
+
+	if sink.Type != "file" {
+		note(item, "the %s sink has no equivalent and was dropped", sink.Type)
+		return nil, nil
+	}
+	noteUnsupportedKeys(item, note, "type", "config")
+
+	config := yaml.MapSlice{{Key: "path", Value: sink.Config["path"]}}
+	if mode, ok := sink.Config["mode"].(int); ok {
+		config = append(config, yaml.MapItem{Key: "permissions", Value: fmt.Sprintf("%04o", mode)})
+	}
+	return yaml.MapSlice{{Key: "type", Value: "file"}, {Key: "config", Value: config}}, nil
+}
+
+func migrateVaultAgentTemplate(item *ast.ObjectItem, pollingInterval string, note func(ast.Node, string, ...interface{})) (yaml.MapSlice, error) {
+	object, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return nil, fmt.Errorf("line %d: template must be a block", item.Pos().Line)
+	}
+
+	var vaultTemplate vaultAgentTemplate
+	if err := hcl.DecodeObject(&vaultTemplate, item.Val); err != nil {
+		return nil, fmt.Errorf("line %d: %w", item.Pos().Line, err)
+	}
+	noteUnsupportedKeys(item, note, "source", "contents", "destination", "command", "perms", "exec", "create_dest_dirs", "error_on_missing_key")
+
+	template := yaml.MapSlice{}
+	templateContent := vaultTemplate.Contents
+	if vaultTemplate.Source != "" {
+		template = append(template, yaml.MapItem{Key: "source-path", Value: vaultTemplate.Source})
+		content, err := os.ReadFile(vaultTemplate.Source)
+		if err != nil {
+			note(item, "unable to read %s to find the Vault mounts it uses, add them to consul-template.mounts by hand", vaultTemplate.Source)
+		}
+		templateContent = string(content)
+	} else {
+		template = append(template, yaml.MapItem{Key: "template-content", Value: vaultTemplate.Contents})
+	}
+	template = append(template, yaml.MapItem{Key: "destination-path", Value: vaultTemplate.Destination})
+
+	config := yaml.MapSlice{}
+	if pollingInterval != "" {
+		config = append(config, yaml.MapItem{Key: "polling-interval", Value: pollingInterval})
+	}
+
+	command := vaultTemplate.Command
+	timeout := 0
+	for _, execItem := range object.List.Filter("exec").Items {
+		var exec vaultAgentTemplateExec
+		if err := hcl.DecodeObject(&exec, execItem.Val); err != nil {
+			return nil, fmt.Errorf("line %d: %w", execItem.Pos().Line, err)
+		}
+		switch value := exec.Command.(type) {
+		case string:
+			command = value
+		case []interface{}:
+			var arguments []string
+			for _, argument := range value {
+				arguments = append(arguments, fmt.Sprint(argument))
+			}
+			command = strings.Join(arguments, " ")
+		}
+		if duration, err := time.ParseDuration(exec.Timeout); err == nil {
+			timeout = int(duration.Seconds())
+		}
+	}
+	if command != "" {
+		execute := yaml.MapSlice{{Key: "command", Value: command}}
+		if timeout > 0 {
+			execute = append(execute, yaml.MapItem{Key: "timeout", Value: timeout})
+		}
+		config = append(config, yaml.MapItem{Key: "execute", Value: execute})
+	}
+
+	switch perms := vaultTemplate.Perms.(type) {
+	case string:
+		config = append(config, yaml.MapItem{Key: "permissions", Value: perms})
+	case int:
+		config = append(config, yaml.MapItem{Key: "permissions", Value: fmt.Sprintf("%04o", perms)})
+	}
+
+	config = append(config, yaml.MapItem{Key: "syntax", Value: TEMPLATE_SYNTAX_CONSUL_TEMPLATE})
+
+	vaultMounts := vaultAgentTemplateMounts(templateContent, item, note)
+	if len(vaultMounts) == 0 {
+		vaultMounts = []vaultAgentMount{{name: "secret", kvVersion: 2}}
+	}
+
+	mounts := yaml.MapSlice{}
+	for _, mount := range vaultMounts {
+		mountConfig := yaml.MapSlice{
+			{Key: "project-id", Value: migratePlaceholderProjectID},
+			{Key: "environment", Value: migratePlaceholderEnvironment},
+			{Key: "secret-path", Value: "/"},
+		}
+		if mount.kvVersion == 1 {
+			mountConfig = append(mountConfig, yaml.MapItem{Key: "kv-version", Value: 1})
+		}
+		mounts = append(mounts, yaml.MapItem{Key: mount.name, Value: mountConfig})
+		note(item, "set the project-id, environment and secret-path the Vault mount %s maps to in consul-template.mounts", mount.name)
+	}
+	config = append(config, yaml.MapItem{Key: "consul-template", Value: yaml.MapSlice{{Key: "mounts", Value: mounts}}})
+
+	return append(template, yaml.MapItem{Key: "config", Value: config}), nil
+}
+
+type vaultAgentMount struct {
+	name      string
+	kvVersion int
+}
+
+// vaultAgentTemplateMounts returns the Vault mounts a template reads from, guessing their KV version
+// from the data/ segment of KV version 2 paths
+func vaultAgentTemplateMounts(content string, item *ast.ObjectItem, note func(ast.Node, string, ...interface{})) []vaultAgentMount {
+	kvVersions := map[string]int{}
+	for _, match := range vaultAgentSecretPath.FindAllStringSubmatch(content, -1) {
+		if match[2] != "" {
+			note(item, "secret %s is called with parameters, only reading KV secrets is supported", match[1])
+			continue
+		}
+
+		mount, rest, _ := strings.Cut(strings.Trim(match[1], "/"), "/")
+		if rest == "data" || strings.HasPrefix(rest, "data/") {
+			kvVersions[mount] = 2
+		} else if kvVersions[mount] == 0 {
+			kvVersions[mount] = 1
+		}
+	}
+
+	for _, match := range vaultAgentUnsupportedFunctions.FindAllStringSubmatch(content, -1) {
+		note(item, "the consul-template function %s is not supported", match[1])
+	}
+
+	var mounts []vaultAgentMount
+	for name, kvVersion := range kvVersions {
+		mounts = append(mounts, vaultAgentMount{name: name, kvVersion: kvVersion})
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].name < mounts[j].name })
+	return mounts
+}
+
+// migratePollingInterval converts a Vault duration into a polling interval of the agent, which is at
+// least a minute
+func migratePollingInterval(interval string) string {
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return ""
+	}
+	if duration < time.Minute {
+		return "60s"
+	}
+	if duration%time.Minute == 0 {
+		return fmt.Sprintf("%dm", int(duration.Minutes()))
+	}
+	return fmt.Sprintf("%ds", int(duration.Seconds()))
+}
+
+// noteUnsupportedKeys reports the keys of a block that aren't converted
+func noteUnsupportedKeys(item *ast.ObjectItem, note func(ast.Node, string, ...interface{}), supported ...string) {
+	object, ok := item.Val.(*ast.ObjectType)
+	if !ok {
+		return
+	}
+
+	for _, child := range object.List.Items {
+		key := child.Keys[0].Token.Value().(string)
+		isSupported := false
+		for _, supportedKey := range supported {
+			if key == supportedKey {
+				isSupported = true
+				break
+			}
+		}
+		if !isSupported {
+			note(child, "%s is not supported and was dropped", key)
+		}
+	}
+}
+
+func init() {
+	agentMigrateCmd.Flags().String("from", MIGRATE_FROM_VAULT_AGENT, "the agent the config is written for. Only vault-agent is supported")
+	agentMigrateCmd.Flags().String("output-file", "", "write the agent config to this file instead of stdout")
+	agentCmd.AddCommand(agentMigrateCmd)
+}
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func TestMigrateVaultAgentConfig(t *testing.T) {
+	vaultAgentConfig := `
+pid_file = "./pidfile"
+auto_auth {
+  method "approle" {
+    config = {
+      role_id_file_path = "/etc/vault/role-id"
+      secret_id_file_path = "/etc/vault/secret-id"
+    }
+  }
+  sink "file" {
+    config = { path = "/tmp/token" }
+  }
+}
+template_config { static_secret_render_interval = "5m" }
+template {
+  contents = "{{ with secret \"kv/data/app\" }}{{ .Data.data.password }}{{ end }}"
+  destination = "/etc/app/password"
+  perms = "0600"
+}
+cache {}
+`
+
+	agentConfigYaml, notes, err := migrateVaultAgentConfig([]byte(vaultAgentConfig))
+	assert.NoError(t, err)
+
+	content, err := yaml.Marshal(agentConfigYaml)
+	assert.NoError(t, err)
+
+	agentConfig := Config{}
+	assert.NoError(t, yaml.Unmarshal(content, &agentConfig))
+
+	assert.Equal(t, "universal-auth", agentConfig.Auth.Type)
+	assert.Equal(t, "/tmp/token", agentConfig.Sinks[0].Config.Path)
+
+	template := agentConfig.Templates[0]
+	assert.Equal(t, "/etc/app/password", template.DestinationPath)
+	assert.Equal(t, "5m", template.Config.PollingInterval)
+	assert.Equal(t, "0600", template.Config.Permissions)
+	assert.Equal(t, TEMPLATE_SYNTAX_CONSUL_TEMPLATE, template.Config.Syntax)
+	assert.Contains(t, template.Config.ConsulTemplate.Mounts, "kv")
+	assert.Equal(t, 0, template.Config.ConsulTemplate.Mounts["kv"].KVVersion)
+
+	assert.Contains(t, notes, "line 2: pid_file has no equivalent and was dropped")
+	assert.Contains(t, notes, "line 20: cache is not supported and was dropped")
+}
+
+func TestMigratePollingInterval(t *testing.T) {
+	assert.Equal(t, "60s", migratePollingInterval("30s"))
+	assert.Equal(t, "5m", migratePollingInterval("300s"))
+	assert.Equal(t, "90s", migratePollingInterval("1m30s"))
+	assert.Equal(t, "", migratePollingInterval("soon"))
+}
@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultCertificateRenewAtFraction = 2.0 / 3.0
+	certificateIssueRetryDelay        = 30 * time.Second
+)
+
+// CertificateConfig requests a certificate from Infisical PKI and renews it before it expires
+type CertificateConfig struct {
+	CaID                  string   `yaml:"ca-id"`
+	CertificateTemplateID string   `yaml:"certificate-template-id"`
+	CommonName            string   `yaml:"common-name"`
+	AltNames              []string `yaml:"alt-names"`
+	TTL                   string   `yaml:"ttl"` // lifetime requested from Infisical, e.g. 30d
+	KeyUsages             []string `yaml:"key-usages"`
+	ExtendedKeyUsages     []string `yaml:"extended-key-usages"`
+	// Renew once this fraction of the certificate's lifetime has passed, defaults to 2/3
+	RenewAtFraction float64 `yaml:"renew-at-fraction"`
+
+	Destination struct {
+		Certificate   string           `yaml:"certificate"`
+		PrivateKey    string           `yaml:"private-key"`
+		Chain         string           `yaml:"chain"` // optional, the issuing CA chain
+		FileOwnership `yaml:",inline"` // the private key is always 0600
+	} `yaml:"destination"`
+
+	ReloadCommand string `yaml:"reload-command"` // run after every renewal, e.g. nginx -s reload
+	ReloadTimeout int64  `yaml:"reload-timeout"`
+}
+
+func (c *CertificateConfig) validate() error {
+	if c.CaID == "" && c.CertificateTemplateID == "" {
+		return errors.New("ca-id or certificate-template-id is required")
+	}
+	if c.CommonName == "" {
+		return errors.New("common-name is required")
+	}
+	if c.TTL == "" {
+		return errors.New("ttl is required")
+	}
+	if c.RenewAtFraction < 0 || c.RenewAtFraction >= 1 {
+		return errors.New("renew-at-fraction must be between 0 and 1")
+	}
+	if c.Destination.Certificate == "" || c.Destination.PrivateKey == "" {
+		return errors.New("destination.certificate and destination.private-key are required")
+	}
+	if _, err := c.Destination.FileOwnership.resolve(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renewalTime returns when a certificate should be renewed
+func (c *CertificateConfig) renewalTime(certificate *x509.Certificate) time.Time {
+	fraction := c.RenewAtFraction
+	if fraction == 0 {
+		fraction = defaultCertificateRenewAtFraction
+	}
+
+	lifetime := certificate.NotAfter.Sub(certificate.NotBefore)
+	return certificate.NotBefore.Add(time.Duration(float64(lifetime) * fraction))
+}
+
+// ManageCertificate keeps the certificate at the destination valid, reusing one from a previous run
+// until it is due for renewal
+func (tm *AgentManager) ManageCertificate(certificateConfig CertificateConfig, certificateId int) {
+	var renewAt time.Time
+
+	if certificate, err := readPEMCertificate(certificateConfig.Destination.Certificate); err == nil && FileExists(certificateConfig.Destination.PrivateKey) && certificate.Subject.CommonName == certificateConfig.CommonName {
+		renewAt = certificateConfig.renewalTime(certificate)
+		if time.Now().Before(renewAt) {
+			log.Info().Msgf("certificate %d: reusing the certificate at %s until %s", certificateId+1, certificateConfig.Destination.Certificate, renewAt.Format(time.RFC3339))
+		}
+	}
+
+	for {
+		if wait := time.Until(renewAt); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		token := tm.GetToken()
+		if token == "" {
+			time.Sleep(3 * time.Second)
+			continue
+		}
+
+		certificate, err := tm.issueCertificate(certificateConfig, token)
+		if err != nil {
+			log.Error().Msgf("certificate %d: unable to issue certificate because %v. Will retry in %s", certificateId+1, err, certificateIssueRetryDelay)
+			time.Sleep(certificateIssueRetryDelay)
+			continue
+		}
+
+		renewAt = certificateConfig.renewalTime(certificate)
+		log.Info().Msgf("certificate %d: issued certificate %s for %s, valid until %s and renewing at %s", certificateId+1, certificate.SerialNumber.Text(16), certificateConfig.CommonName, certificate.NotAfter.Format(time.RFC3339), renewAt.Format(time.RFC3339))
+
+		if certificateConfig.ReloadCommand != "" {
+			log.Info().Msgf("executing command: %s", certificateConfig.ReloadCommand)
+			if err := ExecuteCommandWithTimeout(certificateConfig.ReloadCommand, certificateConfig.ReloadTimeout); err != nil {
+				log.Error().Msgf("unable to execute command because %v", err)
+			}
+		}
+	}
+}
+
+func (tm *AgentManager) issueCertificate(certificateConfig CertificateConfig, token string) (*x509.Certificate, error) {
+	httpClient := api.NewHTTPClient()
+	httpClient.SetAuthToken(token)
+
+	response, err := api.CallIssueCertificateV1(httpClient, api.IssueCertificateV1Request{
+		CaID:                  certificateConfig.CaID,
+		CertificateTemplateID: certificateConfig.CertificateTemplateID,
+		CommonName:            certificateConfig.CommonName,
+		AltNames:              strings.Join(certificateConfig.AltNames, ","),
+		TTL:                   certificateConfig.TTL,
+		KeyUsages:             certificateConfig.KeyUsages,
+		ExtendedKeyUsages:     certificateConfig.ExtendedKeyUsages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	certificate, err := parsePEMCertificate([]byte(response.Certificate))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse the issued certificate: %w", err)
+	}
+
+	// the key is only ever readable by its owner, as with infisical pki issue. The owner and group
+	// still apply so the agent can hand it to the service using it.
+	keyOwnership := certificateConfig.Destination.FileOwnership
+	keyOwnership.Permissions = "0600"
+
+	// readers should reload after the reload command runs, files are replaced one at a time
+	if err := writeFileAtomically(certificateConfig.Destination.PrivateKey, []byte(response.PrivateKey), keyOwnership); err != nil {
+		return nil, fmt.Errorf("unable to write private key: %w", err)
+	}
+	if err := writeFileAtomically(certificateConfig.Destination.Certificate, []byte(response.Certificate), certificateConfig.Destination.FileOwnership); err != nil {
+		return nil, fmt.Errorf("unable to write certificate: %w", err)
+	}
+	if certificateConfig.Destination.Chain != "" {
+		if err := writeFileAtomically(certificateConfig.Destination.Chain, []byte(response.CertificateChain), certificateConfig.Destination.FileOwnership); err != nil {
+			return nil, fmt.Errorf("unable to write certificate chain: %w", err)
+		}
+	}
+
+	return certificate, nil
+}
+
+func readPEMCertificate(path string) (*x509.Certificate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parsePEMCertificate(content)
+}
+
+func parsePEMCertificate(content []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
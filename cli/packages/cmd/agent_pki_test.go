@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueCertificateWritesKeyWithOwnerOnlyMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not enforced on Windows")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app.internal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificateDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/pki/certificates/issue-certificate", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.IssueCertificateV1Response{
+			Certificate:      string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER})),
+			CertificateChain: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificateDER})),
+			PrivateKey:       string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey})),
+		})
+	}))
+	defer server.Close()
+
+	previousURL := config.INFISICAL_URL
+	defer func() { config.INFISICAL_URL = previousURL }()
+	config.INFISICAL_URL = server.URL
+
+	tests := []struct {
+		name            string
+		permissions     string
+		wantCertificate os.FileMode
+	}{
+		{name: "default permissions", wantCertificate: defaultAgentFilePermissions},
+		{name: "permissions wider than the key allows", permissions: "0644", wantCertificate: 0644},
+		{name: "group readable", permissions: "0640", wantCertificate: 0640},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var certificateConfig CertificateConfig
+			certificateConfig.CommonName = "app.internal"
+			certificateConfig.Destination.Certificate = filepath.Join(dir, "cert.pem")
+			certificateConfig.Destination.PrivateKey = filepath.Join(dir, "key.pem")
+			certificateConfig.Destination.Chain = filepath.Join(dir, "chain.pem")
+			certificateConfig.Destination.Permissions = test.permissions
+
+			certificate, err := (&AgentManager{}).issueCertificate(certificateConfig, "token")
+			assert.NoError(t, err)
+			assert.Equal(t, "app.internal", certificate.Subject.CommonName)
+
+			for path, wantMode := range map[string]os.FileMode{
+				certificateConfig.Destination.PrivateKey:  0600,
+				certificateConfig.Destination.Certificate: test.wantCertificate,
+				certificateConfig.Destination.Chain:       test.wantCertificate,
+			} {
+				info, err := os.Stat(path)
+				assert.NoError(t, err)
+				assert.Equal(t, wantMode, info.Mode().Perm(), path)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package cmd
+
+import "syscall"
+
+// Windows has no user defined signals, and only delivers SIGKILL reliably
+var agentExecSignals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+}
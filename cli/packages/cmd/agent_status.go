@@ -0,0 +1,198 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HealthConfig exposes the state of the agent so orchestrators can tell a stuck agent apart from a
+// healthy one
+type HealthConfig struct {
+	// Address of the local endpoint serving /health and /status, e.g. 127.0.0.1:8099
+	ListenAddress string `yaml:"listen-address"`
+	// File the status is written to whenever it changes
+	StatusFile string `yaml:"status-file"`
+	// Report unhealthy when a template hasn't fetched secrets for this long, e.g. 15m. Unset means
+	// only failing authentication and templates that never rendered are unhealthy.
+	MaxFetchAge string `yaml:"max-fetch-age"`
+}
+
+type agentAuthStatus struct {
+	Authenticated       bool       `json:"authenticated"`
+	LastAuthenticatedAt *time.Time `json:"lastAuthenticatedAt,omitempty"`
+	LastError           string     `json:"lastError,omitempty"`
+}
+
+type agentTemplateStatus struct {
+	ID                int        `json:"id"`
+	Destination       string     `json:"destination,omitempty"`
+	LastFetchAt       *time.Time `json:"lastFetchAt,omitempty"`
+	LastRenderAt      *time.Time `json:"lastRenderAt,omitempty"`
+	RenderedFromCache bool       `json:"renderedFromCache"`
+	LastError         string     `json:"lastError,omitempty"`
+}
+
+type agentStatusReport struct {
+	Healthy   bool                  `json:"healthy"`
+	Reasons   []string              `json:"reasons,omitempty"`
+	Auth      agentAuthStatus       `json:"auth"`
+	Templates []agentTemplateStatus `json:"templates"`
+}
+
+type agentStatus struct {
+	mutex       sync.Mutex
+	statusFile  string
+	maxFetchAge time.Duration
+	auth        agentAuthStatus
+	templates   []agentTemplateStatus
+}
+
+func newAgentStatus(healthConfig HealthConfig, templates []Template) (*agentStatus, error) {
+	if healthConfig.ListenAddress == "" && healthConfig.StatusFile == "" {
+		return nil, errors.New("health requires a listen-address, a status-file or both")
+	}
+
+	status := &agentStatus{statusFile: healthConfig.StatusFile}
+
+	if healthConfig.MaxFetchAge != "" {
+		maxFetchAge, err := time.ParseDuration(healthConfig.MaxFetchAge)
+		if err != nil || maxFetchAge <= 0 {
+			return nil, fmt.Errorf("health.max-fetch-age must be a positive duration such as 15m")
+		}
+		status.maxFetchAge = maxFetchAge
+	}
+
+	for i, template := range templates {
+		destination := template.DestinationPath
+		if destination == "" && template.DestinationKubernetes != nil {
+			destination = template.DestinationKubernetes.Name
+		}
+		if destination == "" && template.DestinationAWS != nil {
+			destination = template.DestinationAWS.Name
+		}
+		status.templates = append(status.templates, agentTemplateStatus{ID: i + 1, Destination: destination})
+	}
+
+	return status, nil
+}
+
+func (s *agentStatus) AuthSucceeded() {
+	s.update(func() {
+		now := time.Now()
+		s.auth = agentAuthStatus{Authenticated: true, LastAuthenticatedAt: &now}
+	})
+}
+
+func (s *agentStatus) AuthFailed(err error) {
+	s.update(func() {
+		s.auth.Authenticated = false
+		s.auth.LastError = err.Error()
+	})
+}
+
+func (s *agentStatus) FetchSucceeded(templateId int) {
+	s.update(func() {
+		now := time.Now()
+		s.templates[templateId].LastFetchAt = &now
+		s.templates[templateId].LastError = ""
+	})
+}
+
+func (s *agentStatus) FetchFailed(templateId int, err error) {
+	s.update(func() {
+		s.templates[templateId].LastError = err.Error()
+	})
+}
+
+func (s *agentStatus) TemplateRendered(templateId int, fromCache bool) {
+	s.update(func() {
+		now := time.Now()
+		s.templates[templateId].LastRenderAt = &now
+		s.templates[templateId].RenderedFromCache = fromCache
+	})
+}
+
+func (s *agentStatus) update(fn func()) {
+	if s == nil {
+		return
+	}
+
+	s.mutex.Lock()
+	fn()
+	report := s.reportLocked()
+	s.mutex.Unlock()
+
+	if s.statusFile != "" {
+		encoded, _ := json.MarshalIndent(report, "", "  ")
+		if err := writeFileAtomically(s.statusFile, encoded, FileOwnership{}); err != nil {
+			log.Error().Msgf("unable to write status file %s because %v", s.statusFile, err)
+		}
+	}
+}
+
+func (s *agentStatus) report() agentStatusReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.reportLocked()
+}
+
+func (s *agentStatus) reportLocked() agentStatusReport {
+	report := agentStatusReport{
+		Healthy:   true,
+		Auth:      s.auth,
+		Templates: append([]agentTemplateStatus{}, s.templates...),
+	}
+
+	if !s.auth.Authenticated {
+		report.Reasons = append(report.Reasons, "not authenticated")
+	}
+
+	for _, template := range s.templates {
+		switch {
+		case template.LastRenderAt == nil:
+			report.Reasons = append(report.Reasons, fmt.Sprintf("template %d has not rendered yet", template.ID))
+		case template.RenderedFromCache:
+			report.Reasons = append(report.Reasons, fmt.Sprintf("template %d is rendered from the cache", template.ID))
+		case s.maxFetchAge > 0 && (template.LastFetchAt == nil || time.Since(*template.LastFetchAt) > s.maxFetchAge):
+			report.Reasons = append(report.Reasons, fmt.Sprintf("template %d has not fetched secrets within %s", template.ID, s.maxFetchAge))
+		}
+	}
+
+	report.Healthy = len(report.Reasons) == 0
+	return report
+}
+
+// Serve answers /health with 200 or 503 and /status with the full report
+func (s *agentStatus) Serve(listenAddress string) {
+	server := &http.Server{Addr: listenAddress, Handler: s.handler(), ReadHeaderTimeout: 5 * time.Second}
+	log.Info().Msgf("health endpoint listening on %s", listenAddress)
+	if err := server.ListenAndServe(); err != nil {
+		log.Error().Msgf("health endpoint stopped because %v", err)
+	}
+}
+
+func (s *agentStatus) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		report := s.report()
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"healthy": report.Healthy, "reasons": report.Reasons})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.report())
+	})
+	return mux
+}
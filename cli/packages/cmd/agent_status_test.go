@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewAgentStatus(t *testing.T) {
+	tests := []struct {
+		name             string
+		config           HealthConfig
+		templates        []Template
+		wantErr          string
+		wantDestinations []string
+	}{
+		{name: "nothing to report to", config: HealthConfig{}, wantErr: "listen-address, a status-file or both"},
+		{name: "invalid max fetch age", config: HealthConfig{ListenAddress: "127.0.0.1:0", MaxFetchAge: "soon"}, wantErr: "positive duration"},
+		{
+			name:   "destinations",
+			config: HealthConfig{ListenAddress: "127.0.0.1:0"},
+			templates: []Template{
+				{DestinationPath: "/etc/app/.env"},
+				{DestinationKubernetes: &KubernetesDestination{Name: "app-secrets"}},
+				{DestinationAWS: &AWSDestination{Name: "/app/config"}},
+			},
+			wantDestinations: []string{"/etc/app/.env", "app-secrets", "/app/config"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := newAgentStatus(test.config, test.templates)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			var destinations []string
+			for _, template := range status.templates {
+				destinations = append(destinations, template.Destination)
+			}
+			assert.Equal(t, test.wantDestinations, destinations)
+		})
+	}
+}
+
+func TestAgentStatusReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		maxFetchAge time.Duration
+		events      func(status *agentStatus)
+		wantReasons []string
+	}{
+		{
+			name: "healthy",
+			events: func(status *agentStatus) {
+				status.AuthSucceeded()
+				status.FetchSucceeded(0)
+				status.TemplateRendered(0, false)
+			},
+		},
+		{
+			name:        "starting",
+			wantReasons: []string{"not authenticated", "template 1 has not rendered yet"},
+		},
+		{
+			name: "authentication failed",
+			events: func(status *agentStatus) {
+				status.AuthSucceeded()
+				status.FetchSucceeded(0)
+				status.TemplateRendered(0, false)
+				status.AuthFailed(errors.New("identity not found"))
+			},
+			wantReasons: []string{"not authenticated"},
+		},
+		{
+			name: "rendered from the cache",
+			events: func(status *agentStatus) {
+				status.AuthSucceeded()
+				status.TemplateRendered(0, true)
+			},
+			wantReasons: []string{"template 1 is rendered from the cache"},
+		},
+		{
+			name:        "fetch too old",
+			maxFetchAge: time.Minute,
+			events: func(status *agentStatus) {
+				status.AuthSucceeded()
+				status.FetchSucceeded(0)
+				status.TemplateRendered(0, false)
+				lastFetchAt := time.Now().Add(-time.Hour)
+				status.templates[0].LastFetchAt = &lastFetchAt
+			},
+			wantReasons: []string{"template 1 has not fetched secrets within 1m0s"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status := &agentStatus{maxFetchAge: test.maxFetchAge, templates: []agentTemplateStatus{{ID: 1}}}
+			if test.events != nil {
+				test.events(status)
+			}
+
+			report := status.report()
+			assert.Equal(t, test.wantReasons, report.Reasons)
+			assert.Equal(t, len(test.wantReasons) == 0, report.Healthy)
+		})
+	}
+}
+
+func TestAgentStatusHealthEndpoint(t *testing.T) {
+	status := &agentStatus{templates: []agentTemplateStatus{{ID: 1}}}
+	server := httptest.NewServer(status.handler())
+	defer server.Close()
+
+	health := func() (int, map[string]interface{}) {
+		response, err := http.Get(server.URL + "/health")
+		assert.NoError(t, err)
+		defer response.Body.Close()
+
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(response.Body).Decode(&body))
+		return response.StatusCode, body
+	}
+
+	statusCode, body := health()
+	assert.Equal(t, http.StatusServiceUnavailable, statusCode)
+	assert.Equal(t, false, body["healthy"])
+
+	status.AuthSucceeded()
+	status.TemplateRendered(0, false)
+	statusCode, body = health()
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Equal(t, true, body["healthy"])
+
+	response, err := http.Get(server.URL + "/status")
+	assert.NoError(t, err)
+	defer response.Body.Close()
+	var report agentStatusReport
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&report))
+	assert.True(t, report.Auth.Authenticated)
+	assert.Len(t, report.Templates, 1)
+}
+
+func TestAgentStatusFile(t *testing.T) {
+	statusFile := filepath.Join(t.TempDir(), "status.json")
+	status, err := newAgentStatus(HealthConfig{StatusFile: statusFile}, []Template{{DestinationPath: "/etc/app/.env"}})
+	assert.NoError(t, err)
+
+	status.AuthFailed(errors.New("identity not found"))
+
+	content, err := os.ReadFile(statusFile)
+	assert.NoError(t, err)
+	var report agentStatusReport
+	assert.NoError(t, json.Unmarshal(content, &report))
+	assert.False(t, report.Healthy)
+	assert.Equal(t, "identity not found", report.Auth.LastError)
+}
@@ -0,0 +1,286 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// withAgentTemplateHelpers adds the general purpose helpers, such as encoding and casing, to the
+// secret fetching functions of a template
+func withAgentTemplateHelpers(funcs template.FuncMap) template.FuncMap {
+	helpers := template.FuncMap{
+		"b64enc":       templateBase64Encode,
+		"b64dec":       templateBase64Decode,
+		"toJson":       templateToJSON,
+		"toPrettyJson": templateToPrettyJSON,
+		"fromJson":     templateFromJSON,
+		"toYaml":       templateToYAML,
+		"fromYaml":     templateFromYAML,
+		"pemBundle":    templatePEMBundle,
+		"pkcs12":       templatePKCS12,
+		"upper":        strings.ToUpper,
+		"lower":        strings.ToLower,
+		"trim":         strings.TrimSpace,
+		"title":        templateTitleCase,
+		"camelcase":    templateCamelCase,
+		"snakecase":    func(value string) string { return strings.Join(templateWords(value, strings.ToLower), "_") },
+		"kebabcase":    func(value string) string { return strings.Join(templateWords(value, strings.ToLower), "-") },
+		"default":      templateDefault,
+	}
+
+	for name, fn := range helpers {
+		if _, exists := funcs[name]; !exists {
+			funcs[name] = fn
+		}
+	}
+	return funcs
+}
+
+func templateBase64Encode(value string) string {
+	return base64.StdEncoding.EncodeToString([]byte(value))
+}
+
+func templateBase64Decode(value string) (string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("b64dec: %w", err)
+	}
+	return string(decoded), nil
+}
+
+func templateToJSON(value interface{}) (string, error) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("toJson: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func templateToPrettyJSON(value interface{}) (string, error) {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("toPrettyJson: %w", err)
+	}
+	return string(encoded), nil
+}
+
+func templateFromJSON(value string) (interface{}, error) {
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil, fmt.Errorf("fromJson: %w", err)
+	}
+	return decoded, nil
+}
+
+func templateToYAML(value interface{}) (string, error) {
+	encoded, err := yaml.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("toYaml: %w", err)
+	}
+	return strings.TrimSuffix(string(encoded), "\n"), nil
+}
+
+func templateFromYAML(value string) (interface{}, error) {
+	var decoded interface{}
+	if err := yaml.Unmarshal([]byte(value), &decoded); err != nil {
+		return nil, fmt.Errorf("fromYaml: %w", err)
+	}
+	return stringifyYAMLKeys(decoded), nil
+}
+
+// yaml.v2 decodes mappings with interface{} keys, which toJson and template field access can't use
+func stringifyYAMLKeys(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for key, nested := range typed {
+			converted[fmt.Sprint(key)] = stringifyYAMLKeys(nested)
+		}
+		return converted
+	case []interface{}:
+		for i, nested := range typed {
+			typed[i] = stringifyYAMLKeys(nested)
+		}
+		return typed
+	default:
+		return typed
+	}
+}
+
+// templatePEMBundle joins the PEM blocks of all arguments into one bundle, dropping duplicates and
+// anything that isn't PEM, e.g. to build a certificate chain from a leaf and its issuers
+func templatePEMBundle(values ...string) (string, error) {
+	var bundle bytes.Buffer
+	seen := map[string]bool{}
+
+	for _, value := range values {
+		rest := []byte(value)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+
+			encoded := pem.EncodeToMemory(block)
+			if seen[string(encoded)] {
+				continue
+			}
+			seen[string(encoded)] = true
+			bundle.Write(encoded)
+		}
+	}
+
+	if bundle.Len() == 0 {
+		return "", errors.New("pemBundle: no PEM blocks found")
+	}
+	return bundle.String(), nil
+}
+
+// templatePKCS12 returns a password protected PKCS#12 keystore for a PEM private key and a PEM
+// certificate chain that starts with the key's certificate. Pipe it to b64enc for text formats.
+func templatePKCS12(privateKeyPEM, certificateChainPEM, password string) (string, error) {
+	keyBlock, _ := pem.Decode([]byte(privateKeyPEM))
+	if keyBlock == nil {
+		return "", errors.New("pkcs12: no PEM private key found")
+	}
+
+	privateKey, err := parseTemplatePrivateKey(keyBlock)
+	if err != nil {
+		return "", fmt.Errorf("pkcs12: %w", err)
+	}
+
+	var certificates []*x509.Certificate
+	rest := []byte(certificateChainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("pkcs12: unable to parse certificate: %w", err)
+		}
+		certificates = append(certificates, certificate)
+	}
+
+	if len(certificates) == 0 {
+		return "", errors.New("pkcs12: no PEM certificate found")
+	}
+
+	keystore, err := pkcs12.Modern2023.Encode(privateKey, certificates[0], certificates[1:], password)
+	if err != nil {
+		return "", fmt.Errorf("pkcs12: %w", err)
+	}
+	return string(keystore), nil
+}
+
+func parseTemplatePrivateKey(block *pem.Block) (interface{}, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		return x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported private key type %s", block.Type)
+	}
+}
+
+func templateTitleCase(value string) string {
+	runes := []rune(strings.ToLower(value))
+	for i := range runes {
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) {
+			runes[i] = unicode.ToUpper(runes[i])
+		}
+	}
+	return string(runes)
+}
+
+func templateCamelCase(value string) string {
+	words := templateWords(value, strings.ToLower)
+	for i := 1; i < len(words); i++ {
+		runes := []rune(words[i])
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, "")
+}
+
+// templateWords splits identifiers like DATABASE_URL, databaseUrl or database-url into words
+func templateWords(value string, transform func(string) string) []string {
+	var words []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, transform(string(current)))
+			current = nil
+		}
+	}
+
+	runes := []rune(value)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	return words
+}
+
+// templateDefault returns value unless it is empty, e.g. {{ .Value | default "localhost" }}
+func templateDefault(defaultValue interface{}, value interface{}) interface{} {
+	if value == nil {
+		return defaultValue
+	}
+
+	reflected := reflect.ValueOf(value)
+	switch reflected.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if reflected.Len() == 0 {
+			return defaultValue
+		}
+	case reflect.Ptr, reflect.Interface:
+		if reflected.IsNil() {
+			return defaultValue
+		}
+	case reflect.Bool:
+		if !reflected.Bool() {
+			return defaultValue
+		}
+	}
+	return value
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func TestTemplatePKCS12RoundTrip(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "app.internal"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	assert.NoError(t, err)
+
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	assert.NoError(t, err)
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey}))
+	chainPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}))
+
+	keystore, err := templatePKCS12(keyPEM, chainPEM, "changeit")
+	assert.NoError(t, err)
+
+	privateKey, certificate, caCerts, err := pkcs12.DecodeChain([]byte(keystore), "changeit")
+	assert.NoError(t, err)
+	assert.Equal(t, leafKey, privateKey)
+	assert.Equal(t, leafDER, certificate.Raw)
+	if assert.Len(t, caCerts, 1) {
+		assert.Equal(t, caDER, caCerts[0].Raw)
+	}
+
+	_, _, _, err = pkcs12.DecodeChain([]byte(keystore), "wrong")
+	assert.Error(t, err)
+}
+
+func testECPrivateKeyPEM(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	encodedKey, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encodedKey}))
+}
+
+func TestTemplatePKCS12Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		privateKey  string
+		certificate string
+		wantErr     string
+	}{
+		{name: "no key", wantErr: "no PEM private key found"},
+		{name: "unsupported key", privateKey: "-----BEGIN PUBLIC KEY-----\nAA==\n-----END PUBLIC KEY-----\n", wantErr: "unsupported private key type PUBLIC KEY"},
+		{name: "no certificate", privateKey: testECPrivateKeyPEM(t), wantErr: "no PEM certificate found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := templatePKCS12(test.privateKey, test.certificate, "changeit")
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAgentManagerForceRender(t *testing.T) {
+	tests := []struct {
+		name         string
+		templates    int
+		forceRenders int
+	}{
+		{name: "no templates", templates: 0, forceRenders: 1},
+		{name: "one render", templates: 2, forceRenders: 1},
+		{name: "renders are coalesced", templates: 2, forceRenders: 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			agentManager := NewAgentManager(NewAgentMangerOptions{Templates: make([]Template, test.templates)})
+
+			// never blocks, even while every template already has a render pending
+			for i := 0; i < test.forceRenders; i++ {
+				agentManager.ForceRender()
+			}
+
+			assert.Len(t, agentManager.forceRenderChans, test.templates)
+			for _, forceRenderChan := range agentManager.forceRenderChans {
+				assert.Len(t, forceRenderChan, 1, "each template renders once however often a render is forced")
+			}
+		})
+	}
+}
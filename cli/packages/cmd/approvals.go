@@ -0,0 +1,252 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/go-resty/resty/v2"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	APPROVAL_STATUS_OPEN   = "open"
+	APPROVAL_STATUS_CLOSED = "close"
+
+	APPROVAL_REVIEW_APPROVED = "approved"
+	APPROVAL_REVIEW_REJECTED = "rejected"
+)
+
+var approvalsCmd = &cobra.Command{
+	Example: `
+	infisical approvals list --env=prod
+	infisical approvals approve <change request id> --merge
+	infisical approvals reject <change request id> --comment="rotate it instead"`,
+	Short:                 "Used to review change requests of environments protected by approval policies",
+	Long:                  "Review change requests of environments protected by approval policies. secrets set and delete open change requests instead of changing shared secrets of protected folders, which reviewers can then approve, merge or reject from here.",
+	Use:                   "approvals",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var approvalsListCmd = &cobra.Command{
+	Example:               `infisical approvals list --env=prod --status=open`,
+	Short:                 "Used to list the change requests of a project",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		environmentName, err := cmd.Flags().GetString("env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		status, err := cmd.Flags().GetString("status")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if status == "closed" {
+			status = APPROVAL_STATUS_CLOSED
+		}
+		if status != APPROVAL_STATUS_OPEN && status != APPROVAL_STATUS_CLOSED {
+			util.PrintErrorMessageAndExit("--status must be open or closed")
+		}
+
+		httpClient, _, projectId := newProjectHTTPClient(token, projectId)
+
+		approvals, err := api.CollectAll(func(page api.PageRequest) (api.Page[api.SecretApprovalRequest], error) {
+			approvalsResponse, err := api.CallGetSecretApprovalRequestsV1(httpClient, api.GetSecretApprovalRequestsV1Request{
+				WorkspaceId: projectId,
+				Environment: environmentName,
+				Status:      status,
+				Offset:      page.Offset,
+				Limit:       page.Limit,
+			})
+			return api.Page[api.SecretApprovalRequest]{Items: approvalsResponse.Approvals}, err
+		}, api.CollectOptions{})
+		if err != nil {
+			util.HandleError(err, "Unable to list change requests")
+		}
+
+		if len(approvals) == 0 {
+			fmt.Println("No change requests found")
+			return
+		}
+
+		rows := [][]string{}
+		for _, approval := range approvals {
+			rows = append(rows, []string{
+				approval.ID,
+				fmt.Sprintf("%s:%s", approval.Environment, approval.SecretPath),
+				describeApprovalChanges(approval),
+				approval.Committer.Email,
+				fmt.Sprintf("%d/%d", countApprovalReviews(approval, APPROVAL_REVIEW_APPROVED), approval.Policy.Approvals),
+				approvalStatus(approval),
+				approval.CreatedAt.Local().Format("2006-01-02 15:04"),
+			})
+		}
+		visualize.GenericTable([]string{"ID", "FOLDER", "CHANGES", "REQUESTED BY", "APPROVALS", "STATUS", "CREATED"}, rows)
+	},
+}
+
+var approvalsApproveCmd = &cobra.Command{
+	Example:               `infisical approvals approve <change request id> --merge`,
+	Short:                 "Used to approve a change request, and optionally merge it",
+	Use:                   "approve [change-request-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		shouldMerge, err := cmd.Flags().GetBool("merge")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		httpClient := reviewApprovalRequest(cmd, args[0], APPROVAL_REVIEW_APPROVED)
+		util.PrintSuccessMessage(fmt.Sprintf("Approved change request %s", args[0]))
+
+		if shouldMerge {
+			_, err := api.CallMergeSecretApprovalRequestV1(httpClient, args[0])
+			if err != nil {
+				util.HandleError(err, "Unable to merge the change request. It may still need approvals from other reviewers")
+			}
+			util.PrintSuccessMessage(fmt.Sprintf("Merged change request %s, its changes now apply", args[0]))
+		}
+
+		Telemetry.CaptureEvent("cli-command:approvals approve", posthog.NewProperties().Set("merge", shouldMerge).Set("version", util.CLI_VERSION))
+	},
+}
+
+var approvalsRejectCmd = &cobra.Command{
+	Example:               `infisical approvals reject <change request id> --comment="rotate it instead"`,
+	Short:                 "Used to reject a change request",
+	Use:                   "reject [change-request-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		reviewApprovalRequest(cmd, args[0], APPROVAL_REVIEW_REJECTED)
+		util.PrintSuccessMessage(fmt.Sprintf("Rejected change request %s", args[0]))
+
+		Telemetry.CaptureEvent("cli-command:approvals reject", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+// reviewApprovalRequest submits the review of the caller and returns the client it used
+func reviewApprovalRequest(cmd *cobra.Command, approvalId string, reviewStatus string) *resty.Client {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	comment, err := cmd.Flags().GetString("comment")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	httpClient := newOrganizationHTTPClient(token, "review change requests")
+
+	err = api.CallReviewSecretApprovalRequestV1(httpClient, api.ReviewSecretApprovalRequestV1Request{
+		ApprovalId: approvalId,
+		Status:     reviewStatus,
+		Comment:    comment,
+	})
+	if err != nil {
+		if api.IsForbidden(err) {
+			util.HandleError(err, "You are not an approver of this change request")
+		}
+		util.HandleError(err, "Unable to review the change request")
+	}
+
+	return httpClient
+}
+
+// printOpenedChangeRequests tells the user that their changes wait for approval, and where to find them
+func printOpenedChangeRequests(approvals []api.SecretApprovalRequest) {
+	if len(approvals) == 0 {
+		return
+	}
+
+	for _, approval := range approvals {
+		util.PrintWarning(fmt.Sprintf("This folder is protected by the approval policy %q. Opened change request %s, it applies once it has %d approval(s) and is merged", approval.Policy.Name, approval.ID, approval.Policy.Approvals))
+	}
+	fmt.Println("Reviewers can act on it with [infisical approvals approve] or [infisical approvals reject]")
+}
+
+// countRequestedOperations counts the secret operations that wait for a change request to be merged
+func countRequestedOperations(secretOperations []models.SecretSetOperation) int {
+	requested := 0
+	for _, secretOperation := range secretOperations {
+		if strings.HasSuffix(secretOperation.SecretOperation, " REQUESTED") {
+			requested++
+		}
+	}
+	return requested
+}
+
+// describeApprovalChanges summarizes the commits of a change request, e.g. "create API_KEY, delete OLD_KEY"
+func describeApprovalChanges(approval api.SecretApprovalRequest) string {
+	changes := []string{}
+	for _, commit := range approval.Commits {
+		changes = append(changes, fmt.Sprintf("%s %s", commit.Op, commit.SecretKey))
+	}
+	return strings.Join(changes, ", ")
+}
+
+func countApprovalReviews(approval api.SecretApprovalRequest, reviewStatus string) int {
+	count := 0
+	for _, reviewer := range approval.Reviewers {
+		if reviewer.Status == reviewStatus {
+			count++
+		}
+	}
+	return count
+}
+
+func approvalStatus(approval api.SecretApprovalRequest) string {
+	switch {
+	case approval.HasMerged:
+		return "merged"
+	case approval.Status == APPROVAL_STATUS_CLOSED:
+		return "closed"
+	case countApprovalReviews(approval, APPROVAL_REVIEW_REJECTED) > 0:
+		return "rejected"
+	case countApprovalReviews(approval, APPROVAL_REVIEW_APPROVED) >= approval.Policy.Approvals:
+		return "ready to merge"
+	default:
+		return "open"
+	}
+}
+
+func init() {
+	approvalsCmd.PersistentFlags().String("token", "", "Review change requests using a machine identity access token")
+
+	approvalsListCmd.Flags().String("projectId", "", "manually set the project ID to list change requests of when using machine identity based auth")
+	approvalsListCmd.Flags().String("env", "", "only list change requests of this environment")
+	approvalsListCmd.Flags().String("status", APPROVAL_STATUS_OPEN, "list open or closed change requests")
+	approvalsCmd.AddCommand(approvalsListCmd)
+
+	approvalsApproveCmd.Flags().Bool("merge", false, "merge the change request after approving it, once it has enough approvals")
+	approvalsApproveCmd.Flags().String("comment", "", "a comment to leave with your review")
+	approvalsCmd.AddCommand(approvalsApproveCmd)
+
+	approvalsRejectCmd.Flags().String("comment", "", "a comment to leave with your review")
+	approvalsCmd.AddCommand(approvalsRejectCmd)
+
+	rootCmd.AddCommand(approvalsCmd)
+}
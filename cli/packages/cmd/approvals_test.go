@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApprovalStatus(t *testing.T) {
+	var approval api.SecretApprovalRequest
+	err := json.Unmarshal([]byte(`{
+		"id": "a1",
+		"status": "open",
+		"policy": {"approvals": 2},
+		"reviewers": [{"userId": "u1", "status": "approved"}],
+		"commits": [{"op": "create", "secretKey": "API_KEY"}, {"op": "delete", "secretKey": "OLD_KEY"}]
+	}`), &approval)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "create API_KEY, delete OLD_KEY", describeApprovalChanges(approval))
+	assert.Equal(t, "open", approvalStatus(approval))
+
+	approval.Reviewers = append(approval.Reviewers, approval.Reviewers[0])
+	assert.Equal(t, "ready to merge", approvalStatus(approval))
+
+	approval.Reviewers[1].Status = APPROVAL_REVIEW_REJECTED
+	assert.Equal(t, "rejected", approvalStatus(approval))
+
+	approval.HasMerged = true
+	assert.Equal(t, "merged", approvalStatus(approval))
+}
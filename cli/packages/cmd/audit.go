@@ -0,0 +1,210 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Example: `
+	infisical audit --since=24h
+	infisical audit --projectId=<project id> --event=get-secrets --environment=prod --since=7d
+	infisical audit --actor-type=identity --actor=<identity id> --since=2024-06-01 --until=2024-06-30 --output=json`,
+	Short:                 "Used to query the audit logs of your organization or a project",
+	Long:                  "Query the audit logs of your organization, or of one project with --projectId, newest first. Filter them by actor, event type, time range and the environment or folder they touched, and print them as a table or as JSON for scripted reviews.",
+	Use:                   "audit",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		actorType, err := cmd.Flags().GetString("actor-type")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		actor, err := cmd.Flags().GetString("actor")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		eventType, err := cmd.Flags().GetString("event")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		environmentName, err := cmd.Flags().GetString("environment")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("secret-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		since, err := cmd.Flags().GetString("since")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		until, err := cmd.Flags().GetString("until")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat := getOutputFormat(cmd)
+
+		now := time.Now()
+		request := api.GetAuditLogsV1Request{
+			ProjectId:   projectId,
+			ActorType:   actorType,
+			Actor:       actor,
+			EventType:   eventType,
+			Environment: environmentName,
+			SecretPath:  secretsPath,
+		}
+		if since != "" {
+			startDate, err := parseAuditTime(since, now)
+			if err != nil {
+				util.HandleError(err, "Unable to parse --since")
+			}
+			request.StartDate = &startDate
+		}
+		if until != "" {
+			endDate, err := parseAuditTime(until, now)
+			if err != nil {
+				util.HandleError(err, "Unable to parse --until")
+			}
+			request.EndDate = &endDate
+		}
+
+		httpClient := newOrganizationHTTPClient(token, "read audit logs")
+
+		auditLogs, err := api.CollectAll(func(page api.PageRequest) (api.Page[api.AuditLog], error) {
+			pageRequest := request
+			pageRequest.Offset = page.Offset
+			pageRequest.Limit = page.Limit
+			auditLogsResponse, err := api.CallGetAuditLogsV1(httpClient, pageRequest)
+			return api.Page[api.AuditLog]{Items: auditLogsResponse.AuditLogs}, err
+		}, api.CollectOptions{MaxItems: limit})
+		if errors.Is(err, api.ErrResultsTruncated) {
+			util.PrintWarning(fmt.Sprintf("Showing the newest %d audit logs only, raise --limit to see more", limit))
+		} else if err != nil {
+			if api.IsForbidden(err) {
+				util.HandleError(err, "You don't have permission to read these audit logs")
+			}
+			util.HandleError(err, "Unable to query audit logs")
+		}
+
+		if outputFormat != OutputTable {
+			printOutput(outputFormat, auditLogs, nil)
+		} else if len(auditLogs) == 0 {
+			fmt.Println("No audit logs found")
+		} else {
+			rows := [][]string{}
+			for _, auditLog := range auditLogs {
+				rows = append(rows, []string{
+					auditLog.CreatedAt.Local().Format(time.RFC3339),
+					auditLog.Event.Type,
+					describeAuditActor(auditLog),
+					auditLog.IpAddress,
+					describeAuditMetadata(auditLog.Event.Metadata),
+				})
+			}
+			visualize.GenericTable([]string{"TIME", "EVENT", "ACTOR", "IP ADDRESS", "DETAILS"}, rows)
+		}
+
+		Telemetry.CaptureEvent("cli-command:audit", posthog.NewProperties().Set("logCount", len(auditLogs)).Set("version", util.CLI_VERSION))
+	},
+}
+
+// parseAuditTime reads a point in time given either as RFC3339, as a date, or as a duration before now
+// such as 90m, 24h or 7d
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if pointInTime, err := time.Parse(time.RFC3339, value); err == nil {
+		return pointInTime, nil
+	}
+
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err == nil && count >= 0 {
+			return now.AddDate(0, 0, -count), nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(value); err == nil && duration >= 0 {
+		return now.Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("%q is not a time such as 2024-06-01T00:00:00Z, a date such as 2024-06-01, or a duration ago such as 24h or 7d", value)
+}
+
+// describeAuditActor names the actor of a log by the most readable identifier it has
+func describeAuditActor(auditLog api.AuditLog) string {
+	for _, field := range []string{"email", "name", "userId", "identityId", "serviceId"} {
+		if value, ok := auditLog.Actor.Metadata[field].(string); ok && value != "" {
+			return fmt.Sprintf("%s:%s", auditLog.Actor.Type, value)
+		}
+	}
+	return auditLog.Actor.Type
+}
+
+// describeAuditMetadata lists the scalar event metadata as sorted key=value pairs, nested values are left
+// for --output=json
+func describeAuditMetadata(metadata map[string]interface{}) string {
+	details := []string{}
+	for key, value := range metadata {
+		switch value.(type) {
+		case string, float64, bool:
+			details = append(details, fmt.Sprintf("%s=%v", key, value))
+		}
+	}
+	sort.Strings(details)
+	return strings.Join(details, " ")
+}
+
+func init() {
+	auditCmd.Flags().String("token", "", "Query audit logs using a machine identity access token")
+	auditCmd.Flags().String("projectId", "", "only query the audit logs of this project")
+	auditCmd.Flags().String("actor-type", "", "only show logs of this kind of actor: user, identity, service or platform")
+	auditCmd.Flags().String("actor", "", "only show logs of the user, identity or service token with this ID")
+	auditCmd.Flags().String("event", "", "only show logs of this event type, e.g. get-secrets or update-secret")
+	auditCmd.Flags().String("environment", "", "only show logs touching this environment")
+	auditCmd.Flags().String("secret-path", "", "only show logs touching this folder path")
+	auditCmd.Flags().String("since", "", "only show logs from this time on: RFC3339, a date, or a duration ago such as 24h or 7d")
+	auditCmd.Flags().String("until", "", "only show logs up to this time: RFC3339, a date, or a duration ago such as 24h or 7d")
+	auditCmd.Flags().Int("limit", 100, "the most logs to show, newest first. 0 shows all")
+	addOutputFlag(auditCmd)
+	rootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	pointInTime, err := parseAuditTime("2024-06-01T08:30:00Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 1, 8, 30, 0, 0, time.UTC), pointInTime)
+
+	pointInTime, err = parseAuditTime("7d", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.AddDate(0, 0, -7), pointInTime)
+
+	pointInTime, err = parseAuditTime("90m", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-90*time.Minute), pointInTime)
+
+	_, err = parseAuditTime("yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestDescribeAuditMetadata(t *testing.T) {
+	metadata := map[string]interface{}{
+		"secretPath":  "/api",
+		"environment": "prod",
+		"secretCount": float64(3),
+		"secrets":     []interface{}{"A"},
+	}
+	assert.Equal(t, "environment=prod secretCount=3 secretPath=/api", describeAuditMetadata(metadata))
+}
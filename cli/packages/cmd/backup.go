@@ -0,0 +1,480 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/go-resty/resty/v2"
+	"github.com/manifoldco/promptui"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	INFISICAL_BACKUP_PASSPHRASE_NAME = "INFISICAL_BACKUP_PASSPHRASE"
+
+	projectBackupFormat  = "infisical-project-backup"
+	projectBackupVersion = 1
+)
+
+// projectBackupFile is what a backup file holds, the encrypted and gzipped projectBackup
+type projectBackupFile struct {
+	Format     string                            `json:"format"`
+	Version    int                               `json:"version"`
+	Encryption models.PassphraseEncryptionResult `json:"encryption"`
+}
+
+type projectBackup struct {
+	CreatedAt    time.Time           `json:"createdAt"`
+	Project      api.Project         `json:"project"`
+	Environments []environmentBackup `json:"environments"`
+}
+
+type environmentBackup struct {
+	Name    string         `json:"name"`
+	Slug    string         `json:"slug"`
+	Folders []folderBackup `json:"folders"`
+}
+
+type folderBackup struct {
+	Path    string         `json:"path"`
+	Secrets []secretBackup `json:"secrets"`
+}
+
+type secretBackup struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Comment string `json:"comment,omitempty"`
+}
+
+var backupCmd = &cobra.Command{
+	Example: `
+	infisical backup create --output-file=project.backup
+	infisical backup restore --file=project.backup --projectId=<other-project-id>`,
+	Use:                   "backup",
+	Short:                 "Used to back up the secrets of a project into an encrypted file, and restore them",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var backupCreateCmd = &cobra.Command{
+	Example: `
+	infisical backup create --output-file=project.backup
+	INFISICAL_BACKUP_PASSPHRASE=... infisical backup create --projectId=<project-id> --environments=prod,staging`,
+	Use:                   "create",
+	Short:                 "Used to back up the environments, folders and shared secrets of a project into an encrypted file",
+	Long:                  "Back up the environments, folders and shared secrets of a project, with their comments, into a file encrypted with AES-256-GCM and a key derived from a passphrase. The passphrase is read from $INFISICAL_BACKUP_PASSPHRASE, or asked for. Secret references are kept as they are. Personal secrets are left out.",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputPath, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		environments, err := cmd.Flags().GetStringSlice("environments")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			util.PrintErrorMessageAndExit("Service tokens can't back up a project, log in or use a machine identity")
+		}
+
+		httpClient, _, projectId := newProjectHTTPClient(token, projectId)
+
+		backup, err := createProjectBackup(httpClient, projectId, environments)
+		if err != nil {
+			util.HandleError(err, "Unable to back up the project")
+		}
+
+		if outputPath == "" {
+			outputPath = fmt.Sprintf("infisical-%s-%s.backup", backup.Project.Slug, backup.CreatedAt.Format("20060102-150405"))
+		}
+
+		passphrase, err := readBackupPassphrase(true)
+		if err != nil {
+			util.HandleError(err, "Unable to read the passphrase")
+		}
+
+		content, err := encryptProjectBackup(backup, passphrase)
+		if err != nil {
+			util.HandleError(err, "Unable to encrypt the backup")
+		}
+
+		if err := os.WriteFile(outputPath, content, 0600); err != nil {
+			util.HandleError(err, "Unable to write the backup")
+		}
+
+		secretsCount := 0
+		for _, environment := range backup.Environments {
+			for _, folder := range environment.Folders {
+				secretsCount += len(folder.Secrets)
+			}
+		}
+
+		fmt.Printf("Backed up %d secrets of %d environments of %s to %s\n", secretsCount, len(backup.Environments), backup.Project.Name, outputPath)
+		Telemetry.CaptureEvent("cli-command:backup create", posthog.NewProperties().Set("secretCount", secretsCount).Set("version", util.CLI_VERSION))
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Example: `
+	infisical backup restore --file=project.backup --dry-run
+	infisical backup restore --file=project.backup --projectId=<other-project-id> --on-conflict=overwrite`,
+	Use:                   "restore",
+	Short:                 "Used to restore a backup into the same or another project",
+	Long:                  "Restore a backup into the same or another project. The environments must exist in the project, folders are created where they are missing.",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		environments, err := cmd.Flags().GetStringSlice("environments")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		onConflict, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if onConflict != util.SECRET_CONFLICT_OVERWRITE && onConflict != util.SECRET_CONFLICT_SKIP && onConflict != util.SECRET_CONFLICT_FAIL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--on-conflict must be one of %s, %s or %s", util.SECRET_CONFLICT_OVERWRITE, util.SECRET_CONFLICT_SKIP, util.SECRET_CONFLICT_FAIL))
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			util.PrintErrorMessageAndExit("Service tokens can't restore a project, log in or use a machine identity")
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			util.HandleError(err, "Unable to read the backup")
+		}
+
+		passphrase, err := readBackupPassphrase(false)
+		if err != nil {
+			util.HandleError(err, "Unable to read the passphrase")
+		}
+
+		backup, err := decryptProjectBackup(content, passphrase)
+		if err != nil {
+			util.HandleError(err, "Unable to open the backup")
+		}
+
+		httpClient, tokenDetails, projectId := newProjectHTTPClient(token, projectId)
+
+		project, err := api.CallGetProjectById(httpClient, projectId)
+		if err == nil && project.ID == "" {
+			project.ID = projectId
+		}
+		if err != nil {
+			util.HandleError(err, "Unable to get the project to restore into")
+		}
+
+		existingEnvironments := map[string]bool{}
+		for _, environment := range project.Environments {
+			existingEnvironments[environment.Slug] = true
+		}
+
+		environmentsToRestore := []environmentBackup{}
+		for _, environment := range backup.Environments {
+			if len(environments) > 0 && !slices.Contains(environments, environment.Slug) {
+				continue
+			}
+			if !existingEnvironments[environment.Slug] {
+				util.PrintWarning(fmt.Sprintf("Skipping the environment %s, it doesn't exist in %s", environment.Slug, project.Name))
+				continue
+			}
+			environmentsToRestore = append(environmentsToRestore, environment)
+		}
+
+		// with --on-conflict=fail nothing is written unless no folder has a conflict
+		if onConflict == util.SECRET_CONFLICT_FAIL && !dryRun {
+			restoreOperations, _, _, err := restoreProjectBackup(environmentsToRestore, project.ID, tokenDetails, onConflict, true)
+			if err != nil {
+				printCopyOperations(restoreOperations, true)
+				util.HandleError(err, "Unable to restore the backup")
+			}
+		}
+
+		restoreOperations, approvals, restoredCount, err := restoreProjectBackup(environmentsToRestore, project.ID, tokenDetails, onConflict, dryRun)
+		if err != nil {
+			printCopyOperations(restoreOperations, dryRun)
+			printOpenedChangeRequests(approvals)
+			util.HandleError(err, "Unable to restore the backup")
+		}
+
+		printCopyOperations(restoreOperations, dryRun)
+		printOpenedChangeRequests(approvals)
+
+		if dryRun {
+			fmt.Printf("Dry run, no secrets were changed in %s\n", project.Name)
+		} else {
+			fmt.Printf("Restored %d secrets of the backup of %s taken %s into %s\n", restoredCount, backup.Project.Name, backup.CreatedAt.Format(time.RFC3339), project.Name)
+		}
+
+		Telemetry.CaptureEvent("cli-command:backup restore", posthog.NewProperties().Set("secretCount", restoredCount).Set("crossProject", backup.Project.ID != project.ID).Set("version", util.CLI_VERSION))
+	},
+}
+
+// createProjectBackup reads the shared secrets of every folder of the environments, all of them
+// when none are given
+func createProjectBackup(httpClient *resty.Client, projectId string, environments []string) (projectBackup, error) {
+	project, err := api.CallGetProjectById(httpClient, projectId)
+	if err != nil {
+		return projectBackup{}, err
+	}
+
+	backup := projectBackup{
+		CreatedAt: time.Now().UTC(),
+		Project:   api.Project{ID: project.ID, Name: project.Name, Slug: project.Slug},
+	}
+
+	for _, environment := range project.Environments {
+		if len(environments) > 0 && !slices.Contains(environments, environment.Slug) {
+			continue
+		}
+
+		environmentBackup := environmentBackup{Name: environment.Name, Slug: environment.Slug}
+
+		folders := []string{"/"}
+		for i := 0; i < len(folders); i++ {
+			subFolders, err := api.CallGetFoldersV1(httpClient, api.GetFoldersV1Request{WorkspaceId: projectId, Environment: environment.Slug, FoldersPath: folders[i]})
+			if err != nil {
+				return projectBackup{}, fmt.Errorf("unable to list the folders of %s:%s [err=%v]", environment.Slug, folders[i], err)
+			}
+			for _, subFolder := range subFolders.Folders {
+				folders = append(folders, strings.TrimSuffix(folders[i], "/")+"/"+subFolder.Name)
+			}
+
+			rawSecrets, err := api.CallGetRawSecretsV3(httpClient, api.GetRawSecretsV3Request{WorkspaceId: projectId, Environment: environment.Slug, SecretPath: folders[i]})
+			if err != nil {
+				return projectBackup{}, fmt.Errorf("unable to fetch the secrets of %s:%s [err=%v]", environment.Slug, folders[i], err)
+			}
+
+			folderBackup := folderBackup{Path: folders[i], Secrets: []secretBackup{}}
+			for _, secret := range rawSecrets.Secrets {
+				if secret.Type == util.SECRET_TYPE_PERSONAL {
+					continue
+				}
+				folderBackup.Secrets = append(folderBackup.Secrets, secretBackup{Key: secret.SecretKey, Value: secret.SecretValue, Comment: secret.SecretComment})
+			}
+			environmentBackup.Folders = append(environmentBackup.Folders, folderBackup)
+		}
+
+		backup.Environments = append(backup.Environments, environmentBackup)
+	}
+
+	for _, slug := range environments {
+		found := false
+		for _, environment := range backup.Environments {
+			found = found || environment.Slug == slug
+		}
+		if !found {
+			return projectBackup{}, fmt.Errorf("the environment %s doesn't exist in %s", slug, project.Name)
+		}
+	}
+
+	return backup, nil
+}
+
+// restoreProjectBackup writes the secrets of the backed up environments into the project, opening
+// change requests for folders protected by an approval policy
+func restoreProjectBackup(environments []environmentBackup, projectId string, tokenDetails *models.TokenDetails, onConflict string, dryRun bool) ([]secretCopyOperation, []api.SecretApprovalRequest, int, error) {
+	restoreOperations := []secretCopyOperation{}
+	approvals := []api.SecretApprovalRequest{}
+	restoredCount := 0
+	for _, environment := range environments {
+		for _, folder := range environment.Folders {
+			folderLabel := environment.Slug + ":" + folder.Path
+
+			folderExists, err := ensureSecretFolder(tokenDetails, projectId, environment.Slug, folder.Path, !dryRun)
+			if err != nil {
+				return restoreOperations, approvals, restoredCount, fmt.Errorf("unable to create the folder %s [err=%v]", folderLabel, err)
+			}
+
+			secretsToSet := []models.SingleEnvironmentVariable{}
+			for _, secret := range folder.Secrets {
+				secretsToSet = append(secretsToSet, models.SingleEnvironmentVariable{Key: secret.Key, Value: secret.Value, Comment: secret.Comment})
+			}
+
+			// a dry run doesn't create missing folders, and everything restored into them is new
+			if !folderExists {
+				for _, secret := range secretsToSet {
+					restoreOperations = append(restoreOperations, secretCopyOperation{Folder: folderLabel, SecretKey: secret.Key, SecretOperation: "SECRET CREATED"})
+				}
+				restoredCount += len(secretsToSet)
+				continue
+			}
+
+			if len(secretsToSet) == 0 {
+				continue
+			}
+
+			secretOperations, folderApprovals, err := util.PutRawSecrets(secretsToSet, util.SECRET_TYPE_SHARED, environment.Slug, folder.Path, projectId, tokenDetails, onConflict, dryRun)
+			for _, secretOperation := range secretOperations {
+				restoreOperations = append(restoreOperations, secretCopyOperation{Folder: folderLabel, SecretKey: secretOperation.SecretKey, SecretOperation: secretOperation.SecretOperation})
+			}
+			if err != nil {
+				return restoreOperations, approvals, restoredCount, fmt.Errorf("unable to restore secrets into %s [err=%v]", folderLabel, err)
+			}
+			approvals = append(approvals, folderApprovals...)
+			restoredCount += len(secretsToSet) - countRequestedOperations(secretOperations)
+		}
+	}
+
+	return restoreOperations, approvals, restoredCount, nil
+}
+
+func encryptProjectBackup(backup projectBackup, passphrase string) ([]byte, error) {
+	marshaledBackup, err := json.Marshal(backup)
+	if err != nil {
+		return nil, err
+	}
+
+	var compressed bytes.Buffer
+	gzipWriter := gzip.NewWriter(&compressed)
+	if _, err := gzipWriter.Write(marshaledBackup); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := crypto.EncryptWithPassphrase(compressed.Bytes(), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(projectBackupFile{Format: projectBackupFormat, Version: projectBackupVersion, Encryption: encrypted}, "", "  ")
+}
+
+func decryptProjectBackup(content []byte, passphrase string) (projectBackup, error) {
+	var backupFile projectBackupFile
+	if err := json.Unmarshal(content, &backupFile); err != nil || backupFile.Format != projectBackupFormat {
+		return projectBackup{}, errors.New("the file is not an Infisical project backup")
+	}
+	if backupFile.Version > projectBackupVersion {
+		return projectBackup{}, fmt.Errorf("the backup was made by a newer version of the CLI, update it to restore the backup")
+	}
+
+	compressed, err := crypto.DecryptWithPassphrase(backupFile.Encryption, passphrase)
+	if err != nil {
+		return projectBackup{}, err
+	}
+
+	gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return projectBackup{}, err
+	}
+	marshaledBackup, err := io.ReadAll(gzipReader)
+	if err != nil {
+		return projectBackup{}, err
+	}
+
+	var backup projectBackup
+	if err := json.Unmarshal(marshaledBackup, &backup); err != nil {
+		return projectBackup{}, fmt.Errorf("the backup is malformed [err=%v]", err)
+	}
+	return backup, nil
+}
+
+// readBackupPassphrase returns $INFISICAL_BACKUP_PASSPHRASE, or asks for the passphrase, twice when
+// a new backup is encrypted with it
+func readBackupPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(INFISICAL_BACKUP_PASSPHRASE_NAME); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrasePrompt := promptui.Prompt{
+		Label: "Backup passphrase",
+		Mask:  '*',
+		Validate: func(input string) error {
+			if confirm && len(input) < 12 {
+				return errors.New("the passphrase must be at least 12 characters")
+			}
+			return nil
+		},
+	}
+
+	passphrase, err := passphrasePrompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	if confirm {
+		confirmPrompt := promptui.Prompt{Label: "Confirm the passphrase", Mask: '*'}
+		confirmation, err := confirmPrompt.Run()
+		if err != nil {
+			return "", err
+		}
+		if confirmation != passphrase {
+			return "", errors.New("the passphrases don't match")
+		}
+	}
+
+	return passphrase, nil
+}
+
+func init() {
+	backupCreateCmd.Flags().String("token", "", "back up using a machine identity access token")
+	backupCreateCmd.Flags().String("projectId", "", "the project to back up, defaults to the one of your .infisical.json")
+	backupCreateCmd.Flags().String("output-file", "", "the file to write the backup to, defaults to infisical-<project>-<time>.backup")
+	backupCreateCmd.Flags().StringSlice("environments", []string{}, "comma separated slugs of the environments to back up, defaults to all")
+	backupCmd.AddCommand(backupCreateCmd)
+
+	backupRestoreCmd.Flags().String("token", "", "restore using a machine identity access token")
+	backupRestoreCmd.Flags().String("projectId", "", "the project to restore into, defaults to the one of your .infisical.json")
+	backupRestoreCmd.Flags().StringP("file", "f", "", "the backup to restore")
+	backupRestoreCmd.Flags().StringSlice("environments", []string{}, "comma separated slugs of the environments to restore, defaults to all")
+	backupRestoreCmd.Flags().String("on-conflict", util.SECRET_CONFLICT_FAIL, "what to do with secrets that already exist with another value: overwrite, skip or fail")
+	backupRestoreCmd.Flags().Bool("dry-run", false, "only show what would be restored")
+	backupRestoreCmd.MarkFlagRequired("file")
+	backupCmd.AddCommand(backupRestoreCmd)
+
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectBackupEncryption(t *testing.T) {
+	backup := projectBackup{
+		CreatedAt: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Project:   api.Project{ID: "project-id", Name: "Project", Slug: "project"},
+		Environments: []environmentBackup{
+			{
+				Name: "Production",
+				Slug: "prod",
+				Folders: []folderBackup{
+					{Path: "/", Secrets: []secretBackup{{Key: "DB_URL", Value: "postgres://${DB_HOST}", Comment: "primary"}}},
+					{Path: "/api", Secrets: []secretBackup{}},
+				},
+			},
+		},
+	}
+
+	content, err := encryptProjectBackup(backup, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.NotContains(t, string(content), "postgres://")
+
+	restored, err := decryptProjectBackup(content, "correct horse battery staple")
+	assert.NoError(t, err)
+	assert.Equal(t, backup, restored)
+
+	_, err = decryptProjectBackup(content, "wrong passphrase")
+	assert.Error(t, err)
+
+	_, err = decryptProjectBackup([]byte(`{"secrets": []}`), "correct horse battery staple")
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	infisicalSdk "github.com/infisical/go-sdk"
+)
+
+// getTokenOrLoginInCI returns the token given to the command, like util.GetInfisicalToken. Without one
+// on a CI platform, it logs in as the machine identity the job is set up with, so pipelines need no
+// login step: with OIDC auth when INFISICAL_MACHINE_IDENTITY_ID is set and the platform gives jobs ID
+// tokens, or with universal auth when its client ID and secret are set.
+func getTokenOrLoginInCI(cmd *cobra.Command) (*models.TokenDetails, error) {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil || token != nil {
+		return token, err
+	}
+
+	platform, inCI := util.DetectCIEnvironment()
+	if !inCI {
+		return nil, nil
+	}
+
+	strategy, found := getCIAuthStrategy()
+	if !found {
+		return nil, nil
+	}
+
+	log.Debug().Msgf("running on %s, logging in with %s", platform, formatAuthMethod(string(strategy)))
+	return loginInCI(platform, strategy)
+}
+
+// getAccessTokenOrLogin returns the token given to the command, or found in CI, and otherwise the one
+// of the logged in user
+func getAccessTokenOrLogin(cmd *cobra.Command) string {
+	token, err := getTokenOrLoginInCI(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+		return token.Token
+	}
+
+	util.RequireLogin()
+
+	loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+	if err != nil {
+		util.HandleError(err, "Unable to authenticate")
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	}
+	return loggedInUserDetails.UserCredentials.JTWToken
+}
+
+// getCIAuthStrategy picks the machine identity auth method from the credentials the job has
+func getCIAuthStrategy() (util.AuthStrategyType, bool) {
+	if os.Getenv(util.INFISICAL_MACHINE_IDENTITY_ID_NAME) != "" {
+		if _, found := util.DetectCIOidcTokenSource(); found || os.Getenv(util.INFISICAL_OIDC_AUTH_JWT_NAME) != "" {
+			return util.AuthStrategy.OIDC_AUTH, true
+		}
+	}
+
+	if os.Getenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_ID_NAME) != "" && os.Getenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET_NAME) != "" {
+		return util.AuthStrategy.UNIVERSAL_AUTH, true
+	}
+
+	return "", false
+}
+
+func loginInCI(platform string, strategy util.AuthStrategyType) (*models.TokenDetails, error) {
+	infisicalClient := newMachineIdentityLoginClient()
+
+	var credential infisicalSdk.MachineIdentityCredential
+	var err error
+	if strategy == util.AuthStrategy.OIDC_AUTH {
+		jwt := os.Getenv(util.INFISICAL_OIDC_AUTH_JWT_NAME)
+		if jwt == "" {
+			jwt, _, err = util.GetAmbientOidcToken(os.Getenv(util.INFISICAL_OIDC_AUTH_AUDIENCE_NAME))
+		}
+		if err == nil {
+			credential, err = infisicalClient.Auth().OidcAuthLogin(os.Getenv(util.INFISICAL_MACHINE_IDENTITY_ID_NAME), jwt)
+		}
+	} else {
+		credential, err = infisicalClient.Auth().UniversalAuthLogin(os.Getenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_ID_NAME), os.Getenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET_NAME))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to authenticate with %s on %s [err=%v]", formatAuthMethod(string(strategy)), platform, err)
+	}
+
+	return &models.TokenDetails{
+		Type:   util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER,
+		Token:  credential.AccessToken,
+		Source: fmt.Sprintf("%s login on %s", formatAuthMethod(string(strategy)), platform),
+	}, nil
+}
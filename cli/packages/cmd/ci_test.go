@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetCIAuthStrategy(t *testing.T) {
+	for _, envName := range []string{
+		util.INFISICAL_MACHINE_IDENTITY_ID_NAME, util.INFISICAL_OIDC_AUTH_JWT_NAME, util.INFISICAL_OIDC_AUTH_JWT_FILE_NAME,
+		util.INFISICAL_UNIVERSAL_AUTH_CLIENT_ID_NAME, util.INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET_NAME,
+		"ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN", "GITLAB_CI", "CIRCLECI", "BUILDKITE",
+	} {
+		t.Setenv(envName, "")
+	}
+
+	_, found := getCIAuthStrategy()
+	assert.False(t, found)
+
+	t.Setenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_ID_NAME, "client-id")
+	t.Setenv(util.INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET_NAME, "client-secret")
+	strategy, found := getCIAuthStrategy()
+	assert.True(t, found)
+	assert.Equal(t, util.AuthStrategy.UNIVERSAL_AUTH, strategy)
+
+	// the ID token of the platform is preferred over stored credentials
+	t.Setenv(util.INFISICAL_MACHINE_IDENTITY_ID_NAME, "identity-id")
+	t.Setenv("GITLAB_CI", "true")
+	t.Setenv(util.INFISICAL_GITLAB_ID_TOKEN_NAME, "id-token")
+	strategy, found = getCIAuthStrategy()
+	assert.True(t, found)
+	assert.Equal(t, util.AuthStrategy.OIDC_AUTH, strategy)
+}
@@ -0,0 +1,269 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/go-resty/resty/v2"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var clientSecretCmd = &cobra.Command{
+	Example: `
+	infisical client-secret create --identityId=<identity id> --ttl=720h
+	infisical client-secret list --identityId=<identity id>
+	infisical client-secret rotate <client secret id> --identityId=<identity id> --secret-only`,
+	Short:                 "Manage the universal auth client secrets of machine identities",
+	Long:                  "Manage the universal auth client secrets of machine identities, so CI can rotate the credentials it logs in with. Client secrets are only shown once, when they are created.",
+	Use:                   "client-secret",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var clientSecretCreateCmd = &cobra.Command{
+	Example:               `infisical client-secret create --identityId=<identity id> --description="github actions" --ttl=720h`,
+	Short:                 "Used to create a client secret for a machine identity",
+	Use:                   "create",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient, identityId := newClientSecretsHTTPClient(cmd)
+
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		ttl, err := cmd.Flags().GetDuration("ttl")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		numUsesLimit, err := cmd.Flags().GetInt("uses-limit")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretOnly, err := cmd.Flags().GetBool("secret-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		clientSecretResponse, err := api.CallCreateUniversalAuthClientSecretV1(httpClient, api.CreateUniversalAuthClientSecretRequest{
+			IdentityId:   identityId,
+			Description:  description,
+			NumUsesLimit: numUsesLimit,
+			TTL:          int(ttl.Seconds()),
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to create client secret")
+		}
+
+		printNewClientSecret(httpClient, identityId, clientSecretResponse, secretOnly)
+
+		Telemetry.CaptureEvent("cli-command:client-secret create", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var clientSecretListCmd = &cobra.Command{
+	Example:               `infisical client-secret list --identityId=<identity id>`,
+	Short:                 "Used to list the client secrets of a machine identity",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient, identityId := newClientSecretsHTTPClient(cmd)
+
+		clientSecretsResponse, err := api.CallGetUniversalAuthClientSecretsV1(httpClient, identityId)
+		if err != nil {
+			util.HandleError(err, "Unable to list client secrets")
+		}
+
+		if len(clientSecretsResponse.ClientSecretData) == 0 {
+			fmt.Println("No client secrets found")
+			return
+		}
+
+		rows := [][]string{}
+		for _, clientSecret := range clientSecretsResponse.ClientSecretData {
+			uses := strconv.Itoa(clientSecret.ClientSecretNumUses)
+			if clientSecret.ClientSecretNumUsesLimit > 0 {
+				uses = fmt.Sprintf("%d/%d", clientSecret.ClientSecretNumUses, clientSecret.ClientSecretNumUsesLimit)
+			}
+
+			rows = append(rows, []string{
+				clientSecret.ID,
+				clientSecret.Description,
+				clientSecret.ClientSecretPrefix + "...",
+				uses,
+				describeClientSecretExpiry(clientSecret, time.Now()),
+			})
+		}
+		visualize.GenericTable([]string{"ID", "DESCRIPTION", "SECRET", "USES", "EXPIRES"}, rows)
+	},
+}
+
+var clientSecretRotateCmd = &cobra.Command{
+	Example:               `infisical client-secret rotate <client secret id> --identityId=<identity id> --secret-only`,
+	Short:                 "Used to replace a client secret with a new one of the same description and limits, and revoke it",
+	Use:                   "rotate [client-secret-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient, identityId := newClientSecretsHTTPClient(cmd)
+
+		secretOnly, err := cmd.Flags().GetBool("secret-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		clientSecretsResponse, err := api.CallGetUniversalAuthClientSecretsV1(httpClient, identityId)
+		if err != nil {
+			util.HandleError(err, "Unable to list client secrets")
+		}
+
+		var oldClientSecret *api.UniversalAuthClientSecret
+		for i := range clientSecretsResponse.ClientSecretData {
+			if clientSecretsResponse.ClientSecretData[i].ID == args[0] {
+				oldClientSecret = &clientSecretsResponse.ClientSecretData[i]
+			}
+		}
+		if oldClientSecret == nil {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("No client secret with ID %s found for identity %s", args[0], identityId))
+		}
+
+		request := api.CreateUniversalAuthClientSecretRequest{
+			IdentityId:   identityId,
+			Description:  oldClientSecret.Description,
+			NumUsesLimit: oldClientSecret.ClientSecretNumUsesLimit,
+			TTL:          oldClientSecret.ClientSecretTTL,
+		}
+		if cmd.Flags().Changed("ttl") {
+			ttl, err := cmd.Flags().GetDuration("ttl")
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+			request.TTL = int(ttl.Seconds())
+		}
+
+		clientSecretResponse, err := api.CallCreateUniversalAuthClientSecretV1(httpClient, request)
+		if err != nil {
+			util.HandleError(err, "Unable to create the new client secret")
+		}
+
+		// the new client secret is printed before the old one is revoked, so it isn't lost when revoking fails
+		printNewClientSecret(httpClient, identityId, clientSecretResponse, secretOnly)
+
+		err = api.CallRevokeUniversalAuthClientSecretV1(httpClient, identityId, oldClientSecret.ID)
+		if err != nil {
+			util.PrintWarning(fmt.Sprintf("Created the new client secret %s, but could not revoke the old one [err=%v]. Revoke it with [infisical client-secret revoke %s --identityId=%s]", clientSecretResponse.ClientSecretData.ID, err, oldClientSecret.ID, identityId))
+		}
+
+		Telemetry.CaptureEvent("cli-command:client-secret rotate", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var clientSecretRevokeCmd = &cobra.Command{
+	Example:               `infisical client-secret revoke <client secret id> --identityId=<identity id>`,
+	Short:                 "Used to revoke client secrets of a machine identity",
+	Use:                   "revoke [client-secret-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient, identityId := newClientSecretsHTTPClient(cmd)
+
+		for _, clientSecretId := range args {
+			err := api.CallRevokeUniversalAuthClientSecretV1(httpClient, identityId, clientSecretId)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to revoke client secret %s", clientSecretId))
+			}
+		}
+
+		fmt.Printf("Client secret(s) [%v] have been revoked\n", strings.Join(args, ", "))
+
+		Telemetry.CaptureEvent("cli-command:client-secret revoke", posthog.NewProperties().Set("secretCount", len(args)).Set("version", util.CLI_VERSION))
+	},
+}
+
+func newClientSecretsHTTPClient(cmd *cobra.Command) (*resty.Client, string) {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	identityId, err := cmd.Flags().GetString("identityId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return newOrganizationHTTPClient(token, "manage client secrets"), identityId
+}
+
+// printNewClientSecret prints a client secret that was just created, with the client ID to log in with
+// it unless secretOnly is set
+func printNewClientSecret(httpClient *resty.Client, identityId string, clientSecretResponse api.CreateUniversalAuthClientSecretResponse, secretOnly bool) {
+	if secretOnly {
+		fmt.Println(clientSecretResponse.ClientSecret)
+		return
+	}
+
+	fmt.Printf("New client secret created, it won't be shown again\n")
+	fmt.Printf("ID: %v\n", clientSecretResponse.ClientSecretData.ID)
+	fmt.Printf("Expires: %v\n", describeClientSecretExpiry(clientSecretResponse.ClientSecretData, time.Now()))
+
+	// the secret is only shown once, so failing to look up the client ID mustn't keep it from being printed
+	universalAuthResponse, err := api.CallGetUniversalAuthV1(httpClient, identityId)
+	if err != nil {
+		util.PrintWarning(fmt.Sprintf("Unable to get the client ID of the identity [err=%v]", err))
+	} else {
+		fmt.Printf("Client ID: %v\n", universalAuthResponse.IdentityUniversalAuth.ClientId)
+	}
+	fmt.Printf("Client Secret: %v\n", clientSecretResponse.ClientSecret)
+}
+
+func describeClientSecretExpiry(clientSecret api.UniversalAuthClientSecret, now time.Time) string {
+	if clientSecret.IsClientSecretRevoked {
+		return "revoked"
+	}
+
+	if clientSecret.ClientSecretTTL == 0 {
+		return "never"
+	}
+
+	expiresAt := clientSecret.CreatedAt.Add(time.Duration(clientSecret.ClientSecretTTL) * time.Second)
+	if expiresAt.Before(now) {
+		return "expired"
+	}
+	return expiresAt.Local().Format("2006-01-02 15:04")
+}
+
+func init() {
+	clientSecretCmd.PersistentFlags().String("identityId", "", "the ID of the machine identity")
+	clientSecretCmd.PersistentFlags().String("token", "", "Manage client secrets using a machine identity access token")
+	clientSecretCmd.MarkPersistentFlagRequired("identityId")
+
+	clientSecretCreateCmd.Flags().String("description", "", "what the client secret is used for")
+	clientSecretCreateCmd.Flags().Duration("ttl", 0, "how long the client secret is valid, e.g. 720h. 0 never expires")
+	clientSecretCreateCmd.Flags().Int("uses-limit", 0, "how many times the client secret can be used to log in. 0 is unlimited")
+	clientSecretCreateCmd.Flags().Bool("secret-only", false, "When true, only the client secret will be printed")
+	clientSecretCmd.AddCommand(clientSecretCreateCmd)
+
+	clientSecretCmd.AddCommand(clientSecretListCmd)
+
+	clientSecretRotateCmd.Flags().Duration("ttl", 0, "how long the new client secret is valid, e.g. 720h. Default: the time to live of the old one")
+	clientSecretRotateCmd.Flags().Bool("secret-only", false, "When true, only the new client secret will be printed")
+	clientSecretCmd.AddCommand(clientSecretRotateCmd)
+
+	clientSecretCmd.AddCommand(clientSecretRevokeCmd)
+
+	rootCmd.AddCommand(clientSecretCmd)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribeClientSecretExpiry(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	clientSecret := api.UniversalAuthClientSecret{CreatedAt: now.Add(-2 * time.Hour)}
+
+	assert.Equal(t, "never", describeClientSecretExpiry(clientSecret, now))
+
+	clientSecret.ClientSecretTTL = 3600
+	assert.Equal(t, "expired", describeClientSecretExpiry(clientSecret, now))
+
+	clientSecret.ClientSecretTTL = 3 * 3600
+	assert.Equal(t, now.Add(time.Hour).Local().Format("2006-01-02 15:04"), describeClientSecretExpiry(clientSecret, now))
+
+	clientSecret.IsClientSecretRevoked = true
+	assert.Equal(t, "revoked", describeClientSecretExpiry(clientSecret, now))
+}
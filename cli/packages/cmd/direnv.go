@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// direnvHelper defines use infisical for .envrc files. The secrets are cached in the direnv layout
+// directory, so entering the project again doesn't fetch them every time, and are used from the cache
+// when Infisical can't be reached.
+const direnvHelper = `# use infisical [infisical export flags]
+#
+# Loads the secrets of the project into the environment when entering the directory. Add it to an .envrc:
+#
+#   use infisical --env=dev --path=/api
+#
+# The secrets are cached in the direnv layout directory for INFISICAL_DIRENV_CACHE_TTL seconds, 300 by
+# default, and are used from the cache when Infisical can't be reached. Set it to 0 to never cache them.
+use_infisical() {
+  local ttl="${INFISICAL_DIRENV_CACHE_TTL:-300}"
+
+  watch_file .infisical.json
+
+  if [[ "$ttl" -le 0 ]]; then
+    local secrets
+    secrets="$(infisical export --format=direnv --silent "$@")" || return 1
+    eval "$secrets"
+    return
+  fi
+
+  local cache_dir cache_file fetched_at now
+  cache_dir="$(direnv_layout_dir)/infisical"
+  cache_file="$cache_dir/$(printf '%s\n' "$*" | cksum | cut -d' ' -f1).sh"
+  now="$(date +%s)"
+
+  if [[ -f "$cache_file" ]]; then
+    read -r _ _ fetched_at < "$cache_file"
+    if [[ $((now - ${fetched_at:-0})) -lt "$ttl" ]]; then
+      source "$cache_file"
+      return
+    fi
+  fi
+
+  mkdir -p "$cache_dir"
+  chmod 700 "$cache_dir"
+  if (umask 077 && { echo "# fetched $now"; infisical export --format=direnv --silent "$@"; } > "$cache_file.tmp"); then
+    mv -f "$cache_file.tmp" "$cache_file"
+  else
+    rm -f "$cache_file.tmp"
+    if [[ ! -f "$cache_file" ]]; then
+      log_error "infisical: unable to fetch the secrets"
+      return 1
+    fi
+    log_error "infisical: unable to fetch the secrets, using the ones cached before"
+  fi
+  source "$cache_file"
+}
+`
+
+var direnvCmd = &cobra.Command{
+	Example: `infisical direnv > ~/.config/direnv/lib/infisical.sh
+echo "use infisical --env=dev" >> .envrc`,
+	Short: "Print the use infisical helper for direnv",
+	Long: `Print the use infisical helper for direnv. Saved in ~/.config/direnv/lib, it lets .envrc files load the
+secrets of the project with "use infisical", which takes the flags of infisical export. The secrets are
+cached in the direnv layout directory for INFISICAL_DIRENV_CACHE_TTL seconds, 300 by default, and the
+cache is used when Infisical can't be reached. Set it to 0 to fetch the secrets every time instead.
+
+To load the secrets without the helper, evaluate the direnv format of export in the .envrc:
+
+  eval "$(infisical export --format=direnv)"`,
+	Use:                   "direnv",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		io.WriteString(os.Stdout, direnvHelper)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(direnvCmd)
+}
@@ -0,0 +1,254 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	dockerCredentialSecretPrefix = "DOCKER_"
+	// dockerCredentialsNotFound is the message docker expects from helpers that have no credentials for a registry
+	dockerCredentialsNotFound = "credentials not found in native keychain"
+)
+
+var dockerCredentialCmd = &cobra.Command{
+	Example: `echo https://ghcr.io | infisical docker-credential get
+ln -s "$(which infisical)" /usr/local/bin/docker-credential-infisical`,
+	Short: "Keep the docker login credentials of registries in Infisical",
+	Long: `Keep the docker login credentials of registries in Infisical instead of ~/.docker/config.json. This is
+a docker credential helper: docker runs it with get, store, erase or list and passes the registry or
+the credentials on stdin.
+
+Link the CLI as docker-credential-infisical somewhere on PATH and set it as the credential store, or as
+the helper of some registries, in ~/.docker/config.json:
+
+  { "credsStore": "infisical" }
+  { "credHelpers": { "ghcr.io": "infisical" } }
+
+Docker runs helpers without flags, so set the project, environment and folder of the credentials with
+INFISICAL_DOCKER_CREDENTIAL_PROJECT_ID, INFISICAL_DOCKER_CREDENTIAL_ENV and INFISICAL_DOCKER_CREDENTIAL_PATH.
+Each registry is stored as a secret named after it, such as DOCKER_GHCR_IO.`,
+	Use:                   "docker-credential [get|store|erase|list]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"get", "store", "erase", "list"},
+	Annotations:           map[string]string{credentialHelperAnnotation: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		action := args[0]
+		source := getDockerCredentialSource(cmd)
+
+		switch action {
+		case "get":
+			serverURL := readDockerCredentialInput()
+			credentials, err := listDockerCredentials(source)
+			if err != nil {
+				exitDockerCredentialHelper(err.Error())
+			}
+
+			credential, found := credentials[dockerCredentialSecretName(serverURL)]
+			if !found {
+				exitDockerCredentialHelper(dockerCredentialsNotFound)
+			}
+			printDockerCredentialOutput(credential)
+
+		case "store":
+			var credential dockerCredential
+			if err := json.Unmarshal([]byte(readDockerCredentialInput()), &credential); err != nil {
+				exitDockerCredentialHelper(fmt.Sprintf("unable to parse the credentials [err=%v]", err))
+			}
+			if credential.ServerURL == "" {
+				exitDockerCredentialHelper("no server URL in the credentials")
+			}
+
+			if err := storeDockerCredential(source, credential); err != nil {
+				exitDockerCredentialHelper(err.Error())
+			}
+
+		case "erase":
+			serverURL := readDockerCredentialInput()
+			credentials, err := listDockerCredentials(source)
+			if err != nil {
+				exitDockerCredentialHelper(err.Error())
+			}
+
+			secretName := dockerCredentialSecretName(serverURL)
+			if _, found := credentials[secretName]; !found {
+				exitDockerCredentialHelper(dockerCredentialsNotFound)
+			}
+
+			httpClient, _, projectId := newProjectHTTPClient(source.Token, source.ProjectId)
+			err = api.CallDeleteSecretsRawV3(httpClient, api.DeleteSecretV3Request{
+				WorkspaceId: projectId,
+				Environment: source.Environment,
+				SecretName:  secretName,
+				Type:        util.SECRET_TYPE_SHARED,
+				SecretPath:  source.SecretsPath,
+			})
+			if err != nil {
+				exitDockerCredentialHelper(fmt.Sprintf("unable to erase the credentials of %s [err=%v]", serverURL, err))
+			}
+
+		case "list":
+			credentials, err := listDockerCredentials(source)
+			if err != nil {
+				exitDockerCredentialHelper(err.Error())
+			}
+
+			usernames := map[string]string{}
+			for _, credential := range credentials {
+				usernames[credential.ServerURL] = credential.Username
+			}
+			printDockerCredentialOutput(usernames)
+
+		default:
+			exitDockerCredentialHelper(fmt.Sprintf("unknown action %s, expected get, store, erase or list", action))
+		}
+
+		Telemetry.CaptureEvent("cli-command:docker-credential", posthog.NewProperties().Set("action", action).Set("version", util.CLI_VERSION))
+	},
+}
+
+// dockerCredential is the credentials object of the docker credential helper protocol, and the value
+// of the secret of each registry
+type dockerCredential struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// getDockerCredentialSource reads the project, environment and folder of the credentials from the flags,
+// or from the environment since docker runs helpers without flags
+func getDockerCredentialSource(cmd *cobra.Command) credentialSource {
+	source := credentialSource{}
+
+	var err error
+	source.Token, err = getTokenOrLoginInCI(cmd)
+	if err != nil {
+		exitDockerCredentialHelper(err.Error())
+	}
+
+	source.ProjectId = getDockerCredentialFlagOrEnv(cmd, "projectId", util.INFISICAL_DOCKER_CREDENTIAL_PROJECT_ID_NAME)
+	source.SecretsPath = getDockerCredentialFlagOrEnv(cmd, "path", util.INFISICAL_DOCKER_CREDENTIAL_PATH_NAME)
+	if source.SecretsPath == "" {
+		source.SecretsPath = "/"
+	}
+
+	source.Environment = getDockerCredentialFlagOrEnv(cmd, "env", util.INFISICAL_DOCKER_CREDENTIAL_ENV_NAME)
+	if source.Environment == "" {
+		source.Environment = util.GetEnvFromWorkspaceFile()
+	}
+	if source.Environment == "" {
+		source.Environment = "dev"
+	}
+
+	return source
+}
+
+func getDockerCredentialFlagOrEnv(cmd *cobra.Command, flag string, envName string) string {
+	value, err := cmd.Flags().GetString(flag)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if value == "" {
+		value = os.Getenv(envName)
+	}
+	return value
+}
+
+// dockerCredentialSecretName returns the secret that holds the credentials of a registry. Docker passes
+// the same registry with or without a scheme and trailing slash depending on the command, so both map
+// to the same secret.
+func dockerCredentialSecretName(serverURL string) string {
+	serverURL = strings.TrimSpace(serverURL)
+	if _, withoutScheme, found := strings.Cut(serverURL, "://"); found {
+		serverURL = withoutScheme
+	}
+	return credentialSecretName(dockerCredentialSecretPrefix, strings.TrimRight(serverURL, "/"))
+}
+
+// listDockerCredentials returns the credentials in the folder by secret name. Secrets that aren't
+// docker credentials are skipped.
+func listDockerCredentials(source credentialSource) (map[string]dockerCredential, error) {
+	secrets, err := fetchCredentialSecrets(source)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch the credentials [err=%v]", err)
+	}
+
+	credentials := map[string]dockerCredential{}
+	for _, secret := range secrets {
+		if !strings.HasPrefix(secret.Key, dockerCredentialSecretPrefix) {
+			continue
+		}
+
+		var credential dockerCredential
+		if err := json.Unmarshal([]byte(secret.Value), &credential); err != nil || credential.ServerURL == "" {
+			continue
+		}
+		credentials[secret.Key] = credential
+	}
+	return credentials, nil
+}
+
+func storeDockerCredential(source credentialSource, credential dockerCredential) error {
+	_, tokenDetails, projectId := newProjectHTTPClient(source.Token, source.ProjectId)
+
+	value, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	secret := models.SingleEnvironmentVariable{Key: dockerCredentialSecretName(credential.ServerURL), Value: string(value)}
+	_, approvals, err := util.PutRawSecrets([]models.SingleEnvironmentVariable{secret}, util.SECRET_TYPE_SHARED, source.Environment, source.SecretsPath, projectId, tokenDetails, util.SECRET_CONFLICT_OVERWRITE, false)
+	if err != nil {
+		return fmt.Errorf("unable to store the credentials of %s [err=%v]", credential.ServerURL, err)
+	}
+	if len(approvals) > 0 {
+		return fmt.Errorf("the credentials of %s are stored once change request %s is approved and merged", credential.ServerURL, approvals[0].ID)
+	}
+	return nil
+}
+
+func readDockerCredentialInput() string {
+	input, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		exitDockerCredentialHelper(fmt.Sprintf("unable to read stdin [err=%v]", err))
+	}
+	return strings.TrimSpace(string(input))
+}
+
+func printDockerCredentialOutput(value interface{}) {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		exitDockerCredentialHelper(fmt.Sprintf("unable to format the credentials [err=%v]", err))
+	}
+	fmt.Println(string(encoded))
+}
+
+// exitDockerCredentialHelper fails the helper the way docker expects, with the message on stdout
+func exitDockerCredentialHelper(message string) {
+	fmt.Println(message)
+	os.Exit(1)
+}
+
+func init() {
+	dockerCredentialCmd.Flags().String("token", "", "Use a service token or machine identity access token for the credentials")
+	dockerCredentialCmd.Flags().String("projectId", "", "the project of the credentials [can also set via environment variable name: INFISICAL_DOCKER_CREDENTIAL_PROJECT_ID]")
+	dockerCredentialCmd.Flags().StringP("env", "e", "", "the environment of the credentials. Defaults to the one of the project file, or dev [can also set via environment variable name: INFISICAL_DOCKER_CREDENTIAL_ENV]")
+	dockerCredentialCmd.Flags().String("path", "", "the folder of the credentials. Defaults to / [can also set via environment variable name: INFISICAL_DOCKER_CREDENTIAL_PATH]")
+	rootCmd.AddCommand(dockerCredentialCmd)
+
+	// docker runs the helper of "credsStore": "infisical" as docker-credential-infisical
+	credentialHelperExecutables["docker-credential-infisical"] = dockerCredentialCmd
+}
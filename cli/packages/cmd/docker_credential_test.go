@@ -0,0 +1,13 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDockerCredentialSecretName(t *testing.T) {
+	assert.Equal(t, "DOCKER_INDEX_DOCKER_IO_V1", dockerCredentialSecretName("https://index.docker.io/v1/"))
+	assert.Equal(t, "DOCKER_GHCR_IO", dockerCredentialSecretName("ghcr.io"))
+	assert.Equal(t, dockerCredentialSecretName("https://registry.example.com:5000/"), dockerCredentialSecretName("registry.example.com:5000"))
+}
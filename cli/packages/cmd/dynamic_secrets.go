@@ -4,17 +4,12 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
-	"context"
 	"fmt"
+	"time"
 
 	"github.com/Infisical/infisical-merge/packages/api"
-	"github.com/Infisical/infisical-merge/packages/config"
-	"github.com/Infisical/infisical-merge/packages/visualize"
-
-	// "github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
-	// "github.com/Infisical/infisical-merge/packages/visualize"
-	"github.com/go-resty/resty/v2"
+	"github.com/Infisical/infisical-merge/packages/visualize"
 	"github.com/posthog/posthog-go"
 	"github.com/spf13/cobra"
 
@@ -31,7 +26,16 @@ var dynamicSecretCmd = &cobra.Command{
 	Run:                   getDynamicSecretList,
 }
 
-func getDynamicSecretList(cmd *cobra.Command, args []string) {
+// dynamicSecretsTarget is an SDK client with the project, environment and folder of the dynamic secrets
+// the flags select
+type dynamicSecretsTarget struct {
+	client          infisicalSdk.InfisicalClientInterface
+	projectSlug     string
+	environmentName string
+	secretsPath     string
+}
+
+func getDynamicSecretsTarget(cmd *cobra.Command) dynamicSecretsTarget {
 	environmentName, _ := cmd.Flags().GetString("env")
 	if !cmd.Flags().Changed("env") {
 		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
@@ -40,7 +44,7 @@ func getDynamicSecretList(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	token, err := util.GetInfisicalToken(cmd)
+	token, err := getTokenOrLoginInCI(cmd)
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
@@ -56,7 +60,7 @@ func getDynamicSecretList(cmd *cobra.Command, args []string) {
 	}
 
 	var infisicalToken string
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 
 	if projectId == "" {
 		workspaceFile, err := util.GetWorkSpaceFromFile()
@@ -85,11 +89,7 @@ func getDynamicSecretList(cmd *cobra.Command, args []string) {
 
 	httpClient.SetAuthToken(infisicalToken)
 
-	infisicalClient := infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
-		SiteUrl:          config.INFISICAL_URL,
-		UserAgent:        api.USER_AGENT,
-		AutoTokenRefresh: false,
-	})
+	infisicalClient := newMachineIdentityLoginClient()
 	infisicalClient.Auth().SetAccessToken(infisicalToken)
 
 	projectDetails, err := api.CallGetProjectById(httpClient, projectId)
@@ -97,17 +97,31 @@ func getDynamicSecretList(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "To fetch project details")
 	}
 
-	dynamicSecretRootCredentials, err := infisicalClient.DynamicSecrets().List(infisicalSdk.ListDynamicSecretsRootCredentialsOptions{
-		ProjectSlug:     projectDetails.Slug,
-		SecretPath:      secretsPath,
-		EnvironmentSlug: environmentName,
+	return dynamicSecretsTarget{
+		client:          infisicalClient,
+		projectSlug:     projectDetails.Slug,
+		environmentName: environmentName,
+		secretsPath:     secretsPath,
+	}
+}
+
+func getDynamicSecretList(cmd *cobra.Command, args []string) {
+	outputFormat := getOutputFormat(cmd)
+	target := getDynamicSecretsTarget(cmd)
+
+	dynamicSecretRootCredentials, err := target.client.DynamicSecrets().List(infisicalSdk.ListDynamicSecretsRootCredentialsOptions{
+		ProjectSlug:     target.projectSlug,
+		SecretPath:      target.secretsPath,
+		EnvironmentSlug: target.environmentName,
 	})
 
 	if err != nil {
 		util.HandleError(err, "To fetch dynamic secret root credentials details")
 	}
 
-	visualize.PrintAllDynamicRootCredentials(dynamicSecretRootCredentials)
+	printOutput(outputFormat, toDynamicSecretsOutput(dynamicSecretRootCredentials), func() {
+		visualize.PrintAllDynamicRootCredentials(dynamicSecretRootCredentials)
+	})
 	Telemetry.CaptureEvent("cli-command:dynamic-secrets", posthog.NewProperties().Set("count", len(dynamicSecretRootCredentials)).Set("version", util.CLI_VERSION))
 }
 
@@ -119,7 +133,8 @@ var dynamicSecretLeaseCmd = &cobra.Command{
 }
 
 var dynamicSecretLeaseCreateCmd = &cobra.Command{
-	Example:               `lease create <dynamic secret name>"`,
+	Example: `lease create <dynamic secret name>
+lease create <dynamic secret name> --ttl 15m --output json`,
 	Short:                 "Used to lease dynamic secret by name",
 	Use:                   "create [dynamic-secret]",
 	DisableFlagsInUseLine: true,
@@ -130,98 +145,37 @@ var dynamicSecretLeaseCreateCmd = &cobra.Command{
 func createDynamicSecretLeaseByName(cmd *cobra.Command, args []string) {
 	dynamicSecretRootCredentialName := args[0]
 
-	environmentName, _ := cmd.Flags().GetString("env")
-	if !cmd.Flags().Changed("env") {
-		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
-		if environmentFromWorkspace != "" {
-			environmentName = environmentFromWorkspace
-		}
-	}
-
-	token, err := util.GetInfisicalToken(cmd)
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	projectId, err := cmd.Flags().GetString("projectId")
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
+	outputFormat := getOutputFormat(cmd)
 
 	ttl, err := cmd.Flags().GetString("ttl")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	secretsPath, err := cmd.Flags().GetString("path")
-	if err != nil {
-		util.HandleError(err, "Unable to parse path flag")
-	}
-
 	plainOutput, err := cmd.Flags().GetBool("plain")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	var infisicalToken string
-	httpClient := resty.New()
+	target := getDynamicSecretsTarget(cmd)
 
-	if projectId == "" {
-		workspaceFile, err := util.GetWorkSpaceFromFile()
-		if err != nil {
-			util.HandleError(err, "Unable to get local project details")
-		}
-		projectId = workspaceFile.WorkspaceId
-	}
-
-	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
-		infisicalToken = token.Token
-	} else {
-		util.RequireLogin()
-		util.RequireLocalWorkspaceFile()
-
-		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
-		if err != nil {
-			util.HandleError(err, "Unable to authenticate")
-		}
-
-		if loggedInUserDetails.LoginExpired {
-			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
-		}
-		infisicalToken = loggedInUserDetails.UserCredentials.JTWToken
-	}
-
-	httpClient.SetAuthToken(infisicalToken)
-
-	infisicalClient := infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
-		SiteUrl:          config.INFISICAL_URL,
-		UserAgent:        api.USER_AGENT,
-		AutoTokenRefresh: false,
-	})
-	infisicalClient.Auth().SetAccessToken(infisicalToken)
-
-	projectDetails, err := api.CallGetProjectById(httpClient, projectId)
-	if err != nil {
-		util.HandleError(err, "To fetch project details")
-	}
-
-	dynamicSecretRootCredential, err := infisicalClient.DynamicSecrets().GetByName(infisicalSdk.GetDynamicSecretRootCredentialByNameOptions{
+	dynamicSecretRootCredential, err := target.client.DynamicSecrets().GetByName(infisicalSdk.GetDynamicSecretRootCredentialByNameOptions{
 		DynamicSecretName: dynamicSecretRootCredentialName,
-		ProjectSlug:       projectDetails.Slug,
-		SecretPath:        secretsPath,
-		EnvironmentSlug:   environmentName,
+		ProjectSlug:       target.projectSlug,
+		SecretPath:        target.secretsPath,
+		EnvironmentSlug:   target.environmentName,
 	})
 
 	if err != nil {
 		util.HandleError(err, "To fetch dynamic secret root credentials details")
 	}
 
-	leaseCredentials, _, leaseDetails, err := infisicalClient.DynamicSecrets().Leases().Create(infisicalSdk.CreateDynamicSecretLeaseOptions{
+	leaseCredentials, _, leaseDetails, err := target.client.DynamicSecrets().Leases().Create(infisicalSdk.CreateDynamicSecretLeaseOptions{
 		DynamicSecretName: dynamicSecretRootCredential.Name,
-		ProjectSlug:       projectDetails.Slug,
+		ProjectSlug:       target.projectSlug,
 		TTL:               ttl,
-		SecretPath:        secretsPath,
-		EnvironmentSlug:   environmentName,
+		SecretPath:        target.secretsPath,
+		EnvironmentSlug:   target.environmentName,
 	})
 	if err != nil {
 		util.HandleError(err, "To lease dynamic secret")
@@ -234,19 +188,26 @@ func createDynamicSecretLeaseByName(cmd *cobra.Command, args []string) {
 			}
 		}
 	} else {
-		fmt.Println("Dynamic Secret Leasing")
-		fmt.Printf("Name: %s\n", dynamicSecretRootCredential.Name)
-		fmt.Printf("Provider: %s\n", dynamicSecretRootCredential.Type)
-		fmt.Printf("Lease ID: %s\n", leaseDetails.Id)
-		fmt.Printf("Expire At: %s\n", leaseDetails.ExpireAt.Local().Format("02-Jan-2006 03:04:05 PM"))
-		visualize.PrintAllDyamicSecretLeaseCredentials(leaseCredentials)
+		output := toDynamicSecretLeaseOutput(leaseDetails)
+		output.DynamicSecret = dynamicSecretRootCredential.Name
+		output.Type = dynamicSecretRootCredential.Type
+		output.Credentials = leaseCredentials
+
+		printOutput(outputFormat, output, func() {
+			fmt.Println("Dynamic Secret Leasing")
+			fmt.Printf("Name: %s\n", dynamicSecretRootCredential.Name)
+			fmt.Printf("Provider: %s\n", dynamicSecretRootCredential.Type)
+			fmt.Printf("Lease ID: %s\n", leaseDetails.Id)
+			fmt.Printf("Expire At: %s\n", leaseDetails.ExpireAt.Local().Format("02-Jan-2006 03:04:05 PM"))
+			visualize.PrintAllDyamicSecretLeaseCredentials(leaseCredentials)
+		})
 	}
 
 	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease", posthog.NewProperties().Set("type", dynamicSecretRootCredential.Type).Set("version", util.CLI_VERSION))
 }
 
 var dynamicSecretLeaseRenewCmd = &cobra.Command{
-	Example:               `lease renew <dynamic secret name>"`,
+	Example:               `lease renew <lease id> --ttl 1h`,
 	Short:                 "Used to renew dynamic secret lease by name",
 	Use:                   "renew [lease-id]",
 	DisableFlagsInUseLine: true,
@@ -257,196 +218,84 @@ var dynamicSecretLeaseRenewCmd = &cobra.Command{
 func renewDynamicSecretLeaseByName(cmd *cobra.Command, args []string) {
 	dynamicSecretLeaseId := args[0]
 
-	environmentName, _ := cmd.Flags().GetString("env")
-	if !cmd.Flags().Changed("env") {
-		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
-		if environmentFromWorkspace != "" {
-			environmentName = environmentFromWorkspace
-		}
-	}
-
-	token, err := util.GetInfisicalToken(cmd)
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	projectId, err := cmd.Flags().GetString("projectId")
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
+	outputFormat := getOutputFormat(cmd)
 
 	ttl, err := cmd.Flags().GetString("ttl")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
-	secretsPath, err := cmd.Flags().GetString("path")
-	if err != nil {
-		util.HandleError(err, "Unable to parse path flag")
-	}
-
-	var infisicalToken string
-	httpClient := resty.New()
+	target := getDynamicSecretsTarget(cmd)
 
-	if projectId == "" {
-		workspaceFile, err := util.GetWorkSpaceFromFile()
-		if err != nil {
-			util.HandleError(err, "Unable to get local project details")
-		}
-		projectId = workspaceFile.WorkspaceId
-	}
-
-	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
-		infisicalToken = token.Token
-	} else {
-		util.RequireLogin()
-		util.RequireLocalWorkspaceFile()
-
-		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
-		if err != nil {
-			util.HandleError(err, "Unable to authenticate")
-		}
-
-		if loggedInUserDetails.LoginExpired {
-			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
-		}
-		infisicalToken = loggedInUserDetails.UserCredentials.JTWToken
-	}
-
-	httpClient.SetAuthToken(infisicalToken)
-
-	infisicalClient := infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
-		SiteUrl:          config.INFISICAL_URL,
-		UserAgent:        api.USER_AGENT,
-		AutoTokenRefresh: false,
-	})
-	infisicalClient.Auth().SetAccessToken(infisicalToken)
-
-	projectDetails, err := api.CallGetProjectById(httpClient, projectId)
-	if err != nil {
-		util.HandleError(err, "To fetch project details")
-	}
-
-	if err != nil {
-		util.HandleError(err, "To fetch dynamic secret root credentials details")
-	}
-
-	leaseDetails, err := infisicalClient.DynamicSecrets().Leases().RenewById(infisicalSdk.RenewDynamicSecretLeaseOptions{
-		ProjectSlug:     projectDetails.Slug,
+	leaseDetails, err := target.client.DynamicSecrets().Leases().RenewById(infisicalSdk.RenewDynamicSecretLeaseOptions{
+		ProjectSlug:     target.projectSlug,
 		TTL:             ttl,
-		SecretPath:      secretsPath,
-		EnvironmentSlug: environmentName,
+		SecretPath:      target.secretsPath,
+		EnvironmentSlug: target.environmentName,
 		LeaseId:         dynamicSecretLeaseId,
 	})
 	if err != nil {
 		util.HandleError(err, "To renew dynamic secret lease")
 	}
 
-	fmt.Println("Successfully renewed dynamic secret lease")
-	visualize.PrintAllDynamicSecretLeases([]infisicalSdkModels.DynamicSecretLease{leaseDetails})
+	printOutput(outputFormat, toDynamicSecretLeaseOutput(leaseDetails), func() {
+		fmt.Println("Successfully renewed dynamic secret lease")
+		visualize.PrintAllDynamicSecretLeases([]infisicalSdkModels.DynamicSecretLease{leaseDetails})
+	})
 
 	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease renew", posthog.NewProperties().Set("version", util.CLI_VERSION))
 }
 
 var dynamicSecretLeaseRevokeCmd = &cobra.Command{
-	Example:               `lease delete <dynamic secret name>"`,
-	Short:                 "Used to delete dynamic secret lease by name",
-	Use:                   "delete [lease-id]",
+	Example: `lease revoke <lease id>
+lease revoke <lease id> <lease id>`,
+	Short:                 "Used to revoke dynamic secret leases by ID",
+	Use:                   "delete [lease-id...]",
+	Aliases:               []string{"revoke"},
 	DisableFlagsInUseLine: true,
-	Args:                  cobra.ExactArgs(1),
+	Args:                  cobra.MinimumNArgs(1),
 	Run:                   revokeDynamicSecretLeaseByName,
 }
 
+// revokeDynamicSecretLeaseByName revokes every lease it is given, even after one fails, so scripts
+// cleaning up their leases don't leave the others behind. It exits with 1 if one failed.
 func revokeDynamicSecretLeaseByName(cmd *cobra.Command, args []string) {
-	dynamicSecretLeaseId := args[0]
-
-	environmentName, _ := cmd.Flags().GetString("env")
-	if !cmd.Flags().Changed("env") {
-		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
-		if environmentFromWorkspace != "" {
-			environmentName = environmentFromWorkspace
-		}
-	}
-
-	token, err := util.GetInfisicalToken(cmd)
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	projectId, err := cmd.Flags().GetString("projectId")
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	secretsPath, err := cmd.Flags().GetString("path")
-	if err != nil {
-		util.HandleError(err, "Unable to parse path flag")
-	}
-
-	var infisicalToken string
-	httpClient := resty.New()
-
-	if projectId == "" {
-		workspaceFile, err := util.GetWorkSpaceFromFile()
+	outputFormat := getOutputFormat(cmd)
+	target := getDynamicSecretsTarget(cmd)
+
+	revokedLeases := []infisicalSdkModels.DynamicSecretLease{}
+	failed := false
+	for _, dynamicSecretLeaseId := range args {
+		leaseDetails, err := target.client.DynamicSecrets().Leases().DeleteById(infisicalSdk.DeleteDynamicSecretLeaseOptions{
+			ProjectSlug:     target.projectSlug,
+			SecretPath:      target.secretsPath,
+			EnvironmentSlug: target.environmentName,
+			LeaseId:         dynamicSecretLeaseId,
+		})
 		if err != nil {
-			util.HandleError(err, "Unable to get local project details")
+			util.PrintWarning(fmt.Sprintf("Unable to revoke the dynamic secret lease %s [err=%v]", dynamicSecretLeaseId, err))
+			failed = true
+			continue
 		}
-		projectId = workspaceFile.WorkspaceId
+		revokedLeases = append(revokedLeases, leaseDetails)
 	}
 
-	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
-		infisicalToken = token.Token
-	} else {
-		util.RequireLogin()
-		util.RequireLocalWorkspaceFile()
-
-		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
-		if err != nil {
-			util.HandleError(err, "Unable to authenticate")
-		}
-
-		if loggedInUserDetails.LoginExpired {
-			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	printOutput(outputFormat, toDynamicSecretLeasesOutput(revokedLeases), func() {
+		if len(revokedLeases) > 0 {
+			fmt.Println("Successfully revoked dynamic secret lease")
+			visualize.PrintAllDynamicSecretLeases(revokedLeases)
 		}
-		infisicalToken = loggedInUserDetails.UserCredentials.JTWToken
-	}
-
-	httpClient.SetAuthToken(infisicalToken)
-
-	infisicalClient := infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
-		SiteUrl:          config.INFISICAL_URL,
-		UserAgent:        api.USER_AGENT,
-		AutoTokenRefresh: false,
 	})
-	infisicalClient.Auth().SetAccessToken(infisicalToken)
-
-	projectDetails, err := api.CallGetProjectById(httpClient, projectId)
-	if err != nil {
-		util.HandleError(err, "To fetch project details")
-	}
 
-	if err != nil {
-		util.HandleError(err, "To fetch dynamic secret root credentials details")
-	}
+	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease revoke", posthog.NewProperties().Set("lease-count", len(args)).Set("version", util.CLI_VERSION))
 
-	leaseDetails, err := infisicalClient.DynamicSecrets().Leases().DeleteById(infisicalSdk.DeleteDynamicSecretLeaseOptions{
-		ProjectSlug:     projectDetails.Slug,
-		SecretPath:      secretsPath,
-		EnvironmentSlug: environmentName,
-		LeaseId:         dynamicSecretLeaseId,
-	})
-	if err != nil {
-		util.HandleError(err, "To revoke  dynamic secret lease")
+	if failed {
+		util.PrintErrorMessageAndExit()
 	}
-
-	fmt.Println("Successfully revoked dynamic secret lease")
-	visualize.PrintAllDynamicSecretLeases([]infisicalSdkModels.DynamicSecretLease{leaseDetails})
-
-	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease revoke", posthog.NewProperties().Set("version", util.CLI_VERSION))
 }
 
 var dynamicSecretLeaseListCmd = &cobra.Command{
-	Example:               `lease list <dynamic secret name>"`,
+	Example:               `lease list <dynamic secret name>`,
 	Short:                 "Used to list leases of a dynamic secret by name",
 	Use:                   "list [dynamic-secret]",
 	DisableFlagsInUseLine: true,
@@ -457,84 +306,70 @@ var dynamicSecretLeaseListCmd = &cobra.Command{
 func listDynamicSecretLeaseByName(cmd *cobra.Command, args []string) {
 	dynamicSecretRootCredentialName := args[0]
 
-	environmentName, _ := cmd.Flags().GetString("env")
-	if !cmd.Flags().Changed("env") {
-		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
-		if environmentFromWorkspace != "" {
-			environmentName = environmentFromWorkspace
-		}
-	}
+	outputFormat := getOutputFormat(cmd)
+	target := getDynamicSecretsTarget(cmd)
 
-	token, err := util.GetInfisicalToken(cmd)
-	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
+	dynamicSecretLeases, err := target.client.DynamicSecrets().Leases().List(infisicalSdk.ListDynamicSecretLeasesOptions{
+		DynamicSecretName: dynamicSecretRootCredentialName,
+		ProjectSlug:       target.projectSlug,
+		SecretPath:        target.secretsPath,
+		EnvironmentSlug:   target.environmentName,
+	})
 
-	projectId, err := cmd.Flags().GetString("projectId")
 	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
-	}
-
-	secretsPath, err := cmd.Flags().GetString("path")
-	if err != nil {
-		util.HandleError(err, "Unable to parse path flag")
-	}
-
-	var infisicalToken string
-	httpClient := resty.New()
-
-	if projectId == "" {
-		workspaceFile, err := util.GetWorkSpaceFromFile()
-		if err != nil {
-			util.HandleError(err, "Unable to get local project details")
-		}
-		projectId = workspaceFile.WorkspaceId
+		util.HandleError(err, "To fetch dynamic secret leases list")
 	}
 
-	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
-		infisicalToken = token.Token
-	} else {
-		util.RequireLogin()
-		util.RequireLocalWorkspaceFile()
-
-		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
-		if err != nil {
-			util.HandleError(err, "Unable to authenticate")
-		}
+	printOutput(outputFormat, toDynamicSecretLeasesOutput(dynamicSecretLeases), func() {
+		visualize.PrintAllDynamicSecretLeases(dynamicSecretLeases)
+	})
+	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease list", posthog.NewProperties().Set("lease-count", len(dynamicSecretLeases)).Set("version", util.CLI_VERSION))
+}
 
-		if loggedInUserDetails.LoginExpired {
-			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
-		}
-		infisicalToken = loggedInUserDetails.UserCredentials.JTWToken
-	}
+type dynamicSecretOutput struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	DefaultTTL string `json:"defaultTTL"`
+	MaxTTL     string `json:"maxTTL"`
+}
 
-	httpClient.SetAuthToken(infisicalToken)
+func toDynamicSecretsOutput(dynamicSecrets []infisicalSdkModels.DynamicSecret) []dynamicSecretOutput {
+	output := []dynamicSecretOutput{}
+	for _, dynamicSecret := range dynamicSecrets {
+		output = append(output, dynamicSecretOutput{
+			Name:       dynamicSecret.Name,
+			Type:       dynamicSecret.Type,
+			DefaultTTL: dynamicSecret.DefaultTTL,
+			MaxTTL:     dynamicSecret.MaxTTL,
+		})
+	}
+	return output
+}
 
-	infisicalClient := infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
-		SiteUrl:          config.INFISICAL_URL,
-		UserAgent:        api.USER_AGENT,
-		AutoTokenRefresh: false,
-	})
-	infisicalClient.Auth().SetAccessToken(infisicalToken)
+// dynamicSecretLeaseOutput is a lease, with the dynamic secret and the credentials when it was just created
+type dynamicSecretLeaseOutput struct {
+	Id            string         `json:"id"`
+	DynamicSecret string         `json:"dynamicSecret,omitempty"`
+	Type          string         `json:"type,omitempty"`
+	ExpireAt      time.Time      `json:"expireAt"`
+	CreatedAt     time.Time      `json:"createdAt"`
+	Credentials   map[string]any `json:"credentials,omitempty"`
+}
 
-	projectDetails, err := api.CallGetProjectById(httpClient, projectId)
-	if err != nil {
-		util.HandleError(err, "To fetch project details")
+func toDynamicSecretLeaseOutput(lease infisicalSdkModels.DynamicSecretLease) dynamicSecretLeaseOutput {
+	return dynamicSecretLeaseOutput{
+		Id:        lease.Id,
+		ExpireAt:  lease.ExpireAt,
+		CreatedAt: lease.CreatedAt,
 	}
+}
 
-	dynamicSecretLeases, err := infisicalClient.DynamicSecrets().Leases().List(infisicalSdk.ListDynamicSecretLeasesOptions{
-		DynamicSecretName: dynamicSecretRootCredentialName,
-		ProjectSlug:       projectDetails.Slug,
-		SecretPath:        secretsPath,
-		EnvironmentSlug:   environmentName,
-	})
-
-	if err != nil {
-		util.HandleError(err, "To fetch dynamic secret leases list")
+func toDynamicSecretLeasesOutput(leases []infisicalSdkModels.DynamicSecretLease) []dynamicSecretLeaseOutput {
+	output := []dynamicSecretLeaseOutput{}
+	for _, lease := range leases {
+		output = append(output, toDynamicSecretLeaseOutput(lease))
 	}
-
-	visualize.PrintAllDynamicSecretLeases(dynamicSecretLeases)
-	Telemetry.CaptureEvent("cli-command:dynamic-secrets lease list", posthog.NewProperties().Set("lease-count", len(dynamicSecretLeases)).Set("version", util.CLI_VERSION))
+	return output
 }
 
 func init() {
@@ -543,22 +378,26 @@ func init() {
 	dynamicSecretLeaseCreateCmd.Flags().String("projectId", "", "Manually set the projectId to fetch leased from when using machine identity based auth")
 	dynamicSecretLeaseCreateCmd.Flags().String("ttl", "", "The lease lifetime TTL. If not provided the default TTL of dynamic secret will be used.")
 	dynamicSecretLeaseCreateCmd.Flags().Bool("plain", false, "Print leased credentials without formatting, one per line")
+	addOutputFlag(dynamicSecretLeaseCreateCmd)
 	dynamicSecretLeaseCmd.AddCommand(dynamicSecretLeaseCreateCmd)
 
 	dynamicSecretLeaseListCmd.Flags().StringP("path", "p", "/", "The path from where dynamic secret should be leased from")
 	dynamicSecretLeaseListCmd.Flags().String("token", "", "Fetch dynamic secret leases machine identity access token")
 	dynamicSecretLeaseListCmd.Flags().String("projectId", "", "Manually set the projectId to fetch leased from when using machine identity based auth")
+	addOutputFlag(dynamicSecretLeaseListCmd)
 	dynamicSecretLeaseCmd.AddCommand(dynamicSecretLeaseListCmd)
 
 	dynamicSecretLeaseRenewCmd.Flags().StringP("path", "p", "/", "The path from where dynamic secret should be leased from")
 	dynamicSecretLeaseRenewCmd.Flags().String("token", "", "Renew dynamic secrets machine identity access token")
 	dynamicSecretLeaseRenewCmd.Flags().String("projectId", "", "Manually set the projectId to fetch leased from when using machine identity based auth")
 	dynamicSecretLeaseRenewCmd.Flags().String("ttl", "", "The lease lifetime TTL. If not provided the default TTL of dynamic secret will be used.")
+	addOutputFlag(dynamicSecretLeaseRenewCmd)
 	dynamicSecretLeaseCmd.AddCommand(dynamicSecretLeaseRenewCmd)
 
 	dynamicSecretLeaseRevokeCmd.Flags().StringP("path", "p", "/", "The path from where dynamic secret should be leased from")
 	dynamicSecretLeaseRevokeCmd.Flags().String("token", "", "Delete dynamic secrets using machine identity access token")
 	dynamicSecretLeaseRevokeCmd.Flags().String("projectId", "", "Manually set the projectId to fetch leased from when using machine identity based auth")
+	addOutputFlag(dynamicSecretLeaseRevokeCmd)
 	dynamicSecretLeaseCmd.AddCommand(dynamicSecretLeaseRevokeCmd)
 
 	dynamicSecretCmd.AddCommand(dynamicSecretLeaseCmd)
@@ -567,5 +406,6 @@ func init() {
 	dynamicSecretCmd.Flags().String("projectId", "", "Manually set the projectId to fetch dynamic-secret when using machine identity based auth")
 	dynamicSecretCmd.PersistentFlags().String("env", "dev", "Used to select the environment name on which actions should be taken on")
 	dynamicSecretCmd.Flags().String("path", "/", "get dynamic secret within a folder path")
+	addOutputFlag(dynamicSecretCmd)
 	rootCmd.AddCommand(dynamicSecretCmd)
 }
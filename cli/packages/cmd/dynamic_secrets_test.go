@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	infisicalSdkModels "github.com/infisical/go-sdk/packages/models"
+)
+
+func TestFormatDynamicSecretLeaseOutput(t *testing.T) {
+	expireAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	output := toDynamicSecretLeaseOutput(infisicalSdkModels.DynamicSecretLease{Id: "lease-1", ExpireAt: expireAt, CreatedAt: expireAt.Add(-time.Hour)})
+	output.DynamicSecret = "postgres"
+	output.Credentials = map[string]any{"DB_USERNAME": "user"}
+
+	formatted, err := formatOutput(OutputJson, output)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"id":"lease-1","dynamicSecret":"postgres","expireAt":"2024-05-01T12:00:00Z","createdAt":"2024-05-01T11:00:00Z","credentials":{"DB_USERNAME":"user"}}`, formatted)
+
+	formatted, err = formatOutput(OutputJson, toDynamicSecretLeasesOutput(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, "[]\n", formatted)
+}
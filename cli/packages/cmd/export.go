@@ -7,7 +7,9 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
 	"strings"
 
 	"github.com/Infisical/infisical-merge/packages/models"
@@ -23,14 +25,28 @@ const (
 	FormatCSV          string = "csv"
 	FormatYaml         string = "yaml"
 	FormatDotEnvExport string = "dotenv-export"
+	FormatToml         string = "toml"
+	FormatHcl          string = "hcl"
+	FormatSystemd      string = "systemd"
+	FormatKubernetes   string = "kubernetes-secret"
+	FormatDirenv       string = "direnv"
+	// FormatTerraformExternal is for the program of a Terraform external data source, which reads the
+	// query of the data source on stdin
+	FormatTerraformExternal string = "terraform-external"
 )
 
+var exportFormats = []string{FormatDotenv, FormatJson, FormatCSV, FormatYaml, FormatDotEnvExport, FormatToml, FormatHcl, FormatSystemd, FormatKubernetes, FormatDirenv, FormatTerraformExternal}
+
 // exportCmd represents the export command
 var exportCmd = &cobra.Command{
+	Example: `
+	infisical export --env=prod --format=json > secrets.json
+	infisical export --env=prod --template=application.properties.tmpl > application.properties
+	eval "$(infisical export --env=dev --format=direnv)"
+	echo '{"env":"prod","keys":"DB_PASSWORD"}' | infisical export --format=terraform-external`,
 	Use:                   "export",
 	Short:                 "Used to export environment variables to a file",
 	DisableFlagsInUseLine: true,
-	Example:               "infisical export --env=prod --format=json > secrets.json",
 	Args:                  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
 		environmentName, _ := cmd.Flags().GetString("env")
@@ -46,6 +62,11 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		expandLocally, err := cmd.Flags().GetBool("expand-locally")
+		if err != nil {
+			util.HandleError(err)
+		}
+
 		includeImports, err := cmd.Flags().GetBool("include-imports")
 		if err != nil {
 			util.HandleError(err)
@@ -56,9 +77,9 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
-		token, err := util.GetInfisicalToken(cmd)
+		token, err := getTokenOrLoginInCI(cmd)
 		if err != nil {
-			util.HandleError(err, "Unable to parse flag")
+			util.HandleError(err)
 		}
 
 		format, err := cmd.Flags().GetString("format")
@@ -76,23 +97,69 @@ var exportCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		noOverride, err := cmd.Flags().GetBool("no-override")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		tagsMatch, err := cmd.Flags().GetString("tags-match")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		secretsPath, err := cmd.Flags().GetString("path")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		secretName, err := cmd.Flags().GetString("secret-name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		namespace, err := cmd.Flags().GetString("namespace")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		// the query of a Terraform external data source sets what to export in place of the flags
+		secretKeys := []string{}
+		if strings.ToLower(format) == FormatTerraformExternal {
+			query, err := readTerraformExternalQuery(os.Stdin)
+			if err != nil {
+				util.HandleError(err, "Unable to read the query of the external data source")
+			}
+
+			if query.Environment != "" {
+				environmentName = query.Environment
+			}
+			if query.ProjectId != "" {
+				projectId = query.ProjectId
+			}
+			if query.SecretsPath != "" {
+				secretsPath = query.SecretsPath
+			}
+			if query.Tags != "" {
+				tagSlugs = query.Tags
+			}
+			secretKeys = query.Keys
+		}
+
 		request := models.GetAllSecretsParameters{
-			Environment:            environmentName,
-			TagSlugs:               tagSlugs,
-			WorkspaceId:            projectId,
-			SecretsPath:            secretsPath,
-			IncludeImport:          includeImports,
-			ExpandSecretReferences: shouldExpandSecrets,
+			Environment:                   environmentName,
+			TagSlugs:                      tagSlugs,
+			TagsMatch:                     tagsMatch,
+			ExcludePersonalSecrets:        noOverride,
+			WorkspaceId:                   projectId,
+			SecretsPath:                   secretsPath,
+			IncludeImport:                 includeImports,
+			ExpandSecretReferences:        shouldExpandSecrets,
+			ExpandSecretReferencesLocally: shouldExpandSecrets && expandLocally,
 		}
 
 		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
@@ -101,6 +168,21 @@ var exportCmd = &cobra.Command{
 			request.UniversalAuthAccessToken = token.Token
 		}
 
+		fetchSecrets := func() ([]models.SingleEnvironmentVariable, error) {
+			secrets, err := util.GetAllEnvironmentVariables(request, "")
+			if err != nil {
+				return nil, err
+			}
+
+			if secretOverriding {
+				secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+			} else {
+				secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+			}
+
+			return util.SortSecretsByKeys(secrets), nil
+		}
+
 		if templatePath != "" {
 			sigChan := make(chan os.Signal, 1)
 			dynamicSecretLeases := NewDynamicSecretLeaseManager(sigChan)
@@ -118,7 +200,7 @@ var exportCmd = &cobra.Command{
 				accessToken = loggedInUserDetails.UserCredentials.JTWToken
 			}
 
-			processedTemplate, err := ProcessTemplate(1, templatePath, nil, accessToken, "", &newEtag, dynamicSecretLeases)
+			processedTemplate, err := ProcessTemplate(1, templatePath, &exportTemplateData{fetchSecrets: fetchSecrets}, accessToken, "", &newEtag, dynamicSecretLeases, nil, nil)
 			if err != nil {
 				util.HandleError(err)
 			}
@@ -126,22 +208,21 @@ var exportCmd = &cobra.Command{
 			return
 		}
 
-		secrets, err := util.GetAllEnvironmentVariables(request, "")
+		secrets, err := fetchSecrets()
 		if err != nil {
 			util.HandleError(err, "Unable to fetch secrets")
 		}
 
-		if secretOverriding {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
-		} else {
-			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+		if len(secretKeys) > 0 {
+			secrets, err = selectSecretsByKeys(secrets, secretKeys)
+			if err != nil {
+				util.HandleError(err)
+			}
 		}
 
 		var output string
-		secrets = util.FilterSecretsByTag(secrets, tagSlugs)
-		secrets = util.SortSecretsByKeys(secrets)
 
-		output, err = formatEnvs(secrets, format)
+		output, err = formatEnvs(secrets, format, secretName, namespace)
 		if err != nil {
 			util.HandleError(err)
 		}
@@ -156,18 +237,60 @@ func init() {
 	rootCmd.AddCommand(exportCmd)
 	exportCmd.Flags().StringP("env", "e", "dev", "Set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	exportCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets")
-	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file (dotenv, json, csv)")
+	exportCmd.Flags().Bool("expand-locally", false, "resolve secret references in the CLI instead of on the server, for self-hosted instances that return them unexpanded")
+	exportCmd.Flags().StringP("format", "f", "dotenv", "Set the format of the output file ("+strings.Join(exportFormats, ", ")+")")
 	exportCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
+	exportCmd.Flags().Bool("no-override", false, "ignore your personal overrides and use the shared values of your team")
 	exportCmd.Flags().Bool("include-imports", true, "Imported linked secrets")
 	exportCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	exportCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs")
+	exportCmd.Flags().String("tags-match", util.TAGS_MATCH_ANY, "with --tags, whether secrets need any or all of the tags (any, all)")
 	exportCmd.Flags().String("projectId", "", "manually set the projectId to export secrets from")
 	exportCmd.Flags().String("path", "/", "get secrets within a folder path")
-	exportCmd.Flags().String("template", "", "The path to the template file used to render secrets")
+	exportCmd.Flags().String("template", "", "The path to a Go template to render instead of a format. It has the agent's template functions, and the secrets selected by the other flags as {{ .Secrets }} and {{ .Secret \"KEY\" }}")
+	exportCmd.Flags().String("secret-name", "infisical-secrets", "The name of the Secret when exporting with --format=kubernetes-secret")
+	exportCmd.Flags().String("namespace", "", "The namespace of the Secret when exporting with --format=kubernetes-secret, defaults to the namespace it's applied to")
+}
+
+// exportTemplateData is what templates rendered by export see as their data. The secrets selected
+// by the flags are fetched the first time the template uses them, so templates that only use the
+// template functions don't need access to them.
+type exportTemplateData struct {
+	fetchSecrets func() ([]models.SingleEnvironmentVariable, error)
+	secrets      []models.SingleEnvironmentVariable
+	fetched      bool
+}
+
+// Secrets returns the secrets selected by the flags, sorted by key
+func (d *exportTemplateData) Secrets() ([]models.SingleEnvironmentVariable, error) {
+	if !d.fetched {
+		secrets, err := d.fetchSecrets()
+		if err != nil {
+			return nil, err
+		}
+		d.secrets = secrets
+		d.fetched = true
+	}
+	return d.secrets, nil
+}
+
+// Secret returns the value of one of the secrets selected by the flags
+func (d *exportTemplateData) Secret(key string) (string, error) {
+	secrets, err := d.Secrets()
+	if err != nil {
+		return "", err
+	}
+
+	for _, secret := range secrets {
+		if secret.Key == key {
+			return secret.Value, nil
+		}
+	}
+	return "", fmt.Errorf("secret %s not found", key)
 }
 
 // Format according to the format flag
-func formatEnvs(envs []models.SingleEnvironmentVariable, format string) (string, error) {
+func formatEnvs(envs []models.SingleEnvironmentVariable, format string, secretName string, namespace string) (string, error) {
 	switch strings.ToLower(format) {
 	case FormatDotenv:
 		return formatAsDotEnv(envs), nil
@@ -179,8 +302,20 @@ func formatEnvs(envs []models.SingleEnvironmentVariable, format string) (string,
 		return formatAsCSV(envs), nil
 	case FormatYaml:
 		return formatAsYaml(envs)
+	case FormatToml:
+		return formatAsToml(envs), nil
+	case FormatHcl:
+		return formatAsHcl(envs)
+	case FormatSystemd:
+		return formatAsSystemdEnvironmentFile(envs)
+	case FormatKubernetes:
+		return formatAsKubernetesSecret(envs, secretName, namespace)
+	case FormatDirenv:
+		return formatAsDirenv(envs)
+	case FormatTerraformExternal:
+		return formatAsTerraformExternal(envs)
 	default:
-		return "", fmt.Errorf("invalid format type: %s. Available format types are [%s]", format, []string{FormatDotenv, FormatJson, FormatCSV, FormatYaml, FormatDotEnvExport})
+		return "", fmt.Errorf("invalid format type: %s. Available format types are %s", format, exportFormats)
 	}
 }
 
@@ -238,3 +373,196 @@ func formatAsJson(envs []models.SingleEnvironmentVariable) string {
 	}
 	return string(json)
 }
+
+var (
+	tomlBareKeyRegex     = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	hclIdentifierRegex   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+	envVariableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// Format environment variables as TOML key/value pairs
+func formatAsToml(envs []models.SingleEnvironmentVariable) string {
+	var toml strings.Builder
+	for _, env := range envs {
+		key := env.Key
+		if !tomlBareKeyRegex.MatchString(key) {
+			key = quoteTomlString(key)
+		}
+		fmt.Fprintf(&toml, "%s = %s\n", key, quoteTomlString(env.Value))
+	}
+	return toml.String()
+}
+
+// quoteTomlString writes value as a TOML basic string, escaping control characters
+func quoteTomlString(value string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '"':
+			quoted.WriteString(`\"`)
+		case '\\':
+			quoted.WriteString(`\\`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		case '\t':
+			quoted.WriteString(`\t`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&quoted, `\u%04X`, r)
+			} else {
+				quoted.WriteRune(r)
+			}
+		}
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// Format environment variables as HCL attributes, usable as a Terraform .tfvars file or in a Nomad
+// env block. Template sequences are escaped so values are taken literally.
+func formatAsHcl(envs []models.SingleEnvironmentVariable) (string, error) {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, "${", "$${", "%{", "%%{")
+
+	var hcl strings.Builder
+	for _, env := range envs {
+		if !hclIdentifierRegex.MatchString(env.Key) {
+			return "", fmt.Errorf("secret %s is not a valid HCL attribute name", env.Key)
+		}
+		fmt.Fprintf(&hcl, "%s = \"%s\"\n", env.Key, escaper.Replace(env.Value))
+	}
+	return hcl.String(), nil
+}
+
+// Format environment variables for the EnvironmentFile= option of systemd units. Values are double
+// quoted, which keeps line breaks, with the characters systemd unescapes in them escaped.
+func formatAsSystemdEnvironmentFile(envs []models.SingleEnvironmentVariable) (string, error) {
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`", "$", `\$`)
+
+	var environmentFile strings.Builder
+	for _, env := range envs {
+		if !envVariableNameRegex.MatchString(env.Key) {
+			return "", fmt.Errorf("secret %s is not a valid environment variable name for systemd", env.Key)
+		}
+		fmt.Fprintf(&environmentFile, "%s=\"%s\"\n", env.Key, escaper.Replace(env.Value))
+	}
+	return environmentFile.String(), nil
+}
+
+// Format environment variables as exports for a direnv .envrc. Values are single quoted so the shell
+// takes them literally, whatever they contain.
+func formatAsDirenv(envs []models.SingleEnvironmentVariable) (string, error) {
+	var envrc strings.Builder
+	for _, env := range envs {
+		if !envVariableNameRegex.MatchString(env.Key) {
+			return "", fmt.Errorf("secret %s is not a valid environment variable name for direnv", env.Key)
+		}
+		fmt.Fprintf(&envrc, "export %s=%s\n", env.Key, shellQuote(env.Value))
+	}
+	return envrc.String(), nil
+}
+
+// terraformExternalQuery is the query of a Terraform external data source that runs export. All of
+// its values are strings, as Terraform sends them, so keys is a comma separated list.
+type terraformExternalQuery struct {
+	Environment string
+	ProjectId   string
+	SecretsPath string
+	Tags        string
+	Keys        []string
+}
+
+// readTerraformExternalQuery reads the query Terraform passes on stdin. Unknown keys are rejected so
+// typos don't go unnoticed.
+func readTerraformExternalQuery(input io.Reader) (terraformExternalQuery, error) {
+	query := terraformExternalQuery{}
+
+	content, err := io.ReadAll(input)
+	if err != nil {
+		return query, err
+	}
+	if strings.TrimSpace(string(content)) == "" {
+		return query, nil
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal(content, &values); err != nil {
+		return query, fmt.Errorf("the query must be an object of strings: %w", err)
+	}
+
+	for key, value := range values {
+		switch key {
+		case "env":
+			query.Environment = value
+		case "projectId":
+			query.ProjectId = value
+		case "path":
+			query.SecretsPath = value
+		case "tags":
+			query.Tags = value
+		case "keys":
+			for _, secretKey := range strings.Split(value, ",") {
+				if secretKey = strings.TrimSpace(secretKey); secretKey != "" {
+					query.Keys = append(query.Keys, secretKey)
+				}
+			}
+		default:
+			return query, fmt.Errorf("unknown query key %s, expected env, projectId, path, tags or keys", key)
+		}
+	}
+	return query, nil
+}
+
+// selectSecretsByKeys returns the secrets with the keys, failing when one of them is missing
+func selectSecretsByKeys(secrets []models.SingleEnvironmentVariable, keys []string) ([]models.SingleEnvironmentVariable, error) {
+	secretsByKey := make(map[string]models.SingleEnvironmentVariable, len(secrets))
+	for _, secret := range secrets {
+		secretsByKey[secret.Key] = secret
+	}
+
+	selected := []models.SingleEnvironmentVariable{}
+	for _, key := range keys {
+		secret, found := secretsByKey[key]
+		if !found {
+			return nil, fmt.Errorf("secret %s not found", key)
+		}
+		selected = append(selected, secret)
+	}
+	return util.SortSecretsByKeys(selected), nil
+}
+
+// Format environment variables as the object of strings a Terraform external data source expects as
+// its result
+func formatAsTerraformExternal(envs []models.SingleEnvironmentVariable) (string, error) {
+	result := make(map[string]string, len(envs))
+	for _, env := range envs {
+		result[env.Key] = env.Value
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return "", fmt.Errorf("failed to format environment variables for Terraform: %w", err)
+	}
+	return string(encoded) + "\n", nil
+}
+
+// Format environment variables as a Kubernetes Secret manifest ready for kubectl apply
+func formatAsKubernetesSecret(envs []models.SingleEnvironmentVariable, name string, namespace string) (string, error) {
+	if name == "" {
+		return "", fmt.Errorf("a Secret name is required, set it with --secret-name")
+	}
+
+	values := map[string]string{}
+	for _, env := range envs {
+		values[env.Key] = env.Value
+	}
+
+	secret := (&KubernetesDestination{Kind: "Secret", Name: name}).object(namespace, values)
+	manifest, err := yaml.Marshal(secret)
+	if err != nil {
+		return "", fmt.Errorf("failed to format environment variables as a Kubernetes Secret: %w", err)
+	}
+	return string(manifest), nil
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/Infisical/infisical-merge/packages/models"
@@ -77,3 +80,122 @@ func TestFormatAsYaml(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatAsToml(t *testing.T) {
+	input := []models.SingleEnvironmentVariable{
+		{Key: "KEY1", Value: "VALUE1"},
+		{Key: "KEY.2", Value: "Value \"with\" quotes\nand\\backslash"},
+	}
+
+	expected := "KEY1 = \"VALUE1\"\n\"KEY.2\" = \"Value \\\"with\\\" quotes\\nand\\\\backslash\"\n"
+	assert.Equal(t, expected, formatAsToml(input))
+}
+
+func TestFormatAsHcl(t *testing.T) {
+	result, err := formatAsHcl([]models.SingleEnvironmentVariable{
+		{Key: "KEY1", Value: "VALUE1"},
+		{Key: "KEY2", Value: "${var.not_interpolated} %{if true}\n"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "KEY1 = \"VALUE1\"\nKEY2 = \"$${var.not_interpolated} %%{if true}\\n\"\n", result)
+
+	_, err = formatAsHcl([]models.SingleEnvironmentVariable{{Key: "1KEY", Value: "VALUE"}})
+	assert.Error(t, err)
+}
+
+func TestFormatAsSystemdEnvironmentFile(t *testing.T) {
+	result, err := formatAsSystemdEnvironmentFile([]models.SingleEnvironmentVariable{
+		{Key: "KEY1", Value: "VALUE1"},
+		{Key: "KEY2", Value: "$HOME \"quoted\" `cmd`\nsecond line"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "KEY1=\"VALUE1\"\nKEY2=\"\\$HOME \\\"quoted\\\" \\`cmd\\`\nsecond line\"\n", result)
+
+	_, err = formatAsSystemdEnvironmentFile([]models.SingleEnvironmentVariable{{Key: "KEY-1", Value: "VALUE"}})
+	assert.Error(t, err)
+}
+
+func TestFormatAsDirenv(t *testing.T) {
+	result, err := formatAsDirenv([]models.SingleEnvironmentVariable{
+		{Key: "KEY1", Value: "VALUE1"},
+		{Key: "KEY2", Value: "it's $HOME\nsecond line"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "export KEY1='VALUE1'\nexport KEY2='it'\\''s $HOME\nsecond line'\n", result)
+
+	_, err = formatAsDirenv([]models.SingleEnvironmentVariable{{Key: "KEY-1", Value: "VALUE"}})
+	assert.Error(t, err)
+}
+
+func TestReadTerraformExternalQuery(t *testing.T) {
+	query, err := readTerraformExternalQuery(strings.NewReader(`{"env":"prod","path":"/db","keys":"DB_USER, DB_PASSWORD"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, terraformExternalQuery{Environment: "prod", SecretsPath: "/db", Keys: []string{"DB_USER", "DB_PASSWORD"}}, query)
+
+	_, err = readTerraformExternalQuery(strings.NewReader(`{"environment":"prod"}`))
+	assert.Error(t, err)
+}
+
+func TestFormatAsTerraformExternal(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "DB_USER", Value: "admin"},
+		{Key: "DB_PASSWORD", Value: "p\"ss"},
+		{Key: "OTHER", Value: "value"},
+	}
+
+	selected, err := selectSecretsByKeys(secrets, []string{"DB_USER", "DB_PASSWORD"})
+	assert.NoError(t, err)
+
+	result, err := formatAsTerraformExternal(selected)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"DB_PASSWORD":"p\"ss","DB_USER":"admin"}`+"\n", result)
+
+	_, err = selectSecretsByKeys(secrets, []string{"MISSING"})
+	assert.Error(t, err)
+}
+
+func TestFormatAsKubernetesSecret(t *testing.T) {
+	result, err := formatAsKubernetesSecret([]models.SingleEnvironmentVariable{
+		{Key: "KEY1", Value: "VALUE1"},
+	}, "app-secrets", "production")
+	assert.NoError(t, err)
+
+	var secret struct {
+		APIVersion string            `yaml:"apiVersion"`
+		Kind       string            `yaml:"kind"`
+		Type       string            `yaml:"type"`
+		Metadata   map[string]string `yaml:"metadata"`
+		Data       map[string]string `yaml:"data"`
+	}
+	assert.NoError(t, yaml.Unmarshal([]byte(result), &secret))
+
+	assert.Equal(t, "v1", secret.APIVersion)
+	assert.Equal(t, "Secret", secret.Kind)
+	assert.Equal(t, "Opaque", secret.Type)
+	assert.Equal(t, map[string]string{"name": "app-secrets", "namespace": "production"}, secret.Metadata)
+	assert.Equal(t, map[string]string{"KEY1": "VkFMVUUx"}, secret.Data)
+}
+
+func TestExportTemplateData(t *testing.T) {
+	templatePath := filepath.Join(t.TempDir(), "application.properties.tmpl")
+	err := os.WriteFile(templatePath, []byte(`{{ range .Secrets }}{{ .Key }}={{ .Value }}
+{{ end }}db.url={{ .Secret "DB_URL" }}`), 0600)
+	assert.NoError(t, err)
+
+	fetches := 0
+	data := &exportTemplateData{fetchSecrets: func() ([]models.SingleEnvironmentVariable, error) {
+		fetches++
+		return []models.SingleEnvironmentVariable{
+			{Key: "DB_URL", Value: "postgres://db"},
+			{Key: "PORT", Value: "8080"},
+		}, nil
+	}}
+
+	result, err := ProcessTemplate(1, templatePath, data, "", "", new(string), nil, nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "DB_URL=postgres://db\nPORT=8080\ndb.url=postgres://db", result.String())
+	assert.Equal(t, 1, fetches)
+
+	_, err = data.Secret("MISSING")
+	assert.Error(t, err)
+}
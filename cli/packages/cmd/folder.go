@@ -47,6 +47,8 @@ var getCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		outputFormat := getOutputFormat(cmd)
+
 		request := models.GetAllFoldersParameters{
 			Environment: environmentName,
 			WorkspaceId: projectId,
@@ -64,7 +66,9 @@ var getCmd = &cobra.Command{
 			util.HandleError(err, "Unable to get folders")
 		}
 
-		visualize.PrintAllFoldersDetails(folders, foldersPath)
+		printOutput(outputFormat, toFoldersOutput(folders, foldersPath), func() {
+			visualize.PrintAllFoldersDetails(folders, foldersPath)
+		})
 		Telemetry.CaptureEvent("cli-command:folders get", posthog.NewProperties().Set("folderCount", len(folders)).Set("version", util.CLI_VERSION))
 	},
 }
@@ -207,3 +211,17 @@ var deleteCmd = &cobra.Command{
 		Telemetry.CaptureEvent("cli-command:folders delete", posthog.NewProperties().Set("version", util.CLI_VERSION))
 	},
 }
+
+type folderOutput struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+func toFoldersOutput(folders []models.SingleFolder, foldersPath string) []folderOutput {
+	output := []folderOutput{}
+	for _, folder := range folders {
+		output = append(output, folderOutput{ID: folder.ID, Name: folder.Name, Path: foldersPath})
+	}
+	return output
+}
@@ -6,17 +6,25 @@ import (
 	// "github.com/Infisical/infisical-merge/packages/api"
 	// "github.com/Infisical/infisical-merge/packages/models"
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"os/exec"
 	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/gateway"
 	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
 	"github.com/rs/zerolog/log"
 
-	// "github.com/Infisical/infisical-merge/packages/visualize"
 	// "github.com/rs/zerolog/log"
 
 	// "github.com/go-resty/resty/v2"
@@ -31,16 +39,31 @@ var gatewayCmd = &cobra.Command{
 	DisableFlagsInUseLine: true,
 	Args:                  cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		token, err := util.GetInfisicalToken(cmd)
+		configPath, err := cmd.Flags().GetString("config")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		if token == nil {
-			util.HandleError(fmt.Errorf("Token not found"))
+		var gatewayConfigs []gatewayIdentityConfig
+		if configPath != "" {
+			gatewayConfigs, err = readGatewaysConfig(configPath)
+			if err != nil {
+				util.HandleError(err, "Unable to read the gateways config")
+			}
+		} else {
+			token, err := util.GetInfisicalToken(cmd)
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+
+			if token == nil {
+				util.HandleError(fmt.Errorf("Token not found"))
+			}
+
+			gatewayConfigs = []gatewayIdentityConfig{{token: token.Token}}
 		}
 
-		Telemetry.CaptureEvent("cli-command:gateway", posthog.NewProperties().Set("version", util.CLI_VERSION))
+		Telemetry.CaptureEvent("cli-command:gateway", posthog.NewProperties().Set("gateways", len(gatewayConfigs)).Set("version", util.CLI_VERSION))
 
 		sigCh := make(chan os.Signal, 1)
 		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -60,61 +83,615 @@ var gatewayCmd = &cobra.Command{
 			os.Exit(1)
 		}()
 
-		// Main gateway retry loop with proper context handling
-		retryTicker := time.NewTicker(5 * time.Second)
-		defer retryTicker.Stop()
+		sharedOptions := getGatewayOptions(cmd)
 
-		for {
-			if ctx.Err() != nil {
-				log.Info().Msg("Shutting down gateway")
-				return
+		// every gateway runs on its own: a gateway whose token can't be renewed stops without the others,
+		// and the process exits once none is left. A gateway is counted before it starts, as its token
+		// renewal can fail right away, and the startup holds a count until every gateway has started.
+		var activeGateways atomic.Int32
+		gatewayStopped := func() {
+			if activeGateways.Add(-1) == 0 {
+				cancel()
 			}
-			gatewayInstance, err := gateway.NewGateway(token.Token)
+		}
+		activeGateways.Add(1)
+		gatewayRuns := []*gatewayRun{}
+		for _, gatewayConfig := range gatewayConfigs {
+			activeGateways.Add(1)
+			run, err := startGatewayRun(ctx, gatewayConfig, sharedOptions, gatewayStopped)
 			if err != nil {
+				activeGateways.Add(-1)
+				cancel()
+				stopGatewayRuns(gatewayRuns)
 				util.HandleError(err)
 			}
+			gatewayRuns = append(gatewayRuns, run)
+		}
+		gatewayStopped()
 
-			if err = gatewayInstance.ConnectWithRelay(); err != nil {
-				if ctx.Err() != nil {
-					log.Info().Msg("Shutting down gateway")
-					return
-				}
-
-				log.Error().Msgf("Gateway connection error with relay: %s", err)
-				log.Info().Msg("Retrying connection in 5 seconds...")
-				select {
-				case <-retryTicker.C:
-					continue
-				case <-ctx.Done():
-					log.Info().Msg("Shutting down gateway")
-					return
-				}
+		gatewayInstances := []*gateway.Gateway{}
+		for _, run := range gatewayRuns {
+			gatewayInstances = append(gatewayInstances, run.instance)
+		}
+
+		maintenanceDuration, err := cmd.Flags().GetDuration("maintenance-duration")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		adminSocket, err := cmd.Flags().GetString("admin-socket")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if adminSocket != "" {
+			if err := serveGatewayAdminSocket(ctx, adminSocket, gatewayInstances, maintenanceDuration); err != nil {
+				cancel()
+				stopGatewayRuns(gatewayRuns)
+				util.HandleError(err, "Unable to listen on the admin socket")
 			}
+		}
+
+		<-ctx.Done()
+		stopGatewayRuns(gatewayRuns)
 
-			err = gatewayInstance.Listen(ctx)
-			if ctx.Err() != nil {
-				log.Info().Msg("Gateway shutdown complete")
-				return
+		for _, run := range gatewayRuns {
+			if run.tokenRenewalFailed.Load() {
+				os.Exit(1)
 			}
-			log.Error().Msgf("Gateway listen error: %s", err)
-			log.Info().Msg("Retrying connection in 5 seconds...")
-			select {
-			case <-retryTicker.C:
-				continue
-			case <-ctx.Done():
-				log.Info().Msg("Shutting down gateway")
-				return
+		}
+	},
+}
+
+// gatewayRun is a gateway of the gateway command, with the renewal of its token. Each has a circuit
+// breaker of its own, so the API failures of one identity don't pause the others.
+type gatewayRun struct {
+	instance           *gateway.Gateway
+	cancel             context.CancelFunc
+	tokenRenewalFailed atomic.Bool
+}
+
+// startGatewayRun starts the gateway of an identity. It renews the token for as long as the gateway runs,
+// and stops the gateway once it can't be renewed, calling onTokenRenewalFailed, so that a supervisor
+// restarts it with new credentials.
+func startGatewayRun(ctx context.Context, gatewayConfig gatewayIdentityConfig, sharedOptions []gateway.Option, onTokenRenewalFailed func()) (*gatewayRun, error) {
+	logger := log.Logger
+	if gatewayConfig.Name != "" {
+		logger = log.With().Str("gateway", gatewayConfig.Name).Logger()
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	run := &gatewayRun{cancel: cancel}
+
+	tokenRenewer := util.NewAccessTokenRenewer(gatewayConfig.token, nil)
+	gatewayOptions := []gateway.Option{
+		gateway.WithIdentityTokenSource(tokenRenewer.Token),
+		gateway.WithLogger(gateway.NewZerologLogger(logger)),
+		gateway.WithCircuitBreaker(api.NewCircuitBreaker(api.DefaultCircuitBreaker.FailureThreshold, api.DefaultCircuitBreaker.OpenDuration)),
+	}
+	gatewayOptions = append(gatewayOptions, sharedOptions...)
+	gatewayOptions = append(gatewayOptions, gatewayConfig.options()...)
+
+	var err error
+	run.instance, err = gateway.New(gatewayOptions...)
+	if err == nil {
+		err = run.instance.Start(runCtx)
+	}
+	if err != nil {
+		cancel()
+		if gatewayConfig.Name != "" {
+			err = fmt.Errorf("gateway %s: %w", gatewayConfig.Name, err)
+		}
+		return nil, err
+	}
+
+	go func() {
+		if err := tokenRenewer.Run(runCtx); err != nil {
+			logger.Error().Err(err).Msg("Stopping the gateway")
+			run.tokenRenewalFailed.Store(true)
+			cancel()
+			onTokenRenewalFailed()
+		}
+	}()
+
+	return run, nil
+}
+
+// stopGatewayRuns stops the gateways and waits for them to drain their connections
+func stopGatewayRuns(gatewayRuns []*gatewayRun) {
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer stopCancel()
+
+	for _, run := range gatewayRuns {
+		if err := run.instance.Stop(stopCtx); err != nil {
+			log.Warn().Msgf("Gateway did not shut down cleanly: %s", err)
+		}
+		run.cancel()
+	}
+}
+
+// getGatewayOptions returns the options of the gateway flags, shared by every gateway of --config
+func getGatewayOptions(cmd *cobra.Command) []gateway.Option {
+	gatewayOptions := []gateway.Option{gateway.WithRegistrationCache(getGatewayRegistrationCacheDir())}
+
+	targetCACertPath, err := cmd.Flags().GetString("target-ca-cert")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if targetCACertPath != "" {
+		caCertPEM, err := os.ReadFile(targetCACertPath)
+		if err != nil {
+			util.HandleError(err, "Unable to read target CA certificate")
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCertPEM) {
+			util.HandleError(fmt.Errorf("no valid certificates found in %s", targetCACertPath))
+		}
+
+		gatewayOptions = append(gatewayOptions, gateway.WithTargetTLSConfig(&tls.Config{
+			RootCAs:    caCertPool,
+			MinVersion: tls.VersionTLS12,
+		}))
+	}
+
+	if relayOverride := getRelayOverride(cmd); relayOverride != nil {
+		gatewayOptions = append(gatewayOptions, gateway.WithRelayOverride(*relayOverride))
+	}
+
+	apiTimeout, err := cmd.Flags().GetDuration("api-timeout")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	gatewayOptions = append(gatewayOptions, gateway.WithAPITimeout(apiTimeout))
+
+	heartbeatFailureThreshold, err := cmd.Flags().GetInt("heartbeat-failure-threshold")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	heartbeatRetryInterval, err := cmd.Flags().GetDuration("heartbeat-retry-interval")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	gatewayOptions = append(gatewayOptions, gateway.WithHeartbeatFailureThreshold(heartbeatFailureThreshold, heartbeatRetryInterval))
+
+	staticIps, err := cmd.Flags().GetStringSlice("static-ips")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if len(staticIps) > 0 {
+		gatewayOptions = append(gatewayOptions, gateway.WithStaticIps(staticIps...))
+	}
+
+	connectionBufferSize, err := cmd.Flags().GetInt("connection-buffer-size")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	maxBufferedBytes, err := cmd.Flags().GetInt64("max-buffered-bytes")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	gatewayOptions = append(gatewayOptions, gateway.WithBufferLimits(connectionBufferSize, maxBufferedBytes))
+
+	sessionRecordingDir, err := cmd.Flags().GetString("session-recording-dir")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if sessionRecordingDir != "" {
+		gatewayOptions = append(gatewayOptions, gateway.WithSessionRecording(getSessionRecordingConfig(cmd, sessionRecordingDir)))
+	}
+
+	targetResolver, err := cmd.Flags().GetString("target-resolver")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if targetResolver != "" {
+		gatewayOptions = append(gatewayOptions, gateway.WithTargetResolver(targetResolver))
+	}
+
+	sessionReauthorizationInterval, err := cmd.Flags().GetDuration("session-reauthorization-interval")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	gatewayOptions = append(gatewayOptions, gateway.WithSessionReauthorization(sessionReauthorizationInterval))
+
+	standby, err := cmd.Flags().GetBool("standby")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if standby {
+		if adminSocket, _ := cmd.Flags().GetString("admin-socket"); adminSocket == "" {
+			util.PrintErrorMessageAndExit("--standby requires --admin-socket, which is used to promote the gateway")
+		}
+		gatewayOptions = append(gatewayOptions, gateway.WithStandby())
+	}
+
+	return gatewayOptions
+}
+
+var gatewayBenchCmd = &cobra.Command{
+	Example:               `infisical gateway bench --connections=200 --concurrency=20 --payload-size=1048576`,
+	Short:                 "Measure relay throughput and connection latency for this gateway",
+	Use:                   "bench",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token == nil {
+			util.HandleError(fmt.Errorf("Token not found"))
+		}
+
+		connections, err := cmd.Flags().GetInt("connections")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		concurrency, err := cmd.Flags().GetInt("concurrency")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		payloadSize, err := cmd.Flags().GetInt("payload-size")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		chunkSize, err := cmd.Flags().GetInt("chunk-size")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat := getOutputFormat(cmd)
+
+		Telemetry.CaptureEvent("cli-command:gateway bench", posthog.NewProperties().Set("version", util.CLI_VERSION))
+
+		ctx, cancel := signal.NotifyContext(cmd.Context(), syscall.SIGINT, syscall.SIGTERM)
+		defer cancel()
+
+		gatewayOptions := []gateway.Option{
+			gateway.WithIdentityToken(token.Token),
+			gateway.WithLogger(gateway.NewZerologLogger(log.Logger)),
+			gateway.WithRegistrationCache(getGatewayRegistrationCacheDir()),
+		}
+
+		if relayOverride := getRelayOverride(cmd); relayOverride != nil {
+			gatewayOptions = append(gatewayOptions, gateway.WithRelayOverride(*relayOverride))
+		}
+
+		gatewayInstance, err := gateway.New(gatewayOptions...)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		result, err := gatewayInstance.Bench(ctx, gateway.BenchOptions{
+			Connections: connections,
+			Concurrency: concurrency,
+			PayloadSize: payloadSize,
+			ChunkSize:   chunkSize,
+		})
+		if err != nil && result == nil {
+			util.HandleError(err, "Gateway benchmark failed")
+		}
+		if err != nil {
+			log.Warn().Msgf("Benchmark stopped early: %s", err)
+		}
+
+		printOutput(outputFormat, toGatewayBenchOutput(result), func() {
+			printGatewayBenchResult(result)
+		})
+	},
+}
+
+func printGatewayBenchResult(result *gateway.BenchResult) {
+	visualize.GenericTable([]string{"METRIC", "VALUE"}, [][]string{
+		{"Relay address", result.RelayAddress},
+		{"Connections", fmt.Sprintf("%d (%d failed)", result.Connections, result.Failures)},
+		{"Transferred", fmt.Sprintf("%.2f MiB", float64(result.BytesTransferred)/(1<<20))},
+		{"Elapsed", result.Elapsed.Round(time.Millisecond).String()},
+		{"Throughput", fmt.Sprintf("%.2f MiB/s", result.Throughput/(1<<20))},
+		{"Setup latency p50", result.SetupLatencyP50.Round(time.Microsecond).String()},
+		{"Setup latency p99", result.SetupLatencyP99.Round(time.Microsecond).String()},
+		{"Setup latency max", result.SetupLatencyMax.Round(time.Microsecond).String()},
+		{"Round trip p50", result.RoundTripP50.Round(time.Microsecond).String()},
+		{"Round trip p99", result.RoundTripP99.Round(time.Microsecond).String()},
+		{"Round trip max", result.RoundTripMax.Round(time.Microsecond).String()},
+	})
+}
+
+var gatewayDiagnoseCmd = &cobra.Command{
+	Example:               `infisical gateway diagnose`,
+	Short:                 "Check that this host can reach Infisical and the gateway relay",
+	Use:                   "diagnose",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token == nil {
+			util.HandleError(fmt.Errorf("Token not found"))
+		}
+
+		outputFormat := getOutputFormat(cmd)
+
+		Telemetry.CaptureEvent("cli-command:gateway diagnose", posthog.NewProperties().Set("version", util.CLI_VERSION))
+
+		gatewayOptions := []gateway.Option{
+			gateway.WithIdentityToken(token.Token),
+			gateway.WithRegistrationCache(getGatewayRegistrationCacheDir()),
+		}
+
+		if relayOverride := getRelayOverride(cmd); relayOverride != nil {
+			gatewayOptions = append(gatewayOptions, gateway.WithRelayOverride(*relayOverride))
+		}
+
+		gatewayInstance, err := gateway.New(gatewayOptions...)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		results := gatewayInstance.Diagnose(cmd.Context())
+
+		failed := false
+		for _, result := range results {
+			if result.Status == gateway.DiagnosticFail {
+				failed = true
 			}
 		}
+
+		printOutput(outputFormat, toGatewayDiagnosticsOutput(results), func() {
+			printGatewayDiagnostics(results)
+		})
+
+		if failed {
+			os.Exit(1)
+		}
 	},
 }
 
+type gatewayBenchOutput struct {
+	RelayAddress      string  `json:"relayAddress"`
+	Connections       int     `json:"connections"`
+	Failures          int     `json:"failures"`
+	BytesTransferred  int64   `json:"bytesTransferred"`
+	ElapsedMs         float64 `json:"elapsedMs"`
+	BytesPerSecond    float64 `json:"bytesPerSecond"`
+	SetupLatencyP50Ms float64 `json:"setupLatencyP50Ms"`
+	SetupLatencyP99Ms float64 `json:"setupLatencyP99Ms"`
+	SetupLatencyMaxMs float64 `json:"setupLatencyMaxMs"`
+	RoundTripP50Ms    float64 `json:"roundTripP50Ms"`
+	RoundTripP99Ms    float64 `json:"roundTripP99Ms"`
+	RoundTripMaxMs    float64 `json:"roundTripMaxMs"`
+}
+
+func toGatewayBenchOutput(result *gateway.BenchResult) gatewayBenchOutput {
+	milliseconds := func(duration time.Duration) float64 {
+		return float64(duration.Microseconds()) / 1000
+	}
+
+	return gatewayBenchOutput{
+		RelayAddress:      result.RelayAddress,
+		Connections:       result.Connections,
+		Failures:          result.Failures,
+		BytesTransferred:  result.BytesTransferred,
+		ElapsedMs:         milliseconds(result.Elapsed),
+		BytesPerSecond:    result.Throughput,
+		SetupLatencyP50Ms: milliseconds(result.SetupLatencyP50),
+		SetupLatencyP99Ms: milliseconds(result.SetupLatencyP99),
+		SetupLatencyMaxMs: milliseconds(result.SetupLatencyMax),
+		RoundTripP50Ms:    milliseconds(result.RoundTripP50),
+		RoundTripP99Ms:    milliseconds(result.RoundTripP99),
+		RoundTripMaxMs:    milliseconds(result.RoundTripMax),
+	}
+}
+
+type gatewayDiagnosticOutput struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+func toGatewayDiagnosticsOutput(results []gateway.DiagnosticResult) []gatewayDiagnosticOutput {
+	output := []gatewayDiagnosticOutput{}
+	for _, result := range results {
+		output = append(output, gatewayDiagnosticOutput{
+			Check:  result.Check,
+			Status: string(result.Status),
+			Detail: result.Detail,
+			Hint:   result.Hint,
+		})
+	}
+	return output
+}
+
+func printGatewayDiagnostics(results []gateway.DiagnosticResult) {
+	rows := [][]string{}
+	var hints []string
+	for _, result := range results {
+		rows = append(rows, []string{result.Check, strings.ToUpper(string(result.Status)), result.Detail})
+		if result.Hint != "" {
+			hints = append(hints, fmt.Sprintf("%s: %s", result.Check, result.Hint))
+		}
+	}
+
+	visualize.GenericTable([]string{"CHECK", "STATUS", "DETAILS"}, rows)
+
+	if len(hints) > 0 {
+		fmt.Println("\nSuggestions:")
+		for _, hint := range hints {
+			fmt.Printf("  - %s\n", hint)
+		}
+	}
+}
+
+func addRelayOverrideFlags(cmd *cobra.Command) {
+	cmd.Flags().String("relay-address", "", "Relay host:port to dial instead of the one returned by Infisical, for NATed or split-brain DNS deployments")
+	cmd.Flags().String("relay-server-name", "", "Server name used to verify the relay TLS certificate. Defaults to the relay host returned by Infisical")
+	cmd.Flags().String("relay-username", "", "Relay username to use instead of the one returned by Infisical. The password is read from "+util.INFISICAL_GATEWAY_RELAY_PASSWORD_NAME)
+	cmd.Flags().String("relay-realm", "", "Relay realm to use instead of the one returned by Infisical")
+	cmd.Flags().String("relay-credentials-file", "", "YAML file with the username, password and realm of a self-hosted relay, read again on every reconnect so rotated credentials are picked up. --relay-username, --relay-realm and "+util.INFISICAL_GATEWAY_RELAY_PASSWORD_NAME+" take precedence over it")
+}
+
+// getGatewayRegistrationCacheDir is where gateways save the relay registrations of their identities, for
+// bench and diagnose to reuse them. It's empty, so nothing is saved, when the user has no cache dir.
+func getGatewayRegistrationCacheDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		log.Debug().Msgf("not saving gateway registrations because %v", err)
+		return ""
+	}
+	return filepath.Join(cacheDir, "infisical", "gateway")
+}
+
+func getRelayOverride(cmd *cobra.Command) *gateway.RelayOverride {
+	relayAddress, err := cmd.Flags().GetString("relay-address")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	relayServerName, err := cmd.Flags().GetString("relay-server-name")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	relayUsername, err := cmd.Flags().GetString("relay-username")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	relayRealm, err := cmd.Flags().GetString("relay-realm")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	relayCredentialsFile, err := cmd.Flags().GetString("relay-credentials-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	relayPassword := os.Getenv(util.INFISICAL_GATEWAY_RELAY_PASSWORD_NAME)
+
+	if relayAddress == "" && relayServerName == "" && relayUsername == "" && relayRealm == "" && relayPassword == "" && relayCredentialsFile == "" {
+		return nil
+	}
+
+	return &gateway.RelayOverride{
+		Address:         relayAddress,
+		ServerName:      relayServerName,
+		Username:        relayUsername,
+		Password:        relayPassword,
+		Realm:           relayRealm,
+		CredentialsFile: relayCredentialsFile,
+	}
+}
+
+func getSessionRecordingConfig(cmd *cobra.Command, directory string) gateway.SessionRecordingConfig {
+	targets, err := cmd.Flags().GetStringSlice("session-recording-targets")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if len(targets) == 0 {
+		util.PrintErrorMessageAndExit("--session-recording-targets is required when session recording is enabled")
+	}
+
+	retention, err := cmd.Flags().GetDuration("session-recording-retention")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	uploadCommand, err := cmd.Flags().GetString("session-recording-upload-command")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	encryptionKeyHex := os.Getenv(util.INFISICAL_GATEWAY_SESSION_RECORDING_KEY_NAME)
+	if encryptionKeyHex == "" {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("Session recording requires a hex encoded AES key in the %s environment variable", util.INFISICAL_GATEWAY_SESSION_RECORDING_KEY_NAME))
+	}
+
+	encryptionKey, err := hex.DecodeString(encryptionKeyHex)
+	if err != nil {
+		util.HandleError(err, fmt.Sprintf("Unable to decode %s", util.INFISICAL_GATEWAY_SESSION_RECORDING_KEY_NAME))
+	}
+
+	recordingConfig := gateway.SessionRecordingConfig{
+		Directory:     directory,
+		Targets:       targets,
+		EncryptionKey: encryptionKey,
+		Retention:     retention,
+	}
+
+	if uploadCommand != "" {
+		recordingConfig.UploadHook = func(path string) error {
+			uploadCmd := exec.Command("sh", "-c", uploadCommand)
+			uploadCmd.Env = append(util.ChildProcessEnvironment(os.Environ()), "INFISICAL_SESSION_RECORDING_PATH="+path)
+			output, err := uploadCmd.CombinedOutput()
+			if err != nil {
+				return fmt.Errorf("%w: %s", err, string(output))
+			}
+			return nil
+		}
+	}
+
+	return recordingConfig
+}
+
 func init() {
 	gatewayCmd.SetHelpFunc(func(command *cobra.Command, strings []string) {
 		command.Flags().MarkHidden("domain")
 		command.Parent().HelpFunc()(command, strings)
 	})
 	gatewayCmd.Flags().String("token", "", "Connect with Infisical using machine identity access token")
+	gatewayCmd.Flags().String("config", "", "YAML file listing several gateway identities to serve from this process, each with its own token, relay and static IPs, which replace those of the flags. The other flags apply to all of them")
+	addRelayOverrideFlags(gatewayCmd)
+	gatewayCmd.Flags().Duration("api-timeout", 30*time.Second, "Upper bound for each call the gateway makes to Infisical, including retries")
+	gatewayCmd.Flags().Int("heartbeat-failure-threshold", 3, "Number of heart beats that must fail in a row before the gateway reconnects to the relay")
+	gatewayCmd.Flags().Duration("heartbeat-retry-interval", time.Minute, "How long to wait before retrying a failed heart beat")
+	gatewayCmd.Flags().StringSlice("static-ips", []string{}, "Additional IPs or CIDR ranges (up to /24) allowed to reach the gateway through the relay, e.g. for multi-region Infisical egress")
+	gatewayCmd.Flags().Int("connection-buffer-size", 32*1024, "Bytes buffered per direction of each forwarded connection")
+	gatewayCmd.Flags().Int64("max-buffered-bytes", 0, "Upper bound on bytes buffered across all forwarded connections. New connections wait for capacity once it is reached. 0 means unlimited")
+	gatewayCmd.Flags().String("session-recording-dir", "", "Enable session recording and write encrypted recordings to this directory. The key is read from "+util.INFISICAL_GATEWAY_SESSION_RECORDING_KEY_NAME)
+	gatewayCmd.Flags().StringSlice("session-recording-targets", []string{}, "Targets (host or host:port) whose sessions should be recorded. Use * to record every target")
+	gatewayCmd.Flags().Duration("session-recording-retention", 0, "How long recordings are kept before being deleted (e.g. 720h). Recordings are kept forever by default")
+	gatewayCmd.Flags().String("session-recording-upload-command", "", "Shell command run after each recording is finished. The recording path is passed in INFISICAL_SESSION_RECORDING_PATH")
+	gatewayCmd.Flags().Duration("session-reauthorization-interval", 15*time.Minute, "How often sessions that stay open are re-checked against their peer certificate, closing the ones whose certificate expired or no longer chains to the gateway CA. Revoking the access of an actor ends their sessions once their certificate expires. 0 checks them only when they connect")
+	gatewayCmd.Flags().String("target-resolver", "", "DNS-over-TLS (tls://host[:port]) or DNS-over-HTTPS (https://host/dns-query) server that resolves target hostnames instead of the DNS of the host")
+	gatewayCmd.Flags().Duration("maintenance-duration", 30*time.Minute, "How long maintenance mode lasts once entered with [infisical gateway maintenance on], before new connections are accepted again. 0 lasts until [infisical gateway maintenance off]")
+	gatewayCmd.Flags().Bool("standby", false, "Start as a warm standby that registers and connects to the relay, but only allocates a relay address and serves connections once promoted with [infisical gateway promote]. Requires --admin-socket")
+	gatewayCmd.Flags().String("admin-socket", "", "Unix socket on which the gateway accepts admin commands such as [infisical gateway promote], [infisical gateway maintenance] and [infisical gateway capture]")
+	gatewayCmd.Flags().String("target-ca-cert", "", "Path to a PEM encoded CA bundle used to verify targets when the gateway terminates TLS")
+
+	gatewayBenchCmd.Flags().String("token", "", "Connect with Infisical using machine identity access token")
+	addRelayOverrideFlags(gatewayBenchCmd)
+	addOutputFlag(gatewayBenchCmd)
+	gatewayBenchCmd.Flags().Int("connections", 100, "Total number of connections to open through the relay")
+	gatewayBenchCmd.Flags().Int("concurrency", 10, "Number of connections in flight at the same time")
+	gatewayBenchCmd.Flags().Int("payload-size", 1<<20, "Bytes echoed over each connection")
+	gatewayBenchCmd.Flags().Int("chunk-size", 32*1024, "Size of each write. Every chunk is timed as a round trip")
+	gatewayCmd.AddCommand(gatewayBenchCmd)
+
+	gatewayDiagnoseCmd.Flags().String("token", "", "Connect with Infisical using machine identity access token")
+	addRelayOverrideFlags(gatewayDiagnoseCmd)
+	addOutputFlag(gatewayDiagnoseCmd)
+	gatewayCmd.AddCommand(gatewayDiagnoseCmd)
 
 	rootCmd.AddCommand(gatewayCmd)
 }
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const gatewayAdminTimeout = 10 * time.Second
+
+var gatewayPromoteCmd = &cobra.Command{
+	Example: `infisical gateway promote --admin-socket /run/infisical-gateway.sock`,
+	Short:   "Promote a running standby gateway so that it starts serving connections",
+	Long: `Promote a gateway started with --standby. A standby gateway registers with Infisical and connects to
+the relay, but only allocates a relay address and serves connections once promoted, so failing over to it
+skips registration and the relay handshake. The gateway must be started with --admin-socket.`,
+	Use:                   "promote",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		reply, err := sendGatewayAdminCommand(getGatewayAdminSocket(cmd), "promote")
+		if err != nil {
+			util.HandleError(err, "Unable to promote the gateway")
+		}
+
+		util.PrintSuccessMessage(reply)
+		Telemetry.CaptureEvent("cli-command:gateway promote", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+// serveGatewayAdminSocket answers the commands of [infisical gateway promote], [infisical gateway maintenance],
+// [infisical gateway sessions] and [infisical gateway capture] on a unix socket that only the user running
+// the gateway can connect to, until ctx is done. Maintenance mode lasts maintenanceDuration.
+func serveGatewayAdminSocket(ctx context.Context, path string, gatewayInstances []*gateway.Gateway, maintenanceDuration time.Duration) error {
+	// only the socket of a previous gateway is replaced, never a file the path names by mistake
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode().Type() != os.ModeSocket {
+			return fmt.Errorf("%s exists and is not a socket, refusing to replace it with the admin socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove the previous admin socket: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	listener, err := listenGatewayAdminSocket(path)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		os.Remove(path)
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Error().Msgf("Admin socket stopped accepting connections: %s", err)
+				}
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(gatewayAdminTimeout))
+
+				command, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				reply, err := handleGatewayAdminCommand(strings.TrimSpace(command), gatewayInstances, maintenanceDuration)
+				if err != nil {
+					reply = "ERROR " + err.Error()
+				}
+				fmt.Fprint(conn, reply)
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// listenGatewayAdminSocket listens on a socket created in a directory only the current user can enter,
+// where it is restricted to the user before being linked at path, so no one else can connect to it in between
+func listenGatewayAdminSocket(path string) (*net.UnixListener, error) {
+	privateDir, err := os.MkdirTemp(filepath.Dir(path), ".infisical-admin-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(privateDir)
+
+	privatePath := filepath.Join(privateDir, "admin.sock")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: privatePath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed from path when the gateway stops, not from where it was created
+	listener.SetUnlinkOnClose(false)
+
+	if err := os.Chmod(privatePath, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	// linking fails when something was created at path meanwhile, where renaming would replace it
+	if err := os.Link(privatePath, path); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+// handleGatewayAdminCommand runs a command received on the admin socket. The connection is closed
+// after the reply, so replies can span several lines.
+func handleGatewayAdminCommand(command string, gatewayInstances []*gateway.Gateway, maintenanceDuration time.Duration) (string, error) {
+	name, args, _ := strings.Cut(command, " ")
+	switch name {
+	case "sessions":
+		return listForwardedSessions(gatewayInstances), nil
+	case "capture":
+		// capture <connection-id> <max-bytes> <path>, the path may contain spaces
+		fields := strings.SplitN(args, " ", 3)
+		if len(fields) != 3 {
+			return "", fmt.Errorf("expected capture <connection-id> <max-bytes> <path>")
+		}
+		maxBytes, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return "", fmt.Errorf("invalid capture size limit: %w", err)
+		}
+		return captureForwardedSession(gatewayInstances, fields[0], fields[2], maxBytes)
+	case "capture-stop":
+		return stopForwardedSessionCapture(gatewayInstances, args)
+	case "maintenance":
+		return setGatewayMaintenance(gatewayInstances, args, maintenanceDuration)
+	case "promote":
+		promoted := 0
+		for _, gatewayInstance := range gatewayInstances {
+			if gatewayInstance.IsStandby() {
+				gatewayInstance.Promote()
+				promoted++
+			}
+		}
+		if promoted == 0 {
+			return "No standby gateway to promote", nil
+		}
+		return fmt.Sprintf("Promoted %d standby gateway(s), see the gateway logs to follow the allocation", promoted), nil
+	default:
+		return "", fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func sendGatewayAdminCommand(path string, command string) (string, error) {
+	conn, err := net.DialTimeout("unix", path, gatewayAdminTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(gatewayAdminTimeout))
+
+	if _, err := fmt.Fprintln(conn, command); err != nil {
+		return "", err
+	}
+	content, err := io.ReadAll(conn)
+	if err != nil {
+		return "", err
+	}
+	reply := string(content)
+
+	reply = strings.TrimSpace(reply)
+	if message, ok := strings.CutPrefix(reply, "ERROR "); ok {
+		return "", errors.New(message)
+	}
+	return reply, nil
+}
+
+func init() {
+	gatewayPromoteCmd.Flags().String("admin-socket", "", "the admin socket of the running gateway")
+	gatewayCmd.AddCommand(gatewayPromoteCmd)
+}
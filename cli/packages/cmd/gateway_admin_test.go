@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGatewayAdminCommandPromotesStandbyGateways(t *testing.T) {
+	standby, err := gateway.New(gateway.WithIdentityToken("token"), gateway.WithStandby())
+	assert.NoError(t, err)
+	active, err := gateway.New(gateway.WithIdentityToken("token"))
+	assert.NoError(t, err)
+
+	assert.True(t, standby.IsStandby())
+	assert.False(t, active.IsStandby())
+
+	reply, err := handleGatewayAdminCommand("promote", []*gateway.Gateway{standby, active}, 0)
+	assert.NoError(t, err)
+	assert.Contains(t, reply, "Promoted 1")
+	assert.False(t, standby.IsStandby())
+
+	reply, err = handleGatewayAdminCommand("promote", []*gateway.Gateway{standby, active}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "No standby gateway to promote", reply)
+}
+
+func TestHandleGatewayAdminCommandRejectsUnknownCommands(t *testing.T) {
+	_, err := handleGatewayAdminCommand("restart", nil, 0)
+	assert.Error(t, err)
+}
+
+func TestHandleGatewayAdminCommandSetsMaintenance(t *testing.T) {
+	tests := []struct {
+		name            string
+		command         string
+		inMaintenance   bool
+		wantMaintenance bool
+		wantErr         bool
+	}{
+		{name: "on", command: "maintenance on", inMaintenance: false, wantMaintenance: true},
+		{name: "off", command: "maintenance off", inMaintenance: true, wantMaintenance: false},
+		{name: "no action", command: "maintenance", inMaintenance: true, wantMaintenance: true, wantErr: true},
+		{name: "unknown action", command: "maintenance restart", inMaintenance: false, wantMaintenance: false, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gatewayInstance, err := gateway.New(gateway.WithIdentityToken("token"))
+			assert.NoError(t, err)
+			t.Cleanup(gatewayInstance.ExitMaintenance)
+			if test.inMaintenance {
+				gatewayInstance.EnterMaintenance(0)
+			}
+
+			_, err = handleGatewayAdminCommand(test.command, []*gateway.Gateway{gatewayInstance}, time.Minute)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.wantMaintenance, gatewayInstance.InMaintenance())
+		})
+	}
+}
+
+func TestServeGatewayAdminSocket(t *testing.T) {
+	tests := []struct {
+		name string
+		// existing creates what path names before the gateway starts
+		existing func(t *testing.T, path string)
+		wantErr  bool
+	}{
+		{name: "no socket"},
+		{
+			name: "stale socket",
+			existing: func(t *testing.T, path string) {
+				listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+				assert.NoError(t, err)
+				listener.SetUnlinkOnClose(false)
+				listener.Close()
+			},
+		},
+		{
+			name: "regular file",
+			existing: func(t *testing.T, path string) {
+				assert.NoError(t, os.WriteFile(path, []byte("keep"), 0644))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			directory := t.TempDir()
+			path := filepath.Join(directory, "admin.sock")
+			if test.existing != nil {
+				test.existing(t, path)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err := serveGatewayAdminSocket(ctx, path, nil, 0)
+			if test.wantErr {
+				assert.Error(t, err)
+				content, err := os.ReadFile(path)
+				assert.NoError(t, err)
+				assert.Equal(t, "keep", string(content), "a path that is not a socket is left as it is")
+				return
+			}
+			assert.NoError(t, err)
+
+			info, err := os.Lstat(path)
+			assert.NoError(t, err)
+			assert.Equal(t, os.ModeSocket, info.Mode().Type())
+			if runtime.GOOS != "windows" {
+				assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+			}
+
+			entries, err := os.ReadDir(directory)
+			assert.NoError(t, err)
+			assert.Len(t, entries, 1, "the directory the socket was created in is removed")
+
+			reply, err := sendGatewayAdminCommand(path, "promote")
+			assert.NoError(t, err)
+			assert.Equal(t, "No standby gateway to promote", reply)
+
+			cancel()
+			assert.Eventually(t, func() bool {
+				_, err := os.Stat(path)
+				return errors.Is(err, os.ErrNotExist)
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
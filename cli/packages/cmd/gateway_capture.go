@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var gatewaySessionsCmd = &cobra.Command{
+	Example:               `infisical gateway sessions --admin-socket /run/infisical-gateway.sock`,
+	Short:                 "List the sessions a running gateway forwards, with their connection IDs",
+	Use:                   "sessions",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		reply, err := sendGatewayAdminCommand(getGatewayAdminSocket(cmd), "sessions")
+		if err != nil {
+			util.HandleError(err, "Unable to list the sessions of the gateway")
+		}
+
+		fmt.Println(reply)
+		Telemetry.CaptureEvent("cli-command:gateway sessions", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var gatewayCaptureCmd = &cobra.Command{
+	Example: `infisical gateway capture 3f9a1c27d04e8b65 --output-file session.ndjson --admin-socket /run/infisical-gateway.sock
+infisical gateway capture 3f9a1c27d04e8b65 --stop --admin-socket /run/infisical-gateway.sock`,
+	Short: "Mirror the bytes of a session forwarded by a running gateway to a capture file",
+	Long: `Mirror the bytes of a session forwarded by a running gateway to a capture file, to debug the protocol spoken
+through the gateway without access to the relay. Find the connection ID of the session with [infisical gateway sessions].
+
+The file is written by the gateway process, one JSON record per line: the first describes the session, and each
+following one holds the base64 encoded bytes sent in one direction. The bytes are in clear, so treat captures as
+secrets. The capture stops at --max-bytes of data, when the session ends, or with --stop.`,
+	Use:                   "capture [connection-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		adminSocket := getGatewayAdminSocket(cmd)
+
+		stop, err := cmd.Flags().GetBool("stop")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		output, err := cmd.Flags().GetString("output-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		maxBytes, err := cmd.Flags().GetInt64("max-bytes")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		command := "capture-stop " + args[0]
+		if !stop {
+			if output == "" {
+				util.PrintErrorMessageAndExit("You must set the --output-file flag to the file to capture the session to")
+			}
+			if maxBytes <= 0 {
+				util.PrintErrorMessageAndExit("--max-bytes must be positive")
+			}
+
+			// the gateway may run in another working directory
+			output, err = filepath.Abs(output)
+			if err != nil {
+				util.HandleError(err, "Unable to resolve the output path")
+			}
+			command = fmt.Sprintf("capture %s %d %s", args[0], maxBytes, output)
+		}
+
+		reply, err := sendGatewayAdminCommand(adminSocket, command)
+		if err != nil {
+			util.HandleError(err, "Unable to capture the session")
+		}
+
+		util.PrintSuccessMessage(reply)
+		Telemetry.CaptureEvent("cli-command:gateway capture", posthog.NewProperties().Set("stop", stop).Set("version", util.CLI_VERSION))
+	},
+}
+
+func getGatewayAdminSocket(cmd *cobra.Command) string {
+	adminSocket, err := cmd.Flags().GetString("admin-socket")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if adminSocket == "" {
+		util.PrintErrorMessageAndExit("You must set the --admin-socket flag to the admin socket of the gateway")
+	}
+	return adminSocket
+}
+
+func listForwardedSessions(gatewayInstances []*gateway.Gateway) string {
+	var sessions []gateway.ConnectionInfo
+	for _, gatewayInstance := range gatewayInstances {
+		sessions = append(sessions, gatewayInstance.ForwardedSessions()...)
+	}
+	if len(sessions) == 0 {
+		return "No forwarded sessions"
+	}
+
+	var builder strings.Builder
+	writer := tabwriter.NewWriter(&builder, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "CONNECTION ID\tTARGET\tPEER\tREMOTE ADDRESS\tOPEN FOR")
+	for _, session := range sessions {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", session.ID, session.Route.Target, session.Peer.CommonName, session.RemoteAddress, time.Since(session.AcceptedAt).Round(time.Second))
+	}
+	writer.Flush()
+	return strings.TrimSuffix(builder.String(), "\n")
+}
+
+func captureForwardedSession(gatewayInstances []*gateway.Gateway, id string, path string, maxBytes int64) (string, error) {
+	for _, gatewayInstance := range gatewayInstances {
+		err := gatewayInstance.CaptureSession(id, path, maxBytes)
+		if errors.Is(err, gateway.ErrSessionNotFound) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Capturing session %s to %s", id, path), nil
+	}
+	return "", gateway.ErrSessionNotFound
+}
+
+func stopForwardedSessionCapture(gatewayInstances []*gateway.Gateway, id string) (string, error) {
+	for _, gatewayInstance := range gatewayInstances {
+		if err := gatewayInstance.StopCapture(id); err == nil {
+			return fmt.Sprintf("Stopped the capture of session %s", id), nil
+		}
+	}
+	return "", gateway.ErrSessionNotFound
+}
+
+func init() {
+	gatewaySessionsCmd.Flags().String("admin-socket", "", "the admin socket of the running gateway")
+	gatewayCmd.AddCommand(gatewaySessionsCmd)
+
+	gatewayCaptureCmd.Flags().String("admin-socket", "", "the admin socket of the running gateway")
+	gatewayCaptureCmd.Flags().String("output-file", "", "the capture file to create")
+	gatewayCaptureCmd.Flags().Int64("max-bytes", 10<<20, "stop the capture once this many bytes of the session are written")
+	gatewayCaptureCmd.Flags().Bool("stop", false, "stop capturing the session")
+	gatewayCmd.AddCommand(gatewayCaptureCmd)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleGatewayAdminCommandCapture(t *testing.T) {
+	gatewayInstance, err := gateway.New(gateway.WithIdentityToken("token"))
+	assert.NoError(t, err)
+	gatewayInstances := []*gateway.Gateway{gatewayInstance}
+
+	reply, err := handleGatewayAdminCommand("sessions", gatewayInstances, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "No forwarded sessions", reply)
+
+	_, err = handleGatewayAdminCommand("capture 3f9a1c27d04e8b65 1024 /tmp/my capture.ndjson", gatewayInstances, 0)
+	assert.ErrorIs(t, err, gateway.ErrSessionNotFound)
+
+	_, err = handleGatewayAdminCommand("capture 3f9a1c27d04e8b65 /tmp/capture.ndjson", gatewayInstances, 0)
+	assert.Error(t, err)
+
+	_, err = handleGatewayAdminCommand("capture-stop 3f9a1c27d04e8b65", gatewayInstances, 0)
+	assert.ErrorIs(t, err, gateway.ErrSessionNotFound)
+}
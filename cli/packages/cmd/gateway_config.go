@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"gopkg.in/yaml.v2"
+)
+
+// gatewaysConfig is the file of gateway --config, for one process that serves several gateway
+// identities, each with its own relay allocation and certificate. The other flags of the gateway
+// apply to all of them.
+type gatewaysConfig struct {
+	Gateways []gatewayIdentityConfig `yaml:"gateways"`
+}
+
+type gatewayIdentityConfig struct {
+	Name string `yaml:"name"`
+	// the machine identity access token is read from a file, or from an environment variable
+	TokenFile string `yaml:"token-file"`
+	TokenEnv  string `yaml:"token-env"`
+	// replaces --static-ips for this identity
+	StaticIps []string `yaml:"static-ips"`
+	// replaces the relay flags of the gateway for this identity
+	Relay *struct {
+		Address         string `yaml:"address"`
+		ServerName      string `yaml:"server-name"`
+		Username        string `yaml:"username"`
+		Realm           string `yaml:"realm"`
+		CredentialsFile string `yaml:"credentials-file"`
+	} `yaml:"relay"`
+
+	token string
+}
+
+func readGatewaysConfig(path string) ([]gatewayIdentityConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseGatewaysConfig(content)
+}
+
+func parseGatewaysConfig(content []byte) ([]gatewayIdentityConfig, error) {
+	var config gatewaysConfig
+	if err := yaml.UnmarshalStrict(content, &config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Gateways) == 0 {
+		return nil, fmt.Errorf("no gateways configured")
+	}
+
+	names := map[string]bool{}
+	for i := range config.Gateways {
+		gatewayConfig := &config.Gateways[i]
+		if gatewayConfig.Name == "" {
+			return nil, fmt.Errorf("gateway %d: name is required", i+1)
+		}
+		if names[gatewayConfig.Name] {
+			return nil, fmt.Errorf("gateway %s: the name is used by another gateway", gatewayConfig.Name)
+		}
+		names[gatewayConfig.Name] = true
+
+		switch {
+		case gatewayConfig.TokenFile != "" && gatewayConfig.TokenEnv != "":
+			return nil, fmt.Errorf("gateway %s: set either token-file or token-env", gatewayConfig.Name)
+		case gatewayConfig.TokenFile != "":
+			token, err := os.ReadFile(gatewayConfig.TokenFile)
+			if err != nil {
+				return nil, fmt.Errorf("gateway %s: unable to read the token file: %w", gatewayConfig.Name, err)
+			}
+			gatewayConfig.token = strings.TrimSpace(string(token))
+		case gatewayConfig.TokenEnv != "":
+			gatewayConfig.token = os.Getenv(gatewayConfig.TokenEnv)
+		}
+
+		if gatewayConfig.token == "" {
+			return nil, fmt.Errorf("gateway %s: no token found, set token-file or token-env", gatewayConfig.Name)
+		}
+	}
+
+	return config.Gateways, nil
+}
+
+// options returns the options of the gateway that are specific to its identity
+func (c gatewayIdentityConfig) options() []gateway.Option {
+	var options []gateway.Option
+	if len(c.StaticIps) > 0 {
+		options = append(options, gateway.WithStaticIps(c.StaticIps...))
+	}
+	if c.Relay != nil {
+		options = append(options, gateway.WithRelayOverride(gateway.RelayOverride{
+			Address:         c.Relay.Address,
+			ServerName:      c.Relay.ServerName,
+			Username:        c.Relay.Username,
+			Realm:           c.Relay.Realm,
+			CredentialsFile: c.Relay.CredentialsFile,
+		}))
+	}
+	return options
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGatewaysConfig(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("file-token\n"), 0600))
+	t.Setenv("NETWORK_B_TOKEN", "env-token")
+
+	gatewayConfigs, err := parseGatewaysConfig([]byte(`
+gateways:
+  - name: network-a
+    token-file: ` + tokenFile + `
+    static-ips: [10.0.0.1]
+  - name: network-b
+    token-env: NETWORK_B_TOKEN
+    relay:
+      address: relay.internal:5349
+`))
+	assert.NoError(t, err)
+	assert.Len(t, gatewayConfigs, 2)
+	assert.Equal(t, "file-token", gatewayConfigs[0].token)
+	assert.Len(t, gatewayConfigs[0].options(), 1)
+	assert.Equal(t, "env-token", gatewayConfigs[1].token)
+	assert.Len(t, gatewayConfigs[1].options(), 1)
+
+	_, err = parseGatewaysConfig([]byte(`
+gateways:
+  - name: network-a
+    token-env: NETWORK_B_TOKEN
+  - name: network-a
+    token-env: NETWORK_B_TOKEN
+`))
+	assert.ErrorContains(t, err, "used by another gateway")
+
+	_, err = parseGatewaysConfig([]byte(`
+gateways:
+  - name: network-a
+    token-env: MISSING_GATEWAY_TOKEN
+`))
+	assert.ErrorContains(t, err, "no token found")
+}
+
+func TestStartGatewayRunReturnsStartErrors(t *testing.T) {
+	stopped := false
+	run, err := startGatewayRun(context.Background(), gatewayIdentityConfig{Name: "eu"}, nil, func() { stopped = true })
+
+	assert.Nil(t, run)
+	assert.EqualError(t, err, "gateway eu: an identity token is required to start the gateway")
+	assert.False(t, stopped)
+}
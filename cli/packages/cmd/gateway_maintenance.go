@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/gateway"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var gatewayMaintenanceCmd = &cobra.Command{
+	Example: `infisical gateway maintenance on --admin-socket /run/infisical-gateway.sock
+infisical gateway maintenance off --admin-socket /run/infisical-gateway.sock`,
+	Short: "Put a running gateway in maintenance mode, or take it out",
+	Long: `Put a running gateway in maintenance mode before patching its host, or take it out. In maintenance mode the
+gateway refuses new connections with a draining error, while the sessions already open keep being served.
+It ends by itself after the --maintenance-duration of the gateway, 30 minutes by default. The gateway must
+be started with --admin-socket.`,
+	Use:                   "maintenance [on|off]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"on", "off"},
+	Run: func(cmd *cobra.Command, args []string) {
+		if args[0] != "on" && args[0] != "off" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("unknown action %s, expected on or off", args[0]))
+		}
+
+		reply, err := sendGatewayAdminCommand(getGatewayAdminSocket(cmd), "maintenance "+args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to change the maintenance mode of the gateway")
+		}
+
+		util.PrintSuccessMessage(reply)
+		Telemetry.CaptureEvent("cli-command:gateway maintenance", posthog.NewProperties().Set("action", args[0]).Set("version", util.CLI_VERSION))
+	},
+}
+
+// setGatewayMaintenance puts the gateways in maintenance mode for duration when action is on, and takes
+// them out when it is off
+func setGatewayMaintenance(gatewayInstances []*gateway.Gateway, action string, duration time.Duration) (string, error) {
+	switch action {
+	case "on":
+		for _, gatewayInstance := range gatewayInstances {
+			gatewayInstance.EnterMaintenance(duration)
+		}
+		if duration > 0 {
+			return fmt.Sprintf("%d gateway(s) in maintenance mode for %s", len(gatewayInstances), duration), nil
+		}
+		return fmt.Sprintf("%d gateway(s) in maintenance mode until [infisical gateway maintenance off]", len(gatewayInstances)), nil
+	case "off":
+		for _, gatewayInstance := range gatewayInstances {
+			gatewayInstance.ExitMaintenance()
+		}
+		return fmt.Sprintf("%d gateway(s) out of maintenance mode", len(gatewayInstances)), nil
+	default:
+		return "", fmt.Errorf("expected maintenance on or maintenance off")
+	}
+}
+
+func init() {
+	gatewayMaintenanceCmd.Flags().String("admin-socket", "", "the admin socket of the running gateway")
+	gatewayCmd.AddCommand(gatewayMaintenanceCmd)
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	gitCredentialSecretPrefix = "GIT_"
+	// gitCredentialUsernameSuffix marks the secret with the username for the token of a host, for hosts
+	// such as Bitbucket that check it
+	gitCredentialUsernameSuffix = "_USERNAME"
+)
+
+var gitCredentialCmd = &cobra.Command{
+	Example: `git config --global credential.https://github.com.helper "!infisical git-credential --env prod --projectId <project id>"
+printf 'protocol=https\nhost=github.com\n\n' | infisical git-credential get`,
+	Short: "Give git the tokens of HTTPS remotes from Infisical",
+	Long: `Give git the tokens of HTTPS remotes from Infisical, so they are rotated centrally instead of stored by
+each developer and pipeline. This is a git credential helper: git runs it with get, store or erase and
+passes the remote on stdin.
+
+The token of a host is the secret named after it, such as GIT_GITHUB_COM for github.com. With
+credential.useHttpPath, git also passes the repository, and the secret of its path wins over the one
+of the host: GIT_GITHUB_COM_ACME_API_GIT, then GIT_GITHUB_COM_ACME, then GIT_GITHUB_COM for
+github.com/acme/api.git. The username is the secret with the same name and _USERNAME after it, the one
+in the remote URL, or --username.
+
+Tokens are managed in Infisical, so store and erase, which git runs after a remote accepts or rejects
+a token, do nothing.`,
+	Use:                   "git-credential [get|store|erase]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"get", "store", "erase"},
+	Annotations:           map[string]string{credentialHelperAnnotation: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		action := args[0]
+		if action == "store" || action == "erase" {
+			return
+		}
+		if action != "get" {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("unknown action %s, expected get, store or erase", action))
+		}
+
+		defaultUsername, err := cmd.Flags().GetString("username")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		request, err := readGitCredentialRequest(os.Stdin)
+		if err != nil {
+			util.HandleError(err, "Unable to read the credential request of git")
+		}
+
+		// git asks every helper in turn, so leave remotes that aren't https, or that have no host, to the others
+		if request["protocol"] != "https" || request["host"] == "" {
+			return
+		}
+
+		secrets, err := fetchCredentialSecrets(getCredentialSourceFlags(cmd))
+		if err != nil {
+			util.HandleError(err, "Unable to fetch the git credentials")
+		}
+
+		username, password, found := findGitCredential(secrets, request["host"], request["path"])
+		if !found {
+			return
+		}
+		if username == "" {
+			username = request["username"]
+		}
+		if username == "" {
+			username = defaultUsername
+		}
+
+		fmt.Printf("username=%s\npassword=%s\n", username, password)
+
+		Telemetry.CaptureEvent("cli-command:git-credential", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+// readGitCredentialRequest reads the key=value lines git passes to helpers, up to the first empty line
+func readGitCredentialRequest(input io.Reader) (map[string]string, error) {
+	request := map[string]string{}
+
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("unexpected line %q", line)
+		}
+		request[key] = value
+	}
+	return request, scanner.Err()
+}
+
+// gitCredentialSecretNames returns the secrets that can hold the token of a remote, the most specific
+// first: one for each parent of the path, then the one of the host
+func gitCredentialSecretNames(host string, path string) []string {
+	names := []string{}
+
+	segments := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	for i := len(segments); i > 0; i-- {
+		names = append(names, credentialSecretName(gitCredentialSecretPrefix, host+"/"+strings.Join(segments[:i], "/")))
+	}
+	return append(names, credentialSecretName(gitCredentialSecretPrefix, host))
+}
+
+// findGitCredential returns the token of the remote, and its username when a secret sets one
+func findGitCredential(secrets []models.SingleEnvironmentVariable, host string, path string) (string, string, bool) {
+	secretsByName := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		secretsByName[secret.Key] = secret.Value
+	}
+
+	for _, name := range gitCredentialSecretNames(host, path) {
+		if password, found := secretsByName[name]; found && password != "" {
+			return secretsByName[name+gitCredentialUsernameSuffix], password, true
+		}
+	}
+	return "", "", false
+}
+
+func init() {
+	gitCredentialCmd.Flags().String("token", "", "Fetch the credentials using a service token or machine identity access token")
+	gitCredentialCmd.Flags().String("projectId", "", "manually set the project ID to fetch the credentials from, required when using a machine identity")
+	gitCredentialCmd.Flags().StringP("env", "e", "dev", "the environment of the credentials")
+	gitCredentialCmd.Flags().String("path", "/", "the folder of the credentials")
+	gitCredentialCmd.Flags().String("username", "x-access-token", "the username for tokens when neither a secret nor the remote URL sets one")
+	rootCmd.AddCommand(gitCredentialCmd)
+
+	// git runs the helper of credential.helper=infisical as git-credential-infisical
+	credentialHelperExecutables["git-credential-infisical"] = gitCredentialCmd
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadGitCredentialRequest(t *testing.T) {
+	request, err := readGitCredentialRequest(strings.NewReader("protocol=https\nhost=github.com\npath=acme/api.git\n\nignored=true\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"protocol": "https", "host": "github.com", "path": "acme/api.git"}, request)
+}
+
+func TestFindGitCredential(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "GIT_GITHUB_COM", Value: "host-token"},
+		{Key: "GIT_GITHUB_COM_ACME", Value: "org-token"},
+		{Key: "GIT_GITHUB_COM_ACME_USERNAME", Value: "acme-bot"},
+	}
+
+	username, password, found := findGitCredential(secrets, "github.com", "acme/api.git")
+	assert.True(t, found)
+	assert.Equal(t, "acme-bot", username)
+	assert.Equal(t, "org-token", password)
+
+	username, password, found = findGitCredential(secrets, "github.com", "")
+	assert.True(t, found)
+	assert.Equal(t, "", username)
+	assert.Equal(t, "host-token", password)
+
+	_, _, found = findGitCredential(secrets, "gitlab.com", "")
+	assert.False(t, found)
+}
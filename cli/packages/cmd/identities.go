@@ -0,0 +1,491 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/go-resty/resty/v2"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+// identityAuthMethodPaths maps the auth methods the CLI can attach to their API paths
+var identityAuthMethodPaths = map[string]string{
+	"universal":  "universal-auth",
+	"oidc":       "oidc-auth",
+	"aws":        "aws-auth",
+	"kubernetes": "kubernetes-auth",
+	"tls-cert":   "tls-cert-auth",
+}
+
+var identitiesCmd = &cobra.Command{
+	Example: `
+	infisical identities create ci-deployer --role=no-access
+	infisical identities auth attach <identity id> --method=oidc --oidc-discovery-url=https://token.actions.githubusercontent.com --bound-issuer=https://token.actions.githubusercontent.com --bound-subject=repo:acme/api:ref:refs/heads/main
+	infisical identities role set <identity id> --projectId=<project id> --role=viewer`,
+	Short:                 "Manage the machine identities of your organization",
+	Long:                  "Manage the machine identities of your organization: create and delete them, attach the auth methods workloads log in with, and assign their organization and project roles.",
+	Use:                   "identities",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var identitiesCreateCmd = &cobra.Command{
+	Example:               `infisical identities create <name> --role=no-access`,
+	Short:                 "Used to create a machine identity",
+	Use:                   "create [name]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := cmd.Flags().GetString("role")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		httpClient := newIdentitiesHTTPClient(cmd)
+		organizationId := identitiesOrganizationId(cmd, httpClient)
+
+		identityResponse, err := api.CallCreateIdentityV1(httpClient, api.CreateIdentityV1Request{
+			Name:           args[0],
+			OrganizationId: organizationId,
+			Role:           role,
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to create identity")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Created identity %s with ID %s. Attach an auth method with [infisical identities auth attach %s]", identityResponse.Identity.Name, identityResponse.Identity.ID, identityResponse.Identity.ID))
+
+		Telemetry.CaptureEvent("cli-command:identities create", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var identitiesListCmd = &cobra.Command{
+	Example:               `infisical identities list`,
+	Short:                 "Used to list the machine identities of your organization",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient := newIdentitiesHTTPClient(cmd)
+		organizationId := identitiesOrganizationId(cmd, httpClient)
+
+		identitiesResponse, err := api.CallGetIdentitiesV1(httpClient, organizationId)
+		if err != nil {
+			util.HandleError(err, "Unable to list identities")
+		}
+
+		if len(identitiesResponse.Identities) == 0 {
+			fmt.Println("No identities found")
+			return
+		}
+
+		rows := [][]string{}
+		for _, membership := range identitiesResponse.Identities {
+			rows = append(rows, []string{membership.Identity.ID, membership.Identity.Name, membership.Role, strings.Join(membership.Identity.AuthMethods, ", ")})
+		}
+		visualize.GenericTable([]string{"ID", "NAME", "ORGANIZATION ROLE", "AUTH METHODS"}, rows)
+	},
+}
+
+var identitiesDeleteCmd = &cobra.Command{
+	Example:               `infisical identities delete <identity id>`,
+	Short:                 "Used to delete machine identities",
+	Use:                   "delete [identity-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient := newIdentitiesHTTPClient(cmd)
+
+		for _, identityId := range args {
+			err := api.CallDeleteIdentityV1(httpClient, identityId)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to delete identity %s", identityId))
+			}
+		}
+
+		fmt.Printf("Identities [%v] have been deleted\n", strings.Join(args, ", "))
+
+		Telemetry.CaptureEvent("cli-command:identities delete", posthog.NewProperties().Set("identityCount", len(args)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var identitiesAuthCmd = &cobra.Command{
+	Short:                 "Manage the auth methods of machine identities",
+	Use:                   "auth",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var identitiesAuthAttachCmd = &cobra.Command{
+	Example: `
+	infisical identities auth attach <identity id> --method=universal --access-token-ttl=1h
+	infisical identities auth attach <identity id> --method=aws --allowed-account-ids=123456789012
+	infisical identities auth attach <identity id> --method=kubernetes --kubernetes-host=https://10.0.0.1 --allowed-namespaces=payments`,
+	Short:                 "Used to attach an auth method to a machine identity",
+	Use:                   "attach [identity-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		authMethod, authMethodPath := identityAuthMethodFlag(cmd)
+
+		request, err := identityAuthRequest(cmd, authMethod)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		httpClient := newIdentitiesHTTPClient(cmd)
+		err = api.CallAttachIdentityAuthV1(httpClient, args[0], authMethodPath, request)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to attach %s auth", authMethod))
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Attached %s auth to identity %s", authMethod, args[0]))
+		if authMethod == "universal" {
+			fmt.Printf("Create a client secret to log in with using [infisical client-secret create --identityId=%s]\n", args[0])
+		}
+
+		Telemetry.CaptureEvent("cli-command:identities auth attach", posthog.NewProperties().Set("method", authMethod).Set("version", util.CLI_VERSION))
+	},
+}
+
+var identitiesAuthDetachCmd = &cobra.Command{
+	Example:               `infisical identities auth detach <identity id> --method=universal`,
+	Short:                 "Used to detach an auth method from a machine identity",
+	Use:                   "detach [identity-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		authMethod, authMethodPath := identityAuthMethodFlag(cmd)
+
+		httpClient := newIdentitiesHTTPClient(cmd)
+		err := api.CallDetachIdentityAuthV1(httpClient, args[0], authMethodPath)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to detach %s auth", authMethod))
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Detached %s auth from identity %s, it can no longer log in with it", authMethod, args[0]))
+
+		Telemetry.CaptureEvent("cli-command:identities auth detach", posthog.NewProperties().Set("method", authMethod).Set("version", util.CLI_VERSION))
+	},
+}
+
+var identitiesRoleCmd = &cobra.Command{
+	Short:                 "Manage the organization and project roles of machine identities",
+	Use:                   "role",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var identitiesRoleSetCmd = &cobra.Command{
+	Example: `
+	infisical identities role set <identity id> --role=member
+	infisical identities role set <identity id> --projectId=<project id> --role=developer`,
+	Short:                 "Used to set the organization role of a machine identity, or its role in a project",
+	Use:                   "set [identity-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		role, err := cmd.Flags().GetString("role")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		httpClient := newIdentitiesHTTPClient(cmd)
+
+		if projectId == "" {
+			err = api.CallUpdateIdentityV1(httpClient, api.UpdateIdentityV1Request{IdentityId: args[0], Role: role})
+			if err != nil {
+				util.HandleError(err, "Unable to set the organization role of the identity")
+			}
+			util.PrintSuccessMessage(fmt.Sprintf("Identity %s now has the organization role %s", args[0], role))
+			return
+		}
+
+		// identities that aren't in the project yet are added to it
+		err = api.CallUpdateProjectIdentityMembershipV2(httpClient, api.UpdateProjectIdentityMembershipRequest{
+			ProjectId:  projectId,
+			IdentityId: args[0],
+			Roles:      []api.ProjectIdentityRole{{Role: role}},
+		})
+		if api.IsNotFound(err) {
+			err = api.CallAddProjectIdentityMembershipV2(httpClient, projectId, args[0], role)
+		}
+		if err != nil {
+			util.HandleError(err, "Unable to set the project role of the identity")
+		}
+		util.PrintSuccessMessage(fmt.Sprintf("Identity %s now has the role %s in project %s", args[0], role, projectId))
+	},
+}
+
+var identitiesRoleRemoveCmd = &cobra.Command{
+	Example:               `infisical identities role remove <identity id> --projectId=<project id>`,
+	Short:                 "Used to remove a machine identity from a project",
+	Use:                   "remove [identity-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if projectId == "" {
+			util.PrintErrorMessageAndExit("Set the project to remove the identity from with --projectId")
+		}
+
+		httpClient := newIdentitiesHTTPClient(cmd)
+		err = api.CallDeleteProjectIdentityMembershipV2(httpClient, projectId, args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to remove the identity from the project")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Removed identity %s from project %s", args[0], projectId))
+	},
+}
+
+func newIdentitiesHTTPClient(cmd *cobra.Command) *resty.Client {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return newOrganizationHTTPClient(token, "manage identities")
+}
+
+// identitiesOrganizationId returns the organization of the --organizationId flag, or else the one of the
+// linked project
+func identitiesOrganizationId(cmd *cobra.Command, httpClient *resty.Client) string {
+	organizationId, err := cmd.Flags().GetString("organizationId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if organizationId != "" {
+		return organizationId
+	}
+
+	workspaceFile, err := util.GetWorkSpaceFromFile()
+	if err != nil {
+		util.PrintErrorMessageAndExit("Please either run infisical init to connect to a project or pass in the organization ID with --organizationId")
+	}
+
+	project, err := api.CallGetProjectById(httpClient, workspaceFile.WorkspaceId)
+	if err != nil {
+		util.HandleError(err, "Unable to get the organization of the linked project")
+	}
+
+	return project.OrganizationId
+}
+
+func identityAuthMethodFlag(cmd *cobra.Command) (string, string) {
+	authMethod, err := cmd.Flags().GetString("method")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	authMethodPath, ok := identityAuthMethodPaths[authMethod]
+	if !ok {
+		methods := []string{}
+		for method := range identityAuthMethodPaths {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+		util.PrintErrorMessageAndExit(fmt.Sprintf("--method must be one of %s", strings.Join(methods, ", ")))
+	}
+
+	return authMethod, authMethodPath
+}
+
+// identityAuthRequest builds the attach request of an auth method from the flags of the attach command
+func identityAuthRequest(cmd *cobra.Command, authMethod string) (interface{}, error) {
+	flags := cmd.Flags()
+
+	accessTokenTTL, err := flags.GetDuration("access-token-ttl")
+	if err != nil {
+		return nil, err
+	}
+
+	accessTokenMaxTTL, err := flags.GetDuration("access-token-max-ttl")
+	if err != nil {
+		return nil, err
+	}
+
+	trustedIps, err := flags.GetStringSlice("trusted-ips")
+	if err != nil {
+		return nil, err
+	}
+
+	tokenSettings := api.IdentityAuthTokenSettings{
+		AccessTokenTTL:        int(accessTokenTTL.Seconds()),
+		AccessTokenMaxTTL:     int(accessTokenMaxTTL.Seconds()),
+		AccessTokenTrustedIps: toIdentityTrustedIps(trustedIps),
+	}
+
+	caCert := ""
+	caCertFile, err := flags.GetString("ca-cert-file")
+	if err != nil {
+		return nil, err
+	}
+	if caCertFile != "" {
+		content, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read --ca-cert-file: %w", err)
+		}
+		caCert = string(content)
+	}
+
+	getString := func(name string) string {
+		value, _ := flags.GetString(name)
+		return value
+	}
+
+	switch authMethod {
+	case "universal":
+		clientSecretTrustedIps, err := flags.GetStringSlice("client-secret-trusted-ips")
+		if err != nil {
+			return nil, err
+		}
+		return api.AttachUniversalAuthRequest{
+			IdentityAuthTokenSettings: tokenSettings,
+			ClientSecretTrustedIps:    toIdentityTrustedIps(clientSecretTrustedIps),
+		}, nil
+
+	case "oidc":
+		if getString("oidc-discovery-url") == "" || getString("bound-issuer") == "" {
+			return nil, fmt.Errorf("oidc auth needs --oidc-discovery-url and --bound-issuer")
+		}
+
+		boundClaimArgs, err := flags.GetStringSlice("bound-claims")
+		if err != nil {
+			return nil, err
+		}
+		boundClaims := map[string]string{}
+		for _, boundClaim := range boundClaimArgs {
+			name, value, found := strings.Cut(boundClaim, "=")
+			if !found || name == "" {
+				return nil, fmt.Errorf("--bound-claims must be claim=value pairs, got %q", boundClaim)
+			}
+			boundClaims[name] = value
+		}
+
+		return api.AttachOidcAuthRequest{
+			IdentityAuthTokenSettings: tokenSettings,
+			OidcDiscoveryUrl:          getString("oidc-discovery-url"),
+			BoundIssuer:               getString("bound-issuer"),
+			BoundAudiences:            getString("bound-audiences"),
+			BoundClaims:               boundClaims,
+			BoundSubject:              getString("bound-subject"),
+			CaCert:                    caCert,
+		}, nil
+
+	case "aws":
+		if getString("allowed-principal-arns") == "" && getString("allowed-account-ids") == "" {
+			return nil, fmt.Errorf("aws auth needs --allowed-principal-arns or --allowed-account-ids, or any AWS principal could log in")
+		}
+		return api.AttachAwsAuthRequest{
+			IdentityAuthTokenSettings: tokenSettings,
+			StsEndpoint:               getString("sts-endpoint"),
+			AllowedPrincipalArns:      getString("allowed-principal-arns"),
+			AllowedAccountIds:         getString("allowed-account-ids"),
+		}, nil
+
+	case "kubernetes":
+		if getString("kubernetes-host") == "" {
+			return nil, fmt.Errorf("kubernetes auth needs --kubernetes-host")
+		}
+		return api.AttachKubernetesAuthRequest{
+			IdentityAuthTokenSettings: tokenSettings,
+			KubernetesHost:            getString("kubernetes-host"),
+			TokenReviewerJwt:          getString("token-reviewer-jwt"),
+			AllowedNamespaces:         getString("allowed-namespaces"),
+			AllowedNames:              getString("allowed-names"),
+			AllowedAudience:           getString("allowed-audience"),
+			CaCert:                    caCert,
+		}, nil
+
+	case "tls-cert":
+		if caCert == "" {
+			return nil, fmt.Errorf("tls-cert auth needs --ca-cert-file, the CA that issues the client certificates")
+		}
+		return api.AttachTlsCertAuthRequest{
+			IdentityAuthTokenSettings: tokenSettings,
+			CaCertificate:             caCert,
+			AllowedCommonNames:        getString("allowed-common-names"),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown auth method %s", authMethod)
+}
+
+func toIdentityTrustedIps(ipAddresses []string) []api.IdentityTrustedIp {
+	trustedIps := []api.IdentityTrustedIp{}
+	for _, ipAddress := range ipAddresses {
+		trustedIps = append(trustedIps, api.IdentityTrustedIp{IpAddress: ipAddress})
+	}
+	if len(trustedIps) == 0 {
+		return nil
+	}
+	return trustedIps
+}
+
+// addIdentityAuthFlags adds the settings of every auth method to the attach command
+func addIdentityAuthFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("access-token-ttl", 0, "how long access tokens are valid, e.g. 1h. Default: the default of Infisical")
+	cmd.Flags().Duration("access-token-max-ttl", 0, "how long access tokens can be renewed for, e.g. 720h. Default: the default of Infisical")
+	cmd.Flags().StringSlice("trusted-ips", []string{}, "IP addresses and CIDR ranges that may use access tokens. Default: all")
+	cmd.Flags().StringSlice("client-secret-trusted-ips", []string{}, "universal: IP addresses and CIDR ranges that may log in with client secrets. Default: all")
+	cmd.Flags().String("oidc-discovery-url", "", "oidc: the discovery URL of the identity provider")
+	cmd.Flags().String("bound-issuer", "", "oidc: the issuer tokens must have")
+	cmd.Flags().String("bound-audiences", "", "oidc: comma separated audiences tokens may have")
+	cmd.Flags().StringSlice("bound-claims", []string{}, "oidc: claim=value pairs tokens must have")
+	cmd.Flags().String("bound-subject", "", "oidc: the subject tokens must have")
+	cmd.Flags().String("sts-endpoint", "", "aws: the STS endpoint to verify logins with")
+	cmd.Flags().String("allowed-principal-arns", "", "aws: comma separated IAM principal ARNs that may log in")
+	cmd.Flags().String("allowed-account-ids", "", "aws: comma separated AWS account IDs that may log in")
+	cmd.Flags().String("kubernetes-host", "", "kubernetes: the URL of the Kubernetes API server")
+	cmd.Flags().String("token-reviewer-jwt", "", "kubernetes: the service account token used to review tokens")
+	cmd.Flags().String("allowed-namespaces", "", "kubernetes: comma separated namespaces that may log in")
+	cmd.Flags().String("allowed-names", "", "kubernetes: comma separated service account names that may log in")
+	cmd.Flags().String("allowed-audience", "", "kubernetes: the audience tokens must have")
+	cmd.Flags().String("allowed-common-names", "", "tls-cert: comma separated common names client certificates may have. Default: all")
+	cmd.Flags().String("ca-cert-file", "", "oidc and kubernetes: a PEM file of the CA to trust when calling the provider. tls-cert: the CA client certificates must be issued by")
+}
+
+func init() {
+	identitiesCmd.PersistentFlags().String("token", "", "Manage identities using a machine identity access token")
+	identitiesCmd.PersistentFlags().String("organizationId", "", "the organization of the identities. Default: the organization of the linked project")
+
+	identitiesCreateCmd.Flags().String("role", "no-access", "the organization role of the identity")
+	identitiesCmd.AddCommand(identitiesCreateCmd)
+	identitiesCmd.AddCommand(identitiesListCmd)
+	identitiesCmd.AddCommand(identitiesDeleteCmd)
+
+	identitiesAuthCmd.PersistentFlags().String("method", "", "the auth method: universal, oidc, aws, kubernetes or tls-cert")
+	identitiesAuthCmd.MarkPersistentFlagRequired("method")
+
+	addIdentityAuthFlags(identitiesAuthAttachCmd)
+	identitiesAuthCmd.AddCommand(identitiesAuthAttachCmd)
+	identitiesAuthCmd.AddCommand(identitiesAuthDetachCmd)
+	identitiesCmd.AddCommand(identitiesAuthCmd)
+
+	identitiesRoleCmd.PersistentFlags().String("projectId", "", "the project of the role. Default: the organization role")
+	identitiesRoleSetCmd.Flags().String("role", "", "the role slug, e.g. member or viewer")
+	identitiesRoleSetCmd.MarkFlagRequired("role")
+	identitiesRoleCmd.AddCommand(identitiesRoleSetCmd)
+	identitiesRoleCmd.AddCommand(identitiesRoleRemoveCmd)
+	identitiesCmd.AddCommand(identitiesRoleCmd)
+
+	rootCmd.AddCommand(identitiesCmd)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIdentityAuthAttachTestCmd(t *testing.T, flags map[string]string) *cobra.Command {
+	cmd := &cobra.Command{}
+	addIdentityAuthFlags(cmd)
+	for name, value := range flags {
+		assert.NoError(t, cmd.Flags().Set(name, value))
+	}
+	return cmd
+}
+
+func TestIdentityAuthRequest(t *testing.T) {
+	cmd := newIdentityAuthAttachTestCmd(t, map[string]string{
+		"oidc-discovery-url": "https://token.actions.githubusercontent.com",
+		"bound-issuer":       "https://token.actions.githubusercontent.com",
+		"bound-claims":       "repository=acme/api,ref=refs/heads/main",
+		"access-token-ttl":   "1h",
+	})
+
+	request, err := identityAuthRequest(cmd, "oidc")
+	assert.NoError(t, err)
+
+	oidcRequest := request.(api.AttachOidcAuthRequest)
+	assert.Equal(t, 3600, oidcRequest.AccessTokenTTL)
+	assert.Equal(t, map[string]string{"repository": "acme/api", "ref": "refs/heads/main"}, oidcRequest.BoundClaims)
+
+	_, err = identityAuthRequest(newIdentityAuthAttachTestCmd(t, map[string]string{}), "aws")
+	assert.Error(t, err)
+
+	_, err = identityAuthRequest(newIdentityAuthAttachTestCmd(t, map[string]string{}), "tls-cert")
+	assert.Error(t, err)
+}
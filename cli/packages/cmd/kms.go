@@ -0,0 +1,364 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/crypto"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/go-resty/resty/v2"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const kmsEnvelopeVersion = 1
+
+var kmsKeyIdRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+var kmsCmd = &cobra.Command{
+	Example: `infisical kms encrypt --key artifacts build.tar.gz --output-file build.tar.gz.enc
+infisical kms decrypt build.tar.gz.enc --output-file build.tar.gz
+infisical kms sign --key releases build.tar.gz --output-file build.tar.gz.sig
+infisical kms verify --key releases --signature-file build.tar.gz.sig build.tar.gz`,
+	Short: "Encrypt, decrypt, sign and verify files with Infisical KMS keys",
+	Long: `Encrypt, decrypt, sign and verify files, or stdin, with Infisical KMS keys, so key material never leaves
+Infisical. Keys are set by ID, or by name in the project of --projectId or of the project file.
+
+Files are envelope encrypted: they are encrypted locally with a new data key, and only the data key is
+sent to Infisical to be encrypted with the KMS key. Files are signed by their digest. The whole file is
+held in memory while it's encrypted or decrypted, so it has to fit in memory.`,
+	Use:                   "kms",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var kmsEncryptCmd = &cobra.Command{
+	Example:               `infisical kms encrypt --key artifacts build.tar.gz --output-file build.tar.gz.enc`,
+	Short:                 "Encrypt a file, or stdin, with a KMS key",
+	Use:                   "encrypt [file]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		plaintext := readKmsInput(args)
+		httpClient := newKmsHTTPClient(cmd)
+		keyId := getKmsKeyId(cmd, httpClient)
+
+		dataKey := make([]byte, 32)
+		if _, err := rand.Read(dataKey); err != nil {
+			util.HandleError(err, "Unable to generate a data key")
+		}
+
+		envelope, err := sealKmsEnvelope(plaintext, dataKey)
+		if err != nil {
+			util.HandleError(err, "Unable to encrypt the data")
+		}
+
+		encryptedDataKey, err := api.CallKmsEncryptV1(httpClient, keyId, api.KmsEncryptRequest{Plaintext: base64.StdEncoding.EncodeToString(dataKey)})
+		if err != nil {
+			util.HandleError(err, "Unable to encrypt the data key")
+		}
+		envelope.KeyId = keyId
+		envelope.EncryptedDataKey = encryptedDataKey.Ciphertext
+
+		encoded, err := json.Marshal(envelope)
+		if err != nil {
+			util.HandleError(err, "Unable to format the encrypted data")
+		}
+		writeKmsOutput(cmd, append(encoded, '\n'))
+
+		Telemetry.CaptureEvent("cli-command:kms encrypt", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var kmsDecryptCmd = &cobra.Command{
+	Example:               `infisical kms decrypt build.tar.gz.enc --output-file build.tar.gz`,
+	Short:                 "Decrypt a file, or stdin, encrypted with infisical kms encrypt",
+	Use:                   "decrypt [file]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var envelope kmsEnvelope
+		if err := json.Unmarshal(readKmsInput(args), &envelope); err != nil {
+			util.HandleError(err, "Unable to parse the encrypted data, it must be the output of infisical kms encrypt")
+		}
+		if envelope.Version != kmsEnvelopeVersion {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Unsupported version %d of the encrypted data, update the CLI to decrypt it", envelope.Version))
+		}
+
+		httpClient := newKmsHTTPClient(cmd)
+		decryptedDataKey, err := api.CallKmsDecryptV1(httpClient, envelope.KeyId, api.KmsDecryptRequest{Ciphertext: envelope.EncryptedDataKey})
+		if err != nil {
+			util.HandleError(err, "Unable to decrypt the data key")
+		}
+
+		dataKey, err := base64.StdEncoding.DecodeString(decryptedDataKey.Plaintext)
+		if err != nil {
+			util.HandleError(err, "Unable to decode the data key")
+		}
+
+		plaintext, err := openKmsEnvelope(envelope, dataKey)
+		if err != nil {
+			util.HandleError(err, "Unable to decrypt the data")
+		}
+		writeKmsOutput(cmd, plaintext)
+
+		Telemetry.CaptureEvent("cli-command:kms decrypt", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var kmsSignCmd = &cobra.Command{
+	Example:               `infisical kms sign --key releases build.tar.gz --output-file build.tar.gz.sig`,
+	Short:                 "Sign a file, or stdin, with a KMS key and print the base64 signature",
+	Use:                   "sign [file]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		data := readKmsInput(args)
+		httpClient := newKmsHTTPClient(cmd)
+		keyId := getKmsKeyId(cmd, httpClient)
+		signingAlgorithm := getKmsSigningAlgorithm(cmd, httpClient, keyId)
+
+		digest, isDigest := kmsDigest(signingAlgorithm, data)
+		signed, err := api.CallKmsSignV1(httpClient, keyId, api.KmsSignRequest{
+			Data:             base64.StdEncoding.EncodeToString(digest),
+			SigningAlgorithm: signingAlgorithm,
+			IsDigest:         isDigest,
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to sign the data")
+		}
+		writeKmsOutput(cmd, []byte(signed.Signature+"\n"))
+
+		Telemetry.CaptureEvent("cli-command:kms sign", posthog.NewProperties().Set("signingAlgorithm", signingAlgorithm).Set("version", util.CLI_VERSION))
+	},
+}
+
+var kmsVerifyCmd = &cobra.Command{
+	Example:               `infisical kms verify --key releases --signature-file build.tar.gz.sig build.tar.gz`,
+	Short:                 "Verify the signature of a file, or stdin, exiting with 1 when it isn't valid",
+	Use:                   "verify [file]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		signature, err := cmd.Flags().GetString("signature")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		signatureFile, err := cmd.Flags().GetString("signature-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if (signature == "") == (signatureFile == "") {
+			util.PrintErrorMessageAndExit("Set either --signature or --signature-file")
+		}
+		if signatureFile != "" {
+			content, err := os.ReadFile(signatureFile)
+			if err != nil {
+				util.HandleError(err, "Unable to read the signature file")
+			}
+			signature = strings.TrimSpace(string(content))
+		}
+
+		data := readKmsInput(args)
+		httpClient := newKmsHTTPClient(cmd)
+		keyId := getKmsKeyId(cmd, httpClient)
+		signingAlgorithm := getKmsSigningAlgorithm(cmd, httpClient, keyId)
+
+		digest, isDigest := kmsDigest(signingAlgorithm, data)
+		verified, err := api.CallKmsVerifyV1(httpClient, keyId, api.KmsVerifyRequest{
+			Data:             base64.StdEncoding.EncodeToString(digest),
+			Signature:        signature,
+			SigningAlgorithm: signingAlgorithm,
+			IsDigest:         isDigest,
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to verify the signature")
+		}
+
+		Telemetry.CaptureEvent("cli-command:kms verify", posthog.NewProperties().Set("signatureValid", verified.SignatureValid).Set("version", util.CLI_VERSION))
+
+		if !verified.SignatureValid {
+			util.PrintErrorMessageAndExit("The signature is not valid")
+		}
+		fmt.Println("The signature is valid")
+	},
+}
+
+// kmsEnvelope is data encrypted by infisical kms encrypt: the data encrypted with a data key, and the
+// data key encrypted with the KMS key
+type kmsEnvelope struct {
+	Version          int    `json:"version"`
+	KeyId            string `json:"keyId"`
+	EncryptedDataKey string `json:"encryptedDataKey"`
+	Nonce            []byte `json:"nonce"`
+	AuthTag          []byte `json:"authTag"`
+	Ciphertext       []byte `json:"ciphertext"`
+}
+
+func sealKmsEnvelope(plaintext []byte, dataKey []byte) (kmsEnvelope, error) {
+	encrypted, err := crypto.EncryptSymmetric(plaintext, dataKey)
+	if err != nil {
+		return kmsEnvelope{}, err
+	}
+
+	return kmsEnvelope{
+		Version:    kmsEnvelopeVersion,
+		Nonce:      encrypted.Nonce,
+		AuthTag:    encrypted.AuthTag,
+		Ciphertext: encrypted.CipherText,
+	}, nil
+}
+
+func openKmsEnvelope(envelope kmsEnvelope, dataKey []byte) ([]byte, error) {
+	if len(envelope.Nonce) == 0 || len(envelope.AuthTag) == 0 {
+		return nil, errors.New("the encrypted data has no nonce or auth tag")
+	}
+	return crypto.DecryptSymmetric(dataKey, envelope.Ciphertext, envelope.AuthTag, envelope.Nonce)
+}
+
+// kmsDigest returns the digest to sign data with the algorithm, so the data itself isn't sent. Data is
+// returned as is for algorithms without a known hash.
+func kmsDigest(signingAlgorithm string, data []byte) ([]byte, bool) {
+	switch {
+	case strings.HasSuffix(signingAlgorithm, "SHA_256"):
+		digest := sha256.Sum256(data)
+		return digest[:], true
+	case strings.HasSuffix(signingAlgorithm, "SHA_384"):
+		digest := sha512.Sum384(data)
+		return digest[:], true
+	case strings.HasSuffix(signingAlgorithm, "SHA_512"):
+		digest := sha512.Sum512(data)
+		return digest[:], true
+	default:
+		return data, false
+	}
+}
+
+// newKmsHTTPClient returns a client authenticated with the token given to the command, or as the logged
+// in user
+func newKmsHTTPClient(cmd *cobra.Command) *resty.Client {
+	return api.NewHTTPClient().SetAuthToken(getAccessTokenOrLogin(cmd))
+}
+
+// getKmsKeyId returns the ID of the key of --key, looking it up by name when it isn't an ID
+func getKmsKeyId(cmd *cobra.Command, httpClient *resty.Client) string {
+	key, err := cmd.Flags().GetString("key")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if key == "" {
+		util.PrintErrorMessageAndExit("You must set the --key flag")
+	}
+	if kmsKeyIdRegex.MatchString(key) {
+		return key
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if projectId == "" {
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details, set the project of the key with --projectId")
+		}
+		projectId = workspaceFile.WorkspaceId
+	}
+
+	kmsKey, err := api.CallGetKmsKeyByNameV1(httpClient, projectId, key)
+	if err != nil {
+		util.HandleError(err, fmt.Sprintf("Unable to find the KMS key %s", key))
+	}
+	return kmsKey.Id
+}
+
+// getKmsSigningAlgorithm returns the algorithm of --algorithm, or the first one the key supports
+func getKmsSigningAlgorithm(cmd *cobra.Command, httpClient *resty.Client, keyId string) string {
+	signingAlgorithm, err := cmd.Flags().GetString("algorithm")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if signingAlgorithm != "" {
+		return strings.ToUpper(signingAlgorithm)
+	}
+
+	signingAlgorithms, err := api.CallGetKmsSigningAlgorithmsV1(httpClient, keyId)
+	if err != nil {
+		util.HandleError(err, "Unable to get the signing algorithms of the key")
+	}
+	if len(signingAlgorithms) == 0 {
+		util.PrintErrorMessageAndExit("The key can't sign, use a KMS key for signing")
+	}
+	return signingAlgorithms[0]
+}
+
+// readKmsInput reads the file of the arguments, or stdin without one or with -
+func readKmsInput(args []string) []byte {
+	var content []byte
+	var err error
+	if len(args) == 0 || args[0] == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(args[0])
+	}
+	if err != nil {
+		util.HandleError(err, "Unable to read the input")
+	}
+	return content
+}
+
+// writeKmsOutput writes to the file of --output-file, readable by the current user only, or to stdout
+func writeKmsOutput(cmd *cobra.Command, content []byte) {
+	outputPath, err := cmd.Flags().GetString("output-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if outputPath == "" || outputPath == "-" {
+		if _, err := os.Stdout.Write(content); err != nil {
+			util.HandleError(err, "Unable to write the output")
+		}
+		return
+	}
+
+	if err := os.WriteFile(outputPath, content, 0600); err != nil {
+		util.HandleError(err, "Unable to write the output")
+	}
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{kmsEncryptCmd, kmsDecryptCmd, kmsSignCmd, kmsVerifyCmd} {
+		cmd.Flags().String("token", "", "Use a machine identity access token")
+		if cmd != kmsDecryptCmd {
+			// decrypt uses the key the data was encrypted with
+			cmd.Flags().String("key", "", "the ID or name of the KMS key")
+			cmd.Flags().String("projectId", "", "the project of the KMS key when it is set by name. Defaults to the project of the project file")
+		}
+		if cmd != kmsVerifyCmd {
+			cmd.Flags().String("output-file", "", "the file to write to, defaults to stdout")
+		}
+		kmsCmd.AddCommand(cmd)
+	}
+
+	kmsSignCmd.Flags().String("algorithm", "", "the signing algorithm, such as RSASSA_PSS_SHA_256 or ECDSA_SHA_256. Defaults to the first one the key supports")
+	kmsVerifyCmd.Flags().String("algorithm", "", "the signing algorithm the signature was made with. Defaults to the first one the key supports")
+	kmsVerifyCmd.Flags().String("signature", "", "the base64 signature to verify")
+	kmsVerifyCmd.Flags().String("signature-file", "", "the file with the base64 signature to verify, as written by infisical kms sign")
+
+	rootCmd.AddCommand(kmsCmd)
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKmsEnvelope(t *testing.T) {
+	dataKey := make([]byte, 32)
+	for i := range dataKey {
+		dataKey[i] = byte(i)
+	}
+
+	envelope, err := sealKmsEnvelope([]byte("artifact"), dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, kmsEnvelopeVersion, envelope.Version)
+	assert.NotEqual(t, []byte("artifact"), envelope.Ciphertext)
+
+	plaintext, err := openKmsEnvelope(envelope, dataKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("artifact"), plaintext)
+
+	dataKey[0] ^= 1
+	_, err = openKmsEnvelope(envelope, dataKey)
+	assert.Error(t, err)
+}
+
+func TestKmsDigest(t *testing.T) {
+	expected := sha256.Sum256([]byte("data"))
+
+	digest, isDigest := kmsDigest("RSASSA_PSS_SHA_256", []byte("data"))
+	assert.True(t, isDigest)
+	assert.Equal(t, expected[:], digest)
+
+	digest, isDigest = kmsDigest("UNKNOWN", []byte("data"))
+	assert.False(t, isDigest)
+	assert.Equal(t, []byte("data"), digest)
+}
@@ -0,0 +1,411 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+
+	infisicalSdk "github.com/infisical/go-sdk"
+)
+
+const defaultExecCredentialApiVersion = "client.authentication.k8s.io/v1"
+
+// cachedExecCredentialMargin is how long before it expires a cached credential is leased again, so kubectl
+// doesn't get a token that expires during its request
+const cachedExecCredentialMargin = 10 * time.Second
+
+var credentialSecretNameCharacters = regexp.MustCompile(`[^A-Z0-9]+`)
+
+var kubectlCredentialCmd = &cobra.Command{
+	Example: `infisical kubectl-credential --secret-name KUBE_TOKEN --env prod --projectId <project id>
+infisical kubectl-credential --dynamic-secret deployer --ttl 15m --env prod --projectId <project id>`,
+	Short: "Print a Kubernetes exec credential with a cluster token from Infisical",
+	Long: `Print a Kubernetes exec credential with a cluster token from Infisical, so kubeconfigs get their token
+on demand instead of storing it. The token is the value of a secret, or of a lease of a dynamic secret
+such as the Kubernetes one. Leases are cached in the user cache dir until they expire, so kubectl reuses
+them instead of creating one every time it runs. A cached lease is only reused by the token or logged in
+user that created it. The global --no-cache flag, which also turns off the cache of API responses,
+creates a new lease without reading or writing the cache.
+
+Use it as the exec plugin of a user in the kubeconfig:
+
+  users:
+  - name: infisical
+    user:
+      exec:
+        apiVersion: client.authentication.k8s.io/v1
+        command: infisical
+        args: [kubectl-credential, --secret-name, KUBE_TOKEN, --env, prod, --projectId, <project id>]
+        interactiveMode: Never`,
+	Use:                   "kubectl-credential",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Annotations:           map[string]string{credentialHelperAnnotation: "true"},
+	Run: func(cmd *cobra.Command, args []string) {
+		credentialSource := getCredentialLocationFlags(cmd)
+
+		var err error
+		credentialSource.SecretName, err = cmd.Flags().GetString("secret-name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		credentialSource.DynamicSecretName, err = cmd.Flags().GetString("dynamic-secret")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if (credentialSource.SecretName == "") == (credentialSource.DynamicSecretName == "") {
+			util.PrintErrorMessageAndExit("Set either --secret-name or --dynamic-secret")
+		}
+
+		credentialSource.TTL, err = cmd.Flags().GetString("ttl")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tokenKey, err := cmd.Flags().GetString("token-key")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		credential := execCredential{
+			Kind:       "ExecCredential",
+			ApiVersion: getExecCredentialApiVersion(),
+			Spec:       map[string]interface{}{},
+		}
+
+		credentialSource.Token, err = getTokenOrLoginInCI(cmd)
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		cachePath := ""
+		if credentialSource.DynamicSecretName != "" && !api.DisableResponseCache {
+			cachePath = getExecCredentialCachePath(credentialSource, tokenKey)
+			if cachedCredential, found := readCachedExecCredential(cachePath, time.Now()); found {
+				cachedCredential.ApiVersion = credential.ApiVersion
+				printExecCredential(cachedCredential)
+				return
+			}
+		}
+
+		if credentialSource.DynamicSecretName != "" {
+			leaseCredentials, expireAt, err := createCredentialLease(credentialSource)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to lease the dynamic secret %s", credentialSource.DynamicSecretName))
+			}
+
+			token, ok := leaseCredentials[tokenKey].(string)
+			if !ok || token == "" {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("The lease of %s has no %s credential, set the right one with --token-key", credentialSource.DynamicSecretName, tokenKey))
+			}
+			credential.Status.Token = token
+			credential.Status.ExpirationTimestamp = expireAt.UTC().Format(time.RFC3339)
+
+			if err := writeCachedExecCredential(cachePath, credential); err != nil {
+				log.Debug().Msgf("not caching the exec credential because %v", err)
+			}
+		} else {
+			token, err := fetchCredentialSecret(credentialSource)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to get the secret %s", credentialSource.SecretName))
+			}
+			credential.Status.Token = token
+		}
+
+		printExecCredential(credential)
+
+		Telemetry.CaptureEvent("cli-command:kubectl-credential", posthog.NewProperties().Set("dynamic", credentialSource.DynamicSecretName != "").Set("version", util.CLI_VERSION))
+	},
+}
+
+// execCredential is the ExecCredential object of client.authentication.k8s.io, as read by kubectl
+type execCredential struct {
+	Kind       string                 `json:"kind"`
+	ApiVersion string                 `json:"apiVersion"`
+	Spec       map[string]interface{} `json:"spec"`
+	Status     struct {
+		Token               string `json:"token"`
+		ExpirationTimestamp string `json:"expirationTimestamp,omitempty"`
+	} `json:"status"`
+}
+
+func printExecCredential(credential execCredential) {
+	encoded, err := json.Marshal(credential)
+	if err != nil {
+		util.HandleError(err, "Unable to format the exec credential")
+	}
+	fmt.Println(string(encoded))
+}
+
+// getExecCredentialCachePath is the file the credentials leased for the source are cached in, or empty
+// when the user has no cache dir or there's no one to lease as. Leases are only shared by the same token
+// or logged in user.
+func getExecCredentialCachePath(source credentialSource, tokenKey string) string {
+	identity := getExecCredentialCacheIdentity(source.Token)
+	if identity == "" {
+		return ""
+	}
+
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		log.Debug().Msgf("not caching the exec credential because %v", err)
+		return ""
+	}
+
+	projectId := source.ProjectId
+	if projectId == "" {
+		if workspaceFile, err := util.GetWorkSpaceFromFile(); err == nil {
+			projectId = workspaceFile.WorkspaceId
+		}
+	}
+
+	key := sha256.Sum256([]byte(strings.Join([]string{
+		config.INFISICAL_URL, identity, projectId, source.Environment, source.SecretsPath, source.DynamicSecretName, source.TTL, tokenKey,
+	}, "\n")))
+	return filepath.Join(cacheDir, "infisical", "kubectl", "credential-"+hex.EncodeToString(key[:8])+".json")
+}
+
+// getExecCredentialCacheIdentity identifies who leases the credential: the token given to the command or
+// found in CI, else the logged in user. It's empty when there's neither.
+func getExecCredentialCacheIdentity(token *models.TokenDetails) string {
+	if token != nil && token.Token != "" {
+		tokenHash := sha256.Sum256([]byte(token.Token))
+		return token.Type + ":" + hex.EncodeToString(tokenHash[:])
+	}
+
+	configFile, err := util.GetConfigFile()
+	if err != nil {
+		return ""
+	}
+	email, domain := util.GetLoggedInUserEmailAndDomain(configFile)
+	if email == "" {
+		return ""
+	}
+	return "user:" + email + "@" + domain
+}
+
+// readCachedExecCredential returns the cached credential when it's still valid at now
+func readCachedExecCredential(path string, now time.Time) (execCredential, bool) {
+	if path == "" {
+		return execCredential{}, false
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return execCredential{}, false
+	}
+
+	var credential execCredential
+	if err := json.Unmarshal(content, &credential); err != nil || credential.Status.Token == "" {
+		return execCredential{}, false
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, credential.Status.ExpirationTimestamp)
+	if err != nil || !now.Add(cachedExecCredentialMargin).Before(expiresAt) {
+		return execCredential{}, false
+	}
+	return credential, true
+}
+
+func writeCachedExecCredential(path string, credential execCredential) error {
+	if path == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// write next to the file and rename, so a concurrent kubectl never reads half of it
+	temporaryFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temporaryFile.Name())
+
+	if _, err := temporaryFile.Write(content); err != nil {
+		temporaryFile.Close()
+		return err
+	}
+	if err := temporaryFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temporaryFile.Name(), path)
+}
+
+// getExecCredentialApiVersion answers in the version kubectl asked for in KUBERNETES_EXEC_INFO. v1 and
+// v1beta1 share the fields used here.
+func getExecCredentialApiVersion() string {
+	var execInfo struct {
+		ApiVersion string `json:"apiVersion"`
+	}
+	if err := json.Unmarshal([]byte(os.Getenv("KUBERNETES_EXEC_INFO")), &execInfo); err != nil || execInfo.ApiVersion == "" {
+		return defaultExecCredentialApiVersion
+	}
+	return execInfo.ApiVersion
+}
+
+// credentialSource is where a credential helper reads a credential from: a secret, or a lease of a
+// dynamic secret
+type credentialSource struct {
+	Token             *models.TokenDetails
+	ProjectId         string
+	Environment       string
+	SecretsPath       string
+	SecretName        string
+	DynamicSecretName string
+	TTL               string
+}
+
+// getCredentialSourceFlags reads the project, environment and folder of the credential, and the token to
+// fetch it with
+func getCredentialSourceFlags(cmd *cobra.Command) credentialSource {
+	source := getCredentialLocationFlags(cmd)
+
+	var err error
+	source.Token, err = getTokenOrLoginInCI(cmd)
+	if err != nil {
+		util.HandleError(err)
+	}
+
+	return source
+}
+
+// getCredentialLocationFlags reads the project, environment and folder of the credential
+func getCredentialLocationFlags(cmd *cobra.Command) credentialSource {
+	source := credentialSource{}
+
+	var err error
+	source.Environment, err = cmd.Flags().GetString("env")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if !cmd.Flags().Changed("env") {
+		if environmentFromWorkspace := util.GetEnvFromWorkspaceFile(); environmentFromWorkspace != "" {
+			source.Environment = environmentFromWorkspace
+		}
+	}
+
+	source.ProjectId, err = cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	source.SecretsPath, err = cmd.Flags().GetString("path")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return source
+}
+
+// fetchCredentialSecret returns the value of the secret of the source, personal values first as with
+// infisical secrets
+func fetchCredentialSecret(source credentialSource) (string, error) {
+	secrets, err := fetchCredentialSecrets(source)
+	if err != nil {
+		return "", err
+	}
+
+	for _, secret := range secrets {
+		if secret.Key == source.SecretName {
+			return secret.Value, nil
+		}
+	}
+	return "", fmt.Errorf("no secret named %s in %s:%s", source.SecretName, source.Environment, source.SecretsPath)
+}
+
+// fetchCredentialSecrets returns the secrets in the folder of the source, with personal values in place
+// of shared ones
+func fetchCredentialSecrets(source credentialSource) ([]models.SingleEnvironmentVariable, error) {
+	request := models.GetAllSecretsParameters{
+		Environment: source.Environment,
+		WorkspaceId: source.ProjectId,
+		SecretsPath: source.SecretsPath,
+	}
+	if source.Token != nil && source.Token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		request.InfisicalToken = source.Token.Token
+	} else if source.Token != nil && source.Token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		request.UniversalAuthAccessToken = source.Token.Token
+	}
+
+	secrets, err := util.GetAllEnvironmentVariables(request, "")
+	if err != nil {
+		return nil, err
+	}
+	return util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL), nil
+}
+
+// credentialSecretName turns a registry, host or other name into a secret name with the prefix, e.g.
+// DOCKER_ and ghcr.io into DOCKER_GHCR_IO
+func credentialSecretName(prefix string, name string) string {
+	name = credentialSecretNameCharacters.ReplaceAllString(strings.ToUpper(name), "_")
+	return prefix + strings.Trim(name, "_")
+}
+
+// createCredentialLease leases the dynamic secret of the source and returns its credentials with the
+// time the lease expires
+func createCredentialLease(source credentialSource) (map[string]any, time.Time, error) {
+	httpClient, tokenDetails, projectId := newProjectHTTPClient(source.Token, source.ProjectId)
+
+	project, err := api.CallGetProjectById(httpClient, projectId)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	infisicalClient := newMachineIdentityLoginClient()
+	infisicalClient.Auth().SetAccessToken(tokenDetails.Token)
+
+	leaseCredentials, _, leaseDetails, err := infisicalClient.DynamicSecrets().Leases().Create(infisicalSdk.CreateDynamicSecretLeaseOptions{
+		DynamicSecretName: source.DynamicSecretName,
+		ProjectSlug:       project.Slug,
+		TTL:               source.TTL,
+		SecretPath:        source.SecretsPath,
+		EnvironmentSlug:   source.Environment,
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if leaseCredentials == nil {
+		return nil, time.Time{}, errors.New("the lease has no credentials")
+	}
+
+	return leaseCredentials, leaseDetails.ExpireAt, nil
+}
+
+func init() {
+	kubectlCredentialCmd.Flags().String("token", "", "Fetch the credential using a service token or machine identity access token")
+	kubectlCredentialCmd.Flags().String("projectId", "", "manually set the project ID to fetch the credential from, required when using a machine identity")
+	kubectlCredentialCmd.Flags().StringP("env", "e", "dev", "the environment of the secret")
+	kubectlCredentialCmd.Flags().String("path", "/", "the folder of the secret")
+	kubectlCredentialCmd.Flags().String("secret-name", "", "the secret that holds the cluster token")
+	kubectlCredentialCmd.Flags().String("dynamic-secret", "", "the dynamic secret to lease the cluster token from")
+	kubectlCredentialCmd.Flags().String("token-key", "TOKEN", "the credential of the lease that holds the cluster token, with --dynamic-secret")
+	kubectlCredentialCmd.Flags().String("ttl", "", "the lifetime of leases, e.g. 15m. Defaults to the TTL of the dynamic secret")
+	rootCmd.AddCommand(kubectlCredentialCmd)
+}
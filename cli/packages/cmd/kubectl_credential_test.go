@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetExecCredentialApiVersion(t *testing.T) {
+	t.Setenv("KUBERNETES_EXEC_INFO", "")
+	assert.Equal(t, "client.authentication.k8s.io/v1", getExecCredentialApiVersion())
+
+	t.Setenv("KUBERNETES_EXEC_INFO", `{"kind":"ExecCredential","apiVersion":"client.authentication.k8s.io/v1beta1","spec":{"interactive":false}}`)
+	assert.Equal(t, "client.authentication.k8s.io/v1beta1", getExecCredentialApiVersion())
+}
+
+func TestCachedExecCredential(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "kubectl", "credential.json")
+
+	_, found := readCachedExecCredential(path, now)
+	assert.False(t, found, "nothing is cached yet")
+
+	credential := execCredential{Kind: "ExecCredential", ApiVersion: defaultExecCredentialApiVersion, Spec: map[string]interface{}{}}
+	credential.Status.Token = "cluster-token"
+	credential.Status.ExpirationTimestamp = now.Add(15 * time.Minute).Format(time.RFC3339)
+	assert.NoError(t, writeCachedExecCredential(path, credential))
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	tests := []struct {
+		name      string
+		now       time.Time
+		wantFound bool
+	}{
+		{name: "valid", now: now, wantFound: true},
+		{name: "about to expire", now: now.Add(15*time.Minute - cachedExecCredentialMargin), wantFound: false},
+		{name: "expired", now: now.Add(time.Hour), wantFound: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cached, found := readCachedExecCredential(path, test.now)
+			assert.Equal(t, test.wantFound, found)
+			if test.wantFound {
+				assert.Equal(t, "cluster-token", cached.Status.Token)
+			}
+		})
+	}
+
+	assert.NoError(t, os.WriteFile(path, []byte(`{"status":{"token":"cluster-token"}}`), 0600))
+	_, found = readCachedExecCredential(path, now)
+	assert.False(t, found, "credentials without an expiration aren't cached")
+}
+
+func TestGetExecCredentialCachePath(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	source := credentialSource{
+		Token:             &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "access-token"},
+		ProjectId:         "project",
+		Environment:       "prod",
+		SecretsPath:       "/",
+		TTL:               "15m",
+		DynamicSecretName: "deployer",
+	}
+	path := getExecCredentialCachePath(source, "TOKEN")
+	assert.NotEmpty(t, path)
+	assert.Equal(t, path, getExecCredentialCachePath(source, "TOKEN"))
+	assert.NotContains(t, path, "access-token")
+
+	otherSource := source
+	otherSource.Environment = "staging"
+	assert.NotEqual(t, path, getExecCredentialCachePath(otherSource, "TOKEN"))
+	assert.NotEqual(t, path, getExecCredentialCachePath(source, "CA_TOKEN"))
+
+	otherIdentity := source
+	otherIdentity.Token = &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "other-access-token"}
+	assert.NotEqual(t, path, getExecCredentialCachePath(otherIdentity, "TOKEN"), "leases aren't shared between identities")
+
+	loggedOut := source
+	loggedOut.Token = nil
+	assert.Empty(t, getExecCredentialCachePath(loggedOut, "TOKEN"), "nothing is cached without a token or a logged in user")
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(os.Getenv("HOME"), util.CONFIG_FOLDER_NAME), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(os.Getenv("HOME"), util.CONFIG_FOLDER_NAME, util.CONFIG_FILE_NAME), []byte(`{"loggedInUserEmail":"user@example.com"}`), 0600))
+	userPath := getExecCredentialCachePath(loggedOut, "TOKEN")
+	assert.NotEmpty(t, userPath)
+	assert.NotEqual(t, path, userPath)
+}
@@ -27,7 +27,6 @@ import (
 	"github.com/Infisical/infisical-merge/packages/srp"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/fatih/color"
-	"github.com/go-resty/resty/v2"
 	"github.com/manifoldco/promptui"
 	"github.com/posthog/posthog-go"
 	"github.com/rs/cors"
@@ -130,7 +129,7 @@ func handleOidcAuthLogin(cmd *cobra.Command, infisicalClient infisicalSdk.Infisi
 		return infisicalSdk.MachineIdentityCredential{}, err
 	}
 
-	jwt, err := util.GetCmdFlagOrEnv(cmd, "oidc-jwt", util.INFISICAL_OIDC_AUTH_JWT_NAME)
+	jwt, err := getOidcAuthJwt(cmd)
 	if err != nil {
 		return infisicalSdk.MachineIdentityCredential{}, err
 	}
@@ -138,6 +137,99 @@ func handleOidcAuthLogin(cmd *cobra.Command, infisicalClient infisicalSdk.Infisi
 	return infisicalClient.Auth().OidcAuthLogin(identityId, jwt)
 }
 
+// handleTlsCertAuthLogin proves the identity with the client certificate given with --client-certificate and
+// --client-key, which Infisical verifies against the CA configured on the identity
+func handleTlsCertAuthLogin(cmd *cobra.Command, infisicalClient infisicalSdk.InfisicalClientInterface) (credential infisicalSdk.MachineIdentityCredential, e error) {
+
+	identityId, err := util.GetCmdFlagOrEnv(cmd, "machine-identity-id", util.INFISICAL_MACHINE_IDENTITY_ID_NAME)
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, err
+	}
+
+	if !api.HasClientCertificate() {
+		return infisicalSdk.MachineIdentityCredential{}, fmt.Errorf("please provide a client certificate with the --client-certificate and --client-key flags")
+	}
+
+	loginResponse, err := api.CallTlsCertAuthLoginV1(api.NewHTTPClient(), api.TlsCertAuthLoginV1Request{IdentityId: identityId})
+	if err != nil {
+		return infisicalSdk.MachineIdentityCredential{}, err
+	}
+
+	return infisicalSdk.MachineIdentityCredential{AccessToken: loginResponse.AccessToken}, nil
+}
+
+// getOidcAuthJwt returns the ID token given with --oidc-jwt or --oidc-jwt-file, or else the one found in the
+// environment the CLI runs in
+func getOidcAuthJwt(cmd *cobra.Command) (string, error) {
+	jwt, err := util.GetCmdFlagOrEnv(cmd, "oidc-jwt", util.INFISICAL_OIDC_AUTH_JWT_NAME)
+	if err == nil {
+		return jwt, nil
+	}
+
+	jwtFile, err := cmd.Flags().GetString("oidc-jwt-file")
+	if err != nil {
+		return "", err
+	}
+	if jwtFile != "" {
+		return util.ReadOidcTokenFile(jwtFile)
+	}
+
+	audience, err := cmd.Flags().GetString("oidc-audience")
+	if err != nil {
+		return "", err
+	}
+	if audience == "" {
+		audience = os.Getenv(util.INFISICAL_OIDC_AUTH_AUDIENCE_NAME)
+	}
+
+	jwt, source, err := util.GetAmbientOidcToken(audience)
+	if err != nil {
+		return "", err
+	}
+	log.Debug().Msgf("using the OIDC token from the %s", source)
+	return jwt, nil
+}
+
+var machineIdentityAuthStrategies = map[util.AuthStrategyType]func(cmd *cobra.Command, infisicalClient infisicalSdk.InfisicalClientInterface) (credential infisicalSdk.MachineIdentityCredential, e error){
+	util.AuthStrategy.UNIVERSAL_AUTH:    handleUniversalAuthLogin,
+	util.AuthStrategy.KUBERNETES_AUTH:   handleKubernetesAuthLogin,
+	util.AuthStrategy.AZURE_AUTH:        handleAzureAuthLogin,
+	util.AuthStrategy.GCP_ID_TOKEN_AUTH: handleGcpIdTokenAuthLogin,
+	util.AuthStrategy.GCP_IAM_AUTH:      handleGcpIamAuthLogin,
+	util.AuthStrategy.AWS_IAM_AUTH:      handleAwsIamAuthLogin,
+	util.AuthStrategy.OIDC_AUTH:         handleOidcAuthLogin,
+	util.AuthStrategy.TLS_CERT_AUTH:     handleTlsCertAuthLogin,
+}
+
+// loginWithMachineIdentity logs in with a machine identity auth method for commands that fetch secrets
+// right away, so they need no stored token. The credentials are read from the flags of cmd and the
+// environment, as with infisical login. It also returns how long the token is valid for, to renew it.
+func loginWithMachineIdentity(cmd *cobra.Command, strategy util.AuthStrategyType) (*models.TokenDetails, *util.AccessTokenLifetime, error) {
+	credential, err := machineIdentityAuthStrategies[strategy](cmd, newMachineIdentityLoginClient())
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to authenticate with %s [err=%v]", formatAuthMethod(string(strategy)), err)
+	}
+
+	token := &models.TokenDetails{
+		Type:   util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER,
+		Token:  credential.AccessToken,
+		Source: fmt.Sprintf("%s login", formatAuthMethod(string(strategy))),
+	}
+	lifetime := &util.AccessTokenLifetime{
+		ExpiresIn: time.Duration(credential.ExpiresIn) * time.Second,
+		MaxTTL:    time.Duration(credential.AccessTokenMaxTTL) * time.Second,
+	}
+	return token, lifetime, nil
+}
+
+func newMachineIdentityLoginClient() infisicalSdk.InfisicalClientInterface {
+	return infisicalSdk.NewInfisicalClient(context.Background(), infisicalSdk.Config{
+		SiteUrl:          config.INFISICAL_URL,
+		UserAgent:        api.USER_AGENT,
+		AutoTokenRefresh: false,
+	})
+}
+
 func formatAuthMethod(authMethod string) string {
 	return strings.ReplaceAll(authMethod, "-", " ")
 }
@@ -152,6 +244,7 @@ var loginCmd = &cobra.Command{
 	Use:                   "login",
 	Short:                 "Login into your Infisical account",
 	DisableFlagsInUseLine: true,
+	Annotations:           map[string]string{keyringCommandAnnotation: "true"},
 	Run: func(cmd *cobra.Command, args []string) {
 
 		presetDomain := config.INFISICAL_URL
@@ -193,13 +286,24 @@ var loginCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		// on CI platforms that provide ID tokens, a machine identity needs no stored credential
+		if !cmd.Flags().Changed("method") && os.Getenv(util.INFISICAL_MACHINE_IDENTITY_ID_NAME) != "" {
+			if platform, found := util.DetectCIOidcTokenSource(); found {
+				log.Debug().Msgf("running on %s, logging in with OIDC auth", platform)
+				loginMethod = string(util.AuthStrategy.OIDC_AUTH)
+			}
+		}
+
 		authMethodValid, strategy := util.IsAuthMethodValid(loginMethod, true)
+		if loginMethod == LDAP_LOGIN_METHOD {
+			authMethodValid = true
+		}
 		if !authMethodValid {
 			util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid login method: %s", loginMethod))
 		}
 
 		// standalone user auth
-		if loginMethod == "user" {
+		if loginMethod == "user" || loginMethod == LDAP_LOGIN_METHOD {
 			currentLoggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
 			// if the key can't be found or there is an error getting current credentials from key ring, allow them to override
 			if err != nil && (strings.Contains(err.Error(), "we couldn't find your logged in details")) {
@@ -256,7 +360,19 @@ var loginCmd = &cobra.Command{
 			var userCredentialsToBeStored models.UserCredentials
 
 			interactiveLogin := false
-			if cmd.Flags().Changed("interactive") {
+			if loginMethod == LDAP_LOGIN_METHOD {
+				interactiveLogin = true
+				userCredentialsToBeStored, err = ldapCliLogin(cmd)
+				if err != nil {
+					util.HandleError(err, "Unable to log in with LDAP")
+				}
+			} else if cmd.Flags().Changed("device-code") {
+				interactiveLogin = true
+				userCredentialsToBeStored, err = deviceCodeCliLogin()
+				if err != nil {
+					util.HandleError(err, "Unable to log in with a device code")
+				}
+			} else if cmd.Flags().Changed("interactive") {
 				interactiveLogin = true
 				cliDefaultLogin(&userCredentialsToBeStored)
 			}
@@ -302,17 +418,7 @@ var loginCmd = &cobra.Command{
 			Telemetry.CaptureEvent("cli-command:login", posthog.NewProperties().Set("infisical-backend", config.INFISICAL_URL).Set("version", util.CLI_VERSION))
 		} else {
 
-			authStrategies := map[util.AuthStrategyType]func(cmd *cobra.Command, infisicalClient infisicalSdk.InfisicalClientInterface) (credential infisicalSdk.MachineIdentityCredential, e error){
-				util.AuthStrategy.UNIVERSAL_AUTH:    handleUniversalAuthLogin,
-				util.AuthStrategy.KUBERNETES_AUTH:   handleKubernetesAuthLogin,
-				util.AuthStrategy.AZURE_AUTH:        handleAzureAuthLogin,
-				util.AuthStrategy.GCP_ID_TOKEN_AUTH: handleGcpIdTokenAuthLogin,
-				util.AuthStrategy.GCP_IAM_AUTH:      handleGcpIamAuthLogin,
-				util.AuthStrategy.AWS_IAM_AUTH:      handleAwsIamAuthLogin,
-				util.AuthStrategy.OIDC_AUTH:         handleOidcAuthLogin,
-			}
-
-			credential, err := authStrategies[strategy](cmd, infisicalClient)
+			credential, err := machineIdentityAuthStrategies[strategy](cmd, infisicalClient)
 
 			if err != nil {
 				euErrorMessage := ""
@@ -359,7 +465,7 @@ func cliDefaultLogin(userCredentialsToBeStored *models.UserCredentials) {
 		for i < 6 {
 			mfaVerifyCode := askForMFACode("email")
 
-			httpClient := resty.New()
+			httpClient := api.NewHTTPClient()
 			httpClient.SetAuthToken(loginTwoResponse.Token)
 			verifyMFAresponse, mfaErrorResponse, requestError := api.CallVerifyMfaToken(httpClient, api.VerifyMfaTokenRequest{
 				Email:    email,
@@ -507,14 +613,19 @@ func init() {
 	rootCmd.AddCommand(loginCmd)
 	loginCmd.Flags().Bool("clear-domains", false, "clear all self-hosting domains from the config file")
 	loginCmd.Flags().BoolP("interactive", "i", false, "login via the command line")
-	loginCmd.Flags().String("method", "user", "login method [user, universal-auth]")
+	loginCmd.Flags().Bool("device-code", false, "login by opening a link on any device and confirming a code, for headless servers and SSH sessions. Works with SSO")
+	loginCmd.Flags().String("method", "user", "login method [user, ldap, universal-auth, kubernetes, azure, gcp-id-token (or gcp), gcp-iam, aws-iam, oidc, tls-cert]")
+	loginCmd.Flags().String("organization-slug", "", "the slug of the organization to log in to with LDAP")
+	loginCmd.Flags().String("username", "", "LDAP username. The password is prompted for [can also set via environment variable name: INFISICAL_LDAP_PASSWORD]")
 	loginCmd.Flags().Bool("plain", false, "only output the token without any formatting")
 	loginCmd.Flags().String("client-id", "", "client id for universal auth")
 	loginCmd.Flags().String("client-secret", "", "client secret for universal auth")
-	loginCmd.Flags().String("machine-identity-id", "", "machine identity id for kubernetes, azure, gcp-id-token, gcp-iam, and aws-iam auth methods")
+	loginCmd.Flags().String("machine-identity-id", "", "machine identity id for kubernetes, azure, gcp-id-token, gcp-iam, aws-iam, oidc and tls-cert auth methods. tls-cert uses the certificate given with --client-certificate and --client-key. aws-iam signs in with the default AWS credential chain, e.g. environment variables, AWS SSO profiles or the instance profile")
 	loginCmd.Flags().String("service-account-token-path", "", "service account token path for kubernetes auth")
 	loginCmd.Flags().String("service-account-key-file-path", "", "service account key file path for GCP IAM auth")
 	loginCmd.Flags().String("oidc-jwt", "", "JWT for OIDC authentication")
+	loginCmd.Flags().String("oidc-audience", "", "the audience to request the OIDC token for from CI providers that mint tokens on request, such as GitHub Actions. Must match the audience configured on the identity [can also set via environment variable name: INFISICAL_OIDC_AUTH_AUDIENCE]")
+	loginCmd.Flags().String("oidc-jwt-file", "", "file to read the JWT for OIDC authentication from. Without --oidc-jwt or --oidc-jwt-file, the token is looked for in the environment, e.g. GitHub Actions, GitLab CI, CircleCI, Buildkite or AWS_WEB_IDENTITY_TOKEN_FILE [can also set via environment variable name: INFISICAL_OIDC_AUTH_JWT_FILE]")
 }
 
 func DomainOverridePrompt() (bool, error) {
@@ -548,7 +659,8 @@ func usePresetDomain(presetDomain string) (bool, error) {
 
 	preconfiguredUrl := strings.TrimSuffix(presetDomain, "/api")
 
-	if preconfiguredUrl != "" && preconfiguredUrl != util.INFISICAL_DEFAULT_US_URL && preconfiguredUrl != util.INFISICAL_DEFAULT_EU_URL {
+	// a selected profile always determines the domain, even for Infisical Cloud
+	if preconfiguredUrl != "" && (config.INFISICAL_PROFILE != "" || (preconfiguredUrl != util.INFISICAL_DEFAULT_US_URL && preconfiguredUrl != util.INFISICAL_DEFAULT_EU_URL)) {
 		parsedDomain := strings.TrimSuffix(strings.Trim(preconfiguredUrl, "/"), "/api")
 
 		_, err := url.ParseRequestURI(parsedDomain)
@@ -571,7 +683,11 @@ func usePresetDomain(presetDomain string) (bool, error) {
 		whilte := color.New(color.FgGreen)
 		boldWhite := whilte.Add(color.Bold)
 		time.Sleep(time.Second * 1)
-		boldWhite.Printf("[INFO] Using domain '%s' from domain flag or INFISICAL_API_URL environment variable\n", parsedDomain)
+		if config.INFISICAL_PROFILE != "" {
+			boldWhite.Printf("[INFO] Using domain '%s' from profile '%s'\n", parsedDomain, config.INFISICAL_PROFILE)
+		} else {
+			boldWhite.Printf("[INFO] Using domain '%s' from domain flag or INFISICAL_API_URL environment variable\n", parsedDomain)
+		}
 
 		return true, nil
 	}
@@ -726,7 +842,7 @@ func askForLoginCredentials() (email string, password string, err error) {
 
 func getFreshUserCredentials(email string, password string) (*api.GetLoginOneV2Response, *api.GetLoginTwoV2Response, error) {
 	log.Debug().Msg(fmt.Sprint("getFreshUserCredentials: ", "email", email, "password: ", password))
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetRetryCount(5)
 
 	params := srp.GetParams(4096)
@@ -776,7 +892,7 @@ func getFreshUserCredentials(email string, password string) (*api.GetLoginOneV2R
 func GetJwtTokenWithOrganizationId(oldJwtToken string, email string) string {
 	log.Debug().Msg(fmt.Sprint("GetJwtTokenWithOrganizationId: ", "oldJwtToken", oldJwtToken))
 
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetAuthToken(oldJwtToken)
 
 	organizationResponse, err := api.CallGetAllOrganizations(httpClient)
@@ -811,7 +927,7 @@ func GetJwtTokenWithOrganizationId(oldJwtToken string, email string) string {
 		for i < 6 {
 			mfaVerifyCode := askForMFACode(selectedOrgRes.MfaMethod)
 
-			httpClient := resty.New()
+			httpClient := api.NewHTTPClient()
 			httpClient.SetAuthToken(selectedOrgRes.Token)
 			verifyMFAresponse, mfaErrorResponse, requestError := api.CallVerifyMfaToken(httpClient, api.VerifyMfaTokenRequest{
 				Email:     email,
@@ -913,7 +1029,7 @@ func askToPasteJwtToken(success chan models.UserCredentials, failure chan error)
 	}
 
 	// verify JTW
-	httpClient := resty.New().
+	httpClient := api.NewHTTPClient().
 		SetAuthToken(userCredentials.JTWToken).
 		SetHeader("Accept", "application/json")
 
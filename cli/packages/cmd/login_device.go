@@ -0,0 +1,76 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/go-resty/resty/v2"
+	"github.com/rs/zerolog/log"
+)
+
+// deviceCodeCliLogin logs in without a local browser or callback server: the user opens the printed
+// address on any device, signs in there, including through SSO, and confirms the code while the CLI
+// polls for the result
+func deviceCodeCliLogin() (models.UserCredentials, error) {
+	httpClient := api.NewHTTPClient()
+
+	deviceCode, err := api.CallCreateCliDeviceCodeV1(httpClient)
+	if err != nil {
+		return models.UserCredentials{}, err
+	}
+
+	verificationUri := deviceCode.VerificationUriComplete
+	if verificationUri == "" {
+		verificationUri = deviceCode.VerificationUri
+	}
+	fmt.Printf("\n\nTo complete your login, open this address on any device: %v\n", verificationUri)
+	fmt.Printf("and confirm that it shows the code: %v\n\n", deviceCode.UserCode)
+
+	return pollCliDeviceToken(httpClient, deviceCode, time.Sleep)
+}
+
+// pollCliDeviceToken asks for the credentials of a device code login at the interval Infisical set until
+// the user confirmed or denied the code, or it expired. sleep waits between the requests.
+func pollCliDeviceToken(httpClient *resty.Client, deviceCode api.CreateCliDeviceCodeV1Response, sleep func(time.Duration)) (models.UserCredentials, error) {
+	interval := time.Duration(deviceCode.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	expiresAt := time.Now().Add(time.Duration(deviceCode.ExpiresIn) * time.Second)
+
+	for time.Now().Before(expiresAt) {
+		sleep(interval)
+
+		deviceToken, err := api.CallGetCliDeviceTokenV1(httpClient, api.GetCliDeviceTokenV1Request{DeviceCode: deviceCode.DeviceCode})
+		switch {
+		case err == nil:
+			fmt.Println("Device login successful")
+			return models.UserCredentials{
+				Email:        deviceToken.Email,
+				PrivateKey:   deviceToken.PrivateKey,
+				JTWToken:     deviceToken.JTWToken,
+				RefreshToken: deviceToken.RefreshToken,
+			}, nil
+		case api.HasErrorCode(err, api.DEVICE_CODE_AUTHORIZATION_PENDING):
+			continue
+		case api.HasErrorCode(err, api.DEVICE_CODE_SLOW_DOWN):
+			// back off as RFC 8628 requires
+			interval += 5 * time.Second
+			log.Debug().Msgf("polling for the device login every %v", interval)
+		case api.HasErrorCode(err, api.DEVICE_CODE_ACCESS_DENIED):
+			return models.UserCredentials{}, errors.New("the login was denied in the browser")
+		case api.HasErrorCode(err, api.DEVICE_CODE_EXPIRED_TOKEN):
+			return models.UserCredentials{}, errors.New("the code expired before the login was completed, please try again")
+		default:
+			return models.UserCredentials{}, err
+		}
+	}
+
+	return models.UserCredentials{}, errors.New("the code expired before the login was completed, please try again")
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPollCliDeviceToken(t *testing.T) {
+	credentials := map[string]string{"email": "jdoe@example.com", "privateKey": "private-key", "JTWToken": "jwt", "RefreshToken": "refresh-token"}
+
+	tests := []struct {
+		name          string
+		responses     []string
+		expiresIn     int
+		want          models.UserCredentials
+		wantErr       string
+		wantIntervals []time.Duration
+	}{
+		{
+			name:          "confirmed after a while",
+			responses:     []string{api.DEVICE_CODE_AUTHORIZATION_PENDING, api.DEVICE_CODE_AUTHORIZATION_PENDING, ""},
+			expiresIn:     600,
+			want:          models.UserCredentials{Email: "jdoe@example.com", PrivateKey: "private-key", JTWToken: "jwt", RefreshToken: "refresh-token"},
+			wantIntervals: []time.Duration{2 * time.Second, 2 * time.Second, 2 * time.Second},
+		},
+		{
+			name:          "slow down",
+			responses:     []string{api.DEVICE_CODE_SLOW_DOWN, api.DEVICE_CODE_SLOW_DOWN, ""},
+			expiresIn:     600,
+			want:          models.UserCredentials{Email: "jdoe@example.com", PrivateKey: "private-key", JTWToken: "jwt", RefreshToken: "refresh-token"},
+			wantIntervals: []time.Duration{2 * time.Second, 7 * time.Second, 12 * time.Second},
+		},
+		{
+			name:          "denied",
+			responses:     []string{api.DEVICE_CODE_AUTHORIZATION_PENDING, api.DEVICE_CODE_ACCESS_DENIED},
+			expiresIn:     600,
+			wantErr:       "denied",
+			wantIntervals: []time.Duration{2 * time.Second, 2 * time.Second},
+		},
+		{
+			name:          "expired on the server",
+			responses:     []string{api.DEVICE_CODE_EXPIRED_TOKEN},
+			expiresIn:     600,
+			wantErr:       "expired",
+			wantIntervals: []time.Duration{2 * time.Second},
+		},
+		{
+			name:      "expired before polling",
+			expiresIn: 0,
+			wantErr:   "expired",
+		},
+		{
+			name:          "other errors",
+			responses:     []string{"InternalServerError"},
+			expiresIn:     600,
+			wantErr:       "CallGetCliDeviceTokenV1",
+			wantIntervals: []time.Duration{2 * time.Second},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var request api.GetCliDeviceTokenV1Request
+				json.NewDecoder(r.Body).Decode(&request)
+				assert.Equal(t, "device-code", request.DeviceCode)
+
+				response := test.responses[requests]
+				requests++
+
+				w.Header().Set("Content-Type", "application/json")
+				if response == "" {
+					json.NewEncoder(w).Encode(credentials)
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": http.StatusBadRequest, "error": response, "message": response})
+			}))
+			defer server.Close()
+
+			previousURL := config.INFISICAL_URL
+			t.Cleanup(func() { config.INFISICAL_URL = previousURL })
+			config.INFISICAL_URL = server.URL
+
+			intervals := []time.Duration{}
+			userCredentials, err := pollCliDeviceToken(api.NewHTTPClient(), api.CreateCliDeviceCodeV1Response{
+				DeviceCode: "device-code",
+				ExpiresIn:  test.expiresIn,
+				Interval:   2,
+			}, func(interval time.Duration) { intervals = append(intervals, interval) })
+
+			if test.wantIntervals == nil {
+				test.wantIntervals = []time.Duration{}
+			}
+			assert.Equal(t, test.wantIntervals, intervals)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, userCredentials)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+const LDAP_LOGIN_METHOD = "ldap"
+
+// ldapCliLogin logs a user in with the username and password of the LDAP directory their organization
+// signs in with. The password is prompted for unless it's set in INFISICAL_LDAP_PASSWORD, so it never
+// shows up in the shell history.
+//
+// The credentials have no PrivateKey: it's only decrypted with the Infisical password of the user, which
+// an LDAP login never sees. Everything that works with the JWT alone works, but creating service tokens
+// needs a login with the Infisical password.
+func ldapCliLogin(cmd *cobra.Command) (models.UserCredentials, error) {
+	organizationSlug, username, password, err := askForLdapCredentials(cmd)
+	if err != nil {
+		return models.UserCredentials{}, err
+	}
+
+	providerAuthToken, err := api.CallLdapLoginV1(api.NewHTTPClient(), api.LdapLoginV1Request{
+		OrganizationSlug: organizationSlug,
+		Username:         username,
+		Password:         password,
+	})
+	if err != nil {
+		if api.IsUnauthorized(err) {
+			return models.UserCredentials{}, fmt.Errorf("invalid LDAP username or password")
+		}
+		return models.UserCredentials{}, err
+	}
+
+	email, err := getProviderAuthTokenEmail(providerAuthToken)
+	if err != nil {
+		return models.UserCredentials{}, err
+	}
+
+	tokenExchangeResponse, err := api.CallSsoTokenExchangeV1(api.NewHTTPClient(), api.SsoTokenExchangeV1Request{
+		ProviderAuthToken: providerAuthToken,
+		Email:             email,
+	})
+	if err != nil {
+		return models.UserCredentials{}, err
+	}
+
+	return models.UserCredentials{
+		Email:        email,
+		JTWToken:     GetJwtTokenWithOrganizationId(tokenExchangeResponse.Token, email),
+		RefreshToken: tokenExchangeResponse.RefreshToken,
+	}, nil
+}
+
+func askForLdapCredentials(cmd *cobra.Command) (organizationSlug string, username string, password string, err error) {
+	organizationSlug, err = cmd.Flags().GetString("organization-slug")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	username, err = cmd.Flags().GetString("username")
+	if err != nil {
+		return "", "", "", err
+	}
+
+	notEmpty := func(input string) error {
+		if strings.TrimSpace(input) == "" {
+			return errors.New("this can't be empty")
+		}
+		return nil
+	}
+
+	if organizationSlug == "" {
+		organizationSlugPrompt := promptui.Prompt{
+			Label:    "Organization slug",
+			Validate: notEmpty,
+		}
+		organizationSlug, err = organizationSlugPrompt.Run()
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+
+	if username == "" {
+		usernamePrompt := promptui.Prompt{
+			Label:    "LDAP username",
+			Validate: notEmpty,
+		}
+		username, err = usernamePrompt.Run()
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+
+	password = os.Getenv(util.INFISICAL_LDAP_PASSWORD_NAME)
+	if password == "" {
+		passwordPrompt := promptui.Prompt{
+			Label:    "LDAP password",
+			Validate: notEmpty,
+			Mask:     '*',
+		}
+		password, err = passwordPrompt.Run()
+		if err != nil {
+			return "", "", "", err
+		}
+	}
+
+	return strings.TrimSpace(organizationSlug), strings.TrimSpace(username), password, nil
+}
+
+// getProviderAuthTokenEmail reads the email of the user from the claims of the provider auth token. The
+// token isn't verified here, Infisical does that when it's exchanged.
+func getProviderAuthTokenEmail(providerAuthToken string) (string, error) {
+	parts := strings.Split(providerAuthToken, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("the login token returned by Infisical is malformed")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("the login token returned by Infisical is malformed: %w", err)
+	}
+
+	var claims struct {
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Email == "" {
+		return "", fmt.Errorf("the login token returned by Infisical has no email. Ask your admin to map an email attribute in the LDAP configuration")
+	}
+
+	return claims.Email, nil
+}
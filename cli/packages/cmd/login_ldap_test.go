@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetProviderAuthTokenEmail(t *testing.T) {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"username":"jdoe","email":"jdoe@example.com","authMethod":"ldap"}`))
+
+	email, err := getProviderAuthTokenEmail("header." + payload + ".signature")
+	assert.NoError(t, err)
+	assert.Equal(t, "jdoe@example.com", email)
+
+	noEmail := base64.RawURLEncoding.EncodeToString([]byte(`{"username":"jdoe"}`))
+	_, err = getProviderAuthTokenEmail("header." + noEmail + ".signature")
+	assert.Error(t, err)
+
+	_, err = getProviderAuthTokenEmail("not-a-jwt")
+	assert.Error(t, err)
+}
+
+func TestCreateServiceTokenWithoutPrivateKey(t *testing.T) {
+	// an LDAP login stores no private key
+	loggedInUserDetails := util.LoggedInUserDetails{
+		IsUserLoggedIn:  true,
+		UserCredentials: models.UserCredentials{Email: "jdoe@example.com", JTWToken: "jwt"},
+	}
+
+	_, err := createServiceToken(loggedInUserDetails, api.CreateServiceTokenRequest{Name: "ci", WorkspaceId: "project"})
+	assert.ErrorContains(t, err, "no private key")
+}
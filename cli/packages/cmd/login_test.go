@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// newOidcLoginTestCommand returns a command with the OIDC flags of login set to flags
+func newOidcLoginTestCommand(t *testing.T, flags map[string]string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("oidc-jwt", "", "")
+	cmd.Flags().String("oidc-jwt-file", "", "")
+	cmd.Flags().String("oidc-audience", "", "")
+	for name, value := range flags {
+		assert.NoError(t, cmd.Flags().Set(name, value))
+	}
+	return cmd
+}
+
+func TestGetOidcAuthJwt(t *testing.T) {
+	flagFile := filepath.Join(t.TempDir(), "flag-token")
+	assert.NoError(t, os.WriteFile(flagFile, []byte("file-flag-jwt\n"), 0600))
+	ambientFile := filepath.Join(t.TempDir(), "ambient-token")
+	assert.NoError(t, os.WriteFile(ambientFile, []byte("ambient-jwt"), 0600))
+
+	tests := []struct {
+		name    string
+		flags   map[string]string
+		env     map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "flag",
+			flags: map[string]string{"oidc-jwt": "flag-jwt", "oidc-jwt-file": flagFile},
+			env:   map[string]string{util.INFISICAL_OIDC_AUTH_JWT_NAME: "env-jwt"},
+			want:  "flag-jwt",
+		},
+		{
+			name:  "environment variable",
+			flags: map[string]string{"oidc-jwt-file": flagFile},
+			env:   map[string]string{util.INFISICAL_OIDC_AUTH_JWT_NAME: "env-jwt"},
+			want:  "env-jwt",
+		},
+		{
+			name:  "file flag",
+			flags: map[string]string{"oidc-jwt-file": flagFile},
+			env:   map[string]string{util.INFISICAL_OIDC_AUTH_JWT_FILE_NAME: ambientFile},
+			want:  "file-flag-jwt",
+		},
+		{
+			name:    "missing file",
+			flags:   map[string]string{"oidc-jwt-file": filepath.Join(t.TempDir(), "missing")},
+			wantErr: "unable to read OIDC token file",
+		},
+		{
+			name: "environment the CLI runs in",
+			env:  map[string]string{util.INFISICAL_OIDC_AUTH_JWT_FILE_NAME: ambientFile},
+			want: "ambient-jwt",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(util.INFISICAL_OIDC_AUTH_JWT_NAME, "")
+			t.Setenv(util.INFISICAL_OIDC_AUTH_JWT_FILE_NAME, "")
+			for envName, value := range test.env {
+				t.Setenv(envName, value)
+			}
+
+			jwt, err := getOidcAuthJwt(newOidcLoginTestCommand(t, test.flags))
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, jwt)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// The output formats of commands that print results. json and yaml print the same document, with the
+// keys of the json tags, on stdout only: warnings and errors go to stderr, and errors exit with code 1.
+const (
+	OutputTable = "table"
+	OutputJson  = "json"
+	OutputYaml  = "yaml"
+)
+
+var outputFormats = []string{OutputTable, OutputJson, OutputYaml}
+
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", OutputTable, "the output format: table, json or yaml")
+}
+
+// getOutputFormat returns the format of --output
+func getOutputFormat(cmd *cobra.Command) string {
+	format, err := cmd.Flags().GetString("output")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	format = strings.ToLower(format)
+	if !slices.Contains(outputFormats, format) {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("--output must be one of %s", strings.Join(outputFormats, ", ")))
+	}
+	return format
+}
+
+// printOutput prints value as json or yaml, or calls printTable for the table format
+func printOutput(format string, value interface{}, printTable func()) {
+	if format == OutputTable {
+		printTable()
+		return
+	}
+
+	// print empty lists as [] rather than null
+	if reflected := reflect.ValueOf(value); reflected.Kind() == reflect.Slice && reflected.IsNil() {
+		value = []interface{}{}
+	}
+
+	encoded, err := formatOutput(format, value)
+	if err != nil {
+		util.HandleError(err, "Unable to format the output")
+	}
+	fmt.Print(encoded)
+}
+
+func formatOutput(format string, value interface{}) (string, error) {
+	encoded, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	if format == OutputJson {
+		return string(encoded) + "\n", nil
+	}
+
+	// go through json so both formats have the same keys
+	var document interface{}
+	if err := json.Unmarshal(encoded, &document); err != nil {
+		return "", err
+	}
+
+	yamlBytes, err := yaml.Marshal(document)
+	if err != nil {
+		return "", err
+	}
+	return string(yamlBytes), nil
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatOutput(t *testing.T) {
+	secrets := toSecretsOutput([]models.SingleEnvironmentVariable{{Key: "DB_URL", Value: "postgres://db", Type: "shared"}})
+
+	encoded, err := formatOutput(OutputJson, secrets)
+	assert.NoError(t, err)
+	assert.Equal(t, "[\n  {\n    \"key\": \"DB_URL\",\n    \"value\": \"postgres://db\",\n    \"type\": \"shared\"\n  }\n]\n", encoded)
+
+	encoded, err = formatOutput(OutputYaml, secrets)
+	assert.NoError(t, err)
+	assert.Equal(t, "- key: DB_URL\n  type: shared\n  value: postgres://db\n", encoded)
+}
+
+func TestGetOutputFormat(t *testing.T) {
+	cmd := &cobra.Command{}
+	addOutputFlag(cmd)
+
+	assert.NoError(t, cmd.Flags().Parse([]string{}))
+	assert.Equal(t, OutputTable, getOutputFormat(cmd))
+
+	assert.NoError(t, cmd.Flags().Parse([]string{"-o", "json"}))
+	assert.Equal(t, OutputJson, getOutputFormat(cmd))
+
+	assert.NoError(t, cmd.Flags().Parse([]string{"--output", "YAML"}))
+	assert.Equal(t, OutputYaml, getOutputFormat(cmd))
+}
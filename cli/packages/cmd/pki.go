@@ -0,0 +1,463 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+// The key algorithms of certificates, named as in Infisical PKI
+const (
+	certificateKeyAlgorithmRSA2048   = "RSA_2048"
+	certificateKeyAlgorithmRSA4096   = "RSA_4096"
+	certificateKeyAlgorithmECDSAP256 = "EC_prime256v1"
+	certificateKeyAlgorithmECDSAP384 = "EC_secp384r1"
+)
+
+var pkiCmd = &cobra.Command{
+	Example: `infisical pki issue --ca-id <ca id> --common-name api.internal --ttl 30d --cert-file tls.crt --key-file tls.key
+infisical pki renew --ca-id <ca id> --cert-file tls.crt --key-file tls.key --reload-command "nginx -s reload"`,
+	Short: "Issue and renew certificates from Infisical PKI",
+	Long: `Issue and renew certificates from Infisical PKI, as the agent does for the certificates in its config.
+The private key is generated locally and never sent to Infisical, or a certificate signing request is
+used instead. Keys are written readable by the current user only.`,
+	Use:                   "pki",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var pkiIssueCmd = &cobra.Command{
+	Example: `infisical pki issue --ca-id <ca id> --common-name api.internal --alt-names api.internal,10.0.0.5 --ttl 30d --cert-file tls.crt --key-file tls.key
+infisical pki issue --certificate-template-id <template id> --csr request.csr --ttl 90d --cert-file tls.crt --chain-file chain.pem`,
+	Short:                 "Issue a certificate for a new key, or for a certificate signing request",
+	Use:                   "issue",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		certificateConfig := getCertificateConfigFlags(cmd)
+
+		var err error
+		certificateConfig.CommonName, err = cmd.Flags().GetString("common-name")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		certificateConfig.AltNames, err = cmd.Flags().GetStringSlice("alt-names")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		csrPath, err := cmd.Flags().GetString("csr")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		var csr []byte
+		var privateKey crypto.Signer
+		if csrPath != "" {
+			csr, err = os.ReadFile(csrPath)
+			if err != nil {
+				util.HandleError(err, "Unable to read the certificate signing request")
+			}
+			if certificateConfig.CommonName == "" {
+				certificateConfig.CommonName, err = getCsrCommonName(csr)
+				if err != nil {
+					util.HandleError(err, "Unable to parse the certificate signing request")
+				}
+			}
+		} else {
+			if certificateConfig.Destination.PrivateKey == "" {
+				util.PrintErrorMessageAndExit("You must set --key-file to write the new key to, or --csr to use your own key")
+			}
+
+			keyAlgorithm, err := cmd.Flags().GetString("key-algorithm")
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+
+			privateKey, err = generateCertificateKey(keyAlgorithm)
+			if err != nil {
+				util.HandleError(err, "Unable to generate the private key")
+			}
+		}
+
+		if certificateConfig.CommonName == "" {
+			util.PrintErrorMessageAndExit("You must set the --common-name flag")
+		}
+		if certificateConfig.TTL == "" {
+			util.PrintErrorMessageAndExit("You must set the --ttl flag")
+		}
+
+		if privateKey != nil {
+			csr, err = createCertificateRequest(privateKey, certificateConfig.CommonName, certificateConfig.AltNames)
+			if err != nil {
+				util.HandleError(err, "Unable to create the certificate signing request")
+			}
+		}
+
+		certificate := signCertificate(cmd, certificateConfig, csr, privateKey)
+		fmt.Printf("Issued certificate %s for %s, valid until %s\n", certificate.SerialNumber.Text(16), certificateConfig.CommonName, certificate.NotAfter.Format(time.RFC3339))
+
+		Telemetry.CaptureEvent("cli-command:pki issue", posthog.NewProperties().Set("csr", csrPath != "").Set("version", util.CLI_VERSION))
+	},
+}
+
+var pkiRenewCmd = &cobra.Command{
+	Example: `infisical pki renew --ca-id <ca id> --cert-file tls.crt --key-file tls.key --reload-command "nginx -s reload"`,
+	Short:   "Renew a certificate once it is due, keeping its names and lifetime",
+	Long: `Renew a certificate once the --renew-at-fraction of its lifetime has passed, or right away with --force,
+so it can run from cron. The new certificate has the names and lifetime of the current one, and a new
+key unless --reuse-key is set. --reload-command runs only after a renewal.`,
+	Use:                   "renew",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		certificateConfig := getCertificateConfigFlags(cmd)
+		if certificateConfig.Destination.Certificate == "" || certificateConfig.Destination.PrivateKey == "" {
+			util.PrintErrorMessageAndExit("You must set the --cert-file and --key-file flags")
+		}
+
+		var err error
+		certificateConfig.RenewAtFraction, err = cmd.Flags().GetFloat64("renew-at-fraction")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if certificateConfig.RenewAtFraction <= 0 || certificateConfig.RenewAtFraction >= 1 {
+			util.PrintErrorMessageAndExit("--renew-at-fraction must be between 0 and 1")
+		}
+
+		force, err := cmd.Flags().GetBool("force")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		reuseKey, err := cmd.Flags().GetBool("reuse-key")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		certificateConfig.ReloadCommand, err = cmd.Flags().GetString("reload-command")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		current, err := readPEMCertificate(certificateConfig.Destination.Certificate)
+		if err != nil {
+			util.HandleError(err, "Unable to read the certificate to renew")
+		}
+
+		if renewAt := certificateConfig.renewalTime(current); !force && time.Now().Before(renewAt) {
+			fmt.Printf("The certificate is not due for renewal until %s\n", renewAt.Format(time.RFC3339))
+			return
+		}
+
+		certificateConfig.CommonName = current.Subject.CommonName
+		certificateConfig.AltNames = getCertificateAltNames(current)
+		if certificateConfig.TTL == "" {
+			certificateConfig.TTL = fmt.Sprintf("%ds", int64(current.NotAfter.Sub(current.NotBefore).Seconds()))
+		}
+
+		var privateKey crypto.Signer
+		if reuseKey {
+			privateKey, err = readPEMPrivateKey(certificateConfig.Destination.PrivateKey)
+			if err != nil {
+				util.HandleError(err, "Unable to read the private key to reuse")
+			}
+		} else {
+			keyAlgorithm, err := cmd.Flags().GetString("key-algorithm")
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
+			if !cmd.Flags().Changed("key-algorithm") {
+				keyAlgorithm = getCertificateKeyAlgorithm(current)
+			}
+
+			privateKey, err = generateCertificateKey(keyAlgorithm)
+			if err != nil {
+				util.HandleError(err, "Unable to generate the private key")
+			}
+		}
+
+		csr, err := createCertificateRequest(privateKey, certificateConfig.CommonName, certificateConfig.AltNames)
+		if err != nil {
+			util.HandleError(err, "Unable to create the certificate signing request")
+		}
+
+		// a reused key is already at the destination, so only a new one is written
+		newPrivateKey := privateKey
+		if reuseKey {
+			newPrivateKey = nil
+		}
+
+		certificate := signCertificate(cmd, certificateConfig, csr, newPrivateKey)
+		fmt.Printf("Renewed certificate %s for %s, valid until %s\n", certificate.SerialNumber.Text(16), certificateConfig.CommonName, certificate.NotAfter.Format(time.RFC3339))
+
+		if certificateConfig.ReloadCommand != "" {
+			if err := ExecuteCommandWithTimeout(certificateConfig.ReloadCommand, 0); err != nil {
+				util.HandleError(err, "Unable to run the reload command")
+			}
+		}
+
+		Telemetry.CaptureEvent("cli-command:pki renew", posthog.NewProperties().Set("reuseKey", reuseKey).Set("version", util.CLI_VERSION))
+	},
+}
+
+// getCertificateConfigFlags reads the flags issue and renew share into the certificate config of the agent
+func getCertificateConfigFlags(cmd *cobra.Command) CertificateConfig {
+	certificateConfig := CertificateConfig{}
+
+	var err error
+	certificateConfig.CaID, err = cmd.Flags().GetString("ca-id")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	certificateConfig.CertificateTemplateID, err = cmd.Flags().GetString("certificate-template-id")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if certificateConfig.CaID == "" && certificateConfig.CertificateTemplateID == "" {
+		util.PrintErrorMessageAndExit("You must set the --ca-id or --certificate-template-id flag")
+	}
+
+	certificateConfig.TTL, err = cmd.Flags().GetString("ttl")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	certificateConfig.KeyUsages, err = cmd.Flags().GetStringSlice("key-usages")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	certificateConfig.ExtendedKeyUsages, err = cmd.Flags().GetStringSlice("extended-key-usages")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	certificateConfig.Destination.Certificate, err = cmd.Flags().GetString("cert-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+	if certificateConfig.Destination.Certificate == "" {
+		util.PrintErrorMessageAndExit("You must set the --cert-file flag")
+	}
+
+	certificateConfig.Destination.PrivateKey, err = cmd.Flags().GetString("key-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	certificateConfig.Destination.Chain, err = cmd.Flags().GetString("chain-file")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return certificateConfig
+}
+
+// signCertificate gets a certificate for the certificate signing request and writes it to the
+// destination of the config, with the chain and the new key when there is one
+func signCertificate(cmd *cobra.Command, certificateConfig CertificateConfig, csr []byte, newPrivateKey crypto.Signer) *x509.Certificate {
+	httpClient := api.NewHTTPClient().SetAuthToken(getAccessTokenOrLogin(cmd))
+	response, err := api.CallSignCertificateV1(httpClient, api.SignCertificateV1Request{
+		CaID:                  certificateConfig.CaID,
+		CertificateTemplateID: certificateConfig.CertificateTemplateID,
+		Csr:                   string(csr),
+		CommonName:            certificateConfig.CommonName,
+		AltNames:              strings.Join(certificateConfig.AltNames, ","),
+		TTL:                   certificateConfig.TTL,
+		KeyUsages:             certificateConfig.KeyUsages,
+		ExtendedKeyUsages:     certificateConfig.ExtendedKeyUsages,
+	})
+	if err != nil {
+		util.HandleError(err, "Unable to issue the certificate")
+	}
+
+	certificate, err := parsePEMCertificate([]byte(response.Certificate))
+	if err != nil {
+		util.HandleError(err, "Unable to parse the issued certificate")
+	}
+
+	// the key is written first, so the certificate on disk never belongs to a key that isn't there yet
+	if newPrivateKey != nil {
+		encodedKey, err := x509.MarshalPKCS8PrivateKey(newPrivateKey)
+		if err != nil {
+			util.HandleError(err, "Unable to encode the private key")
+		}
+		keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey})
+		if err := writeFileAtomically(certificateConfig.Destination.PrivateKey, keyPEM, FileOwnership{Permissions: "0600"}); err != nil {
+			util.HandleError(err, "Unable to write the private key")
+		}
+	}
+	if err := writeFileAtomically(certificateConfig.Destination.Certificate, []byte(response.Certificate), certificateConfig.Destination.FileOwnership); err != nil {
+		util.HandleError(err, "Unable to write the certificate")
+	}
+	if certificateConfig.Destination.Chain != "" {
+		if err := writeFileAtomically(certificateConfig.Destination.Chain, []byte(response.CertificateChain), certificateConfig.Destination.FileOwnership); err != nil {
+			util.HandleError(err, "Unable to write the certificate chain")
+		}
+	}
+
+	return certificate
+}
+
+func generateCertificateKey(keyAlgorithm string) (crypto.Signer, error) {
+	switch keyAlgorithm {
+	case certificateKeyAlgorithmRSA2048:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case certificateKeyAlgorithmRSA4096:
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case certificateKeyAlgorithmECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case certificateKeyAlgorithmECDSAP384:
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported key algorithm %s, expected %s, %s, %s or %s", keyAlgorithm, certificateKeyAlgorithmRSA2048, certificateKeyAlgorithmRSA4096, certificateKeyAlgorithmECDSAP256, certificateKeyAlgorithmECDSAP384)
+	}
+}
+
+// getCertificateKeyAlgorithm returns the algorithm of the key of a certificate, so renewals keep it
+func getCertificateKeyAlgorithm(certificate *x509.Certificate) string {
+	switch publicKey := certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if publicKey.N.BitLen() > 2048 {
+			return certificateKeyAlgorithmRSA4096
+		}
+		return certificateKeyAlgorithmRSA2048
+	case *ecdsa.PublicKey:
+		if publicKey.Curve == elliptic.P384() {
+			return certificateKeyAlgorithmECDSAP384
+		}
+	}
+	return certificateKeyAlgorithmECDSAP256
+}
+
+// createCertificateRequest returns a PEM certificate signing request for the key. Alt names are IP
+// addresses, email addresses, URIs or DNS names.
+func createCertificateRequest(privateKey crypto.Signer, commonName string, altNames []string) ([]byte, error) {
+	template := &x509.CertificateRequest{Subject: pkix.Name{CommonName: commonName}}
+	for _, altName := range altNames {
+		if ip := net.ParseIP(altName); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if strings.Contains(altName, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, altName)
+		} else if uri, err := url.Parse(altName); err == nil && uri.Scheme != "" && uri.Host != "" {
+			template.URIs = append(template.URIs, uri)
+		} else {
+			template.DNSNames = append(template.DNSNames, altName)
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, template, privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr}), nil
+}
+
+func getCsrCommonName(csr []byte) (string, error) {
+	block, _ := pem.Decode(csr)
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return "", errors.New("no PEM certificate signing request found")
+	}
+
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	return request.Subject.CommonName, nil
+}
+
+// getCertificateAltNames returns the alt names of a certificate, as createCertificateRequest takes them
+func getCertificateAltNames(certificate *x509.Certificate) []string {
+	altNames := append([]string{}, certificate.DNSNames...)
+	for _, ip := range certificate.IPAddresses {
+		altNames = append(altNames, ip.String())
+	}
+	altNames = append(altNames, certificate.EmailAddresses...)
+	for _, uri := range certificate.URIs {
+		altNames = append(altNames, uri.String())
+	}
+	return altNames
+}
+
+func readPEMPrivateKey(path string) (crypto.Signer, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM private key found")
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("the private key can't sign certificate requests")
+	}
+	return signer, nil
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{pkiIssueCmd, pkiRenewCmd} {
+		cmd.Flags().String("token", "", "Issue the certificate using machine identity access token")
+		cmd.Flags().String("ca-id", "", "the ID of the CA to issue the certificate from")
+		cmd.Flags().String("certificate-template-id", "", "the ID of the certificate template to issue the certificate with, instead of --ca-id")
+		cmd.Flags().StringSlice("key-usages", nil, "the key usages of the certificate, such as digital_signature,key_encipherment")
+		cmd.Flags().StringSlice("extended-key-usages", nil, "the extended key usages of the certificate, such as server_auth,client_auth")
+		cmd.Flags().String("key-algorithm", certificateKeyAlgorithmECDSAP256, "the algorithm of the generated key: RSA_2048, RSA_4096, EC_prime256v1 or EC_secp384r1")
+		cmd.Flags().String("cert-file", "", "the file to write the certificate to")
+		cmd.Flags().String("key-file", "", "the file to write the private key to, readable by the current user only")
+		cmd.Flags().String("chain-file", "", "the file to write the certificate chain of the CA to")
+		pkiCmd.AddCommand(cmd)
+	}
+
+	pkiIssueCmd.Flags().String("common-name", "", "the common name of the certificate. Defaults to the one of --csr")
+	pkiIssueCmd.Flags().StringSlice("alt-names", nil, "the DNS names, IP addresses, email addresses or URIs of the certificate")
+	pkiIssueCmd.Flags().String("ttl", "", "the lifetime of the certificate, such as 30d")
+	pkiIssueCmd.Flags().String("csr", "", "a PEM certificate signing request to issue the certificate for, instead of generating a key")
+
+	pkiRenewCmd.Flags().String("ttl", "", "the lifetime of the new certificate, such as 30d. Defaults to the one of the current certificate")
+	pkiRenewCmd.Flags().Float64("renew-at-fraction", defaultCertificateRenewAtFraction, "renew once this fraction of the lifetime of the certificate has passed")
+	pkiRenewCmd.Flags().Bool("force", false, "renew the certificate even if it isn't due")
+	pkiRenewCmd.Flags().Bool("reuse-key", false, "keep the private key of the current certificate instead of generating a new one")
+	pkiRenewCmd.Flags().String("reload-command", "", "a command to run after the certificate is renewed, such as nginx -s reload")
+
+	rootCmd.AddCommand(pkiCmd)
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateCertificateRequest(t *testing.T) {
+	privateKey, err := generateCertificateKey(certificateKeyAlgorithmECDSAP256)
+	assert.NoError(t, err)
+
+	csr, err := createCertificateRequest(privateKey, "api.internal", []string{"api.internal", "10.0.0.5", "ops@example.com", "spiffe://example.com/api"})
+	assert.NoError(t, err)
+
+	block, _ := pem.Decode(csr)
+	request, err := x509.ParseCertificateRequest(block.Bytes)
+	assert.NoError(t, err)
+	assert.NoError(t, request.CheckSignature())
+	assert.Equal(t, []string{"api.internal"}, request.DNSNames)
+	assert.Equal(t, "10.0.0.5", request.IPAddresses[0].String())
+	assert.Equal(t, []string{"ops@example.com"}, request.EmailAddresses)
+	assert.Equal(t, "spiffe://example.com/api", request.URIs[0].String())
+
+	commonName, err := getCsrCommonName(csr)
+	assert.NoError(t, err)
+	assert.Equal(t, "api.internal", commonName)
+}
+
+func TestGenerateCertificateKey(t *testing.T) {
+	_, err := generateCertificateKey("DSA_1024")
+	assert.Error(t, err)
+}
+
+func TestReadPEMPrivateKey(t *testing.T) {
+	privateKey, err := generateCertificateKey(certificateKeyAlgorithmECDSAP384)
+	assert.NoError(t, err)
+
+	encodedKey, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "tls.key")
+	assert.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encodedKey}), 0600))
+
+	readKey, err := readPEMPrivateKey(path)
+	assert.NoError(t, err)
+	assert.Equal(t, privateKey.Public(), readKey.Public())
+}
@@ -0,0 +1,417 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+// Plugins are executables on PATH named infisical-<name>, which run as infisical <name>. Built-in commands
+// always win over plugins of the same name.
+const pluginPrefix = "infisical-"
+
+// pluginAnnotation marks the commands of plugins, with the path of the plugin
+const pluginAnnotation = "infisical-plugin"
+
+var pluginNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// pluginManifest describes a plugin for help and shell completion. It's read from infisical-<name>.yaml
+// next to the plugin, and is optional.
+type pluginManifest struct {
+	Short   string `yaml:"short"`
+	Long    string `yaml:"long"`
+	Example string `yaml:"example"`
+	// Completions are offered for the first argument of the plugin, usually its subcommands
+	Completions []string `yaml:"completions"`
+	// CobraCompletion is set by plugins built with cobra, which complete their arguments themselves
+	CobraCompletion bool `yaml:"cobra-completion"`
+}
+
+type plugin struct {
+	Name          string
+	Path          string
+	Manifest      *pluginManifest
+	ManifestError error
+	// Shadowed are plugins of the same name later on PATH, which never run
+	Shadowed []string
+}
+
+var pluginCmd = &cobra.Command{
+	Example:               `infisical plugin list`,
+	Short:                 "Used to manage the plugins that extend the CLI",
+	Use:                   "plugin",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Long: `Plugins add subcommands to the CLI without changing it. Any executable on PATH named infisical-<name>
+runs as [infisical <name>], with every argument after the name passed to it as is.
+
+An optional manifest, infisical-<name>.yaml next to the executable, describes the plugin for help and
+shell completion:
+
+  short: Rotate the database credentials of a service
+  long: A longer description shown by [infisical help <name>]
+  example: infisical rotate-db --service api
+  completions: [plan, apply]   # offered for the first argument
+  cobra-completion: false      # true for plugins built with cobra, to complete with their __complete command
+
+Plugins are run with ` + util.INFISICAL_CLI_PATH_NAME + ` and ` + util.INFISICAL_CLI_VERSION_NAME + ` set, so they can call back into the CLI
+that ran them, and without ` + util.INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME + `. Set ` + util.INFISICAL_DISABLE_PLUGINS_NAME + `=true to ignore all plugins.`,
+}
+
+var pluginListCmd = &cobra.Command{
+	Example:               `infisical plugin list`,
+	Short:                 "List the plugins found on PATH",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat := getOutputFormat(cmd)
+
+		output := []pluginOutput{}
+		rows := [][]string{}
+		for _, plugin := range findPlugins() {
+			status := "ok"
+			if isBuiltinCommand(rootCmd, plugin.Name) {
+				status = "ignored, a built-in command has this name"
+				util.PrintWarning(fmt.Sprintf("%s is never run, the built-in command %s has the same name", plugin.Path, plugin.Name))
+			}
+			for _, shadowed := range plugin.Shadowed {
+				util.PrintWarning(fmt.Sprintf("%s is never run, %s comes first on PATH", shadowed, plugin.Path))
+			}
+			if plugin.ManifestError != nil {
+				util.PrintWarning(fmt.Sprintf("Unable to read the manifest of %s: %v", plugin.Name, plugin.ManifestError))
+			}
+
+			description := ""
+			if plugin.Manifest != nil {
+				description = plugin.Manifest.Short
+			}
+
+			output = append(output, pluginOutput{Name: plugin.Name, Path: plugin.Path, Description: description, Status: status, Shadowed: plugin.Shadowed})
+			rows = append(rows, []string{plugin.Name, plugin.Path, description, status})
+		}
+
+		printOutput(outputFormat, output, func() {
+			if len(rows) == 0 {
+				fmt.Printf("No plugins found, add executables named %s<name> to your PATH\n", pluginPrefix)
+				return
+			}
+			visualize.GenericTable([]string{"NAME", "PATH", "DESCRIPTION", "STATUS"}, rows)
+		})
+
+		Telemetry.CaptureEvent("cli-command:plugin list", posthog.NewProperties().Set("pluginCount", len(output)).Set("version", util.CLI_VERSION))
+	},
+}
+
+type pluginOutput struct {
+	Name        string   `json:"name"`
+	Path        string   `json:"path"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"`
+	Shadowed    []string `json:"shadowed,omitempty"`
+}
+
+// needsPluginCommands tells whether the CLI has to look for plugins to run args. Built-in commands run
+// without reading PATH, plugins are only looked up for commands the CLI doesn't know, and for help and
+// completion, which list them.
+func needsPluginCommands(root *cobra.Command, args []string) bool {
+	// help, completion and __complete are only added when the CLI runs, so they aren't found either
+	command, _, err := root.Find(args)
+	return err != nil || command == root
+}
+
+// addPluginCommands adds a command to root for every plugin on PATH that a built-in command doesn't
+// already answer to
+func addPluginCommands(root *cobra.Command) {
+	if disabled, _ := strconv.ParseBool(os.Getenv(util.INFISICAL_DISABLE_PLUGINS_NAME)); disabled {
+		return
+	}
+
+	for _, plugin := range findPlugins() {
+		if !isBuiltinCommand(root, plugin.Name) {
+			root.AddCommand(newPluginCommand(plugin))
+		}
+	}
+}
+
+// findPlugins returns the plugins on PATH sorted by name. Like any other command, the first executable of
+// a name on PATH is the one that runs.
+func findPlugins() []*plugin {
+	pluginsByName := map[string]*plugin{}
+	seenDirs := map[string]bool{}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" || seenDirs[dir] {
+			continue
+		}
+		seenDirs[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := pluginName(entry.Name())
+			if !ok {
+				continue
+			}
+
+			pluginPath := filepath.Join(dir, entry.Name())
+			if !isExecutableFile(pluginPath) {
+				continue
+			}
+
+			if existing, ok := pluginsByName[name]; ok {
+				existing.Shadowed = append(existing.Shadowed, pluginPath)
+				continue
+			}
+			pluginsByName[name] = &plugin{Name: name, Path: pluginPath}
+		}
+	}
+
+	plugins := []*plugin{}
+	for _, plugin := range pluginsByName {
+		plugin.Manifest, plugin.ManifestError = readPluginManifest(plugin)
+		plugins = append(plugins, plugin)
+	}
+	sort.Slice(plugins, func(i, j int) bool {
+		return plugins[i].Name < plugins[j].Name
+	})
+
+	return plugins
+}
+
+// pluginName returns the command name of a plugin file name, which is the name without the prefix and,
+// on Windows, the executable extension
+func pluginName(fileName string) (string, bool) {
+	if !strings.HasPrefix(fileName, pluginPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(fileName, pluginPrefix)
+
+	if runtime.GOOS == "windows" {
+		extension := strings.ToLower(filepath.Ext(name))
+		if extension != ".exe" && extension != ".bat" && extension != ".cmd" {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+
+	// this also leaves out manifests and other files with an extension
+	if !pluginNamePattern.MatchString(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func isExecutableFile(filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		return true
+	}
+	return info.Mode().Perm()&0111 != 0
+}
+
+func readPluginManifest(plugin *plugin) (*pluginManifest, error) {
+	manifestPath := filepath.Join(filepath.Dir(plugin.Path), pluginPrefix+plugin.Name+".yaml")
+
+	manifestBytes, err := os.ReadFile(manifestPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest pluginManifest
+	if err := yaml.UnmarshalStrict(manifestBytes, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	return &manifest, nil
+}
+
+// isBuiltinCommand tells whether name is taken by a command of the CLI, including the ones cobra only adds
+// when it runs
+func isBuiltinCommand(root *cobra.Command, name string) bool {
+	switch name {
+	case "help", "completion", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		return true
+	}
+
+	for _, command := range root.Commands() {
+		if _, isPlugin := command.Annotations[pluginAnnotation]; isPlugin {
+			continue
+		}
+		if command.Name() == name || command.HasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func newPluginCommand(plugin *plugin) *cobra.Command {
+	command := &cobra.Command{
+		Short:                 fmt.Sprintf("Plugin at %s", plugin.Path),
+		Use:                   plugin.Name,
+		DisableFlagsInUseLine: true,
+		Annotations:           map[string]string{pluginAnnotation: plugin.Path},
+		// every argument, flags included, is the plugin's
+		DisableFlagParsing: true,
+		// the flags of the CLI aren't parsed, so there's nothing to set up for the plugin
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+		Run: func(cmd *cobra.Command, args []string) {
+			Telemetry.CaptureEvent("cli-command:plugin", posthog.NewProperties().Set("version", util.CLI_VERSION))
+
+			exitCode, err := runPlugin(plugin, args)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to run the plugin %s", plugin.Name))
+			}
+			os.Exit(exitCode)
+		},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return completePluginArgs(plugin, args, toComplete)
+		},
+	}
+
+	if plugin.Manifest != nil {
+		if plugin.Manifest.Short != "" {
+			command.Short = plugin.Manifest.Short
+		}
+		command.Long = plugin.Manifest.Long
+		command.Example = plugin.Manifest.Example
+	}
+
+	return command
+}
+
+// runPlugin runs the plugin in the foreground and returns its exit code
+func runPlugin(plugin *plugin, args []string) (int, error) {
+	cliPath, err := os.Executable()
+	if err != nil {
+		cliPath = os.Args[0]
+	}
+
+	pluginProcess := exec.Command(plugin.Path, args...)
+	pluginProcess.Stdin = os.Stdin
+	pluginProcess.Stdout = os.Stdout
+	pluginProcess.Stderr = os.Stderr
+	pluginProcess.Env = pluginEnvironment(os.Environ(), cliPath)
+
+	// the plugin decides what interrupts do, the CLI waits for it to exit
+	sigChannel := make(chan os.Signal, 1)
+	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChannel)
+
+	if err := pluginProcess.Start(); err != nil {
+		return 0, err
+	}
+
+	go func() {
+		for sig := range sigChannel {
+			if sig == syscall.SIGTERM {
+				_ = pluginProcess.Process.Signal(sig)
+			}
+		}
+	}()
+
+	err = pluginProcess.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.ExitCode() < 0 {
+			// killed by a signal
+			return 1, nil
+		}
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// pluginEnvironment returns the environment of plugins: the environment of the CLI without the secrets
+// that only the CLI itself uses, and the variables that tell plugins how to call back into the CLI
+func pluginEnvironment(environ []string, cliPath string) []string {
+	environment := []string{}
+	for _, variable := range util.ChildProcessEnvironment(environ) {
+		name, _, _ := strings.Cut(variable, "=")
+		if name == util.INFISICAL_CLI_PATH_NAME || name == util.INFISICAL_CLI_VERSION_NAME {
+			continue
+		}
+		environment = append(environment, variable)
+	}
+
+	return append(environment,
+		fmt.Sprintf("%s=%s", util.INFISICAL_CLI_PATH_NAME, cliPath),
+		fmt.Sprintf("%s=%s", util.INFISICAL_CLI_VERSION_NAME, util.CLI_VERSION),
+	)
+}
+
+func completePluginArgs(plugin *plugin, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if plugin.Manifest == nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	if !plugin.Manifest.CobraCompletion {
+		if len(args) == 0 && len(plugin.Manifest.Completions) > 0 {
+			return plugin.Manifest.Completions, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+
+	completeArgs := append(append([]string{cobra.ShellCompRequestCmd}, args...), toComplete)
+	completeProcess := exec.Command(plugin.Path, completeArgs...)
+	completeProcess.Env = util.ChildProcessEnvironment(os.Environ())
+	output, err := completeProcess.Output()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return parseCobraCompletions(string(output))
+}
+
+// parseCobraCompletions reads the output of the __complete command of cobra programs: a completion per
+// line, then a line with the directive as :<number>
+func parseCobraCompletions(output string) ([]string, cobra.ShellCompDirective) {
+	lines := strings.Split(strings.TrimRight(output, "\r\n"), "\n")
+
+	directive := cobra.ShellCompDirectiveDefault
+	if last := strings.TrimSpace(lines[len(lines)-1]); strings.HasPrefix(last, ":") {
+		if value, err := strconv.Atoi(last[1:]); err == nil {
+			directive = cobra.ShellCompDirective(value)
+		}
+		lines = lines[:len(lines)-1]
+	}
+
+	completions := []string{}
+	for _, line := range lines {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			completions = append(completions, line)
+		}
+	}
+	return completions, directive
+}
+
+func init() {
+	addOutputFlag(pluginListCmd)
+	pluginCmd.AddCommand(pluginListCmd)
+	rootCmd.AddCommand(pluginCmd)
+}
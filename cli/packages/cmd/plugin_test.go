@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are found by extension on Windows")
+	}
+
+	firstDir := t.TempDir()
+	secondDir := t.TempDir()
+	writeFile := func(path string, mode os.FileMode, content string) {
+		assert.NoError(t, os.WriteFile(path, []byte(content), mode))
+	}
+
+	writeFile(filepath.Join(firstDir, "infisical-rotate-db"), 0755, "#!/bin/sh\n")
+	writeFile(filepath.Join(firstDir, "infisical-rotate-db.yaml"), 0644, "short: Rotate database credentials\ncompletions: [plan, apply]\n")
+	writeFile(filepath.Join(firstDir, "infisical-notes"), 0644, "not executable")
+	writeFile(filepath.Join(secondDir, "infisical-rotate-db"), 0755, "#!/bin/sh\n")
+	writeFile(filepath.Join(secondDir, "infisical-audit"), 0755, "#!/bin/sh\n")
+	t.Setenv("PATH", firstDir+string(os.PathListSeparator)+secondDir)
+
+	plugins := findPlugins()
+	assert.Len(t, plugins, 2)
+
+	assert.Equal(t, "audit", plugins[0].Name)
+	assert.Nil(t, plugins[0].Manifest)
+
+	assert.Equal(t, "rotate-db", plugins[1].Name)
+	assert.Equal(t, filepath.Join(firstDir, "infisical-rotate-db"), plugins[1].Path)
+	assert.Equal(t, []string{filepath.Join(secondDir, "infisical-rotate-db")}, plugins[1].Shadowed)
+	assert.NoError(t, plugins[1].ManifestError)
+	assert.Equal(t, "Rotate database credentials", plugins[1].Manifest.Short)
+	assert.Equal(t, []string{"plan", "apply"}, plugins[1].Manifest.Completions)
+
+	root := &cobra.Command{Use: "infisical"}
+	root.AddCommand(&cobra.Command{Use: "audit"})
+	addPluginCommands(root)
+	command, _, err := root.Find([]string{"rotate-db"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Rotate database credentials", command.Short)
+	assert.True(t, isBuiltinCommand(root, "audit"))
+	assert.False(t, isBuiltinCommand(root, "rotate-db"))
+}
+
+func TestParseCobraCompletions(t *testing.T) {
+	completions, directive := parseCobraCompletions("plan\tShow the changes\napply\n:4\n")
+	assert.Equal(t, []string{"plan\tShow the changes", "apply"}, completions)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+}
+
+func TestNeedsPluginCommands(t *testing.T) {
+	root := &cobra.Command{Use: "infisical"}
+	root.PersistentFlags().String("domain", "", "")
+	root.AddCommand(&cobra.Command{Use: "run", Run: func(cmd *cobra.Command, args []string) {}})
+
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{name: "built-in command", args: []string{"run", "--", "npm", "start"}, want: false},
+		{name: "built-in command after a flag", args: []string{"--domain", "https://example.com", "run"}, want: false},
+		{name: "help of a built-in command", args: []string{"run", "--help"}, want: false},
+		{name: "unknown command", args: []string{"rotate-db", "plan"}, want: true},
+		{name: "no command", args: []string{}, want: true},
+		{name: "root help", args: []string{"--help"}, want: true},
+		{name: "help command", args: []string{"help", "rotate-db"}, want: true},
+		{name: "completion", args: []string{cobra.ShellCompRequestCmd, "ro"}, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, needsPluginCommands(root, test.args))
+		})
+	}
+}
+
+func TestPluginEnvironment(t *testing.T) {
+	environment := pluginEnvironment([]string{
+		"PATH=/usr/bin",
+		util.INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME + "=secret",
+		util.INFISICAL_CLI_PATH_NAME + "=/old/infisical",
+		"INFISICAL_TOKEN=token",
+	}, "/usr/local/bin/infisical")
+
+	assert.Equal(t, []string{
+		"PATH=/usr/bin",
+		"INFISICAL_TOKEN=token",
+		util.INFISICAL_CLI_PATH_NAME + "=/usr/local/bin/infisical",
+		util.INFISICAL_CLI_VERSION_NAME + "=" + util.CLI_VERSION,
+	}, environment)
+}
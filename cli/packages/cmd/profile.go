@@ -0,0 +1,215 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var profileCmd = &cobra.Command{
+	Use:                   "profile",
+	Short:                 "Manage named profiles for working with several Infisical instances or regions",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile add eu --region eu",
+	Args:                  cobra.NoArgs,
+	// profile commands only touch the config file, and have to work while --profile or
+	// INFISICAL_PROFILE name a profile that does not exist yet
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+	Run: func(cmd *cobra.Command, args []string) {
+		cmd.Help()
+	},
+}
+
+var profileAddCmd = &cobra.Command{
+	Use:                   "add [name]",
+	Short:                 "Create or update a profile",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile add self-hosted --domain https://infisical.example.com",
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+
+		domain, err := cmd.Flags().GetString("domain")
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		region, err := cmd.Flags().GetString("region")
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		if (domain == "") == (region == "") {
+			util.PrintErrorMessageAndExit("Provide exactly one of --domain or --region")
+		}
+
+		if region != "" {
+			regionDomain, ok := util.ProfileRegionDomains[strings.ToLower(region)]
+			if !ok {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Unknown region '%s', expected us or eu", region))
+			}
+			domain = regionDomain
+		}
+
+		domain = strings.TrimSuffix(strings.TrimRight(domain, "/"), "/api")
+		if _, err := url.ParseRequestURI(domain); err != nil {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid domain URL: '%s'", domain))
+		}
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read config file")
+		}
+
+		if profile := util.FindProfile(configFile, name); profile != nil {
+			if profile.Domain != domain {
+				// the logged in user belongs to the old domain
+				profile.LoggedInUserEmail = ""
+			}
+			profile.Domain = domain
+		} else {
+			configFile.Profiles = append(configFile.Profiles, models.Profile{Name: name, Domain: domain})
+		}
+
+		if err := util.WriteConfigFile(&configFile); err != nil {
+			util.HandleError(err, "Unable to write config file")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Profile '%s' now points to %s. Run [infisical login --profile %s] to log in", name, domain, name))
+		Telemetry.CaptureEvent("cli-command:profile add", posthog.NewProperties().Set("version", util.CLI_VERSION))
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:                   "use [name]",
+	Short:                 "Select the profile used when --profile and INFISICAL_PROFILE are not set",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile use eu",
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read config file")
+		}
+
+		if util.FindProfile(configFile, args[0]) == nil {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Profile '%s' does not exist", args[0]))
+		}
+
+		configFile.ActiveProfile = args[0]
+		if err := util.WriteConfigFile(&configFile); err != nil {
+			util.HandleError(err, "Unable to write config file")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Now using profile '%s'", args[0]))
+	},
+}
+
+var profileClearCmd = &cobra.Command{
+	Use:                   "clear",
+	Short:                 "Stop using a default profile and go back to the globally logged in user",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile clear",
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read config file")
+		}
+
+		configFile.ActiveProfile = ""
+		if err := util.WriteConfigFile(&configFile); err != nil {
+			util.HandleError(err, "Unable to write config file")
+		}
+
+		util.PrintSuccessMessage("No profile is selected by default anymore")
+	},
+}
+
+var profileListCmd = &cobra.Command{
+	Use:                   "list",
+	Short:                 "List profiles",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile list",
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read config file")
+		}
+
+		if len(configFile.Profiles) == 0 {
+			fmt.Println("No profiles yet. Create one with [infisical profile add <name> --domain <url>]")
+			return
+		}
+
+		rows := [][]string{}
+		for _, profile := range configFile.Profiles {
+			active := ""
+			if profile.Name == configFile.ActiveProfile {
+				active = "*"
+			}
+			rows = append(rows, []string{active, profile.Name, profile.Domain, profile.LoggedInUserEmail})
+		}
+
+		visualize.GenericTable([]string{"ACTIVE", "NAME", "DOMAIN", "LOGGED IN USER"}, rows)
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:                   "remove [name]",
+	Short:                 "Delete a profile and the credentials stored for it",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical profile remove eu",
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read config file")
+		}
+
+		profile := util.FindProfile(configFile, args[0])
+		if profile == nil {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Profile '%s' does not exist", args[0]))
+		}
+
+		if profile.LoggedInUserEmail != "" {
+			util.DeleteValueInKeyring(util.ProfileKeyringKey(profile.Name, profile.LoggedInUserEmail))
+		}
+
+		configFile.Profiles = slices.DeleteFunc(configFile.Profiles, func(p models.Profile) bool {
+			return p.Name == args[0]
+		})
+		if configFile.ActiveProfile == args[0] {
+			configFile.ActiveProfile = ""
+		}
+
+		if err := util.WriteConfigFile(&configFile); err != nil {
+			util.HandleError(err, "Unable to write config file")
+		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Removed profile '%s'", args[0]))
+	},
+}
+
+func init() {
+	profileAddCmd.Flags().String("domain", "", "URL of the Infisical instance, e.g. https://infisical.example.com")
+	profileAddCmd.Flags().String("region", "", "Infisical Cloud region (us or eu) instead of --domain")
+
+	profileCmd.AddCommand(profileAddCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileClearCmd)
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+	rootCmd.AddCommand(profileCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileLoginKeepsLoggedInUsers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	previousURL, previousProfile := config.INFISICAL_URL, config.INFISICAL_PROFILE
+	defer func() { config.INFISICAL_URL, config.INFISICAL_PROFILE = previousURL, previousProfile }()
+
+	existing := models.ConfigFile{
+		LoggedInUserEmail:  "dev@example.com",
+		LoggedInUserDomain: "https://app.infisical.com/api",
+		LoggedInUsers: []models.LoggedInUser{
+			{Email: "dev@example.com", Domain: "https://app.infisical.com/api"},
+			{Email: "ops@example.com", Domain: "https://infisical.internal/api"},
+		},
+		Profiles: []models.Profile{{Name: "eu", Domain: "https://eu.infisical.com"}},
+	}
+	content, err := json.Marshal(existing)
+	assert.NoError(t, err)
+	assert.NoError(t, os.MkdirAll(filepath.Join(home, util.CONFIG_FOLDER_NAME), 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(home, util.CONFIG_FOLDER_NAME, util.CONFIG_FILE_NAME), content, 0600))
+
+	// the same user logs in to the eu profile
+	config.INFISICAL_PROFILE = "eu"
+	config.INFISICAL_URL = "https://eu.infisical.com/api"
+	assert.NoError(t, util.WriteInitalConfig(&models.UserCredentials{Email: "dev@example.com"}))
+
+	configFile, err := util.GetConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, existing.LoggedInUsers, configFile.LoggedInUsers)
+	assert.Equal(t, "dev@example.com", configFile.LoggedInUserEmail)
+	assert.Equal(t, "https://app.infisical.com/api", configFile.LoggedInUserDomain)
+	assert.Equal(t, "dev@example.com", configFile.Profiles[0].LoggedInUserEmail)
+
+	// a login outside of profiles adds its user
+	config.INFISICAL_PROFILE = ""
+	config.INFISICAL_URL = "https://app.infisical.com/api"
+	assert.NoError(t, util.WriteInitalConfig(&models.UserCredentials{Email: "new@example.com"}))
+
+	configFile, err = util.GetConfigFile()
+	assert.NoError(t, err)
+	assert.Equal(t, append(existing.LoggedInUsers, models.LoggedInUser{Email: "new@example.com", Domain: "https://app.infisical.com/api"}), configFile.LoggedInUsers)
+	assert.Equal(t, "new@example.com", configFile.LoggedInUserEmail)
+	assert.Equal(t, "dev@example.com", configFile.Profiles[0].LoggedInUserEmail)
+}
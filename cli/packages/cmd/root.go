@@ -6,12 +6,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 
+	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/telemetry"
 	"github.com/Infisical/infisical-merge/packages/util"
@@ -19,6 +22,18 @@ import (
 
 var Telemetry *telemetry.Telemetry
 
+// credentialHelperAnnotation marks commands that other programs run for credentials, such as kubectl. Their
+// output is read by those programs, and they run often, so they skip update checks and tips.
+const credentialHelperAnnotation = "infisical-credential-helper"
+
+// keyringCommandAnnotation marks commands that store credentials in the keyring, which stays enabled for
+// them in CI
+const keyringCommandAnnotation = "infisical-keyring-command"
+
+// credentialHelperExecutables are the commands that programs run under a name of their own, such as
+// docker-credential-infisical, by that name. Linking the CLI as one of them runs its command.
+var credentialHelperExecutables = map[string]*cobra.Command{}
+
 var rootCmd = &cobra.Command{
 	Use:               "infisical",
 	Short:             "Infisical CLI is used to inject environment variables into any process",
@@ -30,26 +45,82 @@ var rootCmd = &cobra.Command{
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
+	args := os.Args[1:]
+	if helperArgs, isCredentialHelper := credentialHelperArgs(os.Args); isCredentialHelper {
+		args = helperArgs
+		rootCmd.SetArgs(args)
+	}
+	if needsPluginCommands(rootCmd, args) {
+		addPluginCommands(rootCmd)
+	}
+
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
 	}
 }
 
+// credentialHelperArgs rewrites the arguments of the CLI when it runs as one of the credential helper
+// executables, so it runs their command
+func credentialHelperArgs(args []string) ([]string, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+
+	name := strings.TrimSuffix(filepath.Base(args[0]), filepath.Ext(args[0]))
+	helperCmd, found := credentialHelperExecutables[name]
+	if !found {
+		return nil, false
+	}
+	return append([]string{helperCmd.Name()}, args[1:]...), true
+}
+
 func init() {
 	cobra.OnInitialize(initLog)
 	rootCmd.PersistentFlags().StringP("log-level", "l", "info", "log level (trace, debug, info, warn, error, fatal)")
+	rootCmd.PersistentFlags().String("log-format", "", "log format, text or json. Defaults to json in CI and text otherwise [can also set via environment variable name: INFISICAL_LOG_FORMAT]")
 	rootCmd.PersistentFlags().Bool("telemetry", true, "Infisical collects non-sensitive telemetry data to enhance features and improve user experience. Participation is voluntary")
 	rootCmd.PersistentFlags().StringVar(&config.INFISICAL_URL, "domain", fmt.Sprintf("%s/api", util.INFISICAL_DEFAULT_US_URL), "Point the CLI to your own backend [can also set via environment variable name: INFISICAL_API_URL]")
+	rootCmd.PersistentFlags().String("profile", "", "Use the domain and logged in user of a named profile created with [infisical profile add] [can also set via environment variable name: INFISICAL_PROFILE]")
+	rootCmd.PersistentFlags().IntVar(&api.DefaultRetryPolicy.MaxRetries, "max-retries", api.DefaultRetryPolicy.MaxRetries, "Number of times failed API requests are retried with exponential backoff [can also set via environment variable name: INFISICAL_HTTP_MAX_RETRIES]")
+	rootCmd.PersistentFlags().DurationVar(&api.DefaultTimeouts.Connect, "http-connect-timeout", api.DefaultTimeouts.Connect, "Timeout for establishing connections to Infisical [can also set via environment variable name: INFISICAL_HTTP_CONNECT_TIMEOUT]")
+	rootCmd.PersistentFlags().DurationVar(&api.DefaultTimeouts.TLSHandshake, "http-tls-timeout", api.DefaultTimeouts.TLSHandshake, "Timeout for TLS handshakes with Infisical [can also set via environment variable name: INFISICAL_HTTP_TLS_TIMEOUT]")
+	rootCmd.PersistentFlags().DurationVar(&api.DefaultTimeouts.ResponseHeader, "http-response-timeout", api.DefaultTimeouts.ResponseHeader, "Timeout waiting for Infisical to start responding [can also set via environment variable name: INFISICAL_HTTP_RESPONSE_TIMEOUT]")
+	rootCmd.PersistentFlags().DurationVar(&api.DefaultTimeouts.Request, "http-timeout", api.DefaultTimeouts.Request, "Overall timeout for a single API request attempt [can also set via environment variable name: INFISICAL_HTTP_TIMEOUT]")
+	rootCmd.PersistentFlags().String("ca-certificate", "", "Path to a PEM encoded CA bundle to trust when connecting to Infisical [can also set via environment variable name: INFISICAL_CA_CERT_PATH]")
+	rootCmd.PersistentFlags().String("client-certificate", "", "Path to a PEM encoded client certificate for mutual TLS with Infisical [can also set via environment variable name: INFISICAL_CLIENT_CERT_PATH]")
+	rootCmd.PersistentFlags().String("client-key", "", "Path to the private key of --client-certificate [can also set via environment variable name: INFISICAL_CLIENT_KEY_PATH]")
+	rootCmd.PersistentFlags().String("proxy", "", "HTTP(S) proxy URL used to reach Infisical. Credentials are read from INFISICAL_HTTP_PROXY_USERNAME and INFISICAL_HTTP_PROXY_PASSWORD [can also set via environment variable name: INFISICAL_HTTP_PROXY]")
+	rootCmd.PersistentFlags().BoolVar(&api.DebugHTTP, "debug-http", false, "Log API requests and responses to stderr with tokens, private keys and secret values redacted [can also set via environment variable name: INFISICAL_DEBUG_HTTP]")
+	rootCmd.PersistentFlags().BoolVar(&api.DisableResponseCache, "no-cache", false, "Always download full API responses instead of revalidating cached ones with ETags")
 	rootCmd.PersistentFlags().Bool("silent", false, "Disable output of tip/info messages. Useful when running in scripts or CI/CD pipelines.")
 	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		initCIDefaults(cmd)
+
 		silent, err := cmd.Flags().GetBool("silent")
 		if err != nil {
 			util.HandleError(err)
 		}
+		// nobody reads tips in CI
+		if _, inCI := util.DetectCIEnvironment(); inCI && !cmd.Flags().Changed("silent") {
+			silent = true
+		}
+		if _, isCredentialHelper := cmd.Annotations[credentialHelperAnnotation]; isCredentialHelper {
+			silent = true
+		}
+
+		profileName, _ := util.GetCmdFlagOrEnv(cmd, "profile", util.INFISICAL_PROFILE_NAME)
+		_, domainFromEnv := os.LookupEnv("INFISICAL_API_URL")
+		if err := util.ResolveProfile(profileName, cmd.Flags().Changed("domain") || domainFromEnv); err != nil {
+			util.HandleError(err, "Unable to select profile")
+		}
 
 		config.INFISICAL_URL = util.AppendAPIEndpoint(config.INFISICAL_URL)
 
+		if err := configureAPIConnection(cmd); err != nil {
+			util.HandleError(err, "Unable to configure the connection to Infisical")
+		}
+
 		if !util.IsRunningInDocker() && !silent {
 			util.CheckForUpdate()
 		}
@@ -74,10 +145,72 @@ func init() {
 		}
 	}
 
+	if !rootCmd.Flag("max-retries").Changed {
+		if envMaxRetries, ok := os.LookupEnv(util.INFISICAL_HTTP_MAX_RETRIES_NAME); ok {
+			maxRetries, err := strconv.Atoi(envMaxRetries)
+			if err != nil || maxRetries < 0 {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("%s must be a non-negative number", util.INFISICAL_HTTP_MAX_RETRIES_NAME))
+			}
+			api.DefaultRetryPolicy.MaxRetries = maxRetries
+		}
+	}
+
+	for flagName, envName := range map[string]string{
+		"debug-http":            util.INFISICAL_DEBUG_HTTP_NAME,
+		"http-connect-timeout":  util.INFISICAL_HTTP_CONNECT_TIMEOUT_NAME,
+		"http-tls-timeout":      util.INFISICAL_HTTP_TLS_TIMEOUT_NAME,
+		"http-response-timeout": util.INFISICAL_HTTP_RESPONSE_TIMEOUT_NAME,
+		"http-timeout":          util.INFISICAL_HTTP_TIMEOUT_NAME,
+	} {
+		if envValue, ok := os.LookupEnv(envName); ok && !rootCmd.Flag(flagName).Changed {
+			if err := rootCmd.PersistentFlags().Set(flagName, envValue); err != nil {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("%s must be a duration such as 30s: %s", envName, err))
+			}
+		}
+	}
+
 	isTelemetryOn, _ := rootCmd.PersistentFlags().GetBool("telemetry")
 	Telemetry = telemetry.NewTelemetry(isTelemetryOn)
 }
 
+func configureAPIConnection(cmd *cobra.Command) error {
+	flagOrEnv := func(flag, envName string) string {
+		value, _ := util.GetCmdFlagOrEnv(cmd, flag, envName)
+		return value
+	}
+
+	return api.ConfigureConnection(api.ConnectionSettings{
+		CACertPath:     flagOrEnv("ca-certificate", util.INFISICAL_CA_CERT_PATH_NAME),
+		ClientCertPath: flagOrEnv("client-certificate", util.INFISICAL_CLIENT_CERT_PATH_NAME),
+		ClientKeyPath:  flagOrEnv("client-key", util.INFISICAL_CLIENT_KEY_PATH_NAME),
+		ProxyURL:       flagOrEnv("proxy", util.INFISICAL_HTTP_PROXY_NAME),
+		ProxyUsername:  os.Getenv(util.INFISICAL_HTTP_PROXY_USERNAME_NAME),
+		ProxyPassword:  os.Getenv(util.INFISICAL_HTTP_PROXY_PASSWORD_NAME),
+	})
+}
+
+// initCIDefaults makes the CLI run unattended on CI platforms, where there's no keyring to unlock. Machine
+// identities log in by themselves there, see getTokenOrLoginInCI. The keyring is only disabled when nothing
+// is set up to use it: no vault backend was picked, no user is logged in and the command doesn't log in.
+func initCIDefaults(cmd *cobra.Command) {
+	platform, inCI := util.DetectCIEnvironment()
+	if !inCI {
+		return
+	}
+
+	if _, usesKeyring := cmd.Annotations[keyringCommandAnnotation]; usesKeyring {
+		return
+	}
+
+	configFile, err := util.GetConfigFile()
+	if err != nil || util.IsKeyringConfigured(configFile) {
+		return
+	}
+
+	log.Debug().Msgf("running on %s, the keyring is disabled", platform)
+	util.DisableKeyring()
+}
+
 func initLog() {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	ll, err := rootCmd.Flags().GetString("log-level")
@@ -100,4 +233,20 @@ func initLog() {
 	default:
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
+
+	logFormat, _ := rootCmd.Flags().GetString("log-format")
+	if logFormat == "" {
+		logFormat = os.Getenv(util.INFISICAL_LOG_FORMAT_NAME)
+	}
+	if _, inCI := util.DetectCIEnvironment(); logFormat == "" && inCI {
+		logFormat = "json"
+	}
+
+	switch strings.ToLower(logFormat) {
+	case "", "text":
+	case "json":
+		log.Logger = zerolog.New(os.Stderr).With().Timestamp().Logger()
+	default:
+		util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid log format: %s, use text or json", logFormat))
+	}
 }
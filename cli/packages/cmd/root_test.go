@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialHelperArgs(t *testing.T) {
+	tests := []struct {
+		name         string
+		args         []string
+		wantArgs     []string
+		wantIsHelper bool
+	}{
+		{name: "docker credential helper", args: []string{"/usr/local/bin/docker-credential-infisical", "get"}, wantArgs: []string{"docker-credential", "get"}, wantIsHelper: true},
+		{name: "git credential helper", args: []string{"/usr/local/bin/git-credential-infisical", "get"}, wantArgs: []string{"git-credential", "get"}, wantIsHelper: true},
+		{name: "windows executable", args: []string{"git-credential-infisical.exe", "store"}, wantArgs: []string{"git-credential", "store"}, wantIsHelper: true},
+		{name: "infisical", args: []string{"/usr/local/bin/infisical", "secrets"}},
+		{name: "no arguments", args: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, isHelper := credentialHelperArgs(test.args)
+			assert.Equal(t, test.wantIsHelper, isHelper)
+			if test.wantIsHelper {
+				assert.Equal(t, test.wantArgs, args)
+			}
+		})
+	}
+}
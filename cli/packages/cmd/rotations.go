@@ -0,0 +1,315 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/go-resty/resty/v2"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	ROTATION_STATUS_SUCCESS = "success"
+	ROTATION_STATUS_FAILED  = "failed"
+	ROTATION_STATUS_PENDING = "pending"
+)
+
+const secretRotationPollInterval = 3 * time.Second
+
+var rotationsCmd = &cobra.Command{
+	Example: `
+	infisical rotations list --env=prod
+	infisical rotations trigger <rotation id> --wait
+	infisical rotations status <rotation id>`,
+	Short:                 "Used to trigger and monitor secret rotations",
+	Long:                  "Trigger and monitor the rotations of rotated secrets, such as database passwords and API keys, from runbooks and CI. Failed rotations exit with code 1.",
+	Use:                   "rotations",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var rotationsListCmd = &cobra.Command{
+	Example:               `infisical rotations list --env=prod`,
+	Short:                 "Used to list the secret rotations of a project",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, err := cmd.Flags().GetString("env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat := getOutputFormat(cmd)
+		httpClient, projectId := newRotationsHTTPClient(cmd)
+
+		rotationsResponse, err := api.CallGetSecretRotationsV1(httpClient, projectId)
+		if err != nil {
+			util.HandleError(err, "Unable to list secret rotations")
+		}
+
+		rotations := []api.SecretRotation{}
+		for _, rotation := range rotationsResponse.SecretRotations {
+			if environmentName == "" || rotation.Environment.Slug == environmentName {
+				rotations = append(rotations, rotation)
+			}
+		}
+
+		printOutput(outputFormat, toSecretRotationsOutput(rotations), func() {
+			if len(rotations) == 0 {
+				fmt.Println("No secret rotations found")
+				return
+			}
+			printSecretRotationsAsTable(rotations)
+		})
+
+		Telemetry.CaptureEvent("cli-command:rotations list", posthog.NewProperties().Set("count", len(rotations)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var rotationsStatusCmd = &cobra.Command{
+	Example:               `infisical rotations status <rotation id>`,
+	Short:                 "Used to show the status of the last run of a secret rotation, exiting with code 1 when it failed",
+	Use:                   "status [rotation-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat := getOutputFormat(cmd)
+		httpClient, projectId := newRotationsHTTPClient(cmd)
+
+		rotation, err := getSecretRotation(httpClient, projectId, args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to get the secret rotation")
+		}
+
+		printOutput(outputFormat, toSecretRotationOutput(rotation), func() {
+			printSecretRotationsAsTable([]api.SecretRotation{rotation})
+			if rotation.Status == ROTATION_STATUS_FAILED && rotation.StatusMessage != "" {
+				fmt.Println(rotation.StatusMessage)
+			}
+		})
+
+		Telemetry.CaptureEvent("cli-command:rotations status", posthog.NewProperties().Set("version", util.CLI_VERSION))
+
+		if rotation.Status == ROTATION_STATUS_FAILED {
+			os.Exit(1)
+		}
+	},
+}
+
+var rotationsTriggerCmd = &cobra.Command{
+	Example: `
+	infisical rotations trigger <rotation id>
+	infisical rotations trigger <rotation id> --wait --timeout=10m`,
+	Short:                 "Used to rotate the secrets of a secret rotation now",
+	Long:                  "Rotate the secrets of a secret rotation now. Infisical rotates them in the background, so with --wait the command blocks until the rotation completes, and exits with code 1 when it fails or doesn't complete within --timeout.",
+	Use:                   "trigger [rotation-id]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		shouldWait, err := cmd.Flags().GetBool("wait")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		timeout, err := cmd.Flags().GetDuration("timeout")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		outputFormat := getOutputFormat(cmd)
+		httpClient, projectId := newRotationsHTTPClient(cmd)
+
+		// the rotation is done once it records a run after the one before the trigger, which doesn't
+		// depend on the clocks of Infisical and this machine agreeing
+		previous, err := getSecretRotation(httpClient, projectId, args[0])
+		if err != nil {
+			util.HandleError(err, "Unable to get the secret rotation")
+		}
+
+		_, err = api.CallRestartSecretRotationV1(httpClient, api.RestartSecretRotationV1Request{ID: args[0]})
+		if err != nil {
+			util.HandleError(err, "Unable to trigger the secret rotation")
+		}
+
+		Telemetry.CaptureEvent("cli-command:rotations trigger", posthog.NewProperties().Set("wait", shouldWait).Set("version", util.CLI_VERSION))
+
+		if !shouldWait {
+			util.PrintSuccessMessage(fmt.Sprintf("Triggered secret rotation %s, check it with [infisical rotations status %s]", args[0], args[0]))
+			return
+		}
+
+		if outputFormat == OutputTable {
+			fmt.Fprintf(os.Stderr, "Triggered secret rotation %s, waiting for it to complete...\n", args[0])
+		}
+
+		rotation, err := waitForSecretRotation(httpClient, projectId, previous, timeout)
+		if err != nil {
+			util.HandleError(err, "Unable to wait for the secret rotation")
+		}
+
+		printOutput(outputFormat, toSecretRotationOutput(rotation), func() {
+			printSecretRotationsAsTable([]api.SecretRotation{rotation})
+		})
+
+		if rotation.Status == ROTATION_STATUS_FAILED {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Secret rotation %s failed: %s", rotation.ID, rotation.StatusMessage))
+		}
+	},
+}
+
+// newRotationsHTTPClient returns a client for the project of the --projectId flag, or of the local
+// project when logged in. In CI it logs in as the machine identity of the job, see getTokenOrLoginInCI.
+func newRotationsHTTPClient(cmd *cobra.Command) (*resty.Client, string) {
+	token, err := getTokenOrLoginInCI(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	httpClient, _, projectId := newProjectHTTPClient(token, projectId)
+	return httpClient, projectId
+}
+
+func getSecretRotation(httpClient *resty.Client, projectId string, rotationId string) (api.SecretRotation, error) {
+	rotationsResponse, err := api.CallGetSecretRotationsV1(httpClient, projectId)
+	if err != nil {
+		return api.SecretRotation{}, err
+	}
+
+	for _, rotation := range rotationsResponse.SecretRotations {
+		if rotation.ID == rotationId {
+			return rotation, nil
+		}
+	}
+	return api.SecretRotation{}, fmt.Errorf("no secret rotation with the ID %s in the project", rotationId)
+}
+
+// waitForSecretRotation polls the rotation until it records a run after the previous one
+func waitForSecretRotation(httpClient *resty.Client, projectId string, previous api.SecretRotation, timeout time.Duration) (api.SecretRotation, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		time.Sleep(secretRotationPollInterval)
+
+		rotation, err := getSecretRotation(httpClient, projectId, previous.ID)
+		if err != nil {
+			return api.SecretRotation{}, err
+		}
+		if hasSecretRotationRunSince(rotation, previous) {
+			return rotation, nil
+		}
+
+		if time.Now().After(deadline) {
+			return api.SecretRotation{}, fmt.Errorf("the secret rotation didn't complete within %s", timeout)
+		}
+	}
+}
+
+func hasSecretRotationRunSince(rotation api.SecretRotation, previous api.SecretRotation) bool {
+	if rotation.LastRotatedAt == nil {
+		return false
+	}
+	return previous.LastRotatedAt == nil || rotation.LastRotatedAt.After(*previous.LastRotatedAt)
+}
+
+func secretRotationStatus(rotation api.SecretRotation) string {
+	if rotation.Status == "" {
+		return ROTATION_STATUS_PENDING
+	}
+	return rotation.Status
+}
+
+func secretRotationSecretKeys(rotation api.SecretRotation) []string {
+	secretKeys := []string{}
+	for _, output := range rotation.Outputs {
+		secretKeys = append(secretKeys, output.Secret.SecretKey)
+	}
+	return secretKeys
+}
+
+func printSecretRotationsAsTable(rotations []api.SecretRotation) {
+	rows := [][]string{}
+	for _, rotation := range rotations {
+		lastRotatedAt := "never"
+		if rotation.LastRotatedAt != nil {
+			lastRotatedAt = rotation.LastRotatedAt.Local().Format("2006-01-02 15:04")
+		}
+
+		rows = append(rows, []string{
+			rotation.ID,
+			rotation.Provider,
+			fmt.Sprintf("%s:%s", rotation.Environment.Slug, rotation.SecretPath),
+			strings.Join(secretRotationSecretKeys(rotation), ", "),
+			fmt.Sprintf("%dd", rotation.Interval),
+			secretRotationStatus(rotation),
+			lastRotatedAt,
+		})
+	}
+	visualize.GenericTable([]string{"ID", "PROVIDER", "FOLDER", "SECRETS", "INTERVAL", "STATUS", "LAST ROTATED"}, rows)
+}
+
+type secretRotationOutput struct {
+	Id            string     `json:"id"`
+	Provider      string     `json:"provider"`
+	Environment   string     `json:"environment"`
+	SecretPath    string     `json:"secretPath"`
+	Secrets       []string   `json:"secrets"`
+	IntervalDays  int        `json:"intervalDays"`
+	Status        string     `json:"status"`
+	StatusMessage string     `json:"statusMessage,omitempty"`
+	LastRotatedAt *time.Time `json:"lastRotatedAt"`
+}
+
+func toSecretRotationOutput(rotation api.SecretRotation) secretRotationOutput {
+	return secretRotationOutput{
+		Id:            rotation.ID,
+		Provider:      rotation.Provider,
+		Environment:   rotation.Environment.Slug,
+		SecretPath:    rotation.SecretPath,
+		Secrets:       secretRotationSecretKeys(rotation),
+		IntervalDays:  rotation.Interval,
+		Status:        secretRotationStatus(rotation),
+		StatusMessage: rotation.StatusMessage,
+		LastRotatedAt: rotation.LastRotatedAt,
+	}
+}
+
+func toSecretRotationsOutput(rotations []api.SecretRotation) []secretRotationOutput {
+	output := []secretRotationOutput{}
+	for _, rotation := range rotations {
+		output = append(output, toSecretRotationOutput(rotation))
+	}
+	return output
+}
+
+func init() {
+	rotationsCmd.PersistentFlags().String("token", "", "Manage secret rotations using a machine identity access token")
+	rotationsCmd.PersistentFlags().String("projectId", "", "manually set the project ID of the secret rotations when using machine identity based auth")
+
+	rotationsListCmd.Flags().String("env", "", "only list secret rotations of this environment")
+	addOutputFlag(rotationsListCmd)
+	rotationsCmd.AddCommand(rotationsListCmd)
+
+	addOutputFlag(rotationsStatusCmd)
+	rotationsCmd.AddCommand(rotationsStatusCmd)
+
+	rotationsTriggerCmd.Flags().Bool("wait", false, "wait for the rotation to complete, and exit with code 1 when it fails")
+	rotationsTriggerCmd.Flags().Duration("timeout", 5*time.Minute, "how long --wait waits for the rotation to complete")
+	rotationsTriggerCmd.Flags().StringP("output", "o", OutputTable, "the output format of the completed rotation with --wait: table, json or yaml")
+	rotationsCmd.AddCommand(rotationsTriggerCmd)
+
+	rootCmd.AddCommand(rotationsCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHasSecretRotationRunSince(t *testing.T) {
+	var rotation api.SecretRotation
+	err := json.Unmarshal([]byte(`{
+		"id": "r1",
+		"provider": "postgres",
+		"interval": 30,
+		"secretPath": "/db",
+		"environment": {"slug": "prod"},
+		"outputs": [{"key": "username", "secret": {"secretKey": "DB_USER"}}, {"key": "password", "secret": {"secretKey": "DB_PASSWORD"}}]
+	}`), &rotation)
+	assert.NoError(t, err)
+
+	output := toSecretRotationOutput(rotation)
+	assert.Equal(t, ROTATION_STATUS_PENDING, output.Status)
+	assert.Equal(t, []string{"DB_USER", "DB_PASSWORD"}, output.Secrets)
+
+	previous := rotation
+	assert.False(t, hasSecretRotationRunSince(rotation, previous))
+
+	rotatedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotation.LastRotatedAt = &rotatedAt
+	assert.True(t, hasSecretRotationRunSince(rotation, previous))
+
+	previous.LastRotatedAt = &rotatedAt
+	assert.False(t, hasSecretRotationRunSince(rotation, previous))
+
+	rotatedAgainAt := rotatedAt.Add(time.Minute)
+	rotation.LastRotatedAt = &rotatedAgainAt
+	assert.True(t, hasSecretRotationRunSince(rotation, previous))
+}
@@ -4,12 +4,15 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -30,6 +33,9 @@ var runCmd = &cobra.Command{
 	Example: `
 	infisical run --env=dev -- npm run dev
 	infisical run --command "first-command && second-command; more-commands..."
+	infisical run --env=dev --path=/shared --source path=/apps/api -- npm run dev
+	infisical run --method=aws-iam --machine-identity-id=<identity id> --projectId=<project id> --env=prod -- ./backup.sh
+	infisical run --method=gcp --machine-identity-id=<identity id> --projectId=<project id> --env=prod -- ./server
 	`,
 	Use:                   "run [any infisical run command flags] -- [your application start command]",
 	Short:                 "Used to inject environments variables into your application process",
@@ -67,10 +73,29 @@ var runCmd = &cobra.Command{
 			}
 		}
 
-		token, err := util.GetInfisicalToken(cmd)
+		authMethod, err := cmd.Flags().GetString("method")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
+		var token *models.TokenDetails
+		var tokenLifetime *util.AccessTokenLifetime
+		var authStrategy util.AuthStrategyType
+		if authMethod != "" {
+			authStrategy, err = getRunAuthStrategy(authMethod)
+			if err != nil {
+				util.PrintErrorMessageAndExit(err.Error())
+			}
+
+			token, tokenLifetime, err = loginWithMachineIdentity(cmd, authStrategy)
+			if err != nil {
+				util.HandleError(err)
+			}
+		} else {
+			token, err = getTokenOrLoginInCI(cmd)
+			if err != nil {
+				util.HandleError(err)
+			}
+		}
 
 		projectConfigDir, err := cmd.Flags().GetString("project-config-dir")
 		if err != nil {
@@ -96,6 +121,11 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		noOverride, err := cmd.Flags().GetBool("no-override")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		watchMode, err := cmd.Flags().GetBool("watch")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
@@ -106,6 +136,25 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		maskOutput, err := cmd.Flags().GetBool("mask-output")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsAsFiles, err := cmd.Flags().GetString("secrets-as-files")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		fileSecretKeys := parseFileSecretKeys(secretsAsFiles)
+
+		secretsVia, err := cmd.Flags().GetString("secrets-via")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if err := validateSecretsVia(secretsVia); err != nil {
+			util.HandleError(err)
+		}
+
 		// If the --watch flag has been set, the --watch-interval flag should also be set
 		if watchMode && watchModeInterval < 5 {
 			util.HandleError(fmt.Errorf("watch interval must be at least 5 seconds, you passed %d seconds", watchModeInterval))
@@ -116,11 +165,21 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		expandLocally, err := cmd.Flags().GetBool("expand-locally")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		tagsMatch, err := cmd.Flags().GetString("tags-match")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		secretsPath, err := cmd.Flags().GetString("path")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
@@ -136,17 +195,64 @@ var runCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		prefixKeysWithPath, err := cmd.Flags().GetBool("prefix-keys-with-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		pathSeparator, err := cmd.Flags().GetString("path-separator")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keyTemplate, err := cmd.Flags().GetString("key-template")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keyPathSeparator := ""
+		if prefixKeysWithPath {
+			keyPathSeparator = pathSeparator
+		}
+
+		offlineFallback, err := cmd.Flags().GetBool("offline-fallback")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		maxStaleness, err := cmd.Flags().GetDuration("max-staleness")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		request := models.GetAllSecretsParameters{
-			Environment:            environmentName,
-			WorkspaceId:            projectId,
-			TagSlugs:               tagSlugs,
-			SecretsPath:            secretsPath,
-			IncludeImport:          includeImports,
-			Recursive:              recursive,
-			ExpandSecretReferences: shouldExpandSecrets,
+			Environment:                   environmentName,
+			WorkspaceId:                   projectId,
+			TagSlugs:                      tagSlugs,
+			TagsMatch:                     tagsMatch,
+			ExcludePersonalSecrets:        noOverride,
+			SecretsPath:                   secretsPath,
+			IncludeImport:                 includeImports,
+			Recursive:                     recursive,
+			KeyPathSeparator:              keyPathSeparator,
+			KeyTemplate:                   keyTemplate,
+			ExpandSecretReferences:        shouldExpandSecrets,
+			ExpandSecretReferencesLocally: shouldExpandSecrets && expandLocally,
+			OfflineFallback:               offlineFallback,
+			MaxCacheStaleness:             maxStaleness,
+		}
+
+		sources, err := cmd.Flags().GetStringArray("source")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		requests, err := parseRunSources(request, sources)
+		if err != nil {
+			util.HandleError(err, "Invalid --source")
 		}
 
-		injectableEnvironment, err := fetchAndFormatSecretsForShell(request, projectConfigDir, secretOverriding, token)
+		injectableEnvironment, err := fetchAndFormatSecretsForShell(requests, projectConfigDir, secretOverriding, token, fileSecretKeys)
 		if err != nil {
 			util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
 		}
@@ -154,19 +260,38 @@ var runCmd = &cobra.Command{
 		log.Debug().Msgf("injecting the following environment variables into shell: %v", injectableEnvironment.Variables)
 
 		if watchMode {
-			executeCommandWithWatchMode(command, args, watchModeInterval, request, projectConfigDir, secretOverriding, token)
+			tokenRenewer := startRunTokenRenewal(cmd, token, tokenLifetime, authStrategy)
+			executeCommandWithWatchMode(command, args, watchModeInterval, requests, projectConfigDir, secretOverriding, token, tokenRenewer, maskOutput, fileSecretKeys, secretsVia)
 		} else {
+			var secretsDir string
+			extraFiles, err := passSecretsOutsideEnv(secretsVia, &secretsDir, &injectableEnvironment)
+			if err != nil {
+				runCommandCleanups()
+				util.HandleError(err, "Unable to pass secrets outside the environment")
+			}
+
+			if len(fileSecretKeys) > 0 {
+				injectableEnvironment.Variables, err = prepareSecretFiles(&secretsDir, injectableEnvironment.FileSecrets, injectableEnvironment.Variables)
+				if err != nil {
+					runCommandCleanups()
+					util.HandleError(err, "Unable to pass secrets as files")
+				}
+			}
+
+			stdout, stderr := commandOutputs(maskOutput, injectableEnvironment.SecretValues)
 			if cmd.Flags().Changed("command") {
 				command := cmd.Flag("command").Value.String()
-				err = executeMultipleCommandWithEnvs(command, injectableEnvironment.SecretsCount, injectableEnvironment.Variables)
+				err = executeMultipleCommandWithEnvs(command, injectableEnvironment.SecretsCount, injectableEnvironment.Variables, stdout, stderr, extraFiles)
 				if err != nil {
+					runCommandCleanups()
 					fmt.Println(err)
 					os.Exit(1)
 				}
 
 			} else {
-				err = executeSingleCommandWithEnvs(args, injectableEnvironment.SecretsCount, injectableEnvironment.Variables)
+				err = executeSingleCommandWithEnvs(args, injectableEnvironment.SecretsCount, injectableEnvironment.Variables, stdout, stderr, extraFiles)
 				if err != nil {
+					runCommandCleanups()
 					fmt.Println(err)
 					os.Exit(1)
 				}
@@ -176,6 +301,32 @@ var runCmd = &cobra.Command{
 	},
 }
 
+// runAuthStrategies are the machine identity auth methods run can log in with by itself, those that
+// need no credential besides the identity ID
+var runAuthStrategies = []util.AuthStrategyType{
+	util.AuthStrategy.AWS_IAM_AUTH,
+	util.AuthStrategy.AZURE_AUTH,
+	util.AuthStrategy.GCP_ID_TOKEN_AUTH,
+	util.AuthStrategy.TLS_CERT_AUTH,
+}
+
+// getRunAuthStrategy returns the auth strategy of the --method of run, if run can log in with it
+func getRunAuthStrategy(authMethod string) (util.AuthStrategyType, error) {
+	authMethodValid, authStrategy := util.IsAuthMethodValid(authMethod, false)
+	if !authMethodValid || !slices.Contains(runAuthStrategies, authStrategy) {
+		return "", fmt.Errorf("invalid login method: %s. infisical run supports %s", authMethod, describeAuthStrategies(runAuthStrategies))
+	}
+	return authStrategy, nil
+}
+
+func describeAuthStrategies(strategies []util.AuthStrategyType) string {
+	names := []string{}
+	for _, strategy := range strategies {
+		names = append(names, string(strategy))
+	}
+	return strings.Join(names, ", ")
+}
+
 func filterReservedEnvVars(env map[string]models.SingleEnvironmentVariable) {
 	var (
 		reservedEnvVars = []string{
@@ -210,22 +361,36 @@ func filterReservedEnvVars(env map[string]models.SingleEnvironmentVariable) {
 func init() {
 	rootCmd.AddCommand(runCmd)
 	runCmd.Flags().String("token", "", "fetch secrets using service token or machine identity access token")
+	runCmd.Flags().String("method", "", "log in as a machine identity with this auth method instead of using a token: aws-iam, which signs in with the default AWS credential chain, e.g. environment variables, AWS SSO profiles or the instance profile, azure and gcp, which use the identity token of the VM from its metadata service, or tls-cert, which uses the certificate given with --client-certificate and --client-key")
+	runCmd.Flags().String("machine-identity-id", "", "the machine identity to log in as with --method [can also set via environment variable name: INFISICAL_MACHINE_IDENTITY_ID]")
 	runCmd.Flags().String("projectId", "", "manually set the project ID to fetch secrets from when using machine identity based auth")
 	runCmd.Flags().StringP("env", "e", "dev", "set the environment (dev, prod, etc.) from which your secrets should be pulled from")
 	runCmd.Flags().Bool("expand", true, "parse shell parameter expansions in your secrets")
+	runCmd.Flags().Bool("expand-locally", false, "resolve secret references in the CLI instead of on the server, for self-hosted instances that return them unexpanded")
 	runCmd.Flags().Bool("include-imports", true, "import linked secrets ")
 	runCmd.Flags().Bool("recursive", false, "fetch secrets from all sub-folders")
+	runCmd.Flags().Bool("prefix-keys-with-path", false, "with --recursive, prefix the names of secrets in sub-folders with their folder path, e.g. DB__PASSWORD for PASSWORD in /db")
+	runCmd.Flags().String("path-separator", "__", "the separator between folder names and the secret name used by --prefix-keys-with-path")
+	runCmd.Flags().String("key-template", "", "with --recursive, a Go template for the name of each secret, e.g. '{{ join \"_\" (upper .Folder) .Key }}'. It has .Key, .Path, .Folder and .Environment, and the upper, lower, replace and join functions")
+	runCmd.Flags().Bool("offline-fallback", false, "with --token, cache fetched secrets encrypted with a key in the system keyring, and use them when Infisical can't be reached. Logged in users always have this")
+	runCmd.Flags().Duration("max-staleness", 0, "don't use cached secrets older than this when Infisical can't be reached, e.g. 24h. 0 allows any age")
 	runCmd.Flags().Bool("secret-overriding", true, "prioritizes personal secrets, if any, with the same name over shared secrets")
+	runCmd.Flags().Bool("no-override", false, "ignore your personal overrides and use the shared values of your team")
 	runCmd.Flags().Bool("watch", false, "enable reload of application when secrets change")
 	runCmd.Flags().Int("watch-interval", 10, "interval in seconds to check for secret changes")
+	runCmd.Flags().StringArray("source", []string{}, "additional secrets to merge in, as comma separated projectId=, env= and path= overrides of the flags above (e.g. --source env=dev,path=/shared). Secrets from later sources win")
+	runCmd.Flags().String("secrets-as-files", "", "comma separated secret names, or * for all, passed to the command as files in the directory $INFISICAL_SECRETS_DIR instead of environment variables")
+	runCmd.Flags().String("secrets-via", secretsViaEnv, "how secrets reach the command: env for environment variables, file for a dotenv file at $INFISICAL_ENV_FILE or fd for a pipe at the file descriptor in $INFISICAL_ENV_FD. Use file or fd when secrets exceed the size limits of the environment")
+	runCmd.Flags().Bool("mask-output", false, "replace secret values in the output of the command with ***. The command's output is no longer a terminal, which some programs react to")
 	runCmd.Flags().StringP("command", "c", "", "chained commands to execute (e.g. \"npm install && npm run dev; echo ...\")")
 	runCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs ")
+	runCmd.Flags().String("tags-match", util.TAGS_MATCH_ANY, "with --tags, whether secrets need any or all of the tags (any, all)")
 	runCmd.Flags().String("path", "/", "get secrets within a folder path")
 	runCmd.Flags().String("project-config-dir", "", "explicitly set the directory where the .infisical.json resides")
 }
 
 // Will execute a single command and pass in the given secrets into the process
-func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string) error {
+func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string, stdout io.Writer, stderr io.Writer, extraFiles []*os.File) error {
 	command := args[0]
 	argsForCommand := args[1:]
 
@@ -233,14 +398,15 @@ func executeSingleCommandWithEnvs(args []string, secretsCount int, env []string)
 
 	cmd := exec.Command(command, argsForCommand...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
+	cmd.ExtraFiles = extraFiles
 
 	return execBasicCmd(cmd)
 }
 
-func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []string) error {
+func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []string, stdout io.Writer, stderr io.Writer, extraFiles []*os.File) error {
 	shell := [2]string{"sh", "-c"}
 	if runtime.GOOS == "windows" {
 		shell = [2]string{"cmd", "/C"}
@@ -253,9 +419,10 @@ func executeMultipleCommandWithEnvs(fullCommand string, secretsCount int, env []
 
 	cmd := exec.Command(shell[0], shell[1], fullCommand)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
+	cmd.ExtraFiles = extraFiles
 
 	log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into your application process", secretsCount))
 	log.Debug().Msgf("executing command: %s %s %s \n", shell[0], shell[1], fullCommand)
@@ -270,6 +437,7 @@ func execBasicCmd(cmd *exec.Cmd) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
+	util.CloseInheritedFiles(cmd)
 
 	go func() {
 		for {
@@ -278,18 +446,41 @@ func execBasicCmd(cmd *exec.Cmd) error {
 		}
 	}()
 
-	if err := cmd.Wait(); err != nil {
+	err := cmd.Wait()
+	flushCommandOutputs(cmd)
+	if err != nil {
 		_ = cmd.Process.Signal(os.Kill)
 		return fmt.Errorf("failed to wait for command termination: %v", err)
 	}
 
 	waitStatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
+	runCommandCleanups()
 	os.Exit(waitStatus.ExitStatus())
 	return nil
 }
 
+// commandOutputs returns the writers for the output of the command, masking the secret values when
+// requested
+func commandOutputs(maskOutput bool, secretValues []string) (io.Writer, io.Writer) {
+	if !maskOutput {
+		return os.Stdout, os.Stderr
+	}
+	return util.NewMaskingWriter(os.Stdout, secretValues), util.NewMaskingWriter(os.Stderr, secretValues)
+}
+
+// flushCommandOutputs writes output the masking writers held back once the command has exited
+func flushCommandOutputs(cmd *exec.Cmd) {
+	for _, output := range []io.Writer{cmd.Stdout, cmd.Stderr} {
+		if maskingWriter, ok := output.(*util.MaskingWriter); ok {
+			maskingWriter.Flush()
+		}
+	}
+}
+
 func waitForExitCommand(cmd *exec.Cmd) (int, error) {
-	if err := cmd.Wait(); err != nil {
+	err := cmd.Wait()
+	flushCommandOutputs(cmd)
+	if err != nil {
 		// ignore errors
 		cmd.Process.Signal(os.Kill) // #nosec G104
 
@@ -307,7 +498,46 @@ func waitForExitCommand(cmd *exec.Cmd) (int, error) {
 	return waitStatus.ExitStatus(), nil
 }
 
-func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInterval int, request models.GetAllSecretsParameters, projectConfigDir string, secretOverriding bool, token *models.TokenDetails) {
+// startRunTokenRenewal keeps the machine identity access token of run --watch valid while the command runs.
+// Tokens from --method are renewed by logging in again once they reach their max TTL. It returns nil for
+// service tokens and logged in users. tokenLifetime is nil unless run logged in itself.
+func startRunTokenRenewal(cmd *cobra.Command, token *models.TokenDetails, tokenLifetime *util.AccessTokenLifetime, authStrategy util.AuthStrategyType) *util.AccessTokenRenewer {
+	if token == nil || token.Type != util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		return nil
+	}
+
+	tokenRenewer := util.NewAccessTokenRenewer(token.Token, tokenLifetime)
+	if authStrategy != "" {
+		tokenRenewer.Reauthenticate = func() (string, util.AccessTokenLifetime, error) {
+			newToken, newTokenLifetime, err := loginWithMachineIdentity(cmd, authStrategy)
+			if err != nil {
+				return "", util.AccessTokenLifetime{}, err
+			}
+			return newToken.Token, *newTokenLifetime, nil
+		}
+	}
+
+	go func() {
+		if err := tokenRenewer.Run(context.Background()); err != nil {
+			log.Error().Err(err).Msg("[HOT RELOAD] Secrets can no longer be fetched, the command keeps running with the last ones")
+		}
+	}()
+
+	return tokenRenewer
+}
+
+// currentRunToken returns token with the latest access token of tokenRenewer, if it is renewed
+func currentRunToken(token *models.TokenDetails, tokenRenewer *util.AccessTokenRenewer) *models.TokenDetails {
+	if tokenRenewer == nil {
+		return token
+	}
+
+	renewedToken := *token
+	renewedToken.Token = tokenRenewer.Token()
+	return &renewedToken
+}
+
+func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInterval int, requests []models.GetAllSecretsParameters, projectConfigDir string, secretOverriding bool, token *models.TokenDetails, tokenRenewer *util.AccessTokenRenewer, maskOutput bool, fileSecretKeys []string, secretsVia string) {
 
 	var cmd *exec.Cmd
 	var err error
@@ -317,6 +547,7 @@ func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInt
 	var processMutex sync.Mutex
 	var beingTerminated = false
 	var currentETag string
+	var secretsDir string
 
 	if err != nil {
 		util.HandleError(err, "Failed to fetch secrets")
@@ -378,7 +609,24 @@ func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInt
 		// start the process
 		log.Info().Msgf(color.GreenString("Injecting %v Infisical secrets into your application process", environmentVariables.SecretsCount))
 
-		cmd, err = util.RunCommand(commandFlag, args, environmentVariables.Variables, false)
+		extraFiles, err := passSecretsOutsideEnv(secretsVia, &secretsDir, &environmentVariables)
+		if err != nil {
+			watcherWaitGroup.Done()
+			runCommandCleanups()
+			util.HandleError(err, "Unable to pass secrets outside the environment")
+		}
+
+		if len(fileSecretKeys) > 0 {
+			environmentVariables.Variables, err = prepareSecretFiles(&secretsDir, environmentVariables.FileSecrets, environmentVariables.Variables)
+			if err != nil {
+				watcherWaitGroup.Done()
+				runCommandCleanups()
+				util.HandleError(err, "Unable to pass secrets as files")
+			}
+		}
+
+		stdout, stderr := commandOutputs(maskOutput, environmentVariables.SecretValues)
+		cmd, err = util.RunCommand(commandFlag, args, environmentVariables.Variables, false, stdout, stderr, extraFiles)
 		if err != nil {
 			defer watcherWaitGroup.Done()
 			util.HandleError(err)
@@ -401,6 +649,7 @@ func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInt
 					}
 				}
 
+				runCommandCleanups()
 				os.Exit(exitCode)
 			}
 		}()
@@ -417,13 +666,26 @@ func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInt
 		}
 	}()
 
+	// secret change events trigger a recheck right away, polling stays as the fallback
+	if secretChangeWatcher := newRunSecretChangeWatcher(requests, projectConfigDir, token, tokenRenewer); secretChangeWatcher != nil {
+		go func() {
+			for range secretChangeWatcher.RefreshChan(0) {
+				select {
+				case recheckSecretsChannel <- true:
+				default:
+					// a recheck is already pending
+				}
+			}
+		}()
+	}
+
 	for {
 		<-recheckSecretsChannel
 		func() {
 			watchMutex.Lock()
 			defer watchMutex.Unlock()
 
-			newEnvironmentVariables, err := fetchAndFormatSecretsForShell(request, projectConfigDir, secretOverriding, token)
+			newEnvironmentVariables, err := fetchAndFormatSecretsForShell(requests, projectConfigDir, secretOverriding, currentRunToken(token, tokenRenewer), fileSecretKeys)
 			if err != nil {
 				log.Error().Err(err).Msg("[HOT RELOAD] Failed to fetch secrets")
 				return
@@ -439,31 +701,124 @@ func executeCommandWithWatchMode(commandFlag string, args []string, watchModeInt
 	}
 }
 
-func fetchAndFormatSecretsForShell(request models.GetAllSecretsParameters, projectConfigDir string, secretOverriding bool, token *models.TokenDetails) (models.InjectableEnvironmentResult, error) {
-
+// newRunSecretChangeWatcher subscribes to changes of the secrets injected by run. It returns nil when
+// events can't be used, e.g. with service tokens or outside of a project.
+func newRunSecretChangeWatcher(requests []models.GetAllSecretsParameters, projectConfigDir string, token *models.TokenDetails, tokenRenewer *util.AccessTokenRenewer) *SecretChangeWatcher {
 	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
-		request.InfisicalToken = token.Token
-	} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
-		request.UniversalAuthAccessToken = token.Token
+		return nil
 	}
 
-	secrets, err := util.GetAllEnvironmentVariables(request, projectConfigDir)
+	var workspaceProjectId string
+	var workspaceFileRead bool
 
-	if err != nil {
-		return models.InjectableEnvironmentResult{}, err
+	getToken := func() string {
+		if token != nil {
+			return currentRunToken(token, tokenRenewer).Token
+		}
+
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+		if err != nil || !loggedInUserDetails.IsUserLoggedIn || loggedInUserDetails.LoginExpired {
+			return ""
+		}
+		return loggedInUserDetails.UserCredentials.JTWToken
 	}
 
-	if secretOverriding {
-		secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
-	} else {
-		secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+	secretChangeWatcher := NewSecretChangeWatcher(getToken)
+	for _, request := range requests {
+		projectId := request.WorkspaceId
+		if projectId == "" {
+			if !workspaceFileRead {
+				var workspaceFile models.WorkspaceConfigFile
+				var err error
+				if projectConfigDir != "" {
+					workspaceFile, err = util.GetWorkSpaceFromFilePath(projectConfigDir)
+				} else {
+					workspaceFile, err = util.GetWorkSpaceFromFile()
+				}
+				if err == nil {
+					workspaceProjectId = workspaceFile.WorkspaceId
+				}
+				workspaceFileRead = true
+			}
+			projectId = workspaceProjectId
+		}
+
+		if projectId == "" {
+			log.Debug().Msgf("[HOT RELOAD] not subscribing to secret changes of %s:%s because the project is unknown", request.Environment, request.SecretsPath)
+			continue
+		}
+		secretChangeWatcher.Watch(0, projectId, request.Environment, request.SecretsPath)
+	}
+	return secretChangeWatcher
+}
+
+// parseRunSources returns the base request followed by one request per --source, each a copy of the
+// base with the given projectId, env and path
+func parseRunSources(base models.GetAllSecretsParameters, sources []string) ([]models.GetAllSecretsParameters, error) {
+	requests := []models.GetAllSecretsParameters{base}
+
+	for _, source := range sources {
+		request := base
+		for _, field := range strings.Split(source, ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(field), "=")
+			if !found || value == "" {
+				return nil, fmt.Errorf("expected key=value pairs in '%s'", source)
+			}
+
+			switch key {
+			case "projectId":
+				request.WorkspaceId = value
+			case "env":
+				request.Environment = value
+			case "path":
+				request.SecretsPath = value
+			default:
+				return nil, fmt.Errorf("unknown key '%s' in '%s', expected projectId, env or path", key, source)
+			}
+		}
+		requests = append(requests, request)
 	}
 
-	secretsByKey := getSecretsByKeys(secrets)
+	return requests, nil
+}
+
+// fetchAndFormatSecretsForShell merges the secrets of all requests, secrets of later requests
+// replacing those of earlier ones with the same name
+func fetchAndFormatSecretsForShell(requests []models.GetAllSecretsParameters, projectConfigDir string, secretOverriding bool, token *models.TokenDetails, fileSecretKeys []string) (models.InjectableEnvironmentResult, error) {
+	var allSecrets []models.SingleEnvironmentVariable
+	secretsByKey := map[string]models.SingleEnvironmentVariable{}
+
+	for _, request := range requests {
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			request.InfisicalToken = token.Token
+		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			request.UniversalAuthAccessToken = token.Token
+		}
+
+		secrets, err := util.GetAllEnvironmentVariables(request, projectConfigDir)
+
+		if err != nil {
+			if len(requests) > 1 {
+				return models.InjectableEnvironmentResult{}, fmt.Errorf("unable to fetch secrets of %s:%s: %w", request.Environment, request.SecretsPath, err)
+			}
+			return models.InjectableEnvironmentResult{}, err
+		}
+
+		if secretOverriding {
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_PERSONAL)
+		} else {
+			secrets = util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED)
+		}
+
+		allSecrets = append(allSecrets, secrets...)
+		for key, secret := range getSecretsByKeys(secrets) {
+			secretsByKey[key] = secret
+		}
+	}
 	environmentVariables := make(map[string]string)
 
 	// add all existing environment vars
-	for _, s := range os.Environ() {
+	for _, s := range util.ChildProcessEnvironment(os.Environ()) {
 		kv := strings.SplitN(s, "=", 2)
 		key := kv[0]
 		value := kv[1]
@@ -474,8 +829,17 @@ func fetchAndFormatSecretsForShell(request models.GetAllSecretsParameters, proje
 	filterReservedEnvVars(secretsByKey)
 
 	// now add infisical secrets
+	secretValues := make([]string, 0, len(secretsByKey))
+	fileSecrets := map[string]string{}
+	envSecrets := map[string]string{}
 	for k, v := range secretsByKey {
+		secretValues = append(secretValues, v.Value)
+		if isFileSecret(fileSecretKeys, k) {
+			fileSecrets[k] = v.Value
+			continue
+		}
 		environmentVariables[k] = v.Value
+		envSecrets[k] = v.Value
 	}
 
 	env := make([]string, 0, len(environmentVariables))
@@ -485,7 +849,10 @@ func fetchAndFormatSecretsForShell(request models.GetAllSecretsParameters, proje
 
 	return models.InjectableEnvironmentResult{
 		Variables:    env,
-		ETag:         util.GenerateETagFromSecrets(secrets),
+		ETag:         util.GenerateETagFromSecrets(allSecrets),
 		SecretsCount: len(secretsByKey),
+		SecretValues: secretValues,
+		FileSecrets:  fileSecrets,
+		Secrets:      envSecrets,
 	}, nil
 }
@@ -0,0 +1,231 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/rs/zerolog/log"
+)
+
+// memory backed directory available on most Linux systems, so secret files never reach a disk
+const sharedMemoryDir = "/dev/shm"
+
+var (
+	commandCleanupsMutex sync.Mutex
+	commandCleanups      []func()
+)
+
+// onCommandExit registers cleanup that must run before run exits, since it exits with the command's
+// exit code through os.Exit
+func onCommandExit(cleanup func()) {
+	commandCleanupsMutex.Lock()
+	defer commandCleanupsMutex.Unlock()
+	commandCleanups = append(commandCleanups, cleanup)
+}
+
+func runCommandCleanups() {
+	commandCleanupsMutex.Lock()
+	defer commandCleanupsMutex.Unlock()
+
+	for _, cleanup := range commandCleanups {
+		cleanup()
+	}
+	commandCleanups = nil
+}
+
+// parseFileSecretKeys reads the --secrets-as-files flag, where * selects every secret
+func parseFileSecretKeys(flagValue string) []string {
+	keys := []string{}
+	for _, key := range strings.Split(flagValue, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func isFileSecret(fileSecretKeys []string, key string) bool {
+	for _, fileSecretKey := range fileSecretKeys {
+		if fileSecretKey == "*" || fileSecretKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// createSecretsDir creates a directory only the current user can access, in memory when possible
+func createSecretsDir() (string, error) {
+	parentDir := ""
+	if runtime.GOOS == "linux" {
+		if info, err := os.Stat(sharedMemoryDir); err == nil && info.IsDir() {
+			parentDir = sharedMemoryDir
+		}
+	}
+
+	if parentDir == "" {
+		util.PrintWarning("no memory backed file system found, secret files are written to the temporary directory and removed when the command exits")
+	}
+
+	dir, err := os.MkdirTemp(parentDir, "infisical-run-")
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+
+	onCommandExit(func() {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Error().Msgf("unable to remove secrets directory %s because %v", dir, err)
+		}
+	})
+
+	return dir, nil
+}
+
+// writeSecretFiles makes dir contain exactly one read only file per secret, named after its key
+func writeSecretFiles(dir string, secrets map[string]string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if _, ok := secrets[entry.Name()]; !ok && entry.Name() != secretsEnvFileName {
+			os.Remove(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	for key, value := range secrets {
+		if key != filepath.Base(key) || key == "." || key == ".." || key == secretsEnvFileName {
+			return fmt.Errorf("secret %s can't be used as a file name", key)
+		}
+
+		if err := writeFileAtomically(filepath.Join(dir, key), []byte(value), FileOwnership{Permissions: "0400"}); err != nil {
+			return fmt.Errorf("unable to write secret %s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// prepareSecretFiles writes the file secrets to the secrets directory, creating it on first use, and
+// points the command at it
+func prepareSecretFiles(secretsDir *string, secrets map[string]string, env []string) ([]string, error) {
+	if *secretsDir == "" {
+		dir, err := createSecretsDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create secrets directory: %w", err)
+		}
+		*secretsDir = dir
+	}
+
+	if err := writeSecretFiles(*secretsDir, secrets); err != nil {
+		return nil, err
+	}
+
+	log.Debug().Msgf("wrote %d secrets to %s", len(secrets), *secretsDir)
+	return append(env, util.INFISICAL_SECRETS_DIR_NAME+"="+*secretsDir), nil
+}
+
+const (
+	secretsViaEnv  = "env"
+	secretsViaFile = "file"
+	secretsViaFd   = "fd"
+
+	// the first of cmd.ExtraFiles, after stdin, stdout and stderr
+	secretsPipeFd = 3
+	// written to the secrets directory next to the secrets passed as files
+	secretsEnvFileName = ".env"
+)
+
+func validateSecretsVia(secretsVia string) error {
+	switch secretsVia {
+	case secretsViaEnv, secretsViaFile:
+		return nil
+	case secretsViaFd:
+		if runtime.GOOS == "windows" {
+			return errors.New("--secrets-via=fd isn't supported on Windows, use --secrets-via=file instead")
+		}
+		return nil
+	default:
+		return fmt.Errorf("--secrets-via must be one of %s, %s or %s", secretsViaEnv, secretsViaFile, secretsViaFd)
+	}
+}
+
+// formatSecretsEnvFile writes the secrets in the dotenv format, with values double quoted and \, "
+// and line breaks escaped so any value survives
+func formatSecretsEnvFile(secrets map[string]string) []byte {
+	keys := make([]string, 0, len(secrets))
+	for key := range secrets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escaper := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`)
+
+	var content bytes.Buffer
+	for _, key := range keys {
+		fmt.Fprintf(&content, "%s=\"%s\"\n", key, escaper.Replace(secrets[key]))
+	}
+	return content.Bytes()
+}
+
+// passSecretsOutsideEnv takes the secrets out of the environment of the command, which the OS limits
+// in size, and hands them over as an env file or through a pipe instead. It returns the files the
+// command must inherit.
+func passSecretsOutsideEnv(secretsVia string, secretsDir *string, environment *models.InjectableEnvironmentResult) ([]*os.File, error) {
+	if secretsVia == secretsViaEnv || secretsVia == "" {
+		return nil, nil
+	}
+
+	// the secrets were merged into a copy of the environment of the CLI, start over from it
+	env := util.ChildProcessEnvironment(os.Environ())
+	content := formatSecretsEnvFile(environment.Secrets)
+
+	if secretsVia == secretsViaFd {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create pipe for secrets: %w", err)
+		}
+
+		// a pipe only buffers a few kilobytes, so the secrets are written while the command reads them
+		go func() {
+			defer writer.Close()
+			if _, err := writer.Write(content); err != nil {
+				log.Debug().Msgf("command did not read all secrets from fd %d: %v", secretsPipeFd, err)
+			}
+		}()
+
+		environment.Variables = append(env, fmt.Sprintf("%s=%d", util.INFISICAL_ENV_FD_NAME, secretsPipeFd))
+		return []*os.File{reader}, nil
+	}
+
+	if *secretsDir == "" {
+		dir, err := createSecretsDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to create secrets directory: %w", err)
+		}
+		*secretsDir = dir
+	}
+
+	envFile := filepath.Join(*secretsDir, secretsEnvFileName)
+	if err := writeFileAtomically(envFile, content, FileOwnership{Permissions: "0600"}); err != nil {
+		return nil, fmt.Errorf("unable to write env file: %w", err)
+	}
+
+	environment.Variables = append(env, util.INFISICAL_ENV_FILE_NAME+"="+envFile)
+	return nil, nil
+}
@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSecretKeys(t *testing.T) {
+	tests := []struct {
+		name      string
+		flagValue string
+		wantKeys  []string
+		fileKeys  []string
+		envKeys   []string
+	}{
+		{name: "none", flagValue: "", wantKeys: []string{}, envKeys: []string{"TLS_KEY"}},
+		{name: "some", flagValue: "TLS_KEY, TLS_CERT,", wantKeys: []string{"TLS_KEY", "TLS_CERT"}, fileKeys: []string{"TLS_KEY", "TLS_CERT"}, envKeys: []string{"DB_URL"}},
+		{name: "all", flagValue: "*", wantKeys: []string{"*"}, fileKeys: []string{"TLS_KEY", "DB_URL"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			keys := parseFileSecretKeys(test.flagValue)
+			assert.Equal(t, test.wantKeys, keys)
+			for _, key := range test.fileKeys {
+				assert.True(t, isFileSecret(keys, key), key)
+			}
+			for _, key := range test.envKeys {
+				assert.False(t, isFileSecret(keys, key), key)
+			}
+		})
+	}
+}
+
+func TestWriteSecretFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  []string
+		secrets   map[string]string
+		wantErr   string
+		wantFiles []string
+	}{
+		{
+			name:      "secrets",
+			secrets:   map[string]string{"TLS_KEY": "key", "TLS_CERT": "cert"},
+			wantFiles: []string{"TLS_CERT", "TLS_KEY"},
+		},
+		{
+			name:      "removed secrets are deleted, the env file is kept",
+			existing:  []string{"OLD_SECRET", secretsEnvFileName},
+			secrets:   map[string]string{"TLS_KEY": "key"},
+			wantFiles: []string{secretsEnvFileName, "TLS_KEY"},
+		},
+		{name: "path in the key", secrets: map[string]string{"../TLS_KEY": "key"}, wantErr: "can't be used as a file name"},
+		{name: "dot dot", secrets: map[string]string{"..": "key"}, wantErr: "can't be used as a file name"},
+		{name: "key named like the env file", secrets: map[string]string{secretsEnvFileName: "key"}, wantErr: "can't be used as a file name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range test.existing {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("old"), 0600))
+			}
+
+			err := writeSecretFiles(dir, test.secrets)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+
+			entries, err := os.ReadDir(dir)
+			assert.NoError(t, err)
+			var files []string
+			for _, entry := range entries {
+				files = append(files, entry.Name())
+			}
+			sort.Strings(files)
+			assert.Equal(t, test.wantFiles, files)
+
+			for key, value := range test.secrets {
+				content, err := os.ReadFile(filepath.Join(dir, key))
+				assert.NoError(t, err)
+				assert.Equal(t, value, string(content))
+
+				if runtime.GOOS != "windows" {
+					info, err := os.Stat(filepath.Join(dir, key))
+					assert.NoError(t, err)
+					assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareSecretFiles(t *testing.T) {
+	defer runCommandCleanups()
+
+	secretsDir := ""
+	env, err := prepareSecretFiles(&secretsDir, map[string]string{"TLS_KEY": "key"}, []string{"PATH=/usr/bin"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PATH=/usr/bin", util.INFISICAL_SECRETS_DIR_NAME + "=" + secretsDir}, env)
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(secretsDir)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+
+	// later reloads reuse the directory
+	previousDir := secretsDir
+	_, err = prepareSecretFiles(&secretsDir, map[string]string{"TLS_KEY": "rotated"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, previousDir, secretsDir)
+	content, err := os.ReadFile(filepath.Join(secretsDir, "TLS_KEY"))
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", string(content))
+
+	runCommandCleanups()
+	_, err = os.Stat(secretsDir)
+	assert.True(t, os.IsNotExist(err), "the directory is removed when the command exits")
+}
+
+func TestValidateSecretsVia(t *testing.T) {
+	fdErr := ""
+	if runtime.GOOS == "windows" {
+		fdErr = "isn't supported on Windows"
+	}
+
+	tests := []struct {
+		secretsVia string
+		wantErr    string
+	}{
+		{secretsVia: secretsViaEnv},
+		{secretsVia: secretsViaFile},
+		{secretsVia: secretsViaFd, wantErr: fdErr},
+		{secretsVia: "stdin", wantErr: "--secrets-via must be one of env, file or fd"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.secretsVia, func(t *testing.T) {
+			err := validateSecretsVia(test.secretsVia)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFormatSecretsEnvFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets map[string]string
+		want    string
+	}{
+		{name: "no secrets", secrets: map[string]string{}, want: ""},
+		{name: "sorted by key", secrets: map[string]string{"B": "2", "A": "1"}, want: "A=\"1\"\nB=\"2\"\n"},
+		{name: "quotes and backslashes", secrets: map[string]string{"A": `say "hi" \o/`}, want: `A="say \"hi\" \\o/"` + "\n"},
+		{name: "line breaks", secrets: map[string]string{"KEY": "line 1\r\nline 2"}, want: `KEY="line 1\r\nline 2"` + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, string(formatSecretsEnvFile(test.secrets)))
+		})
+	}
+}
+
+func TestPassSecretsOutsideEnv(t *testing.T) {
+	defer runCommandCleanups()
+	t.Setenv("CLI_VARIABLE", "1")
+
+	newEnvironment := func() *models.InjectableEnvironmentResult {
+		return &models.InjectableEnvironmentResult{
+			Variables: append(os.Environ(), "DB_PASSWORD=hunter2"),
+			Secrets:   map[string]string{"DB_PASSWORD": "hunter2"},
+		}
+	}
+
+	t.Run("env", func(t *testing.T) {
+		environment := newEnvironment()
+		files, err := passSecretsOutsideEnv(secretsViaEnv, new(string), environment)
+		assert.NoError(t, err)
+		assert.Nil(t, files)
+		assert.Contains(t, environment.Variables, "DB_PASSWORD=hunter2")
+	})
+
+	t.Run("file", func(t *testing.T) {
+		environment := newEnvironment()
+		secretsDir := ""
+		files, err := passSecretsOutsideEnv(secretsViaFile, &secretsDir, environment)
+		assert.NoError(t, err)
+		assert.Nil(t, files)
+
+		envFile := filepath.Join(secretsDir, secretsEnvFileName)
+		assert.Contains(t, environment.Variables, util.INFISICAL_ENV_FILE_NAME+"="+envFile)
+		assert.Contains(t, environment.Variables, "CLI_VARIABLE=1")
+		assert.NotContains(t, environment.Variables, "DB_PASSWORD=hunter2")
+
+		content, err := os.ReadFile(envFile)
+		assert.NoError(t, err)
+		assert.Equal(t, "DB_PASSWORD=\"hunter2\"\n", string(content))
+	})
+
+	t.Run("fd", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("pipes can't be inherited on Windows")
+		}
+
+		environment := newEnvironment()
+		files, err := passSecretsOutsideEnv(secretsViaFd, new(string), environment)
+		assert.NoError(t, err)
+		assert.Contains(t, environment.Variables, util.INFISICAL_ENV_FD_NAME+"=3")
+		assert.NotContains(t, environment.Variables, "DB_PASSWORD=hunter2")
+
+		if assert.Len(t, files, 1) {
+			defer files[0].Close()
+			content, err := io.ReadAll(files[0])
+			assert.NoError(t, err)
+			assert.Equal(t, "DB_PASSWORD=\"hunter2\"\n", string(content))
+		}
+	})
+}
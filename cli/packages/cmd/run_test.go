@@ -0,0 +1,274 @@
+package cmd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewRunSecretChangeWatcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	previousURL := config.INFISICAL_URL
+	defer func() { config.INFISICAL_URL = previousURL }()
+	config.INFISICAL_URL = server.URL
+
+	projectConfigDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(projectConfigDir, ".infisical.json"), []byte(`{"workspaceId":"project-from-file"}`), 0600))
+
+	identityToken := &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+
+	tests := []struct {
+		name             string
+		requests         []models.GetAllSecretsParameters
+		projectConfigDir string
+		token            *models.TokenDetails
+		wantNil          bool
+		wantScopes       map[string]map[secretEventScope]map[int]bool
+	}{
+		{
+			name:     "service token",
+			requests: []models.GetAllSecretsParameters{{WorkspaceId: "project", Environment: "dev", SecretsPath: "/"}},
+			token:    &models.TokenDetails{Type: util.SERVICE_TOKEN_IDENTIFIER, Token: "st.token"},
+			wantNil:  true,
+		},
+		{
+			name:             "unknown project",
+			requests:         []models.GetAllSecretsParameters{{Environment: "dev", SecretsPath: "/"}},
+			projectConfigDir: t.TempDir(),
+			token:            identityToken,
+			wantScopes:       map[string]map[secretEventScope]map[int]bool{},
+		},
+		{
+			name:     "project given",
+			requests: []models.GetAllSecretsParameters{{WorkspaceId: "project", Environment: "dev", SecretsPath: "/app"}},
+			token:    identityToken,
+			wantScopes: map[string]map[secretEventScope]map[int]bool{
+				"project": {{environment: "dev", secretPath: "/app"}: {0: true}},
+			},
+		},
+		{
+			name: "several sources",
+			requests: []models.GetAllSecretsParameters{
+				{Environment: "prod", SecretsPath: "/"},
+				{WorkspaceId: "shared", Environment: "prod", SecretsPath: "/common"},
+				{Environment: "prod", SecretsPath: "/app"},
+			},
+			projectConfigDir: projectConfigDir,
+			token:            identityToken,
+			wantScopes: map[string]map[secretEventScope]map[int]bool{
+				"project-from-file": {
+					{environment: "prod", secretPath: "/"}:    {0: true},
+					{environment: "prod", secretPath: "/app"}: {0: true},
+				},
+				"shared": {{environment: "prod", secretPath: "/common"}: {0: true}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			watcher := newRunSecretChangeWatcher(test.requests, test.projectConfigDir, test.token, nil)
+			if test.wantNil {
+				assert.Nil(t, watcher)
+				return
+			}
+
+			if !assert.NotNil(t, watcher) {
+				return
+			}
+			watcher.mutex.Lock()
+			defer watcher.mutex.Unlock()
+
+			scopes := map[string]map[secretEventScope]map[int]bool{}
+			for projectId, subscription := range watcher.subscriptions {
+				subscription.cancel()
+				scopes[projectId] = subscription.scopes
+			}
+			assert.Equal(t, test.wantScopes, scopes)
+		})
+	}
+}
+
+func TestParseRunSources(t *testing.T) {
+	base := models.GetAllSecretsParameters{WorkspaceId: "project", Environment: "dev", SecretsPath: "/"}
+
+	tests := []struct {
+		name         string
+		sources      []string
+		wantErr      string
+		wantRequests []models.GetAllSecretsParameters
+	}{
+		{name: "no sources", wantRequests: []models.GetAllSecretsParameters{base}},
+		{
+			name:    "sources",
+			sources: []string{"path=/shared", "projectId=other, env=prod"},
+			wantRequests: []models.GetAllSecretsParameters{
+				base,
+				{WorkspaceId: "project", Environment: "dev", SecretsPath: "/shared"},
+				{WorkspaceId: "other", Environment: "prod", SecretsPath: "/"},
+			},
+		},
+		{name: "not key=value", sources: []string{"/shared"}, wantErr: "expected key=value pairs"},
+		{name: "empty value", sources: []string{"env="}, wantErr: "expected key=value pairs"},
+		{name: "unknown key", sources: []string{"folder=/shared"}, wantErr: "unknown key 'folder'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests, err := parseRunSources(base, test.sources)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantRequests, requests)
+		})
+	}
+}
+
+func TestFetchAndFormatSecretsForShellMergesSources(t *testing.T) {
+	newSecretsTestServer(t, map[string][]testSecret{
+		"dev:/":        {{Key: "DB_URL", Value: "postgres://dev"}, {Key: "LOG_LEVEL", Value: "info"}},
+		"dev:/shared":  {{Key: "LOG_LEVEL", Value: "debug"}, {Key: "SENTRY_DSN", Value: "https://sentry"}},
+		"prod:/shared": {{Key: "SENTRY_DSN", Value: "https://sentry-prod"}},
+	})
+
+	token := &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+	base := models.GetAllSecretsParameters{WorkspaceId: "project", Environment: "dev", SecretsPath: "/"}
+
+	tests := []struct {
+		name        string
+		sources     []string
+		fileSecrets []string
+		wantSecrets map[string]string
+		wantFiles   map[string]string
+	}{
+		{
+			name:        "one source",
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev", "LOG_LEVEL": "info"},
+			wantFiles:   map[string]string{},
+		},
+		{
+			name:        "later sources win",
+			sources:     []string{"path=/shared", "env=prod,path=/shared"},
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev", "LOG_LEVEL": "debug", "SENTRY_DSN": "https://sentry-prod"},
+			wantFiles:   map[string]string{},
+		},
+		{
+			name:        "secrets as files",
+			sources:     []string{"path=/shared"},
+			fileSecrets: []string{"SENTRY_DSN"},
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev", "LOG_LEVEL": "debug"},
+			wantFiles:   map[string]string{"SENTRY_DSN": "https://sentry"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			requests, err := parseRunSources(base, test.sources)
+			assert.NoError(t, err)
+
+			environment, err := fetchAndFormatSecretsForShell(requests, "", true, token, test.fileSecrets)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantSecrets, environment.Secrets)
+			assert.Equal(t, test.wantFiles, environment.FileSecrets)
+			assert.Equal(t, len(test.wantSecrets)+len(test.wantFiles), environment.SecretsCount)
+		})
+	}
+}
+
+func TestFetchAndFormatSecretsForShellFiltersByTags(t *testing.T) {
+	server := newSecretsTestServer(t, map[string][]testSecret{
+		"dev:/": {
+			{Key: "DB_URL", Value: "postgres://dev", Tags: []string{"backend", "database"}},
+			{Key: "API_URL", Value: "https://api", Tags: []string{"backend"}},
+			{Key: "CDN_URL", Value: "https://cdn", Tags: []string{"frontend"}},
+			{Key: "LOG_LEVEL", Value: "info"},
+		},
+	})
+
+	token := &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+
+	tests := []struct {
+		name        string
+		tagSlugs    string
+		tagsMatch   string
+		wantSecrets map[string]string
+	}{
+		{
+			name:        "no tags",
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev", "API_URL": "https://api", "CDN_URL": "https://cdn", "LOG_LEVEL": "info"},
+		},
+		{
+			name:        "any tag",
+			tagSlugs:    "database,frontend",
+			tagsMatch:   util.TAGS_MATCH_ANY,
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev", "CDN_URL": "https://cdn"},
+		},
+		{
+			name:        "all tags",
+			tagSlugs:    "backend, database",
+			tagsMatch:   util.TAGS_MATCH_ALL,
+			wantSecrets: map[string]string{"DB_URL": "postgres://dev"},
+		},
+		{
+			name:        "unknown tag",
+			tagSlugs:    "mobile",
+			wantSecrets: map[string]string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := models.GetAllSecretsParameters{WorkspaceId: "project", Environment: "dev", SecretsPath: "/", TagSlugs: test.tagSlugs, TagsMatch: test.tagsMatch}
+
+			environment, err := fetchAndFormatSecretsForShell([]models.GetAllSecretsParameters{request}, "", true, token, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantSecrets, environment.Secrets)
+
+			requests := server.receivedRequests()
+			assert.Equal(t, test.tagSlugs, requests[len(requests)-1]["tagSlugs"])
+		})
+	}
+}
+
+func TestGetRunAuthStrategy(t *testing.T) {
+	tests := []struct {
+		name       string
+		authMethod string
+		want       util.AuthStrategyType
+		wantErr    bool
+	}{
+		{name: "aws-iam", authMethod: "aws-iam", want: util.AuthStrategy.AWS_IAM_AUTH},
+		{name: "azure", authMethod: "azure", want: util.AuthStrategy.AZURE_AUTH},
+		{name: "gcp", authMethod: "gcp", want: util.AuthStrategy.GCP_ID_TOKEN_AUTH},
+		{name: "gcp-id-token", authMethod: "gcp-id-token", want: util.AuthStrategy.GCP_ID_TOKEN_AUTH},
+		{name: "needs a key file", authMethod: "gcp-iam", wantErr: true},
+		{name: "needs a client secret", authMethod: "universal-auth", wantErr: true},
+		{name: "user", authMethod: "user", wantErr: true},
+		{name: "unknown", authMethod: "password", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			strategy, err := getRunAuthStrategy(test.authMethod)
+			if test.wantErr {
+				assert.ErrorContains(t, err, "infisical run supports aws-iam")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, strategy)
+		})
+	}
+}
@@ -67,14 +67,19 @@ func init() {
 	scanCmd.Flags().Bool("no-git", false, "treat git repo as a regular directory and scan those files, --log-opts has no effect on the scan when --no-git is set")
 	scanCmd.Flags().Bool("pipe", false, "scan input from stdin, ex: `cat some_file | infisical scan --pipe`")
 	scanCmd.Flags().Bool("follow-symlinks", false, "scan files that are symlinks to other files")
+	scanCmd.Flags().String("image", "", "container image to scan every layer of, ex: `infisical scan --image nginx:latest`. It's pulled with docker or podman if needed")
+	scanCmd.Flags().String("archive", "", "tar, gzipped tar or zip archive to scan the files of, including archives nested in it such as the layers of a saved image")
 
 	// global scan flags
 	scanCmd.PersistentFlags().StringP("config", "c", "", configDescription)
+	scanCmd.PersistentFlags().String("rules-path", "", fmt.Sprintf("path to a TOML file of custom rules to add to the rules of the scan config, defaults to the file in %s", config.DefaultScanRulesEnvName))
 	scanCmd.PersistentFlags().Int("exit-code", 1, "exit code when leaks have been encountered")
 	scanCmd.PersistentFlags().StringP("source", "s", ".", "path to source")
 	scanCmd.PersistentFlags().StringP("report-path", "r", "", "report file")
-	scanCmd.PersistentFlags().StringP("report-format", "f", "json", "output format (json, csv, sarif)")
+	scanCmd.PersistentFlags().StringP("report-format", "f", "json", "output format (json, csv, sarif). sarif reports can be uploaded to GitHub code scanning and other SARIF consumers")
+	scanCmd.PersistentFlags().StringP("output", "o", OutputTable, "print the findings on stdout as json or yaml, table only logs a summary")
 	scanCmd.PersistentFlags().StringP("baseline-path", "b", "", "path to baseline with issues that can be ignored")
+	scanCmd.PersistentFlags().Bool("update-baseline", false, "record the findings of this scan in the file at --baseline-path, so later scans ignore them")
 	scanCmd.PersistentFlags().BoolP("verbose", "v", false, "show verbose output from scan (which file, where in the file, what secret)")
 	scanCmd.PersistentFlags().BoolP("no-color", "", false, "turn off color for verbose output")
 	scanCmd.PersistentFlags().Int("max-target-megabytes", 0, "files larger than this will be skipped")
@@ -216,6 +221,7 @@ var scanCmd = &cobra.Command{
 			log.Fatal().Err(err).Msg("Failed to load config")
 		}
 		cfg.Path, _ = cmd.Flags().GetString("config")
+		addCustomScanRules(cmd, &cfg)
 
 		// start timer
 		start := time.Now()
@@ -258,13 +264,7 @@ var scanCmd = &cobra.Command{
 		}
 
 		// ignore findings from the baseline (an existing report in json format generated earlier)
-		baselinePath, _ := cmd.Flags().GetString("baseline-path")
-		if baselinePath != "" {
-			err = detector.AddBaseline(baselinePath, source)
-			if err != nil {
-				log.Error().Msgf("Could not load baseline. The path must point to report generated by `infisical scan` using the default format: %s", err)
-			}
-		}
+		baselinePath, updateBaseline := setScanBaseline(cmd, detector, source)
 
 		// set follow symlinks flag
 		if detector.FollowSymlinks, err = cmd.Flags().GetBool("follow-symlinks"); err != nil {
@@ -276,6 +276,7 @@ var scanCmd = &cobra.Command{
 		if err != nil {
 			log.Fatal().Err(err).Msg("could not get exit code")
 		}
+		outputFormat := getOutputFormat(cmd)
 
 		// determine what type of scan:
 		// - git: scan the history of the repo
@@ -289,10 +290,29 @@ var scanCmd = &cobra.Command{
 			log.Fatal().Err(err)
 		}
 
+		image, err := cmd.Flags().GetString("image")
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+		archive, err := cmd.Flags().GetString("archive")
+		if err != nil {
+			log.Fatal().Err(err).Msg("")
+		}
+
 		log.Info().Msgf("scanning for exposed secrets...")
 
 		// start the detector scan
-		if noGit {
+		if image != "" {
+			findings, err = detector.DetectImage(image)
+			if err != nil {
+				log.Error().Err(err).Msg("")
+			}
+		} else if archive != "" {
+			findings, err = detector.DetectArchive(archive, archive)
+			if err != nil {
+				log.Error().Err(err).Msg("")
+			}
+		} else if noGit {
 			findings, err = detector.DetectFiles(source)
 			if err != nil {
 				// don't exit on error, just log it
@@ -345,11 +365,17 @@ var scanCmd = &cobra.Command{
 				log.Fatal().Err(err).Msg("could not write")
 			}
 		}
+		printScanFindings(outputFormat, findings)
 
 		if err != nil {
 			os.Exit(1)
 		}
 
+		if updateBaseline {
+			recordScanBaseline(findings, baselinePath)
+			return
+		}
+
 		if len(findings) != 0 {
 			os.Exit(exitCode)
 		}
@@ -373,7 +399,9 @@ var scanGitChangesCmd = &cobra.Command{
 		}
 
 		cfg.Path, _ = cmd.Flags().GetString("config")
+		addCustomScanRules(cmd, &cfg)
 		exitCode, _ := cmd.Flags().GetInt("exit-code")
+		outputFormat := getOutputFormat(cmd)
 		staged, _ := cmd.Flags().GetBool("staged")
 		start := time.Now()
 
@@ -414,6 +442,8 @@ var scanGitChangesCmd = &cobra.Command{
 			}
 		}
 
+		baselinePath, updateBaseline := setScanBaseline(cmd, detector, source)
+
 		// get log options for git scan
 		logOpts, err := cmd.Flags().GetString("log-opts")
 		if err != nil {
@@ -451,12 +481,76 @@ var scanGitChangesCmd = &cobra.Command{
 				log.Fatal().Err(err).Msg("")
 			}
 		}
+		printScanFindings(outputFormat, findings)
+		if updateBaseline {
+			recordScanBaseline(findings, baselinePath)
+			return
+		}
 		if len(findings) != 0 {
 			os.Exit(exitCode)
 		}
 	},
 }
 
+// addCustomScanRules adds the rules of the rules file to the scan config, on top of its own rules
+func addCustomScanRules(cmd *cobra.Command, cfg *config.Config) {
+	rulesPath, _ := cmd.Flags().GetString("rules-path")
+	if rulesPath == "" {
+		rulesPath = os.Getenv(config.DefaultScanRulesEnvName)
+	}
+	if rulesPath == "" {
+		return
+	}
+
+	customRules, err := config.LoadCustomRules(rulesPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load custom rules")
+	}
+
+	cfg.AddCustomRules(customRules)
+	log.Debug().Msgf("added %d custom rules from %s", len(customRules.Rules), rulesPath)
+}
+
+// setScanBaseline makes the detector ignore the findings recorded in --baseline-path. With --update-baseline
+// the baseline isn't loaded, since the scan records a new one.
+func setScanBaseline(cmd *cobra.Command, detector *detect.Detector, source string) (string, bool) {
+	baselinePath, _ := cmd.Flags().GetString("baseline-path")
+	updateBaseline, _ := cmd.Flags().GetBool("update-baseline")
+
+	if updateBaseline {
+		if baselinePath == "" {
+			log.Fatal().Msg("--update-baseline needs --baseline-path to know where to record the findings")
+		}
+		if err := detector.SetBaselinePath(baselinePath, source); err != nil {
+			log.Fatal().Err(err).Msg("could not set the baseline path")
+		}
+		return baselinePath, true
+	}
+
+	if baselinePath != "" {
+		if err := detector.AddBaseline(baselinePath, source); err != nil {
+			log.Error().Msgf("Could not load baseline. The path must point to report generated by `infisical scan` using the default format: %s", err)
+		}
+	}
+	return baselinePath, false
+}
+
+// printScanFindings prints the findings on stdout with --output json or yaml, in the schema of json
+// reports. The table format keeps to the summary that's logged.
+func printScanFindings(outputFormat string, findings []report.Finding) {
+	if outputFormat == OutputTable {
+		return
+	}
+	printOutput(outputFormat, findings, nil)
+}
+
+func recordScanBaseline(findings []report.Finding, baselinePath string) {
+	if err := detect.WriteBaseline(findings, baselinePath); err != nil {
+		log.Fatal().Err(err).Msg("could not record the baseline")
+	}
+	log.Info().Msgf("recorded %d findings in the baseline %s, scans with --baseline-path %s will ignore them", len(findings), baselinePath, baselinePath)
+}
+
 func fileExists(fileName string) bool {
 	// check for a .infisicalignore file
 	info, err := os.Stat(fileName)
@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	managedHookStart = "# MANAGED BY INFISICAL CLI (Do not modify): START"
+	managedHookEnd   = "# MANAGED BY INFISICAL CLI (Do not modify): END"
+
+	preCommitFrameworkConfigFileName = ".pre-commit-config.yaml"
+)
+
+// scanGitHooks are the scripts installed into each git hook. The pre-commit hook only scans the staged
+// changes, and the pre-push hook only the commits the remote doesn't have yet, so both stay fast.
+var scanGitHooks = map[string]string{
+	"pre-commit": `infisical scan git-changes --staged --redact --verbose || exit $?`,
+	"pre-push": `zero=$(git hash-object --stdin </dev/null | tr '0-9a-f' '0')
+while read -r local_ref local_sha remote_ref remote_sha; do
+	# the branch is being deleted
+	if [ "$local_sha" = "$zero" ]; then
+		continue
+	fi
+	if [ "$remote_sha" = "$zero" ]; then
+		range="$local_sha --not --remotes"
+	else
+		range="$remote_sha..$local_sha"
+	fi
+	infisical scan --redact --verbose --log-opts="$range" || exit $?
+done`,
+}
+
+// preCommitFrameworkHooks are the hooks added to the config of the pre-commit framework, see https://pre-commit.com
+var preCommitFrameworkHooks = map[string]string{
+	"pre-commit": `      - id: infisical-scan
+        name: infisical scan
+        entry: infisical scan git-changes --staged --redact --verbose
+        language: system
+        pass_filenames: false
+        stages: [pre-commit]
+`,
+	"pre-push": `      - id: infisical-scan-pre-push
+        name: infisical scan (pre-push)
+        entry: sh -c 'infisical scan --redact --verbose --log-opts="$PRE_COMMIT_FROM_REF..$PRE_COMMIT_TO_REF"'
+        language: system
+        pass_filenames: false
+        always_run: true
+        stages: [pre-push]
+`,
+}
+
+var scanInstallHooksCmd = &cobra.Command{
+	Example: `infisical scan install-hooks
+infisical scan install-hooks --hooks pre-commit,pre-push
+infisical scan install-hooks --hooks pre-commit,pre-push --pre-commit-framework`,
+	Short:                 "Install git hooks that block commits and pushes which leak secrets",
+	Use:                   "install-hooks",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		hooks, err := cmd.Flags().GetStringSlice("hooks")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		preCommitFramework, err := cmd.Flags().GetBool("pre-commit-framework")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		for _, hook := range hooks {
+			if _, ok := scanGitHooks[hook]; !ok {
+				util.PrintErrorMessageAndExit(fmt.Sprintf("Unsupported hook %s, the supported hooks are pre-commit and pre-push", hook))
+			}
+		}
+
+		gitRoot, err := GetGitRoot()
+		if err != nil {
+			util.HandleError(err, "Unable to find the git repository, run this command inside one")
+		}
+
+		if preCommitFramework {
+			configPath := filepath.Join(gitRoot, preCommitFrameworkConfigFileName)
+			if err := addPreCommitFrameworkHooks(configPath, hooks); err != nil {
+				util.HandleError(err, "Unable to add the hooks to the pre-commit config")
+			}
+
+			fmt.Printf("Added the infisical scan hooks to %s\n", configPath)
+			fmt.Printf("Run [pre-commit install --hook-type %s] to enable them\n", strings.Join(hooks, " --hook-type "))
+		} else {
+			hooksDir, err := getGitHooksDir()
+			if err != nil {
+				util.HandleError(err, "Unable to find the git hooks directory")
+			}
+
+			for _, hook := range hooks {
+				if err := installScanGitHook(filepath.Join(hooksDir, hook), scanGitHooks[hook]); err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to install the %s hook", hook))
+				}
+				fmt.Printf("Installed the %s hook in %s\n", hook, hooksDir)
+			}
+		}
+
+		Telemetry.CaptureEvent("cli-command:scan install-hooks", posthog.NewProperties().Set("hooks", hooks).Set("preCommitFramework", preCommitFramework).Set("version", util.CLI_VERSION))
+	},
+}
+
+// getGitHooksDir returns the directory git runs hooks from, which respects core.hooksPath
+func getGitHooksDir() (string, error) {
+	output, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to get git hooks directory: %w", err)
+	}
+
+	return filepath.Abs(strings.TrimSpace(string(output)))
+}
+
+// installScanGitHook adds the script to the hook, keeping whatever else the hook already runs. Installing
+// again updates the script in place.
+func installScanGitHook(hookPath string, script string) error {
+	content, err := os.ReadFile(hookPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(hookPath, []byte(upsertManagedHookBlock(string(content), script)), 0755); err != nil {
+		return err
+	}
+
+	// WriteFile keeps the mode of existing files
+	return os.Chmod(hookPath, 0755)
+}
+
+func upsertManagedHookBlock(hook string, script string) string {
+	block := fmt.Sprintf("%s\n%s\n%s\n", managedHookStart, script, managedHookEnd)
+
+	if hook == "" {
+		return "#!/bin/sh\n\n" + block
+	}
+
+	start := strings.Index(hook, managedHookStart)
+	end := strings.Index(hook, managedHookEnd)
+	if start != -1 && end > start {
+		end += len(managedHookEnd)
+		if end < len(hook) && hook[end] == '\n' {
+			end++
+		}
+		return hook[:start] + block + hook[end:]
+	}
+
+	if !strings.HasSuffix(hook, "\n") {
+		hook += "\n"
+	}
+	return hook + "\n" + block
+}
+
+// addPreCommitFrameworkHooks adds the hooks as a local repo of the pre-commit config. The config is
+// edited as text to keep its comments, so it's only done when repos is its last top level key.
+func addPreCommitFrameworkHooks(configPath string, hooks []string) error {
+	var localRepo strings.Builder
+	localRepo.WriteString("  - repo: local\n    hooks:\n")
+	for _, hook := range hooks {
+		localRepo.WriteString(preCommitFrameworkHooks[hook])
+	}
+
+	content, err := os.ReadFile(configPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return os.WriteFile(configPath, []byte("repos:\n"+localRepo.String()), 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	config := string(content)
+	if strings.Contains(config, "id: infisical-scan") {
+		log.Debug().Msgf("%s already runs infisical scan", configPath)
+		return nil
+	}
+
+	if !isLastTopLevelYamlKey(config, "repos") {
+		return fmt.Errorf("unable to find where to add the hooks in %s, add them to its repos yourself:\n%s", configPath, localRepo.String())
+	}
+
+	if !strings.HasSuffix(config, "\n") {
+		config += "\n"
+	}
+	return os.WriteFile(configPath, []byte(config+localRepo.String()), 0644)
+}
+
+func isLastTopLevelYamlKey(yaml string, key string) bool {
+	found := false
+	for _, line := range strings.Split(yaml, "\n") {
+		if line == "" || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "-") || strings.HasPrefix(line, "#") {
+			continue
+		}
+		found = strings.HasPrefix(line, key+":")
+	}
+	return found
+}
+
+func init() {
+	scanInstallHooksCmd.Flags().StringSlice("hooks", []string{"pre-commit"}, "comma separated git hooks to install, pre-commit and/or pre-push")
+	scanInstallHooksCmd.Flags().Bool("pre-commit-framework", false, "add the hooks to the .pre-commit-config.yaml of the pre-commit framework instead of the git hooks directory")
+	scanCmd.AddCommand(scanInstallHooksCmd)
+}
@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpsertManagedHookBlock(t *testing.T) {
+	installed := upsertManagedHookBlock("", "infisical scan")
+	assert.Equal(t, "#!/bin/sh\n\n"+managedHookStart+"\ninfisical scan\n"+managedHookEnd+"\n", installed)
+
+	// installing again replaces the script rather than adding it twice
+	updated := upsertManagedHookBlock(installed+"npm test\n", "infisical scan --redact")
+	assert.Equal(t, "#!/bin/sh\n\n"+managedHookStart+"\ninfisical scan --redact\n"+managedHookEnd+"\nnpm test\n", updated)
+
+	// existing hooks keep what they already run
+	appended := upsertManagedHookBlock("#!/bin/sh\nnpm test", "infisical scan")
+	assert.Equal(t, "#!/bin/sh\nnpm test\n\n"+managedHookStart+"\ninfisical scan\n"+managedHookEnd+"\n", appended)
+}
+
+func TestIsLastTopLevelYamlKey(t *testing.T) {
+	assert.True(t, isLastTopLevelYamlKey("default_stages: [pre-commit]\nrepos:\n  - repo: local\n", "repos"))
+	assert.False(t, isLastTopLevelYamlKey("repos:\n  - repo: local\nfail_fast: true\n", "repos"))
+}
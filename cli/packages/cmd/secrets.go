@@ -4,16 +4,20 @@ Copyright (c) 2023 Infisical Inc.
 package cmd
 
 import (
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/Infisical/infisical-merge/packages/visualize"
-	"github.com/go-resty/resty/v2"
 	"github.com/posthog/posthog-go"
 	"github.com/spf13/cobra"
 )
@@ -33,9 +37,9 @@ var secretsCmd = &cobra.Command{
 			}
 		}
 
-		token, err := util.GetInfisicalToken(cmd)
+		token, err := getTokenOrLoginInCI(cmd)
 		if err != nil {
-			util.HandleError(err, "Unable to parse flag")
+			util.HandleError(err)
 		}
 
 		projectId, err := cmd.Flags().GetString("projectId")
@@ -48,11 +52,18 @@ var secretsCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		outputFormat := getOutputFormat(cmd)
+
 		shouldExpandSecrets, err := cmd.Flags().GetBool("expand")
 		if err != nil {
 			util.HandleError(err)
 		}
 
+		expandLocally, err := cmd.Flags().GetBool("expand-locally")
+		if err != nil {
+			util.HandleError(err)
+		}
+
 		includeImports, err := cmd.Flags().GetBool("include-imports")
 		if err != nil {
 			util.HandleError(err)
@@ -63,11 +74,36 @@ var secretsCmd = &cobra.Command{
 			util.HandleError(err)
 		}
 
+		prefixKeysWithPath, err := cmd.Flags().GetBool("prefix-keys-with-path")
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		pathSeparator, err := cmd.Flags().GetString("path-separator")
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		keyTemplate, err := cmd.Flags().GetString("key-template")
+		if err != nil {
+			util.HandleError(err)
+		}
+
+		keyPathSeparator := ""
+		if prefixKeysWithPath {
+			keyPathSeparator = pathSeparator
+		}
+
 		tagSlugs, err := cmd.Flags().GetString("tags")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
 		}
 
+		tagsMatch, err := cmd.Flags().GetString("tags-match")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
 		secretOverriding, err := cmd.Flags().GetBool("secret-overriding")
 		if err != nil {
 			util.HandleError(err, "Unable to parse flag")
@@ -79,13 +115,17 @@ var secretsCmd = &cobra.Command{
 		}
 
 		request := models.GetAllSecretsParameters{
-			Environment:            environmentName,
-			WorkspaceId:            projectId,
-			TagSlugs:               tagSlugs,
-			SecretsPath:            secretsPath,
-			IncludeImport:          includeImports,
-			Recursive:              recursive,
-			ExpandSecretReferences: shouldExpandSecrets,
+			Environment:                   environmentName,
+			WorkspaceId:                   projectId,
+			TagSlugs:                      tagSlugs,
+			TagsMatch:                     tagsMatch,
+			SecretsPath:                   secretsPath,
+			IncludeImport:                 includeImports,
+			Recursive:                     recursive,
+			KeyPathSeparator:              keyPathSeparator,
+			KeyTemplate:                   keyTemplate,
+			ExpandSecretReferences:        shouldExpandSecrets,
+			ExpandSecretReferencesLocally: shouldExpandSecrets && expandLocally,
 		}
 
 		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
@@ -113,7 +153,9 @@ var secretsCmd = &cobra.Command{
 				fmt.Println(fmt.Sprintf("%s=%s", secret.Key, secret.Value))
 			}
 		} else {
-			visualize.PrintAllSecretDetails(secrets)
+			printOutput(outputFormat, toSecretsOutput(secrets), func() {
+				visualize.PrintAllSecretDetails(secrets)
+			})
 		}
 
 		Telemetry.CaptureEvent("cli-command:secrets", posthog.NewProperties().Set("secretCount", len(secrets)).Set("version", util.CLI_VERSION))
@@ -139,7 +181,10 @@ var secretsGenerateExampleEnvCmd = &cobra.Command{
 }
 
 var secretsSetCmd = &cobra.Command{
-	Example:               `secrets set <secretName=secretValue> <secretName=secretValue>..."`,
+	Example: `
+	secrets set <secretName=secretValue> <secretName=secretValue>...
+	secrets set TLS_KEY --from-file=key.pem
+	cat keystore.p12 | secrets set KEYSTORE --from-stdin --base64`,
 	Short:                 "Used set secrets",
 	Use:                   "set [secrets]",
 	DisableFlagsInUseLine: true,
@@ -177,13 +222,37 @@ var secretsSetCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse secret type")
 		}
 
-		var secretOperations []models.SecretSetOperation
+		fromFile, err := cmd.Flags().GetString("from-file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		fromStdin, err := cmd.Flags().GetBool("from-stdin")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		encodeBase64, err := cmd.Flags().GetBool("base64")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if fromFile != "" || fromStdin {
+			args, err = secretArgFromInput(args, fromFile, fromStdin, encodeBase64)
+			if err != nil {
+				util.HandleError(err, "Unable to read the secret value")
+			}
+		} else if encodeBase64 {
+			util.PrintErrorMessageAndExit("--base64 can only be used with --from-file or --from-stdin")
+		}
+
+		var tokenDetails *models.TokenDetails
 		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
 			if projectId == "" {
 				util.PrintErrorMessageAndExit("When using service tokens or machine identities, you must set the --projectId flag")
 			}
 
-			secretOperations, err = util.SetRawSecrets(args, secretType, environmentName, secretsPath, projectId, token)
+			tokenDetails = token
 		} else {
 			if projectId == "" {
 				workspaceFile, err := util.GetWorkSpaceFromFile()
@@ -203,12 +272,13 @@ var secretsSetCmd = &cobra.Command{
 				util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
 			}
 
-			secretOperations, err = util.SetRawSecrets(args, secretType, environmentName, secretsPath, projectId, &models.TokenDetails{
+			tokenDetails = &models.TokenDetails{
 				Type:  "",
 				Token: loggedInUserDetails.UserCredentials.JTWToken,
-			})
+			}
 		}
 
+		secretOperations, approvals, err := util.SetRawSecrets(args, secretType, environmentName, secretsPath, projectId, tokenDetails)
 		if err != nil {
 			util.HandleError(err, "Unable to set secrets")
 		}
@@ -222,6 +292,8 @@ var secretsSetCmd = &cobra.Command{
 
 		visualize.Table(headers, rows)
 
+		printOpenedChangeRequests(approvals)
+
 		Telemetry.CaptureEvent("cli-command:secrets set", posthog.NewProperties().Set("version", util.CLI_VERSION))
 	},
 }
@@ -261,7 +333,7 @@ var secretsDeleteCmd = &cobra.Command{
 			util.HandleError(err, "Unable to parse flag")
 		}
 
-		httpClient := resty.New().
+		httpClient := api.NewHTTPClient().
 			SetHeader("Accept", "application/json")
 
 		if projectId == "" {
@@ -272,6 +344,7 @@ var secretsDeleteCmd = &cobra.Command{
 			projectId = workspaceFile.WorkspaceId
 		}
 
+		tokenDetails := token
 		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
 			httpClient.SetAuthToken(token.Token)
 		} else {
@@ -288,21 +361,37 @@ var secretsDeleteCmd = &cobra.Command{
 			}
 
 			httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken)
+			tokenDetails = &models.TokenDetails{Token: loggedInUserDetails.UserCredentials.JTWToken}
 		}
 
-		for _, secretName := range args {
-			request := api.DeleteSecretV3Request{
-				WorkspaceId: projectId,
-				Environment: environmentName,
-				SecretName:  secretName,
-				Type:        secretType,
-				SecretPath:  secretsPath,
-			}
-
-			err = api.CallDeleteSecretsRawV3(httpClient, request)
+		// shared secrets are deleted in one batch, which opens a change request instead when the
+		// folder is protected by an approval policy
+		if secretType == util.SECRET_TYPE_SHARED {
+			approval, err := util.DeleteSharedRawSecrets(args, environmentName, secretsPath, projectId, tokenDetails)
 			if err != nil {
 				util.HandleError(err, "Unable to complete your delete request")
 			}
+
+			if approval != nil {
+				printOpenedChangeRequests([]api.SecretApprovalRequest{*approval})
+				Telemetry.CaptureEvent("cli-command:secrets delete", posthog.NewProperties().Set("secretCount", len(args)).Set("approvalRequested", true).Set("version", util.CLI_VERSION))
+				return
+			}
+		} else {
+			for _, secretName := range args {
+				request := api.DeleteSecretV3Request{
+					WorkspaceId: projectId,
+					Environment: environmentName,
+					SecretName:  secretName,
+					Type:        secretType,
+					SecretPath:  secretsPath,
+				}
+
+				err = api.CallDeleteSecretsRawV3(httpClient, request)
+				if err != nil {
+					util.HandleError(err, "Unable to complete your delete request")
+				}
+			}
 		}
 
 		fmt.Printf("secret name(s) [%v] have been deleted from your project \n", strings.Join(args, ", "))
@@ -311,6 +400,38 @@ var secretsDeleteCmd = &cobra.Command{
 	},
 }
 
+// secretArgFromInput turns the single secret name given with --from-file or --from-stdin into the
+// name=value argument set expects, so values are used exactly as read, without shell quoting
+func secretArgFromInput(args []string, fromFile string, fromStdin bool, encodeBase64 bool) ([]string, error) {
+	if fromFile != "" && fromStdin {
+		return nil, errors.New("use either --from-file or --from-stdin")
+	}
+	if len(args) != 1 || strings.Contains(args[0], "=") {
+		return nil, errors.New("give only the name of the secret when reading its value from a file or stdin")
+	}
+
+	var value []byte
+	var err error
+	if fromFile != "" {
+		value, err = os.ReadFile(fromFile)
+	} else {
+		value, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if encodeBase64 {
+		return []string{args[0] + "=" + base64.StdEncoding.EncodeToString(value)}, nil
+	}
+
+	if !utf8.Valid(value) {
+		return nil, errors.New("the value is binary, use --base64 to store it base64 encoded")
+	}
+
+	return []string{args[0] + "=" + string(value)}, nil
+}
+
 func getSecretsByNames(cmd *cobra.Command, args []string) {
 	environmentName, _ := cmd.Flags().GetString("env")
 	if !cmd.Flags().Changed("env") {
@@ -320,9 +441,9 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	token, err := util.GetInfisicalToken(cmd)
+	token, err := getTokenOrLoginInCI(cmd)
 	if err != nil {
-		util.HandleError(err, "Unable to parse flag")
+		util.HandleError(err)
 	}
 
 	shouldExpand, err := cmd.Flags().GetBool("expand")
@@ -330,11 +451,21 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	expandLocally, err := cmd.Flags().GetBool("expand-locally")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	tagSlugs, err := cmd.Flags().GetString("tags")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	tagsMatch, err := cmd.Flags().GetString("tags-match")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	projectId, err := cmd.Flags().GetString("projectId")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
@@ -361,6 +492,8 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	outputFormat := getOutputFormat(cmd)
+
 	includeImports, err := cmd.Flags().GetBool("include-imports")
 	if err != nil {
 		util.HandleError(err, "Unable to parse flag")
@@ -371,14 +504,28 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	offlineFallback, err := cmd.Flags().GetBool("offline-fallback")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	maxStaleness, err := cmd.Flags().GetDuration("max-staleness")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	request := models.GetAllSecretsParameters{
-		Environment:            environmentName,
-		WorkspaceId:            projectId,
-		TagSlugs:               tagSlugs,
-		SecretsPath:            secretsPath,
-		IncludeImport:          includeImports,
-		Recursive:              recursive,
-		ExpandSecretReferences: shouldExpand,
+		Environment:                   environmentName,
+		WorkspaceId:                   projectId,
+		TagSlugs:                      tagSlugs,
+		TagsMatch:                     tagsMatch,
+		SecretsPath:                   secretsPath,
+		IncludeImport:                 includeImports,
+		Recursive:                     recursive,
+		ExpandSecretReferences:        shouldExpand,
+		ExpandSecretReferencesLocally: shouldExpand && expandLocally,
+		OfflineFallback:               offlineFallback,
+		MaxCacheStaleness:             maxStaleness,
 	}
 
 	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
@@ -421,6 +568,17 @@ func getSecretsByNames(cmd *cobra.Command, args []string) {
 		for _, secret := range requestedSecrets {
 			fmt.Println(secret.Value)
 		}
+	} else if outputFormat != OutputTable {
+		// documents only list the secrets that exist, the others are reported on stderr
+		foundSecrets := []models.SingleEnvironmentVariable{}
+		for _, secretKeyFromArg := range args {
+			if value, ok := secretsMap[secretKeyFromArg]; ok {
+				foundSecrets = append(foundSecrets, value)
+			} else {
+				util.PrintWarning(fmt.Sprintf("secret %s not found", secretKeyFromArg))
+			}
+		}
+		printOutput(outputFormat, toSecretsOutput(foundSecrets), nil)
 	} else {
 		visualize.PrintAllSecretDetails(requestedSecrets)
 	}
@@ -457,10 +615,16 @@ func generateExampleEnv(cmd *cobra.Command, args []string) {
 		util.HandleError(err, "Unable to parse flag")
 	}
 
+	tagsMatch, err := cmd.Flags().GetString("tags-match")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
 	request := models.GetAllSecretsParameters{
 		Environment:   environmentName,
 		WorkspaceId:   projectId,
 		TagSlugs:      tagSlugs,
+		TagsMatch:     tagsMatch,
 		SecretsPath:   secretsPath,
 		IncludeImport: true,
 	}
@@ -669,6 +833,26 @@ func getSecretsByKeys(secrets []models.SingleEnvironmentVariable) map[string]mod
 	return secretMapByName
 }
 
+type secretOutput struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Type       string `json:"type"`
+	SecretPath string `json:"secretPath,omitempty"`
+}
+
+func toSecretsOutput(secrets []models.SingleEnvironmentVariable) []secretOutput {
+	output := []secretOutput{}
+	for _, secret := range secrets {
+		output = append(output, secretOutput{
+			Key:        secret.Key,
+			Value:      secret.Value,
+			Type:       secret.Type,
+			SecretPath: secret.SecretPath,
+		})
+	}
+	return output
+}
+
 func init() {
 	secretsGenerateExampleEnvCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	secretsGenerateExampleEnvCmd.Flags().String("projectId", "", "manually set the projectId when using machine identity based auth")
@@ -679,9 +863,13 @@ func init() {
 	secretsGetCmd.Flags().String("projectId", "", "manually set the project ID to fetch secrets from when using machine identity based auth")
 	secretsGetCmd.Flags().String("path", "/", "get secrets within a folder path")
 	secretsGetCmd.Flags().Bool("plain", false, "print values without formatting, one per line")
+	addOutputFlag(secretsGetCmd)
 	secretsGetCmd.Flags().Bool("raw-value", false, "deprecated. Returns only the value of secret, only works with one secret. Use --plain instead")
 	secretsGetCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
 	secretsGetCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsGetCmd.Flags().Bool("offline-fallback", false, "with --token, cache fetched secrets encrypted with a key in the system keyring, and use them when Infisical can't be reached. Logged in users always have this")
+	secretsGetCmd.Flags().Duration("max-staleness", 0, "don't use cached secrets older than this when Infisical can't be reached, e.g. 24h. 0 allows any age")
+	secretsGetCmd.Flags().Bool("expand-locally", false, "resolve secret references in the CLI instead of on the server, for self-hosted instances that return them unexpanded")
 	secretsGetCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
 	secretsGetCmd.Flags().Bool("secret-overriding", true, "Prioritizes personal secrets, if any, with the same name over shared secrets")
 	secretsCmd.AddCommand(secretsGetCmd)
@@ -691,6 +879,9 @@ func init() {
 	secretsSetCmd.Flags().String("projectId", "", "manually set the project ID to for setting secrets when using machine identity based auth")
 	secretsSetCmd.Flags().String("path", "/", "set secrets within a folder path")
 	secretsSetCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of secret to create: personal or shared")
+	secretsSetCmd.Flags().String("from-file", "", "set the secret named by the only argument to the content of this file, e.g. a certificate")
+	secretsSetCmd.Flags().Bool("from-stdin", false, "set the secret named by the only argument to everything read from stdin")
+	secretsSetCmd.Flags().Bool("base64", false, "base64 encode the value read with --from-file or --from-stdin, for binary content")
 
 	secretsDeleteCmd.Flags().String("type", "personal", "the type of secret to delete: personal or shared  (default: personal)")
 	secretsDeleteCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
@@ -705,6 +896,7 @@ func init() {
 	getCmd.Flags().StringP("path", "p", "/", "The path from where folders should be fetched from")
 	getCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
 	getCmd.Flags().String("projectId", "", "manually set the projectId to fetch folders from when using machine identity based auth")
+	addOutputFlag(getCmd)
 	folderCmd.AddCommand(getCmd)
 
 	// Add createCmd flags here
@@ -729,10 +921,16 @@ func init() {
 	secretsCmd.Flags().String("projectId", "", "manually set the projectId to fetch secrets when using machine identity based auth")
 	secretsCmd.PersistentFlags().String("env", "dev", "Used to select the environment name on which actions should be taken on")
 	secretsCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsCmd.Flags().Bool("expand-locally", false, "resolve secret references in the CLI instead of on the server, for self-hosted instances that return them unexpanded")
 	secretsCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
 	secretsCmd.Flags().Bool("recursive", false, "Fetch secrets from all sub-folders")
+	secretsCmd.Flags().Bool("prefix-keys-with-path", false, "with --recursive, prefix the names of secrets in sub-folders with their folder path, e.g. DB__PASSWORD for PASSWORD in /db")
+	secretsCmd.Flags().String("path-separator", "__", "the separator between folder names and the secret name used by --prefix-keys-with-path")
+	secretsCmd.Flags().String("key-template", "", "with --recursive, a Go template for the name of each secret, e.g. '{{ join \"_\" (upper .Folder) .Key }}'. It has .Key, .Path, .Folder and .Environment, and the upper, lower, replace and join functions")
 	secretsCmd.PersistentFlags().StringP("tags", "t", "", "filter secrets by tag slugs")
+	secretsCmd.PersistentFlags().String("tags-match", util.TAGS_MATCH_ANY, "with --tags, whether secrets need any or all of the tags (any, all)")
 	secretsCmd.Flags().String("path", "/", "get secrets within a folder path")
 	secretsCmd.Flags().Bool("plain", false, "print values without formatting, one per line")
+	addOutputFlag(secretsCmd)
 	rootCmd.AddCommand(secretsCmd)
 }
@@ -0,0 +1,384 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/go-resty/resty/v2"
+	"github.com/manifoldco/promptui"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const maskedSecretValue = "********"
+
+var secretsBrowseCmd = &cobra.Command{
+	Example: `infisical secrets browse
+infisical secrets browse --env prod --path /backend
+infisical secrets browse --clear-clipboard-after 10s`,
+	Short:                 "Browse, reveal, copy and edit the secrets of a project in an interactive terminal UI",
+	Use:                   "browse",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		clearClipboardAfter, err := cmd.Flags().GetDuration("clear-clipboard-after")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		// logged in users outside of a project directory pick the project to browse
+		if projectId == "" && token == nil {
+			if _, err := util.GetWorkSpaceFromFile(); err != nil {
+				projectId, err = selectProjectToBrowse(newOrganizationHTTPClient(token, "browse projects"))
+				if err != nil {
+					util.HandleError(err, "Unable to select a project")
+				}
+			}
+		}
+
+		httpClient, tokenDetails, projectId := newProjectHTTPClient(token, projectId)
+
+		project, err := api.CallGetProjectById(httpClient, projectId)
+		if err != nil {
+			util.HandleError(err, "Unable to get the project")
+		}
+
+		browser := &secretsBrowser{
+			httpClient:          httpClient,
+			tokenDetails:        tokenDetails,
+			projectId:           projectId,
+			environments:        project.Environments,
+			secretsPath:         path.Clean("/" + secretsPath),
+			clearClipboardAfter: clearClipboardAfter,
+		}
+		defer browser.close()
+
+		if cmd.Flags().Changed("env") {
+			browser.environment, _ = cmd.Flags().GetString("env")
+		} else if environmentFromWorkspace := util.GetEnvFromWorkspaceFile(); environmentFromWorkspace != "" {
+			browser.environment = environmentFromWorkspace
+		} else if err := browser.selectEnvironment(); err != nil {
+			browser.close()
+			util.HandleError(err, "Unable to select an environment")
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets browse", posthog.NewProperties().Set("version", util.CLI_VERSION))
+
+		if err := browser.run(); err != nil {
+			browser.close()
+			util.HandleError(err)
+		}
+	},
+}
+
+type secretsBrowser struct {
+	httpClient   *resty.Client
+	tokenDetails *models.TokenDetails
+	projectId    string
+	environments []api.ProjectEnvironment
+
+	environment string
+	secretsPath string
+
+	clearClipboardAfter time.Duration
+	clipboardTimer      *time.Timer
+}
+
+type browserItemKind int
+
+const (
+	browserParentItem browserItemKind = iota
+	browserFolderItem
+	browserSecretItem
+	browserEnvironmentItem
+	browserQuitItem
+)
+
+type browserItem struct {
+	label  string
+	kind   browserItemKind
+	folder string
+	secret models.SingleEnvironmentVariable
+}
+
+func (b *secretsBrowser) run() error {
+	for {
+		items, err := b.listItems()
+		if err != nil {
+			return err
+		}
+
+		labels := []string{}
+		for _, item := range items {
+			labels = append(labels, item.label)
+		}
+
+		selectPrompt := promptui.Select{
+			Label: fmt.Sprintf("%s:%s (press / to search)", b.environment, b.secretsPath),
+			Items: labels,
+			Size:  15,
+			Searcher: func(input string, index int) bool {
+				return strings.Contains(strings.ToLower(labels[index]), strings.ToLower(input))
+			},
+		}
+
+		index, _, err := selectPrompt.Run()
+		if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		item := items[index]
+		switch item.kind {
+		case browserParentItem:
+			b.secretsPath = path.Dir(b.secretsPath)
+		case browserFolderItem:
+			b.secretsPath = path.Join(b.secretsPath, item.folder)
+		case browserSecretItem:
+			if err := b.secretActions(item.secret); err != nil {
+				return err
+			}
+		case browserEnvironmentItem:
+			if err := b.selectEnvironment(); err != nil {
+				return err
+			}
+		case browserQuitItem:
+			return nil
+		}
+	}
+}
+
+// listItems returns the folders and secrets of the current folder. Values are masked until revealed.
+func (b *secretsBrowser) listItems() ([]browserItem, error) {
+	folders, err := api.CallGetFoldersV1(b.httpClient, api.GetFoldersV1Request{
+		Environment: b.environment,
+		WorkspaceId: b.projectId,
+		FoldersPath: b.secretsPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := util.GetPlainTextSecretsV3(b.tokenDetails.Token, b.projectId, b.environment, b.secretsPath, false, false, "", false)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []browserItem{}
+	if b.secretsPath != "/" {
+		items = append(items, browserItem{label: "..", kind: browserParentItem})
+	}
+	for _, folder := range folders.Folders {
+		items = append(items, browserItem{label: folder.Name + "/", kind: browserFolderItem, folder: folder.Name})
+	}
+	for _, secret := range util.SortSecretsByKeys(secrets.Secrets) {
+		label := fmt.Sprintf("%s = %s", secret.Key, maskedSecretValue)
+		if secret.Type == util.SECRET_TYPE_PERSONAL {
+			label += " (personal)"
+		}
+		items = append(items, browserItem{label: label, kind: browserSecretItem, secret: secret})
+	}
+	items = append(items,
+		browserItem{label: "[switch environment]", kind: browserEnvironmentItem},
+		browserItem{label: "[quit]", kind: browserQuitItem},
+	)
+
+	return items, nil
+}
+
+func (b *secretsBrowser) secretActions(secret models.SingleEnvironmentVariable) error {
+	const (
+		revealAction = "Reveal value"
+		copyAction   = "Copy value to clipboard"
+		editAction   = "Edit value"
+		backAction   = "Back"
+	)
+
+	for {
+		actionPrompt := promptui.Select{
+			Label: secret.Key,
+			Items: []string{revealAction, copyAction, editAction, backAction},
+		}
+
+		_, action, err := actionPrompt.Run()
+		if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch action {
+		case revealAction:
+			fmt.Printf("%s=%s\n", secret.Key, secret.Value)
+		case copyAction:
+			if err := b.copyToClipboard(secret.Value); err != nil {
+				util.PrintWarning(fmt.Sprintf("Unable to copy the value: %v", err))
+			}
+		case editAction:
+			// the folder is listed again afterwards, with the new value
+			return b.editSecret(secret)
+		case backAction:
+			return nil
+		}
+	}
+}
+
+func (b *secretsBrowser) editSecret(secret models.SingleEnvironmentVariable) error {
+	valuePrompt := promptui.Prompt{
+		Label:   fmt.Sprintf("New value of %s", secret.Key),
+		Default: secret.Value,
+		Validate: func(input string) error {
+			if input == "" {
+				return errors.New("the value can't be empty")
+			}
+			return nil
+		},
+	}
+
+	value, err := valuePrompt.Run()
+	if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if value == secret.Value {
+		return nil
+	}
+
+	secretArgs := []string{secret.Key + "=" + value}
+
+	secretType := util.SECRET_TYPE_SHARED
+	if secret.Type == util.SECRET_TYPE_PERSONAL {
+		secretType = util.SECRET_TYPE_PERSONAL
+	}
+
+	_, approvals, err := util.SetRawSecrets(secretArgs, secretType, b.environment, b.secretsPath, b.projectId, b.tokenDetails)
+	if err != nil {
+		return err
+	}
+	if len(approvals) > 0 {
+		printOpenedChangeRequests(approvals)
+		return nil
+	}
+	fmt.Printf("Updated %s\n", secret.Key)
+	return nil
+}
+
+func (b *secretsBrowser) selectEnvironment() error {
+	if len(b.environments) == 0 {
+		return errors.New("the project has no environments")
+	}
+
+	labels := []string{}
+	for _, environment := range b.environments {
+		labels = append(labels, fmt.Sprintf("%s (%s)", environment.Name, environment.Slug))
+	}
+
+	environmentPrompt := promptui.Select{
+		Label: "Environment",
+		Items: labels,
+	}
+
+	index, _, err := environmentPrompt.Run()
+	if err != nil {
+		return err
+	}
+
+	b.environment = b.environments[index].Slug
+	b.secretsPath = "/"
+	return nil
+}
+
+// copyToClipboard copies the value and clears the clipboard once clearClipboardAfter passed, so
+// secrets don't linger in it
+func (b *secretsBrowser) copyToClipboard(value string) error {
+	if err := util.CopyToClipboard(value); err != nil {
+		return err
+	}
+
+	if b.clipboardTimer != nil {
+		b.clipboardTimer.Stop()
+	}
+
+	if b.clearClipboardAfter <= 0 {
+		fmt.Println("Copied to the clipboard")
+		return nil
+	}
+
+	b.clipboardTimer = time.AfterFunc(b.clearClipboardAfter, func() {
+		util.ClearClipboard()
+	})
+	fmt.Printf("Copied to the clipboard, it's cleared in %v\n", b.clearClipboardAfter)
+	return nil
+}
+
+// close clears the clipboard right away if it still holds a copied secret
+func (b *secretsBrowser) close() {
+	if b.clipboardTimer != nil && b.clipboardTimer.Stop() {
+		util.ClearClipboard()
+	}
+}
+
+func selectProjectToBrowse(httpClient *resty.Client) (string, error) {
+	workspaces, err := api.CallGetAllWorkSpacesUserBelongsTo(httpClient)
+	if err != nil {
+		return "", err
+	}
+	if len(workspaces.Workspaces) == 0 {
+		return "", errors.New("you don't have access to any project")
+	}
+
+	labels := []string{}
+	for _, workspace := range workspaces.Workspaces {
+		labels = append(labels, workspace.Name)
+	}
+
+	projectPrompt := promptui.Select{
+		Label: "Project",
+		Items: labels,
+		Size:  15,
+	}
+
+	index, _, err := projectPrompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	return workspaces.Workspaces[index].ID, nil
+}
+
+func init() {
+	secretsBrowseCmd.Flags().String("token", "", "Browse secrets using a service token or machine identity access token")
+	secretsBrowseCmd.Flags().String("projectId", "", "manually set the project ID to browse, required when using a machine identity")
+	secretsBrowseCmd.Flags().String("path", "/", "folder to start browsing in")
+	secretsBrowseCmd.Flags().Duration("clear-clipboard-after", 30*time.Second, "clear the clipboard this long after copying a value, 0 keeps it")
+	secretsCmd.AddCommand(secretsBrowseCmd)
+}
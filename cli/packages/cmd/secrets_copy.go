@@ -0,0 +1,433 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+var secretsCopyCmd = &cobra.Command{
+	Example: `
+	infisical secrets copy --env=staging --to-env=prod --dry-run
+	infisical secrets copy DB_HOST DB_PORT --env=dev --to-env=staging --on-conflict=skip
+	infisical secrets copy --env=staging --path=/api --to-env=prod --recursive
+	infisical secrets copy --env=dev --projectId=<project-id> --to-project=<other-project-id>`,
+	Short:                 "Used to copy or promote secrets from one environment, folder or project to another",
+	Use:                   "copy [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token == nil {
+			util.RequireLocalWorkspaceFile()
+		}
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		targetEnvironment, err := cmd.Flags().GetString("to-env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		targetPath, err := cmd.Flags().GetString("to-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		targetProjectId, err := cmd.Flags().GetString("to-project")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeImports, err := cmd.Flags().GetBool("include-imports")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagsMatch, err := cmd.Flags().GetString("tags-match")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if tagsMatch != util.TAGS_MATCH_ANY && tagsMatch != util.TAGS_MATCH_ALL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("Invalid --tags-match %q, it must be %s or %s", tagsMatch, util.TAGS_MATCH_ANY, util.TAGS_MATCH_ALL))
+		}
+
+		onConflict, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if onConflict != util.SECRET_CONFLICT_OVERWRITE && onConflict != util.SECRET_CONFLICT_SKIP && onConflict != util.SECRET_CONFLICT_FAIL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--on-conflict must be one of %s, %s or %s", util.SECRET_CONFLICT_OVERWRITE, util.SECRET_CONFLICT_SKIP, util.SECRET_CONFLICT_FAIL))
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tokenDetails := token
+		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+			if projectId == "" {
+				util.PrintErrorMessageAndExit("When using service tokens or machine identities, you must set the --projectId flag")
+			}
+		} else {
+			if projectId == "" {
+				workspaceFile, err := util.GetWorkSpaceFromFile()
+				if err != nil {
+					util.HandleError(err, "unable to get your local config details [err=%v]")
+				}
+
+				projectId = workspaceFile.WorkspaceId
+			}
+
+			loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+			if err != nil {
+				util.HandleError(err, "unable to authenticate [err=%v]")
+			}
+
+			if loggedInUserDetails.LoginExpired {
+				util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+			}
+
+			tokenDetails = &models.TokenDetails{
+				Type:  "",
+				Token: loggedInUserDetails.UserCredentials.JTWToken,
+			}
+		}
+
+		if targetEnvironment == "" {
+			targetEnvironment = environmentName
+		}
+		if targetPath == "" {
+			targetPath = secretsPath
+		}
+		if targetProjectId == "" {
+			targetProjectId = projectId
+		}
+
+		if targetProjectId == projectId && targetEnvironment == environmentName && path.Clean("/"+targetPath) == path.Clean("/"+secretsPath) {
+			util.PrintErrorMessageAndExit("Set where to copy the secrets to with --to-env, --to-path or --to-project")
+		}
+
+		sourceRequest := models.GetAllSecretsParameters{
+			Environment:   environmentName,
+			WorkspaceId:   projectId,
+			SecretsPath:   secretsPath,
+			TagSlugs:      tagSlugs,
+			TagsMatch:     tagsMatch,
+			IncludeImport: includeImports,
+		}
+		if tokenDetails.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			sourceRequest.InfisicalToken = tokenDetails.Token
+		} else if tokenDetails.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			sourceRequest.UniversalAuthAccessToken = tokenDetails.Token
+		}
+
+		folders := []string{secretsPath}
+		if recursive {
+			folders, err = collectSecretFolders(sourceRequest)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to list the folders of %s:%s", environmentName, secretsPath))
+			}
+		}
+
+		secretsByFolder, err := fetchSecretsToCopy(sourceRequest, tokenDetails, folders, args)
+		if err != nil {
+			util.HandleError(err, "Unable to fetch the secrets to copy")
+		}
+
+		// with --on-conflict=fail nothing is written unless no folder has a conflict
+		if onConflict == util.SECRET_CONFLICT_FAIL && !dryRun {
+			for _, folder := range folders {
+				if len(secretsByFolder[folder]) == 0 {
+					continue
+				}
+
+				destinationPath := copyDestinationPath(secretsPath, targetPath, folder)
+				folderExists, err := ensureSecretFolder(tokenDetails, targetProjectId, targetEnvironment, destinationPath, false)
+				if err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to check the folder %s in %s", destinationPath, targetEnvironment))
+				}
+				if !folderExists {
+					continue
+				}
+
+				_, _, err = util.PutRawSecrets(secretsByFolder[folder], util.SECRET_TYPE_SHARED, targetEnvironment, destinationPath, targetProjectId, tokenDetails, onConflict, true)
+				if err != nil {
+					util.HandleError(err, fmt.Sprintf("Unable to copy secrets into %s:%s", targetEnvironment, destinationPath))
+				}
+			}
+		}
+
+		copyOperations := []secretCopyOperation{}
+		approvals := []api.SecretApprovalRequest{}
+		copiedCount := 0
+		for _, folder := range folders {
+			secretsToCopy := secretsByFolder[folder]
+			if len(secretsToCopy) == 0 {
+				continue
+			}
+
+			destinationPath := copyDestinationPath(secretsPath, targetPath, folder)
+
+			folderExists, err := ensureSecretFolder(tokenDetails, targetProjectId, targetEnvironment, destinationPath, !dryRun)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to create the folder %s in %s", destinationPath, targetEnvironment))
+			}
+
+			// a dry run doesn't create missing folders, and everything copied into them is new
+			if !folderExists {
+				for _, secret := range secretsToCopy {
+					copyOperations = append(copyOperations, secretCopyOperation{Folder: destinationPath, SecretKey: secret.Key, SecretOperation: "SECRET CREATED"})
+				}
+				copiedCount += len(secretsToCopy)
+				continue
+			}
+
+			secretOperations, folderApprovals, err := util.PutRawSecrets(secretsToCopy, util.SECRET_TYPE_SHARED, targetEnvironment, destinationPath, targetProjectId, tokenDetails, onConflict, dryRun)
+			for _, secretOperation := range secretOperations {
+				copyOperations = append(copyOperations, secretCopyOperation{Folder: destinationPath, SecretKey: secretOperation.SecretKey, SecretOperation: secretOperation.SecretOperation})
+			}
+			if err != nil {
+				printCopyOperations(copyOperations, dryRun)
+				printOpenedChangeRequests(approvals)
+				util.HandleError(err, fmt.Sprintf("Unable to copy secrets into %s:%s", targetEnvironment, destinationPath))
+			}
+			approvals = append(approvals, folderApprovals...)
+			copiedCount += len(secretsToCopy) - countRequestedOperations(secretOperations)
+		}
+
+		if len(copyOperations) == 0 {
+			util.PrintWarning(fmt.Sprintf("No secrets to copy found in %s:%s", environmentName, secretsPath))
+			return
+		}
+
+		printCopyOperations(copyOperations, dryRun)
+		printOpenedChangeRequests(approvals)
+
+		if dryRun {
+			fmt.Printf("Dry run, no secrets were changed in %s:%s\n", targetEnvironment, targetPath)
+		} else {
+			fmt.Printf("Copied %d secrets from %s:%s to %s:%s\n", copiedCount, environmentName, secretsPath, targetEnvironment, targetPath)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets copy", posthog.NewProperties().Set("secretCount", copiedCount).Set("crossProject", targetProjectId != projectId).Set("version", util.CLI_VERSION))
+	},
+}
+
+type secretCopyOperation struct {
+	Folder          string
+	SecretKey       string
+	SecretOperation string
+}
+
+// values are left out, the table is meant to be reviewed before promoting secrets
+func printCopyOperations(copyOperations []secretCopyOperation, dryRun bool) {
+	status := "STATUS"
+	if dryRun {
+		status = "STATUS (DRY RUN)"
+	}
+
+	rows := [][]string{}
+	for _, copyOperation := range copyOperations {
+		rows = append(rows, []string{copyOperation.Folder, copyOperation.SecretKey, copyOperation.SecretOperation})
+	}
+
+	visualize.GenericTable([]string{"FOLDER", "SECRET NAME", status}, rows)
+}
+
+// collectSecretFolders returns the folder of the request and all folders below it, parents first
+func collectSecretFolders(request models.GetAllSecretsParameters) ([]string, error) {
+	folders := []string{request.SecretsPath}
+
+	for i := 0; i < len(folders); i++ {
+		subFolders, err := util.GetAllFolders(models.GetAllFoldersParameters{
+			WorkspaceId:              request.WorkspaceId,
+			Environment:              request.Environment,
+			FoldersPath:              folders[i],
+			InfisicalToken:           request.InfisicalToken,
+			UniversalAuthAccessToken: request.UniversalAuthAccessToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		names := []string{}
+		for _, subFolder := range subFolders {
+			names = append(names, subFolder.Name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			folders = append(folders, path.Join(folders[i], name))
+		}
+	}
+
+	return folders, nil
+}
+
+// fetchSecretsToCopy returns the shared secrets of each folder, only the named ones when names are set.
+// References are copied as they are, for every kind of token, so they resolve in the target.
+func fetchSecretsToCopy(request models.GetAllSecretsParameters, tokenDetails *models.TokenDetails, folders []string, names []string) (map[string][]models.SingleEnvironmentVariable, error) {
+	wanted := map[string]bool{}
+	for _, name := range names {
+		wanted[name] = true
+	}
+	found := map[string]bool{}
+
+	secretsByFolder := map[string][]models.SingleEnvironmentVariable{}
+	for _, folder := range folders {
+		secrets, err := fetchRawSecretsToCopy(request, tokenDetails, folder)
+		if err != nil {
+			return nil, fmt.Errorf("unable to fetch secrets of %s:%s [err=%v]", request.Environment, folder, err)
+		}
+
+		secrets = util.FilterSecretsByTags(secrets, request.TagSlugs, request.TagsMatch)
+		// only shared secrets are copied, in the order they were fetched in
+		for _, secret := range secrets {
+			if secret.Type == util.SECRET_TYPE_PERSONAL || (len(wanted) > 0 && !wanted[secret.Key]) {
+				continue
+			}
+			found[secret.Key] = true
+			secretsByFolder[folder] = append(secretsByFolder[folder], models.SingleEnvironmentVariable{Key: secret.Key, Value: secret.Value})
+		}
+	}
+
+	missing := []string{}
+	for _, name := range names {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("secrets not found in %s:%s: %s", request.Environment, request.SecretsPath, strings.Join(missing, ", "))
+	}
+
+	return secretsByFolder, nil
+}
+
+// fetchRawSecretsToCopy fetches the secrets of a folder without expanding their references, which the
+// logged in user path of GetAllEnvironmentVariables always does
+func fetchRawSecretsToCopy(request models.GetAllSecretsParameters, tokenDetails *models.TokenDetails, folder string) ([]models.SingleEnvironmentVariable, error) {
+	if tokenDetails.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		return util.GetPlainTextSecretsViaServiceToken(tokenDetails.Token, request.Environment, folder, request.IncludeImport, false, request.TagSlugs, false)
+	}
+
+	res, err := util.GetPlainTextSecretsV3(tokenDetails.Token, request.WorkspaceId, request.Environment, folder, request.IncludeImport, false, request.TagSlugs, false)
+	if err != nil {
+		return nil, err
+	}
+	return res.Secrets, nil
+}
+
+// copyDestinationPath returns where the secrets of folder go, keeping their place below the copied path
+func copyDestinationPath(sourcePath string, targetPath string, folder string) string {
+	relativePath := strings.TrimPrefix(path.Clean("/"+folder), path.Clean("/"+sourcePath))
+	return path.Join("/", targetPath, relativePath)
+}
+
+// ensureSecretFolder checks that a folder exists, creating it and its parents when create is set
+func ensureSecretFolder(tokenDetails *models.TokenDetails, projectId string, environment string, folderPath string, create bool) (bool, error) {
+	parentPath := "/"
+	for _, name := range strings.Split(strings.Trim(folderPath, "/"), "/") {
+		if name == "" {
+			continue
+		}
+
+		listRequest := models.GetAllFoldersParameters{WorkspaceId: projectId, Environment: environment, FoldersPath: parentPath}
+		if tokenDetails.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			listRequest.InfisicalToken = tokenDetails.Token
+		} else if tokenDetails.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			listRequest.UniversalAuthAccessToken = tokenDetails.Token
+		}
+
+		folders, err := util.GetAllFolders(listRequest)
+		if err != nil {
+			return false, err
+		}
+
+		exists := false
+		for _, folder := range folders {
+			if folder.Name == name {
+				exists = true
+				break
+			}
+		}
+
+		if !exists {
+			if !create {
+				return false, nil
+			}
+
+			_, err := util.CreateFolder(models.CreateFolderParameters{
+				FolderName:     name,
+				WorkspaceId:    projectId,
+				Environment:    environment,
+				FolderPath:     parentPath,
+				InfisicalToken: tokenDetails.Token,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+
+		parentPath = path.Join(parentPath, name)
+	}
+
+	return true, nil
+}
+
+func init() {
+	secretsCopyCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsCopyCmd.Flags().String("projectId", "", "manually set the project ID to copy secrets from when using machine identity based auth")
+	secretsCopyCmd.Flags().String("path", "/", "the folder path to copy secrets from")
+	secretsCopyCmd.Flags().String("to-env", "", "the environment to copy secrets to, defaults to --env")
+	secretsCopyCmd.Flags().String("to-path", "", "the folder path to copy secrets to, defaults to --path")
+	secretsCopyCmd.Flags().String("to-project", "", "the ID of the project to copy secrets to, defaults to the project they are copied from")
+	secretsCopyCmd.Flags().Bool("recursive", false, "also copy the secrets of all sub-folders, creating the folders where they are missing")
+	secretsCopyCmd.Flags().Bool("include-imports", false, "also copy imported secrets, as secrets of the destination")
+	secretsCopyCmd.Flags().String("on-conflict", util.SECRET_CONFLICT_OVERWRITE, "what to do with secrets that already exist in the destination with another value: overwrite, skip or fail")
+	secretsCopyCmd.Flags().Bool("dry-run", false, "only show what would be copied")
+	secretsCmd.AddCommand(secretsCopyCmd)
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCopyDestinationPath(t *testing.T) {
+	tests := []struct {
+		sourcePath string
+		targetPath string
+		folder     string
+		expected   string
+	}{
+		{sourcePath: "/", targetPath: "/", folder: "/", expected: "/"},
+		{sourcePath: "/", targetPath: "/", folder: "/db/primary", expected: "/db/primary"},
+		{sourcePath: "/api", targetPath: "/", folder: "/api", expected: "/"},
+		{sourcePath: "/api", targetPath: "/services/api", folder: "/api/db", expected: "/services/api/db"},
+		{sourcePath: "/api/", targetPath: "services", folder: "/api/db/", expected: "/services/db"},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, copyDestinationPath(test.sourcePath, test.targetPath, test.folder), test.folder)
+	}
+}
+
+func TestFetchSecretsToCopy(t *testing.T) {
+	server := newSecretsTestServer(t, map[string][]testSecret{
+		"dev:/": {
+			{Key: "DB_HOST", Value: "db.dev", Tags: []string{"database"}},
+			{Key: "DB_URL", Value: "postgres://${DB_HOST}/app", Tags: []string{"database"}},
+			{Key: "DB_URL", Value: "postgres://localhost/app", Type: util.SECRET_TYPE_PERSONAL},
+			{Key: "LOG_LEVEL", Value: "info"},
+		},
+		"dev:/api": {{Key: "PORT", Value: "8080"}},
+	})
+	request := models.GetAllSecretsParameters{WorkspaceId: "project", Environment: "dev", SecretsPath: "/", TagsMatch: util.TAGS_MATCH_ANY}
+
+	tests := []struct {
+		name         string
+		tokenDetails *models.TokenDetails
+		tagSlugs     string
+		folders      []string
+		names        []string
+		want         map[string][]models.SingleEnvironmentVariable
+		wantErr      string
+	}{
+		{
+			name:         "logged in user",
+			tokenDetails: &models.TokenDetails{Token: "jwt"},
+			folders:      []string{"/", "/api"},
+			want: map[string][]models.SingleEnvironmentVariable{
+				"/":    {{Key: "DB_HOST", Value: "db.dev"}, {Key: "DB_URL", Value: "postgres://${DB_HOST}/app"}, {Key: "LOG_LEVEL", Value: "info"}},
+				"/api": {{Key: "PORT", Value: "8080"}},
+			},
+		},
+		{
+			name:         "by name",
+			tokenDetails: &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"},
+			folders:      []string{"/"},
+			names:        []string{"LOG_LEVEL"},
+			want:         map[string][]models.SingleEnvironmentVariable{"/": {{Key: "LOG_LEVEL", Value: "info"}}},
+		},
+		{
+			name:         "by tag",
+			tokenDetails: &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"},
+			tagSlugs:     "database",
+			folders:      []string{"/"},
+			want:         map[string][]models.SingleEnvironmentVariable{"/": {{Key: "DB_HOST", Value: "db.dev"}, {Key: "DB_URL", Value: "postgres://${DB_HOST}/app"}}},
+		},
+		{
+			name:         "missing name",
+			tokenDetails: &models.TokenDetails{Token: "jwt"},
+			folders:      []string{"/"},
+			names:        []string{"LOG_LEVEL", "MISSING"},
+			wantErr:      "secrets not found in dev:/: MISSING",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			folderRequest := request
+			folderRequest.TagSlugs = test.tagSlugs
+
+			secretsByFolder, err := fetchSecretsToCopy(folderRequest, test.tokenDetails, test.folders, test.names)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, secretsByFolder)
+
+			for _, received := range server.receivedRequests() {
+				assert.Empty(t, received["expandSecretReferences"])
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/go-resty/resty/v2"
+	"github.com/spf13/cobra"
+)
+
+const (
+	diffValuesHidden = "hidden"
+	diffValuesHashed = "hashed"
+	diffValuesPlain  = "plain"
+
+	diffStatusAdded   = "added"
+	diffStatusRemoved = "removed"
+	diffStatusChanged = "changed"
+
+	// snapshots are listed newest first, this many at a time
+	secretSnapshotsPageSize = 50
+)
+
+var secretsDiffCmd = &cobra.Command{
+	Example: `
+	infisical secrets diff --env=staging --compare-env=prod --keys-only
+	infisical secrets diff --env=prod --path=/api --compare-path=/worker
+	infisical secrets diff --env=prod --at=2024-06-01T00:00:00Z --values=hashed
+	infisical secrets diff --env=staging --compare-env=prod --keys-only --output=json --exit-code=1`,
+	Short:                 "Compare the secrets of two environments or folders, or of a folder and one of its snapshots",
+	Use:                   "diff",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		compareEnvironment, err := cmd.Flags().GetString("compare-env")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		comparePath, err := cmd.Flags().GetString("compare-path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		snapshotId, err := cmd.Flags().GetString("snapshot")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		at, err := cmd.Flags().GetString("at")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldExpandSecrets, err := cmd.Flags().GetBool("expand")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeImports, err := cmd.Flags().GetBool("include-imports")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keysOnly, err := cmd.Flags().GetBool("keys-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		valuesMode, err := cmd.Flags().GetString("values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if valuesMode != diffValuesHidden && valuesMode != diffValuesHashed && valuesMode != diffValuesPlain {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--values must be one of %s, %s or %s", diffValuesHidden, diffValuesHashed, diffValuesPlain))
+		}
+
+		outputFormat := getOutputFormat(cmd)
+
+		exitCode, err := cmd.Flags().GetInt("exit-code")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		comparesSnapshot := snapshotId != "" || at != ""
+		comparesFolder := compareEnvironment != "" || comparePath != ""
+		if comparesSnapshot == comparesFolder || (snapshotId != "" && at != "") {
+			util.PrintErrorMessageAndExit("Set what to compare with: --compare-env and/or --compare-path, --snapshot or --at")
+		}
+
+		if compareEnvironment == "" {
+			compareEnvironment = environmentName
+		}
+		if comparePath == "" {
+			comparePath = secretsPath
+		}
+
+		baseRequest := models.GetAllSecretsParameters{
+			Environment:            environmentName,
+			WorkspaceId:            projectId,
+			SecretsPath:            secretsPath,
+			IncludeImport:          includeImports,
+			ExpandSecretReferences: shouldExpandSecrets,
+		}
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			baseRequest.InfisicalToken = token.Token
+		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			baseRequest.UniversalAuthAccessToken = token.Token
+		}
+
+		base, err := fetchSecretsForDiff(baseRequest)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch secrets of %s:%s", environmentName, secretsPath))
+		}
+		baseLabel := fmt.Sprintf("%s:%s", environmentName, secretsPath)
+
+		var compared map[string]string
+		var comparedLabel string
+		if comparesSnapshot {
+			httpClient, _, workspaceId := newProjectHTTPClient(token, projectId)
+			compared, comparedLabel, err = fetchSnapshotSecretsForDiff(httpClient, workspaceId, environmentName, secretsPath, snapshotId, at)
+			if err != nil {
+				util.HandleError(err, "Unable to fetch the snapshot")
+			}
+		} else {
+			comparedRequest := baseRequest
+			comparedRequest.Environment = compareEnvironment
+			comparedRequest.SecretsPath = comparePath
+
+			compared, err = fetchSecretsForDiff(comparedRequest)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to fetch secrets of %s:%s", compareEnvironment, comparePath))
+			}
+			comparedLabel = fmt.Sprintf("%s:%s", compareEnvironment, comparePath)
+		}
+
+		differences := diffSecrets(base, compared, keysOnly)
+
+		printOutput(outputFormat, toSecretsDiffOutput(baseLabel, comparedLabel, differences, valuesMode), func() {
+			printSecretsDiffAsTable(baseLabel, comparedLabel, differences, valuesMode)
+		})
+
+		if len(differences) > 0 {
+			os.Exit(exitCode)
+		}
+	},
+}
+
+type secretDifference struct {
+	Key       string
+	Status    string
+	BaseValue *string
+	NewValue  *string
+}
+
+// diffSecrets returns how compared differs from base, sorted by key. Added secrets only exist in
+// compared and removed ones only in base.
+func diffSecrets(base map[string]string, compared map[string]string, keysOnly bool) []secretDifference {
+	differences := []secretDifference{}
+
+	for key, baseValue := range base {
+		baseValue := baseValue
+		comparedValue, ok := compared[key]
+		if !ok {
+			differences = append(differences, secretDifference{Key: key, Status: diffStatusRemoved, BaseValue: &baseValue})
+			continue
+		}
+		if !keysOnly && comparedValue != baseValue {
+			differences = append(differences, secretDifference{Key: key, Status: diffStatusChanged, BaseValue: &baseValue, NewValue: &comparedValue})
+		}
+	}
+
+	for key, comparedValue := range compared {
+		comparedValue := comparedValue
+		if _, ok := base[key]; !ok {
+			differences = append(differences, secretDifference{Key: key, Status: diffStatusAdded, NewValue: &comparedValue})
+		}
+	}
+
+	sort.Slice(differences, func(i, j int) bool { return differences[i].Key < differences[j].Key })
+	return differences
+}
+
+// displayDiffValue shows a value as requested by --values, hashes let values be compared without
+// revealing them
+func displayDiffValue(value *string, valuesMode string) string {
+	if value == nil {
+		return ""
+	}
+
+	switch valuesMode {
+	case diffValuesPlain:
+		return *value
+	case diffValuesHashed:
+		hash := sha256.Sum256([]byte(*value))
+		return "sha256:" + hex.EncodeToString(hash[:])[:12]
+	default:
+		return ""
+	}
+}
+
+func printSecretsDiffAsTable(baseLabel string, comparedLabel string, differences []secretDifference, valuesMode string) {
+	if len(differences) == 0 {
+		fmt.Printf("No differences between %s and %s\n", baseLabel, comparedLabel)
+		return
+	}
+
+	headers := []string{"SECRET NAME", "STATUS"}
+	if valuesMode != diffValuesHidden {
+		headers = append(headers, baseLabel, comparedLabel)
+	}
+
+	rows := [][]string{}
+	for _, difference := range differences {
+		row := []string{difference.Key, difference.Status}
+		if valuesMode != diffValuesHidden {
+			row = append(row, displayDiffValue(difference.BaseValue, valuesMode), displayDiffValue(difference.NewValue, valuesMode))
+		}
+		rows = append(rows, row)
+	}
+
+	visualize.GenericTable(headers, rows)
+}
+
+type secretDifferenceOutput struct {
+	Key       string `json:"key"`
+	Status    string `json:"status"`
+	BaseValue string `json:"baseValue,omitempty"`
+	NewValue  string `json:"newValue,omitempty"`
+}
+
+type secretsDiffOutput struct {
+	Base        string                   `json:"base"`
+	Compared    string                   `json:"compared"`
+	Identical   bool                     `json:"identical"`
+	Differences []secretDifferenceOutput `json:"differences"`
+}
+
+func toSecretsDiffOutput(baseLabel string, comparedLabel string, differences []secretDifference, valuesMode string) secretsDiffOutput {
+	output := secretsDiffOutput{
+		Base:        baseLabel,
+		Compared:    comparedLabel,
+		Identical:   len(differences) == 0,
+		Differences: []secretDifferenceOutput{},
+	}
+
+	for _, difference := range differences {
+		output.Differences = append(output.Differences, secretDifferenceOutput{
+			Key:       difference.Key,
+			Status:    difference.Status,
+			BaseValue: displayDiffValue(difference.BaseValue, valuesMode),
+			NewValue:  displayDiffValue(difference.NewValue, valuesMode),
+		})
+	}
+
+	return output
+}
+
+// fetchSecretsForDiff returns the shared secrets of a folder by key, personal overrides would make
+// two folders differ for one user only
+func fetchSecretsForDiff(request models.GetAllSecretsParameters) (map[string]string, error) {
+	secrets, err := util.GetAllEnvironmentVariables(request, "")
+	if err != nil {
+		return nil, err
+	}
+
+	secretsByKey := map[string]string{}
+	for _, secret := range util.OverrideSecrets(secrets, util.SECRET_TYPE_SHARED) {
+		secretsByKey[secret.Key] = secret.Value
+	}
+	return secretsByKey, nil
+}
+
+// newProjectHTTPClient returns a client authenticated with the token, or the logged in user, and
+// the token details and project ID to use with it
+func newProjectHTTPClient(token *models.TokenDetails, projectId string) (*resty.Client, *models.TokenDetails, string) {
+	httpClient := api.NewHTTPClient().
+		SetHeader("Accept", "application/json")
+
+	if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+		if projectId == "" {
+			util.PrintErrorMessageAndExit("When using service tokens or machine identities, you must set the --projectId flag")
+		}
+		httpClient.SetAuthToken(token.Token)
+		return httpClient, token, projectId
+	}
+
+	util.RequireLogin()
+	if projectId == "" {
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+		projectId = workspaceFile.WorkspaceId
+	}
+
+	loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+	if err != nil {
+		util.HandleError(err, "Unable to authenticate")
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	}
+
+	httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken)
+	return httpClient, &models.TokenDetails{Token: loggedInUserDetails.UserCredentials.JTWToken}, projectId
+}
+
+// newOrganizationHTTPClient returns a client authenticated as the machine identity of token or as the
+// logged in user, for actions such as reviews and audits that service tokens can't take
+func newOrganizationHTTPClient(token *models.TokenDetails, action string) *resty.Client {
+	httpClient := api.NewHTTPClient().
+		SetHeader("Accept", "application/json")
+
+	if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+		util.PrintErrorMessageAndExit(fmt.Sprintf("Service tokens can't %s, use a machine identity or log in", action))
+	}
+
+	if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+		httpClient.SetAuthToken(token.Token)
+		return httpClient
+	}
+
+	util.RequireLogin()
+
+	loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+	if err != nil {
+		util.HandleError(err, "Unable to authenticate")
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	}
+
+	httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken)
+	return httpClient
+}
+
+// fetchSnapshotSecretsForDiff returns the shared secrets of a snapshot, either the one with the given
+// id or the latest one of the folder taken at or before the given time
+func fetchSnapshotSecretsForDiff(httpClient *resty.Client, workspaceId string, environmentName string, secretsPath string, snapshotId string, at string) (map[string]string, string, error) {
+	if snapshotId == "" {
+		pointInTime, err := time.Parse(time.RFC3339, at)
+		if err != nil {
+			return nil, "", fmt.Errorf("--at must be a time such as 2024-06-01T00:00:00Z: %w", err)
+		}
+
+		snapshot, err := findSecretSnapshotAt(httpClient, workspaceId, environmentName, secretsPath, pointInTime)
+		if err != nil {
+			return nil, "", err
+		}
+		snapshotId = snapshot.ID
+	}
+
+	response, err := api.CallGetSecretSnapshotV1(httpClient, snapshotId)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secretsByKey := map[string]string{}
+	for _, secretVersion := range response.SecretSnapshot.SecretVersions {
+		if secretVersion.Type == "" || secretVersion.Type == util.SECRET_TYPE_SHARED {
+			secretsByKey[secretVersion.SecretKey] = secretVersion.SecretValue
+		}
+	}
+
+	label := fmt.Sprintf("snapshot %s (%s)", snapshotId, response.SecretSnapshot.CreatedAt.Format(time.RFC3339))
+	return secretsByKey, label, nil
+}
+
+func findSecretSnapshotAt(httpClient *resty.Client, workspaceId string, environmentName string, secretsPath string, pointInTime time.Time) (api.SecretSnapshot, error) {
+	for offset := 0; ; offset += secretSnapshotsPageSize {
+		response, err := api.CallGetSecretSnapshotsV1(httpClient, api.GetSecretSnapshotsV1Request{
+			WorkspaceId: workspaceId,
+			Environment: environmentName,
+			SecretPath:  secretsPath,
+			Offset:      offset,
+			Limit:       secretSnapshotsPageSize,
+		})
+		if err != nil {
+			return api.SecretSnapshot{}, err
+		}
+
+		for _, snapshot := range response.SecretSnapshots {
+			if !snapshot.CreatedAt.After(pointInTime) {
+				return snapshot, nil
+			}
+		}
+
+		if len(response.SecretSnapshots) < secretSnapshotsPageSize {
+			return api.SecretSnapshot{}, fmt.Errorf("no snapshot of %s:%s was taken at or before %s", environmentName, secretsPath, pointInTime.Format(time.RFC3339))
+		}
+	}
+}
+
+func init() {
+	secretsDiffCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsDiffCmd.Flags().String("projectId", "", "manually set the project ID to compare secrets of when using machine identity based auth")
+	secretsDiffCmd.Flags().String("path", "/", "the folder path to compare")
+	secretsDiffCmd.Flags().String("compare-env", "", "the environment to compare with, defaults to --env")
+	secretsDiffCmd.Flags().String("compare-path", "", "the folder path to compare with, defaults to --path")
+	secretsDiffCmd.Flags().String("snapshot", "", "compare with the snapshot with this ID")
+	secretsDiffCmd.Flags().String("at", "", "compare with the folder as it was at this time, e.g. 2024-06-01T00:00:00Z")
+	secretsDiffCmd.Flags().Bool("keys-only", false, "only compare which secrets exist, not their values")
+	secretsDiffCmd.Flags().String("values", diffValuesHidden, "show values of differing secrets: hidden, hashed or plain")
+	addOutputFlag(secretsDiffCmd)
+	secretsDiffCmd.Flags().Int("exit-code", 0, "exit code when the secrets differ, e.g. 1 to fail a CI job")
+	secretsDiffCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsDiffCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
+	secretsCmd.AddCommand(secretsDiffCmd)
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffSecrets(t *testing.T) {
+	value := func(v string) *string { return &v }
+
+	tests := []struct {
+		name     string
+		base     map[string]string
+		compared map[string]string
+		keysOnly bool
+		want     []secretDifference
+	}{
+		{
+			name:     "identical",
+			base:     map[string]string{"A": "1", "B": "2"},
+			compared: map[string]string{"A": "1", "B": "2"},
+			want:     []secretDifference{},
+		},
+		{
+			name:     "both empty",
+			base:     map[string]string{},
+			compared: map[string]string{},
+			want:     []secretDifference{},
+		},
+		{
+			name:     "added, removed and changed, sorted by key",
+			base:     map[string]string{"C": "3", "A": "1", "B": "2"},
+			compared: map[string]string{"A": "1", "B": "two", "D": "4"},
+			want: []secretDifference{
+				{Key: "B", Status: diffStatusChanged, BaseValue: value("2"), NewValue: value("two")},
+				{Key: "C", Status: diffStatusRemoved, BaseValue: value("3")},
+				{Key: "D", Status: diffStatusAdded, NewValue: value("4")},
+			},
+		},
+		{
+			name:     "keys only ignores changed values",
+			base:     map[string]string{"A": "1", "B": "2"},
+			compared: map[string]string{"A": "one", "C": "3"},
+			keysOnly: true,
+			want: []secretDifference{
+				{Key: "B", Status: diffStatusRemoved, BaseValue: value("2")},
+				{Key: "C", Status: diffStatusAdded, NewValue: value("3")},
+			},
+		},
+		{
+			name:     "empty value is not a missing secret",
+			base:     map[string]string{"A": ""},
+			compared: map[string]string{"A": "set"},
+			want: []secretDifference{
+				{Key: "A", Status: diffStatusChanged, BaseValue: value(""), NewValue: value("set")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, diffSecrets(test.base, test.compared, test.keysOnly))
+		})
+	}
+}
+
+func TestDisplayDiffValue(t *testing.T) {
+	value := "hunter2"
+
+	tests := []struct {
+		name       string
+		value      *string
+		valuesMode string
+		want       string
+	}{
+		{name: "hidden", value: &value, valuesMode: diffValuesHidden, want: ""},
+		{name: "plain", value: &value, valuesMode: diffValuesPlain, want: "hunter2"},
+		{name: "hashed", value: &value, valuesMode: diffValuesHashed, want: "sha256:f52fbd32b2b3"},
+		{name: "missing", value: nil, valuesMode: diffValuesPlain, want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, displayDiffValue(test.value, test.valuesMode))
+		})
+	}
+}
+
+func TestToSecretsDiffOutput(t *testing.T) {
+	base, changed := "1", "2"
+	output := toSecretsDiffOutput("dev:/", "prod:/", []secretDifference{{Key: "A", Status: diffStatusChanged, BaseValue: &base, NewValue: &changed}}, diffValuesPlain)
+	assert.Equal(t, secretsDiffOutput{
+		Base:        "dev:/",
+		Compared:    "prod:/",
+		Differences: []secretDifferenceOutput{{Key: "A", Status: diffStatusChanged, BaseValue: "1", NewValue: "2"}},
+	}, output)
+
+	assert.True(t, toSecretsDiffOutput("dev:/", "prod:/", []secretDifference{}, diffValuesPlain).Identical)
+}
@@ -0,0 +1,399 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	_ "embed"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	generatorRandom     = "random"
+	generatorPassphrase = "passphrase"
+	generatorUuid       = "uuid"
+	generatorRsa        = "rsa"
+	generatorEc         = "ec"
+
+	charsetAlphanumeric = "alphanumeric"
+	charsetNumeric      = "numeric"
+	charsetHex          = "hex"
+	charsetBase64Url    = "base64url"
+	charsetAscii        = "ascii"
+)
+
+var generateCharsets = map[string]string{
+	charsetAlphanumeric: "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789",
+	charsetNumeric:      "0123456789",
+	charsetHex:          "0123456789abcdef",
+	charsetBase64Url:    "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_",
+	charsetAscii:        "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#$%&()*+,-./:;<=>?@[]^_{|}~",
+}
+
+var generateCurves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// the BIP39 list of 2048 english words, each word of a passphrase adds 11 bits of entropy
+//
+//go:embed passphrase-wordlist/bip39-english.txt
+var passphraseWordlist string
+
+// secretGenerationPolicy is how the values of generated secrets are made
+type secretGenerationPolicy struct {
+	Generator string
+	Length    int
+	Charset   string
+	Chars     string
+	Words     int
+	Separator string
+	Bits      int
+	Curve     string
+}
+
+var secretsGenerateCmd = &cobra.Command{
+	Example: `
+	infisical secrets generate DB_PASSWORD --env=prod
+	infisical secrets generate API_KEY --length=48 --charset=base64url
+	infisical secrets generate ADMIN_PASSPHRASE --generator=passphrase --words=7
+	infisical secrets generate JWT_SIGNING_KEY --generator=ec --curve=P-384
+	infisical secrets generate SESSION_SECRET --on-conflict=overwrite`,
+	Short:                 "Used to generate random values for secrets and store them",
+	Long:                  "Generate random values for secrets and store them, without the values passing through your shell. Keypairs are stored as the PEM private key under the name, and the public key under the name with --public-key-suffix.",
+	Use:                   "generate [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token == nil {
+			util.RequireLocalWorkspaceFile()
+		}
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretType, err := cmd.Flags().GetString("type")
+		if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+			util.HandleError(err, "Unable to parse secret type")
+		}
+
+		policy := secretGenerationPolicy{}
+
+		policy.Generator, err = cmd.Flags().GetString("generator")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Length, err = cmd.Flags().GetInt("length")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Charset, err = cmd.Flags().GetString("charset")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Chars, err = cmd.Flags().GetString("chars")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Words, err = cmd.Flags().GetInt("words")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Separator, err = cmd.Flags().GetString("separator")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Bits, err = cmd.Flags().GetInt("bits")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		policy.Curve, err = cmd.Flags().GetString("curve")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		publicKeySuffix, err := cmd.Flags().GetString("public-key-suffix")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		onConflict, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if onConflict != util.SECRET_CONFLICT_OVERWRITE && onConflict != util.SECRET_CONFLICT_SKIP && onConflict != util.SECRET_CONFLICT_FAIL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--on-conflict must be one of %s, %s or %s", util.SECRET_CONFLICT_OVERWRITE, util.SECRET_CONFLICT_SKIP, util.SECRET_CONFLICT_FAIL))
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if err := policy.validate(); err != nil {
+			util.HandleError(err, "Invalid generation policy")
+		}
+
+		secretsToSet := []models.SingleEnvironmentVariable{}
+		for _, secretName := range args {
+			generated, err := policy.generate(secretName, publicKeySuffix)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to generate a value for %s", secretName))
+			}
+			secretsToSet = append(secretsToSet, generated...)
+		}
+
+		tokenDetails := token
+		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+			if projectId == "" {
+				util.PrintErrorMessageAndExit("When using service tokens or machine identities, you must set the --projectId flag")
+			}
+		} else {
+			if projectId == "" {
+				workspaceFile, err := util.GetWorkSpaceFromFile()
+				if err != nil {
+					util.HandleError(err, "unable to get your local config details [err=%v]")
+				}
+
+				projectId = workspaceFile.WorkspaceId
+			}
+
+			loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+			if err != nil {
+				util.HandleError(err, "unable to authenticate [err=%v]")
+			}
+
+			if loggedInUserDetails.LoginExpired {
+				util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+			}
+
+			tokenDetails = &models.TokenDetails{
+				Type:  "",
+				Token: loggedInUserDetails.UserCredentials.JTWToken,
+			}
+		}
+
+		secretOperations, approvals, err := util.PutRawSecrets(secretsToSet, secretType, environmentName, secretsPath, projectId, tokenDetails, onConflict, dryRun)
+		if secretOperations != nil {
+			// the generated values are never printed, they are meant to only exist in Infisical
+			printImportOperations(secretOperations, dryRun)
+		}
+		printOpenedChangeRequests(approvals)
+		if err != nil {
+			util.HandleError(err, "Unable to store the generated secrets")
+		}
+
+		if dryRun {
+			fmt.Printf("Dry run, no secrets were changed in %s:%s\n", environmentName, secretsPath)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets generate", posthog.NewProperties().Set("secretCount", len(secretsToSet)).Set("generator", policy.Generator).Set("version", util.CLI_VERSION))
+	},
+}
+
+func (p secretGenerationPolicy) validate() error {
+	switch p.Generator {
+	case generatorRandom:
+		if p.Length < 8 {
+			return fmt.Errorf("--length must be at least 8")
+		}
+		if p.Chars == "" {
+			if _, ok := generateCharsets[p.Charset]; !ok {
+				return fmt.Errorf("unknown charset %q, it must be one of %s, %s, %s, %s or %s", p.Charset, charsetAlphanumeric, charsetNumeric, charsetHex, charsetBase64Url, charsetAscii)
+			}
+		} else if len(uniqueRunes(p.Chars)) < 2 {
+			return fmt.Errorf("--chars must have at least 2 different characters")
+		}
+	case generatorPassphrase:
+		if p.Words < 4 {
+			return fmt.Errorf("--words must be at least 4")
+		}
+	case generatorUuid:
+	case generatorRsa:
+		if p.Bits != 2048 && p.Bits != 3072 && p.Bits != 4096 {
+			return fmt.Errorf("--bits must be 2048, 3072 or 4096")
+		}
+	case generatorEc:
+		if _, ok := generateCurves[p.Curve]; !ok {
+			return fmt.Errorf("unknown curve %q, it must be P-256, P-384 or P-521", p.Curve)
+		}
+	default:
+		return fmt.Errorf("unknown generator %q, it must be one of %s, %s, %s, %s or %s", p.Generator, generatorRandom, generatorPassphrase, generatorUuid, generatorRsa, generatorEc)
+	}
+	return nil
+}
+
+// generate returns the secrets to store for name, two of them for keypairs
+func (p secretGenerationPolicy) generate(name string, publicKeySuffix string) ([]models.SingleEnvironmentVariable, error) {
+	switch p.Generator {
+	case generatorPassphrase:
+		words := strings.Fields(passphraseWordlist)
+		chosen := make([]string, 0, p.Words)
+		for i := 0; i < p.Words; i++ {
+			index, err := rand.Int(rand.Reader, big.NewInt(int64(len(words))))
+			if err != nil {
+				return nil, err
+			}
+			chosen = append(chosen, words[index.Int64()])
+		}
+		return []models.SingleEnvironmentVariable{{Key: name, Value: strings.Join(chosen, p.Separator)}}, nil
+
+	case generatorUuid:
+		value, err := generateUuidV4()
+		if err != nil {
+			return nil, err
+		}
+		return []models.SingleEnvironmentVariable{{Key: name, Value: value}}, nil
+
+	case generatorRsa, generatorEc:
+		privateKey, publicKey, err := p.generateKeypair()
+		if err != nil {
+			return nil, err
+		}
+		return []models.SingleEnvironmentVariable{
+			{Key: name, Value: privateKey},
+			{Key: name + publicKeySuffix, Value: publicKey},
+		}, nil
+
+	default:
+		chars := uniqueRunes(p.Chars)
+		if p.Chars == "" {
+			chars = []rune(generateCharsets[p.Charset])
+		}
+
+		value, err := generateRandomString(p.Length, chars)
+		if err != nil {
+			return nil, err
+		}
+		return []models.SingleEnvironmentVariable{{Key: name, Value: value}}, nil
+	}
+}
+
+// generateKeypair returns the PEM encoded PKCS #8 private key and PKIX public key
+func (p secretGenerationPolicy) generateKeypair() (string, string, error) {
+	var privateKey interface{}
+	var publicKey interface{}
+
+	if p.Generator == generatorRsa {
+		rsaKey, err := rsa.GenerateKey(rand.Reader, p.Bits)
+		if err != nil {
+			return "", "", err
+		}
+		privateKey, publicKey = rsaKey, &rsaKey.PublicKey
+	} else {
+		ecKey, err := ecdsa.GenerateKey(generateCurves[p.Curve], rand.Reader)
+		if err != nil {
+			return "", "", err
+		}
+		privateKey, publicKey = ecKey, &ecKey.PublicKey
+	}
+
+	privateKeyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return "", "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKeyBytes})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})), nil
+}
+
+// generateRandomString picks each character uniformly from chars, without the bias of a modulo
+func generateRandomString(length int, chars []rune) (string, error) {
+	value := make([]rune, length)
+	for i := range value {
+		index, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		value[i] = chars[index.Int64()]
+	}
+	return string(value), nil
+}
+
+func generateUuidV4() (string, error) {
+	uuid := make([]byte, 16)
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:16]), nil
+}
+
+func uniqueRunes(chars string) []rune {
+	seen := map[rune]bool{}
+	unique := []rune{}
+	for _, char := range chars {
+		if !seen[char] {
+			seen[char] = true
+			unique = append(unique, char)
+		}
+	}
+	return unique
+}
+
+func init() {
+	secretsGenerateCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsGenerateCmd.Flags().String("projectId", "", "manually set the project ID to store secrets in when using machine identity based auth")
+	secretsGenerateCmd.Flags().String("path", "/", "store secrets in a folder path")
+	secretsGenerateCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of secrets to create: personal or shared")
+	secretsGenerateCmd.Flags().String("generator", generatorRandom, "how values are generated: random, passphrase, uuid, rsa or ec")
+	secretsGenerateCmd.Flags().Int("length", 32, "the length of random values")
+	secretsGenerateCmd.Flags().String("charset", charsetAlphanumeric, "the characters of random values: alphanumeric, numeric, hex, base64url or ascii")
+	secretsGenerateCmd.Flags().String("chars", "", "the exact characters of random values, instead of --charset")
+	secretsGenerateCmd.Flags().Int("words", 6, "the number of words of passphrases")
+	secretsGenerateCmd.Flags().String("separator", "-", "the separator between the words of passphrases")
+	secretsGenerateCmd.Flags().Int("bits", 4096, "the size of RSA keys: 2048, 3072 or 4096")
+	secretsGenerateCmd.Flags().String("curve", "P-256", "the curve of EC keys: P-256, P-384 or P-521")
+	secretsGenerateCmd.Flags().String("public-key-suffix", "_PUBLIC", "the suffix of the name the public key of a keypair is stored under")
+	secretsGenerateCmd.Flags().String("on-conflict", util.SECRET_CONFLICT_FAIL, "what to do with secrets that already exist: overwrite, skip or fail")
+	secretsGenerateCmd.Flags().Bool("dry-run", false, "only show which secrets would be generated")
+	secretsCmd.AddCommand(secretsGenerateCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretGenerationPolicy(t *testing.T) {
+	t.Run("random", func(t *testing.T) {
+		policy := secretGenerationPolicy{Generator: generatorRandom, Length: 40, Charset: charsetHex}
+		assert.NoError(t, policy.validate())
+
+		secrets, err := policy.generate("API_KEY", "_PUBLIC")
+		assert.NoError(t, err)
+		assert.Len(t, secrets, 1)
+		assert.Equal(t, "API_KEY", secrets[0].Key)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{40}$`), secrets[0].Value)
+	})
+
+	t.Run("custom characters", func(t *testing.T) {
+		policy := secretGenerationPolicy{Generator: generatorRandom, Length: 16, Chars: "ab"}
+		assert.NoError(t, policy.validate())
+
+		secrets, err := policy.generate("PIN", "_PUBLIC")
+		assert.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[ab]{16}$`), secrets[0].Value)
+	})
+
+	t.Run("passphrase", func(t *testing.T) {
+		policy := secretGenerationPolicy{Generator: generatorPassphrase, Words: 5, Separator: "."}
+		assert.NoError(t, policy.validate())
+
+		secrets, err := policy.generate("PASSPHRASE", "_PUBLIC")
+		assert.NoError(t, err)
+		assert.Len(t, strings.Split(secrets[0].Value, "."), 5)
+		assert.Len(t, strings.Fields(passphraseWordlist), 2048)
+	})
+
+	t.Run("uuid", func(t *testing.T) {
+		secrets, err := secretGenerationPolicy{Generator: generatorUuid}.generate("ID", "_PUBLIC")
+		assert.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`), secrets[0].Value)
+	})
+
+	t.Run("ec keypair", func(t *testing.T) {
+		policy := secretGenerationPolicy{Generator: generatorEc, Curve: "P-384"}
+		assert.NoError(t, policy.validate())
+
+		secrets, err := policy.generate("SIGNING_KEY", "_PUBLIC")
+		assert.NoError(t, err)
+		assert.Equal(t, "SIGNING_KEY", secrets[0].Key)
+		assert.Equal(t, "SIGNING_KEY_PUBLIC", secrets[1].Key)
+
+		privateBlock, _ := pem.Decode([]byte(secrets[0].Value))
+		_, err = x509.ParsePKCS8PrivateKey(privateBlock.Bytes)
+		assert.NoError(t, err)
+
+		publicBlock, _ := pem.Decode([]byte(secrets[1].Value))
+		_, err = x509.ParsePKIXPublicKey(publicBlock.Bytes)
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid policies", func(t *testing.T) {
+		assert.Error(t, secretGenerationPolicy{Generator: generatorRandom, Length: 4, Charset: charsetHex}.validate())
+		assert.Error(t, secretGenerationPolicy{Generator: generatorRandom, Length: 32, Charset: "emoji"}.validate())
+		assert.Error(t, secretGenerationPolicy{Generator: generatorRandom, Length: 32, Chars: "aaa"}.validate())
+		assert.Error(t, secretGenerationPolicy{Generator: generatorRsa, Bits: 1024}.validate())
+		assert.Error(t, secretGenerationPolicy{Generator: "dice"}.validate())
+	})
+}
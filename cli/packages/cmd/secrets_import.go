@@ -0,0 +1,369 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+)
+
+var secretsImportCmd = &cobra.Command{
+	Example: `
+	infisical secrets import --file=.env --env=dev
+	infisical secrets import --file=secrets.json --env=prod --on-conflict=fail
+	infisical secrets import --file=config.yaml --path=/api --dry-run`,
+	Short:                 "Used to import secrets from a dotenv, JSON or YAML file",
+	Use:                   "import",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if token == nil {
+			util.RequireLocalWorkspaceFile()
+		}
+
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretType, err := cmd.Flags().GetString("type")
+		if err != nil || (secretType != util.SECRET_TYPE_SHARED && secretType != util.SECRET_TYPE_PERSONAL) {
+			util.HandleError(err, "Unable to parse secret type")
+		}
+
+		filePath, err := cmd.Flags().GetString("file")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		format, err := cmd.Flags().GetString("format")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		onConflict, err := cmd.Flags().GetString("on-conflict")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if onConflict != util.SECRET_CONFLICT_OVERWRITE && onConflict != util.SECRET_CONFLICT_SKIP && onConflict != util.SECRET_CONFLICT_FAIL {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--on-conflict must be one of %s, %s or %s", util.SECRET_CONFLICT_OVERWRITE, util.SECRET_CONFLICT_SKIP, util.SECRET_CONFLICT_FAIL))
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			util.HandleError(err, "Unable to read the file to import")
+		}
+
+		secretsToImport, err := parseSecretsFile(content, importFormatOf(filePath, format))
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to parse %s", filePath))
+		}
+
+		if len(secretsToImport) == 0 {
+			util.PrintWarning(fmt.Sprintf("No secrets found in %s", filePath))
+			return
+		}
+
+		tokenDetails := token
+		if token != nil && (token.Type == util.SERVICE_TOKEN_IDENTIFIER || token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER) {
+			if projectId == "" {
+				util.PrintErrorMessageAndExit("When using service tokens or machine identities, you must set the --projectId flag")
+			}
+		} else {
+			if projectId == "" {
+				workspaceFile, err := util.GetWorkSpaceFromFile()
+				if err != nil {
+					util.HandleError(err, "unable to get your local config details [err=%v]")
+				}
+
+				projectId = workspaceFile.WorkspaceId
+			}
+
+			loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+			if err != nil {
+				util.HandleError(err, "unable to authenticate [err=%v]")
+			}
+
+			if loggedInUserDetails.LoginExpired {
+				util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+			}
+
+			tokenDetails = &models.TokenDetails{
+				Type:  "",
+				Token: loggedInUserDetails.UserCredentials.JTWToken,
+			}
+		}
+
+		secretOperations, approvals, err := util.PutRawSecrets(secretsToImport, secretType, environmentName, secretsPath, projectId, tokenDetails, onConflict, dryRun)
+		if secretOperations != nil {
+			printImportOperations(secretOperations, dryRun)
+		}
+		printOpenedChangeRequests(approvals)
+		if err != nil {
+			util.HandleError(err, "Unable to import secrets")
+		}
+
+		if dryRun {
+			fmt.Printf("Dry run, no secrets were changed in %s:%s\n", environmentName, secretsPath)
+		} else {
+			fmt.Printf("Imported %d secrets from %s into %s:%s\n", len(secretsToImport)-countRequestedOperations(secretOperations), filePath, environmentName, secretsPath)
+		}
+
+		Telemetry.CaptureEvent("cli-command:secrets import", posthog.NewProperties().Set("secretCount", len(secretsToImport)).Set("version", util.CLI_VERSION))
+	},
+}
+
+// values are left out, an import can hold hundreds of them
+func printImportOperations(secretOperations []models.SecretSetOperation, dryRun bool) {
+	status := "STATUS"
+	if dryRun {
+		status = "STATUS (DRY RUN)"
+	}
+
+	rows := [][]string{}
+	for _, secretOperation := range secretOperations {
+		rows = append(rows, []string{secretOperation.SecretKey, secretOperation.SecretOperation})
+	}
+
+	visualize.GenericTable([]string{"SECRET NAME", status}, rows)
+}
+
+// importFormatOf returns the format of the file to import, from the extension unless it's set
+func importFormatOf(filePath string, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".json":
+		return FormatJson
+	case ".yaml", ".yml":
+		return FormatYaml
+	default:
+		return FormatDotenv
+	}
+}
+
+// parseSecretsFile reads secrets in the dotenv format, a JSON or YAML object of names to values, or
+// the JSON written by export --format=json
+func parseSecretsFile(content []byte, format string) ([]models.SingleEnvironmentVariable, error) {
+	var secrets []models.SingleEnvironmentVariable
+	var err error
+
+	switch format {
+	case FormatDotenv:
+		secrets, err = parseDotEnvSecrets(string(content))
+	case FormatJson:
+		secrets, err = parseJsonSecrets(content)
+	case FormatYaml:
+		values := map[string]interface{}{}
+		if err := yaml.Unmarshal(content, &values); err != nil {
+			return nil, err
+		}
+		secrets, err = secretsFromMap(values)
+	default:
+		return nil, fmt.Errorf("unsupported format %s, use %s, %s or %s", format, FormatDotenv, FormatJson, FormatYaml)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, secret := range secrets {
+		if secret.Key == "" || unicode.IsNumber(rune(secret.Key[0])) {
+			return nil, fmt.Errorf("invalid secret name %q, names can't be empty or start with a number", secret.Key)
+		}
+		if seen[secret.Key] {
+			return nil, fmt.Errorf("secret %s is defined more than once", secret.Key)
+		}
+		seen[secret.Key] = true
+	}
+
+	return secrets, nil
+}
+
+func parseJsonSecrets(content []byte) ([]models.SingleEnvironmentVariable, error) {
+	// the output of export --format=json
+	var exported []models.SingleEnvironmentVariable
+	if err := json.Unmarshal(content, &exported); err == nil {
+		secrets := []models.SingleEnvironmentVariable{}
+		for _, secret := range exported {
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: secret.Key, Value: secret.Value})
+		}
+		return secrets, nil
+	}
+
+	// numbers are kept as written, a float64 would turn long ones into exponents
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+
+	values := map[string]interface{}{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, errors.New("expected a JSON object of secret names to values")
+	}
+	return secretsFromMap(values)
+}
+
+// secretsFromMap accepts strings, numbers and booleans as values, nested values have no secret to
+// map to
+func secretsFromMap(values map[string]interface{}) ([]models.SingleEnvironmentVariable, error) {
+	secrets := []models.SingleEnvironmentVariable{}
+	for key, value := range values {
+		switch typed := value.(type) {
+		case string:
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: typed})
+		case nil:
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: ""})
+		case bool, int, int64, uint64, float64, json.Number:
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: fmt.Sprint(typed)})
+		default:
+			return nil, fmt.Errorf("the value of %s is not a string, nested values can't be imported", key)
+		}
+	}
+
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Key < secrets[j].Key })
+	return secrets, nil
+}
+
+var dotEnvKeyRegex = regexp.MustCompile(`^(?:export\s+)?([A-Za-z_][A-Za-z0-9_.-]*)\s*=\s*`)
+
+// parseDotEnvSecrets reads a dotenv file. Single quoted values are taken literally, double quoted
+// values support \n, \", \\ escapes, and both may span several lines. Unquoted values end at a
+// comment.
+func parseDotEnvSecrets(content string) ([]models.SingleEnvironmentVariable, error) {
+	secrets := []models.SingleEnvironmentVariable{}
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+
+	lineNumber := 1
+	for len(content) > 0 {
+		line, rest, _ := strings.Cut(content, "\n")
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			content = rest
+			lineNumber++
+			continue
+		}
+
+		match := dotEnvKeyRegex.FindStringSubmatch(trimmed)
+		if match == nil {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+		key := match[1]
+
+		// the value continues from the original content, as quoted values can span lines
+		valueStart := strings.Index(content, trimmed) + len(match[0])
+		value := content[valueStart:]
+
+		if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			parsed, length, err := parseQuotedDotEnvValue(value[1:], quote)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: parsed})
+
+			consumed := value[:length+2]
+			lineNumber += strings.Count(consumed, "\n")
+			content = value[length+2:]
+
+			// skip whatever follows the closing quote on its line, usually a comment
+			_, content, _ = strings.Cut(content, "\n")
+			lineNumber++
+			continue
+		}
+
+		value, _, _ = strings.Cut(value, "\n")
+		if commentStart := strings.Index(value, " #"); commentStart >= 0 {
+			value = value[:commentStart]
+		}
+		secrets = append(secrets, models.SingleEnvironmentVariable{Key: key, Value: strings.TrimSpace(value)})
+
+		content = rest
+		lineNumber++
+	}
+
+	return secrets, nil
+}
+
+// parseQuotedDotEnvValue reads a value up to its closing quote, returning the value and the length of
+// the raw value without the quotes
+func parseQuotedDotEnvValue(raw string, quote byte) (string, int, error) {
+	var value strings.Builder
+	for i := 0; i < len(raw); i++ {
+		switch {
+		case raw[i] == quote:
+			return value.String(), i, nil
+		case quote == '"' && raw[i] == '\\' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 't':
+				value.WriteByte('\t')
+			case '"', '\\', '$':
+				value.WriteByte(raw[i])
+			default:
+				value.WriteByte('\\')
+				value.WriteByte(raw[i])
+			}
+		default:
+			value.WriteByte(raw[i])
+		}
+	}
+	return "", 0, fmt.Errorf("missing closing %c", quote)
+}
+
+func init() {
+	secretsImportCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsImportCmd.Flags().String("projectId", "", "manually set the project ID to import secrets into when using machine identity based auth")
+	secretsImportCmd.Flags().String("path", "/", "import secrets into a folder path")
+	secretsImportCmd.Flags().String("type", util.SECRET_TYPE_SHARED, "the type of secrets to create: personal or shared")
+	secretsImportCmd.Flags().StringP("file", "f", "", "the dotenv, JSON or YAML file to import")
+	secretsImportCmd.Flags().String("format", "", "the format of the file: dotenv, json or yaml. Defaults to the file extension, and dotenv for any other")
+	secretsImportCmd.Flags().String("on-conflict", util.SECRET_CONFLICT_OVERWRITE, "what to do with secrets that already exist with another value: overwrite, skip or fail")
+	secretsImportCmd.Flags().Bool("dry-run", false, "only show what would be imported")
+	secretsImportCmd.MarkFlagRequired("file")
+	secretsCmd.AddCommand(secretsImportCmd)
+}
@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSecretsFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		expected []models.SingleEnvironmentVariable
+	}{
+		{
+			name:   "dotenv",
+			format: FormatDotenv,
+			input:  "# comment\nexport A=1\nB='literal $x \\n' # trailing\nC=\"line1\\nline2 \\\"quoted\\\"\"\nD=\"multi\nline\"\nE=http://host#fragment # comment\nF=\n",
+			expected: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "1"},
+				{Key: "B", Value: "literal $x \\n"},
+				{Key: "C", Value: "line1\nline2 \"quoted\""},
+				{Key: "D", Value: "multi\nline"},
+				{Key: "E", Value: "http://host#fragment"},
+				{Key: "F", Value: ""},
+			},
+		},
+		{
+			name:   "json object",
+			format: FormatJson,
+			input:  `{"B": "two", "A": 1, "C": true}`,
+			expected: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "1"},
+				{Key: "B", Value: "two"},
+				{Key: "C", Value: "true"},
+			},
+		},
+		{
+			name:   "json from export",
+			format: FormatJson,
+			input:  `[{"key": "A", "value": "one", "type": "shared"}]`,
+			expected: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "one"},
+			},
+		},
+		{
+			name:   "yaml",
+			format: FormatYaml,
+			input:  "A: one\nB: |\n  multi\n  line\n",
+			expected: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "one"},
+				{Key: "B", Value: "multi\nline\n"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parseSecretsFile([]byte(tt.input), tt.format)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestParseSecretsFileErrors(t *testing.T) {
+	_, err := parseSecretsFile([]byte("A=\"unterminated\nB=1"), FormatDotenv)
+	assert.Error(t, err)
+
+	_, err = parseSecretsFile([]byte("A=1\nA=2"), FormatDotenv)
+	assert.Error(t, err)
+
+	_, err = parseSecretsFile([]byte(`{"A": {"nested": "value"}}`), FormatJson)
+	assert.Error(t, err)
+}
+
+func TestPutRawSecretsComparesRawValues(t *testing.T) {
+	server := newSecretsTestServer(t, map[string][]testSecret{
+		"dev:/": {
+			{Key: "DB_HOST", Value: "db.internal"},
+			{Key: "DB_URL", Value: "postgres://${DB_HOST}/app"},
+			{Key: "LOG_LEVEL", Value: "info"},
+		},
+	})
+	tokenDetails := &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+
+	secretsToSet := []models.SingleEnvironmentVariable{
+		{Key: "DB_URL", Value: "postgres://${DB_HOST}/app"},
+		{Key: "LOG_LEVEL", Value: "debug"},
+		{Key: "PORT", Value: "8080"},
+	}
+
+	operations, _, err := util.PutRawSecrets(secretsToSet, util.SECRET_TYPE_SHARED, "dev", "/", "project", tokenDetails, util.SECRET_CONFLICT_SKIP, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.SecretSetOperation{
+		{SecretKey: "DB_URL", SecretValue: "postgres://${DB_HOST}/app", SecretOperation: "SECRET VALUE UNCHANGED"},
+		{SecretKey: "LOG_LEVEL", SecretValue: "info", SecretOperation: "SECRET SKIPPED, ALREADY EXISTS"},
+		{SecretKey: "PORT", SecretValue: "8080", SecretOperation: "SECRET CREATED"},
+	}, operations)
+
+	_, _, err = util.PutRawSecrets(secretsToSet[:1], util.SECRET_TYPE_SHARED, "dev", "/", "project", tokenDetails, util.SECRET_CONFLICT_FAIL, true)
+	assert.NoError(t, err, "a reference that is unchanged isn't a conflict")
+
+	for _, request := range server.receivedRequests() {
+		assert.Empty(t, request["expandSecretReferences"])
+		assert.Empty(t, request["include_imports"])
+	}
+}
+
+func TestPutRawSecretsGoesThroughChangeRequests(t *testing.T) {
+	tests := []struct {
+		name           string
+		protected      bool
+		wantOperations []string
+		wantApprovals  int
+	}{
+		{
+			name:           "unprotected folder",
+			wantOperations: []string{"SECRET VALUE MODIFIED", "SECRET CREATED"},
+		},
+		{
+			name:           "protected folder",
+			protected:      true,
+			wantOperations: []string{"SECRET MODIFICATION REQUESTED", "SECRET CREATION REQUESTED"},
+			wantApprovals:  2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newSecretsTestServer(t, map[string][]testSecret{
+				"dev:/": {{Key: "LOG_LEVEL", Value: "info"}},
+			})
+			server.protected = test.protected
+			tokenDetails := &models.TokenDetails{Type: util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER, Token: "token"}
+
+			operations, approvals, err := util.PutRawSecrets([]models.SingleEnvironmentVariable{
+				{Key: "LOG_LEVEL", Value: "debug"},
+				{Key: "PORT", Value: "8080"},
+			}, util.SECRET_TYPE_SHARED, "dev", "/", "project", tokenDetails, util.SECRET_CONFLICT_OVERWRITE, false)
+
+			assert.NoError(t, err)
+			gotOperations := []string{}
+			for _, operation := range operations {
+				gotOperations = append(gotOperations, operation.SecretOperation)
+			}
+			assert.Equal(t, test.wantOperations, gotOperations)
+			assert.Len(t, approvals, test.wantApprovals)
+			assert.Equal(t, test.wantApprovals, countRequestedOperations(operations))
+			assert.Equal(t, []string{http.MethodPost, http.MethodPatch}, server.receivedBatchWrites())
+		})
+	}
+}
@@ -0,0 +1,261 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+)
+
+const (
+	overrideStatusDiffers  = "overrides the shared value"
+	overrideStatusSame     = "same as the shared value"
+	overrideStatusNoShared = "no shared secret"
+)
+
+var secretsOverrideCmd = &cobra.Command{
+	Example: `
+	infisical secrets override set API_URL=http://localhost:8080
+	infisical secrets override list --values=hashed
+	infisical secrets override clear API_URL
+	infisical secrets override clear --all`,
+	Short:                 "Used to manage your personal overrides of shared secrets",
+	Long:                  "Manage your personal overrides of shared secrets. Overrides only apply to you, and replace the shared value of a secret in run, export and secrets. Use --no-override with run and export to ignore them.",
+	Use:                   "override",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+}
+
+var secretsOverrideSetCmd = &cobra.Command{
+	Example:               `secrets override set <secretName=secretValue> <secretName=secretValue>...`,
+	Short:                 "Used to set your personal overrides of secrets",
+	Use:                   "set [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, secretsPath, projectId, tokenDetails := getOverrideContext(cmd)
+
+		secretOperations, _, err := util.SetRawSecrets(args, util.SECRET_TYPE_PERSONAL, environmentName, secretsPath, projectId, tokenDetails)
+		if err != nil {
+			util.HandleError(err, "Unable to set your overrides")
+		}
+
+		rows := [][]string{}
+		for _, secretOperation := range secretOperations {
+			rows = append(rows, []string{secretOperation.SecretKey, strings.Replace(secretOperation.SecretOperation, "SECRET", "OVERRIDE", 1)})
+		}
+		visualize.GenericTable([]string{"SECRET NAME", "STATUS"}, rows)
+
+		Telemetry.CaptureEvent("cli-command:secrets override set", posthog.NewProperties().Set("secretCount", len(secretOperations)).Set("version", util.CLI_VERSION))
+	},
+}
+
+var secretsOverrideListCmd = &cobra.Command{
+	Example:               `secrets override list --env=dev --values=hashed`,
+	Short:                 "Used to list your personal overrides and how they differ from the shared values",
+	Use:                   "list",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, secretsPath, projectId, _ := getOverrideContext(cmd)
+
+		valuesMode, err := cmd.Flags().GetString("values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if valuesMode != diffValuesHidden && valuesMode != diffValuesHashed && valuesMode != diffValuesPlain {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--values must be one of %s, %s or %s", diffValuesHidden, diffValuesHashed, diffValuesPlain))
+		}
+
+		secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{
+			Environment: environmentName,
+			WorkspaceId: projectId,
+			SecretsPath: secretsPath,
+		}, "")
+		if err != nil {
+			util.HandleError(err, "Unable to fetch secrets")
+		}
+
+		overrides := listPersonalOverrides(secrets)
+		if len(overrides) == 0 {
+			fmt.Printf("You have no overrides in %s:%s\n", environmentName, secretsPath)
+			return
+		}
+
+		headers := []string{"SECRET NAME", "STATUS"}
+		if valuesMode != diffValuesHidden {
+			headers = append(headers, "SHARED VALUE", "YOUR VALUE")
+		}
+
+		rows := [][]string{}
+		for _, override := range overrides {
+			row := []string{override.Key, override.Status}
+			if valuesMode != diffValuesHidden {
+				row = append(row, displayDiffValue(override.BaseValue, valuesMode), displayDiffValue(override.NewValue, valuesMode))
+			}
+			rows = append(rows, row)
+		}
+		visualize.GenericTable(headers, rows)
+	},
+}
+
+var secretsOverrideClearCmd = &cobra.Command{
+	Example: `
+	secrets override clear <secret name A> <secret name B>...
+	secrets override clear --all`,
+	Short:                 "Used to remove your personal overrides, so the shared values apply to you again",
+	Use:                   "clear [secrets]",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.ArbitraryArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, secretsPath, projectId, tokenDetails := getOverrideContext(cmd)
+
+		clearAll, err := cmd.Flags().GetBool("all")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if clearAll == (len(args) > 0) {
+			util.PrintErrorMessageAndExit("Give the names of the overrides to clear, or --all")
+		}
+
+		if clearAll {
+			secrets, err := util.GetAllEnvironmentVariables(models.GetAllSecretsParameters{
+				Environment: environmentName,
+				WorkspaceId: projectId,
+				SecretsPath: secretsPath,
+			}, "")
+			if err != nil {
+				util.HandleError(err, "Unable to fetch secrets")
+			}
+
+			for _, override := range listPersonalOverrides(secrets) {
+				args = append(args, override.Key)
+			}
+			if len(args) == 0 {
+				fmt.Printf("You have no overrides in %s:%s\n", environmentName, secretsPath)
+				return
+			}
+		}
+
+		httpClient := api.NewHTTPClient().
+			SetAuthToken(tokenDetails.Token).
+			SetHeader("Accept", "application/json")
+
+		for _, secretName := range args {
+			err := api.CallDeleteSecretsRawV3(httpClient, api.DeleteSecretV3Request{
+				WorkspaceId: projectId,
+				Environment: environmentName,
+				SecretName:  secretName,
+				Type:        util.SECRET_TYPE_PERSONAL,
+				SecretPath:  secretsPath,
+			})
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to clear your override of %s", secretName))
+			}
+		}
+
+		fmt.Printf("Cleared your overrides of [%v], the shared values apply to you again\n", strings.Join(args, ", "))
+
+		Telemetry.CaptureEvent("cli-command:secrets override clear", posthog.NewProperties().Set("secretCount", len(args)).Set("version", util.CLI_VERSION))
+	},
+}
+
+// getOverrideContext returns the folder the override commands work on, and the credentials of the
+// logged in user. Overrides belong to users, so machine identities and service tokens have none.
+func getOverrideContext(cmd *cobra.Command) (string, string, string, *models.TokenDetails) {
+	environmentName, _ := cmd.Flags().GetString("env")
+	if !cmd.Flags().Changed("env") {
+		environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+		if environmentFromWorkspace != "" {
+			environmentName = environmentFromWorkspace
+		}
+	}
+
+	secretsPath, err := cmd.Flags().GetString("path")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	projectId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	util.RequireLogin()
+	util.RequireLocalWorkspaceFile()
+
+	if projectId == "" {
+		workspaceFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.HandleError(err, "Unable to get local project details")
+		}
+		projectId = workspaceFile.WorkspaceId
+	}
+
+	loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
+	if err != nil {
+		util.HandleError(err, "Unable to authenticate")
+	}
+
+	if loggedInUserDetails.LoginExpired {
+		util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+	}
+
+	return environmentName, secretsPath, projectId, &models.TokenDetails{Token: loggedInUserDetails.UserCredentials.JTWToken}
+}
+
+// listPersonalOverrides compares each personal secret with the shared secret of the same name,
+// sorted by key
+func listPersonalOverrides(secrets []models.SingleEnvironmentVariable) []secretDifference {
+	sharedValues := map[string]string{}
+	for _, secret := range secrets {
+		if secret.Type != util.SECRET_TYPE_PERSONAL {
+			sharedValues[secret.Key] = secret.Value
+		}
+	}
+
+	overrides := []secretDifference{}
+	for _, secret := range secrets {
+		if secret.Type != util.SECRET_TYPE_PERSONAL {
+			continue
+		}
+
+		personalValue := secret.Value
+		override := secretDifference{Key: secret.Key, Status: overrideStatusNoShared, NewValue: &personalValue}
+		if sharedValue, ok := sharedValues[secret.Key]; ok {
+			override.BaseValue = &sharedValue
+			override.Status = overrideStatusDiffers
+			if sharedValue == personalValue {
+				override.Status = overrideStatusSame
+			}
+		}
+		overrides = append(overrides, override)
+	}
+
+	sort.Slice(overrides, func(i, j int) bool { return overrides[i].Key < overrides[j].Key })
+	return overrides
+}
+
+func init() {
+	secretsOverrideCmd.PersistentFlags().String("path", "/", "the folder path of the overrides")
+	secretsOverrideCmd.PersistentFlags().String("projectId", "", "manually set the project ID of the overrides")
+
+	secretsOverrideListCmd.Flags().String("values", diffValuesHidden, "show the shared and your values: hidden, hashed or plain")
+	secretsOverrideCmd.AddCommand(secretsOverrideListCmd)
+
+	secretsOverrideCmd.AddCommand(secretsOverrideSetCmd)
+
+	secretsOverrideClearCmd.Flags().Bool("all", false, "clear all your overrides of the folder")
+	secretsOverrideCmd.AddCommand(secretsOverrideClearCmd)
+
+	secretsCmd.AddCommand(secretsOverrideCmd)
+}
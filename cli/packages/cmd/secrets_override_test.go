@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPersonalOverrides(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "B", Value: "shared", Type: util.SECRET_TYPE_SHARED},
+		{Key: "B", Value: "mine", Type: util.SECRET_TYPE_PERSONAL},
+		{Key: "A", Value: "same", Type: util.SECRET_TYPE_SHARED},
+		{Key: "A", Value: "same", Type: util.SECRET_TYPE_PERSONAL},
+		{Key: "C", Value: "only mine", Type: util.SECRET_TYPE_PERSONAL},
+		{Key: "D", Value: "not overridden", Type: util.SECRET_TYPE_SHARED},
+	}
+
+	overrides := listPersonalOverrides(secrets)
+
+	assert.Len(t, overrides, 3)
+	assert.Equal(t, "A", overrides[0].Key)
+	assert.Equal(t, overrideStatusSame, overrides[0].Status)
+	assert.Equal(t, overrideStatusDiffers, overrides[1].Status)
+	assert.Equal(t, "shared", *overrides[1].BaseValue)
+	assert.Equal(t, "mine", *overrides[1].NewValue)
+	assert.Equal(t, overrideStatusNoShared, overrides[2].Status)
+	assert.Nil(t, overrides[2].BaseValue)
+}
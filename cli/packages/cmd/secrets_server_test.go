@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+)
+
+// testSecret is a secret as the raw secrets endpoint returns it
+type testSecret struct {
+	Key     string
+	Value   string
+	Type    string
+	Tags    []string
+	Comment string
+}
+
+// secretsTestServer serves the raw secrets endpoint from secrets keyed by "environment:path", and
+// records the query of every request it receives. Batch writes are accepted, and answered with a
+// change request when the server is protected
+type secretsTestServer struct {
+	*httptest.Server
+	mutex       sync.Mutex
+	secrets     map[string][]testSecret
+	requests    []map[string]string
+	batchWrites []string
+	protected   bool
+}
+
+// newSecretsTestServer starts a secrets server and points the CLI at it for the rest of the test
+func newSecretsTestServer(t *testing.T, secrets map[string][]testSecret) *secretsTestServer {
+	server := &secretsTestServer{secrets: secrets}
+	server.Server = httptest.NewServer(http.HandlerFunc(server.serveSecrets))
+	t.Cleanup(server.Close)
+
+	previousURL := config.INFISICAL_URL
+	t.Cleanup(func() { config.INFISICAL_URL = previousURL })
+	config.INFISICAL_URL = server.URL
+
+	return server
+}
+
+func (s *secretsTestServer) serveSecrets(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/v3/secrets/batch/raw" {
+		s.serveBatchWrite(w, r)
+		return
+	}
+	if r.Method != http.MethodGet || r.URL.Path != "/v3/secrets/raw" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	query := map[string]string{}
+	for key := range r.URL.Query() {
+		query[key] = r.URL.Query().Get(key)
+	}
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, query)
+	secrets := s.secrets[query["environment"]+":"+query["secretPath"]]
+	s.mutex.Unlock()
+
+	type tag struct {
+		Slug string `json:"slug"`
+	}
+	response := struct {
+		Secrets []map[string]interface{} `json:"secrets"`
+		Imports []interface{}            `json:"imports"`
+	}{Secrets: []map[string]interface{}{}, Imports: []interface{}{}}
+
+	for _, secret := range secrets {
+		secretType := secret.Type
+		if secretType == "" {
+			secretType = "shared"
+		}
+		tags := []tag{}
+		for _, slug := range secret.Tags {
+			tags = append(tags, tag{Slug: slug})
+		}
+		response.Secrets = append(response.Secrets, map[string]interface{}{
+			"secretKey":     secret.Key,
+			"secretValue":   secret.Value,
+			"secretComment": secret.Comment,
+			"type":          secretType,
+			"environment":   query["environment"],
+			"secretPath":    query["secretPath"],
+			"workspace":     query["workspaceId"],
+			"tags":          tags,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *secretsTestServer) serveBatchWrite(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.batchWrites = append(s.batchWrites, r.Method)
+	protected := s.protected
+	s.mutex.Unlock()
+
+	response := map[string]interface{}{"secrets": []interface{}{}}
+	if protected {
+		response["approval"] = map[string]interface{}{"id": "approval-id", "slug": "change-request", "status": "open"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// receivedBatchWrites returns the methods of the batch writes received so far
+func (s *secretsTestServer) receivedBatchWrites() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.batchWrites...)
+}
+
+// receivedRequests returns the queries of the requests received so far
+func (s *secretsTestServer) receivedRequests() []map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]map[string]string{}, s.requests...)
+}
@@ -0,0 +1,192 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/spf13/cobra"
+)
+
+const (
+	verifyStatusMissingInFile      = "missing in file"
+	verifyStatusMissingInInfisical = "missing in Infisical"
+	verifyStatusDiffers            = "differs"
+)
+
+var secretsVerifyCmd = &cobra.Command{
+	Example: `
+	infisical secrets verify --against=.env --env=dev
+	infisical secrets verify --against=config/.env.production --env=prod --path=/api --keys-only
+	infisical secrets verify --against=.env --ignore=LOCAL_DEBUG,PORT --output=json`,
+	Short:                 "Used to check a local dotenv, JSON or YAML file for drift from your secrets",
+	Long:                  "Check a local dotenv, JSON or YAML file for drift from your secrets. Secrets missing on either side and values that differ are reported, and the command exits with --exit-code when there are any, so it can guard migrations off checked-in files in CI. Values are compared but only shown as hashes, unless --values is set otherwise.",
+	Use:                   "verify",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		filePath, err := cmd.Flags().GetString("against")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		fileFormat, err := cmd.Flags().GetString("file-format")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		ignoredKeys, err := cmd.Flags().GetStringSlice("ignore")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldExpandSecrets, err := cmd.Flags().GetBool("expand")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeImports, err := cmd.Flags().GetBool("include-imports")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		keysOnly, err := cmd.Flags().GetBool("keys-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		valuesMode, err := cmd.Flags().GetString("values")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if valuesMode != diffValuesHidden && valuesMode != diffValuesHashed && valuesMode != diffValuesPlain {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("--values must be one of %s, %s or %s", diffValuesHidden, diffValuesHashed, diffValuesPlain))
+		}
+
+		outputFormat := getOutputFormat(cmd)
+
+		exitCode, err := cmd.Flags().GetInt("exit-code")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		content, err := os.ReadFile(filePath)
+		if err != nil {
+			util.HandleError(err, "Unable to read the file to verify")
+		}
+
+		fileSecrets, err := parseSecretsFile(content, importFormatOf(filePath, fileFormat))
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to parse %s", filePath))
+		}
+
+		request := models.GetAllSecretsParameters{
+			Environment:            environmentName,
+			WorkspaceId:            projectId,
+			SecretsPath:            secretsPath,
+			IncludeImport:          includeImports,
+			ExpandSecretReferences: shouldExpandSecrets,
+		}
+		if token != nil && token.Type == util.SERVICE_TOKEN_IDENTIFIER {
+			request.InfisicalToken = token.Token
+		} else if token != nil && token.Type == util.UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
+			request.UniversalAuthAccessToken = token.Token
+		}
+
+		infisicalSecrets, err := fetchSecretsForDiff(request)
+		if err != nil {
+			util.HandleError(err, fmt.Sprintf("Unable to fetch secrets of %s:%s", environmentName, secretsPath))
+		}
+
+		differences := verifySecretsFile(infisicalSecrets, fileSecrets, ignoredKeys, keysOnly)
+
+		infisicalLabel := fmt.Sprintf("%s:%s", environmentName, secretsPath)
+		printOutput(outputFormat, toSecretsDiffOutput(infisicalLabel, filePath, differences, valuesMode), func() {
+			printSecretsDiffAsTable(infisicalLabel, filePath, differences, valuesMode)
+		})
+
+		if len(differences) > 0 {
+			os.Exit(exitCode)
+		}
+	},
+}
+
+// verifySecretsFile returns the drift of a file from the secrets in Infisical, with statuses that
+// read from the point of view of the file
+func verifySecretsFile(infisicalSecrets map[string]string, fileSecrets []models.SingleEnvironmentVariable, ignoredKeys []string, keysOnly bool) []secretDifference {
+	ignored := map[string]bool{}
+	for _, key := range ignoredKeys {
+		ignored[key] = true
+	}
+
+	inInfisical := map[string]string{}
+	for key, value := range infisicalSecrets {
+		if !ignored[key] {
+			inInfisical[key] = value
+		}
+	}
+
+	inFile := map[string]string{}
+	for _, secret := range fileSecrets {
+		if !ignored[secret.Key] {
+			inFile[secret.Key] = secret.Value
+		}
+	}
+
+	differences := diffSecrets(inInfisical, inFile, keysOnly)
+	for i := range differences {
+		switch differences[i].Status {
+		case diffStatusRemoved:
+			differences[i].Status = verifyStatusMissingInFile
+		case diffStatusAdded:
+			differences[i].Status = verifyStatusMissingInInfisical
+		case diffStatusChanged:
+			differences[i].Status = verifyStatusDiffers
+		}
+	}
+	return differences
+}
+
+func init() {
+	secretsVerifyCmd.Flags().String("token", "", "Fetch secrets using service token or machine identity access token")
+	secretsVerifyCmd.Flags().String("projectId", "", "manually set the project ID to verify secrets of when using machine identity based auth")
+	secretsVerifyCmd.Flags().String("path", "/", "the folder path to verify the file against")
+	secretsVerifyCmd.Flags().String("against", "", "the dotenv, JSON or YAML file to verify")
+	secretsVerifyCmd.Flags().String("file-format", "", "the format of the file: dotenv, json or yaml. Defaults to the file extension, and dotenv for any other")
+	secretsVerifyCmd.Flags().StringSlice("ignore", []string{}, "comma separated secret names to leave out of the check")
+	secretsVerifyCmd.Flags().Bool("keys-only", false, "only check which secrets exist, not their values")
+	secretsVerifyCmd.Flags().String("values", diffValuesHashed, "show values of differing secrets: hidden, hashed or plain")
+	addOutputFlag(secretsVerifyCmd)
+	secretsVerifyCmd.Flags().Int("exit-code", 1, "exit code when the file has drifted")
+	secretsVerifyCmd.Flags().Bool("expand", true, "Parse shell parameter expansions in your secrets, and process your referenced secrets")
+	secretsVerifyCmd.Flags().Bool("include-imports", true, "Imported linked secrets ")
+	secretsVerifyCmd.MarkFlagRequired("against")
+	secretsCmd.AddCommand(secretsVerifyCmd)
+}
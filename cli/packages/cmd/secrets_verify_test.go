@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifySecretsFile(t *testing.T) {
+	infisicalSecrets := map[string]string{"A": "1", "B": "2", "C": "3", "IGNORED": "x"}
+	fileSecrets := []models.SingleEnvironmentVariable{
+		{Key: "A", Value: "1"},
+		{Key: "B", Value: "changed"},
+		{Key: "D", Value: "4"},
+	}
+
+	differences := verifySecretsFile(infisicalSecrets, fileSecrets, []string{"IGNORED"}, false)
+
+	statuses := map[string]string{}
+	for _, difference := range differences {
+		statuses[difference.Key] = difference.Status
+	}
+	assert.Equal(t, map[string]string{
+		"B": verifyStatusDiffers,
+		"C": verifyStatusMissingInFile,
+		"D": verifyStatusMissingInInfisical,
+	}, statuses)
+
+	differences = verifySecretsFile(infisicalSecrets, fileSecrets, []string{"IGNORED"}, true)
+	assert.Len(t, differences, 2)
+}
@@ -0,0 +1,283 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const INFISICAL_SHELL_ENV_NAME = "INFISICAL_SHELL"
+
+var shellCmd = &cobra.Command{
+	Example: `
+	infisical shell --env=dev
+	infisical shell --env=prod --path=/apps/api --shell=zsh
+	`,
+	Use:                   "shell",
+	Short:                 "Start an interactive shell with your secrets in its environment",
+	Long:                  "Start an interactive shell with your secrets as environment variables. The secrets only exist in the shell and the commands it runs, and are gone once you exit it.",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		environmentName, _ := cmd.Flags().GetString("env")
+		if !cmd.Flags().Changed("env") {
+			environmentFromWorkspace := util.GetEnvFromWorkspaceFile()
+			if environmentFromWorkspace != "" {
+				environmentName = environmentFromWorkspace
+			}
+		}
+
+		token, err := util.GetInfisicalToken(cmd)
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectConfigDir, err := cmd.Flags().GetString("project-config-dir")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		projectId, err := cmd.Flags().GetString("projectId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretsPath, err := cmd.Flags().GetString("path")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shouldExpandSecrets, err := cmd.Flags().GetBool("expand")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		includeImports, err := cmd.Flags().GetBool("include-imports")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		recursive, err := cmd.Flags().GetBool("recursive")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		secretOverriding, err := cmd.Flags().GetBool("secret-overriding")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagSlugs, err := cmd.Flags().GetString("tags")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		tagsMatch, err := cmd.Flags().GetString("tags-match")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		shellPath, err := cmd.Flags().GetString("shell")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if activeShell := os.Getenv(INFISICAL_SHELL_ENV_NAME); activeShell != "" {
+			util.PrintWarning(fmt.Sprintf("You are already in an Infisical shell for %s. Exit it first to avoid mixing secrets of both", activeShell))
+		}
+
+		request := models.GetAllSecretsParameters{
+			Environment:            environmentName,
+			WorkspaceId:            projectId,
+			TagSlugs:               tagSlugs,
+			TagsMatch:              tagsMatch,
+			SecretsPath:            secretsPath,
+			IncludeImport:          includeImports,
+			Recursive:              recursive,
+			ExpandSecretReferences: shouldExpandSecrets,
+		}
+
+		injectableEnvironment, err := fetchAndFormatSecretsForShell([]models.GetAllSecretsParameters{request}, projectConfigDir, secretOverriding, token, nil)
+		if err != nil {
+			util.HandleError(err, "Could not fetch secrets", "If you are using a service token to fetch secrets, please ensure it is valid")
+		}
+
+		if shellPath == "" {
+			shellPath = defaultInteractiveShell()
+		}
+
+		label := environmentName
+		if secretsPath != "/" {
+			label = environmentName + ":" + secretsPath
+		}
+
+		env := append(injectableEnvironment.Variables, INFISICAL_SHELL_ENV_NAME+"="+label)
+		shellArgs, env, err := withShellPrompt(shellPath, label, env)
+		if err != nil {
+			util.HandleError(err, "Unable to set up the shell prompt")
+		}
+
+		shell := exec.Command(shellPath, shellArgs...)
+		shell.Stdin = os.Stdin
+		shell.Stdout = os.Stdout
+		shell.Stderr = os.Stderr
+		shell.Env = env
+
+		fmt.Println(color.GreenString("Starting %s with %d Infisical secrets from %s. Exit the shell to remove them", filepath.Base(shellPath), injectableEnvironment.SecretsCount, label))
+
+		exitCode, err := runInteractiveShell(shell)
+		runCommandCleanups()
+		if err != nil {
+			util.HandleError(err, "Unable to start the shell")
+		}
+
+		fmt.Println(color.GreenString("Left the Infisical shell, its secrets are no longer in your environment"))
+		os.Exit(exitCode)
+	},
+}
+
+// runInteractiveShell runs the shell in the foreground and returns its exit code. Ctrl+C already
+// reaches the shell through the terminal, so the CLI only needs to stay alive until it exits.
+func runInteractiveShell(shell *exec.Cmd) (int, error) {
+	sigChannel := make(chan os.Signal, 1)
+	signal.Notify(sigChannel, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigChannel)
+
+	if err := shell.Start(); err != nil {
+		return 0, err
+	}
+
+	go func() {
+		for sig := range sigChannel {
+			if sig == syscall.SIGTERM {
+				_ = shell.Process.Signal(sig)
+			}
+		}
+	}()
+
+	// the exit code of an interactive shell is the one of the last command, not a failure of the shell
+	_ = shell.Wait()
+	return shell.ProcessState.ExitCode(), nil
+}
+
+func defaultInteractiveShell() string {
+	if runtime.GOOS == "windows" {
+		if comSpec := os.Getenv("COMSPEC"); comSpec != "" {
+			return comSpec
+		}
+		return "cmd.exe"
+	}
+
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "/bin/sh"
+}
+
+// withShellPrompt returns the arguments that start the shell interactively with its prompt prefixed
+// by the environment, so it's clear the shell holds secrets. bash and zsh get a startup file that
+// loads the user's own one first, since that usually sets the prompt, and fish and PowerShell wrap
+// the prompt function once their profile is loaded.
+func withShellPrompt(shellPath string, label string, env []string) ([]string, []string, error) {
+	prefix := fmt.Sprintf("(infisical:%s) ", label)
+	shellName := strings.TrimSuffix(strings.ToLower(filepath.Base(shellPath)), ".exe")
+
+	switch shellName {
+	case "bash":
+		rcDir, err := createShellRcDir()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		rcFile := filepath.Join(rcDir, "bashrc")
+		rc := fmt.Sprintf("[ -f ~/.bashrc ] && . ~/.bashrc\nPS1=%s\"$PS1\"\n", shellQuote(prefix))
+		if err := os.WriteFile(rcFile, []byte(rc), 0600); err != nil {
+			return nil, nil, err
+		}
+		return []string{"--rcfile", rcFile, "-i"}, env, nil
+
+	case "zsh":
+		rcDir, err := createShellRcDir()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		userZdotdir := os.Getenv("ZDOTDIR")
+		if userZdotdir == "" {
+			userZdotdir, _ = os.UserHomeDir()
+		}
+
+		rc := fmt.Sprintf("ZDOTDIR=%s\n[ -f \"$ZDOTDIR/.zshrc\" ] && . \"$ZDOTDIR/.zshrc\"\nPROMPT=%s\"$PROMPT\"\n", shellQuote(userZdotdir), shellQuote(prefix))
+		if err := os.WriteFile(filepath.Join(rcDir, ".zshrc"), []byte(rc), 0600); err != nil {
+			return nil, nil, err
+		}
+		return []string{"-i"}, append(env, "ZDOTDIR="+rcDir), nil
+
+	case "fish":
+		initCommand := fmt.Sprintf("functions -c fish_prompt __infisical_fish_prompt; function fish_prompt; echo -n %s; __infisical_fish_prompt; end", shellQuote(prefix))
+		return []string{"--interactive", "--init-command", initCommand}, env, nil
+
+	case "pwsh", "powershell":
+		command := fmt.Sprintf("$__infisicalPrompt = $function:prompt; function global:prompt { %s + (& $__infisicalPrompt) }", powerShellQuote(prefix))
+		return []string{"-NoLogo", "-NoExit", "-Command", command}, env, nil
+
+	case "cmd":
+		return nil, append(env, "PROMPT="+prefix+"$P$G"), nil
+
+	case "sh", "dash", "ash", "ksh", "mksh":
+		return []string{"-i"}, append(env, "PS1="+prefix+"$ "), nil
+
+	default:
+		// unknown shells are started as they are, with a prompt for those reading it from the environment
+		return nil, append(env, "PS1="+prefix+"$ "), nil
+	}
+}
+
+func createShellRcDir() (string, error) {
+	rcDir, err := os.MkdirTemp("", "infisical-shell-")
+	if err != nil {
+		return "", err
+	}
+
+	onCommandExit(func() {
+		os.RemoveAll(rcDir)
+	})
+	return rcDir, nil
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func powerShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func init() {
+	rootCmd.AddCommand(shellCmd)
+	shellCmd.Flags().String("token", "", "fetch secrets using service token or machine identity access token")
+	shellCmd.Flags().String("projectId", "", "manually set the project ID to fetch secrets from when using machine identity based auth")
+	shellCmd.Flags().StringP("env", "e", "dev", "set the environment (dev, prod, etc.) from which your secrets should be pulled from")
+	shellCmd.Flags().Bool("expand", true, "parse shell parameter expansions in your secrets")
+	shellCmd.Flags().Bool("include-imports", true, "import linked secrets")
+	shellCmd.Flags().Bool("recursive", false, "fetch secrets from all sub-folders")
+	shellCmd.Flags().Bool("secret-overriding", true, "prioritizes personal secrets, if any, with the same name over shared secrets")
+	shellCmd.Flags().StringP("tags", "t", "", "filter secrets by tag slugs")
+	shellCmd.Flags().String("tags-match", util.TAGS_MATCH_ANY, "with --tags, whether secrets need any or all of the tags (any, all)")
+	shellCmd.Flags().String("path", "/", "get secrets within a folder path")
+	shellCmd.Flags().String("project-config-dir", "", "explicitly set the directory where the .infisical.json resides")
+	shellCmd.Flags().String("shell", "", "the shell to start, defaults to $SHELL")
+}
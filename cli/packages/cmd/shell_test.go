@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithShellPrompt(t *testing.T) {
+	defer runCommandCleanups()
+
+	tests := []struct {
+		shellPath string
+		wantArgs  []string
+		wantEnv   []string
+		// arguments that hold a generated startup file, checked for the prompt instead
+		rcFileArg int
+	}{
+		{shellPath: "/bin/bash", wantArgs: []string{"--rcfile", "", "-i"}, rcFileArg: 1},
+		{shellPath: "/usr/bin/zsh", wantArgs: []string{"-i"}},
+		{
+			shellPath: "/usr/bin/fish",
+			wantArgs:  []string{"--interactive", "--init-command", "functions -c fish_prompt __infisical_fish_prompt; function fish_prompt; echo -n '(infisical:dev) '; __infisical_fish_prompt; end"},
+		},
+		{
+			shellPath: "/usr/local/bin/pwsh",
+			wantArgs:  []string{"-NoLogo", "-NoExit", "-Command", "$__infisicalPrompt = $function:prompt; function global:prompt { '(infisical:dev) ' + (& $__infisicalPrompt) }"},
+		},
+		{
+			shellPath: "powershell.exe",
+			wantArgs:  []string{"-NoLogo", "-NoExit", "-Command", "$__infisicalPrompt = $function:prompt; function global:prompt { '(infisical:dev) ' + (& $__infisicalPrompt) }"},
+		},
+		{shellPath: "cmd.exe", wantEnv: []string{"PROMPT=(infisical:dev) $P$G"}},
+		{shellPath: "/bin/sh", wantArgs: []string{"-i"}, wantEnv: []string{"PS1=(infisical:dev) $ "}},
+		{shellPath: "/bin/dash", wantArgs: []string{"-i"}, wantEnv: []string{"PS1=(infisical:dev) $ "}},
+		{shellPath: "/usr/bin/nu", wantEnv: []string{"PS1=(infisical:dev) $ "}},
+	}
+
+	for _, test := range tests {
+		t.Run(filepath.Base(test.shellPath), func(t *testing.T) {
+			args, env, err := withShellPrompt(test.shellPath, "dev", []string{"A=1"})
+			assert.NoError(t, err)
+
+			if test.rcFileArg > 0 {
+				rc, err := os.ReadFile(args[test.rcFileArg])
+				assert.NoError(t, err)
+				assert.Contains(t, string(rc), "PS1='(infisical:dev) '\"$PS1\"")
+				args[test.rcFileArg] = ""
+			}
+			assert.Equal(t, test.wantArgs, args)
+
+			if filepath.Base(test.shellPath) == "zsh" {
+				assert.Len(t, env, 2)
+				zdotdir := env[1][len("ZDOTDIR="):]
+				rc, err := os.ReadFile(filepath.Join(zdotdir, ".zshrc"))
+				assert.NoError(t, err)
+				assert.Contains(t, string(rc), "PROMPT='(infisical:dev) '\"$PROMPT\"")
+				return
+			}
+			assert.Equal(t, append([]string{"A=1"}, test.wantEnv...), env)
+		})
+	}
+}
+
+func TestShellQuoting(t *testing.T) {
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+	assert.Equal(t, `'it''s'`, powerShellQuote("it's"))
+}
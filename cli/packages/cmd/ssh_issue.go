@@ -0,0 +1,202 @@
+/*
+Copyright (c) 2023 Infisical Inc.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/posthog/posthog-go"
+	"github.com/spf13/cobra"
+
+	infisicalSdk "github.com/infisical/go-sdk"
+	infisicalSdkUtil "github.com/infisical/go-sdk/packages/util"
+)
+
+// The keys ssh issue signs when --key isn't set, in the order ssh-keygen prefers them
+var defaultSshUserKeys = []string{"~/.ssh/id_ed25519.pub", "~/.ssh/id_ecdsa.pub", "~/.ssh/id_rsa.pub"}
+var defaultSshHostKeys = []string{"/etc/ssh/ssh_host_ed25519_key.pub", "/etc/ssh/ssh_host_ecdsa_key.pub", "/etc/ssh/ssh_host_rsa_key.pub"}
+
+var sshIssueCmd = &cobra.Command{
+	Example: `infisical ssh issue --certificateTemplateId <template id>
+infisical ssh issue --certificateTemplateId <template id> --principal deploy --ttl 1h --agent
+sudo infisical ssh issue --certificateTemplateId <template id> --host`,
+	Short: "Used to get a short-lived SSH certificate for your key or host key",
+	Long: `Used to get a short-lived SSH certificate for your key or host key from an Infisical SSH CA. The key is
+the first of ~/.ssh/id_ed25519.pub, ~/.ssh/id_ecdsa.pub and ~/.ssh/id_rsa.pub, or of the host keys in
+/etc/ssh with --host, unless --key is set. The certificate is written next to it as <key>-cert.pub, where
+ssh finds it, and --agent also adds the key with the certificate to the SSH agent until it expires.
+
+The principals default to your username, or to the hostname with --host.`,
+	Use:                   "issue",
+	DisableFlagsInUseLine: true,
+	Args:                  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		infisicalToken := getAccessTokenOrLogin(cmd)
+
+		certificateTemplateId, err := cmd.Flags().GetString("certificateTemplateId")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if certificateTemplateId == "" {
+			util.PrintErrorMessageAndExit("You must set the --certificateTemplateId flag")
+		}
+
+		isHostCert, err := cmd.Flags().GetBool("host")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		principals, err := cmd.Flags().GetStringSlice("principal")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if len(principals) == 0 {
+			principals, err = getDefaultSshPrincipals(isHostCert)
+			if err != nil {
+				util.HandleError(err, "Unable to find the default principal, set it with --principal")
+			}
+		}
+
+		ttl, err := cmd.Flags().GetString("ttl")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		addToAgent, err := cmd.Flags().GetBool("agent")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		publicKeyPath, err := cmd.Flags().GetString("key")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+		if publicKeyPath == "" {
+			defaultKeys := defaultSshUserKeys
+			if isHostCert {
+				defaultKeys = defaultSshHostKeys
+			}
+			publicKeyPath, err = findSshPublicKey(defaultKeys)
+			if err != nil {
+				util.HandleError(err, "Unable to find an SSH key, set it with --key")
+			}
+		}
+		publicKeyPath, err = expandHomeDir(publicKeyPath)
+		if err != nil {
+			util.HandleError(err, "Failed to resolve home directory")
+		}
+		if !strings.HasSuffix(publicKeyPath, ".pub") {
+			publicKeyPath += ".pub"
+		}
+
+		publicKey, err := os.ReadFile(publicKeyPath)
+		if err != nil {
+			util.HandleError(err, "Failed to read public key file")
+		}
+
+		certType := infisicalSdkUtil.UserCert
+		if isHostCert {
+			certType = infisicalSdkUtil.HostCert
+		}
+
+		infisicalClient := newMachineIdentityLoginClient()
+		infisicalClient.Auth().SetAccessToken(infisicalToken)
+
+		creds, err := infisicalClient.Ssh().SignKey(infisicalSdk.SignSshPublicKeyOptions{
+			CertificateTemplateID: certificateTemplateId,
+			PublicKey:             strings.TrimSpace(string(publicKey)),
+			Principals:            principals,
+			CertType:              certType,
+			TTL:                   ttl,
+		})
+		if err != nil {
+			util.HandleError(err, "Failed to sign SSH public key")
+		}
+
+		signedKeyPath := strings.TrimSuffix(publicKeyPath, ".pub") + "-cert.pub"
+		if err := writeToFile(signedKeyPath, creds.SignedKey, 0644); err != nil {
+			util.HandleError(err, "Failed to write Signed Key to file")
+		}
+		fmt.Println("Successfully wrote SSH certificate to:", signedKeyPath)
+
+		if addToAgent {
+			privateKey, err := os.ReadFile(strings.TrimSuffix(publicKeyPath, ".pub"))
+			if err != nil {
+				util.HandleError(err, "Failed to read private key file")
+			}
+
+			if err := addCredentialsToAgent(string(privateKey), creds.SignedKey); err != nil {
+				util.HandleError(err, "Failed to add keys to SSH agent. For keys with a passphrase, run ssh-add instead, which also loads the certificate next to the key")
+			}
+			fmt.Println("The SSH key and certificate have been successfully added to your ssh-agent.")
+		}
+
+		Telemetry.CaptureEvent("cli-command:ssh issue", posthog.NewProperties().Set("certType", string(certType)).Set("version", util.CLI_VERSION))
+	},
+}
+
+// getDefaultSshPrincipals returns the username for user certificates, and the hostname for host
+// certificates
+func getDefaultSshPrincipals(isHostCert bool) ([]string, error) {
+	if isHostCert {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+		return []string{hostname}, nil
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return nil, err
+	}
+	// on Windows the username includes the domain
+	username := currentUser.Username
+	if _, name, found := strings.Cut(username, `\`); found {
+		username = name
+	}
+	return []string{username}, nil
+}
+
+// findSshPublicKey returns the first of the keys that exists
+func findSshPublicKey(candidates []string) (string, error) {
+	for _, candidate := range candidates {
+		path, err := expandHomeDir(candidate)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("none of %s exist", strings.Join(candidates, ", "))
+}
+
+func expandHomeDir(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~")), nil
+}
+
+func init() {
+	sshIssueCmd.Flags().String("token", "", "Issue the SSH certificate using machine identity access token")
+	sshIssueCmd.Flags().String("certificateTemplateId", "", "The ID of the SSH certificate template to issue the SSH certificate for")
+	sshIssueCmd.Flags().String("key", "", "The public key to sign, such as ~/.ssh/id_ed25519.pub. Defaults to your first SSH key, or host key with --host")
+	sshIssueCmd.Flags().StringSlice("principal", nil, "A principal of the certificate, can be repeated. Defaults to your username, or the hostname with --host")
+	sshIssueCmd.Flags().String("ttl", "", "The ttl of the certificate, such as 1h. Defaults to the one of the certificate template")
+	sshIssueCmd.Flags().Bool("host", false, "Issue a host certificate for the host key instead of a user certificate")
+	sshIssueCmd.Flags().Bool("agent", false, "Also add the key and certificate to the SSH agent until the certificate expires")
+	sshCmd.AddCommand(sshIssueCmd)
+}
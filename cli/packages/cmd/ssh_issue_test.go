@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindSshPublicKey(t *testing.T) {
+	dir := t.TempDir()
+	ecdsaKey := filepath.Join(dir, "id_ecdsa.pub")
+	assert.NoError(t, os.WriteFile(ecdsaKey, []byte("ecdsa-sha2-nistp256 AAAA"), 0644))
+
+	path, err := findSshPublicKey([]string{filepath.Join(dir, "id_ed25519.pub"), ecdsaKey, filepath.Join(dir, "id_rsa.pub")})
+	assert.NoError(t, err)
+	assert.Equal(t, ecdsaKey, path)
+
+	_, err = findSshPublicKey([]string{filepath.Join(dir, "id_rsa.pub")})
+	assert.Error(t, err)
+}
+
+func TestExpandHomeDir(t *testing.T) {
+	t.Setenv("HOME", "/home/dev")
+
+	path, err := expandHomeDir("~/.ssh/id_ed25519.pub")
+	assert.NoError(t, err)
+	assert.Equal(t, "/home/dev/.ssh/id_ed25519.pub", path)
+
+	path, err = expandHomeDir("/etc/ssh/ssh_host_ed25519_key.pub")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/ssh/ssh_host_ed25519_key.pub", path)
+}
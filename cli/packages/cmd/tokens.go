@@ -9,10 +9,12 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/crypto"
 	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/Infisical/infisical-merge/packages/visualize"
 	"github.com/go-resty/resty/v2"
 	"github.com/spf13/cobra"
 )
@@ -116,66 +118,289 @@ var tokensCreateCmd = &cobra.Command{
 			}
 		}
 
-		workspaceKey, err := util.GetPlainTextWorkspaceKey(loggedInUserDetails.UserCredentials.JTWToken, loggedInUserDetails.UserCredentials.PrivateKey, workspaceId)
+		serviceToken, err := createServiceToken(loggedInUserDetails, api.CreateServiceTokenRequest{
+			Name:        serviceTokenName,
+			WorkspaceId: workspaceId,
+			Scopes:      permissions,
+			ExpiresIn:   expireSeconds,
+			Permissions: accessLevels,
+		})
 		if err != nil {
-			util.HandleError(err, "Unable to get workspace key needed to create service token")
+			util.HandleError(err, "Unable to create service token")
+		}
+
+		if tokenOnly {
+			fmt.Println(serviceToken)
+		} else {
+			printablePermission := []string{}
+			for _, permission := range permissions {
+				printablePermission = append(printablePermission, fmt.Sprintf("([environment: %v] [path: %v])", permission.Environment, permission.SecretPath))
+			}
+
+			fmt.Printf("New service token created\n")
+			fmt.Printf("Name: %v\n", serviceTokenName)
+			fmt.Printf("Project ID: %v\n", workspaceId)
+			fmt.Printf("Access type: [%v]\n", strings.Join(accessLevels, ", "))
+			fmt.Printf("Permission(s): %v\n", strings.Join(printablePermission, ", "))
+			fmt.Printf("Service Token: %v\n", serviceToken)
 		}
+	},
+}
+
+var tokensListCmd = &cobra.Command{
+	Use:                   "list",
+	Short:                 "Used to list the service tokens of a project",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical service-token list --projectId=<project id>",
+	Args:                  cobra.ExactArgs(0),
+	Run: func(cmd *cobra.Command, args []string) {
+		outputFormat := getOutputFormat(cmd)
+		httpClient, workspaceId := newServiceTokensHTTPClient(cmd)
 
-		newWorkspaceEncryptionKey := make([]byte, 16)
-		_, err = rand.Read(newWorkspaceEncryptionKey)
+		serviceTokensResponse, err := api.CallGetServiceTokensV2(httpClient, workspaceId)
 		if err != nil {
-			util.HandleError(err)
+			util.HandleError(err, "Unable to list service tokens")
 		}
 
-		newWorkspaceEncryptionKeyHexFormat := hex.EncodeToString(newWorkspaceEncryptionKey)
+		if outputFormat != OutputTable {
+			printOutput(outputFormat, toServiceTokensOutput(serviceTokensResponse.ServiceTokenData), nil)
+			return
+		}
 
-		// encrypt the workspace key symmetrically
-		encryptedDetails, err := crypto.EncryptSymmetric(workspaceKey, []byte(newWorkspaceEncryptionKeyHexFormat))
+		if len(serviceTokensResponse.ServiceTokenData) == 0 {
+			fmt.Println("No service tokens found")
+			return
+		}
+
+		rows := [][]string{}
+		for _, serviceTokenData := range serviceTokensResponse.ServiceTokenData {
+			scopes := []string{}
+			for _, scope := range serviceTokenData.Scopes {
+				scopes = append(scopes, fmt.Sprintf("%s:%s", scope.Environment, scope.SecretPath))
+			}
+
+			expiresAt := "never"
+			if serviceTokenData.ExpiresAt != nil {
+				expiresAt = serviceTokenData.ExpiresAt.Local().Format("2006-01-02 15:04")
+				if serviceTokenData.ExpiresAt.Before(time.Now()) {
+					expiresAt += " (expired)"
+				}
+			}
+
+			rows = append(rows, []string{serviceTokenData.ID, serviceTokenData.Name, strings.Join(scopes, ", "), strings.Join(serviceTokenData.Permissions, ", "), expiresAt})
+		}
+		visualize.GenericTable([]string{"ID", "NAME", "SCOPES", "ACCESS", "EXPIRES"}, rows)
+	},
+}
+
+var tokensRotateCmd = &cobra.Command{
+	Use:                   "rotate [service-token-id]",
+	Short:                 "Used to replace a service token with a new one of the same name, scopes and access, and revoke it",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical service-token rotate <service token id> --token-only",
+	Args:                  cobra.ExactArgs(1),
+	PreRun: func(cmd *cobra.Command, args []string) {
+		util.RequireLogin()
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		loggedInUserDetails, err := util.GetCurrentLoggedInUserDetails(true)
 		if err != nil {
-			util.HandleError(err)
+			util.HandleError(err, "Unable to retrieve your logged in your details. Please login in then try again")
 		}
 
-		// make a call to the api to save the encrypted symmetric key details
-		httpClient := resty.New()
-		httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
-			SetHeader("Accept", "application/json")
+		if loggedInUserDetails.LoginExpired {
+			util.PrintErrorMessageAndExit("Your login session has expired, please run [infisical login] and try again")
+		}
 
-		createServiceTokenResponse, err := api.CallCreateServiceToken(httpClient, api.CreateServiceTokenRequest{
-			Name:         serviceTokenName,
-			WorkspaceId:  workspaceId,
-			Scopes:       permissions,
-			ExpiresIn:    expireSeconds,
-			EncryptedKey: base64.StdEncoding.EncodeToString(encryptedDetails.CipherText),
-			Iv:           base64.StdEncoding.EncodeToString(encryptedDetails.Nonce),
-			Tag:          base64.StdEncoding.EncodeToString(encryptedDetails.AuthTag),
-			RandomBytes:  newWorkspaceEncryptionKeyHexFormat,
-			Permissions:  accessLevels,
-		})
+		tokenOnly, err := cmd.Flags().GetBool("token-only")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		workspaceId := serviceTokensProjectId(cmd)
+		httpClient := api.NewHTTPClient().
+			SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+			SetHeader("Accept", "application/json")
 
+		serviceTokensResponse, err := api.CallGetServiceTokensV2(httpClient, workspaceId)
 		if err != nil {
-			util.HandleError(err, "Unable to create service token")
+			util.HandleError(err, "Unable to list service tokens")
+		}
+
+		var oldServiceToken *api.ServiceTokenData
+		for i := range serviceTokensResponse.ServiceTokenData {
+			if serviceTokensResponse.ServiceTokenData[i].ID == args[0] {
+				oldServiceToken = &serviceTokensResponse.ServiceTokenData[i]
+			}
+		}
+		if oldServiceToken == nil {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("No service token with ID %s found in project %s", args[0], workspaceId))
+		}
+
+		// the new token lives as long as the old one was meant to, unless told otherwise
+		expireSeconds := 0
+		if oldServiceToken.ExpiresAt != nil {
+			expireSeconds = int(oldServiceToken.ExpiresAt.Sub(oldServiceToken.CreatedAt).Seconds())
+		}
+		if cmd.Flags().Changed("expiry-seconds") {
+			expireSeconds, err = cmd.Flags().GetInt("expiry-seconds")
+			if err != nil {
+				util.HandleError(err, "Unable to parse flag")
+			}
 		}
 
-		serviceToken := createServiceTokenResponse.ServiceToken + "." + newWorkspaceEncryptionKeyHexFormat
+		serviceToken, err := createServiceToken(loggedInUserDetails, api.CreateServiceTokenRequest{
+			Name:        oldServiceToken.Name,
+			WorkspaceId: workspaceId,
+			Scopes:      oldServiceToken.Scopes,
+			ExpiresIn:   expireSeconds,
+			Permissions: oldServiceToken.Permissions,
+		})
+		if err != nil {
+			util.HandleError(err, "Unable to create the new service token")
+		}
 
+		// the new token is printed before the old one is revoked, so it isn't lost when revoking fails
 		if tokenOnly {
 			fmt.Println(serviceToken)
 		} else {
-			printablePermission := []string{}
-			for _, permission := range permissions {
-				printablePermission = append(printablePermission, fmt.Sprintf("([environment: %v] [path: %v])", permission.Environment, permission.SecretPath))
-			}
-
-			fmt.Printf("New service token created\n")
-			fmt.Printf("Name: %v\n", serviceTokenName)
-			fmt.Printf("Project ID: %v\n", workspaceId)
-			fmt.Printf("Access type: [%v]\n", strings.Join(accessLevels, ", "))
-			fmt.Printf("Permission(s): %v\n", strings.Join(printablePermission, ", "))
+			fmt.Printf("Service token %v rotated\n", oldServiceToken.Name)
 			fmt.Printf("Service Token: %v\n", serviceToken)
 		}
+
+		err = api.CallDeleteServiceTokenV2(httpClient, oldServiceToken.ID)
+		if err != nil {
+			util.PrintWarning(fmt.Sprintf("Created the new service token, but could not revoke the old one [err=%v]. Revoke it with [infisical service-token revoke %s]", err, oldServiceToken.ID))
+			return
+		}
+
+		if !tokenOnly {
+			fmt.Println("The old token no longer works")
+		}
 	},
 }
 
+var tokensRevokeCmd = &cobra.Command{
+	Use:                   "revoke [service-token-id]",
+	Short:                 "Used to revoke service tokens",
+	DisableFlagsInUseLine: true,
+	Example:               "infisical service-token revoke <service token id>",
+	Args:                  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		httpClient, _ := newServiceTokensHTTPClient(cmd)
+
+		for _, serviceTokenId := range args {
+			err := api.CallDeleteServiceTokenV2(httpClient, serviceTokenId)
+			if err != nil {
+				util.HandleError(err, fmt.Sprintf("Unable to revoke service token %s", serviceTokenId))
+			}
+		}
+
+		fmt.Printf("Service token(s) [%v] have been revoked\n", strings.Join(args, ", "))
+	},
+}
+
+// createServiceToken creates a service token that can decrypt the project key, and returns the full token
+func createServiceToken(loggedInUserDetails util.LoggedInUserDetails, request api.CreateServiceTokenRequest) (string, error) {
+	// logins without the Infisical password, such as LDAP, have no private key to decrypt the project key with
+	if loggedInUserDetails.UserCredentials.PrivateKey == "" {
+		return "", fmt.Errorf("your login has no private key to decrypt the project key with. Log in with your Infisical email and password, or through the browser, to create service tokens")
+	}
+
+	workspaceKey, err := util.GetPlainTextWorkspaceKey(loggedInUserDetails.UserCredentials.JTWToken, loggedInUserDetails.UserCredentials.PrivateKey, request.WorkspaceId)
+	if err != nil {
+		return "", fmt.Errorf("unable to get workspace key needed to create service token: %w", err)
+	}
+
+	newWorkspaceEncryptionKey := make([]byte, 16)
+	_, err = rand.Read(newWorkspaceEncryptionKey)
+	if err != nil {
+		return "", err
+	}
+
+	newWorkspaceEncryptionKeyHexFormat := hex.EncodeToString(newWorkspaceEncryptionKey)
+
+	// encrypt the workspace key symmetrically
+	encryptedDetails, err := crypto.EncryptSymmetric(workspaceKey, []byte(newWorkspaceEncryptionKeyHexFormat))
+	if err != nil {
+		return "", err
+	}
+
+	request.EncryptedKey = base64.StdEncoding.EncodeToString(encryptedDetails.CipherText)
+	request.Iv = base64.StdEncoding.EncodeToString(encryptedDetails.Nonce)
+	request.Tag = base64.StdEncoding.EncodeToString(encryptedDetails.AuthTag)
+	request.RandomBytes = newWorkspaceEncryptionKeyHexFormat
+
+	// make a call to the api to save the encrypted symmetric key details
+	httpClient := api.NewHTTPClient()
+	httpClient.SetAuthToken(loggedInUserDetails.UserCredentials.JTWToken).
+		SetHeader("Accept", "application/json")
+
+	createServiceTokenResponse, err := api.CallCreateServiceToken(httpClient, request)
+	if err != nil {
+		return "", err
+	}
+
+	return createServiceTokenResponse.ServiceToken + "." + newWorkspaceEncryptionKeyHexFormat, nil
+}
+
+// newServiceTokensHTTPClient returns a client for managing the service tokens of a project, as the machine
+// identity of --token or the logged in user
+func newServiceTokensHTTPClient(cmd *cobra.Command) (*resty.Client, string) {
+	token, err := util.GetInfisicalToken(cmd)
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	return newOrganizationHTTPClient(token, "manage service tokens"), serviceTokensProjectId(cmd)
+}
+
+// serviceTokensProjectId returns the project of the --projectId flag, or else the linked project
+func serviceTokensProjectId(cmd *cobra.Command) string {
+	workspaceId, err := cmd.Flags().GetString("projectId")
+	if err != nil {
+		util.HandleError(err, "Unable to parse flag")
+	}
+
+	if workspaceId == "" {
+		configFile, err := util.GetWorkSpaceFromFile()
+		if err != nil {
+			util.PrintErrorMessageAndExit("Please either run infisical init to connect to a project or pass in project id with --projectId flag")
+		}
+		workspaceId = configFile.WorkspaceId
+	}
+
+	return workspaceId
+}
+
+type serviceTokenOutput struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Scopes      []api.ScopePermission `json:"scopes"`
+	Permissions []string              `json:"permissions"`
+	ExpiresAt   *time.Time            `json:"expiresAt"`
+	Expired     bool                  `json:"expired"`
+	LastUsed    time.Time             `json:"lastUsed"`
+	CreatedAt   time.Time             `json:"createdAt"`
+}
+
+func toServiceTokensOutput(serviceTokens []api.ServiceTokenData) []serviceTokenOutput {
+	output := []serviceTokenOutput{}
+	for _, serviceToken := range serviceTokens {
+		output = append(output, serviceTokenOutput{
+			ID:          serviceToken.ID,
+			Name:        serviceToken.Name,
+			Scopes:      serviceToken.Scopes,
+			Permissions: serviceToken.Permissions,
+			ExpiresAt:   serviceToken.ExpiresAt,
+			Expired:     serviceToken.ExpiresAt != nil && serviceToken.ExpiresAt.Before(time.Now()),
+			LastUsed:    serviceToken.LastUsed,
+			CreatedAt:   serviceToken.CreatedAt,
+		})
+	}
+	return output
+}
+
 func init() {
 	tokensCreateCmd.Flags().String("projectId", "", "The project ID you'd like to create the service token for. Default: will use linked Infisical project in .infisical.json")
 	tokensCreateCmd.Flags().StringSliceP("scope", "s", []string{}, "Environment and secret path. Example format: <env-slug>:<folder-path>")
@@ -186,5 +411,19 @@ func init() {
 
 	tokensCmd.AddCommand(tokensCreateCmd)
 
+	tokensListCmd.Flags().String("projectId", "", "The project ID to list service tokens of. Default: will use linked Infisical project in .infisical.json")
+	tokensListCmd.Flags().String("token", "", "List service tokens using a machine identity access token")
+	addOutputFlag(tokensListCmd)
+	tokensCmd.AddCommand(tokensListCmd)
+
+	tokensRotateCmd.Flags().String("projectId", "", "The project ID of the service token. Default: will use linked Infisical project in .infisical.json")
+	tokensRotateCmd.Flags().Bool("token-only", false, "When true, only the new service token will be printed")
+	tokensRotateCmd.Flags().IntP("expiry-seconds", "e", 0, "Set the new service token's expiration time in seconds from now. To never expire set to zero. Default: the lifetime of the old token")
+	tokensCmd.AddCommand(tokensRotateCmd)
+
+	tokensRevokeCmd.Flags().String("projectId", "", "The project ID of the service tokens. Default: will use linked Infisical project in .infisical.json")
+	tokensRevokeCmd.Flags().String("token", "", "Revoke service tokens using a machine identity access token")
+	tokensCmd.AddCommand(tokensRevokeCmd)
+
 	rootCmd.AddCommand(tokensCmd)
 }
@@ -5,13 +5,17 @@ package cmd
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
+	"github.com/Infisical/infisical-merge/packages/models"
 	"github.com/Infisical/infisical-merge/packages/util"
 	"github.com/posthog/posthog-go"
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
+	"github.com/zalando/go-keyring"
 )
 
 type VaultBackendType struct {
@@ -24,17 +28,37 @@ var AvailableVaults = []VaultBackendType{
 		Name:        "auto",
 		Description: "automatically select the system keyring",
 	},
+	{
+		Name:        "keychain",
+		Description: "macOS Keychain",
+	},
+	{
+		Name:        "wincred",
+		Description: "Windows Credential Manager",
+	},
+	{
+		Name:        "secret-service",
+		Description: "Secret Service on Linux, e.g. GNOME Keyring or KWallet",
+	},
+	{
+		Name:        "pass",
+		Description: "the pass password manager, encrypted with your GPG key",
+	},
 	{
 		Name:        "file",
-		Description: "encrypted file vault",
+		Description: "encrypted file vault, optionally protected with a passphrase that isn't stored",
 	},
 }
 
 var vaultSetCmd = &cobra.Command{
-	Example:               `infisical vault set file`,
-	Use:                   "set [file|auto]",
+	Example: `
+	infisical vault set file
+	infisical vault set file --passphrase
+	infisical vault set secret-service`,
+	Use:                   "set [auto|keychain|wincred|secret-service|pass|file]",
 	Short:                 "Used to configure the vault backends",
 	DisableFlagsInUseLine: true,
+	Annotations:           map[string]string{keyringCommandAnnotation: "true"},
 	Args:                  cobra.MinimumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		wantedVaultTypeName := args[0]
@@ -44,41 +68,188 @@ var vaultSetCmd = &cobra.Command{
 			return
 		}
 
-		if wantedVaultTypeName == string(currentVaultBackend) {
+		passphraseProtected, err := cmd.Flags().GetBool("passphrase")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if passphraseProtected && wantedVaultTypeName != util.VAULT_BACKEND_FILE_MODE {
+			log.Error().Msg("Only the file vault can be protected with a passphrase")
+			return
+		}
+
+		if !util.IsVaultBackendKnown(wantedVaultTypeName) {
+			var availableVaultsNames []string
+			for _, vault := range AvailableVaults {
+				availableVaultsNames = append(availableVaultsNames, vault.Name)
+			}
+			log.Error().Msgf("The requested vault type [%s] is not available on this system. Only the following vault backends are available for you system: %s", wantedVaultTypeName, strings.Join(availableVaultsNames, ", "))
+			return
+		}
+
+		if err := util.CheckVaultBackendAvailable(wantedVaultTypeName); err != nil {
+			log.Error().Msgf("Unable to set vault to [%s] because %s", wantedVaultTypeName, err)
+			return
+		}
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			log.Error().Msgf("Unable to set vault to [%s] because of [err=%s]", wantedVaultTypeName, err)
+			return
+		}
+
+		if wantedVaultTypeName == string(currentVaultBackend) && passphraseProtected == configFile.VaultPassphraseProtected {
 			log.Error().Msgf("You are already on vault backend [%s]", currentVaultBackend)
 			return
 		}
 
-		if wantedVaultTypeName == util.VAULT_BACKEND_AUTO_MODE || wantedVaultTypeName == util.VAULT_BACKEND_FILE_MODE {
-			configFile, err := util.GetConfigFile()
+		configFile.LoggedInUserEmail = ""
+		setVaultBackend(&configFile, wantedVaultTypeName, passphraseProtected)
+
+		err = util.WriteConfigFile(&configFile)
+		if err != nil {
+			log.Error().Msgf("Unable to set vault to [%s] because an error occurred when saving the config file [err=%s]", wantedVaultTypeName, err)
+			return
+		}
+
+		fmt.Printf("\nSuccessfully, switched vault backend from [%s] to [%s]. Please login in again to store your login details in the new vault with [infisical login]\n", currentVaultBackend, wantedVaultTypeName)
+		if passphraseProtected {
+			fmt.Printf("You will be asked for the vault passphrase when it's needed, or set it in the %s environment variable\n", util.INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME)
+		}
+		fmt.Println("To keep your logins, use [infisical vault migrate] instead")
+
+		Telemetry.CaptureEvent("cli-command:vault set", posthog.NewProperties().Set("currentVault", currentVaultBackend).Set("wantedVault", wantedVaultTypeName).Set("version", util.CLI_VERSION))
+	},
+}
+
+var vaultMigrateCmd = &cobra.Command{
+	Example: `
+	infisical vault migrate pass
+	infisical vault migrate file --passphrase`,
+	Use:                   "migrate [auto|keychain|wincred|secret-service|pass|file]",
+	Short:                 "Used to move your login details to another vault backend and switch to it",
+	DisableFlagsInUseLine: true,
+	Annotations:           map[string]string{keyringCommandAnnotation: "true"},
+	Args:                  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		wantedVaultTypeName := args[0]
+
+		passphraseProtected, err := cmd.Flags().GetBool("passphrase")
+		if err != nil {
+			util.HandleError(err, "Unable to parse flag")
+		}
+
+		if passphraseProtected && wantedVaultTypeName != util.VAULT_BACKEND_FILE_MODE {
+			util.PrintErrorMessageAndExit("Only the file vault can be protected with a passphrase")
+		}
+
+		if err := util.CheckVaultBackendAvailable(wantedVaultTypeName); err != nil {
+			util.HandleError(err, "Unable to migrate the vault")
+		}
+
+		currentVaultBackend, err := util.GetCurrentVaultBackend()
+		if err != nil {
+			util.HandleError(err, "Unable to get the current vault")
+		}
+
+		configFile, err := util.GetConfigFile()
+		if err != nil {
+			util.HandleError(err, "Unable to read the config file")
+		}
+
+		// re-encrypting the file vault with a passphrase is a migration as well
+		if wantedVaultTypeName == currentVaultBackend && (wantedVaultTypeName != util.VAULT_BACKEND_FILE_MODE || passphraseProtected == configFile.VaultPassphraseProtected) {
+			util.PrintErrorMessageAndExit(fmt.Sprintf("You are already on vault backend [%s]", currentVaultBackend))
+		}
+
+		values := map[string]string{}
+		for _, key := range getVaultKeys(configFile) {
+			value, err := util.GetValueInVaultBackend(currentVaultBackend, key)
+			if errors.Is(err, keyring.ErrNotFound) {
+				continue
+			}
 			if err != nil {
-				log.Error().Msgf("Unable to set vault to [%s] because of [err=%s]", wantedVaultTypeName, err)
-				return
+				util.HandleError(err, fmt.Sprintf("Unable to read [%s] from the %s vault", key, currentVaultBackend))
 			}
+			values[key] = value
+		}
 
-			configFile.VaultBackendType = wantedVaultTypeName
-			configFile.LoggedInUserEmail = ""
-			configFile.VaultBackendPassphrase = base64.StdEncoding.EncodeToString([]byte(util.GenerateRandomString(10)))
-
-			err = util.WriteConfigFile(&configFile)
+		migratedConfigFile := configFile
+		setVaultBackend(&migratedConfigFile, wantedVaultTypeName, passphraseProtected)
+		if passphraseProtected {
+			passphrase, err := util.AskForVaultPassphrase(true)
 			if err != nil {
-				log.Error().Msgf("Unable to set vault to [%s] because an error occurred when saving the config file [err=%s]", wantedVaultTypeName, err)
-				return
+				util.HandleError(err, "Unable to read the vault passphrase")
 			}
+			util.SetVaultFilePassphrase(passphrase)
+		}
 
-			fmt.Printf("\nSuccessfully, switched vault backend from [%s] to [%s]. Please login in again to store your login details in the new vault with [infisical login]\n", currentVaultBackend, wantedVaultTypeName)
+		err = util.WriteConfigFile(&migratedConfigFile)
+		if err != nil {
+			util.HandleError(err, "Unable to save the config file")
+		}
 
-			Telemetry.CaptureEvent("cli-command:vault set", posthog.NewProperties().Set("currentVault", currentVaultBackend).Set("wantedVault", wantedVaultTypeName).Set("version", util.CLI_VERSION))
-		} else {
-			var availableVaultsNames []string
-			for _, vault := range AvailableVaults {
-				availableVaultsNames = append(availableVaultsNames, vault.Name)
+		for key, value := range values {
+			if err := util.SetValueInVaultBackend(wantedVaultTypeName, key, value); err != nil {
+				// stay on the old vault, which still has every value
+				if restoreErr := util.WriteConfigFile(&configFile); restoreErr != nil {
+					log.Error().Msgf("Unable to restore the config file [err=%s]", restoreErr)
+				}
+				util.HandleError(err, fmt.Sprintf("Unable to store [%s] in the %s vault, nothing was migrated", key, wantedVaultTypeName))
+			}
+		}
+
+		if !util.IsSameVaultStorage(currentVaultBackend, wantedVaultTypeName) {
+			for key := range values {
+				if err := util.DeleteValueInVaultBackend(currentVaultBackend, key); err != nil {
+					log.Debug().Err(err).Msgf("unable to delete [%s] from the %s vault", key, currentVaultBackend)
+				}
 			}
-			log.Error().Msgf("The requested vault type [%s] is not available on this system. Only the following vault backends are available for you system: %s", wantedVaultTypeName, strings.Join(availableVaultsNames, ", "))
 		}
+
+		util.PrintSuccessMessage(fmt.Sprintf("Moved %d value(s) from the [%s] vault to the [%s] vault, which is now used", len(values), currentVaultBackend, wantedVaultTypeName))
+
+		Telemetry.CaptureEvent("cli-command:vault migrate", posthog.NewProperties().Set("currentVault", currentVaultBackend).Set("wantedVault", wantedVaultTypeName).Set("version", util.CLI_VERSION))
 	},
 }
 
+// setVaultBackend switches the config to a vault backend. The file vault gets a new random passphrase,
+// kept in the config file, unless it's passphrase protected.
+func setVaultBackend(configFile *models.ConfigFile, vaultBackend string, passphraseProtected bool) {
+	configFile.VaultBackendType = vaultBackend
+	configFile.VaultPassphraseProtected = passphraseProtected && vaultBackend == util.VAULT_BACKEND_FILE_MODE
+
+	if configFile.VaultPassphraseProtected {
+		configFile.VaultBackendPassphrase = ""
+	} else {
+		configFile.VaultBackendPassphrase = base64.StdEncoding.EncodeToString([]byte(util.GenerateRandomString(10)))
+	}
+}
+
+// getVaultKeys lists the keys the CLI may have stored in the vault: the login of every user and
+// profile, and the key that encrypts cached secrets
+func getVaultKeys(configFile models.ConfigFile) []string {
+	keys := []string{}
+	addKey := func(key string) {
+		if key != "" && !slices.Contains(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
+	addKey(configFile.LoggedInUserEmail)
+	for _, loggedInUser := range configFile.LoggedInUsers {
+		addKey(loggedInUser.Email)
+	}
+	for _, profile := range configFile.Profiles {
+		if profile.LoggedInUserEmail != "" {
+			addKey(util.ProfileKeyringKey(profile.Name, profile.LoggedInUserEmail))
+		}
+	}
+	addKey(util.INFISICAL_BACKUP_SECRET_ENCRYPTION_KEY)
+
+	return keys
+}
+
 // runCmd represents the run command
 var vaultCmd = &cobra.Command{
 	Use:                   "vault",
@@ -107,7 +278,11 @@ func printAvailableVaultBackends() {
 }
 
 func init() {
+	vaultSetCmd.Flags().Bool("passphrase", false, "with file, protect the vault with a passphrase that isn't stored, asked for when needed or read from INFISICAL_VAULT_FILE_PASSPHRASE")
 	vaultCmd.AddCommand(vaultSetCmd)
 
+	vaultMigrateCmd.Flags().Bool("passphrase", false, "with file, protect the vault with a passphrase that isn't stored, asked for when needed or read from INFISICAL_VAULT_FILE_PASSPHRASE")
+	vaultCmd.AddCommand(vaultMigrateCmd)
+
 	rootCmd.AddCommand(vaultCmd)
 }
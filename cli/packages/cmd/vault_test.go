@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/Infisical/infisical-merge/packages/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetVaultKeys(t *testing.T) {
+	configFile := models.ConfigFile{
+		LoggedInUserEmail: "jane@example.com",
+		LoggedInUsers: []models.LoggedInUser{
+			{Email: "jane@example.com"},
+			{Email: "john@example.com"},
+		},
+		Profiles: []models.Profile{
+			{Name: "staging", LoggedInUserEmail: "jane@example.com"},
+			{Name: "empty"},
+		},
+	}
+
+	assert.Equal(t, []string{
+		"jane@example.com",
+		"john@example.com",
+		"profile:staging:jane@example.com",
+		util.INFISICAL_BACKUP_SECRET_ENCRYPTION_KEY,
+	}, getVaultKeys(configFile))
+}
+
+func TestSetVaultBackend(t *testing.T) {
+	configFile := models.ConfigFile{VaultBackendPassphrase: "b2xk"}
+
+	setVaultBackend(&configFile, util.VAULT_BACKEND_FILE_MODE, true)
+	assert.True(t, configFile.VaultPassphraseProtected)
+	assert.Empty(t, configFile.VaultBackendPassphrase)
+
+	setVaultBackend(&configFile, util.VAULT_BACKEND_PASS_MODE, true)
+	assert.False(t, configFile.VaultPassphraseProtected)
+	assert.NotEmpty(t, configFile.VaultBackendPassphrase)
+}
@@ -3,3 +3,4 @@ package config
 var INFISICAL_URL string
 var INFISICAL_URL_MANUAL_OVERRIDE string
 var INFISICAL_LOGIN_URL string
+var INFISICAL_PROFILE string
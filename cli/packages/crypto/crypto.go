@@ -4,12 +4,24 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"fmt"
 	"io"
 
 	"github.com/Infisical/infisical-merge/packages/models"
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/nacl/box"
 )
 
+const passphraseKdfArgon2id = "argon2id"
+
+// bounds of the argon2id parameters read from encrypted files, so that decrypting a crafted file
+// can't take unbounded memory or time. They leave room above the parameters EncryptWithPassphrase uses.
+const (
+	maxPassphraseKdfTime        = 16
+	maxPassphraseKdfMemory      = 1024 * 1024 // KiB
+	maxPassphraseKdfParallelism = 32
+)
+
 // will decrypt cipher text to plain text using iv and tag
 func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]byte, error) {
 	// Case: empty string
@@ -17,6 +29,12 @@ func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]b
 		return []byte{}, nil
 	}
 
+	return DecryptSymmetricWithAdditionalData(key, cipherText, tag, iv, nil)
+}
+
+// DecryptSymmetricWithAdditionalData decrypts cipher text sealed by EncryptSymmetricWithAdditionalData,
+// and fails unless additionalData is the data it was sealed with
+func DecryptSymmetricWithAdditionalData(key []byte, cipherText []byte, tag []byte, iv []byte, additionalData []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, err
@@ -30,7 +48,7 @@ func DecryptSymmetric(key []byte, cipherText []byte, tag []byte, iv []byte) ([]b
 	var nonce = iv
 	var ciphertext = append(cipherText, tag...) // the aesgcm open method expects auth tag at the end of the cipher text
 
-	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, nil)
+	plaintext, err := aesgcm.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
 		return nil, err
 	}
@@ -46,6 +64,12 @@ func GenerateNewKey() (newKey []byte, keyErr error) {
 
 // Will encrypt a plain text with the provided key
 func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncryptionResult, err error) {
+	return EncryptSymmetricWithAdditionalData(plaintext, key, nil)
+}
+
+// EncryptSymmetricWithAdditionalData encrypts like EncryptSymmetric, and also authenticates
+// additionalData, which isn't stored but must be passed again to decrypt
+func EncryptSymmetricWithAdditionalData(plaintext []byte, key []byte, additionalData []byte) (result models.SymmetricEncryptionResult, err error) {
 	block, err := aes.NewCipher(key)
 	if err != nil {
 		return models.SymmetricEncryptionResult{}, err
@@ -62,7 +86,7 @@ func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncr
 		panic(err)
 	}
 
-	ciphertext := aesgcm.Seal(nil, nonce, plaintext, nil)
+	ciphertext := aesgcm.Seal(nil, nonce, plaintext, additionalData)
 
 	ciphertextOnly := ciphertext[:len(ciphertext)-16] // combines the auth tag with the cipher text so we need to extract it
 
@@ -75,6 +99,57 @@ func EncryptSymmetric(plaintext []byte, key []byte) (result models.SymmetricEncr
 	}, nil
 }
 
+// Will encrypt a plain text with a key derived from the passphrase with argon2id
+func EncryptWithPassphrase(plaintext []byte, passphrase string) (models.PassphraseEncryptionResult, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return models.PassphraseEncryptionResult{}, err
+	}
+
+	result := models.PassphraseEncryptionResult{
+		Kdf:         passphraseKdfArgon2id,
+		Salt:        salt,
+		Time:        3,
+		Memory:      64 * 1024,
+		Parallelism: 4,
+	}
+
+	key := argon2.IDKey([]byte(passphrase), result.Salt, result.Time, result.Memory, result.Parallelism, 32)
+	encrypted, err := EncryptSymmetric(plaintext, key)
+	if err != nil {
+		return models.PassphraseEncryptionResult{}, err
+	}
+
+	result.CipherText = encrypted.CipherText
+	result.Nonce = encrypted.Nonce
+	result.AuthTag = encrypted.AuthTag
+	return result, nil
+}
+
+// will decrypt the result of EncryptWithPassphrase, a wrong passphrase fails to authenticate
+func DecryptWithPassphrase(encrypted models.PassphraseEncryptionResult, passphrase string) ([]byte, error) {
+	if encrypted.Kdf != passphraseKdfArgon2id {
+		return nil, fmt.Errorf("unsupported key derivation function %q", encrypted.Kdf)
+	}
+
+	if encrypted.Time < 1 || encrypted.Time > maxPassphraseKdfTime {
+		return nil, fmt.Errorf("unsupported key derivation time %d, it must be between 1 and %d", encrypted.Time, maxPassphraseKdfTime)
+	}
+	if encrypted.Parallelism < 1 || encrypted.Parallelism > maxPassphraseKdfParallelism {
+		return nil, fmt.Errorf("unsupported key derivation parallelism %d, it must be between 1 and %d", encrypted.Parallelism, maxPassphraseKdfParallelism)
+	}
+	if encrypted.Memory > maxPassphraseKdfMemory {
+		return nil, fmt.Errorf("unsupported key derivation memory %d KiB, it must be at most %d KiB", encrypted.Memory, maxPassphraseKdfMemory)
+	}
+
+	key := argon2.IDKey([]byte(passphrase), encrypted.Salt, encrypted.Time, encrypted.Memory, encrypted.Parallelism, 32)
+	plaintext, err := DecryptSymmetric(key, encrypted.CipherText, encrypted.AuthTag, encrypted.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt, the passphrase may be wrong [err=%v]", err)
+	}
+	return plaintext, nil
+}
+
 func DecryptAsymmetric(ciphertext []byte, nonce []byte, publicKey []byte, privateKey []byte) (plainText []byte) {
 	plainTextToReturn, _ := box.Open(nil, ciphertext, (*[24]byte)(nonce), (*[32]byte)(publicKey), (*[32]byte)(privateKey))
 	return plainTextToReturn
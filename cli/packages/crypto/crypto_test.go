@@ -0,0 +1,44 @@
+package crypto
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecryptWithPassphrase(t *testing.T) {
+	encrypted, err := EncryptWithPassphrase([]byte("DB_PASSWORD=hunter2"), "correct horse")
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		passphrase string
+		modify     func(encrypted *models.PassphraseEncryptionResult)
+		wantErr    string
+	}{
+		{name: "right passphrase", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) {}},
+		{name: "wrong passphrase", passphrase: "wrong", modify: func(encrypted *models.PassphraseEncryptionResult) {}, wantErr: "the passphrase may be wrong"},
+		{name: "other kdf", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Kdf = "scrypt" }, wantErr: `unsupported key derivation function "scrypt"`},
+		{name: "no time", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Time = 0 }, wantErr: "unsupported key derivation time 0"},
+		{name: "too much time", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Time = 1 << 20 }, wantErr: "unsupported key derivation time"},
+		{name: "too much memory", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Memory = 1 << 31 }, wantErr: "unsupported key derivation memory"},
+		{name: "no parallelism", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Parallelism = 0 }, wantErr: "unsupported key derivation parallelism 0"},
+		{name: "too much parallelism", passphrase: "correct horse", modify: func(encrypted *models.PassphraseEncryptionResult) { encrypted.Parallelism = 255 }, wantErr: "unsupported key derivation parallelism 255"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			modified := encrypted
+			test.modify(&modified)
+
+			plaintext, err := DecryptWithPassphrase(modified, test.passphrase)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "DB_PASSWORD=hunter2", string(plaintext))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pion/stun/v3"
+)
+
+// errAllocationLost means the relay no longer holds the allocation of the gateway, e.g. because it
+// expired while the relay couldn't be refreshed. The gateway then allocates a new relay address and
+// exchanges its certificate for it right away, which registers the new address with Infisical.
+var errAllocationLost = errors.New("the relay allocation expired or was lost")
+
+// allocationMismatchPattern matches the error code of STUN error responses as pion/turn formats them
+// into its errors, "<method> error response (error 437: <reason>)", as it doesn't wrap them
+var allocationMismatchPattern = regexp.MustCompile(fmt.Sprintf(`\(error %d: [^)]*\)`, stun.CodeAllocMismatch))
+
+// isAllocationMismatch reports whether the relay answered with 437 Allocation Mismatch, which TURN
+// servers return for requests on an allocation they no longer have
+func isAllocationMismatch(err error) bool {
+	if err == nil {
+		return false
+	}
+	message := err.Error()
+	return allocationMismatchPattern.MatchString(message) || strings.Contains(strings.ToLower(message), "allocation mismatch")
+}
+
+// reportRelayError hands err to listen without blocking, as only the first error is read
+func reportRelayError(errCh chan error, err error) {
+	select {
+	case errCh <- err:
+	default:
+	}
+}
@@ -0,0 +1,32 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAllocationMismatch(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "no error", err: nil, want: false},
+		{name: "allocation mismatch", err: errors.New("CreatePermission error response (error 437: Allocation Mismatch)"), want: true},
+		{name: "another reason", err: errors.New("Refresh error response (error 437: No allocation)"), want: true},
+		{name: "wrapped", err: fmt.Errorf("failed to refresh: %w", errors.New("Refresh error response (error 437: Allocation Mismatch)")), want: true},
+		{name: "reason only", err: errors.New("allocation mismatch"), want: true},
+		{name: "other STUN error", err: errors.New("CreatePermission error response (error 403: Forbidden)"), want: false},
+		{name: "port with 437", err: errors.New("dial tcp 10.0.0.1:54379: connect: connection refused"), want: false},
+		{name: "error code in an address", err: errors.New("relay 437.example.com (error 401: Unauthorized)"), want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, isAllocationMismatch(test.err))
+		})
+	}
+}
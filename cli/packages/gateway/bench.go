@@ -0,0 +1,294 @@
+package gateway
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+)
+
+// BenchOptions controls the synthetic load generated by Bench.
+type BenchOptions struct {
+	// Connections is the total number of connections opened through the relay.
+	Connections int
+	// Concurrency is how many of those connections are in flight at once.
+	Concurrency int
+	// PayloadSize is the number of bytes echoed over each connection.
+	PayloadSize int
+	// ChunkSize is the size of every write. Each chunk is timed as a round trip.
+	ChunkSize int
+}
+
+type BenchResult struct {
+	RelayAddress     string
+	Connections      int
+	Failures         int
+	BytesTransferred int64
+	Elapsed          time.Duration
+	// Throughput is the echoed payload in bytes per second, summed across all connections.
+	Throughput float64
+
+	SetupLatencyP50 time.Duration
+	SetupLatencyP99 time.Duration
+	SetupLatencyMax time.Duration
+
+	RoundTripP50 time.Duration
+	RoundTripP99 time.Duration
+	RoundTripMax time.Duration
+}
+
+// benchRelayAllocation is the TCP allocation of the relay the echo target listens on
+type benchRelayAllocation interface {
+	net.Listener
+	CreatePermissions(addrs ...net.Addr) error
+}
+
+type benchConnResult struct {
+	setup      time.Duration
+	roundTrips []time.Duration
+	bytes      int64
+	err        error
+}
+
+// Bench allocates a relay socket, serves a built-in echo target behind it and drives synthetic
+// connections through the relay from this host. It does not exchange gateway certificates, so
+// no traffic from Infisical is accepted while it runs. It uses the saved registration of the identity
+// when there is one, and only registers it when there's none or the relay refuses it.
+func (g *Gateway) Bench(ctx context.Context, opts BenchOptions) (*BenchResult, error) {
+	if opts.Connections <= 0 || opts.Concurrency <= 0 || opts.PayloadSize <= 0 || opts.ChunkSize <= 0 {
+		return nil, fmt.Errorf("connections, concurrency, payload size and chunk size must be greater than zero")
+	}
+
+	if relayDetails := g.savedRegistration(); relayDetails != nil {
+		allocation, err := g.benchAllocation(ctx, relayDetails)
+		if err == nil {
+			defer g.client.Close()
+			defer allocation.Close()
+			return g.bench(ctx, allocation, opts)
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		g.logger.Warnf("The relay refused the saved registration, registering the identity again: %s", err)
+	}
+
+	relayDetails, err := g.registerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allocation, err := g.benchAllocation(ctx, relayDetails)
+	if err != nil {
+		return nil, err
+	}
+	defer g.client.Close()
+	defer allocation.Close()
+
+	return g.bench(ctx, allocation, opts)
+}
+
+// benchAllocation connects with the relay and allocates the socket of the echo target
+func (g *Gateway) benchAllocation(ctx context.Context, relayDetails *api.GetRelayCredentialsResponseV1) (benchRelayAllocation, error) {
+	if err := g.dialRelay(ctx, relayDetails); err != nil {
+		return nil, err
+	}
+
+	if err := g.client.Listen(); err != nil {
+		g.client.Close()
+		return nil, fmt.Errorf("Failed to listen to relay server: %w", err)
+	}
+
+	allocation, err := g.client.AllocateTCP()
+	if err != nil {
+		g.client.Close()
+		return nil, fmt.Errorf("Failed to allocate relay connection: %w", err)
+	}
+	return allocation, nil
+}
+
+func (g *Gateway) bench(ctx context.Context, allocation benchRelayAllocation, opts BenchOptions) (*BenchResult, error) {
+	// The relay only accepts peers it has a permission for, so allow the address it sees us from
+	mappedAddr, err := g.client.SendBindingRequest()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to discover public address: %w", err)
+	}
+
+	if err := allocation.CreatePermissions(mappedAddr); err != nil {
+		return nil, fmt.Errorf("Failed to create relay permission for %s: %w", mappedAddr, err)
+	}
+
+	relayAddress := allocation.Addr().String()
+	g.logger.Infof("Benchmarking through relay address %s from %s", relayAddress, mappedAddr)
+
+	echoCtx, stopEcho := context.WithCancel(ctx)
+	defer stopEcho()
+	go g.serveBenchEcho(echoCtx, allocation)
+
+	payload := make([]byte, opts.ChunkSize)
+	if _, err := rand.Read(payload); err != nil {
+		return nil, err
+	}
+
+	jobs := make(chan struct{})
+	results := make(chan benchConnResult, opts.Connections)
+
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range jobs {
+				results <- g.benchConnection(ctx, relayAddress, payload, opts.PayloadSize)
+			}
+		}()
+	}
+
+	start := time.Now()
+	go func() {
+		defer close(jobs)
+		for i := 0; i < opts.Connections; i++ {
+			select {
+			case jobs <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+	close(results)
+
+	result := &BenchResult{RelayAddress: relayAddress, Elapsed: time.Since(start)}
+
+	var setupLatencies, roundTrips []time.Duration
+	for connResult := range results {
+		result.Connections++
+		if connResult.err != nil {
+			result.Failures++
+			g.logger.Debugf("Benchmark connection failed: %v", connResult.err)
+			continue
+		}
+		result.BytesTransferred += connResult.bytes
+		setupLatencies = append(setupLatencies, connResult.setup)
+		roundTrips = append(roundTrips, connResult.roundTrips...)
+	}
+
+	if result.Elapsed > 0 {
+		result.Throughput = float64(result.BytesTransferred) / result.Elapsed.Seconds()
+	}
+
+	result.SetupLatencyP50, result.SetupLatencyP99, result.SetupLatencyMax = latencyPercentiles(setupLatencies)
+	result.RoundTripP50, result.RoundTripP99, result.RoundTripMax = latencyPercentiles(roundTrips)
+
+	if ctx.Err() != nil {
+		return result, ctx.Err()
+	}
+
+	return result, nil
+}
+
+func (g *Gateway) serveBenchEcho(ctx context.Context, allocation net.Listener) {
+	go func() {
+		<-ctx.Done()
+		allocation.Close()
+	}()
+
+	for {
+		conn, err := allocation.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				continue
+			}
+			g.logger.Errorf("Benchmark echo target failed to accept connection: %v", err)
+			return
+		}
+
+		go func(c net.Conn) {
+			defer c.Close()
+			io.Copy(c, c)
+		}(conn)
+	}
+}
+
+// benchConnection measures setup as the time until the first byte is echoed back, since the relay
+// only binds the peer connection once the echo target accepts it.
+func (g *Gateway) benchConnection(ctx context.Context, relayAddress string, payload []byte, payloadSize int) benchConnResult {
+	var result benchConnResult
+
+	start := time.Now()
+	conn, err := g.dialer.DialContext(ctx, "tcp", relayAddress)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	defer conn.Close()
+
+	stopDeadline := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+	defer stopDeadline()
+
+	probe := []byte{0}
+	if _, err := conn.Write(probe); err != nil {
+		result.err = err
+		return result
+	}
+	if _, err := io.ReadFull(conn, probe); err != nil {
+		result.err = err
+		return result
+	}
+	result.setup = time.Since(start)
+
+	readBuffer := make([]byte, len(payload))
+	for remaining := payloadSize; remaining > 0; {
+		chunk := payload
+		if remaining < len(chunk) {
+			chunk = chunk[:remaining]
+		}
+
+		sentAt := time.Now()
+		if _, err := conn.Write(chunk); err != nil {
+			result.err = err
+			return result
+		}
+		if _, err := io.ReadFull(conn, readBuffer[:len(chunk)]); err != nil {
+			result.err = err
+			return result
+		}
+		result.roundTrips = append(result.roundTrips, time.Since(sentAt))
+
+		result.bytes += int64(len(chunk))
+		remaining -= len(chunk)
+	}
+
+	if ctx.Err() != nil {
+		result.err = errors.New("benchmark cancelled")
+	}
+
+	return result
+}
+
+func latencyPercentiles(samples []time.Duration) (p50 time.Duration, p99 time.Duration, max time.Duration) {
+	if len(samples) == 0 {
+		return 0, 0, 0
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	percentile := func(p float64) time.Duration {
+		index := int(float64(len(samples)-1) * p)
+		return samples[index]
+	}
+
+	return percentile(0.50), percentile(0.99), samples[len(samples)-1]
+}
@@ -0,0 +1,70 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	defaultConnectionBufferSize = 32 * 1024
+	bufferAcquireTimeout        = 30 * time.Second
+)
+
+// WithBufferLimits caps how much memory forwarded connections may hold. perConnection is the buffer
+// used for each direction of a connection; a direction stops reading from its source until the
+// buffered chunk has been written out, so a slow reader pushes back on a fast writer instead of
+// growing the buffer. maxTotal bounds the sum across all connections. Zero leaves it unlimited.
+func WithBufferLimits(perConnection int, maxTotal int64) Option {
+	return func(g *Gateway) {
+		g.connectionBufferSize = perConnection
+		g.maxBufferedBytes = maxTotal
+	}
+}
+
+func (g *Gateway) validateBufferLimits() error {
+	if g.connectionBufferSize <= 0 {
+		return fmt.Errorf("per connection buffer size must be greater than zero")
+	}
+
+	if g.maxBufferedBytes < 0 {
+		return fmt.Errorf("max buffered bytes must not be negative")
+	}
+
+	if g.maxBufferedBytes > 0 {
+		if g.maxBufferedBytes < int64(2*g.connectionBufferSize) {
+			return fmt.Errorf("max buffered bytes (%d) must fit at least one connection (%d)", g.maxBufferedBytes, 2*g.connectionBufferSize)
+		}
+		g.bufferBudget = semaphore.NewWeighted(g.maxBufferedBytes)
+	}
+
+	return nil
+}
+
+// acquireCopyBuffers reserves buffers for both directions of a connection. When the gateway is at
+// its memory ceiling the caller waits for other connections to finish, and gives up after
+// bufferAcquireTimeout.
+func (g *Gateway) acquireCopyBuffers(ctx context.Context) ([]byte, []byte, func(), error) {
+	size := int64(2 * g.connectionBufferSize)
+
+	if g.bufferBudget != nil && !g.bufferBudget.TryAcquire(size) {
+		g.logger.Warnf("Gateway buffer limit of %d bytes reached, waiting for capacity", g.maxBufferedBytes)
+
+		acquireCtx, cancel := context.WithTimeout(ctx, bufferAcquireTimeout)
+		defer cancel()
+
+		if err := g.bufferBudget.Acquire(acquireCtx, size); err != nil {
+			return nil, nil, nil, fmt.Errorf("gateway buffer limit reached: %w", err)
+		}
+	}
+
+	release := func() {
+		if g.bufferBudget != nil {
+			g.bufferBudget.Release(size)
+		}
+	}
+
+	return make([]byte, g.connectionBufferSize), make([]byte, g.connectionBufferSize), release, nil
+}
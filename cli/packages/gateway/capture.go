@@ -0,0 +1,211 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSessionNotFound is returned for a connection ID that isn't forwarding a session.
+var ErrSessionNotFound = errors.New("no forwarded session with this connection ID")
+
+// captureRecord is a line of a capture file. The first line describes the session, and the following
+// ones hold the bytes sent in one direction, base64 encoded.
+type captureRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Data      []byte    `json:"data,omitempty"`
+
+	ConnectionID  string `json:"connectionId,omitempty"`
+	Target        string `json:"target,omitempty"`
+	RemoteAddress string `json:"remoteAddress,omitempty"`
+	PeerName      string `json:"peerName,omitempty"`
+	Truncated     bool   `json:"truncated,omitempty"`
+}
+
+type sessionCapture struct {
+	file      *os.File
+	encoder   *json.Encoder
+	remaining int64
+}
+
+// sessionTap is attached to every forwarded session so a capture can be started while it is open
+type sessionTap struct {
+	info ConnectionInfo
+
+	// capturing is set while capture is, so writes only take the mutex during a capture
+	capturing atomic.Bool
+	mutex     sync.Mutex
+	capture   *sessionCapture
+}
+
+type sessionTaps struct {
+	mutex sync.Mutex
+	taps  map[string]*sessionTap
+}
+
+func (t *sessionTaps) add(info ConnectionInfo) *sessionTap {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.taps == nil {
+		t.taps = map[string]*sessionTap{}
+	}
+	tap := &sessionTap{info: info}
+	t.taps[info.ID] = tap
+	return tap
+}
+
+func (t *sessionTaps) remove(tap *sessionTap) {
+	t.mutex.Lock()
+	delete(t.taps, tap.info.ID)
+	t.mutex.Unlock()
+
+	tap.stop()
+}
+
+func (t *sessionTaps) get(id string) (*sessionTap, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tap, ok := t.taps[id]
+	return tap, ok
+}
+
+// ForwardedSessions returns the sessions being forwarded to a target, oldest first.
+func (g *Gateway) ForwardedSessions() []ConnectionInfo {
+	g.taps.mutex.Lock()
+	defer g.taps.mutex.Unlock()
+
+	sessions := make([]ConnectionInfo, 0, len(g.taps.taps))
+	for _, tap := range g.taps.taps {
+		sessions = append(sessions, tap.info)
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].AcceptedAt.Before(sessions[j].AcceptedAt) })
+	return sessions
+}
+
+// CaptureSession mirrors the bytes of the forwarded session with the connection ID id to a new file
+// at path, one JSON record per line, to debug the protocol spoken through the gateway. The bytes are
+// written in clear, so the file is only readable by the user running the gateway. The capture stops
+// once maxBytes of data are written, when the session ends, or with StopCapture.
+func (g *Gateway) CaptureSession(id string, path string, maxBytes int64) error {
+	if maxBytes <= 0 {
+		return fmt.Errorf("the capture size limit must be positive")
+	}
+
+	tap, ok := g.taps.get(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	tap.mutex.Lock()
+	defer tap.mutex.Unlock()
+	if tap.capture != nil {
+		return fmt.Errorf("session %s is already being captured", id)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	capture := &sessionCapture{file: file, encoder: json.NewEncoder(file), remaining: maxBytes}
+	err = capture.encoder.Encode(captureRecord{
+		Time:          time.Now(),
+		Direction:     "metadata",
+		ConnectionID:  tap.info.ID,
+		Target:        tap.info.Route.Target,
+		RemoteAddress: tap.info.RemoteAddress,
+		PeerName:      tap.info.Peer.CommonName,
+	})
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	tap.capture = capture
+	tap.capturing.Store(true)
+	g.logger.Infof("Capturing session %s to %s at %s", id, tap.info.Route.Target, path)
+	return nil
+}
+
+// StopCapture stops the capture of the forwarded session with the connection ID id.
+func (g *Gateway) StopCapture(id string) error {
+	tap, ok := g.taps.get(id)
+	if !ok {
+		return ErrSessionNotFound
+	}
+	tap.stop()
+	return nil
+}
+
+func (t *sessionTap) record(direction SessionDirection, data []byte) {
+	if !t.capturing.Load() {
+		return
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	capture := t.capture
+	if capture == nil {
+		return
+	}
+
+	record := captureRecord{Time: time.Now(), Direction: "target-to-client", Data: data}
+	if direction == SessionDirectionClientToTarget {
+		record.Direction = "client-to-target"
+	}
+	if int64(len(data)) > capture.remaining {
+		record.Data = data[:capture.remaining]
+		record.Truncated = true
+	}
+
+	err := capture.encoder.Encode(record)
+	capture.remaining -= int64(len(record.Data))
+	if err != nil || capture.remaining <= 0 {
+		capture.file.Close()
+		t.capture = nil
+		t.capturing.Store(false)
+	}
+}
+
+func (t *sessionTap) stop() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.capture != nil {
+		t.capture.file.Close()
+		t.capture = nil
+		t.capturing.Store(false)
+	}
+}
+
+// tappedConn mirrors everything written to the wrapped connection to the tap of its session. Until a
+// capture starts a write only costs an atomic load, and copyData hides ReaderFrom from every connection
+// anyway so that copies go through the capped buffers.
+type tappedConn struct {
+	net.Conn
+	tap       *sessionTap
+	direction SessionDirection
+}
+
+func (c *tappedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.tap.record(c.direction, b[:n])
+	}
+	return n, err
+}
+
+func (c *tappedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(CloseWrite); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
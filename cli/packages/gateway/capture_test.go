@@ -0,0 +1,129 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readCaptureRecords returns the records of a capture file after its metadata record
+func readCaptureRecords(t *testing.T, path string) []captureRecord {
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	var records []captureRecord
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record captureRecord
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	assert.NotEmpty(t, records)
+	assert.Equal(t, "metadata", records[0].Direction)
+	return records[1:]
+}
+
+func TestCaptureSession(t *testing.T) {
+	type write struct {
+		direction SessionDirection
+		data      string
+	}
+
+	tests := []struct {
+		name     string
+		maxBytes int64
+		writes   []write
+		want     []captureRecord
+	}{
+		{
+			name:     "both directions",
+			maxBytes: 1024,
+			writes:   []write{{SessionDirectionClientToTarget, "GET / HTTP/1.1"}, {SessionDirectionTargetToClient, "HTTP/1.1 200 OK"}},
+			want: []captureRecord{
+				{Direction: "client-to-target", Data: []byte("GET / HTTP/1.1")},
+				{Direction: "target-to-client", Data: []byte("HTTP/1.1 200 OK")},
+			},
+		},
+		{
+			name:     "size limit",
+			maxBytes: 6,
+			writes:   []write{{SessionDirectionClientToTarget, "ping"}, {SessionDirectionTargetToClient, "pong"}, {SessionDirectionClientToTarget, "ping"}},
+			want: []captureRecord{
+				{Direction: "client-to-target", Data: []byte("ping")},
+				{Direction: "target-to-client", Data: []byte("po"), Truncated: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g, err := New(WithIdentityToken("token"))
+			assert.NoError(t, err)
+			tap := g.taps.add(ConnectionInfo{ID: "connection", Route: Route{Target: "api.internal:443"}})
+			path := filepath.Join(t.TempDir(), "capture.ndjson")
+
+			// nothing is recorded before the capture starts
+			tap.record(SessionDirectionClientToTarget, []byte("before"))
+
+			assert.NoError(t, g.CaptureSession("connection", path, test.maxBytes))
+			assert.Error(t, g.CaptureSession("connection", path, test.maxBytes), "a session is captured once at a time")
+			for _, write := range test.writes {
+				tap.record(write.direction, []byte(write.data))
+			}
+			g.taps.remove(tap)
+
+			var got []captureRecord
+			for _, record := range readCaptureRecords(t, path) {
+				got = append(got, captureRecord{Direction: record.Direction, Data: record.Data, Truncated: record.Truncated})
+			}
+			assert.Equal(t, test.want, got)
+			assert.False(t, tap.capturing.Load())
+		})
+	}
+}
+
+func TestCaptureSessionErrors(t *testing.T) {
+	g, err := New(WithIdentityToken("token"))
+	assert.NoError(t, err)
+	g.taps.add(ConnectionInfo{ID: "connection"})
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+
+	assert.ErrorIs(t, g.CaptureSession("missing", path, 1024), ErrSessionNotFound)
+	assert.ErrorIs(t, g.StopCapture("missing"), ErrSessionNotFound)
+	assert.Error(t, g.CaptureSession("connection", path, 0))
+
+	assert.NoError(t, os.WriteFile(path, nil, 0600))
+	assert.Error(t, g.CaptureSession("connection", path, 1024), "an existing file is never overwritten")
+}
+
+func TestTappedConnRecordsWritesWhileCapturing(t *testing.T) {
+	g, err := New(WithIdentityToken("token"))
+	assert.NoError(t, err)
+	tap := g.taps.add(ConnectionInfo{ID: "connection"})
+	path := filepath.Join(t.TempDir(), "capture.ndjson")
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	go io.Copy(io.Discard, peer)
+	tapped := &tappedConn{Conn: conn, tap: tap, direction: SessionDirectionTargetToClient}
+
+	_, err = tapped.Write([]byte("not captured"))
+	assert.NoError(t, err)
+	assert.NoError(t, g.CaptureSession("connection", path, 1024))
+	_, err = tapped.Write([]byte("captured"))
+	assert.NoError(t, err)
+	assert.NoError(t, g.StopCapture("connection"))
+	_, err = tapped.Write([]byte("stopped"))
+	assert.NoError(t, err)
+
+	records := readCaptureRecords(t, path)
+	assert.Len(t, records, 1)
+	assert.Equal(t, []byte("captured"), records[0].Data)
+}
@@ -3,86 +3,124 @@ package gateway
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"errors"
 	"io"
 	"net"
+	"strings"
 	"sync"
-
-	"github.com/rs/zerolog/log"
 )
 
-func handleConnection(conn net.Conn) {
+// handleConnection negotiates the route of a connection and hands it to the connection handler
+func (g *Gateway) handleConnection(ctx context.Context, conn net.Conn, info ConnectionInfo) {
 	defer conn.Close()
-	log.Info().Msgf("New connection from: %s", conn.RemoteAddr().String())
+	g.logger.Infof("New connection %s from: %s", info.ID, info.RemoteAddress)
 
 	// Use buffered reader for better handling of fragmented data
 	reader := bufio.NewReader(conn)
-	for {
-		msg, err := reader.ReadBytes('\n')
-		if err != nil {
-			if errors.Is(err, io.EOF) {
-				return
-			}
-			log.Error().Msgf("Error reading command: %s", err)
-			return
+	msg, err := reader.ReadBytes('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			g.logger.Errorf("Error reading command: %s", err)
 		}
+		return
+	}
 
-		cmd := bytes.ToUpper(bytes.TrimSpace(bytes.Split(msg, []byte(" "))[0]))
-		args := bytes.TrimSpace(bytes.TrimPrefix(msg, cmd))
-
-		switch string(cmd) {
-		case "FORWARD-TCP":
-			proxyAddress := string(bytes.Split(args, []byte(" "))[0])
-			destTarget, err := net.Dial("tcp", proxyAddress)
-			if err != nil {
-				log.Error().Msgf("Failed to connect to target: %v", err)
-				return
-			}
-			defer destTarget.Close()
-
-			// Handle buffered data
-			buffered := reader.Buffered()
-			if buffered > 0 {
-				bufferedData := make([]byte, buffered)
-				_, err := reader.Read(bufferedData)
-				if err != nil {
-					log.Error().Msgf("Error reading buffered data: %v", err)
-					return
-				}
-
-				if _, err = destTarget.Write(bufferedData); err != nil {
-					log.Error().Msgf("Error writing buffered data: %v", err)
-					return
-				}
-			}
-
-			CopyData(conn, destTarget)
-			return
-		case "PING":
-			if _, err := conn.Write([]byte("PONG")); err != nil {
-				log.Error().Msgf("Error writing PONG response: %v", err)
-			}
+	cmd := bytes.ToUpper(bytes.TrimSpace(bytes.Split(msg, []byte(" "))[0]))
+	args := bytes.TrimSpace(bytes.TrimPrefix(msg, cmd))
+
+	switch string(cmd) {
+	case CommandForwardTCP:
+		info.Route = Route{Command: CommandForwardTCP, Target: string(bytes.Split(args, []byte(" "))[0])}
+	case CommandForwardTLS:
+		argParts := bytes.Split(args, []byte(" "))
+		info.Route = Route{Command: CommandForwardTLS, Target: string(argParts[0]), TLSMode: TLSModeTerminate}
+		if len(argParts) > 1 {
+			info.Route.TLSMode = TLSMode(strings.ToLower(string(argParts[1])))
+		}
+	case "PING":
+		if _, err := conn.Write([]byte("PONG")); err != nil {
+			g.logger.Errorf("Error writing PONG response: %v", err)
+		}
+		return
+	default:
+		g.logger.Errorf("Unknown command: %s", string(cmd))
+		return
+	}
+
+	if g.InMaintenance() {
+		g.logger.Infof("Refusing connection %s to %s, the gateway is in maintenance mode", info.ID, info.Route.Target)
+		if _, err := conn.Write([]byte(maintenanceRejection)); err != nil {
+			g.logger.Errorf("Error writing draining response: %v", err)
+		}
+		return
+	}
+
+	g.connectionHandler.HandleConnection(withConnectionInfo(ctx, info), &bufferedConn{Conn: conn, reader: reader})
+}
+
+// forwardConnection is the connection handler that connects a connection to the target of its route
+func (g *Gateway) forwardConnection(ctx context.Context, conn net.Conn) {
+	info, _ := ConnectionInfoFromContext(ctx)
+
+	clientConn, ok := conn.(*bufferedConn)
+	if !ok {
+		clientConn = &bufferedConn{Conn: conn, reader: bufio.NewReader(conn)}
+	}
+
+	var destTarget net.Conn
+	var err error
+	switch info.Route.Command {
+	case CommandForwardTCP:
+		destTarget, err = g.dialTarget(ctx, info.Route.Target)
+		if err != nil {
+			g.logger.Errorf("Failed to connect to target: %v", err)
 			return
-		default:
-			log.Error().Msgf("Unknown command: %s", string(cmd))
+		}
+	case CommandForwardTLS:
+		destTarget, err = g.dialTLSTarget(ctx, clientConn.reader, info.Route.Target, info.Route.TLSMode)
+		if err != nil {
+			g.logger.Errorf("Failed to connect to TLS target %s [mode=%s]: %v", info.Route.Target, info.Route.TLSMode, err)
 			return
 		}
+	default:
+		g.logger.Errorf("Unknown route command: %s", info.Route.Command)
+		return
 	}
+	defer destTarget.Close()
+
+	recordedClientConn, targetConn, finishRecording := g.recordSession(info.ID, info.Route.Target, clientConn, destTarget)
+	defer finishRecording()
+
+	tap := g.taps.add(info)
+	defer g.taps.remove(tap)
+
+	g.copyData(ctx,
+		&tappedConn{Conn: recordedClientConn, tap: tap, direction: SessionDirectionTargetToClient},
+		&tappedConn{Conn: targetConn, tap: tap, direction: SessionDirectionClientToTarget})
 }
 
 type CloseWrite interface {
 	CloseWrite() error
 }
 
-func CopyData(src, dst net.Conn) {
+func (g *Gateway) copyData(ctx context.Context, src, dst net.Conn) {
+	srcBuffer, dstBuffer, release, err := g.acquireCopyBuffers(ctx)
+	if err != nil {
+		g.logger.Errorf("Dropping connection: %v", err)
+		return
+	}
+	defer release()
+
 	var wg sync.WaitGroup
 	wg.Add(2)
 
-	copyAndClose := func(dst, src net.Conn, done chan<- bool) {
+	copyAndClose := func(dst, src net.Conn, buffer []byte, done chan<- bool) {
 		defer wg.Done()
-		_, err := io.Copy(dst, src)
+		// Hide ReaderFrom/WriterTo so the copy always goes through the capped buffer
+		_, err := io.CopyBuffer(struct{ io.Writer }{dst}, struct{ io.Reader }{src}, buffer)
 		if err != nil && !errors.Is(err, io.EOF) {
-			log.Error().Msgf("Copy error: %v", err)
+			g.logger.Errorf("Copy error: %v", err)
 		}
 
 		// Signal we're done writing
@@ -97,8 +135,8 @@ func CopyData(src, dst net.Conn) {
 	done1 := make(chan bool, 1)
 	done2 := make(chan bool, 1)
 
-	go copyAndClose(dst, src, done1)
-	go copyAndClose(src, dst, done2)
+	go copyAndClose(dst, src, srcBuffer, done1)
+	go copyAndClose(src, dst, dstBuffer, done2)
 
 	// Wait for both copies to complete
 	<-done1
@@ -0,0 +1,350 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/pion/stun/v3"
+)
+
+type DiagnosticStatus string
+
+const (
+	DiagnosticPass DiagnosticStatus = "pass"
+	DiagnosticWarn DiagnosticStatus = "warn"
+	DiagnosticFail DiagnosticStatus = "fail"
+	DiagnosticSkip DiagnosticStatus = "skip"
+)
+
+const (
+	diagnosticTimeout = 5 * time.Second
+	maxClockSkew      = 30 * time.Second
+	// Large enough that the datagram no longer fits a typical 1500 byte MTU once a tunnel or
+	// VPN adds its own headers.
+	stunLargeProbePadding = 1300
+	// Comprehension-optional attribute type, so relays ignore it instead of rejecting the request
+	stunProbePaddingAttr = stun.AttrType(0x8055)
+)
+
+var diagnosticRelayPorts = []string{"5349", "443", "3478"}
+
+// DiagnosticResult is the outcome of a single check run by Diagnose. Hint is set when the check
+// didn't pass and explains what to change.
+type DiagnosticResult struct {
+	Check  string
+	Status DiagnosticStatus
+	Detail string
+	Hint   string
+}
+
+// Diagnose runs the network checks needed for the gateway to reach Infisical and its relay. It
+// keeps going after failures so every problem is reported in a single run.
+func (g *Gateway) Diagnose(ctx context.Context) []DiagnosticResult {
+	var results []DiagnosticResult
+
+	results = append(results, g.diagnoseClockSkew(ctx))
+
+	// registering the identity again would hand a running gateway new relay credentials, so only the
+	// registration it saved, or the relay address given, is checked
+	relayDetails := g.savedRegistration()
+	if relayDetails != nil {
+		results = append(results, DiagnosticResult{
+			Check:  "Relay details from the gateway registration",
+			Status: DiagnosticPass,
+			Detail: relayDetails.TurnServerAddress,
+		})
+	} else if g.relayOverride != nil && g.relayOverride.Address != "" {
+		relayDetails = &api.GetRelayCredentialsResponseV1{TurnServerAddress: g.relayOverride.Address}
+	} else {
+		return append(results, DiagnosticResult{
+			Check:  "Relay details from the gateway registration",
+			Status: DiagnosticSkip,
+			Detail: "the identity hasn't registered a gateway on this host",
+			Hint:   "Start the gateway once with this identity, or set --relay-address, to check the relay",
+		})
+	}
+
+	relayDetails, relayServerName, err := g.applyRelayOverride(relayDetails)
+	if err != nil {
+		return append(results, DiagnosticResult{
+			Check:  "Relay credentials",
+			Status: DiagnosticFail,
+			Detail: err.Error(),
+			Hint:   "The relay credentials file must be YAML with username, password and realm keys",
+		})
+	}
+	relayHost, relayPort, err := net.SplitHostPort(relayDetails.TurnServerAddress)
+	if err != nil {
+		return append(results, DiagnosticResult{
+			Check:  "Relay address",
+			Status: DiagnosticFail,
+			Detail: err.Error(),
+			Hint:   "Relay addresses must be in host:port form",
+		})
+	}
+
+	dnsResult, relayIps := diagnoseDNS(ctx, relayHost)
+	results = append(results, dnsResult)
+	if len(relayIps) == 0 {
+		return results
+	}
+
+	results = append(results, diagnoseProxy(relayHost))
+
+	ports := diagnosticRelayPorts
+	if !slices.Contains(ports, relayPort) {
+		ports = append([]string{relayPort}, ports...)
+	}
+
+	for _, port := range ports {
+		results = append(results, g.diagnoseTCP(ctx, relayHost, port, port == relayPort))
+		if port == "5349" || port == "443" {
+			results = append(results, g.diagnoseTLS(ctx, relayHost, port, relayServerName, port == relayPort))
+		}
+	}
+
+	results = append(results, diagnoseSTUN(net.JoinHostPort(relayHost, "3478"))...)
+
+	return results
+}
+
+func (g *Gateway) diagnoseClockSkew(ctx context.Context) DiagnosticResult {
+	result := DiagnosticResult{Check: "Clock skew"}
+
+	response, err := g.httpClient.R().SetContext(ctx).Get(fmt.Sprintf("%v/status", config.INFISICAL_URL))
+	if err != nil {
+		result.Status = DiagnosticFail
+		result.Detail = err.Error()
+		result.Hint = fmt.Sprintf("Unable to reach %s. Check outbound HTTPS access and proxy settings", config.INFISICAL_URL)
+		return result
+	}
+
+	serverTime, err := http.ParseTime(response.Header().Get("Date"))
+	if err != nil {
+		result.Status = DiagnosticSkip
+		result.Detail = "Infisical did not return a Date header"
+		return result
+	}
+
+	skew := time.Since(serverTime).Round(time.Second)
+	result.Detail = fmt.Sprintf("local clock is %s off from Infisical", skew)
+	if skew > maxClockSkew || skew < -maxClockSkew {
+		result.Status = DiagnosticFail
+		result.Hint = "Sync the system clock with NTP. Certificates issued to the gateway are rejected when the clock drifts"
+		return result
+	}
+
+	result.Status = DiagnosticPass
+	return result
+}
+
+func diagnoseDNS(ctx context.Context, host string) (DiagnosticResult, []string) {
+	result := DiagnosticResult{Check: fmt.Sprintf("DNS %s", host)}
+
+	if ip := net.ParseIP(host); ip != nil {
+		result.Status = DiagnosticPass
+		result.Detail = "relay address is an IP"
+		return result, []string{host}
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+
+	ips, err := net.DefaultResolver.LookupHost(lookupCtx, host)
+	if err != nil {
+		result.Status = DiagnosticFail
+		result.Detail = err.Error()
+		result.Hint = "The relay host name does not resolve. Check /etc/resolv.conf, or use --relay-address with an IP reachable from this network"
+		return result, nil
+	}
+
+	result.Status = DiagnosticPass
+	result.Detail = strings.Join(ips, ", ")
+
+	for _, resolved := range ips {
+		if ip := net.ParseIP(resolved); ip != nil && (ip.IsPrivate() || ip.IsLoopback()) {
+			result.Status = DiagnosticWarn
+			result.Hint = "The relay resolves to a private address. This is expected with split-brain DNS, otherwise check for DNS hijacking"
+		}
+	}
+
+	return result, ips
+}
+
+func diagnoseProxy(relayHost string) DiagnosticResult {
+	result := DiagnosticResult{Check: "Proxy"}
+
+	var proxies []string
+	for _, name := range []string{"HTTPS_PROXY", "https_proxy", "HTTP_PROXY", "http_proxy", "ALL_PROXY", "all_proxy"} {
+		if value := os.Getenv(name); value != "" {
+			proxies = append(proxies, name)
+		}
+	}
+
+	if len(proxies) == 0 {
+		result.Status = DiagnosticPass
+		result.Detail = "no proxy configured"
+		return result
+	}
+
+	result.Status = DiagnosticWarn
+	result.Detail = fmt.Sprintf("%s set", strings.Join(proxies, ", "))
+	result.Hint = fmt.Sprintf("Relay traffic is raw TCP/TLS and is not sent through HTTP proxies. Allow direct egress to %s", relayHost)
+	return result
+}
+
+func (g *Gateway) diagnoseTCP(ctx context.Context, host string, port string, required bool) DiagnosticResult {
+	address := net.JoinHostPort(host, port)
+	result := DiagnosticResult{Check: fmt.Sprintf("TCP %s", address)}
+
+	dialCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := g.dialer.DialContext(dialCtx, "tcp", address)
+	if err != nil {
+		result.Status = failOrWarn(required)
+		result.Detail = err.Error()
+		result.Hint = fmt.Sprintf("Allow outbound TCP to %s in firewalls and security groups", address)
+		return result
+	}
+	conn.Close()
+
+	result.Status = DiagnosticPass
+	result.Detail = fmt.Sprintf("connected in %s", time.Since(start).Round(time.Millisecond))
+	return result
+}
+
+func (g *Gateway) diagnoseTLS(ctx context.Context, host string, port string, serverName string, required bool) DiagnosticResult {
+	address := net.JoinHostPort(host, port)
+	result := DiagnosticResult{Check: fmt.Sprintf("TLS %s", address)}
+
+	dialCtx, cancel := context.WithTimeout(ctx, diagnosticTimeout)
+	defer cancel()
+
+	conn, err := g.dialer.DialContext(dialCtx, "tcp", address)
+	if err != nil {
+		result.Status = DiagnosticSkip
+		result.Detail = "TCP connection failed"
+		return result
+	}
+	defer conn.Close()
+
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: serverName})
+	if err := tlsConn.HandshakeContext(dialCtx); err != nil {
+		result.Status = failOrWarn(required)
+		result.Detail = err.Error()
+
+		var unknownAuthority x509.UnknownAuthorityError
+		var hostnameErr x509.HostnameError
+		switch {
+		case errors.As(err, &unknownAuthority):
+			result.Hint = "The certificate is signed by an unknown authority, which usually means a TLS inspecting proxy or firewall. Exempt the relay from inspection"
+		case errors.As(err, &hostnameErr):
+			result.Hint = "The certificate does not match the relay name. If you dial the relay through another address, set --relay-server-name"
+		case errors.Is(err, context.DeadlineExceeded):
+			result.Hint = "TCP connects but the handshake stalls. This points to MTU or fragmentation problems on the path, or deep packet inspection dropping TLS"
+		default:
+			result.Hint = "Check that nothing between the gateway and the relay terminates or rewrites TLS"
+		}
+		return result
+	}
+
+	state := tlsConn.ConnectionState()
+	result.Status = DiagnosticPass
+	result.Detail = fmt.Sprintf("%s, issued by %s", tls.VersionName(state.Version), state.PeerCertificates[0].Issuer.CommonName)
+	return result
+}
+
+// diagnoseSTUN sends a small and a large binding request over UDP. When only the large one goes
+// unanswered, datagrams near the MTU are being dropped on the path.
+func diagnoseSTUN(address string) []DiagnosticResult {
+	smallResult := DiagnosticResult{Check: fmt.Sprintf("STUN %s", address)}
+
+	mappedAddr, err := stunBindingRequest(address, 0)
+	if err != nil {
+		smallResult.Status = DiagnosticWarn
+		smallResult.Detail = err.Error()
+		smallResult.Hint = "UDP to the relay is blocked. The gateway only needs TCP, but STUN failures also hide MTU problems from this tool"
+		return []DiagnosticResult{smallResult}
+	}
+
+	smallResult.Status = DiagnosticPass
+	smallResult.Detail = fmt.Sprintf("public address %s", mappedAddr)
+
+	largeResult := DiagnosticResult{Check: "MTU"}
+	if _, err := stunBindingRequest(address, stunLargeProbePadding); err != nil {
+		largeResult.Status = DiagnosticWarn
+		largeResult.Detail = fmt.Sprintf("%d byte datagrams are dropped: %s", stunLargeProbePadding, err)
+		largeResult.Hint = "Large packets are lost on the path. Lower the MTU on VPN or tunnel interfaces, or allow ICMP fragmentation-needed messages"
+		return []DiagnosticResult{smallResult, largeResult}
+	}
+
+	largeResult.Status = DiagnosticPass
+	largeResult.Detail = fmt.Sprintf("%d byte datagrams pass", stunLargeProbePadding)
+	return []DiagnosticResult{smallResult, largeResult}
+}
+
+func stunBindingRequest(address string, padding int) (net.Addr, error) {
+	conn, err := net.DialTimeout("udp", address, diagnosticTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	setters := []stun.Setter{stun.TransactionID, stun.BindingRequest}
+	if padding > 0 {
+		setters = append(setters, stun.RawAttribute{Type: stunProbePaddingAttr, Value: make([]byte, padding)})
+	}
+	setters = append(setters, stun.Fingerprint)
+
+	request, err := stun.Build(setters...)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(diagnosticTimeout))
+	if _, err := conn.Write(request.Raw); err != nil {
+		return nil, err
+	}
+
+	buffer := make([]byte, 1500)
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &stun.Message{Raw: buffer[:n]}
+	if err := response.Decode(); err != nil {
+		return nil, err
+	}
+
+	if response.TransactionID != request.TransactionID {
+		return nil, fmt.Errorf("unexpected STUN transaction in response")
+	}
+
+	var mappedAddr stun.XORMappedAddress
+	if err := mappedAddr.GetFrom(response); err != nil {
+		return nil, err
+	}
+
+	return &net.UDPAddr{IP: mappedAddr.IP, Port: mappedAddr.Port}, nil
+}
+
+func failOrWarn(required bool) DiagnosticStatus {
+	if required {
+		return DiagnosticFail
+	}
+	return DiagnosticWarn
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -14,7 +15,15 @@ import (
 	"github.com/go-resty/resty/v2"
 	"github.com/pion/logging"
 	"github.com/pion/turn/v4"
-	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	firstHeartbeatDelay = 10 * time.Second
+	heartbeatInterval   = 1 * time.Hour
+
+	defaultHeartbeatFailureThreshold = 3
+	defaultHeartbeatRetryInterval    = 1 * time.Minute
 )
 
 type GatewayConfig struct {
@@ -29,47 +38,281 @@ type GatewayConfig struct {
 }
 
 type Gateway struct {
-	httpClient *resty.Client
-	config     *GatewayConfig
-	client     *turn.Client
+	httpClient          *resty.Client
+	circuitBreaker      *api.CircuitBreaker
+	config              *GatewayConfig
+	client              *turn.Client
+	identityToken       string
+	identityTokenSource func() string
+	retryInterval       time.Duration
+	apiTimeout          time.Duration
+
+	heartbeatFailureThreshold int
+	heartbeatRetryInterval    time.Duration
+
+	logger          Logger
+	metrics         Metrics
+	dialer          Dialer
+	targetTLSConfig *tls.Config
+
+	targetResolverAddress string
+	targetResolver        *net.Resolver
+
+	relayOverride        *RelayOverride
+	registrationCacheDir string
+	extraStaticIps       []string
+	sessionRecording     *SessionRecordingConfig
+
+	sessionReauthorizationInterval time.Duration
+	sessions                       sessionRegistry
+
+	connectionMiddlewares []func(next ConnectionHandler) ConnectionHandler
+	connectionHandler     ConnectionHandler
+	maintenance           maintenanceMode
+	taps                  sessionTaps
+
+	standby     bool
+	promoted    chan struct{}
+	promoteOnce sync.Once
+
+	connectionBufferSize int
+	maxBufferedBytes     int64
+	bufferBudget         *semaphore.Weighted
+
+	mutex   sync.Mutex
+	cancel  context.CancelFunc
+	stopped chan struct{}
+}
+
+// New creates a gateway that can be embedded in any Go program. Call Start to connect to the relay
+// and Stop to drain active connections.
+func New(opts ...Option) (*Gateway, error) {
+	g := &Gateway{
+		config:        &GatewayConfig{},
+		retryInterval: 5 * time.Second,
+		apiTimeout:    30 * time.Second,
+		logger:        nopLogger{},
+		metrics:       nopMetrics{},
+		dialer:        &net.Dialer{Timeout: 30 * time.Second},
+
+		heartbeatFailureThreshold: defaultHeartbeatFailureThreshold,
+		heartbeatRetryInterval:    defaultHeartbeatRetryInterval,
+
+		promoted:       make(chan struct{}),
+		circuitBreaker: api.DefaultCircuitBreaker,
+
+		connectionBufferSize:           defaultConnectionBufferSize,
+		sessionReauthorizationInterval: defaultSessionReauthorizationInterval,
+	}
+
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	if g.httpClient == nil {
+		// Registration, cert exchange and heartbeats are all safe to repeat
+		retryPolicy := api.DefaultRetryPolicy
+		retryPolicy.RetryNonIdempotent = true
+		g.httpClient = api.NewHTTPClientWithCircuitBreaker(retryPolicy, g.circuitBreaker)
+	}
+
+	if g.identityTokenSource != nil {
+		g.httpClient.SetAuthToken(g.identityTokenSource())
+		g.httpClient.OnBeforeRequest(func(_ *resty.Client, request *resty.Request) error {
+			request.SetAuthToken(g.identityTokenSource())
+			return nil
+		})
+	} else if g.identityToken != "" {
+		g.httpClient.SetAuthToken(g.identityToken)
+	}
+
+	if g.httpClient.Token == "" {
+		return nil, fmt.Errorf("an identity token is required to start the gateway")
+	}
+
+	if g.apiTimeout <= 0 {
+		return nil, fmt.Errorf("API timeout must be greater than zero")
+	}
+
+	if g.heartbeatFailureThreshold < 1 {
+		return nil, fmt.Errorf("heartbeat failure threshold must be at least 1")
+	}
+
+	if g.heartbeatRetryInterval <= 0 {
+		return nil, fmt.Errorf("heartbeat retry interval must be greater than zero")
+	}
+
+	if err := g.validateBufferLimits(); err != nil {
+		return nil, err
+	}
+
+	if g.sessionRecording != nil {
+		if err := validateSessionRecordingConfig(g.sessionRecording); err != nil {
+			return nil, err
+		}
+	}
+
+	g.connectionHandler = g.buildConnectionHandler()
+
+	if g.relayOverride != nil && g.relayOverride.CredentialsFile != "" {
+		if _, err := readRelayCredentialsFile(g.relayOverride.CredentialsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	if g.targetResolverAddress != "" {
+		targetResolver, err := newTargetResolver(g.targetResolverAddress)
+		if err != nil {
+			return nil, err
+		}
+		g.targetResolver = targetResolver
+	}
+
+	return g, nil
+}
+
+// Start connects to the relay and serves connections in the background until ctx is cancelled or
+// Stop is called. Relay failures are retried every retry interval.
+func (g *Gateway) Start(ctx context.Context) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	if g.cancel != nil {
+		return fmt.Errorf("gateway already started")
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	g.cancel = cancel
+	g.stopped = make(chan struct{})
+
+	removeListener := g.circuitBreaker.OnStateChange(g.circuitBreakerStateChanged)
+
+	go func() {
+		defer close(g.stopped)
+		defer removeListener()
+		g.run(runCtx)
+	}()
+
+	return nil
+}
+
+func (g *Gateway) circuitBreakerStateChanged(state api.CircuitState) {
+	if state == api.CircuitOpen {
+		g.logger.Warnf("Infisical API is unavailable, pausing control-plane calls. Active connections keep being served")
+	} else {
+		g.logger.Infof("Infisical API circuit breaker is %s", state)
+	}
+	if breakerMetrics, ok := g.metrics.(CircuitBreakerMetrics); ok {
+		breakerMetrics.CircuitBreakerStateChanged(string(state))
+	}
 }
 
-func NewGateway(identityToken string) (Gateway, error) {
-	httpClient := resty.New()
-	httpClient.SetAuthToken(identityToken)
+// Stop signals the gateway to shut down and waits until it has exited or ctx expires.
+func (g *Gateway) Stop(ctx context.Context) error {
+	g.mutex.Lock()
+	cancel, stopped := g.cancel, g.stopped
+	g.mutex.Unlock()
+
+	if cancel == nil {
+		return nil
+	}
+
+	cancel()
 
-	return Gateway{
-		httpClient: httpClient,
-		config:     &GatewayConfig{},
-	}, nil
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
 }
 
-func (g *Gateway) ConnectWithRelay() error {
-	relayDetails, err := api.CallRegisterGatewayIdentityV1(g.httpClient)
+func (g *Gateway) run(ctx context.Context) {
+	retryTicker := time.NewTicker(g.retryInterval)
+	defer retryTicker.Stop()
+
+	for {
+		if ctx.Err() != nil {
+			g.logger.Infof("Shutting down gateway")
+			return
+		}
+
+		err := g.connectWithRelay(ctx)
+		if err == nil {
+			err = g.listen(ctx)
+		}
+
+		if ctx.Err() != nil {
+			g.logger.Infof("Gateway shutdown complete")
+			return
+		}
+
+		if errors.Is(err, errStandbyRefresh) {
+			g.logger.Debugf("%s", err)
+			continue
+		}
+
+		if errors.Is(err, errAllocationLost) {
+			g.logger.Warnf("%s. Allocating a new relay address", err)
+			g.metrics.RelayReconnected()
+			continue
+		}
+
+		g.logger.Errorf("Gateway error: %s", err)
+		if api.IsUnauthorized(err) || api.IsForbidden(err) {
+			g.logger.Errorf("The identity token was rejected. Make sure it has not expired and the identity has access to register gateways")
+		}
+		g.logger.Infof("Retrying connection in %s...", g.retryInterval)
+		g.metrics.RelayReconnected()
+
+		select {
+		case <-retryTicker.C:
+		case <-ctx.Done():
+			g.logger.Infof("Shutting down gateway")
+			return
+		}
+	}
+}
+
+func (g *Gateway) connectWithRelay(ctx context.Context) error {
+	relayDetails, err := g.registerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+	return g.dialRelay(ctx, relayDetails)
+}
+
+// dialRelay connects the TURN client with the relay of the registration
+func (g *Gateway) dialRelay(ctx context.Context, relayDetails *api.GetRelayCredentialsResponseV1) error {
+	relayDetails, relayServerName, err := g.applyRelayOverride(relayDetails)
 	if err != nil {
 		return err
 	}
-	relayAddress, relayPort := strings.Split(relayDetails.TurnServerAddress, ":")[0], strings.Split(relayDetails.TurnServerAddress, ":")[1]
-	var conn net.Conn
+
+	_, relayPort, err := net.SplitHostPort(relayDetails.TurnServerAddress)
+	if err != nil {
+		return fmt.Errorf("Invalid relay address %q: %w", relayDetails.TurnServerAddress, err)
+	}
 
 	// Dial TURN Server
+	conn, err := g.dialer.DialContext(ctx, "tcp", relayDetails.TurnServerAddress)
+	if err != nil {
+		return fmt.Errorf("Failed to connect with relay server: %w", err)
+	}
+
 	if relayPort == "5349" {
-		log.Info().Msgf("Provided relay port %s. Using TLS", relayPort)
-		conn, err = tls.Dial("tcp", relayDetails.TurnServerAddress, &tls.Config{
+		g.logger.Infof("Provided relay port %s. Using TLS", relayPort)
+		tlsConn := tls.Client(conn, &tls.Config{
 			InsecureSkipVerify: false,
-			ServerName:         relayAddress,
+			ServerName:         relayServerName,
 		})
-	} else {
-		log.Info().Msgf("Provided relay port %s. Using non TLS connection.", relayPort)
-		peerAddr, err := net.ResolveTCPAddr("tcp", relayDetails.TurnServerAddress)
-		if err != nil {
-			return fmt.Errorf("Failed to parse turn server address: %w", err)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return fmt.Errorf("Failed to connect with relay server: %w", err)
 		}
-		conn, err = net.DialTCP("tcp", nil, peerAddr)
-	}
-
-	if err != nil {
-		return fmt.Errorf("Failed to connect with relay server: %w", err)
+		conn = tlsConn
+	} else {
+		g.logger.Infof("Provided relay port %s. Using non TLS connection.", relayPort)
 	}
 
 	// Start a new TURN Client and wrap our net.Conn in a STUNConn
@@ -95,23 +338,23 @@ func (g *Gateway) ConnectWithRelay() error {
 		TurnServerAddress:  relayDetails.TurnServerAddress,
 		InfisicalStaticIp:  relayDetails.InfisicalStaticIp,
 	}
-	// if port not specific allow all port
-	if relayDetails.InfisicalStaticIp != "" && !strings.Contains(relayDetails.InfisicalStaticIp, ":") {
-		g.config.InfisicalStaticIp = g.config.InfisicalStaticIp + ":0"
-	}
 
 	g.client = client
 	return nil
 }
 
-func (g *Gateway) Listen(ctx context.Context) error {
+func (g *Gateway) listen(ctx context.Context) error {
 	defer g.client.Close()
 	err := g.client.Listen()
 	if err != nil {
 		return fmt.Errorf("Failed to listen to relay server: %w", err)
 	}
 
-	log.Info().Msg("Connected with relay")
+	g.logger.Infof("Connected with relay")
+
+	if err := g.waitForPromotion(ctx); err != nil {
+		return err
+	}
 
 	// Allocate a relay socket on the TURN server. On success, it
 	// will return a net.PacketConn which represents the remote
@@ -121,14 +364,17 @@ func (g *Gateway) Listen(ctx context.Context) error {
 		return fmt.Errorf("Failed to allocate relay connection: %w", err)
 	}
 
-	log.Info().Msg(relayNonTlsConn.Addr().String())
+	g.logger.Infof("Relay address: %s", relayNonTlsConn.Addr().String())
 	defer func() {
 		if closeErr := relayNonTlsConn.Close(); closeErr != nil {
-			log.Error().Msgf("Failed to close connection: %s", closeErr)
+			g.logger.Errorf("Failed to close connection: %s", closeErr)
 		}
 	}()
 
-	gatewayCert, err := api.CallExchangeRelayCertV1(g.httpClient, api.ExchangeRelayCertRequestV1{
+	apiCtx, cancel := context.WithTimeout(ctx, g.apiTimeout)
+	defer cancel()
+
+	gatewayCert, err := api.CallExchangeRelayCertV1(apiCtx, g.httpClient, api.ExchangeRelayCertRequestV1{
 		RelayAddress: relayNonTlsConn.Addr().String(),
 	})
 	if err != nil {
@@ -141,17 +387,21 @@ func (g *Gateway) Listen(ctx context.Context) error {
 	g.config.CertificateChain = gatewayCert.CertificateChain
 
 	shutdownCh := make(chan bool, 1)
+	errCh := make(chan error, 1)
 
-	if g.config.InfisicalStaticIp != "" {
-		log.Info().Msgf("Found static ip from Infisical: %s. Creating permission IP lifecycle", g.config.InfisicalStaticIp)
-		peerAddr, err := net.ResolveTCPAddr("tcp", g.config.InfisicalStaticIp)
-		if err != nil {
-			return fmt.Errorf("Failed to parse infisical static ip: %w", err)
+	staticIpEntries := splitStaticIpEntries(g.config.InfisicalStaticIp)
+	staticIpEntries = append(staticIpEntries, g.extraStaticIps...)
+	if len(staticIpEntries) > 0 {
+		g.logger.Infof("Found static ips from Infisical: %s. Creating permission IP lifecycle", strings.Join(staticIpEntries, ", "))
+		for _, entry := range staticIpEntries {
+			peerAddrs, err := parseStaticIpEntry(entry)
+			if err != nil {
+				return fmt.Errorf("Failed to parse infisical static ip %q: %w", entry, err)
+			}
+			g.registerPermissionLifecycle(entry, func() error {
+				return relayNonTlsConn.CreatePermissions(peerAddrs...)
+			}, errCh, shutdownCh)
 		}
-		g.registerPermissionLifecycle(func() error {
-			err := relayNonTlsConn.CreatePermissions(peerAddr)
-			return err
-		}, shutdownCh)
 	}
 
 	cert, err := tls.X509KeyPair([]byte(gatewayCert.Certificate), []byte(gatewayCert.PrivateKey))
@@ -169,10 +419,10 @@ func (g *Gateway) Listen(ctx context.Context) error {
 		ClientAuth:   tls.RequireAndVerifyClientCert,
 	})
 
-	errCh := make(chan error, 1)
-	log.Info().Msg("Gateway started successfully")
-	g.registerHeartBeat(errCh, shutdownCh)
+	g.logger.Infof("Gateway started successfully")
+	g.registerHeartBeat(ctx, errCh, shutdownCh)
 	g.registerRelayIsActive(relayNonTlsConn.Addr().String(), errCh, shutdownCh)
+	g.registerSessionReauthorization(caCertPool, shutdownCh)
 
 	// Create a WaitGroup to track active connections
 	var wg sync.WaitGroup
@@ -198,14 +448,14 @@ func (g *Gateway) Listen(ctx context.Context) error {
 					}
 
 					if !strings.Contains(err.Error(), "data contains incomplete STUN or TURN frame") {
-						log.Error().Msgf("Failed to accept connection: %v", err)
+						g.logger.Errorf("Failed to accept connection: %v", err)
 					}
 					continue
 				}
 
 				tlsConn, ok := conn.(*tls.Conn)
 				if !ok {
-					log.Error().Msg("Failed to convert to TLS connection")
+					g.logger.Errorf("Failed to convert to TLS connection")
 					conn.Close()
 					continue
 				}
@@ -216,7 +466,8 @@ func (g *Gateway) Listen(ctx context.Context) error {
 				// Clear the deadline after handshake
 				tlsConn.SetDeadline(time.Time{})
 				if err != nil {
-					log.Error().Msgf("TLS handshake failed: %v", err)
+					g.logger.Errorf("TLS handshake failed: %v", err)
+					g.metrics.HandshakeFailed()
 					conn.Close()
 					continue
 				}
@@ -227,16 +478,32 @@ func (g *Gateway) Listen(ctx context.Context) error {
 					organizationUnit := state.PeerCertificates[0].Subject.OrganizationalUnit
 					commonName := state.PeerCertificates[0].Subject.CommonName
 					if organizationUnit[0] != "gateway-client" || commonName != "cloud" {
-						log.Error().Msgf("Client certificate verification failed. Received %s, %s", organizationUnit, commonName)
+						g.logger.Errorf("Client certificate verification failed. Received %s, %s", organizationUnit, commonName)
+						g.metrics.HandshakeFailed()
 						conn.Close()
 						continue
 					}
 				}
 
+				var peerCertificate *x509.Certificate
+				if len(state.PeerCertificates) > 0 {
+					peerCertificate = state.PeerCertificates[0]
+				}
+				info, err := newConnectionInfo(conn, peerCertificate)
+				if err != nil {
+					g.logger.Errorf("Failed to create connection ID: %v", err)
+					conn.Close()
+					continue
+				}
+
 				// Handle the connection in a goroutine
 				wg.Add(1)
+				g.metrics.ConnectionAccepted()
+				session := g.sessions.add(conn, state.PeerCertificates)
 				go func(c net.Conn) {
 					defer wg.Done()
+					defer g.metrics.ConnectionClosed()
+					defer g.sessions.remove(session)
 					defer c.Close()
 
 					// Monitor parent context to close this connection when needed
@@ -249,7 +516,7 @@ func (g *Gateway) Listen(ctx context.Context) error {
 						}
 					}()
 
-					handleConnection(c)
+					g.handleConnection(ctx, c, info)
 				}(conn)
 			}
 		}
@@ -257,7 +524,7 @@ func (g *Gateway) Listen(ctx context.Context) error {
 
 	select {
 	case <-ctx.Done():
-		log.Info().Msg("Shutting down gateway...")
+		g.logger.Infof("Shutting down gateway...")
 	case err = <-errCh:
 	}
 
@@ -275,51 +542,87 @@ func (g *Gateway) Listen(ctx context.Context) error {
 	case <-waitCh:
 		// All connections closed normally
 	case <-time.After(5 * time.Second):
-		log.Warn().Msg("Timeout waiting for connections to close gracefully")
+		g.logger.Warnf("Timeout waiting for connections to close gracefully")
 	}
 
 	return err
 }
 
-func (g *Gateway) registerHeartBeat(errCh chan error, done chan bool) {
-	ticker := time.NewTicker(1 * time.Hour)
-
+// registerHeartBeat sends a heart beat every hour. Failed heart beats are retried every heartbeat retry
+// interval, and the gateway reconnects only once the failure threshold is reached in a row, so a blip
+// of the control plane doesn't drop the connections it serves.
+func (g *Gateway) registerHeartBeat(ctx context.Context, errCh chan error, done chan bool) {
 	go func() {
-		time.Sleep(10 * time.Second)
-		log.Info().Msg("Registering first heart beat")
-		err := api.CallGatewayHeartBeatV1(g.httpClient)
-		if err != nil {
-			log.Error().Msgf("Failed to register heartbeat: %s", err)
-		}
+		timer := time.NewTimer(firstHeartbeatDelay)
+		defer timer.Stop()
 
+		failures := 0
 		for {
 			select {
 			case <-done:
-				ticker.Stop()
 				return
-			case <-ticker.C:
-				log.Info().Msg("Registering heart beat")
-				err := api.CallGatewayHeartBeatV1(g.httpClient)
-				errCh <- err
+			case <-timer.C:
+			}
+
+			g.logger.Infof("Registering heart beat")
+			next := heartbeatInterval
+
+			err := g.callHeartBeat(ctx)
+			switch {
+			case err == nil:
+				failures = 0
+			case errors.Is(err, api.ErrCircuitOpen):
+				// Keep serving with the relay allocation and certificate we already have
+				g.metrics.HeartbeatFailed()
+				g.logger.Warnf("Skipping heart beat while the Infisical API is unavailable")
+			default:
+				g.metrics.HeartbeatFailed()
+				failures++
+				if failures >= g.heartbeatFailureThreshold {
+					reportRelayError(errCh, fmt.Errorf("%d heart beats failed in a row: %w", failures, err))
+					return
+				}
+
+				g.logger.Errorf("Failed to register heartbeat (%d of %d failures before reconnecting), retrying in %s: %s", failures, g.heartbeatFailureThreshold, g.heartbeatRetryInterval, err)
+				next = g.heartbeatRetryInterval
 			}
+
+			timer.Reset(next)
 		}
 	}()
 }
 
-func (g *Gateway) registerPermissionLifecycle(permissionFn func() error, done chan bool) {
+func (g *Gateway) callHeartBeat(ctx context.Context) error {
+	apiCtx, cancel := context.WithTimeout(ctx, g.apiTimeout)
+	defer cancel()
+
+	return api.CallGatewayHeartBeatV1(apiCtx, g.httpClient)
+}
+
+func (g *Gateway) registerPermissionLifecycle(entry string, permissionFn func() error, errCh chan error, done chan bool) {
 	ticker := time.NewTicker(3 * time.Minute)
 
 	go func() {
-		// wait for 5 mins
-		permissionFn()
-		log.Printf("Created permission for incoming connections")
+		if err := permissionFn(); err != nil {
+			g.logger.Errorf("Failed to create permission for %s: %s", entry, err)
+		} else {
+			g.logger.Infof("Created permission for incoming connections from %s", entry)
+		}
+
 		for {
 			select {
 			case <-done:
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				permissionFn()
+				if err := permissionFn(); err != nil {
+					if isAllocationMismatch(err) {
+						reportRelayError(errCh, fmt.Errorf("%w: %v", errAllocationLost, err))
+						ticker.Stop()
+						return
+					}
+					g.logger.Errorf("Failed to refresh permission for %s: %s", entry, err)
+				}
 			}
 		}
 	}()
@@ -336,9 +639,12 @@ func (g *Gateway) registerRelayIsActive(serverAddr string, errCh chan error, don
 				ticker.Stop()
 				return
 			case <-ticker.C:
-				conn, err := net.Dial("tcp", serverAddr)
+				// the relay stops listening on the relay address once the allocation is gone, but the relay
+				// may as well be down, so reconnecting waits for the retry interval
+				conn, err := g.dialer.DialContext(context.Background(), "tcp", serverAddr)
 				if err != nil {
-					errCh <- err
+					reportRelayError(errCh, fmt.Errorf("relay address %s is unreachable: %w", serverAddr, err))
+					ticker.Stop()
 					return
 				}
 				if conn != nil {
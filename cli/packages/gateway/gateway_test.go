@@ -0,0 +1,51 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithStaticIpsReplacesEarlierEntries(t *testing.T) {
+	g, err := New(WithIdentityToken("token"), WithStaticIps("10.0.0.1", "10.0.1.0/24"), WithStaticIps("192.168.0.1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"192.168.0.1"}, g.extraStaticIps)
+}
+
+func TestWithCircuitBreaker(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	previousURL, previousRetryPolicy := config.INFISICAL_URL, api.DefaultRetryPolicy
+	t.Cleanup(func() {
+		config.INFISICAL_URL = previousURL
+		api.DefaultRetryPolicy = previousRetryPolicy
+	})
+	config.INFISICAL_URL = server.URL
+	api.DefaultRetryPolicy.MaxRetries = 0
+
+	failingBreaker := api.NewCircuitBreaker(2, time.Minute)
+	failing, err := New(WithIdentityToken("token"), WithCircuitBreaker(failingBreaker))
+	assert.NoError(t, err)
+	otherBreaker := api.NewCircuitBreaker(2, time.Minute)
+	other, err := New(WithIdentityToken("token"), WithCircuitBreaker(otherBreaker))
+	assert.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		assert.Error(t, failing.callHeartBeat(context.Background()))
+	}
+	assert.Equal(t, api.CircuitOpen, failingBreaker.State())
+	assert.ErrorIs(t, failing.callHeartBeat(context.Background()), api.ErrCircuitOpen)
+
+	assert.Equal(t, api.CircuitClosed, otherBreaker.State(), "the failures of a gateway don't pause the others")
+	assert.Equal(t, api.CircuitClosed, api.DefaultCircuitBreaker.State())
+	assert.NotErrorIs(t, other.callHeartBeat(context.Background()), api.ErrCircuitOpen)
+}
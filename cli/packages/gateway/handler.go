@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"time"
+)
+
+// The commands clients send to open a route through the gateway
+const (
+	CommandForwardTCP = "FORWARD-TCP"
+	CommandForwardTLS = "FORWARD-TLS"
+)
+
+// ConnectionHandler serves a connection once its route is negotiated. The context carries the
+// ConnectionInfo of the connection, and reads from conn include the bytes the client sent right after
+// the route. The connection is closed when HandleConnection returns.
+type ConnectionHandler interface {
+	HandleConnection(ctx context.Context, conn net.Conn)
+}
+
+// ConnectionHandlerFunc adapts a function to a ConnectionHandler.
+type ConnectionHandlerFunc func(ctx context.Context, conn net.Conn)
+
+func (f ConnectionHandlerFunc) HandleConnection(ctx context.Context, conn net.Conn) {
+	f(ctx, conn)
+}
+
+// PeerIdentity is the identity of the verified client certificate of a connection.
+type PeerIdentity struct {
+	CommonName         string
+	OrganizationalUnit []string
+	SerialNumber       string
+	Certificate        *x509.Certificate
+}
+
+// Route is what the client asked the gateway to connect to.
+type Route struct {
+	// Command is CommandForwardTCP or CommandForwardTLS
+	Command string
+	// Target is the host:port to forward to
+	Target string
+	// TLSMode is set for CommandForwardTLS
+	TLSMode TLSMode
+}
+
+// ConnectionInfo describes a connection accepted from the relay.
+type ConnectionInfo struct {
+	ID            string
+	RemoteAddress string
+	AcceptedAt    time.Time
+	Peer          PeerIdentity
+	Route         Route
+}
+
+type connectionInfoKey struct{}
+
+// ConnectionInfoFromContext returns the ConnectionInfo of the connection a handler serves.
+func ConnectionInfoFromContext(ctx context.Context) (ConnectionInfo, bool) {
+	info, ok := ctx.Value(connectionInfoKey{}).(ConnectionInfo)
+	return info, ok
+}
+
+func withConnectionInfo(ctx context.Context, info ConnectionInfo) context.Context {
+	return context.WithValue(ctx, connectionInfoKey{}, info)
+}
+
+// WithConnectionMiddleware wraps the handler that forwards connections to their target, e.g. to
+// audit connections or to refuse routes depending on the peer. Middlewares run in the order they are
+// added, and a middleware that doesn't call next ends the connection.
+func WithConnectionMiddleware(middleware func(next ConnectionHandler) ConnectionHandler) Option {
+	return func(g *Gateway) {
+		g.connectionMiddlewares = append(g.connectionMiddlewares, middleware)
+	}
+}
+
+// buildConnectionHandler returns the forwarding handler wrapped in the middlewares
+func (g *Gateway) buildConnectionHandler() ConnectionHandler {
+	var handler ConnectionHandler = ConnectionHandlerFunc(g.forwardConnection)
+	for i := len(g.connectionMiddlewares) - 1; i >= 0; i-- {
+		handler = g.connectionMiddlewares[i](handler)
+	}
+	return handler
+}
+
+func newConnectionInfo(conn net.Conn, peerCertificate *x509.Certificate) (ConnectionInfo, error) {
+	randomBytes := make([]byte, 8)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return ConnectionInfo{}, err
+	}
+
+	info := ConnectionInfo{
+		ID:            hex.EncodeToString(randomBytes),
+		RemoteAddress: conn.RemoteAddr().String(),
+		AcceptedAt:    time.Now(),
+	}
+	if peerCertificate != nil {
+		info.Peer = PeerIdentity{
+			CommonName:         peerCertificate.Subject.CommonName,
+			OrganizationalUnit: peerCertificate.Subject.OrganizationalUnit,
+			SerialNumber:       peerCertificate.SerialNumber.String(),
+			Certificate:        peerCertificate,
+		}
+	}
+	return info, nil
+}
+
+// bufferedConn reads through the reader the route was read with, so the bytes it buffered past the
+// route reach the handler
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(CloseWrite); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
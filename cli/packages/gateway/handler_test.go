@@ -0,0 +1,205 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/x509"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingMiddleware records its name before and after calling next, and ends the connection
+// instead when stop is set
+func recordingMiddleware(name string, stop bool, calls *[]string) func(next ConnectionHandler) ConnectionHandler {
+	return func(next ConnectionHandler) ConnectionHandler {
+		return ConnectionHandlerFunc(func(ctx context.Context, conn net.Conn) {
+			*calls = append(*calls, name)
+			if stop {
+				return
+			}
+			next.HandleConnection(ctx, conn)
+			*calls = append(*calls, name+" done")
+		})
+	}
+}
+
+func TestBuildConnectionHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		stopAt    string
+		wantCalls []string
+	}{
+		{name: "every middleware calls next", wantCalls: []string{"first", "second", "third", "third done", "second done", "first done"}},
+		{name: "first middleware ends the connection", stopAt: "first", wantCalls: []string{"first"}},
+		{name: "middle middleware ends the connection", stopAt: "second", wantCalls: []string{"first", "second", "first done"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			var opts []Option
+			for _, name := range []string{"first", "second", "third"} {
+				opts = append(opts, WithConnectionMiddleware(recordingMiddleware(name, name == test.stopAt, &calls)))
+			}
+			g, err := New(append([]Option{WithIdentityToken("token")}, opts...)...)
+			assert.NoError(t, err)
+
+			conn, peer := net.Pipe()
+			defer conn.Close()
+			defer peer.Close()
+
+			// the route has no command, so the forwarding handler at the end of the chain returns
+			// without dialing anything
+			g.connectionHandler.HandleConnection(withConnectionInfo(context.Background(), ConnectionInfo{ID: "connection"}), conn)
+			assert.Equal(t, test.wantCalls, calls)
+		})
+	}
+}
+
+func TestHandleConnectionRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   string
+		wantRoute Route
+		// wantRead is what the handler reads after the route
+		wantRead string
+		// wantHandled is false when the command never reaches the handler
+		wantHandled bool
+	}{
+		{
+			name:        "tcp",
+			request:     "FORWARD-TCP db.internal:5432\nhello",
+			wantRoute:   Route{Command: CommandForwardTCP, Target: "db.internal:5432"},
+			wantRead:    "hello",
+			wantHandled: true,
+		},
+		{
+			name:        "tls without a mode",
+			request:     "FORWARD-TLS api.internal:443\n",
+			wantRoute:   Route{Command: CommandForwardTLS, Target: "api.internal:443", TLSMode: TLSModeTerminate},
+			wantHandled: true,
+		},
+		{
+			name:        "tls with a mode",
+			request:     "FORWARD-TLS api.internal:443 PASSTHROUGH\nclient hello",
+			wantRoute:   Route{Command: CommandForwardTLS, Target: "api.internal:443", TLSMode: TLSMode("passthrough")},
+			wantRead:    "client hello",
+			wantHandled: true,
+		},
+		{name: "ping", request: "PING\n"},
+		{name: "unknown command", request: "FORWARD-UDP dns.internal:53\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handled := false
+			var gotInfo ConnectionInfo
+			var gotRead string
+			g, err := New(WithIdentityToken("token"), WithConnectionMiddleware(func(next ConnectionHandler) ConnectionHandler {
+				return ConnectionHandlerFunc(func(ctx context.Context, conn net.Conn) {
+					handled = true
+					gotInfo, _ = ConnectionInfoFromContext(ctx)
+					read, _ := io.ReadAll(conn)
+					gotRead = string(read)
+				})
+			}))
+			assert.NoError(t, err)
+
+			conn, peer := net.Pipe()
+			go func() {
+				peer.Write([]byte(test.request))
+				// drain the PONG of a ping before closing
+				peer.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+				io.Copy(io.Discard, peer)
+				peer.Close()
+			}()
+
+			g.handleConnection(context.Background(), conn, ConnectionInfo{ID: "connection", RemoteAddress: "pipe"})
+
+			assert.Equal(t, test.wantHandled, handled)
+			if test.wantHandled {
+				assert.Equal(t, test.wantRoute, gotInfo.Route)
+				assert.Equal(t, "connection", gotInfo.ID)
+				assert.Equal(t, test.wantRead, gotRead)
+			}
+		})
+	}
+}
+
+func TestConnectionInfoFromContext(t *testing.T) {
+	_, ok := ConnectionInfoFromContext(context.Background())
+	assert.False(t, ok)
+
+	info := ConnectionInfo{ID: "connection", Route: Route{Command: CommandForwardTCP, Target: "db.internal:5432"}}
+	got, ok := ConnectionInfoFromContext(withConnectionInfo(context.Background(), info))
+	assert.True(t, ok)
+	assert.Equal(t, info, got)
+}
+
+func TestNewConnectionInfo(t *testing.T) {
+	ca, caKey := newTestCertificate(t, 1, nil, nil, nil)
+	client, _ := newTestCertificate(t, 42, ca, caKey, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+
+	tests := []struct {
+		name            string
+		peerCertificate *x509.Certificate
+		wantPeer        PeerIdentity
+	}{
+		{name: "no peer certificate"},
+		{
+			name:            "peer certificate",
+			peerCertificate: client,
+			wantPeer:        PeerIdentity{CommonName: "cloud", OrganizationalUnit: []string{"gateway-client"}, SerialNumber: "42", Certificate: client},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, peer := net.Pipe()
+			defer conn.Close()
+			defer peer.Close()
+
+			info, err := newConnectionInfo(conn, test.peerCertificate)
+			assert.NoError(t, err)
+			assert.Len(t, info.ID, 16)
+			assert.Equal(t, conn.RemoteAddr().String(), info.RemoteAddress)
+			assert.WithinDuration(t, time.Now(), info.AcceptedAt, time.Minute)
+			assert.Equal(t, test.wantPeer, info.Peer)
+		})
+	}
+
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+	first, err := newConnectionInfo(conn, nil)
+	assert.NoError(t, err)
+	second, err := newConnectionInfo(conn, nil)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.ID, second.ID)
+}
+
+func TestBufferedConnReadsBufferedBytesFirst(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer conn.Close()
+
+	go func() {
+		peer.Write([]byte("FORWARD-TCP db.internal:5432\nbuffered"))
+		peer.Write([]byte(" and later"))
+		peer.Close()
+	}()
+
+	reader := bufio.NewReader(conn)
+	route, err := reader.ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "FORWARD-TCP db.internal:5432\n", route)
+
+	var rest strings.Builder
+	_, err = io.Copy(&rest, &bufferedConn{Conn: conn, reader: reader})
+	assert.NoError(t, err)
+	assert.Equal(t, "buffered and later", rest.String())
+}
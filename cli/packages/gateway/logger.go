@@ -0,0 +1,46 @@
+package gateway
+
+import (
+	"github.com/rs/zerolog"
+)
+
+// Logger is the logging surface used by the gateway. The package never writes to a global logger,
+// so embedders decide where output goes.
+type Logger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(format string, args ...interface{}) {}
+func (nopLogger) Infof(format string, args ...interface{})  {}
+func (nopLogger) Warnf(format string, args ...interface{})  {}
+func (nopLogger) Errorf(format string, args ...interface{}) {}
+
+type zerologLogger struct {
+	logger zerolog.Logger
+}
+
+// NewZerologLogger adapts a zerolog logger to the gateway Logger interface.
+func NewZerologLogger(logger zerolog.Logger) Logger {
+	return &zerologLogger{logger: logger}
+}
+
+func (z *zerologLogger) Debugf(format string, args ...interface{}) {
+	z.logger.Debug().Msgf(format, args...)
+}
+
+func (z *zerologLogger) Infof(format string, args ...interface{}) {
+	z.logger.Info().Msgf(format, args...)
+}
+
+func (z *zerologLogger) Warnf(format string, args ...interface{}) {
+	z.logger.Warn().Msgf(format, args...)
+}
+
+func (z *zerologLogger) Errorf(format string, args ...interface{}) {
+	z.logger.Error().Msgf(format, args...)
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"sync"
+	"time"
+)
+
+// maintenanceRejection is sent to clients that open a route while the gateway is draining
+const maintenanceRejection = "ERROR gateway draining\n"
+
+type maintenanceMode struct {
+	mutex   sync.Mutex
+	enabled bool
+	until   time.Time
+	timer   *time.Timer
+}
+
+// EnterMaintenance puts the gateway in maintenance mode, e.g. before patching its host: new
+// connections are refused with a draining error while the ones already open keep being served.
+// Maintenance mode ends by itself after duration, or only with ExitMaintenance when it is zero.
+func (g *Gateway) EnterMaintenance(duration time.Duration) {
+	g.maintenance.mutex.Lock()
+	defer g.maintenance.mutex.Unlock()
+
+	if g.maintenance.timer != nil {
+		g.maintenance.timer.Stop()
+		g.maintenance.timer = nil
+	}
+
+	g.maintenance.enabled = true
+	g.maintenance.until = time.Time{}
+	if duration > 0 {
+		g.maintenance.until = time.Now().Add(duration)
+		until := g.maintenance.until
+		g.maintenance.timer = time.AfterFunc(duration, func() { g.expireMaintenance(until) })
+		g.logger.Infof("Entering maintenance mode until %s. New connections are refused, open ones keep being served", g.maintenance.until.Format(time.RFC3339))
+		return
+	}
+	g.logger.Infof("Entering maintenance mode. New connections are refused, open ones keep being served")
+}
+
+// ExitMaintenance makes the gateway accept new connections again.
+func (g *Gateway) ExitMaintenance() {
+	g.maintenance.mutex.Lock()
+	defer g.maintenance.mutex.Unlock()
+
+	if g.maintenance.timer != nil {
+		g.maintenance.timer.Stop()
+		g.maintenance.timer = nil
+	}
+
+	if g.maintenance.enabled {
+		g.maintenance.enabled = false
+		g.logger.Infof("Leaving maintenance mode, accepting new connections")
+	}
+}
+
+// expireMaintenance ends the maintenance mode that was entered until then, unless it was entered again
+func (g *Gateway) expireMaintenance(until time.Time) {
+	g.maintenance.mutex.Lock()
+	defer g.maintenance.mutex.Unlock()
+
+	if g.maintenance.enabled && g.maintenance.until.Equal(until) {
+		g.maintenance.enabled = false
+		g.maintenance.timer = nil
+		g.logger.Infof("Maintenance mode ended, accepting new connections")
+	}
+}
+
+// InMaintenance reports whether the gateway refuses new connections.
+func (g *Gateway) InMaintenance() bool {
+	g.maintenance.mutex.Lock()
+	defer g.maintenance.mutex.Unlock()
+	return g.maintenance.enabled
+}
@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/go-resty/resty/v2"
+)
+
+// Dialer opens outbound connections on behalf of the gateway. *net.Dialer satisfies it.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Metrics receives gateway lifecycle events. Implementations must be safe for concurrent use.
+type Metrics interface {
+	ConnectionAccepted()
+	ConnectionClosed()
+	HandshakeFailed()
+	HeartbeatFailed()
+	RelayReconnected()
+}
+
+// CircuitBreakerMetrics can optionally be implemented by a Metrics to track the state of the API
+// circuit breaker ("closed", "open" or "half-open").
+type CircuitBreakerMetrics interface {
+	CircuitBreakerStateChanged(state string)
+}
+
+// SessionMetrics can optionally be implemented by a Metrics to count the sessions closed because they
+// were no longer authorized.
+type SessionMetrics interface {
+	SessionRevoked()
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) ConnectionAccepted() {}
+func (nopMetrics) ConnectionClosed()   {}
+func (nopMetrics) HandshakeFailed()    {}
+func (nopMetrics) HeartbeatFailed()    {}
+func (nopMetrics) RelayReconnected()   {}
+
+type Option func(*Gateway)
+
+// WithIdentityToken sets the machine identity access token used to talk to Infisical.
+func WithIdentityToken(token string) Option {
+	return func(g *Gateway) {
+		g.identityToken = token
+	}
+}
+
+// WithIdentityTokenSource reads the identity token before every call to Infisical, for tokens that are
+// renewed while the gateway runs. It takes precedence over WithIdentityToken.
+func WithIdentityTokenSource(tokenSource func() string) Option {
+	return func(g *Gateway) {
+		g.identityTokenSource = tokenSource
+	}
+}
+
+// WithHTTPClient replaces the resty client used for control-plane calls.
+// The identity token, if set, is applied on top of it.
+func WithHTTPClient(httpClient *resty.Client) Option {
+	return func(g *Gateway) {
+		g.httpClient = httpClient
+	}
+}
+
+// WithCircuitBreaker gives the gateway a circuit breaker of its own, instead of the one shared by every
+// client of the process, so the failures of one gateway don't pause the others. It has no effect with
+// WithHTTPClient.
+func WithCircuitBreaker(breaker *api.CircuitBreaker) Option {
+	return func(g *Gateway) {
+		g.circuitBreaker = breaker
+	}
+}
+
+func WithLogger(logger Logger) Option {
+	return func(g *Gateway) {
+		g.logger = logger
+	}
+}
+
+func WithMetrics(metrics Metrics) Option {
+	return func(g *Gateway) {
+		g.metrics = metrics
+	}
+}
+
+// WithDialer sets the dialer used to reach the relay and forwarded targets.
+func WithDialer(dialer Dialer) Option {
+	return func(g *Gateway) {
+		g.dialer = dialer
+	}
+}
+
+// WithRetryInterval sets how long Start waits before reconnecting after the relay connection drops.
+func WithRetryInterval(interval time.Duration) Option {
+	return func(g *Gateway) {
+		g.retryInterval = interval
+	}
+}
+
+// WithAPITimeout bounds each control-plane call made by the gateway, including its retries.
+func WithAPITimeout(timeout time.Duration) Option {
+	return func(g *Gateway) {
+		g.apiTimeout = timeout
+	}
+}
+
+// WithHeartbeatFailureThreshold sets how many heart beats must fail in a row before the gateway
+// reconnects, and how long it waits to retry a failed heart beat.
+func WithHeartbeatFailureThreshold(failures int, retryInterval time.Duration) Option {
+	return func(g *Gateway) {
+		g.heartbeatFailureThreshold = failures
+		g.heartbeatRetryInterval = retryInterval
+	}
+}
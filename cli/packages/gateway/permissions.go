@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// TURN permissions are per IP address, so CIDR ranges are expanded. Keep this small so a mistyped
+// prefix can't turn into thousands of permission entries on the relay.
+const maxStaticIpRangeSize = 256
+
+// WithStaticIps sets addresses that may reach the gateway through the relay, on top of the ones
+// returned by Infisical. Entries are IPs, ip:port pairs or CIDR ranges. They replace the entries of an
+// earlier WithStaticIps.
+func WithStaticIps(entries ...string) Option {
+	return func(g *Gateway) {
+		g.extraStaticIps = append([]string{}, entries...)
+	}
+}
+
+func splitStaticIpEntries(raw string) []string {
+	var entries []string
+	for _, entry := range strings.Split(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// parseStaticIpEntry turns a single entry into the peer addresses a permission must be created for.
+// A missing port allows every port.
+func parseStaticIpEntry(entry string) ([]net.Addr, error) {
+	if strings.Contains(entry, "/") {
+		return expandStaticIpRange(entry)
+	}
+
+	if ip := net.ParseIP(entry); ip != nil {
+		return []net.Addr{&net.TCPAddr{IP: ip}}, nil
+	}
+
+	peerAddr, err := net.ResolveTCPAddr("tcp", entry)
+	if err != nil {
+		return nil, err
+	}
+
+	return []net.Addr{peerAddr}, nil
+}
+
+func expandStaticIpRange(cidr string) ([]net.Addr, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones > 8 {
+		return nil, fmt.Errorf("range %s is larger than the supported %d addresses", cidr, maxStaticIpRangeSize)
+	}
+
+	var addrs []net.Addr
+	for ip := ipNet.IP.Mask(ipNet.Mask); ipNet.Contains(ip); ip = nextIp(ip) {
+		addrs = append(addrs, &net.TCPAddr{IP: ip})
+	}
+
+	return addrs, nil
+}
+
+func nextIp(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+	return next
+}
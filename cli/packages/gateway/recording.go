@@ -0,0 +1,369 @@
+package gateway
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/crypto"
+)
+
+type SessionDirection byte
+
+const (
+	SessionDirectionMetadata       SessionDirection = 0
+	SessionDirectionClientToTarget SessionDirection = 1
+	SessionDirectionTargetToClient SessionDirection = 2
+	// SessionDirectionEnd is the empty last frame of a complete recording
+	SessionDirectionEnd SessionDirection = 3
+)
+
+const sessionRecordingFileSuffix = ".rec"
+
+// maxSessionFrameData is the most data a frame holds, larger writes are split across frames
+const maxSessionFrameData = 1 << 20
+
+// sessionFrameHeaderSize is nonce(16) | tag(16) | length(4), and sessionFramePlaintextOverhead is
+// direction(1) | unix nanos(8)
+const (
+	sessionFrameHeaderSize        = 36
+	sessionFramePlaintextOverhead = 9
+)
+
+// SessionRecordingConfig enables recording of proxied traffic for designated targets. Recordings are
+// raw byte streams in both directions; each chunk is encrypted with AES-GCM before it touches disk, bound
+// to the session and to its position in the recording, and a recording ends with an authenticated frame.
+type SessionRecordingConfig struct {
+	// Directory where recordings are written. Created with 0700 permissions when missing.
+	Directory string
+	// Targets to record, as host:port or bare host. A "*" entry records every target.
+	Targets []string
+	// EncryptionKey must be 16, 24 or 32 bytes long.
+	EncryptionKey []byte
+	// Retention is how long finished recordings are kept on disk. Zero keeps them forever.
+	Retention time.Duration
+	// UploadHook, when set, is called in the background with the path of every finished recording.
+	UploadHook func(path string) error
+}
+
+// SessionFrame is a single decrypted chunk of a recording.
+type SessionFrame struct {
+	Direction SessionDirection
+	Timestamp time.Time
+	Data      []byte
+}
+
+type sessionMetadata struct {
+	SessionID     string    `json:"sessionId"`
+	Target        string    `json:"target"`
+	RemoteAddress string    `json:"remoteAddress"`
+	StartedAt     time.Time `json:"startedAt"`
+}
+
+// WithSessionRecording records sessions to the targets listed in config.
+func WithSessionRecording(config SessionRecordingConfig) Option {
+	return func(g *Gateway) {
+		g.sessionRecording = &config
+	}
+}
+
+func validateSessionRecordingConfig(config *SessionRecordingConfig) error {
+	if config.Directory == "" {
+		return fmt.Errorf("session recording directory is required")
+	}
+
+	switch len(config.EncryptionKey) {
+	case 16, 24, 32:
+	default:
+		return fmt.Errorf("session recording encryption key must be 16, 24 or 32 bytes, got %d", len(config.EncryptionKey))
+	}
+
+	return os.MkdirAll(config.Directory, 0700)
+}
+
+func (c *SessionRecordingConfig) shouldRecord(target string) bool {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+
+	for _, entry := range c.Targets {
+		if entry == "*" || strings.EqualFold(entry, target) || strings.EqualFold(entry, host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type sessionRecorder struct {
+	config    *SessionRecordingConfig
+	file      *os.File
+	path      string
+	sessionID string
+	mutex     sync.Mutex
+	// frames is the number of frames written, the position of the next one
+	frames uint64
+	err    error
+}
+
+// newSessionRecorder starts the recording of a session, named after the ID of its connection
+func newSessionRecorder(config *SessionRecordingConfig, sessionID string, target string, remoteAddress string) (*sessionRecorder, error) {
+	startedAt := time.Now().UTC()
+	fileName := fmt.Sprintf("session-%s-%s-%s%s", startedAt.Format("20060102T150405Z"), sanitizeRecordingName(target), sessionID, sessionRecordingFileSuffix)
+	path := filepath.Join(config.Directory, fileName)
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create session recording file: %w", err)
+	}
+
+	recorder := &sessionRecorder{config: config, file: file, path: path, sessionID: sessionID}
+
+	metadata, _ := json.Marshal(sessionMetadata{
+		SessionID:     sessionID,
+		Target:        target,
+		RemoteAddress: remoteAddress,
+		StartedAt:     startedAt,
+	})
+	recorder.record(SessionDirectionMetadata, metadata)
+
+	if recorder.err != nil {
+		file.Close()
+		return nil, recorder.err
+	}
+
+	return recorder, nil
+}
+
+func sanitizeRecordingName(target string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+			return r
+		}
+		return '_'
+	}, target)
+}
+
+// sessionFrameAdditionalData binds a frame to its session and position. The metadata frame comes first
+// and holds the session ID, so it is only bound to its position.
+func sessionFrameAdditionalData(sessionID string, position uint64) []byte {
+	if position == 0 {
+		sessionID = ""
+	}
+	return binary.BigEndian.AppendUint64([]byte(sessionID), position)
+}
+
+// record appends data in encrypted frames of at most maxSessionFrameData bytes
+func (r *sessionRecorder) record(direction SessionDirection, data []byte) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for {
+		chunk := data
+		if len(chunk) > maxSessionFrameData {
+			chunk = chunk[:maxSessionFrameData]
+		}
+		r.writeFrame(direction, chunk)
+
+		data = data[len(chunk):]
+		if len(data) == 0 {
+			return
+		}
+	}
+}
+
+// writeFrame appends an encrypted frame: nonce(16) | tag(16) | length(4) | ciphertext.
+// The plaintext is direction(1) | unix nanos(8) | data.
+func (r *sessionRecorder) writeFrame(direction SessionDirection, data []byte) {
+	if r.err != nil {
+		return
+	}
+
+	plaintext := make([]byte, sessionFramePlaintextOverhead+len(data))
+	plaintext[0] = byte(direction)
+	binary.BigEndian.PutUint64(plaintext[1:9], uint64(time.Now().UnixNano()))
+	copy(plaintext[9:], data)
+
+	encrypted, err := crypto.EncryptSymmetricWithAdditionalData(plaintext, r.config.EncryptionKey, sessionFrameAdditionalData(r.sessionID, r.frames))
+	if err != nil {
+		r.err = err
+		return
+	}
+
+	frame := make([]byte, 0, len(encrypted.Nonce)+len(encrypted.AuthTag)+4+len(encrypted.CipherText))
+	frame = append(frame, encrypted.Nonce...)
+	frame = append(frame, encrypted.AuthTag...)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(encrypted.CipherText)))
+	frame = append(frame, encrypted.CipherText...)
+
+	if _, err := r.file.Write(frame); err != nil {
+		r.err = err
+		return
+	}
+	r.frames++
+}
+
+// close ends the recording with the end frame, so that readers can tell it wasn't cut short
+func (r *sessionRecorder) close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.writeFrame(SessionDirectionEnd, nil)
+	if err := r.file.Close(); err != nil && r.err == nil {
+		r.err = err
+	}
+	return r.err
+}
+
+// recordedConn records everything written to the wrapped connection
+type recordedConn struct {
+	net.Conn
+	recorder  *sessionRecorder
+	direction SessionDirection
+}
+
+func (c *recordedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.recorder.record(c.direction, b[:n])
+	}
+	return n, err
+}
+
+func (c *recordedConn) CloseWrite() error {
+	if cw, ok := c.Conn.(CloseWrite); ok {
+		return cw.CloseWrite()
+	}
+	return nil
+}
+
+// recordSession wraps both legs of a forwarded session when the target is designated for recording.
+// The returned finish func must be called once the session is over.
+func (g *Gateway) recordSession(sessionID string, target string, clientConn net.Conn, targetConn net.Conn) (net.Conn, net.Conn, func()) {
+	if g.sessionRecording == nil || !g.sessionRecording.shouldRecord(target) {
+		return clientConn, targetConn, func() {}
+	}
+
+	recorder, err := newSessionRecorder(g.sessionRecording, sessionID, target, clientConn.RemoteAddr().String())
+	if err != nil {
+		g.logger.Errorf("Unable to start session recording for %s: %v", target, err)
+		return clientConn, targetConn, func() {}
+	}
+
+	g.logger.Infof("Recording session to %s at %s", target, recorder.path)
+
+	finish := func() {
+		if err := recorder.close(); err != nil {
+			g.logger.Errorf("Session recording %s is incomplete: %v", recorder.path, err)
+		}
+
+		if g.sessionRecording.UploadHook != nil {
+			go func() {
+				if err := g.sessionRecording.UploadHook(recorder.path); err != nil {
+					g.logger.Errorf("Session recording upload hook failed for %s: %v", recorder.path, err)
+				}
+			}()
+		}
+
+		g.pruneSessionRecordings()
+	}
+
+	return &recordedConn{Conn: clientConn, recorder: recorder, direction: SessionDirectionTargetToClient},
+		&recordedConn{Conn: targetConn, recorder: recorder, direction: SessionDirectionClientToTarget},
+		finish
+}
+
+// pruneSessionRecordings removes recordings older than the configured retention
+func (g *Gateway) pruneSessionRecordings() {
+	if g.sessionRecording == nil || g.sessionRecording.Retention <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(g.sessionRecording.Directory)
+	if err != nil {
+		g.logger.Errorf("Unable to read session recording directory: %v", err)
+		return
+	}
+
+	cutoff := time.Now().Add(-g.sessionRecording.Retention)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), sessionRecordingFileSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+
+		path := filepath.Join(g.sessionRecording.Directory, entry.Name())
+		if err := os.Remove(path); err != nil {
+			g.logger.Errorf("Unable to remove expired session recording %s: %v", path, err)
+		}
+	}
+}
+
+// ReadSessionRecording decrypts a recording produced by the gateway and calls fn for each frame in order.
+// It fails when frames were reordered, removed or taken from another recording, or when the recording
+// doesn't end with its end frame, which the gateway writes once the session is over.
+func ReadSessionRecording(reader io.Reader, encryptionKey []byte, fn func(SessionFrame) error) error {
+	header := make([]byte, sessionFrameHeaderSize)
+	var sessionID string
+	for position := uint64(0); ; position++ {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("truncated session recording: it has no end frame")
+			}
+			return fmt.Errorf("truncated session recording: %w", err)
+		}
+
+		nonce, tag := header[:16], header[16:32]
+		length := binary.BigEndian.Uint32(header[32:36])
+		if length < sessionFramePlaintextOverhead || length > maxSessionFrameData+sessionFramePlaintextOverhead {
+			return fmt.Errorf("malformed session recording frame: invalid length %d", length)
+		}
+		cipherText := make([]byte, length)
+		if _, err := io.ReadFull(reader, cipherText); err != nil {
+			return fmt.Errorf("truncated session recording: %w", err)
+		}
+
+		plaintext, err := crypto.DecryptSymmetricWithAdditionalData(encryptionKey, cipherText, tag, nonce, sessionFrameAdditionalData(sessionID, position))
+		if err != nil {
+			return fmt.Errorf("unable to decrypt session recording frame %d, the recording was altered or the key is wrong: %w", position, err)
+		}
+
+		frame := SessionFrame{
+			Direction: SessionDirection(plaintext[0]),
+			Timestamp: time.Unix(0, int64(binary.BigEndian.Uint64(plaintext[1:9]))),
+			Data:      plaintext[9:],
+		}
+
+		if position == 0 {
+			var metadata sessionMetadata
+			if frame.Direction != SessionDirectionMetadata || json.Unmarshal(frame.Data, &metadata) != nil || metadata.SessionID == "" {
+				return fmt.Errorf("malformed session recording: it doesn't start with its metadata")
+			}
+			sessionID = metadata.SessionID
+		}
+
+		if frame.Direction == SessionDirectionEnd {
+			if _, err := io.ReadFull(reader, make([]byte, 1)); err == nil {
+				return fmt.Errorf("malformed session recording: data after the end frame")
+			}
+			return nil
+		}
+
+		if err := fn(frame); err != nil {
+			return err
+		}
+	}
+}
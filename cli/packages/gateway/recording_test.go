@@ -0,0 +1,88 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var recordingTestKey = bytes.Repeat([]byte{7}, 32)
+
+// newTestRecording records a session with writes and returns the recording split in its frames
+func newTestRecording(t *testing.T, sessionID string, writes ...[]byte) [][]byte {
+	config := &SessionRecordingConfig{Directory: t.TempDir(), EncryptionKey: recordingTestKey}
+	recorder, err := newSessionRecorder(config, sessionID, "db.internal:5432", "10.0.0.1:4000")
+	assert.NoError(t, err)
+	for _, write := range writes {
+		recorder.record(SessionDirectionClientToTarget, write)
+	}
+	assert.NoError(t, recorder.close())
+
+	content, err := os.ReadFile(recorder.path)
+	assert.NoError(t, err)
+
+	var frames [][]byte
+	for len(content) > 0 {
+		size := sessionFrameHeaderSize + int(binary.BigEndian.Uint32(content[32:36]))
+		frames = append(frames, content[:size])
+		content = content[size:]
+	}
+	return frames
+}
+
+func readTestRecording(frames [][]byte) ([]SessionFrame, error) {
+	var read []SessionFrame
+	err := ReadSessionRecording(bytes.NewReader(bytes.Join(frames, nil)), recordingTestKey, func(frame SessionFrame) error {
+		read = append(read, frame)
+		return nil
+	})
+	return read, err
+}
+
+func TestSessionRecordingRoundTrip(t *testing.T) {
+	large := bytes.Repeat([]byte("x"), maxSessionFrameData+10)
+	frames := newTestRecording(t, "session", []byte("SELECT 1"), large)
+	// metadata, one frame for the first write, two for the large one and the end frame
+	assert.Len(t, frames, 5)
+
+	read, err := readTestRecording(frames)
+	assert.NoError(t, err)
+	assert.Len(t, read, 4)
+	assert.Equal(t, SessionDirectionMetadata, read[0].Direction)
+	assert.Contains(t, string(read[0].Data), `"sessionId":"session"`)
+	assert.Equal(t, []byte("SELECT 1"), read[1].Data)
+	assert.Equal(t, large, append(append([]byte{}, read[2].Data...), read[3].Data...))
+}
+
+func TestReadSessionRecordingDetectsTampering(t *testing.T) {
+	frames := newTestRecording(t, "session", []byte("first"), []byte("second"), []byte("third"))
+	otherFrames := newTestRecording(t, "other", []byte("first"), []byte("second"), []byte("third"))
+
+	oversized := append([]byte{}, frames[1][:sessionFrameHeaderSize]...)
+	binary.BigEndian.PutUint32(oversized[32:36], 0xffffffff)
+
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		wantErr string
+	}{
+		{name: "cut at a frame boundary", frames: frames[:4], wantErr: "it has no end frame"},
+		{name: "cut inside a frame", frames: [][]byte{frames[0], frames[1][:20]}, wantErr: "truncated session recording"},
+		{name: "reordered frames", frames: [][]byte{frames[0], frames[2], frames[1], frames[3], frames[4]}, wantErr: "unable to decrypt session recording frame 1"},
+		{name: "deleted frame", frames: [][]byte{frames[0], frames[1], frames[3], frames[4]}, wantErr: "unable to decrypt session recording frame 2"},
+		{name: "frame of another recording", frames: [][]byte{frames[0], otherFrames[1], frames[2], frames[3], frames[4]}, wantErr: "unable to decrypt session recording frame 1"},
+		{name: "metadata of another recording", frames: append([][]byte{otherFrames[0]}, frames[1:]...), wantErr: "unable to decrypt session recording frame 1"},
+		{name: "data after the end frame", frames: append(append([][]byte{}, frames...), frames[1]), wantErr: "data after the end frame"},
+		{name: "oversized frame", frames: [][]byte{frames[0], oversized}, wantErr: "invalid length"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readTestRecording(test.frames)
+			assert.ErrorContains(t, err, test.wantErr)
+		})
+	}
+}
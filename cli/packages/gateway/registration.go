@@ -0,0 +1,128 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/Infisical/infisical-merge/packages/config"
+)
+
+// WithRegistrationCache saves the relay details of every registration of the identity in dir, so Bench
+// and Diagnose reuse them instead of registering the identity again next to a running gateway.
+func WithRegistrationCache(dir string) Option {
+	return func(g *Gateway) {
+		g.registrationCacheDir = dir
+	}
+}
+
+// registerIdentity registers the identity as a gateway and saves the relay details it is given
+func (g *Gateway) registerIdentity(ctx context.Context) (*api.GetRelayCredentialsResponseV1, error) {
+	apiCtx, cancel := context.WithTimeout(ctx, g.apiTimeout)
+	defer cancel()
+
+	relayDetails, err := api.CallRegisterGatewayIdentityV1(apiCtx, g.httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := g.saveRegistration(relayDetails); err != nil {
+		g.logger.Warnf("Unable to save the relay registration: %s", err)
+	}
+	return relayDetails, nil
+}
+
+// savedRegistration returns the relay details of the last registration of the identity, or nil when it
+// hasn't registered from this host
+func (g *Gateway) savedRegistration() *api.GetRelayCredentialsResponseV1 {
+	path := g.registrationPath()
+	if path == "" {
+		return nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	var relayDetails api.GetRelayCredentialsResponseV1
+	if err := json.Unmarshal(content, &relayDetails); err != nil || relayDetails.TurnServerAddress == "" {
+		g.logger.Debugf("Ignoring the invalid relay registration in %s", path)
+		return nil
+	}
+	return &relayDetails
+}
+
+func (g *Gateway) saveRegistration(relayDetails *api.GetRelayCredentialsResponseV1) error {
+	path := g.registrationPath()
+	if path == "" {
+		return nil
+	}
+
+	content, err := json.Marshal(relayDetails)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	// write next to the file and rename, so a concurrent bench never reads half of it
+	temporaryPath := path + ".tmp"
+	if err := os.WriteFile(temporaryPath, content, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temporaryPath, path)
+}
+
+// registrationPath is the file of the registrations of the identity with this Infisical instance, or
+// empty when there's no cache or the token doesn't name its identity
+func (g *Gateway) registrationPath() string {
+	if g.registrationCacheDir == "" {
+		return ""
+	}
+
+	identityID := tokenIdentityID(g.currentIdentityToken())
+	if identityID == "" {
+		return ""
+	}
+
+	key := sha256.Sum256([]byte(config.INFISICAL_URL + "\n" + identityID))
+	return filepath.Join(g.registrationCacheDir, "relay-"+hex.EncodeToString(key[:8])+".json")
+}
+
+func (g *Gateway) currentIdentityToken() string {
+	if g.identityTokenSource != nil {
+		return g.identityTokenSource()
+	}
+	return g.httpClient.Token
+}
+
+// tokenIdentityID returns the identity a machine identity access token was issued to, which stays the
+// same when the token is renewed
+func tokenIdentityID(token string) string {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+
+	var claims struct {
+		IdentityID string `json:"identityId"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.IdentityID
+}
@@ -0,0 +1,48 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func identityToken(claims string) string {
+	return "eyJhbGciOiJIUzI1NiJ9." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".signature"
+}
+
+func TestTokenIdentityID(t *testing.T) {
+	assert.Equal(t, "7f9c5a2e", tokenIdentityID(identityToken(`{"identityId":"7f9c5a2e","exp":1700000000}`)))
+	assert.Equal(t, "", tokenIdentityID(identityToken(`{"exp":1700000000}`)))
+	assert.Equal(t, "", tokenIdentityID("st.service-token"))
+}
+
+func TestSavedRegistration(t *testing.T) {
+	cacheDir := t.TempDir()
+	relayDetails := &api.GetRelayCredentialsResponseV1{
+		TurnServerAddress:  "relay.example.com:5349",
+		TurnServerUsername: "user",
+		TurnServerPassword: "password",
+	}
+
+	g, err := New(WithIdentityToken(identityToken(`{"identityId":"identity-1"}`)), WithRegistrationCache(cacheDir))
+	assert.NoError(t, err)
+	assert.Nil(t, g.savedRegistration())
+
+	assert.NoError(t, g.saveRegistration(relayDetails))
+	assert.Equal(t, relayDetails, g.savedRegistration())
+
+	// a renewed token of the same identity finds the registration
+	renewed, err := New(WithIdentityToken(identityToken(`{"identityId":"identity-1","exp":1800000000}`)), WithRegistrationCache(cacheDir))
+	assert.NoError(t, err)
+	assert.Equal(t, relayDetails, renewed.savedRegistration())
+
+	other, err := New(WithIdentityToken(identityToken(`{"identityId":"identity-2"}`)), WithRegistrationCache(cacheDir))
+	assert.NoError(t, err)
+	assert.Nil(t, other.savedRegistration())
+
+	withoutCache, err := New(WithIdentityToken(identityToken(`{"identityId":"identity-1"}`)))
+	assert.NoError(t, err)
+	assert.Nil(t, withoutCache.savedRegistration())
+}
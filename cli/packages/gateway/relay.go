@@ -0,0 +1,100 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"gopkg.in/yaml.v2"
+)
+
+// RelayOverride replaces relay details returned by Infisical. Empty fields keep the value from the API.
+type RelayOverride struct {
+	// Address is the host:port the gateway dials, for relays behind NAT or split-brain DNS.
+	Address string
+	// ServerName is used to verify the relay certificate on port 5349. It defaults to the host
+	// returned by Infisical, so overriding only the address keeps verifying the original name.
+	ServerName string
+	Username   string
+	Password   string
+	Realm      string
+	// CredentialsFile is a YAML file with the username, password and realm keys of a self-hosted relay.
+	// It is read again every time the gateway connects to the relay, so credentials rotated on disk are
+	// picked up on the next reconnect. Username, Password and Realm take precedence over it.
+	CredentialsFile string
+}
+
+type relayCredentials struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Realm    string `yaml:"realm"`
+}
+
+func readRelayCredentialsFile(path string) (relayCredentials, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return relayCredentials{}, fmt.Errorf("unable to read relay credentials file: %w", err)
+	}
+
+	var credentials relayCredentials
+	if err := yaml.UnmarshalStrict(content, &credentials); err != nil {
+		return relayCredentials{}, fmt.Errorf("unable to parse relay credentials file %s: %w", path, err)
+	}
+	return credentials, nil
+}
+
+// WithRelayOverride directs the gateway to a relay address or credentials other than the ones
+// returned by Infisical.
+func WithRelayOverride(override RelayOverride) Option {
+	return func(g *Gateway) {
+		g.relayOverride = &override
+	}
+}
+
+// applyRelayOverride returns the relay details to use and the server name to verify the relay with
+func (g *Gateway) applyRelayOverride(relayDetails *api.GetRelayCredentialsResponseV1) (*api.GetRelayCredentialsResponseV1, string, error) {
+	serverName, _, err := net.SplitHostPort(relayDetails.TurnServerAddress)
+	if err != nil {
+		serverName = relayDetails.TurnServerAddress
+	}
+
+	if g.relayOverride == nil {
+		return relayDetails, serverName, nil
+	}
+
+	overridden := *relayDetails
+	if g.relayOverride.CredentialsFile != "" {
+		credentials, err := readRelayCredentialsFile(g.relayOverride.CredentialsFile)
+		if err != nil {
+			return nil, "", err
+		}
+		if credentials.Username != "" {
+			overridden.TurnServerUsername = credentials.Username
+		}
+		if credentials.Password != "" {
+			overridden.TurnServerPassword = credentials.Password
+		}
+		if credentials.Realm != "" {
+			overridden.TurnServerRealm = credentials.Realm
+		}
+	}
+	if g.relayOverride.Address != "" {
+		g.logger.Infof("Overriding relay address %s with %s", relayDetails.TurnServerAddress, g.relayOverride.Address)
+		overridden.TurnServerAddress = g.relayOverride.Address
+	}
+	if g.relayOverride.Username != "" {
+		overridden.TurnServerUsername = g.relayOverride.Username
+	}
+	if g.relayOverride.Password != "" {
+		overridden.TurnServerPassword = g.relayOverride.Password
+	}
+	if g.relayOverride.Realm != "" {
+		overridden.TurnServerRealm = g.relayOverride.Realm
+	}
+	if g.relayOverride.ServerName != "" {
+		serverName = g.relayOverride.ServerName
+	}
+
+	return &overridden, serverName, nil
+}
@@ -0,0 +1,241 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDnsOverTlsPort = "853"
+	targetResolverTimeout = 10 * time.Second
+	dnsMessageContentType = "application/dns-message"
+)
+
+// WithTargetResolver resolves the hostnames of targets with a DNS-over-TLS or DNS-over-HTTPS server
+// instead of the resolver of the host, for hosts whose DNS is untrusted or split between networks.
+// The resolver is tls://host[:port] for DNS-over-TLS, port 853 by default, or an https:// URL such as
+// https://cloudflare-dns.com/dns-query for DNS-over-HTTPS. The relay is still resolved by the host, and
+// so is the hostname of the resolver itself: use an IP address to avoid it.
+func WithTargetResolver(resolver string) Option {
+	return func(g *Gateway) {
+		g.targetResolverAddress = resolver
+	}
+}
+
+// newTargetResolver returns a resolver that sends every query to the DoT or DoH server. Queries are
+// written by the Go resolver in the length-prefixed framing of DNS over TCP, which is the framing of
+// DoT, so a TLS connection is all it needs. Look names up with lookupTargetHost, so the search domains of
+// the host aren't appended to them.
+func newTargetResolver(address string) (*net.Resolver, error) {
+	resolverUrl, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target resolver %q: %w", address, err)
+	}
+	if resolverUrl.Host == "" {
+		return nil, fmt.Errorf("invalid target resolver %q: expected tls://host[:port] or an https:// URL", address)
+	}
+
+	switch resolverUrl.Scheme {
+	case "tls":
+		serverAddress := resolverUrl.Host
+		if resolverUrl.Port() == "" {
+			serverAddress = net.JoinHostPort(resolverUrl.Hostname(), defaultDnsOverTlsPort)
+		}
+		tlsConfig := &tls.Config{ServerName: resolverUrl.Hostname(), MinVersion: tls.VersionTLS12}
+
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				dialer := &tls.Dialer{Config: tlsConfig, NetDialer: &net.Dialer{Timeout: targetResolverTimeout}}
+				return dialer.DialContext(ctx, "tcp", serverAddress)
+			},
+		}, nil
+	case "https":
+		return newDnsOverHttpsResolver(resolverUrl.String(), &http.Client{Timeout: targetResolverTimeout}), nil
+	default:
+		return nil, fmt.Errorf("unsupported target resolver scheme %q, expected tls or https", resolverUrl.Scheme)
+	}
+}
+
+func newDnsOverHttpsResolver(url string, httpClient *http.Client) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return &dnsOverHttpsConn{ctx: ctx, httpClient: httpClient, url: url}, nil
+		},
+	}
+}
+
+// lookupTargetHost resolves host with the target resolver. The name is made fully qualified, so the Go
+// resolver doesn't try it with the search domains and ndots of the resolv.conf of the host, which would
+// send internal names of the host's network to the resolver.
+func lookupTargetHost(ctx context.Context, resolver *net.Resolver, host string) ([]net.IPAddr, error) {
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	return resolver.LookupIPAddr(ctx, host)
+}
+
+// dialTarget connects to a forwarded target, resolving its hostname with the target resolver when
+// one is configured
+func (g *Gateway) dialTarget(ctx context.Context, address string) (net.Conn, error) {
+	if g.targetResolver == nil {
+		return g.dialer.DialContext(ctx, "tcp", address)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target address: %w", err)
+	}
+	if net.ParseIP(host) != nil {
+		return g.dialer.DialContext(ctx, "tcp", address)
+	}
+
+	ips, err := lookupTargetHost(ctx, g.targetResolver, host)
+	if err != nil {
+		return nil, fmt.Errorf("unable to resolve %s with the target resolver: %w", host, err)
+	}
+
+	var dialErr error
+	for _, ip := range ips {
+		conn, err := g.dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		dialErr = err
+	}
+	if dialErr == nil {
+		dialErr = fmt.Errorf("no addresses found for %s", host)
+	}
+	return nil, dialErr
+}
+
+// dnsOverHttpsConn carries the DNS over TCP exchanges of the Go resolver over DNS-over-HTTPS (RFC 8484):
+// each query written is posted to the server, and its answer is read back with the same framing. The
+// request is bound to the read deadline the Go resolver sets for its query timeout.
+type dnsOverHttpsConn struct {
+	ctx        context.Context
+	httpClient *http.Client
+	url        string
+
+	mutex         sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+
+	query    bytes.Buffer
+	response bytes.Buffer
+}
+
+func (c *dnsOverHttpsConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if deadlinePassed(c.writeDeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	return c.query.Write(b)
+}
+
+func (c *dnsOverHttpsConn) Read(b []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if deadlinePassed(c.readDeadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	if c.response.Len() == 0 {
+		if err := c.roundTrip(); err != nil {
+			return 0, err
+		}
+	}
+	return c.response.Read(b)
+}
+
+func deadlinePassed(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+func (c *dnsOverHttpsConn) roundTrip() error {
+	if c.query.Len() < 2 {
+		return io.EOF
+	}
+	length := int(binary.BigEndian.Uint16(c.query.Next(2)))
+	if c.query.Len() < length {
+		return errors.New("incomplete DNS query")
+	}
+	message := c.query.Next(length)
+
+	ctx := c.ctx
+	if !c.readDeadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, c.readDeadline)
+		defer cancel()
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(message))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", dnsMessageContentType)
+	request.Header.Set("Accept", dnsMessageContentType)
+
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil && c.ctx.Err() == nil {
+			return os.ErrDeadlineExceeded
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("DNS-over-HTTPS server responded with %s", response.Status)
+	}
+
+	answer, err := io.ReadAll(io.LimitReader(response.Body, 65535))
+	if err != nil {
+		return err
+	}
+
+	binary.Write(&c.response, binary.BigEndian, uint16(len(answer)))
+	c.response.Write(answer)
+	return nil
+}
+
+func (c *dnsOverHttpsConn) SetDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readDeadline = t
+	c.writeDeadline = t
+	return nil
+}
+
+func (c *dnsOverHttpsConn) SetReadDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.readDeadline = t
+	return nil
+}
+
+func (c *dnsOverHttpsConn) SetWriteDeadline(t time.Time) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.writeDeadline = t
+	return nil
+}
+
+func (c *dnsOverHttpsConn) Close() error         { return nil }
+func (c *dnsOverHttpsConn) LocalAddr() net.Addr  { return &net.TCPAddr{} }
+func (c *dnsOverHttpsConn) RemoteAddr() net.Addr { return &net.TCPAddr{} }
@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newDnsOverHttpsServer answers A queries for the names in records, and records the names it's asked
+func newDnsOverHttpsServer(t *testing.T, records map[string]net.IP) (*httptest.Server, func() []string) {
+	var mutex sync.Mutex
+	var queriedNames []string
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, dnsMessageContentType, r.Header.Get("Content-Type"))
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil || len(query.Questions) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		question := query.Questions[0]
+
+		mutex.Lock()
+		queriedNames = append(queriedNames, question.Name.String())
+		mutex.Unlock()
+
+		answer := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		ip, found := records[question.Name.String()]
+		switch {
+		case !found:
+			answer.RCode = dnsmessage.RCodeNameError
+		case question.Type == dnsmessage.TypeA:
+			var a [4]byte
+			copy(a[:], ip.To4())
+			answer.Answers = append(answer.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: question.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   &dnsmessage.AResource{A: a},
+			})
+		}
+
+		packed, err := answer.Pack()
+		assert.NoError(t, err)
+		w.Header().Set("Content-Type", dnsMessageContentType)
+		w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, queriedNames...)
+	}
+}
+
+func TestDnsOverHttpsResolver(t *testing.T) {
+	server, queriedNames := newDnsOverHttpsServer(t, map[string]net.IP{
+		"target.internal.": net.ParseIP("10.0.0.5"),
+	})
+	resolver := newDnsOverHttpsResolver(server.URL, server.Client())
+
+	tests := []struct {
+		name    string
+		host    string
+		wantIPs []string
+		wantErr bool
+	}{
+		{name: "name", host: "target.internal", wantIPs: []string{"10.0.0.5"}},
+		{name: "fully qualified name", host: "target.internal.", wantIPs: []string{"10.0.0.5"}},
+		{name: "unknown name", host: "missing.internal", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ips, err := lookupTargetHost(context.Background(), resolver, test.host)
+			if test.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			var got []string
+			for _, ip := range ips {
+				got = append(got, ip.IP.String())
+			}
+			assert.Equal(t, test.wantIPs, got)
+		})
+	}
+
+	// the search domains of the host are never appended
+	for _, name := range queriedNames() {
+		assert.Contains(t, []string{"target.internal.", "missing.internal."}, name)
+	}
+}
+
+func TestDnsOverHttpsConnDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: 1, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("target.internal."), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	assert.NoError(t, err)
+	framedQuery := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	framedQuery = append(framedQuery, query...)
+
+	t.Run("read times out", func(t *testing.T) {
+		conn := &dnsOverHttpsConn{ctx: context.Background(), httpClient: server.Client(), url: server.URL}
+		assert.NoError(t, conn.SetDeadline(time.Now().Add(50*time.Millisecond)))
+		_, err := conn.Write(framedQuery)
+		assert.NoError(t, err)
+
+		started := time.Now()
+		_, err = conn.Read(make([]byte, 512))
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		assert.Less(t, time.Since(started), 5*time.Second)
+	})
+
+	t.Run("deadline already passed", func(t *testing.T) {
+		conn := &dnsOverHttpsConn{ctx: context.Background(), httpClient: server.Client(), url: server.URL}
+		assert.NoError(t, conn.SetDeadline(time.Now().Add(-time.Second)))
+
+		_, err := conn.Write(framedQuery)
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+		_, err = conn.Read(make([]byte, 512))
+		assert.ErrorIs(t, err, os.ErrDeadlineExceeded)
+	})
+}
+
+func TestDnsOverHttpsConnServerError(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	conn := &dnsOverHttpsConn{ctx: context.Background(), httpClient: server.Client(), url: server.URL}
+	_, err := conn.Write([]byte{0, 2, 0, 1})
+	assert.NoError(t, err)
+	_, err = conn.Read(make([]byte, 512))
+	assert.ErrorContains(t, err, "503")
+}
@@ -0,0 +1,140 @@
+package gateway
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const defaultSessionReauthorizationInterval = 15 * time.Minute
+
+// WithSessionReauthorization re-checks sessions every interval once they have been open for that long,
+// instead of trusting the decision made when they connected. A session is closed when its peer
+// certificate has expired or no longer chains to the CA of the gateway. Infisical has no endpoint that
+// tells whether the actor of a certificate still has access to the gateway, so revoking that access only
+// ends sessions once their certificate expires. Zero disables it.
+func WithSessionReauthorization(interval time.Duration) Option {
+	return func(g *Gateway) {
+		g.sessionReauthorizationInterval = interval
+	}
+}
+
+type activeSession struct {
+	conn             net.Conn
+	peerCertificates []*x509.Certificate
+	remoteAddress    string
+	startedAt        time.Time
+	lastAuthorizedAt time.Time
+}
+
+type sessionRegistry struct {
+	mutex    sync.Mutex
+	sessions map[*activeSession]struct{}
+}
+
+func (r *sessionRegistry) add(conn net.Conn, peerCertificates []*x509.Certificate) *activeSession {
+	now := time.Now()
+	session := &activeSession{
+		conn:             conn,
+		peerCertificates: peerCertificates,
+		remoteAddress:    conn.RemoteAddr().String(),
+		startedAt:        now,
+		lastAuthorizedAt: now,
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.sessions == nil {
+		r.sessions = map[*activeSession]struct{}{}
+	}
+	r.sessions[session] = struct{}{}
+	return session
+}
+
+func (r *sessionRegistry) remove(session *activeSession) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.sessions, session)
+}
+
+func (r *sessionRegistry) authorized(session *activeSession, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	session.lastAuthorizedAt = now
+}
+
+// due returns the sessions last authorized at least interval ago
+func (r *sessionRegistry) due(interval time.Duration, now time.Time) []*activeSession {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var sessions []*activeSession
+	for session := range r.sessions {
+		if now.Sub(session.lastAuthorizedAt) >= interval {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions
+}
+
+func (g *Gateway) registerSessionReauthorization(caCertPool *x509.CertPool, done chan bool) {
+	if g.sessionReauthorizationInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(g.sessionReauthorizationInterval)
+
+	go func() {
+		for {
+			select {
+			case <-done:
+				ticker.Stop()
+				return
+			case <-ticker.C:
+				g.reauthorizeSessions(caCertPool)
+			}
+		}
+	}()
+}
+
+func (g *Gateway) reauthorizeSessions(caCertPool *x509.CertPool) {
+	now := time.Now()
+	for _, session := range g.sessions.due(g.sessionReauthorizationInterval, now) {
+		if err := verifySessionPeer(session, caCertPool, now); err != nil {
+			g.logger.Warnf("Closing session from %s opened at %s: %v", session.remoteAddress, session.startedAt.Format(time.RFC3339), err)
+			if sessionMetrics, ok := g.metrics.(SessionMetrics); ok {
+				sessionMetrics.SessionRevoked()
+			}
+			session.conn.Close()
+			continue
+		}
+		g.sessions.authorized(session, now)
+	}
+}
+
+// verifySessionPeer checks that the certificate the peer connected with is still valid for the CA of
+// the gateway
+func verifySessionPeer(session *activeSession, caCertPool *x509.CertPool, now time.Time) error {
+	if len(session.peerCertificates) == 0 {
+		return errors.New("the session has no peer certificate")
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, certificate := range session.peerCertificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+
+	_, err := session.peerCertificates[0].Verify(x509.VerifyOptions{
+		Roots:         caCertPool,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return fmt.Errorf("the peer certificate is no longer valid: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sessionTestTime is now, as sessions are re-authorized at the current time
+var sessionTestTime = time.Now()
+
+// newTestCertificate issues a certificate valid for a day around sessionTestTime, signed by parent or
+// self-signed when parent is nil
+func newTestCertificate(t *testing.T, serialNumber int64, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, extKeyUsage []x509.ExtKeyUsage) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "cloud", OrganizationalUnit: []string{"gateway-client"}},
+		NotBefore:    sessionTestTime.Add(-12 * time.Hour),
+		NotAfter:     sessionTestTime.Add(12 * time.Hour),
+		ExtKeyUsage:  extKeyUsage,
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	assert.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate, key
+}
+
+func TestSessionRegistry(t *testing.T) {
+	var registry sessionRegistry
+	conn, peer := net.Pipe()
+	defer peer.Close()
+
+	session := registry.add(conn, nil)
+	assert.Empty(t, registry.due(time.Minute, time.Now()), "new sessions were just authorized")
+	assert.Equal(t, []*activeSession{session}, registry.due(time.Minute, time.Now().Add(time.Minute)))
+
+	registry.authorized(session, time.Now().Add(time.Minute))
+	assert.Empty(t, registry.due(time.Minute, time.Now().Add(time.Minute)))
+	assert.Equal(t, []*activeSession{session}, registry.due(time.Minute, time.Now().Add(2*time.Minute)))
+
+	registry.remove(session)
+	assert.Empty(t, registry.due(time.Minute, time.Now().Add(time.Hour)))
+}
+
+func TestVerifySessionPeer(t *testing.T) {
+	ca, caKey := newTestCertificate(t, 1, nil, nil, nil)
+	otherCa, otherCaKey := newTestCertificate(t, 2, nil, nil, nil)
+	client, _ := newTestCertificate(t, 3, ca, caKey, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	serverOnly, _ := newTestCertificate(t, 4, ca, caKey, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth})
+	otherClient, _ := newTestCertificate(t, 5, otherCa, otherCaKey, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+
+	caCertPool := x509.NewCertPool()
+	caCertPool.AddCert(ca)
+
+	tests := []struct {
+		name             string
+		peerCertificates []*x509.Certificate
+		now              time.Time
+		wantErr          string
+	}{
+		{name: "valid", peerCertificates: []*x509.Certificate{client}, now: sessionTestTime},
+		{name: "expired", peerCertificates: []*x509.Certificate{client}, now: sessionTestTime.Add(24 * time.Hour), wantErr: "the peer certificate is no longer valid"},
+		{name: "another CA", peerCertificates: []*x509.Certificate{otherClient}, now: sessionTestTime, wantErr: "the peer certificate is no longer valid"},
+		{name: "not a client certificate", peerCertificates: []*x509.Certificate{serverOnly}, now: sessionTestTime, wantErr: "the peer certificate is no longer valid"},
+		{name: "no certificate", now: sessionTestTime, wantErr: "the session has no peer certificate"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := verifySessionPeer(&activeSession{peerCertificates: test.peerCertificates}, caCertPool, test.now)
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestReauthorizeSessions(t *testing.T) {
+	ca, caKey := newTestCertificate(t, 1, nil, nil, nil)
+	otherCa, otherCaKey := newTestCertificate(t, 2, nil, nil, nil)
+	valid, _ := newTestCertificate(t, 10, ca, caKey, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	otherClient, _ := newTestCertificate(t, 11, otherCa, otherCaKey, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth})
+	caCertPool := x509.NewCertPool()
+	caCertPool.AddCert(ca)
+
+	g, err := New(WithIdentityToken("token"), WithSessionReauthorization(time.Nanosecond))
+	assert.NoError(t, err)
+
+	validConn, validPeer := net.Pipe()
+	defer validPeer.Close()
+	otherConn, otherPeer := net.Pipe()
+	defer otherPeer.Close()
+	g.sessions.add(validConn, []*x509.Certificate{valid})
+	g.sessions.add(otherConn, []*x509.Certificate{otherClient})
+
+	g.reauthorizeSessions(caCertPool)
+
+	assert.True(t, isPipeOpen(validConn))
+	assert.False(t, isPipeOpen(otherConn), "a session whose certificate no longer chains to the CA is closed")
+}
+
+// isPipeOpen tells whether the side of a net.Pipe was closed
+func isPipeOpen(conn net.Conn) bool {
+	conn.SetReadDeadline(time.Now().Add(time.Millisecond))
+	_, err := conn.Read(make([]byte, 1))
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
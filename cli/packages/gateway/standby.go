@@ -0,0 +1,67 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// standbyRefreshInterval is how often a standby gateway registers again, so that the relay credentials
+// it holds are fresh when it is promoted
+const standbyRefreshInterval = 10 * time.Minute
+
+var errStandbyRefresh = errors.New("refreshing the registration of the standby gateway")
+
+// WithStandby starts the gateway as a warm standby: it registers with Infisical and connects to the
+// relay, but allocates a relay address, exchanges its certificate for it and serves connections only
+// once Promote is called. Failing over to it then skips registration and the relay handshake.
+func WithStandby() Option {
+	return func(g *Gateway) {
+		g.standby = true
+	}
+}
+
+// Promote makes a standby gateway allocate a relay address and serve connections. It does nothing
+// for gateways that are already active.
+func (g *Gateway) Promote() {
+	g.promoteOnce.Do(func() {
+		if g.standby {
+			g.logger.Infof("Promoting the standby gateway")
+		}
+		close(g.promoted)
+	})
+}
+
+// IsStandby reports whether the gateway waits to be promoted.
+func (g *Gateway) IsStandby() bool {
+	if !g.standby {
+		return false
+	}
+	select {
+	case <-g.promoted:
+		return false
+	default:
+		return true
+	}
+}
+
+// waitForPromotion returns once a standby gateway is promoted, or errStandbyRefresh when its
+// registration is due to be refreshed
+func (g *Gateway) waitForPromotion(ctx context.Context) error {
+	if !g.IsStandby() {
+		return nil
+	}
+
+	g.logger.Infof("Connected with relay, standing by until promoted")
+	timer := time.NewTimer(standbyRefreshInterval)
+	defer timer.Stop()
+
+	select {
+	case <-g.promoted:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return errStandbyRefresh
+	}
+}
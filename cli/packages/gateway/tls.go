@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+)
+
+type TLSMode string
+
+const (
+	// TLSModeTerminate makes the gateway open its own TLS session to the target. The client speaks plain
+	// bytes inside the relay tunnel, which is already mutually authenticated.
+	TLSModeTerminate TLSMode = "terminate"
+	// TLSModePassthrough forwards the client's TLS session untouched so the target sees the original
+	// client certificate. Required for targets that enforce mTLS themselves.
+	TLSModePassthrough TLSMode = "passthrough"
+)
+
+const (
+	tlsRecordTypeHandshake = 0x16
+	targetHandshakeTimeout = 10 * time.Second
+)
+
+// WithTargetTLSConfig sets the TLS configuration used when the gateway terminates TLS towards a target.
+// ServerName is filled from the target address when left empty.
+func WithTargetTLSConfig(tlsConfig *tls.Config) Option {
+	return func(g *Gateway) {
+		g.targetTLSConfig = tlsConfig
+	}
+}
+
+func (g *Gateway) dialTLSTarget(ctx context.Context, reader *bufio.Reader, address string, mode TLSMode) (net.Conn, error) {
+	switch mode {
+	case TLSModePassthrough:
+		if err := expectTLSClientHello(reader); err != nil {
+			return nil, err
+		}
+		return g.dialTarget(ctx, address)
+	case TLSModeTerminate:
+		rawConn, err := g.dialTarget(ctx, address)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if g.targetTLSConfig != nil {
+			tlsConfig = g.targetTLSConfig.Clone()
+		}
+
+		if tlsConfig.ServerName == "" {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				rawConn.Close()
+				return nil, fmt.Errorf("invalid target address: %w", err)
+			}
+			tlsConfig.ServerName = host
+		}
+
+		tlsConn := tls.Client(rawConn, tlsConfig)
+		handshakeCtx, cancel := context.WithTimeout(ctx, targetHandshakeTimeout)
+		defer cancel()
+
+		if err := tlsConn.HandshakeContext(handshakeCtx); err != nil {
+			rawConn.Close()
+			return nil, fmt.Errorf("TLS handshake with target failed: %w", err)
+		}
+
+		return tlsConn, nil
+	default:
+		return nil, fmt.Errorf("unsupported TLS mode %q", mode)
+	}
+}
+
+// expectTLSClientHello peeks at the first record sent by the client and makes sure it is a TLS
+// handshake, so passthrough can't be used to smuggle arbitrary protocols to a TLS-only target.
+func expectTLSClientHello(reader *bufio.Reader) error {
+	header, err := reader.Peek(3)
+	if err != nil {
+		return fmt.Errorf("unable to read TLS client hello: %w", err)
+	}
+
+	if header[0] != tlsRecordTypeHandshake || header[1] != 0x03 {
+		return fmt.Errorf("expected a TLS client hello from the client, received something else")
+	}
+
+	return nil
+}
@@ -17,6 +17,18 @@ type ConfigFile struct {
 	VaultBackendType       string         `json:"vaultBackendType,omitempty"`
 	VaultBackendPassphrase string         `json:"vaultBackendPassphrase,omitempty"`
 	Domains                []string       `json:"domains,omitempty"`
+	Profiles               []Profile      `json:"profiles,omitempty"`
+	ActiveProfile          string         `json:"activeProfile,omitempty"`
+	// VaultPassphraseProtected is set when the passphrase of the file vault isn't stored, but given with
+	// INFISICAL_VAULT_FILE_PASSPHRASE or at a prompt
+	VaultPassphraseProtected bool `json:"vaultPassphraseProtected,omitempty"`
+}
+
+// Profile points the CLI at one Infisical instance or region and remembers who is logged in there
+type Profile struct {
+	Name              string `json:"name"`
+	Domain            string `json:"domain"`
+	LoggedInUserEmail string `json:"loggedInUserEmail,omitempty"`
 }
 
 type LoggedInUser struct {
@@ -39,6 +51,8 @@ type SingleEnvironmentVariable struct {
 	} `json:"tags"`
 	Comment string `json:"comment"`
 	Etag    string `json:"Etag"`
+	// set for imported secrets, the environment they were imported from
+	Environment string `json:"-"`
 }
 
 type PlaintextSecretResult struct {
@@ -94,6 +108,19 @@ type SymmetricEncryptionResult struct {
 	AuthTag    []byte `json:"AuthTag"`
 }
 
+// PassphraseEncryptionResult is a SymmetricEncryptionResult with what it takes to derive its key again
+// from the passphrase
+type PassphraseEncryptionResult struct {
+	Kdf         string `json:"kdf"`
+	Salt        []byte `json:"salt"`
+	Time        uint32 `json:"time"`
+	Memory      uint32 `json:"memory"`
+	Parallelism uint8  `json:"parallelism"`
+	CipherText  []byte `json:"cipherText"`
+	Nonce       []byte `json:"nonce"`
+	AuthTag     []byte `json:"authTag"`
+}
+
 type GetAllSecretsParameters struct {
 	Environment              string
 	EnvironmentPassedViaFlag bool
@@ -105,12 +132,29 @@ type GetAllSecretsParameters struct {
 	IncludeImport            bool
 	Recursive                bool
 	ExpandSecretReferences   bool
+	// util.TAGS_MATCH_ANY or util.TAGS_MATCH_ALL, any when empty
+	TagsMatch string
+	// leave out personal overrides, so only the shared values of the team are used
+	ExcludePersonalSecrets bool
+	// resolve references in the CLI, for servers that don't expand them
+	ExpandSecretReferencesLocally bool
+	// how secrets of sub-folders are named when fetching recursively, see util.RenameSecretKeysByFolder
+	KeyPathSeparator string
+	KeyTemplate      string
+	// cache secrets fetched with a token and serve them when Infisical can't be reached. Logged in
+	// users always have this
+	OfflineFallback bool
+	// cached secrets older than this are not served, any age is when 0
+	MaxCacheStaleness time.Duration
 }
 
 type InjectableEnvironmentResult struct {
 	Variables    []string
 	ETag         string
 	SecretsCount int
+	SecretValues []string
+	FileSecrets  map[string]string // secrets passed to the command as files instead of variables
+	Secrets      map[string]string // secrets passed to the command as variables
 }
 
 type GetAllFoldersParameters struct {
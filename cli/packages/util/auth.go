@@ -10,6 +10,7 @@ var AuthStrategy = struct {
 	GCP_IAM_AUTH      AuthStrategyType
 	AWS_IAM_AUTH      AuthStrategyType
 	OIDC_AUTH         AuthStrategyType
+	TLS_CERT_AUTH     AuthStrategyType
 }{
 	UNIVERSAL_AUTH:    "universal-auth",
 	KUBERNETES_AUTH:   "kubernetes",
@@ -18,6 +19,7 @@ var AuthStrategy = struct {
 	GCP_IAM_AUTH:      "gcp-iam",
 	AWS_IAM_AUTH:      "aws-iam",
 	OIDC_AUTH:         "oidc-auth",
+	TLS_CERT_AUTH:     "tls-cert",
 }
 
 var AVAILABLE_AUTH_STRATEGIES = []AuthStrategyType{
@@ -28,6 +30,7 @@ var AVAILABLE_AUTH_STRATEGIES = []AuthStrategyType{
 	AuthStrategy.GCP_IAM_AUTH,
 	AuthStrategy.AWS_IAM_AUTH,
 	AuthStrategy.OIDC_AUTH,
+	AuthStrategy.TLS_CERT_AUTH,
 }
 
 func IsAuthMethodValid(authMethod string, allowUserAuth bool) (isValid bool, strategy AuthStrategyType) {
@@ -36,6 +39,14 @@ func IsAuthMethodValid(authMethod string, allowUserAuth bool) (isValid bool, str
 		return true, ""
 	}
 
+	// short names of oidc-auth and gcp-id-token
+	if authMethod == "oidc" {
+		return true, AuthStrategy.OIDC_AUTH
+	}
+	if authMethod == "gcp" {
+		return true, AuthStrategy.GCP_ID_TOKEN_AUTH
+	}
+
 	for _, strategy := range AVAILABLE_AUTH_STRATEGIES {
 		if string(strategy) == authMethod {
 			return true, strategy
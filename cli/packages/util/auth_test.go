@@ -0,0 +1,33 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsAuthMethodValid(t *testing.T) {
+	tests := []struct {
+		name          string
+		authMethod    string
+		allowUserAuth bool
+		wantValid     bool
+		wantStrategy  AuthStrategyType
+	}{
+		{name: "user", authMethod: "user", allowUserAuth: true, wantValid: true},
+		{name: "user when not allowed", authMethod: "user"},
+		{name: "full name", authMethod: "universal-auth", wantValid: true, wantStrategy: AuthStrategy.UNIVERSAL_AUTH},
+		{name: "oidc-auth", authMethod: "oidc-auth", wantValid: true, wantStrategy: AuthStrategy.OIDC_AUTH},
+		{name: "short name of oidc-auth", authMethod: "oidc", wantValid: true, wantStrategy: AuthStrategy.OIDC_AUTH},
+		{name: "short name of gcp-id-token", authMethod: "gcp", wantValid: true, wantStrategy: AuthStrategy.GCP_ID_TOKEN_AUTH},
+		{name: "unknown", authMethod: "password"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			valid, strategy := IsAuthMethodValid(test.authMethod, test.allowUserAuth)
+			assert.Equal(t, test.wantValid, valid)
+			assert.Equal(t, test.wantStrategy, strategy)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// CIEnvironment is a CI platform the CLI can run on
+type CIEnvironment struct {
+	Name   string
+	Detect func() bool
+}
+
+// CI_ENVIRONMENTS are the CI platforms recognised by name. Others that set CI=true are run on as generic CI.
+var CI_ENVIRONMENTS = []CIEnvironment{
+	{
+		Name: "GitHub Actions",
+		Detect: func() bool {
+			return os.Getenv("GITHUB_ACTIONS") == "true"
+		},
+	},
+	{
+		Name: "GitLab CI",
+		Detect: func() bool {
+			return os.Getenv("GITLAB_CI") == "true"
+		},
+	},
+	{
+		Name: "CircleCI",
+		Detect: func() bool {
+			return os.Getenv("CIRCLECI") == "true"
+		},
+	},
+	{
+		Name: "Jenkins",
+		Detect: func() bool {
+			return os.Getenv("JENKINS_URL") != "" && os.Getenv("BUILD_ID") != ""
+		},
+	},
+	{
+		Name: "Buildkite",
+		Detect: func() bool {
+			return os.Getenv("BUILDKITE") == "true"
+		},
+	},
+}
+
+// DetectCIEnvironment returns the name of the CI platform the CLI runs on. INFISICAL_CI=false turns
+// detection off, and INFISICAL_CI=true makes any other environment count as CI.
+func DetectCIEnvironment() (string, bool) {
+	override, err := strconv.ParseBool(os.Getenv(INFISICAL_CI_NAME))
+	if err == nil && !override {
+		return "", false
+	}
+
+	for _, environment := range CI_ENVIRONMENTS {
+		if environment.Detect() {
+			return environment.Name, true
+		}
+	}
+
+	if ci, _ := strconv.ParseBool(os.Getenv("CI")); ci || override {
+		return "CI", true
+	}
+	return "", false
+}
+
+// IsKeyringConfigured tells whether the CLI was set up to use the keyring, by picking a vault backend or
+// logging in a user. CI runs without the keyring otherwise.
+func IsKeyringConfigured(configFile models.ConfigFile) bool {
+	return configFile.VaultBackendType != "" || configFile.LoggedInUserEmail != ""
+}
@@ -0,0 +1,27 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsKeyringConfigured(t *testing.T) {
+	tests := []struct {
+		name       string
+		configFile models.ConfigFile
+		want       bool
+	}{
+		{name: "nothing configured", configFile: models.ConfigFile{}, want: false},
+		{name: "vault backend picked", configFile: models.ConfigFile{VaultBackendType: VAULT_BACKEND_FILE_MODE}, want: true},
+		{name: "automatic vault backend picked", configFile: models.ConfigFile{VaultBackendType: VAULT_BACKEND_AUTO_MODE}, want: true},
+		{name: "user logged in", configFile: models.ConfigFile{LoggedInUserEmail: "dev@example.com"}, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, IsKeyringConfigured(test.configFile))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are the tools the clipboard is written with, tried in order. There's no portable
+// way to reach it from Go, so the CLI relies on the one the desktop provides.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	}
+
+	commands := [][]string{}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		commands = append(commands, []string{"wl-copy"})
+	}
+	commands = append(commands,
+		[]string{"xclip", "-selection", "clipboard"},
+		[]string{"xsel", "--clipboard", "--input"},
+		// WSL
+		[]string{"clip.exe"},
+	)
+	return commands
+}
+
+// CopyToClipboard replaces the content of the clipboard with value
+func CopyToClipboard(value string) error {
+	for _, command := range clipboardCommands() {
+		if _, err := exec.LookPath(command[0]); err != nil {
+			continue
+		}
+
+		copyCommand := exec.Command(command[0], command[1:]...)
+		copyCommand.Stdin = strings.NewReader(value)
+
+		var stderr bytes.Buffer
+		copyCommand.Stderr = &stderr
+		if err := copyCommand.Run(); err != nil {
+			return fmt.Errorf("%s failed: %s [err=%w]", command[0], strings.TrimSpace(stderr.String()), err)
+		}
+		return nil
+	}
+
+	return errors.New("no clipboard tool found, install wl-clipboard, xclip or xsel")
+}
+
+// ClearClipboard empties the clipboard
+func ClearClipboard() error {
+	return CopyToClipboard("")
+}
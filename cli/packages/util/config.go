@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/models"
@@ -38,14 +39,18 @@ func WriteInitalConfig(userCredentials *models.UserCredentials) error {
 		Email:  userCredentials.Email,
 		Domain: config.INFISICAL_URL,
 	}
-	//if empty or if email not in loggedinUsers
-	if len(existingConfigFile.LoggedInUsers) == 0 || !ConfigContainsEmail(existingConfigFile.LoggedInUsers, userCredentials.Email) {
-		existingConfigFile.LoggedInUsers = append(existingConfigFile.LoggedInUsers, loggedInUser)
-	} else {
-		//if exists update domain of loggedin users
-		for idx, user := range existingConfigFile.LoggedInUsers {
-			if user.Email == userCredentials.Email {
-				existingConfigFile.LoggedInUsers[idx] = loggedInUser
+	// users logged in to a profile are remembered by the profile, so only logins outside of profiles
+	// change the users of user switch and their domains
+	if FindProfile(existingConfigFile, config.INFISICAL_PROFILE) == nil {
+		//if empty or if email not in loggedinUsers
+		if len(existingConfigFile.LoggedInUsers) == 0 || !ConfigContainsEmail(existingConfigFile.LoggedInUsers, userCredentials.Email) {
+			existingConfigFile.LoggedInUsers = append(existingConfigFile.LoggedInUsers, loggedInUser)
+		} else {
+			//if exists update domain of loggedin users
+			for idx, user := range existingConfigFile.LoggedInUsers {
+				if user.Email == userCredentials.Email {
+					existingConfigFile.LoggedInUsers[idx] = loggedInUser
+				}
 			}
 		}
 	}
@@ -56,6 +61,18 @@ func WriteInitalConfig(userCredentials *models.UserCredentials) error {
 		LoggedInUsers:          existingConfigFile.LoggedInUsers,
 		VaultBackendType:       existingConfigFile.VaultBackendType,
 		VaultBackendPassphrase: existingConfigFile.VaultBackendPassphrase,
+		Profiles:               existingConfigFile.Profiles,
+		ActiveProfile:          existingConfigFile.ActiveProfile,
+
+		VaultPassphraseProtected: existingConfigFile.VaultPassphraseProtected,
+	}
+
+	// logging in to a profile only changes who is logged in to that profile
+	if profile := FindProfile(configFile, config.INFISICAL_PROFILE); profile != nil {
+		profile.LoggedInUserEmail = userCredentials.Email
+		profile.Domain = strings.TrimSuffix(config.INFISICAL_URL, "/api")
+		configFile.LoggedInUserEmail = existingConfigFile.LoggedInUserEmail
+		configFile.LoggedInUserDomain = existingConfigFile.LoggedInUserDomain
 	}
 
 	configFileMarshalled, err := json.Marshal(configFile)
@@ -222,7 +239,7 @@ func GetConfigFile() (models.ConfigFile, error) {
 		if err != nil {
 			return models.ConfigFile{}, fmt.Errorf("GetConfigFile: Unable to decode base64 passphrase [err=%s]", err)
 		}
-		os.Setenv("INFISICAL_VAULT_FILE_PASSPHRASE", string(decodedPassphrase))
+		SetVaultFilePassphrase(string(decodedPassphrase))
 	}
 
 	return configFile, nil
@@ -8,15 +8,23 @@ const (
 	INFISICAL_WORKSPACE_CONFIG_FILE_NAME       = ".infisical.json"
 	INFISICAL_TOKEN_NAME                       = "INFISICAL_TOKEN"
 	INFISICAL_UNIVERSAL_AUTH_ACCESS_TOKEN_NAME = "INFISICAL_UNIVERSAL_AUTH_ACCESS_TOKEN"
-	INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME   = "INFISICAL_VAULT_FILE_PASSPHRASE" // This works because we've forked the keyring package and added support for this env variable. This explains why you won't find any occurrences of it in the CLI codebase.
+	INFISICAL_PROFILE_NAME                     = "INFISICAL_PROFILE"
+	INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME   = "INFISICAL_VAULT_FILE_PASSPHRASE"
 
-	VAULT_BACKEND_AUTO_MODE = "auto"
-	VAULT_BACKEND_FILE_MODE = "file"
+	VAULT_BACKEND_AUTO_MODE           = "auto"
+	VAULT_BACKEND_FILE_MODE           = "file"
+	VAULT_BACKEND_KEYCHAIN_MODE       = "keychain"
+	VAULT_BACKEND_WINCRED_MODE        = "wincred"
+	VAULT_BACKEND_SECRET_SERVICE_MODE = "secret-service"
+	VAULT_BACKEND_PASS_MODE           = "pass"
 
 	// Universal Auth
 	INFISICAL_UNIVERSAL_AUTH_CLIENT_ID_NAME     = "INFISICAL_UNIVERSAL_AUTH_CLIENT_ID"
 	INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET_NAME = "INFISICAL_UNIVERSAL_AUTH_CLIENT_SECRET"
 
+	// LDAP login
+	INFISICAL_LDAP_PASSWORD_NAME = "INFISICAL_LDAP_PASSWORD"
+
 	// Kubernetes auth
 	INFISICAL_KUBERNETES_SERVICE_ACCOUNT_TOKEN_NAME = "INFISICAL_KUBERNETES_SERVICE_ACCOUNT_TOKEN_PATH"
 
@@ -24,11 +32,62 @@ const (
 	INFISICAL_GCP_IAM_SERVICE_ACCOUNT_KEY_FILE_PATH_NAME = "INFISICAL_GCP_IAM_SERVICE_ACCOUNT_KEY_FILE_PATH"
 
 	// OIDC Auth
-	INFISICAL_OIDC_AUTH_JWT_NAME = "INFISICAL_OIDC_AUTH_JWT"
+	INFISICAL_OIDC_AUTH_JWT_NAME      = "INFISICAL_OIDC_AUTH_JWT"
+	INFISICAL_OIDC_AUTH_JWT_FILE_NAME = "INFISICAL_OIDC_AUTH_JWT_FILE"
+	INFISICAL_OIDC_AUTH_AUDIENCE_NAME = "INFISICAL_OIDC_AUTH_AUDIENCE"
+	INFISICAL_GITLAB_ID_TOKEN_NAME    = "INFISICAL_ID_TOKEN"
+
+	// HTTP client
+	INFISICAL_HTTP_MAX_RETRIES_NAME      = "INFISICAL_HTTP_MAX_RETRIES"
+	INFISICAL_HTTP_CONNECT_TIMEOUT_NAME  = "INFISICAL_HTTP_CONNECT_TIMEOUT"
+	INFISICAL_HTTP_TLS_TIMEOUT_NAME      = "INFISICAL_HTTP_TLS_TIMEOUT"
+	INFISICAL_HTTP_RESPONSE_TIMEOUT_NAME = "INFISICAL_HTTP_RESPONSE_TIMEOUT"
+	INFISICAL_HTTP_TIMEOUT_NAME          = "INFISICAL_HTTP_TIMEOUT"
+	INFISICAL_CA_CERT_PATH_NAME          = "INFISICAL_CA_CERT_PATH"
+	INFISICAL_CLIENT_CERT_PATH_NAME      = "INFISICAL_CLIENT_CERT_PATH"
+	INFISICAL_CLIENT_KEY_PATH_NAME       = "INFISICAL_CLIENT_KEY_PATH"
+	INFISICAL_HTTP_PROXY_NAME            = "INFISICAL_HTTP_PROXY"
+	INFISICAL_HTTP_PROXY_USERNAME_NAME   = "INFISICAL_HTTP_PROXY_USERNAME"
+	INFISICAL_HTTP_PROXY_PASSWORD_NAME   = "INFISICAL_HTTP_PROXY_PASSWORD"
+	INFISICAL_DEBUG_HTTP_NAME            = "INFISICAL_DEBUG_HTTP"
+
+	// Gateway
+	INFISICAL_GATEWAY_SESSION_RECORDING_KEY_NAME = "INFISICAL_GATEWAY_SESSION_RECORDING_KEY"
+	INFISICAL_GATEWAY_RELAY_PASSWORD_NAME        = "INFISICAL_GATEWAY_RELAY_PASSWORD"
 
 	// Generic env variable used for auth methods that require a machine identity ID
 	INFISICAL_MACHINE_IDENTITY_ID_NAME = "INFISICAL_MACHINE_IDENTITY_ID"
 
+	// Set by run for the command when secrets are passed as files
+	INFISICAL_SECRETS_DIR_NAME = "INFISICAL_SECRETS_DIR"
+	// Set by run for the command when secrets are passed as an env file or through a pipe
+	INFISICAL_ENV_FILE_NAME = "INFISICAL_ENV_FILE"
+	INFISICAL_ENV_FD_NAME   = "INFISICAL_ENV_FD"
+
+	// CI
+	INFISICAL_CI_NAME         = "INFISICAL_CI"
+	INFISICAL_LOG_FORMAT_NAME = "INFISICAL_LOG_FORMAT"
+
+	// Plugins
+	INFISICAL_DISABLE_PLUGINS_NAME = "INFISICAL_DISABLE_PLUGINS"
+	// Set for plugins, so they can call back into the CLI that ran them
+	INFISICAL_CLI_PATH_NAME    = "INFISICAL_CLI_PATH"
+	INFISICAL_CLI_VERSION_NAME = "INFISICAL_CLI_VERSION"
+
+	// Docker credential helper, which docker runs without flags
+	INFISICAL_DOCKER_CREDENTIAL_PROJECT_ID_NAME = "INFISICAL_DOCKER_CREDENTIAL_PROJECT_ID"
+	INFISICAL_DOCKER_CREDENTIAL_ENV_NAME        = "INFISICAL_DOCKER_CREDENTIAL_ENV"
+	INFISICAL_DOCKER_CREDENTIAL_PATH_NAME       = "INFISICAL_DOCKER_CREDENTIAL_PATH"
+
+	// How secrets that already exist with another value are handled when setting secrets
+	SECRET_CONFLICT_OVERWRITE = "overwrite"
+	SECRET_CONFLICT_SKIP      = "skip"
+	SECRET_CONFLICT_FAIL      = "fail"
+
+	// Whether secrets filtered by tags need one or all of the tags
+	TAGS_MATCH_ANY = "any"
+	TAGS_MATCH_ALL = "all"
+
 	SECRET_TYPE_PERSONAL      = "personal"
 	SECRET_TYPE_SHARED        = "shared"
 	KEYRING_SERVICE_NAME      = "infisical"
@@ -9,7 +9,6 @@ import (
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/config"
 	"github.com/Infisical/infisical-merge/packages/models"
-	"github.com/go-resty/resty/v2"
 	"github.com/zalando/go-keyring"
 )
 
@@ -25,7 +24,7 @@ func StoreUserCredsInKeyRing(userCred *models.UserCredentials) error {
 		return fmt.Errorf("StoreUserCredsInKeyRing: something went wrong when marshalling user creds [err=%s]", err)
 	}
 
-	err = SetValueInKeyring(userCred.Email, string(userCredMarshalled))
+	err = SetValueInKeyring(userCredentialsKeyringKey(userCred.Email), string(userCredMarshalled))
 	if err != nil {
 		return fmt.Errorf("StoreUserCredsInKeyRing: unable to store user credentials because [err=%s]", err)
 	}
@@ -34,7 +33,7 @@ func StoreUserCredsInKeyRing(userCred *models.UserCredentials) error {
 }
 
 func GetUserCredsFromKeyRing(userEmail string) (credentials models.UserCredentials, err error) {
-	credentialsValue, err := GetValueInKeyring(userEmail)
+	credentialsValue, err := GetValueInKeyring(userCredentialsKeyringKey(userEmail))
 	if err != nil {
 		if err == keyring.ErrUnsupportedPlatform {
 			return models.UserCredentials{}, errors.New("your OS does not support keyring. Consider using a service token https://infisical.com/docs/documentation/platform/token")
@@ -62,11 +61,12 @@ func GetCurrentLoggedInUserDetails(setConfigVariables bool) (LoggedInUserDetails
 			return LoggedInUserDetails{}, fmt.Errorf("getCurrentLoggedInUserDetails: unable to get logged in user from config file [err=%s]", err)
 		}
 
-		if configFile.LoggedInUserEmail == "" {
+		loggedInUserEmail, loggedInUserDomain := GetLoggedInUserEmailAndDomain(configFile)
+		if loggedInUserEmail == "" {
 			return LoggedInUserDetails{}, nil
 		}
 
-		userCreds, err := GetUserCredsFromKeyRing(configFile.LoggedInUserEmail)
+		userCreds, err := GetUserCredsFromKeyRing(loggedInUserEmail)
 		if err != nil {
 			if strings.Contains(err.Error(), "credentials not found in system keyring") {
 				return LoggedInUserDetails{}, errors.New("we couldn't find your logged in details, try running [infisical login] then try again")
@@ -79,13 +79,13 @@ func GetCurrentLoggedInUserDetails(setConfigVariables bool) (LoggedInUserDetails
 			config.INFISICAL_URL_MANUAL_OVERRIDE = config.INFISICAL_URL
 			//configFile.LoggedInUserDomain
 			//if not empty set as infisical url
-			if configFile.LoggedInUserDomain != "" {
-				config.INFISICAL_URL = AppendAPIEndpoint(configFile.LoggedInUserDomain)
+			if loggedInUserDomain != "" {
+				config.INFISICAL_URL = AppendAPIEndpoint(loggedInUserDomain)
 			}
 		}
 
 		// check to to see if the JWT is still valid
-		httpClient := resty.New().
+		httpClient := api.NewHTTPClient().
 			SetAuthToken(userCreds.JTWToken).
 			SetHeader("Accept", "application/json")
 
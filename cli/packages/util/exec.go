@@ -2,38 +2,55 @@ package util
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"runtime"
+	"strings"
 	"syscall"
 )
 
-func RunCommand(singleCommand string, args []string, env []string, waitForExit bool) (*exec.Cmd, error) {
+func RunCommand(singleCommand string, args []string, env []string, waitForExit bool, stdout io.Writer, stderr io.Writer, extraFiles []*os.File) (*exec.Cmd, error) {
 	var c *exec.Cmd
 	var err error
 
 	if singleCommand != "" {
-		c, err = RunCommandFromString(singleCommand, env, waitForExit)
+		c, err = RunCommandFromString(singleCommand, env, waitForExit, stdout, stderr, extraFiles)
 	} else {
-		c, err = RunCommandFromArgs(args, env, waitForExit)
+		c, err = RunCommandFromArgs(args, env, waitForExit, stdout, stderr, extraFiles)
 	}
 
 	return c, err
 }
 
+// ChildProcessEnvironment returns environ without the variables only the CLI itself reads, as the
+// environment of the commands it starts. They don't get the passphrase of the file vault.
+func ChildProcessEnvironment(environ []string) []string {
+	environment := make([]string, 0, len(environ))
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if name == INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME {
+			continue
+		}
+		environment = append(environment, variable)
+	}
+	return environment
+}
+
 func IsProcessRunning(p *os.Process) bool {
 	err := p.Signal(syscall.Signal(0))
 	return err == nil
 }
 
 // For "infisical run -- COMMAND"
-func RunCommandFromArgs(args []string, env []string, waitForExit bool) (*exec.Cmd, error) {
+func RunCommandFromArgs(args []string, env []string, waitForExit bool, stdout io.Writer, stderr io.Writer, extraFiles []*os.File) (*exec.Cmd, error) {
 	cmd := exec.Command(args[0], args[1:]...)
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
 	cmd.Env = env
+	cmd.ExtraFiles = extraFiles
 
 	err := execCommand(cmd, waitForExit)
 
@@ -47,6 +64,7 @@ func execCommand(cmd *exec.Cmd, waitForExit bool) error {
 	if err := cmd.Start(); err != nil {
 		return err
 	}
+	CloseInheritedFiles(cmd)
 
 	go func() {
 		for {
@@ -70,7 +88,7 @@ func execCommand(cmd *exec.Cmd, waitForExit bool) error {
 }
 
 // For "infisical run --command=COMMAND"
-func RunCommandFromString(command string, env []string, waitForExit bool) (*exec.Cmd, error) {
+func RunCommandFromString(command string, env []string, waitForExit bool, stdout io.Writer, stderr io.Writer, extraFiles []*os.File) (*exec.Cmd, error) {
 	shell := [2]string{"sh", "-c"}
 	if runtime.GOOS == "windows" {
 		shell = [2]string{"cmd", "/C"}
@@ -84,9 +102,18 @@ func RunCommandFromString(command string, env []string, waitForExit bool) (*exec
 	cmd := exec.Command(shell[0], shell[1], command) // #nosec G204 nosemgrep: semgrep_configs.prohibit-exec-command
 	cmd.Env = env
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.ExtraFiles = extraFiles
 
 	err := execCommand(cmd, waitForExit)
 	return cmd, err
 }
+
+// CloseInheritedFiles closes the parent's copies of files handed to a started command, the command
+// keeps its own
+func CloseInheritedFiles(cmd *exec.Cmd) {
+	for _, file := range cmd.ExtraFiles {
+		file.Close()
+	}
+}
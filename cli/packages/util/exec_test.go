@@ -0,0 +1,18 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChildProcessEnvironment(t *testing.T) {
+	environment := ChildProcessEnvironment([]string{
+		"PATH=/usr/bin",
+		INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME + "=secret",
+		"INFISICAL_TOKEN=token",
+		"EMPTY=",
+	})
+
+	assert.Equal(t, []string{"PATH=/usr/bin", "INFISICAL_TOKEN=token", "EMPTY="}, environment)
+}
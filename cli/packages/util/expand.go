@@ -0,0 +1,187 @@
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/rs/zerolog/log"
+)
+
+// references look like ${KEY} for a secret of the same folder, ${env.KEY} for one at the root of
+// another environment and ${env.folder.sub-folder.KEY} for one in a folder of another environment
+var secretReferenceRegex = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+type secretReferenceExpander struct {
+	params                models.GetAllSecretsParameters
+	projectConfigFilePath string
+	folders               map[string]map[string]string // secrets by key, by environment:path
+	expanded              map[string]string
+	// references being expanded, in order, to report cycles
+	chain []string
+}
+
+// ExpandSecretReferencesLocally resolves the references in the values of secrets in the CLI, for
+// servers that return them unexpanded. Secrets in other folders are fetched with the credentials of
+// params as needed.
+func ExpandSecretReferencesLocally(secrets []models.SingleEnvironmentVariable, params models.GetAllSecretsParameters, projectConfigFilePath string) ([]models.SingleEnvironmentVariable, error) {
+	expander := &secretReferenceExpander{
+		params:                params,
+		projectConfigFilePath: projectConfigFilePath,
+		folders:               map[string]map[string]string{},
+		expanded:              map[string]string{},
+	}
+
+	// the fetched secrets are already known, shared ones are what references point to
+	for _, secret := range secrets {
+		if secret.Type == SECRET_TYPE_PERSONAL {
+			continue
+		}
+		environment, secretPath := expander.folderOf(secret)
+		folder := expander.folders[folderId(environment, secretPath)]
+		if folder == nil {
+			folder = map[string]string{}
+			expander.folders[folderId(environment, secretPath)] = folder
+		}
+		folder[secret.Key] = secret.Value
+	}
+
+	expandedSecrets := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	for _, secret := range secrets {
+		environment, secretPath := expander.folderOf(secret)
+		reference := referenceId(environment, secretPath, secret.Key)
+
+		expander.chain = []string{reference}
+		value, err := expander.expand(environment, secretPath, secret.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		secret.Value = value
+		expandedSecrets = append(expandedSecrets, secret)
+	}
+
+	return expandedSecrets, nil
+}
+
+func (e *secretReferenceExpander) folderOf(secret models.SingleEnvironmentVariable) (string, string) {
+	environment := e.params.Environment
+	if secret.Environment != "" {
+		environment = secret.Environment
+	}
+
+	secretPath := e.params.SecretsPath
+	if secret.SecretPath != "" {
+		secretPath = secret.SecretPath
+	}
+	if secretPath == "" {
+		secretPath = "/"
+	}
+
+	return environment, secretPath
+}
+
+func (e *secretReferenceExpander) expand(environment string, secretPath string, value string) (string, error) {
+	var expandErr error
+	expanded := secretReferenceRegex.ReplaceAllStringFunc(value, func(match string) string {
+		if expandErr != nil {
+			return match
+		}
+
+		referencedEnvironment, referencedPath, referencedKey := parseSecretReference(match[2:len(match)-1], environment, secretPath)
+		resolved, err := e.resolve(referencedEnvironment, referencedPath, referencedKey)
+		if err != nil {
+			expandErr = err
+			return match
+		}
+		return resolved
+	})
+
+	return expanded, expandErr
+}
+
+func (e *secretReferenceExpander) resolve(environment string, secretPath string, key string) (string, error) {
+	reference := referenceId(environment, secretPath, key)
+	if value, ok := e.expanded[reference]; ok {
+		return value, nil
+	}
+
+	for _, expanding := range e.chain {
+		if expanding == reference {
+			return "", fmt.Errorf("circular secret reference: %s", strings.Join(append(e.chain, reference), " -> "))
+		}
+	}
+
+	folder, err := e.folder(environment, secretPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to fetch secrets of %s:%s referenced by %s: %w", environment, secretPath, e.chain[len(e.chain)-1], err)
+	}
+
+	value, ok := folder[key]
+	if !ok {
+		return "", fmt.Errorf("secret %s referenced by %s does not exist or you don't have access to it", reference, e.chain[len(e.chain)-1])
+	}
+
+	e.chain = append(e.chain, reference)
+	value, err = e.expand(environment, secretPath, value)
+	e.chain = e.chain[:len(e.chain)-1]
+	if err != nil {
+		return "", err
+	}
+
+	e.expanded[reference] = value
+	return value, nil
+}
+
+// folder returns the shared secrets of a folder, fetching them the first time
+func (e *secretReferenceExpander) folder(environment string, secretPath string) (map[string]string, error) {
+	if folder, ok := e.folders[folderId(environment, secretPath)]; ok {
+		return folder, nil
+	}
+
+	log.Debug().Msgf("fetching secrets of %s:%s to expand references", environment, secretPath)
+
+	request := e.params
+	request.Environment = environment
+	request.SecretsPath = secretPath
+	request.Recursive = false
+	request.TagSlugs = ""
+	request.IncludeImport = true
+	request.ExpandSecretReferences = false
+	request.ExpandSecretReferencesLocally = false
+
+	secrets, err := GetAllEnvironmentVariables(request, e.projectConfigFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	folder := map[string]string{}
+	for _, secret := range secrets {
+		if secret.Type != SECRET_TYPE_PERSONAL {
+			folder[secret.Key] = secret.Value
+		}
+	}
+	e.folders[folderId(environment, secretPath)] = folder
+	return folder, nil
+}
+
+// parseSecretReference splits a reference into the environment, path and key it points to, relative
+// to the folder of the secret holding it
+func parseSecretReference(reference string, environment string, secretPath string) (string, string, string) {
+	parts := strings.Split(strings.TrimSpace(reference), ".")
+	if len(parts) == 1 {
+		return environment, secretPath, parts[0]
+	}
+
+	key := parts[len(parts)-1]
+	return parts[0], "/" + strings.Join(parts[1:len(parts)-1], "/"), key
+}
+
+func folderId(environment string, secretPath string) string {
+	return environment + ":" + secretPath
+}
+
+func referenceId(environment string, secretPath string, key string) string {
+	return environment + ":" + strings.TrimSuffix(secretPath, "/") + "/" + key
+}
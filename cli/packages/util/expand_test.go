@@ -0,0 +1,173 @@
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// newExpandTestServer serves the raw secrets of folders keyed by "environment:path" and counts the
+// requests for each folder
+func newExpandTestServer(t *testing.T, folders map[string]map[string]string) map[string]int {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		folder := r.URL.Query().Get("environment") + ":" + r.URL.Query().Get("secretPath")
+		mutex.Lock()
+		requests[folder]++
+		mutex.Unlock()
+
+		secrets := []map[string]string{}
+		for key, value := range folders[folder] {
+			secrets = append(secrets, map[string]string{"secretKey": key, "secretValue": value, "type": SECRET_TYPE_SHARED})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"secrets": secrets, "imports": []interface{}{}})
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL := config.INFISICAL_URL
+	t.Cleanup(func() { config.INFISICAL_URL = previousURL })
+	config.INFISICAL_URL = server.URL
+
+	return requests
+}
+
+func TestExpandSecretReferencesLocally(t *testing.T) {
+	otherFolders := map[string]map[string]string{
+		"prod:/":         {"DB_HOST": "db.prod", "DB_URL": "postgres://${DB_HOST}/app"},
+		"prod:/apps/api": {"API_KEY": "key-${prod.DB_HOST}"},
+		"dev:/apps":      {"LOOP": "${dev.LOOP_BACK}"},
+	}
+
+	tests := []struct {
+		name    string
+		secrets []models.SingleEnvironmentVariable
+		want    map[string]string
+		wantErr string
+	}{
+		{
+			name: "no references",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "plain $HOME {A}"},
+			},
+			want: map[string]string{"A": "plain $HOME {A}"},
+		},
+		{
+			name: "same folder",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "URL", Value: "http://${HOST}:${PORT}"},
+				{Key: "HOST", Value: "${DOMAIN}"},
+				{Key: "DOMAIN", Value: "example.com"},
+				{Key: "PORT", Value: "8080"},
+			},
+			want: map[string]string{"URL": "http://example.com:8080", "HOST": "example.com", "DOMAIN": "example.com", "PORT": "8080"},
+		},
+		{
+			name: "another environment",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "DATABASE_URL", Value: "${prod.DB_URL}"},
+			},
+			want: map[string]string{"DATABASE_URL": "postgres://db.prod/app"},
+		},
+		{
+			name: "another folder",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "API_KEY", Value: "${prod.apps.api.API_KEY}"},
+			},
+			want: map[string]string{"API_KEY": "key-db.prod"},
+		},
+		{
+			name: "personal secrets aren't referenced",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "${B}"},
+				{Key: "B", Value: "personal", Type: SECRET_TYPE_PERSONAL},
+				{Key: "B", Value: "shared", Type: SECRET_TYPE_SHARED},
+			},
+			want: map[string]string{"A": "shared", "B": "shared"},
+		},
+		{
+			name: "self reference",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "${A}"},
+			},
+			wantErr: "circular secret reference: dev:/A -> dev:/A",
+		},
+		{
+			name: "cycle",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "${B}"},
+				{Key: "B", Value: "${C}"},
+				{Key: "C", Value: "${A}"},
+			},
+			wantErr: "circular secret reference: dev:/A -> dev:/B -> dev:/C -> dev:/A",
+		},
+		{
+			name: "cycle across folders",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "LOOP_BACK", Value: "${dev.apps.LOOP}"},
+			},
+			wantErr: "circular secret reference: dev:/LOOP_BACK -> dev:/apps/LOOP -> dev:/LOOP_BACK",
+		},
+		{
+			name: "missing reference",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "${MISSING}"},
+			},
+			wantErr: "secret dev:/MISSING referenced by dev:/A does not exist",
+		},
+		{
+			name: "missing in another folder",
+			secrets: []models.SingleEnvironmentVariable{
+				{Key: "A", Value: "${staging.apps.MISSING}"},
+			},
+			wantErr: "secret staging:/apps/MISSING referenced by dev:/A does not exist",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newExpandTestServer(t, otherFolders)
+			params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/", WorkspaceId: "project", UniversalAuthAccessToken: "token"}
+
+			expanded, err := ExpandSecretReferencesLocally(test.secrets, params, "")
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			got := map[string]string{}
+			for _, secret := range expanded {
+				if secret.Type != SECRET_TYPE_PERSONAL {
+					got[secret.Key] = secret.Value
+				}
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestExpandSecretReferencesLocallyFetchesFoldersOnce(t *testing.T) {
+	requests := newExpandTestServer(t, map[string]map[string]string{
+		"prod:/": {"DB_HOST": "db.prod", "DB_PORT": "5432"},
+	})
+	params := models.GetAllSecretsParameters{Environment: "dev", SecretsPath: "/", WorkspaceId: "project", UniversalAuthAccessToken: "token"}
+
+	expanded, err := ExpandSecretReferencesLocally([]models.SingleEnvironmentVariable{
+		{Key: "HOST", Value: "${prod.DB_HOST}"},
+		{Key: "ADDRESS", Value: "${prod.DB_HOST}:${prod.DB_PORT}"},
+	}, params, "")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "db.prod:5432", expanded[1].Value)
+	assert.Equal(t, map[string]int{"prod:/": 1}, requests, "the folder of the current secrets isn't fetched again")
+}
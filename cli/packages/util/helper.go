@@ -16,7 +16,6 @@ import (
 
 	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/Infisical/infisical-merge/packages/models"
-	"github.com/go-resty/resty/v2"
 	"github.com/spf13/cobra"
 )
 
@@ -120,7 +119,7 @@ func GetInfisicalToken(cmd *cobra.Command) (token *models.TokenDetails, err erro
 }
 
 func UniversalAuthLogin(clientId string, clientSecret string) (api.UniversalAuthLoginResponse, error) {
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetRetryCount(10000).
 		SetRetryMaxWaitTime(20 * time.Second).
 		SetRetryWaitTime(5 * time.Second)
@@ -135,7 +134,7 @@ func UniversalAuthLogin(clientId string, clientSecret string) (api.UniversalAuth
 
 func RenewMachineIdentityAccessToken(accessToken string) (string, error) {
 
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetRetryCount(10000).
 		SetRetryMaxWaitTime(20 * time.Second).
 		SetRetryWaitTime(5 * time.Second)
@@ -166,14 +165,15 @@ func RequireLogin() {
 	// get the config file that stores the current logged in user email
 	configFile, _ := GetConfigFile()
 
-	if configFile.LoggedInUserEmail == "" {
+	if email, _ := GetLoggedInUserEmailAndDomain(configFile); email == "" {
 		PrintErrorMessageAndExit("You must be logged in to run this command. To login, run [infisical login]")
 	}
 }
 
 func IsLoggedIn() bool {
 	configFile, _ := GetConfigFile()
-	return configFile.LoggedInUserEmail != ""
+	email, _ := GetLoggedInUserEmailAndDomain(configFile)
+	return email != ""
 }
 
 func RequireServiceToken() {
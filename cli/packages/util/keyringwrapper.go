@@ -2,6 +2,7 @@ package util
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 
 	"github.com/rs/zerolog/log"
@@ -10,6 +11,17 @@ import (
 
 const MAIN_KEYRING_SERVICE = "infisical-cli"
 
+// ErrKeyringDisabled is returned for every use of the keyring once it's disabled, as it is in CI where
+// there's no one to unlock it
+var ErrKeyringDisabled = errors.New("the keyring is disabled in CI, use a token or a machine identity instead")
+
+var keyringDisabled bool
+
+// DisableKeyring makes the CLI run without reading or storing anything in the keyring
+func DisableKeyring() {
+	keyringDisabled = true
+}
+
 type TimeoutError struct {
 	message string
 }
@@ -19,14 +31,20 @@ func (e *TimeoutError) Error() string {
 }
 
 func SetValueInKeyring(key, value string) error {
+	if keyringDisabled {
+		return ErrKeyringDisabled
+	}
+
 	currentVaultBackend, err := GetCurrentVaultBackend()
 	if err != nil {
 		PrintErrorAndExit(1, err, "Unable to get current vault. Tip: run [infisical rest] then try again")
 	}
 
-	err = keyring.Set(currentVaultBackend, MAIN_KEYRING_SERVICE, key, value)
+	err = SetValueInVaultBackend(currentVaultBackend, key, value)
 
-	if err != nil {
+	// only the automatically selected keyring falls back to the file vault, backends picked explicitly
+	// are kept to
+	if err != nil && currentVaultBackend == VAULT_BACKEND_AUTO_MODE {
 		log.Debug().Msg(fmt.Sprintf("Error while setting default keyring: %v", err))
 		configFile, _ := GetConfigFile()
 
@@ -39,11 +57,11 @@ func SetValueInKeyring(key, value string) error {
 				return err
 			}
 
-			// We call this function at last to trigger the environment variable to be set
+			// We call this function at last to set the passphrase of the file vault
 			GetConfigFile()
 		}
 
-		err = keyring.Set(VAULT_BACKEND_FILE_MODE, MAIN_KEYRING_SERVICE, key, value)
+		err = SetValueInVaultBackend(VAULT_BACKEND_FILE_MODE, key, value)
 		log.Debug().Msg(fmt.Sprintf("Error while setting file keyring: %v", err))
 	}
 
@@ -51,19 +69,94 @@ func SetValueInKeyring(key, value string) error {
 }
 
 func GetValueInKeyring(key string) (string, error) {
+	if keyringDisabled {
+		return "", ErrKeyringDisabled
+	}
+
 	currentVaultBackend, err := GetCurrentVaultBackend()
 	if err != nil {
 		PrintErrorAndExit(1, err, "Unable to get current vault. Tip: run [infisical reset] then try again")
 	}
-	return keyring.Get(currentVaultBackend, MAIN_KEYRING_SERVICE, key)
+	return GetValueInVaultBackend(currentVaultBackend, key)
 
 }
 
 func DeleteValueInKeyring(key string) error {
+	if keyringDisabled {
+		return ErrKeyringDisabled
+	}
+
 	currentVaultBackend, err := GetCurrentVaultBackend()
 	if err != nil {
 		return err
 	}
 
-	return keyring.Delete(currentVaultBackend, MAIN_KEYRING_SERVICE, key)
+	return DeleteValueInVaultBackend(currentVaultBackend, key)
+}
+
+// SetValueInVaultBackend stores a value in the given vault backend, whichever is the current one
+func SetValueInVaultBackend(vaultBackend, key, value string) error {
+	switch vaultBackend {
+	case VAULT_BACKEND_PASS_MODE:
+		return passSet(key, value)
+	case VAULT_BACKEND_FILE_MODE:
+		passphrase, err := getVaultFilePassphrase()
+		if err != nil {
+			return err
+		}
+		return fileVaultSet(key, value, passphrase)
+	}
+
+	keyringBackend, err := getKeyringBackend(vaultBackend)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringBackend, MAIN_KEYRING_SERVICE, key, value)
+}
+
+func GetValueInVaultBackend(vaultBackend, key string) (string, error) {
+	switch vaultBackend {
+	case VAULT_BACKEND_PASS_MODE:
+		return passGet(key)
+	case VAULT_BACKEND_FILE_MODE:
+		passphrase, err := getVaultFilePassphrase()
+		if err != nil {
+			return "", err
+		}
+		return fileVaultGet(key, passphrase)
+	}
+
+	keyringBackend, err := getKeyringBackend(vaultBackend)
+	if err != nil {
+		return "", err
+	}
+	return keyring.Get(keyringBackend, MAIN_KEYRING_SERVICE, key)
+}
+
+func DeleteValueInVaultBackend(vaultBackend, key string) error {
+	switch vaultBackend {
+	case VAULT_BACKEND_PASS_MODE:
+		return passDelete(key)
+	case VAULT_BACKEND_FILE_MODE:
+		return fileVaultDelete(key)
+	}
+
+	keyringBackend, err := getKeyringBackend(vaultBackend)
+	if err != nil {
+		return err
+	}
+	return keyring.Delete(keyringBackend, MAIN_KEYRING_SERVICE, key)
+}
+
+// getKeyringBackend returns the backend of the keyring package for a vault backend. The system keyrings
+// that can be picked explicitly are the ones auto selects on their OS.
+func getKeyringBackend(vaultBackend string) (string, error) {
+	if _, ok := VAULT_BACKEND_PLATFORMS[vaultBackend]; ok {
+		if err := CheckVaultBackendAvailable(vaultBackend); err != nil {
+			return "", err
+		}
+		return VAULT_BACKEND_AUTO_MODE, nil
+	}
+
+	return vaultBackend, nil
 }
@@ -0,0 +1,133 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+var keyUnsafeCharactersRegex = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// SecretKeyTemplateData is what --key-template renders a name from
+type SecretKeyTemplateData struct {
+	Key         string
+	Path        string // the folder of the secret relative to the fetched path, / for the fetched path
+	Folder      string // the last part of Path, empty for the fetched path
+	Environment string
+}
+
+// RenameSecretKeysByFolder renames the secrets of a recursive fetch so that same named secrets of
+// different folders don't replace each other. With a separator, keys of sub-folders get the folder
+// path as a prefix, /db/primary and PASSWORD becoming DB__PRIMARY__PASSWORD. A key template
+// renders each name instead. Imported secrets keep their names.
+func RenameSecretKeysByFolder(secrets []models.SingleEnvironmentVariable, params models.GetAllSecretsParameters) ([]models.SingleEnvironmentVariable, error) {
+	var keyTemplate *template.Template
+	if params.KeyTemplate != "" {
+		parsed, err := template.New("key").Funcs(template.FuncMap{
+			"upper":   strings.ToUpper,
+			"lower":   strings.ToLower,
+			"replace": func(old, new, value string) string { return strings.ReplaceAll(value, old, new) },
+			"join":    joinNonEmpty,
+		}).Parse(params.KeyTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key template: %w", err)
+		}
+		keyTemplate = parsed
+	}
+
+	renamed := make([]models.SingleEnvironmentVariable, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret.Environment != "" {
+			renamed = append(renamed, secret)
+			continue
+		}
+
+		relativePath := relativeSecretPath(params.SecretsPath, secret.SecretPath)
+
+		switch {
+		case keyTemplate != nil:
+			var name bytes.Buffer
+			err := keyTemplate.Execute(&name, SecretKeyTemplateData{
+				Key:         secret.Key,
+				Path:        relativePath,
+				Folder:      strings.TrimPrefix(path.Base(relativePath), "/"),
+				Environment: params.Environment,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("unable to render the name of %s%s: %w", secret.SecretPath, secret.Key, err)
+			}
+			if strings.TrimSpace(name.String()) == "" {
+				return nil, fmt.Errorf("the key template renders an empty name for %s in %s", secret.Key, relativePath)
+			}
+			secret.Key = strings.TrimSpace(name.String())
+
+		case params.KeyPathSeparator != "" && relativePath != "/":
+			parts := []string{}
+			for _, folder := range strings.Split(strings.Trim(relativePath, "/"), "/") {
+				parts = append(parts, strings.ToUpper(keyUnsafeCharactersRegex.ReplaceAllString(folder, "_")))
+			}
+			secret.Key = strings.Join(append(parts, secret.Key), params.KeyPathSeparator)
+		}
+
+		renamed = append(renamed, secret)
+	}
+
+	return renamed, nil
+}
+
+// FindSecretKeyCollisions returns the keys held by secrets of more than one folder, with the folders
+func FindSecretKeyCollisions(secrets []models.SingleEnvironmentVariable) map[string][]string {
+	foldersByKey := map[string]map[string]bool{}
+	for _, secret := range secrets {
+		if foldersByKey[secret.Key] == nil {
+			foldersByKey[secret.Key] = map[string]bool{}
+		}
+		foldersByKey[secret.Key][secret.Environment+":"+secret.SecretPath] = true
+	}
+
+	collisions := map[string][]string{}
+	for key, folders := range foldersByKey {
+		if len(folders) < 2 {
+			continue
+		}
+		for folder := range folders {
+			collisions[key] = append(collisions[key], strings.TrimPrefix(folder, ":"))
+		}
+		sort.Strings(collisions[key])
+	}
+	return collisions
+}
+
+// joinNonEmpty joins the parts that aren't empty, so {{ join "_" .Folder .Key }} is KEY rather than
+// _KEY for secrets of the fetched path
+func joinNonEmpty(separator string, parts ...string) string {
+	nonEmpty := []string{}
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, separator)
+}
+
+func relativeSecretPath(basePath string, secretPath string) string {
+	basePath = "/" + strings.Trim(basePath, "/")
+	secretPath = "/" + strings.Trim(secretPath, "/")
+
+	if basePath == "/" {
+		return secretPath
+	}
+	if secretPath == basePath {
+		return "/"
+	}
+	if strings.HasPrefix(secretPath, basePath+"/") {
+		return strings.TrimPrefix(secretPath, basePath)
+	}
+	return secretPath
+}
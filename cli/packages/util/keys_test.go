@@ -0,0 +1,93 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenameSecretKeysByFolder(t *testing.T) {
+	secrets := []models.SingleEnvironmentVariable{
+		{Key: "PASSWORD", SecretPath: "/apps"},
+		{Key: "PASSWORD", SecretPath: "/apps/db"},
+		{Key: "PASSWORD", SecretPath: "/apps/db/read-replica"},
+		{Key: "TOKEN", SecretPath: "/shared", Environment: "prod"},
+	}
+
+	tests := []struct {
+		name     string
+		params   models.GetAllSecretsParameters
+		wantKeys []string
+		wantErr  string
+	}{
+		{
+			name:     "nothing requested",
+			params:   models.GetAllSecretsParameters{SecretsPath: "/apps"},
+			wantKeys: []string{"PASSWORD", "PASSWORD", "PASSWORD", "TOKEN"},
+		},
+		{
+			name:     "separator",
+			params:   models.GetAllSecretsParameters{SecretsPath: "/apps", KeyPathSeparator: "__"},
+			wantKeys: []string{"PASSWORD", "DB__PASSWORD", "DB__READ_REPLICA__PASSWORD", "TOKEN"},
+		},
+		{
+			name:     "separator from the root",
+			params:   models.GetAllSecretsParameters{SecretsPath: "/", KeyPathSeparator: "_"},
+			wantKeys: []string{"APPS_PASSWORD", "APPS_DB_PASSWORD", "APPS_DB_READ_REPLICA_PASSWORD", "TOKEN"},
+		},
+		{
+			name:     "template joining the folder",
+			params:   models.GetAllSecretsParameters{SecretsPath: "/apps", KeyTemplate: `{{ join "_" (upper .Folder) .Key }}`},
+			wantKeys: []string{"PASSWORD", "DB_PASSWORD", "READ-REPLICA_PASSWORD", "TOKEN"},
+		},
+		{
+			name:     "template with the path and environment",
+			params:   models.GetAllSecretsParameters{SecretsPath: "/apps/", Environment: "dev", KeyTemplate: `{{ .Environment }}:{{ .Path }}:{{ .Key | lower }}`},
+			wantKeys: []string{"dev:/:password", "dev:/db:password", "dev:/db/read-replica:password", "TOKEN"},
+		},
+		{
+			name:    "template rendering an empty name",
+			params:  models.GetAllSecretsParameters{SecretsPath: "/apps", KeyTemplate: "{{ .Folder }}"},
+			wantErr: "the key template renders an empty name for PASSWORD in /",
+		},
+		{
+			name:    "invalid template",
+			params:  models.GetAllSecretsParameters{KeyTemplate: "{{ .Key"},
+			wantErr: "invalid key template",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			renamed, err := RenameSecretKeysByFolder(secrets, test.params)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			keys := []string{}
+			for _, secret := range renamed {
+				keys = append(keys, secret.Key)
+			}
+			assert.Equal(t, test.wantKeys, keys)
+		})
+	}
+}
+
+func TestFindSecretKeyCollisions(t *testing.T) {
+	collisions := FindSecretKeyCollisions([]models.SingleEnvironmentVariable{
+		{Key: "PASSWORD", SecretPath: "/db"},
+		{Key: "PASSWORD", SecretPath: "/"},
+		{Key: "PASSWORD", SecretPath: "/", Type: SECRET_TYPE_PERSONAL},
+		{Key: "TOKEN", SecretPath: "/"},
+		{Key: "TOKEN", SecretPath: "/", Environment: "prod"},
+		{Key: "PORT", SecretPath: "/"},
+	})
+
+	assert.Equal(t, map[string][]string{
+		"PASSWORD": {"/", "/db"},
+		"TOKEN":    {"/", "prod:/"},
+	}, collisions)
+}
@@ -1,9 +1,11 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
+	"github.com/Infisical/infisical-merge/packages/api"
 	"github.com/fatih/color"
 )
 
@@ -16,11 +18,17 @@ func PrintErrorAndExit(exitCode int, err error, messages ...string) {
 
 	if len(messages) > 0 {
 		for _, message := range messages {
-			fmt.Println(message)
+			fmt.Fprintln(os.Stderr, message)
 		}
 	}
 
 	supportMsg := fmt.Sprintf("\n\nIf this issue continues, get support at https://infisical.com/slack")
+
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) && apiErr.RequestID != "" {
+		supportMsg += fmt.Sprintf(" and include the request ID %s", apiErr.RequestID)
+	}
+
 	fmt.Fprintln(os.Stderr, supportMsg)
 
 	os.Exit(exitCode)
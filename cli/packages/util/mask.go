@@ -0,0 +1,117 @@
+package util
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SECRET_MASK = "***"
+	// shorter values like "1" or "true" would mask unrelated output
+	minMaskedSecretLength = 4
+	// how long output that might be the start of a secret is held back before it is written anyway
+	maskingWriterFlushDelay = 50 * time.Millisecond
+)
+
+// MaskingWriter replaces secret values in everything written to it with ***. A secret can be split
+// across writes, so output ending with the start of a secret is held back until the next write or
+// a short delay.
+type MaskingWriter struct {
+	mutex      sync.Mutex
+	out        io.Writer
+	secrets    [][]byte
+	pending    []byte
+	flushTimer *time.Timer
+}
+
+func NewMaskingWriter(out io.Writer, secretValues []string) *MaskingWriter {
+	unique := map[string]bool{}
+	for _, value := range secretValues {
+		// multi line secrets are usually printed line by line
+		for _, candidate := range append([]string{value}, strings.Split(value, "\n")...) {
+			candidate = strings.TrimSpace(candidate)
+			if len(candidate) >= minMaskedSecretLength {
+				unique[candidate] = true
+			}
+		}
+	}
+
+	secrets := make([][]byte, 0, len(unique))
+	for value := range unique {
+		secrets = append(secrets, []byte(value))
+	}
+	// longer secrets first, so a secret containing another is masked as a whole
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+
+	return &MaskingWriter{out: out, secrets: secrets}
+}
+
+func (w *MaskingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.flushTimer != nil {
+		w.flushTimer.Stop()
+	}
+
+	content := w.mask(append(w.pending, p...))
+
+	held := w.partialSecretSuffix(content)
+	w.pending = append([]byte{}, content[len(content)-held:]...)
+
+	if _, err := w.out.Write(content[:len(content)-held]); err != nil {
+		return 0, err
+	}
+
+	if held > 0 {
+		w.flushTimer = time.AfterFunc(maskingWriterFlushDelay, func() { w.Flush() })
+	}
+
+	return len(p), nil
+}
+
+// Flush writes output held back because it might have been the start of a secret
+func (w *MaskingWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	_, err := w.out.Write(w.pending)
+	w.pending = nil
+	return err
+}
+
+func (w *MaskingWriter) mask(content []byte) []byte {
+	for _, secret := range w.secrets {
+		if bytes.Contains(content, secret) {
+			content = bytes.ReplaceAll(content, secret, []byte(SECRET_MASK))
+		}
+	}
+	return content
+}
+
+// partialSecretSuffix returns the length of the longest end of content that is the start of a secret
+func (w *MaskingWriter) partialSecretSuffix(content []byte) int {
+	longest := 0
+	for _, secret := range w.secrets {
+		maxLength := len(secret) - 1
+		if maxLength > len(content) {
+			maxLength = len(content)
+		}
+
+		for length := maxLength; length > longest; length-- {
+			if bytes.HasSuffix(content, secret[:length]) {
+				longest = length
+				break
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,105 @@
+package util
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskingWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		secrets []string
+		writes  []string
+		want    string
+	}{
+		{
+			name:    "secret in a write",
+			secrets: []string{"hunter2"},
+			writes:  []string{"password is hunter2\n"},
+			want:    "password is ***\n",
+		},
+		{
+			name:    "secret split across writes",
+			secrets: []string{"hunter2"},
+			writes:  []string{"password is hun", "ter2\n"},
+			want:    "password is ***\n",
+		},
+		{
+			name:    "start of a secret that isn't one",
+			secrets: []string{"hunter2"},
+			writes:  []string{"hunting", " season"},
+			want:    "hunting season",
+		},
+		{
+			name:    "values shorter than four bytes are left alone",
+			secrets: []string{"1", "true"},
+			writes:  []string{"replicas: 1, enabled: true\n"},
+			want:    "replicas: 1, enabled: ***\n",
+		},
+		{
+			name:    "longer secret containing another",
+			secrets: []string{"token", "token-with-suffix"},
+			writes:  []string{"token-with-suffix and token\n"},
+			want:    "*** and ***\n",
+		},
+		{
+			name:    "multi line secret printed line by line",
+			secrets: []string{"-----BEGIN KEY-----\nMIIEabcd\n-----END KEY-----"},
+			writes:  []string{"line: MIIEabcd\n"},
+			want:    "line: ***\n",
+		},
+		{
+			name:    "secret at the end of the output",
+			secrets: []string{"hunter2"},
+			writes:  []string{"hunter"},
+			want:    "hunter",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writer := NewMaskingWriter(&out, test.secrets)
+			for _, write := range test.writes {
+				written, err := writer.Write([]byte(write))
+				assert.NoError(t, err)
+				assert.Equal(t, len(write), written)
+			}
+			assert.NoError(t, writer.Flush())
+			assert.Equal(t, test.want, out.String())
+		})
+	}
+}
+
+func TestMaskingWriterFlushesHeldOutput(t *testing.T) {
+	var out syncBuffer
+	writer := NewMaskingWriter(&out, []string{"hunter2"})
+
+	_, err := writer.Write([]byte("prompt> hun"))
+	assert.NoError(t, err)
+	assert.Equal(t, "prompt> ", out.String())
+
+	// output that might be the start of a secret is written anyway after a short delay
+	assert.Eventually(t, func() bool { return out.String() == "prompt> hun" }, time.Second, 10*time.Millisecond)
+}
+
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.String()
+}
@@ -0,0 +1,218 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// OidcTokenSource is a place an OIDC ID token can be found in the environment the CLI runs in, so
+// machine identities can log in with OIDC auth without a stored credential
+type OidcTokenSource struct {
+	Name string
+	// Detect reports whether the CLI runs where this source has a token
+	Detect func() bool
+	// Fetch returns the token. Sources that mint tokens on request use audience, the others ignore it.
+	Fetch func(audience string) (string, error)
+	// CI marks sources of CI platforms, where login picks OIDC auth by itself when a machine identity is set
+	CI bool
+}
+
+// OIDC_TOKEN_SOURCES are tried in order by GetAmbientOidcToken
+var OIDC_TOKEN_SOURCES = []OidcTokenSource{
+	oidcTokenFileSource("token file", INFISICAL_OIDC_AUTH_JWT_FILE_NAME),
+	{
+		Name: "GitHub Actions",
+		Detect: func() bool {
+			return os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL") != "" && os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN") != ""
+		},
+		Fetch: fetchGitHubActionsOidcToken,
+		CI:    true,
+	},
+	{
+		Name: "GitLab CI",
+		Detect: func() bool {
+			return os.Getenv("GITLAB_CI") == "true" && gitLabOidcToken() != ""
+		},
+		Fetch: func(audience string) (string, error) {
+			return gitLabOidcToken(), nil
+		},
+		CI: true,
+	},
+	{
+		Name: "CircleCI",
+		Detect: func() bool {
+			return os.Getenv("CIRCLECI") == "true" && circleCiOidcToken() != ""
+		},
+		Fetch: func(audience string) (string, error) {
+			return circleCiOidcToken(), nil
+		},
+		CI: true,
+	},
+	{
+		Name: "Buildkite",
+		Detect: func() bool {
+			_, err := exec.LookPath("buildkite-agent")
+			return os.Getenv("BUILDKITE") == "true" && err == nil
+		},
+		Fetch: fetchBuildkiteOidcToken,
+		CI:    true,
+	},
+	oidcTokenFileSource("AWS web identity token", "AWS_WEB_IDENTITY_TOKEN_FILE"),
+	oidcTokenFileSource("Azure federated token", "AZURE_FEDERATED_TOKEN_FILE"),
+}
+
+// oidcTokenFileSource reads the token from the file the environment variable points to
+func oidcTokenFileSource(name string, envName string) OidcTokenSource {
+	return OidcTokenSource{
+		Name: name,
+		Detect: func() bool {
+			return os.Getenv(envName) != ""
+		},
+		Fetch: func(audience string) (string, error) {
+			return ReadOidcTokenFile(os.Getenv(envName))
+		},
+	}
+}
+
+// fetchGitHubActionsOidcToken requests the ID token of the running job. The workflow needs the
+// id-token: write permission for GitHub to set the request URL.
+func fetchGitHubActionsOidcToken(audience string) (string, error) {
+	requestUrl, err := url.Parse(os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL"))
+	if err != nil {
+		return "", fmt.Errorf("invalid ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+
+	if audience != "" {
+		query := requestUrl.Query()
+		query.Set("audience", audience)
+		requestUrl.RawQuery = query.Encode()
+	}
+
+	request, err := http.NewRequest(http.MethodGet, requestUrl.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", "bearer "+os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN"))
+	request.Header.Set("Accept", "application/json")
+
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub responded with status %d: %s. Make sure the workflow has the id-token: write permission", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokenResponse struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(body, &tokenResponse); err != nil || tokenResponse.Value == "" {
+		return "", fmt.Errorf("GitHub responded without an ID token")
+	}
+
+	return tokenResponse.Value, nil
+}
+
+// fetchBuildkiteOidcToken requests the ID token of the running job from the Buildkite agent
+func fetchBuildkiteOidcToken(audience string) (string, error) {
+	args := []string{"oidc", "request-token"}
+	if audience != "" {
+		args = append(args, "--audience", audience)
+	}
+
+	output, err := exec.Command("buildkite-agent", args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("buildkite-agent failed: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		return "", fmt.Errorf("buildkite-agent returned no ID token")
+	}
+	return token, nil
+}
+
+// gitLabOidcToken returns the ID token GitLab sets for the job. Jobs declare it with the id_tokens keyword,
+// which also sets its audience, under the name INFISICAL_ID_TOKEN. CI_JOB_JWT_V2 is the token of older
+// GitLab versions.
+func gitLabOidcToken() string {
+	for _, envName := range []string{INFISICAL_GITLAB_ID_TOKEN_NAME, "CI_JOB_JWT_V2"} {
+		if token := strings.TrimSpace(os.Getenv(envName)); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// circleCiOidcToken returns the ID token CircleCI sets for jobs that use a context. The V2 token carries
+// more claims, such as the branch, to bind the identity to.
+func circleCiOidcToken() string {
+	for _, envName := range []string{"CIRCLE_OIDC_TOKEN_V2", "CIRCLE_OIDC_TOKEN"} {
+		if token := strings.TrimSpace(os.Getenv(envName)); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// ReadOidcTokenFile reads an ID token from a file, such as a projected service account token
+func ReadOidcTokenFile(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read OIDC token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf("the OIDC token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// DetectCIOidcTokenSource returns the name of the CI platform the CLI runs on, if it provides ID tokens
+func DetectCIOidcTokenSource() (string, bool) {
+	for _, source := range OIDC_TOKEN_SOURCES {
+		if source.CI && source.Detect() {
+			return source.Name, true
+		}
+	}
+	return "", false
+}
+
+// GetAmbientOidcToken returns the ID token of the first source detected in the environment, with the
+// name of that source
+func GetAmbientOidcToken(audience string) (string, string, error) {
+	for _, source := range OIDC_TOKEN_SOURCES {
+		if !source.Detect() {
+			continue
+		}
+
+		token, err := source.Fetch(audience)
+		if err != nil {
+			return "", source.Name, fmt.Errorf("unable to get the OIDC token from the %s: %w", source.Name, err)
+		}
+		return token, source.Name, nil
+	}
+
+	return "", "", fmt.Errorf("no OIDC token found. Pass one with --oidc-jwt or --oidc-jwt-file, or run where an ID token is available")
+}
@@ -0,0 +1,292 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// clearOidcEnvironment unsets the variables of every OIDC token source, so tests don't pick up the
+// tokens of the CI they run on
+func clearOidcEnvironment(t *testing.T) {
+	for _, envName := range []string{
+		INFISICAL_OIDC_AUTH_JWT_FILE_NAME, "AWS_WEB_IDENTITY_TOKEN_FILE", "AZURE_FEDERATED_TOKEN_FILE",
+		"ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN",
+		"GITLAB_CI", INFISICAL_GITLAB_ID_TOKEN_NAME, "CI_JOB_JWT_V2",
+		"CIRCLECI", "CIRCLE_OIDC_TOKEN_V2", "CIRCLE_OIDC_TOKEN",
+		"BUILDKITE",
+	} {
+		t.Setenv(envName, "")
+	}
+}
+
+func writeTokenFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func TestReadOidcTokenFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		missing bool
+		want    string
+		wantErr string
+	}{
+		{name: "token", content: "header.payload.signature", want: "header.payload.signature"},
+		{name: "trailing newline", content: "header.payload.signature\n", want: "header.payload.signature"},
+		{name: "empty", content: " \n", wantErr: "is empty"},
+		{name: "missing", missing: true, wantErr: "unable to read OIDC token file"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "token")
+			if !test.missing {
+				path = writeTokenFile(t, "token", test.content)
+			}
+
+			token, err := ReadOidcTokenFile(path)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, token)
+		})
+	}
+}
+
+func TestGetAmbientOidcToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]string
+		wantToken  string
+		wantSource string
+		wantErr    string
+	}{
+		{
+			name:    "nothing in the environment",
+			wantErr: "no OIDC token found",
+		},
+		{
+			name:       "AWS web identity token",
+			files:      map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": "aws-token"},
+			wantToken:  "aws-token",
+			wantSource: "AWS web identity token",
+		},
+		{
+			name:       "Azure federated token",
+			files:      map[string]string{"AZURE_FEDERATED_TOKEN_FILE": "azure-token"},
+			wantToken:  "azure-token",
+			wantSource: "Azure federated token",
+		},
+		{
+			name:       "the token file of the CLI comes first",
+			files:      map[string]string{INFISICAL_OIDC_AUTH_JWT_FILE_NAME: "infisical-token", "AWS_WEB_IDENTITY_TOKEN_FILE": "aws-token"},
+			wantToken:  "infisical-token",
+			wantSource: "token file",
+		},
+		{
+			name:       "empty token file",
+			files:      map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": ""},
+			wantSource: "AWS web identity token",
+			wantErr:    "unable to get the OIDC token from the AWS web identity token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearOidcEnvironment(t)
+			for envName, content := range test.files {
+				t.Setenv(envName, writeTokenFile(t, envName, content))
+			}
+
+			token, source, err := GetAmbientOidcToken("")
+			assert.Equal(t, test.wantSource, source)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantToken, token)
+		})
+	}
+}
+
+func TestFetchGitHubActionsOidcToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		audience     string
+		status       int
+		body         string
+		want         string
+		wantAudience string
+		wantErr      string
+	}{
+		{
+			name:   "token",
+			status: http.StatusOK,
+			body:   `{"value":"github-jwt"}`,
+			want:   "github-jwt",
+		},
+		{
+			name:         "audience",
+			audience:     "https://app.infisical.com",
+			status:       http.StatusOK,
+			body:         `{"value":"github-jwt"}`,
+			want:         "github-jwt",
+			wantAudience: "https://app.infisical.com",
+		},
+		{
+			name:    "missing id-token permission",
+			status:  http.StatusForbidden,
+			body:    "forbidden",
+			wantErr: "id-token: write permission",
+		},
+		{
+			name:    "no token in the response",
+			status:  http.StatusOK,
+			body:    `{}`,
+			wantErr: "without an ID token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "bearer request-token", r.Header.Get("Authorization"))
+				assert.Equal(t, "value", r.URL.Query().Get("api-version"), "the query of the request URL is kept")
+				assert.Equal(t, test.wantAudience, r.URL.Query().Get("audience"))
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			clearOidcEnvironment(t)
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"/token?api-version=value")
+			t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+
+			token, err := fetchGitHubActionsOidcToken(test.audience)
+			if test.wantErr != "" {
+				assert.ErrorContains(t, err, test.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, token)
+		})
+	}
+}
+
+func TestDetectCIOidcTokenSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantName  string
+		wantFound bool
+	}{
+		{
+			name: "not on CI",
+		},
+		{
+			name:      "GitHub Actions",
+			env:       map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": "https://token.actions.githubusercontent.com", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token"},
+			wantName:  "GitHub Actions",
+			wantFound: true,
+		},
+		{
+			name: "GitHub Actions without the id-token permission",
+			env:  map[string]string{"ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token"},
+		},
+		{
+			name:      "GitLab CI",
+			env:       map[string]string{"GITLAB_CI": "true", INFISICAL_GITLAB_ID_TOKEN_NAME: "gitlab-jwt"},
+			wantName:  "GitLab CI",
+			wantFound: true,
+		},
+		{
+			name: "GitLab CI job without an ID token",
+			env:  map[string]string{"GITLAB_CI": "true"},
+		},
+		{
+			name:      "CircleCI",
+			env:       map[string]string{"CIRCLECI": "true", "CIRCLE_OIDC_TOKEN": "circleci-jwt"},
+			wantName:  "CircleCI",
+			wantFound: true,
+		},
+		{
+			name: "CircleCI job without a context",
+			env:  map[string]string{"CIRCLECI": "true"},
+		},
+		{
+			name: "token files aren't CI platforms",
+			env:  map[string]string{"AWS_WEB_IDENTITY_TOKEN_FILE": "/var/run/secrets/token"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearOidcEnvironment(t)
+			for envName, value := range test.env {
+				t.Setenv(envName, value)
+			}
+
+			name, found := DetectCIOidcTokenSource()
+			assert.Equal(t, test.wantFound, found)
+			assert.Equal(t, test.wantName, name)
+		})
+	}
+}
+
+func TestGetAmbientOidcTokenOfCIPlatforms(t *testing.T) {
+	tests := []struct {
+		name       string
+		env        map[string]string
+		wantToken  string
+		wantSource string
+	}{
+		{
+			name:       "GitLab CI ID token",
+			env:        map[string]string{"GITLAB_CI": "true", INFISICAL_GITLAB_ID_TOKEN_NAME: " gitlab-jwt\n", "CI_JOB_JWT_V2": "legacy-jwt"},
+			wantToken:  "gitlab-jwt",
+			wantSource: "GitLab CI",
+		},
+		{
+			name:       "GitLab CI token of older versions",
+			env:        map[string]string{"GITLAB_CI": "true", "CI_JOB_JWT_V2": "legacy-jwt"},
+			wantToken:  "legacy-jwt",
+			wantSource: "GitLab CI",
+		},
+		{
+			name:       "CircleCI V2 token",
+			env:        map[string]string{"CIRCLECI": "true", "CIRCLE_OIDC_TOKEN_V2": "circleci-v2-jwt", "CIRCLE_OIDC_TOKEN": "circleci-jwt"},
+			wantToken:  "circleci-v2-jwt",
+			wantSource: "CircleCI",
+		},
+		{
+			name:       "CircleCI token",
+			env:        map[string]string{"CIRCLECI": "true", "CIRCLE_OIDC_TOKEN": "circleci-jwt"},
+			wantToken:  "circleci-jwt",
+			wantSource: "CircleCI",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clearOidcEnvironment(t)
+			for envName, value := range test.env {
+				t.Setenv(envName, value)
+			}
+
+			token, source, err := GetAmbientOidcToken("ignored-audience")
+			assert.NoError(t, err)
+			assert.Equal(t, test.wantToken, token)
+			assert.Equal(t, test.wantSource, source)
+		})
+	}
+}
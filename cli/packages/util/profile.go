@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/Infisical/infisical-merge/packages/models"
+)
+
+// Regions that can be used in place of a domain when creating a profile
+var ProfileRegionDomains = map[string]string{
+	"us": INFISICAL_DEFAULT_US_URL,
+	"eu": INFISICAL_DEFAULT_EU_URL,
+}
+
+// ResolveProfile selects the profile named by --profile or INFISICAL_PROFILE, falling back to the
+// active profile in the config file, and points the CLI at its domain unless the domain was
+// overridden explicitly with --domain or INFISICAL_API_URL.
+func ResolveProfile(profileName string, domainOverridden bool) error {
+	configFile, err := GetConfigFile()
+	if err != nil {
+		return fmt.Errorf("unable to read config file [err=%s]", err)
+	}
+
+	explicit := profileName != ""
+	if !explicit {
+		profileName = configFile.ActiveProfile
+	}
+
+	if profileName == "" {
+		return nil
+	}
+
+	profile := FindProfile(configFile, profileName)
+	if profile == nil {
+		if explicit {
+			return fmt.Errorf("profile '%s' does not exist. Create it with [infisical profile add %s --domain <url>]", profileName, profileName)
+		}
+		// the active profile was removed by hand, behave as if none is set
+		return nil
+	}
+
+	config.INFISICAL_PROFILE = profile.Name
+
+	if !domainOverridden {
+		config.INFISICAL_URL = AppendAPIEndpoint(profile.Domain)
+		config.INFISICAL_LOGIN_URL = fmt.Sprintf("%s/login", strings.TrimSuffix(config.INFISICAL_URL, "/api"))
+	}
+
+	return nil
+}
+
+func FindProfile(configFile models.ConfigFile, name string) *models.Profile {
+	for i := range configFile.Profiles {
+		if configFile.Profiles[i].Name == name {
+			return &configFile.Profiles[i]
+		}
+	}
+	return nil
+}
+
+// GetLoggedInUserEmailAndDomain returns the user logged in to the selected profile, or the globally
+// logged in user when no profile is selected
+func GetLoggedInUserEmailAndDomain(configFile models.ConfigFile) (email string, domain string) {
+	if config.INFISICAL_PROFILE != "" {
+		if profile := FindProfile(configFile, config.INFISICAL_PROFILE); profile != nil {
+			return profile.LoggedInUserEmail, profile.Domain
+		}
+	}
+	return configFile.LoggedInUserEmail, configFile.LoggedInUserDomain
+}
+
+// Credentials of the same user in different profiles belong to different instances, so they are
+// stored under their own keyring entries
+func userCredentialsKeyringKey(email string) string {
+	if config.INFISICAL_PROFILE == "" {
+		return email
+	}
+	return ProfileKeyringKey(config.INFISICAL_PROFILE, email)
+}
+
+func ProfileKeyringKey(profileName, email string) string {
+	return fmt.Sprintf("profile:%s:%s", profileName, email)
+}
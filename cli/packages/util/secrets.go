@@ -8,7 +8,9 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/Infisical/infisical-merge/packages/api"
@@ -27,7 +29,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string, environment str
 
 	serviceToken := fmt.Sprintf("%v.%v.%v", serviceTokenParts[0], serviceTokenParts[1], serviceTokenParts[2])
 
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 
 	httpClient.SetAuthToken(serviceToken).
 		SetHeader("Accept", "application/json")
@@ -63,7 +65,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string, environment str
 	plainTextSecrets := []models.SingleEnvironmentVariable{}
 
 	for _, secret := range rawSecrets.Secrets {
-		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace})
+		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace, SecretPath: secret.SecretPath, Tags: secret.Tags})
 	}
 
 	if includeImports {
@@ -78,7 +80,7 @@ func GetPlainTextSecretsViaServiceToken(fullServiceToken string, environment str
 }
 
 func GetPlainTextSecretsV3(accessToken string, workspaceId string, environmentName string, secretsPath string, includeImports bool, recursive bool, tagSlugs string, expandSecretReferences bool) (models.PlaintextSecretResult, error) {
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetAuthToken(accessToken).
 		SetHeader("Accept", "application/json")
 
@@ -104,7 +106,7 @@ func GetPlainTextSecretsV3(accessToken string, workspaceId string, environmentNa
 	plainTextSecrets := []models.SingleEnvironmentVariable{}
 
 	for _, secret := range rawSecrets.Secrets {
-		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace, SecretPath: secret.SecretPath})
+		plainTextSecrets = append(plainTextSecrets, models.SingleEnvironmentVariable{Key: secret.SecretKey, Value: secret.SecretValue, Type: secret.Type, WorkspaceId: secret.Workspace, SecretPath: secret.SecretPath, Tags: secret.Tags})
 	}
 
 	if includeImports {
@@ -121,7 +123,7 @@ func GetPlainTextSecretsV3(accessToken string, workspaceId string, environmentNa
 }
 
 func GetSinglePlainTextSecretByNameV3(accessToken string, workspaceId string, environmentName string, secretsPath string, secretName string) (models.SingleEnvironmentVariable, string, error) {
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetAuthToken(accessToken).
 		SetHeader("Accept", "application/json")
 
@@ -152,7 +154,7 @@ func GetSinglePlainTextSecretByNameV3(accessToken string, workspaceId string, en
 }
 
 func CreateDynamicSecretLease(accessToken string, projectSlug string, environmentName string, secretsPath string, slug string, ttl string) (models.DynamicSecretLease, error) {
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetAuthToken(accessToken).
 		SetHeader("Accept", "application/json")
 
@@ -198,6 +200,9 @@ func InjectRawImportedSecret(secrets []models.SingleEnvironmentVariable, importe
 					Value:       sec.SecretValue,
 					Type:        sec.Type,
 					ID:          sec.ID,
+					SecretPath:  importSec.SecretPath,
+					Environment: importSec.Environment,
+					Tags:        sec.Tags,
 				})
 				hasOverriden[sec.SecretKey] = true
 			}
@@ -207,24 +212,35 @@ func InjectRawImportedSecret(secrets []models.SingleEnvironmentVariable, importe
 }
 
 func FilterSecretsByTag(plainTextSecrets []models.SingleEnvironmentVariable, tagSlugs string) []models.SingleEnvironmentVariable {
-	if tagSlugs == "" {
-		return plainTextSecrets
-	}
+	return FilterSecretsByTags(plainTextSecrets, tagSlugs, TAGS_MATCH_ANY)
+}
 
+// FilterSecretsByTags keeps the secrets with any of the comma separated tag slugs, or with all of
+// them when tagsMatch is TAGS_MATCH_ALL
+func FilterSecretsByTags(plainTextSecrets []models.SingleEnvironmentVariable, tagSlugs string, tagsMatch string) []models.SingleEnvironmentVariable {
 	tagSlugsMap := make(map[string]bool)
-	tagSlugsList := strings.Split(tagSlugs, ",")
-	for _, slug := range tagSlugsList {
-		tagSlugsMap[slug] = true
+	for _, slug := range strings.Split(tagSlugs, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			tagSlugsMap[slug] = true
+		}
+	}
+
+	if len(tagSlugsMap) == 0 {
+		return plainTextSecrets
 	}
 
 	filteredSecrets := []models.SingleEnvironmentVariable{}
 	for _, secret := range plainTextSecrets {
+		matchedSlugs := make(map[string]bool)
 		for _, tag := range secret.Tags {
 			if tagSlugsMap[tag.Slug] {
-				filteredSecrets = append(filteredSecrets, secret)
-				break
+				matchedSlugs[tag.Slug] = true
 			}
 		}
+
+		if (tagsMatch == TAGS_MATCH_ALL && len(matchedSlugs) == len(tagSlugsMap)) || (tagsMatch != TAGS_MATCH_ALL && len(matchedSlugs) > 0) {
+			filteredSecrets = append(filteredSecrets, secret)
+		}
 	}
 
 	return filteredSecrets
@@ -235,6 +251,10 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters, projectCo
 	// var serviceTokenDetails api.GetServiceTokenDetailsResponse
 	var errorToReturn error
 
+	if params.TagsMatch != "" && params.TagsMatch != TAGS_MATCH_ANY && params.TagsMatch != TAGS_MATCH_ALL {
+		return nil, fmt.Errorf("invalid tag match %q, it must be %s or %s", params.TagsMatch, TAGS_MATCH_ANY, TAGS_MATCH_ALL)
+	}
+
 	if params.InfisicalToken == "" && params.UniversalAuthAccessToken == "" {
 		if projectConfigFilePath == "" {
 			RequireLocalWorkspaceFile()
@@ -284,7 +304,7 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters, projectCo
 		}
 
 		res, err := GetPlainTextSecretsV3(loggedInUserDetails.UserCredentials.JTWToken, infisicalDotJson.WorkspaceId,
-			params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, true)
+			params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, !params.ExpandSecretReferencesLocally)
 		log.Debug().Msgf("GetAllEnvironmentVariables: Trying to fetch secrets JTW token [err=%s]", err)
 
 		if err == nil {
@@ -292,28 +312,23 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters, projectCo
 			if err != nil {
 				return nil, err
 			}
-			WriteBackupSecrets(infisicalDotJson.WorkspaceId, params.Environment, params.SecretsPath, backupEncryptionKey, res.Secrets)
+			WriteBackupSecrets(secretsBackupId(infisicalDotJson.WorkspaceId, params), params.Environment, params.SecretsPath, backupEncryptionKey, res.Secrets)
 		}
 
 		secretsToReturn = res.Secrets
 		errorToReturn = err
 		// only attempt to serve cached secrets if no internet connection and if at least one secret cached
 		if !isConnected {
-			backupEncryptionKey, _ := GetBackupEncryptionKey()
-			if backupEncryptionKey != nil {
-				backedUpSecrets, err := ReadBackupSecrets(infisicalDotJson.WorkspaceId, params.Environment, params.SecretsPath, backupEncryptionKey)
-				if len(backedUpSecrets) > 0 {
-					PrintWarning("Unable to fetch the latest secret(s) due to connection error, serving secrets from last successful fetch. For more info, run with --debug")
-					secretsToReturn = backedUpSecrets
-					errorToReturn = err
-				}
+			if backedUpSecrets, ok := readBackupSecretsForFallback(secretsBackupId(infisicalDotJson.WorkspaceId, params), params); ok {
+				secretsToReturn = backedUpSecrets
+				errorToReturn = nil
 			}
 		}
 
 	} else {
 		if params.InfisicalToken != "" {
 			log.Debug().Msg("Trying to fetch secrets using service token")
-			secretsToReturn, errorToReturn = GetPlainTextSecretsViaServiceToken(params.InfisicalToken, params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, params.ExpandSecretReferences)
+			secretsToReturn, errorToReturn = GetPlainTextSecretsViaServiceToken(params.InfisicalToken, params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, params.ExpandSecretReferences && !params.ExpandSecretReferencesLocally)
 		} else if params.UniversalAuthAccessToken != "" {
 
 			if params.WorkspaceId == "" {
@@ -321,16 +336,79 @@ func GetAllEnvironmentVariables(params models.GetAllSecretsParameters, projectCo
 			}
 
 			log.Debug().Msg("Trying to fetch secrets using universal auth")
-			res, err := GetPlainTextSecretsV3(params.UniversalAuthAccessToken, params.WorkspaceId, params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, params.ExpandSecretReferences)
+			res, err := GetPlainTextSecretsV3(params.UniversalAuthAccessToken, params.WorkspaceId, params.Environment, params.SecretsPath, params.IncludeImport, params.Recursive, params.TagSlugs, params.ExpandSecretReferences && !params.ExpandSecretReferencesLocally)
 
 			errorToReturn = err
 			secretsToReturn = res.Secrets
 		}
+
+		if params.OfflineFallback {
+			secretsToReturn, errorToReturn = withOfflineFallback(secretsBackupId(tokenBackupId(params), params), params, secretsToReturn, errorToReturn)
+		}
+	}
+
+	if errorToReturn == nil && params.ExcludePersonalSecrets {
+		secretsToReturn = WithoutPersonalSecrets(secretsToReturn)
+	}
+
+	if errorToReturn == nil && params.ExpandSecretReferencesLocally {
+		secretsToReturn, errorToReturn = ExpandSecretReferencesLocally(secretsToReturn, params, projectConfigFilePath)
+	}
+
+	// the server returns secrets with any of the tags, and not every endpoint filters imported secrets
+	if errorToReturn == nil && params.TagSlugs != "" {
+		secretsToReturn = FilterSecretsByTags(secretsToReturn, params.TagSlugs, params.TagsMatch)
+	}
+
+	if errorToReturn == nil && params.Recursive {
+		secretsToReturn, errorToReturn = nameRecursiveSecrets(secretsToReturn, params)
 	}
 
 	return secretsToReturn, errorToReturn
 }
 
+// nameRecursiveSecrets applies the key naming options of a recursive fetch. Same named secrets of
+// different folders are an error once those options are used, and a warning otherwise.
+func nameRecursiveSecrets(secrets []models.SingleEnvironmentVariable, params models.GetAllSecretsParameters) ([]models.SingleEnvironmentVariable, error) {
+	namingRequested := params.KeyPathSeparator != "" || params.KeyTemplate != ""
+	if namingRequested {
+		renamed, err := RenameSecretKeysByFolder(secrets, params)
+		if err != nil {
+			return nil, err
+		}
+		secrets = renamed
+	}
+
+	collisions := FindSecretKeyCollisions(secrets)
+	if len(collisions) == 0 {
+		return secrets, nil
+	}
+
+	keys := make([]string, 0, len(collisions))
+	for key := range collisions {
+		keys = append(keys, fmt.Sprintf("%s (%s)", key, strings.Join(collisions[key], ", ")))
+	}
+	sort.Strings(keys)
+
+	if namingRequested {
+		return nil, fmt.Errorf("secrets of different folders still have the same name: %s", strings.Join(keys, "; "))
+	}
+
+	PrintWarning(fmt.Sprintf("secrets of different folders have the same name and only one of each is used: %s. Use --prefix-keys-with-path or --key-template to keep all of them", strings.Join(keys, "; ")))
+	return secrets, nil
+}
+
+// WithoutPersonalSecrets leaves out the personal overrides of the user
+func WithoutPersonalSecrets(secrets []models.SingleEnvironmentVariable) []models.SingleEnvironmentVariable {
+	sharedSecrets := []models.SingleEnvironmentVariable{}
+	for _, secret := range secrets {
+		if secret.Type != SECRET_TYPE_PERSONAL {
+			sharedSecrets = append(sharedSecrets, secret)
+		}
+	}
+	return sharedSecrets
+}
+
 func getSecretsByKeys(secrets []models.SingleEnvironmentVariable) map[string]models.SingleEnvironmentVariable {
 	secretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
 
@@ -384,6 +462,97 @@ func OverrideSecrets(secrets []models.SingleEnvironmentVariable, secretType stri
 	return secretsToReturn
 }
 
+// withOfflineFallback caches the secrets fetched with a token, or serves the cached ones when the
+// fetch failed because Infisical can't be reached
+func withOfflineFallback(backupId string, params models.GetAllSecretsParameters, secrets []models.SingleEnvironmentVariable, fetchErr error) ([]models.SingleEnvironmentVariable, error) {
+	if fetchErr == nil {
+		backupEncryptionKey, err := GetBackupEncryptionKey()
+		if err != nil {
+			log.Debug().Msgf("withOfflineFallback: unable to get the backup encryption key, secrets are not cached [err=%s]", err)
+			return secrets, nil
+		}
+
+		if err := WriteBackupSecrets(backupId, params.Environment, params.SecretsPath, backupEncryptionKey, secrets); err != nil {
+			log.Debug().Msgf("withOfflineFallback: unable to cache secrets [err=%s]", err)
+		}
+		return secrets, nil
+	}
+
+	// errors from a reachable instance, such as an expired token, are not an outage
+	if ValidateInfisicalAPIConnection() {
+		return secrets, fetchErr
+	}
+
+	if backedUpSecrets, ok := readBackupSecretsForFallback(backupId, params); ok {
+		return backedUpSecrets, nil
+	}
+	return secrets, fetchErr
+}
+
+// readBackupSecretsForFallback returns the cached secrets of the request, unless there are none or
+// they are older than params.MaxCacheStaleness. Serving them is always announced, they may be outdated.
+func readBackupSecretsForFallback(backupId string, params models.GetAllSecretsParameters) ([]models.SingleEnvironmentVariable, bool) {
+	backupEncryptionKey, _ := GetBackupEncryptionKey()
+	if backupEncryptionKey == nil {
+		return nil, false
+	}
+
+	backedUpSecrets, err := ReadBackupSecrets(backupId, params.Environment, params.SecretsPath, backupEncryptionKey)
+	if err != nil || len(backedUpSecrets) == 0 {
+		log.Debug().Msgf("readBackupSecretsForFallback: no cached secrets to serve [err=%v]", err)
+		return nil, false
+	}
+
+	age := time.Duration(0)
+	if backedUpAt, err := GetBackupSecretsTime(backupId, params.Environment, params.SecretsPath); err == nil {
+		age = time.Since(backedUpAt).Round(time.Second)
+	}
+
+	if params.MaxCacheStaleness > 0 && age > params.MaxCacheStaleness {
+		PrintWarning(fmt.Sprintf("Unable to reach Infisical, and the cached secrets are %s old, more than the allowed %s", age, params.MaxCacheStaleness))
+		return nil, false
+	}
+
+	PrintWarning(fmt.Sprintf("Unable to fetch the latest secret(s) due to connection error, serving secrets cached %s ago. They may be outdated. For more info, run with --debug", age))
+	return backedUpSecrets, true
+}
+
+// tokenBackupId identifies the cached secrets of a token based fetch. Service tokens don't need a
+// project ID, so their cache is kept by the ID of the token.
+func tokenBackupId(params models.GetAllSecretsParameters) string {
+	if params.InfisicalToken != "" {
+		serviceTokenParts := strings.SplitN(params.InfisicalToken, ".", 4)
+		if len(serviceTokenParts) > 1 {
+			return "service-token-" + serviceTokenParts[1]
+		}
+	}
+	return params.WorkspaceId
+}
+
+// secretsBackupId adds the options of a fetch that change which secrets it returns to the ID of its
+// cache, so that e.g. a fetch filtered by tags is never served to one that isn't
+func secretsBackupId(backupId string, params models.GetAllSecretsParameters) string {
+	if params.IncludeImport {
+		backupId += "_imports"
+	}
+	if params.Recursive {
+		backupId += "_recursive"
+	}
+
+	tagSlugs := []string{}
+	for _, slug := range strings.Split(params.TagSlugs, ",") {
+		if slug = strings.TrimSpace(slug); slug != "" {
+			tagSlugs = append(tagSlugs, slug)
+		}
+	}
+	if len(tagSlugs) > 0 {
+		sort.Strings(tagSlugs)
+		backupId += "_tags-" + GetHashFromStringList([]string{strings.Join(tagSlugs, ",")})[:12]
+	}
+
+	return backupId
+}
+
 func GetBackupEncryptionKey() ([]byte, error) {
 	encryptionKey, err := GetValueInKeyring(INFISICAL_BACKUP_SECRET_ENCRYPTION_KEY)
 	if err != nil {
@@ -405,9 +574,27 @@ func GetBackupEncryptionKey() ([]byte, error) {
 	return []byte(encryptionKey), nil
 }
 
-func WriteBackupSecrets(workspace string, environment string, secretsPath string, encryptionKey []byte, secrets []models.SingleEnvironmentVariable) error {
+func getBackupSecretsFileName(workspace string, environment string, secretsPath string) string {
 	formattedPath := strings.ReplaceAll(secretsPath, "/", "-")
-	fileName := fmt.Sprintf("project_secrets_%s_%s_%s.json", workspace, environment, formattedPath)
+	return fmt.Sprintf("project_secrets_%s_%s_%s.json", workspace, environment, formattedPath)
+}
+
+// GetBackupSecretsTime returns when the secrets of a folder were last backed up
+func GetBackupSecretsTime(workspace string, environment string, secretsPath string) (time.Time, error) {
+	_, fullConfigFileDirPath, err := GetFullConfigFilePath()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("GetBackupSecretsTime: unable to get full config folder path [err=%s]", err)
+	}
+
+	fileInfo, err := os.Stat(fmt.Sprintf("%s/%s/%s", fullConfigFileDirPath, "secrets-backup", getBackupSecretsFileName(workspace, environment, secretsPath)))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return fileInfo.ModTime(), nil
+}
+
+func WriteBackupSecrets(workspace string, environment string, secretsPath string, encryptionKey []byte, secrets []models.SingleEnvironmentVariable) error {
+	fileName := getBackupSecretsFileName(workspace, environment, secretsPath)
 	secrets_backup_folder_name := "secrets-backup"
 
 	_, fullConfigFileDirPath, err := GetFullConfigFilePath()
@@ -438,8 +625,7 @@ func WriteBackupSecrets(workspace string, environment string, secretsPath string
 }
 
 func ReadBackupSecrets(workspace string, environment string, secretsPath string, encryptionKey []byte) ([]models.SingleEnvironmentVariable, error) {
-	formattedPath := strings.ReplaceAll(secretsPath, "/", "-")
-	fileName := fmt.Sprintf("project_secrets_%s_%s_%s.json", workspace, environment, formattedPath)
+	fileName := getBackupSecretsFileName(workspace, environment, secretsPath)
 	secrets_backup_folder_name := "secrets-backup"
 
 	_, fullConfigFileDirPath, err := GetFullConfigFilePath()
@@ -524,7 +710,7 @@ func GetEnvelopmentBasedOnGitBranch(workspaceFile models.WorkspaceConfigFile) st
 }
 
 func GetPlainTextWorkspaceKey(authenticationToken string, receiverPrivateKey string, workspaceId string) ([]byte, error) {
-	httpClient := resty.New()
+	httpClient := api.NewHTTPClient()
 	httpClient.SetAuthToken(authenticationToken).
 		SetHeader("Accept", "application/json")
 
@@ -564,34 +750,92 @@ func GetPlainTextWorkspaceKey(authenticationToken string, receiverPrivateKey str
 	return crypto.DecryptAsymmetric(encryptedWorkspaceKey, encryptedWorkspaceKeyNonce, encryptedWorkspaceKeySenderPublicKey, currentUsersPrivateKey), nil
 }
 
-func SetRawSecrets(secretArgs []string, secretType string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails) ([]models.SecretSetOperation, error) {
+func SetRawSecrets(secretArgs []string, secretType string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails) ([]models.SecretSetOperation, []api.SecretApprovalRequest, error) {
+	return PutRawSecrets(parseSecretArgs(secretArgs), secretType, environmentName, secretsPath, projectId, tokenDetails, SECRET_CONFLICT_OVERWRITE, false)
+}
 
-	if tokenDetails == nil {
-		return nil, fmt.Errorf("unable to process set secret operations, token details are missing")
+// parseSecretArgs turns name=value arguments into secrets, exiting on malformed ones
+func parseSecretArgs(secretArgs []string) []models.SingleEnvironmentVariable {
+	secretsToSet := []models.SingleEnvironmentVariable{}
+	for _, arg := range secretArgs {
+		splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
+		if splitKeyValueFromArg[0] == "" || len(splitKeyValueFromArg) < 2 || splitKeyValueFromArg[1] == "" {
+			PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
+		}
+
+		if unicode.IsNumber(rune(splitKeyValueFromArg[0][0])) {
+			PrintErrorMessageAndExit("keys of secrets cannot start with a number. Modify the key name(s) and try again")
+		}
+
+		secretsToSet = append(secretsToSet, models.SingleEnvironmentVariable{Key: splitKeyValueFromArg[0], Value: splitKeyValueFromArg[1]})
 	}
 
-	getAllEnvironmentVariablesRequest := models.GetAllSecretsParameters{Environment: environmentName, SecretsPath: secretsPath, WorkspaceId: projectId}
-	if tokenDetails.Type == UNIVERSAL_AUTH_TOKEN_IDENTIFIER {
-		getAllEnvironmentVariablesRequest.UniversalAuthAccessToken = tokenDetails.Token
+	return secretsToSet
+}
+
+// DeleteSharedRawSecrets deletes shared secrets of a folder. When the folder is protected by an
+// approval policy, a change request is opened instead and returned.
+func DeleteSharedRawSecrets(secretNames []string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails) (*api.SecretApprovalRequest, error) {
+	httpClient := api.NewHTTPClient().
+		SetAuthToken(tokenDetails.Token).
+		SetHeader("Accept", "application/json")
+
+	secretsToDelete := []api.SecretBatchRawV3Secret{}
+	for _, secretName := range secretNames {
+		secretsToDelete = append(secretsToDelete, api.SecretBatchRawV3Secret{SecretKey: secretName})
 	}
 
+	batchResponse, err := api.CallDeleteSecretsBatchRawV3(httpClient, api.SecretBatchRawV3Request{
+		WorkspaceId: projectId,
+		Environment: environmentName,
+		SecretPath:  secretsPath,
+		Secrets:     secretsToDelete,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return batchResponse.Approval, nil
+}
+
+// getRawSecretsOfFolder returns the secrets of a folder without imports and with their references
+// unexpanded
+func getRawSecretsOfFolder(environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails) ([]models.SingleEnvironmentVariable, error) {
 	if tokenDetails.Type == SERVICE_TOKEN_IDENTIFIER {
-		getAllEnvironmentVariablesRequest.InfisicalToken = tokenDetails.Token
+		return GetPlainTextSecretsViaServiceToken(tokenDetails.Token, environmentName, secretsPath, false, false, "", false)
 	}
 
-	httpClient := resty.New().
+	res, err := GetPlainTextSecretsV3(tokenDetails.Token, projectId, environmentName, secretsPath, false, false, "", false)
+	if err != nil {
+		return nil, err
+	}
+	return res.Secrets, nil
+}
+
+// PutRawSecrets creates the given secrets and handles those that already exist as onConflict says.
+// With dryRun it only returns what it would do. Shared secrets of a folder protected by an approval
+// policy aren't changed, the change requests opened for them are returned instead.
+func PutRawSecrets(secretsToSet []models.SingleEnvironmentVariable, secretType string, environmentName string, secretsPath string, projectId string, tokenDetails *models.TokenDetails, onConflict string, dryRun bool) ([]models.SecretSetOperation, []api.SecretApprovalRequest, error) {
+
+	if tokenDetails == nil {
+		return nil, nil, fmt.Errorf("unable to process set secret operations, token details are missing")
+	}
+
+	httpClient := api.NewHTTPClient().
 		SetAuthToken(tokenDetails.Token).
 		SetHeader("Accept", "application/json")
 
-	// pull current secrets
-	secrets, err := GetAllEnvironmentVariables(getAllEnvironmentVariablesRequest, "")
+	// pull current secrets as stored, since the values to set are raw and a reference compared to
+	// what it expands to would always look like a change
+	secrets, err := getRawSecretsOfFolder(environmentName, secretsPath, projectId, tokenDetails)
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve secrets [err=%v]", err)
+		return nil, nil, fmt.Errorf("unable to retrieve secrets [err=%v]", err)
 	}
 
 	secretsToCreate := []api.RawSecret{}
 	secretsToModify := []api.RawSecret{}
 	secretOperations := []models.SecretSetOperation{}
+	conflictingKeys := []string{}
 
 	sharedSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
 	personalSecretMapByName := make(map[string]models.SingleEnvironmentVariable, len(secrets))
@@ -604,19 +848,9 @@ func SetRawSecrets(secretArgs []string, secretType string, environmentName strin
 		}
 	}
 
-	for _, arg := range secretArgs {
-		splitKeyValueFromArg := strings.SplitN(arg, "=", 2)
-		if splitKeyValueFromArg[0] == "" || splitKeyValueFromArg[1] == "" {
-			PrintErrorMessageAndExit("ensure that each secret has a none empty key and value. Modify the input and try again")
-		}
-
-		if unicode.IsNumber(rune(splitKeyValueFromArg[0][0])) {
-			PrintErrorMessageAndExit("keys of secrets cannot start with a number. Modify the key name(s) and try again")
-		}
-
-		// Key and value from argument
-		key := splitKeyValueFromArg[0]
-		value := splitKeyValueFromArg[1]
+	for _, secretToSet := range secretsToSet {
+		key := secretToSet.Key
+		value := secretToSet.Value
 
 		var existingSecret models.SingleEnvironmentVariable
 		var doesSecretExist bool
@@ -630,35 +864,44 @@ func SetRawSecrets(secretArgs []string, secretType string, environmentName strin
 		if doesSecretExist {
 			// case: secret exists in project so it needs to be modified
 			encryptedSecretDetails := api.RawSecret{
-				ID:          existingSecret.ID,
-				SecretValue: value,
-				SecretKey:   key,
-				Type:        existingSecret.Type,
+				ID:            existingSecret.ID,
+				SecretValue:   value,
+				SecretKey:     key,
+				SecretComment: secretToSet.Comment,
+				Type:          existingSecret.Type,
 			}
 
 			// Only add to modifications if the value is different
-			if existingSecret.Value != value {
-				secretsToModify = append(secretsToModify, encryptedSecretDetails)
+			if existingSecret.Value == value {
+				// Current value is same as existing so no change
 				secretOperations = append(secretOperations, models.SecretSetOperation{
 					SecretKey:       key,
 					SecretValue:     value,
-					SecretOperation: "SECRET VALUE MODIFIED",
+					SecretOperation: "SECRET VALUE UNCHANGED",
+				})
+			} else if onConflict == SECRET_CONFLICT_SKIP {
+				secretOperations = append(secretOperations, models.SecretSetOperation{
+					SecretKey:       key,
+					SecretValue:     existingSecret.Value,
+					SecretOperation: "SECRET SKIPPED, ALREADY EXISTS",
 				})
 			} else {
-				// Current value is same as existing so no change
+				conflictingKeys = append(conflictingKeys, key)
+				secretsToModify = append(secretsToModify, encryptedSecretDetails)
 				secretOperations = append(secretOperations, models.SecretSetOperation{
 					SecretKey:       key,
 					SecretValue:     value,
-					SecretOperation: "SECRET VALUE UNCHANGED",
+					SecretOperation: "SECRET VALUE MODIFIED",
 				})
 			}
 
 		} else {
 			// case: secret doesn't exist in project so it needs to be created
 			encryptedSecretDetails := api.RawSecret{
-				SecretKey:   key,
-				SecretValue: value,
-				Type:        secretType,
+				SecretKey:     key,
+				SecretValue:   value,
+				SecretComment: secretToSet.Comment,
+				Type:          secretType,
 			}
 			secretsToCreate = append(secretsToCreate, encryptedSecretDetails)
 			secretOperations = append(secretOperations, models.SecretSetOperation{
@@ -669,38 +912,106 @@ func SetRawSecrets(secretArgs []string, secretType string, environmentName strin
 		}
 	}
 
+	if onConflict == SECRET_CONFLICT_FAIL && len(conflictingKeys) > 0 {
+		return secretOperations, nil, fmt.Errorf("secrets already exist with different values: %s", strings.Join(conflictingKeys, ", "))
+	}
+
+	if dryRun {
+		return secretOperations, nil, nil
+	}
+
+	if secretType == SECRET_TYPE_SHARED {
+		approvals, err := writeSharedRawSecrets(httpClient, secretsToCreate, secretsToModify, environmentName, secretsPath, projectId, secretOperations)
+		if err != nil {
+			return nil, nil, err
+		}
+		return secretOperations, approvals, nil
+	}
+
 	for _, secret := range secretsToCreate {
 		createSecretRequest := api.CreateRawSecretV3Request{
-			SecretName:  secret.SecretKey,
-			SecretValue: secret.SecretValue,
-			Type:        secret.Type,
-			SecretPath:  secretsPath,
-			WorkspaceID: projectId,
-			Environment: environmentName,
+			SecretName:    secret.SecretKey,
+			SecretValue:   secret.SecretValue,
+			SecretComment: secret.SecretComment,
+			Type:          secret.Type,
+			SecretPath:    secretsPath,
+			WorkspaceID:   projectId,
+			Environment:   environmentName,
 		}
 
 		err = api.CallCreateRawSecretsV3(httpClient, createSecretRequest)
 		if err != nil {
-			return nil, fmt.Errorf("unable to process new secret creations [err=%v]", err)
+			return nil, nil, fmt.Errorf("unable to process new secret creations [err=%v]", err)
 		}
 	}
 
 	for _, secret := range secretsToModify {
 		updateSecretRequest := api.UpdateRawSecretByNameV3Request{
-			SecretName:  secret.SecretKey,
-			SecretValue: secret.SecretValue,
-			SecretPath:  secretsPath,
-			WorkspaceID: projectId,
-			Environment: environmentName,
-			Type:        secret.Type,
+			SecretName:    secret.SecretKey,
+			SecretValue:   secret.SecretValue,
+			SecretComment: secret.SecretComment,
+			SecretPath:    secretsPath,
+			WorkspaceID:   projectId,
+			Environment:   environmentName,
+			Type:          secret.Type,
 		}
 
 		err = api.CallUpdateRawSecretsV3(httpClient, updateSecretRequest)
 		if err != nil {
-			return nil, fmt.Errorf("unable to process secret update request [err=%v]", err)
+			return nil, nil, fmt.Errorf("unable to process secret update request [err=%v]", err)
 		}
 	}
 
-	return secretOperations, nil
+	return secretOperations, nil, nil
+
+}
+
+// writeSharedRawSecrets creates and updates shared secrets in one batch each. The batches open a change
+// request instead when the folder is protected by an approval policy, and the operations of their
+// secrets then say so.
+func writeSharedRawSecrets(httpClient *resty.Client, secretsToCreate []api.RawSecret, secretsToModify []api.RawSecret, environmentName string, secretsPath string, projectId string, secretOperations []models.SecretSetOperation) ([]api.SecretApprovalRequest, error) {
+	batches := []struct {
+		secrets            []api.RawSecret
+		call               func(*resty.Client, api.SecretBatchRawV3Request) (api.SecretBatchRawV3Response, error)
+		operation          string
+		requestedOperation string
+		errorMessage       string
+	}{
+		{secretsToCreate, api.CallCreateSecretsBatchRawV3, "SECRET CREATED", "SECRET CREATION REQUESTED", "unable to process new secret creations"},
+		{secretsToModify, api.CallUpdateSecretsBatchRawV3, "SECRET VALUE MODIFIED", "SECRET MODIFICATION REQUESTED", "unable to process secret update request"},
+	}
+
+	approvals := []api.SecretApprovalRequest{}
+	for _, batch := range batches {
+		if len(batch.secrets) == 0 {
+			continue
+		}
+
+		batchSecrets := []api.SecretBatchRawV3Secret{}
+		for _, secret := range batch.secrets {
+			batchSecrets = append(batchSecrets, api.SecretBatchRawV3Secret{SecretKey: secret.SecretKey, SecretValue: secret.SecretValue, SecretComment: secret.SecretComment})
+		}
+
+		batchResponse, err := batch.call(httpClient, api.SecretBatchRawV3Request{
+			WorkspaceId: projectId,
+			Environment: environmentName,
+			SecretPath:  secretsPath,
+			Secrets:     batchSecrets,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%s [err=%v]", batch.errorMessage, err)
+		}
+		if batchResponse.Approval == nil {
+			continue
+		}
+
+		approvals = append(approvals, *batchResponse.Approval)
+		for i := range secretOperations {
+			if secretOperations[i].SecretOperation == batch.operation {
+				secretOperations[i].SecretOperation = batch.requestedOperation
+			}
+		}
+	}
 
+	return approvals, nil
 }
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/Infisical/infisical-merge/packages/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecretsBackupId(t *testing.T) {
+	tests := []struct {
+		name   string
+		params models.GetAllSecretsParameters
+		want   string
+	}{
+		{name: "folder only", params: models.GetAllSecretsParameters{}, want: "project"},
+		{name: "imports", params: models.GetAllSecretsParameters{IncludeImport: true}, want: "project_imports"},
+		{name: "recursive", params: models.GetAllSecretsParameters{Recursive: true}, want: "project_recursive"},
+		{name: "imports and recursive", params: models.GetAllSecretsParameters{IncludeImport: true, Recursive: true}, want: "project_imports_recursive"},
+		{name: "empty tags", params: models.GetAllSecretsParameters{TagSlugs: " , "}, want: "project"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, secretsBackupId("project", test.params))
+		})
+	}
+}
+
+func TestSecretsBackupIdTags(t *testing.T) {
+	backend := secretsBackupId("project", models.GetAllSecretsParameters{TagSlugs: "backend"})
+	backendAndDatabase := secretsBackupId("project", models.GetAllSecretsParameters{TagSlugs: "backend,database"})
+
+	assert.Regexp(t, `^project_tags-[0-9a-f]{12}$`, backend)
+	assert.NotEqual(t, backend, backendAndDatabase)
+	assert.Equal(t, backendAndDatabase, secretsBackupId("project", models.GetAllSecretsParameters{TagSlugs: "database, backend"}), "the order of tags doesn't matter")
+}
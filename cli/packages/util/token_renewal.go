@@ -0,0 +1,225 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/api"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// access tokens are renewed once a fifth of their remaining lifetime is left, and at least this long
+	// before they expire
+	ACCESS_TOKEN_RENEWAL_MARGIN         = 30 * time.Second
+	ACCESS_TOKEN_RENEWAL_RETRY_INTERVAL = 10 * time.Second
+	// renewals are at least this far apart, even for tokens that are about to expire
+	ACCESS_TOKEN_RENEWAL_MIN_DELAY = 5 * time.Second
+)
+
+// AccessTokenLifetime is how long a machine identity access token is valid, as Infisical returns it on
+// login. Zero durations mean no limit.
+type AccessTokenLifetime struct {
+	// ExpiresIn is how long the token is valid for until it's renewed
+	ExpiresIn time.Duration
+	// MaxTTL is how long the token can be renewed for, counted from the login
+	MaxTTL time.Duration
+}
+
+// AccessTokenRenewer keeps a machine identity access token valid for the lifetime of a long running
+// command. It renews the token shortly before it expires, and logs in again with Reauthenticate once
+// Infisical refuses to renew it, e.g. because it reached its max TTL.
+type AccessTokenRenewer struct {
+	// Reauthenticate, if set, logs in again and returns a new access token with its lifetime
+	Reauthenticate func() (string, AccessTokenLifetime, error)
+
+	mutex sync.RWMutex
+	token string
+	// expiresAt is when the token expires unless it's renewed, zero for tokens that never do
+	expiresAt time.Time
+	// maxExpiresAt is when the token can't be renewed anymore, zero when that isn't known
+	maxExpiresAt  time.Time
+	lifetimeKnown bool
+}
+
+// NewAccessTokenRenewer returns a renewer of token. lifetime is nil for tokens that weren't just logged in
+// with, such as those given with --token, and their lifetime is learned by renewing them right away.
+func NewAccessTokenRenewer(token string, lifetime *AccessTokenLifetime) *AccessTokenRenewer {
+	renewer := &AccessTokenRenewer{token: token}
+	if lifetime != nil {
+		renewer.setLogin(token, *lifetime, time.Now())
+	}
+	return renewer
+}
+
+// Token returns the current access token
+func (r *AccessTokenRenewer) Token() string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.token
+}
+
+func (r *AccessTokenRenewer) expiry() (expiresAt time.Time, lifetimeKnown bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.expiresAt, r.lifetimeKnown
+}
+
+// setLogin sets the token of a new login, which can be renewed until its max TTL from now
+func (r *AccessTokenRenewer) setLogin(token string, lifetime AccessTokenLifetime, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.token = token
+	r.maxExpiresAt = time.Time{}
+	if lifetime.MaxTTL > 0 {
+		r.maxExpiresAt = now.Add(lifetime.MaxTTL)
+	}
+	r.expiresAt = accessTokenExpiry(now, lifetime.ExpiresIn, r.maxExpiresAt)
+	r.lifetimeKnown = true
+}
+
+// setRenewal sets the token of a renewal, which keeps the max TTL of its login
+func (r *AccessTokenRenewer) setRenewal(token string, expiresIn time.Duration, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.token = token
+	r.expiresAt = accessTokenExpiry(now, expiresIn, r.maxExpiresAt)
+	r.lifetimeKnown = true
+}
+
+// Run renews the token until ctx is done. Failed renewals are retried until the token expires, at which
+// point Run returns the error. It returns right away for tokens that never expire.
+func (r *AccessTokenRenewer) Run(ctx context.Context) error {
+	if _, lifetimeKnown := r.expiry(); !lifetimeKnown {
+		// Infisical returns how long the token is valid for with every renewal
+		if err := r.renew(); err != nil {
+			expiresAt, expires := GetAccessTokenExpiry(r.Token())
+			if !expires {
+				log.Debug().Err(err).Msg("the access token can't be renewed and doesn't expire, it won't be renewed")
+				return nil
+			}
+			log.Warn().Err(err).Msgf("unable to renew the access token, retrying before it expires at %v", expiresAt)
+			r.mutex.Lock()
+			r.expiresAt = expiresAt
+			r.mutex.Unlock()
+		}
+	}
+
+	for {
+		expiresAt, _ := r.expiry()
+		if expiresAt.IsZero() {
+			log.Debug().Msg("the access token doesn't expire, it won't be renewed")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(accessTokenRenewalDelay(time.Until(expiresAt))):
+		}
+
+		if err := r.renewBefore(ctx, expiresAt); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *AccessTokenRenewer) renewBefore(ctx context.Context, expiresAt time.Time) error {
+	for {
+		err := r.renew()
+		if err == nil {
+			log.Debug().Msg("renewed the access token")
+			return nil
+		}
+
+		if time.Now().After(expiresAt) {
+			return fmt.Errorf("the access token expired and could not be renewed [err=%v]", err)
+		}
+		log.Warn().Err(err).Msgf("unable to renew the access token, retrying in %v", ACCESS_TOKEN_RENEWAL_RETRY_INTERVAL)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(ACCESS_TOKEN_RENEWAL_RETRY_INTERVAL):
+		}
+	}
+}
+
+func (r *AccessTokenRenewer) renew() error {
+	response, err := api.CallMachineIdentityRefreshAccessToken(api.NewHTTPClient(), api.UniversalAuthRefreshRequest{AccessToken: r.Token()})
+	if err == nil {
+		r.setRenewal(response.AccessToken, time.Duration(response.AccessTokenTTL)*time.Second, time.Now())
+		return nil
+	}
+
+	// Infisical refused to renew the token, rather than being unreachable
+	var apiErr *api.APIError
+	if r.Reauthenticate != nil && errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError {
+		log.Debug().Err(err).Msg("the access token can't be renewed anymore, logging in again")
+		token, lifetime, err := r.Reauthenticate()
+		if err != nil {
+			return err
+		}
+		r.setLogin(token, lifetime, time.Now())
+		return nil
+	}
+
+	return err
+}
+
+// accessTokenExpiry returns when a token that is valid for expiresIn from now expires, which is never
+// past the end of its max TTL. It's zero for tokens that never expire.
+func accessTokenExpiry(now time.Time, expiresIn time.Duration, maxExpiresAt time.Time) time.Time {
+	if expiresIn <= 0 {
+		return maxExpiresAt
+	}
+
+	expiresAt := now.Add(expiresIn)
+	if !maxExpiresAt.IsZero() && maxExpiresAt.Before(expiresAt) {
+		return maxExpiresAt
+	}
+	return expiresAt
+}
+
+// accessTokenRenewalDelay returns how long to wait before renewing a token that expires in remaining. It's
+// never below ACCESS_TOKEN_RENEWAL_MIN_DELAY, so a token whose expiry doesn't move isn't renewed in a loop.
+func accessTokenRenewalDelay(remaining time.Duration) time.Duration {
+	margin := remaining / 5
+	if margin < ACCESS_TOKEN_RENEWAL_MARGIN {
+		margin = ACCESS_TOKEN_RENEWAL_MARGIN
+	}
+	if remaining-margin < ACCESS_TOKEN_RENEWAL_MIN_DELAY {
+		return ACCESS_TOKEN_RENEWAL_MIN_DELAY
+	}
+	return remaining - margin
+}
+
+// GetAccessTokenExpiry reads when a machine identity access token expires from its exp claim. Tokens
+// without one, or that can't be parsed, are reported as not expiring.
+func GetAccessTokenExpiry(accessToken string) (time.Time, bool) {
+	parts := strings.Split(accessToken, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	var claims struct {
+		ExpiresAt int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.ExpiresAt == 0 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(claims.ExpiresAt, 0), true
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Infisical/infisical-merge/packages/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessTokenRenewalDelay(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		want      time.Duration
+	}{
+		{name: "a fifth of the lifetime before", remaining: time.Hour, want: 48 * time.Minute},
+		{name: "at least the margin before", remaining: 2 * time.Minute, want: 90 * time.Second},
+		{name: "about to expire", remaining: 31 * time.Second, want: ACCESS_TOKEN_RENEWAL_MIN_DELAY},
+		{name: "expired", remaining: -time.Minute, want: ACCESS_TOKEN_RENEWAL_MIN_DELAY},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, accessTokenRenewalDelay(test.remaining))
+		})
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		expiresIn    time.Duration
+		maxExpiresAt time.Time
+		want         time.Time
+	}{
+		{name: "no max TTL", expiresIn: time.Hour, want: now.Add(time.Hour)},
+		{name: "before the max TTL", expiresIn: time.Hour, maxExpiresAt: now.Add(24 * time.Hour), want: now.Add(time.Hour)},
+		{name: "capped at the max TTL", expiresIn: time.Hour, maxExpiresAt: now.Add(10 * time.Minute), want: now.Add(10 * time.Minute)},
+		{name: "never expires", want: time.Time{}},
+		{name: "only a max TTL", maxExpiresAt: now.Add(24 * time.Hour), want: now.Add(24 * time.Hour)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, accessTokenExpiry(now, test.expiresIn, test.maxExpiresAt))
+		})
+	}
+}
+
+// newRenewalTestServer answers token renewals with status, returning the token of the request with
+// expiresIn seconds when it succeeds
+func newRenewalTestServer(t *testing.T, status int, expiresIn int) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/auth/token/renew", r.URL.Path)
+		var request struct {
+			AccessToken string `json:"accessToken"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			json.NewEncoder(w).Encode(map[string]interface{}{"statusCode": status, "message": "Failed to renew MI access token due to Max TTL expiration"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"accessToken": request.AccessToken, "expiresIn": expiresIn, "accessTokenMaxTTL": 86400, "tokenType": "Bearer"})
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL := config.INFISICAL_URL
+	t.Cleanup(func() { config.INFISICAL_URL = previousURL })
+	config.INFISICAL_URL = server.URL
+}
+
+// runRenewerBriefly runs renewer until its first renewals are done
+func runRenewerBriefly(t *testing.T, renewer *AccessTokenRenewer) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	return renewer.Run(ctx)
+}
+
+func TestAccessTokenRenewerLearnsTheLifetimeOfGivenTokens(t *testing.T) {
+	newRenewalTestServer(t, http.StatusOK, 3600)
+	renewer := NewAccessTokenRenewer("given-token", nil)
+
+	before := time.Now()
+	assert.NoError(t, runRenewerBriefly(t, renewer))
+
+	expiresAt, lifetimeKnown := renewer.expiry()
+	assert.True(t, lifetimeKnown)
+	assert.WithinDuration(t, before.Add(time.Hour), expiresAt, 5*time.Second)
+	assert.Equal(t, "given-token", renewer.Token())
+}
+
+func TestAccessTokenRenewerKeepsTokensThatCantBeRenewed(t *testing.T) {
+	newRenewalTestServer(t, http.StatusBadRequest, 0)
+
+	// no exp claim, so the token never expires
+	token := "header." + base64.RawURLEncoding.EncodeToString([]byte(`{"identityId":"identity"}`)) + ".signature"
+	renewer := NewAccessTokenRenewer(token, nil)
+
+	done := make(chan error)
+	go func() { done <- renewer.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the renewer kept renewing a token that doesn't expire")
+	}
+	assert.Equal(t, token, renewer.Token())
+}
+
+func TestAccessTokenRenewerLogsInAgainOnceRenewalsAreRefused(t *testing.T) {
+	newRenewalTestServer(t, http.StatusBadRequest, 0)
+
+	renewer := NewAccessTokenRenewer("old-token", nil)
+	renewer.Reauthenticate = func() (string, AccessTokenLifetime, error) {
+		return "new-token", AccessTokenLifetime{ExpiresIn: time.Hour, MaxTTL: 2 * time.Hour}, nil
+	}
+
+	before := time.Now()
+	assert.NoError(t, runRenewerBriefly(t, renewer))
+
+	expiresAt, _ := renewer.expiry()
+	assert.Equal(t, "new-token", renewer.Token())
+	assert.WithinDuration(t, before.Add(time.Hour), expiresAt, 5*time.Second)
+
+	// renewals of the new token stop at its max TTL
+	renewer.setRenewal("new-token", 3*time.Hour, time.Now())
+	expiresAt, _ = renewer.expiry()
+	assert.WithinDuration(t, before.Add(2*time.Hour), expiresAt, 5*time.Second)
+}
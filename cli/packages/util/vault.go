@@ -2,21 +2,147 @@ package util
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+
+	"golang.org/x/term"
 )
 
+// VAULT_BACKEND_PLATFORMS are the system keyrings that can be picked explicitly, and the OS they exist on.
+// auto selects the one of the current OS.
+var VAULT_BACKEND_PLATFORMS = map[string]string{
+	VAULT_BACKEND_KEYCHAIN_MODE:       "darwin",
+	VAULT_BACKEND_WINCRED_MODE:        "windows",
+	VAULT_BACKEND_SECRET_SERVICE_MODE: "linux",
+}
+
 func GetCurrentVaultBackend() (string, error) {
 	configFile, err := GetConfigFile()
 	if err != nil {
 		return "", fmt.Errorf("getCurrentVaultBackend: unable to get config file [err=%s]", err)
 	}
 
-	if configFile.VaultBackendType == "" {
+	if configFile.VaultBackendType == "" || !IsVaultBackendKnown(configFile.VaultBackendType) {
 		return VAULT_BACKEND_AUTO_MODE, nil
 	}
 
-	if configFile.VaultBackendType != VAULT_BACKEND_AUTO_MODE && configFile.VaultBackendType != VAULT_BACKEND_FILE_MODE {
-		return VAULT_BACKEND_AUTO_MODE, nil
+	return configFile.VaultBackendType, nil
+}
+
+func IsVaultBackendKnown(vaultBackend string) bool {
+	if _, ok := VAULT_BACKEND_PLATFORMS[vaultBackend]; ok {
+		return true
 	}
+	return vaultBackend == VAULT_BACKEND_AUTO_MODE || vaultBackend == VAULT_BACKEND_FILE_MODE || vaultBackend == VAULT_BACKEND_PASS_MODE
+}
 
-	return configFile.VaultBackendType, nil
+// CheckVaultBackendAvailable returns why a vault backend can't be used on this machine, if it can't
+func CheckVaultBackendAvailable(vaultBackend string) error {
+	if !IsVaultBackendKnown(vaultBackend) {
+		return fmt.Errorf("unknown vault backend [%s]", vaultBackend)
+	}
+
+	if platform, ok := VAULT_BACKEND_PLATFORMS[vaultBackend]; ok && platform != runtime.GOOS {
+		return fmt.Errorf("the %s vault backend is only available on %s", vaultBackend, platform)
+	}
+
+	if vaultBackend == VAULT_BACKEND_PASS_MODE {
+		if _, err := exec.LookPath("pass"); err != nil {
+			return fmt.Errorf("the pass vault backend needs the pass password manager to be installed and initialized")
+		}
+	}
+
+	return nil
+}
+
+// IsSameVaultStorage reports whether two vault backends keep values in the same place, as auto does with
+// the system keyring of the OS
+func IsSameVaultStorage(vaultBackend, otherVaultBackend string) bool {
+	resolve := func(backend string) string {
+		if platform, ok := VAULT_BACKEND_PLATFORMS[backend]; ok && platform == runtime.GOOS {
+			return VAULT_BACKEND_AUTO_MODE
+		}
+		return backend
+	}
+	return resolve(vaultBackend) == resolve(otherVaultBackend)
+}
+
+var (
+	vaultFilePassphraseMutex sync.Mutex
+	// vaultFilePassphrase is the passphrase of the file vault once the CLI knows it, from the config file
+	// or a prompt. It's only handed to the file vault, never put in the environment.
+	vaultFilePassphrase string
+)
+
+// SetVaultFilePassphrase sets the passphrase the file vault is opened with
+func SetVaultFilePassphrase(passphrase string) {
+	vaultFilePassphraseMutex.Lock()
+	defer vaultFilePassphraseMutex.Unlock()
+	vaultFilePassphrase = passphrase
+}
+
+// getVaultFilePassphrase returns the passphrase of the file vault: the one of the config file, unless the
+// vault is protected with a passphrase of the user, which is read from INFISICAL_VAULT_FILE_PASSPHRASE or
+// asked for
+func getVaultFilePassphrase() (string, error) {
+	// reading the config file sets the passphrase it holds
+	if _, err := GetConfigFile(); err != nil {
+		return "", err
+	}
+
+	vaultFilePassphraseMutex.Lock()
+	defer vaultFilePassphraseMutex.Unlock()
+
+	if vaultFilePassphrase != "" {
+		return vaultFilePassphrase, nil
+	}
+	if passphrase := os.Getenv(INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME); passphrase != "" {
+		return passphrase, nil
+	}
+
+	passphrase, err := AskForVaultPassphrase(false)
+	if err != nil {
+		return "", err
+	}
+	vaultFilePassphrase = passphrase
+	return passphrase, nil
+}
+
+// AskForVaultPassphrase reads the passphrase of the file vault from the terminal. With confirm, it's
+// asked for twice to catch typos when setting a new one.
+func AskForVaultPassphrase(confirm bool) (string, error) {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return "", fmt.Errorf("the file vault is passphrase protected, set its passphrase in the %s environment variable", INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME)
+	}
+
+	fmt.Fprint(os.Stderr, "Vault passphrase: ")
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+
+	if len(passphrase) == 0 {
+		return "", fmt.Errorf("the vault passphrase can't be empty")
+	}
+	if confirm && len(passphrase) < 12 {
+		return "", fmt.Errorf("the vault passphrase must be at least 12 characters")
+	}
+
+	if confirm {
+		fmt.Fprint(os.Stderr, "Confirm vault passphrase: ")
+		confirmation, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+
+		if string(confirmation) != string(passphrase) {
+			return "", fmt.Errorf("the passphrases don't match")
+		}
+	}
+
+	return string(passphrase), nil
 }
@@ -0,0 +1,90 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	jose "github.com/dvsekhvalnov/jose2go"
+	"github.com/mtibben/percent"
+	"github.com/zalando/go-keyring"
+)
+
+// The file vault keeps each value in its own file of ~/infisical-keyring, encrypted with the passphrase
+// of the vault. It's read and written the way the keyring package stores its file backend, so vaults
+// created by earlier versions keep working, but with the passphrase passed in rather than read from
+// INFISICAL_VAULT_FILE_PASSPHRASE, which the CLI would otherwise have to set for the whole process.
+
+const fileVaultDirectoryName = "infisical-keyring"
+
+func fileVaultEntryPath(key string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	directory := filepath.Join(homeDir, fileVaultDirectoryName)
+	if err := os.MkdirAll(directory, 0700); err != nil {
+		return "", err
+	}
+	return filepath.Join(directory, percent.Encode(key, "/")), nil
+}
+
+func fileVaultSet(key, value, passphrase string) error {
+	path, err := fileVaultEntryPath(key)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	token, err := jose.Encrypt(string(payload), jose.PBES2_HS256_A128KW, jose.A256GCM, passphrase,
+		jose.Headers(map[string]interface{}{"created": time.Now().String()}))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(token), 0600)
+}
+
+func fileVaultGet(key, passphrase string) (string, error) {
+	path, err := fileVaultEntryPath(key)
+	if err != nil {
+		return "", err
+	}
+
+	token, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", keyring.ErrNotFound
+	} else if err != nil {
+		return "", err
+	}
+
+	payload, _, err := jose.Decode(string(token), passphrase)
+	if err != nil {
+		return "", fmt.Errorf("unable to decrypt [%s] from the file vault, check the vault passphrase [err=%w]", key, err)
+	}
+
+	var value string
+	err = json.Unmarshal([]byte(payload), &value)
+	return value, err
+}
+
+func fileVaultDelete(key string) error {
+	path, err := fileVaultEntryPath(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return keyring.ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+)
+
+// The pass vault backend keeps values in the pass password manager, under the infisical-cli folder of the
+// password store. pass encrypts them with the GPG key the store was initialized with.
+
+func passEntryName(key string) string {
+	return MAIN_KEYRING_SERVICE + "/" + key
+}
+
+func passSet(key, value string) error {
+	command := exec.Command("pass", "insert", "--multiline", "--force", passEntryName(key))
+	command.Stdin = strings.NewReader(value)
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("pass insert failed: %s [err=%w]", strings.TrimSpace(stderr.String()), err)
+	}
+	return nil
+}
+
+func passGet(key string) (string, error) {
+	command := exec.Command("pass", "show", passEntryName(key))
+
+	var stdout, stderr bytes.Buffer
+	command.Stdout = &stdout
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		if strings.Contains(stderr.String(), "is not in the password store") {
+			return "", keyring.ErrNotFound
+		}
+		return "", fmt.Errorf("pass show failed: %s [err=%w]", strings.TrimSpace(stderr.String()), err)
+	}
+
+	// pass insert --multiline stores the value exactly as given
+	return stdout.String(), nil
+}
+
+func passDelete(key string) error {
+	command := exec.Command("pass", "rm", "--force", passEntryName(key))
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+	if err := command.Run(); err != nil {
+		if strings.Contains(stderr.String(), "is not in the password store") {
+			return keyring.ErrNotFound
+		}
+		return fmt.Errorf("pass rm failed: %s [err=%w]", strings.TrimSpace(stderr.String()), err)
+	}
+	return nil
+}
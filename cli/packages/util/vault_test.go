@@ -0,0 +1,75 @@
+package util
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/zalando/go-keyring"
+)
+
+func TestGetVaultFilePassphrase(t *testing.T) {
+	tests := []struct {
+		name           string
+		userPassphrase string
+		cliPassphrase  string
+		want           string
+	}{
+		{name: "passphrase known to the CLI", cliPassphrase: "prompted-passphrase", want: "prompted-passphrase"},
+		{name: "passphrase set by the user", userPassphrase: "user-passphrase", want: "user-passphrase"},
+		{
+			name:           "config passphrase over the one of the user",
+			userPassphrase: "user-passphrase",
+			cliPassphrase:  "config-passphrase",
+			want:           "config-passphrase",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv(INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME, test.userPassphrase)
+
+			SetVaultFilePassphrase(test.cliPassphrase)
+			t.Cleanup(func() { SetVaultFilePassphrase("") })
+
+			passphrase, err := getVaultFilePassphrase()
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, passphrase)
+			assert.Equal(t, test.userPassphrase, os.Getenv(INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME), "the passphrase is never put in the environment")
+		})
+	}
+}
+
+func TestFileVault(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	assert.NoError(t, fileVaultSet("infisical-cli/user@example.com", "credentials", "passphrase"))
+	value, err := fileVaultGet("infisical-cli/user@example.com", "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, "credentials", value)
+
+	_, err = fileVaultGet("infisical-cli/user@example.com", "wrong-passphrase")
+	assert.Error(t, err)
+
+	assert.NoError(t, fileVaultDelete("infisical-cli/user@example.com"))
+	_, err = fileVaultGet("infisical-cli/user@example.com", "passphrase")
+	assert.ErrorIs(t, err, keyring.ErrNotFound)
+	assert.ErrorIs(t, fileVaultDelete("infisical-cli/user@example.com"), keyring.ErrNotFound)
+}
+
+func TestFileVaultReadsValuesOfTheKeyringPackage(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	// the keyring package reads the passphrase from the environment
+	t.Setenv(INFISICAL_VAULT_FILE_PASSPHRASE_ENV_NAME, "passphrase")
+
+	assert.NoError(t, keyring.Set(VAULT_BACKEND_FILE_MODE, MAIN_KEYRING_SERVICE, "written-by-keyring", "old value"))
+	value, err := fileVaultGet("written-by-keyring", "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, "old value", value)
+
+	assert.NoError(t, fileVaultSet("written-by-cli", "new value", "passphrase"))
+	value, err = keyring.Get(VAULT_BACKEND_FILE_MODE, MAIN_KEYRING_SERVICE, "written-by-cli")
+	assert.NoError(t, err)
+	assert.Equal(t, "new value", value)
+}
@@ -23,3 +23,7 @@ package report
 
 const version = "v8.0.0"
 const driver = "gitleaks"
+
+// every finding is a leaked secret, so all are reported at the highest level
+const sarifLevel = "error"
+const securitySeverity = "8.0"
@@ -23,6 +23,8 @@
 package report
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"strings"
 )
 
@@ -62,11 +64,36 @@ type Finding struct {
 
 	// unique identifer
 	Fingerprint string
+
+	// SecretHash is the sha256 of Secret, kept when the finding is redacted
+	// so that redacted findings can still be told apart
+	SecretHash string `json:",omitempty"`
 }
 
 // Redact removes sensitive information from a finding.
 func (f *Finding) Redact() {
+	if f.SecretHash == "" {
+		f.SecretHash = hashSecret(f.Secret)
+	}
 	f.Line = strings.Replace(f.Line, f.Secret, "REDACTED", -1)
 	f.Match = strings.Replace(f.Match, f.Secret, "REDACTED", -1)
 	f.Secret = "REDACTED"
 }
+
+// StableFingerprint identifies a finding by its rule, file and secret. Unlike
+// Fingerprint it doesn't include the line or commit, so it stays the same when
+// the secret moves within the file.
+func (f Finding) StableFingerprint() string {
+	secretHash := f.SecretHash
+	if secretHash == "" {
+		secretHash = hashSecret(f.Secret)
+	}
+
+	hash := sha256.Sum256([]byte(f.RuleID + "\x00" + f.File + "\x00" + secretHash))
+	return hex.EncodeToString(hash[:])
+}
+
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
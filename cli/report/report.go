@@ -23,6 +23,7 @@
 package report
 
 import (
+	"fmt"
 	"os"
 	"strings"
 
@@ -36,11 +37,17 @@ const (
 )
 
 func Write(findings []Finding, cfg config.Config, ext string, reportPath string) error {
+	ext = strings.ToLower(ext)
+	switch ext {
+	case ".json", "json", ".csv", "csv", ".sarif", "sarif":
+	default:
+		return fmt.Errorf("unsupported report format %s, use json, csv or sarif", ext)
+	}
+
 	file, err := os.Create(reportPath)
 	if err != nil {
 		return err
 	}
-	ext = strings.ToLower(ext)
 	switch ext {
 	case ".json", "json":
 		err = writeJson(findings, file)
@@ -26,11 +26,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"path/filepath"
+	"strings"
 
 	"github.com/Infisical/infisical-merge/config"
 )
 
 func writeSarif(cfg config.Config, findings []Finding, w io.WriteCloser) error {
+	defer w.Close()
 	sarif := Sarif{
 		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
 		Version: "2.1.0",
@@ -74,25 +77,44 @@ func hasEmptyRules(tool Tool) bool {
 }
 
 func getRules(cfg config.Config) []Rules {
-	// TODO	for _, rule := range cfg.Rules {
 	var rules []Rules
 	for _, rule := range cfg.OrderedRules() {
-		shortDescription := ShortDescription{
-			Text: rule.Description,
+		description := rule.Description
+		if description == "" {
+			description = rule.RuleID
 		}
+
+		fullDescription := description
 		if rule.Regex != nil {
-			shortDescription = ShortDescription{
-				Text: rule.Regex.String(),
-			}
+			fullDescription = fmt.Sprintf("%s, matched by the regex %s", description, rule.Regex.String())
 		} else if rule.Path != nil {
-			shortDescription = ShortDescription{
-				Text: rule.Path.String(),
-			}
+			fullDescription = fmt.Sprintf("%s, matched by the path %s", description, rule.Path.String())
 		}
+
+		// GitHub code scanning groups and filters results by these tags
+		tags := []string{"security", "secret", "external/cwe/" + strings.ToLower(CWE)}
+		tags = append(tags, rule.Tags...)
+
 		rules = append(rules, Rules{
-			ID:          rule.RuleID,
-			Name:        rule.Description,
-			Description: shortDescription,
+			ID:   rule.RuleID,
+			Name: rule.RuleID,
+			Description: ShortDescription{
+				Text: description,
+			},
+			FullDescription: FullDescription{
+				Text: fullDescription,
+			},
+			Help: Help{
+				Text: fmt.Sprintf("%s (%s). Remove the secret from the code and rotate it, as it stays readable in the history of the repository.", CWE_DESCRIPTION, CWE),
+			},
+			DefaultConfiguration: DefaultConfiguration{
+				Level: sarifLevel,
+			},
+			Properties: RuleProperties{
+				Tags:             tags,
+				Precision:        "high",
+				SecuritySeverity: securitySeverity,
+			},
 		})
 	}
 	return rules
@@ -115,10 +137,15 @@ func getResults(findings []Finding) []Results {
 				Text: messageText(f),
 			},
 			RuleId:    f.RuleID,
+			Level:     sarifLevel,
 			Locations: getLocation(f),
-			// This information goes in partial fingerprings until revision
-			// data can be added somewhere else
-			PartialFingerPrints: PartialFingerPrints{
+			// consumers match results across runs with these, so they must not
+			// change when unrelated lines are added to the file
+			PartialFingerPrints: map[string]string{
+				"secretFingerprint/v1": f.StableFingerprint(),
+			},
+			Properties: ResultProperties{
+				Fingerprint:   f.Fingerprint,
 				CommitSha:     f.Commit,
 				Email:         f.Email,
 				CommitMessage: f.Message,
@@ -136,32 +163,39 @@ func getLocation(f Finding) []Locations {
 	if f.SymlinkFile != "" {
 		uri = f.SymlinkFile
 	}
-	return []Locations{
-		{
-			PhysicalLocation: PhysicalLocation{
-				ArtifactLocation: ArtifactLocation{
-					URI: uri,
-				},
-				Region: Region{
-					StartLine:   f.StartLine,
-					EndLine:     f.EndLine,
-					StartColumn: f.StartColumn,
-					EndColumn:   f.EndColumn,
-					Snippet: Snippet{
-						Text: f.Secret,
-					},
-				},
+
+	location := Locations{
+		PhysicalLocation: PhysicalLocation{
+			ArtifactLocation: ArtifactLocation{
+				URI: filepath.ToSlash(uri),
 			},
 		},
 	}
+
+	// findings of path only rules have no line
+	if f.StartLine > 0 {
+		location.PhysicalLocation.Region = &Region{
+			StartLine:   f.StartLine,
+			EndLine:     f.EndLine,
+			StartColumn: f.StartColumn,
+			// the end column of a SARIF region is exclusive
+			EndColumn: f.EndColumn + 1,
+			Snippet: Snippet{
+				Text: f.Match,
+			},
+		}
+	}
+
+	return []Locations{location}
 }
 
-type PartialFingerPrints struct {
-	CommitSha     string `json:"commitSha"`
-	Email         string `json:"email"`
-	Author        string `json:"author"`
-	Date          string `json:"date"`
-	CommitMessage string `json:"commitMessage"`
+type ResultProperties struct {
+	Fingerprint   string `json:"fingerprint,omitempty"`
+	CommitSha     string `json:"commitSha,omitempty"`
+	Email         string `json:"email,omitempty"`
+	Author        string `json:"author,omitempty"`
+	Date          string `json:"date,omitempty"`
+	CommitMessage string `json:"commitMessage,omitempty"`
 }
 
 type Sarif struct {
@@ -178,10 +212,28 @@ type FullDescription struct {
 	Text string `json:"text"`
 }
 
+type Help struct {
+	Text string `json:"text"`
+}
+
+type DefaultConfiguration struct {
+	Level string `json:"level"`
+}
+
+type RuleProperties struct {
+	Tags             []string `json:"tags"`
+	Precision        string   `json:"precision"`
+	SecuritySeverity string   `json:"security-severity"`
+}
+
 type Rules struct {
-	ID          string           `json:"id"`
-	Name        string           `json:"name"`
-	Description ShortDescription `json:"shortDescription"`
+	ID                   string               `json:"id"`
+	Name                 string               `json:"name"`
+	Description          ShortDescription     `json:"shortDescription"`
+	FullDescription      FullDescription      `json:"fullDescription"`
+	Help                 Help                 `json:"help"`
+	DefaultConfiguration DefaultConfiguration `json:"defaultConfiguration"`
+	Properties           RuleProperties       `json:"properties"`
 }
 
 type Driver struct {
@@ -205,9 +257,9 @@ type ArtifactLocation struct {
 
 type Region struct {
 	StartLine   int     `json:"startLine"`
-	StartColumn int     `json:"startColumn"`
-	EndLine     int     `json:"endLine"`
-	EndColumn   int     `json:"endColumn"`
+	StartColumn int     `json:"startColumn,omitempty"`
+	EndLine     int     `json:"endLine,omitempty"`
+	EndColumn   int     `json:"endColumn,omitempty"`
 	Snippet     Snippet `json:"snippet"`
 }
 
@@ -217,7 +269,7 @@ type Snippet struct {
 
 type PhysicalLocation struct {
 	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
-	Region           Region           `json:"region"`
+	Region           *Region          `json:"region,omitempty"`
 }
 
 type Locations struct {
@@ -225,10 +277,12 @@ type Locations struct {
 }
 
 type Results struct {
-	Message             Message     `json:"message"`
-	RuleId              string      `json:"ruleId"`
-	Locations           []Locations `json:"locations"`
-	PartialFingerPrints `json:"partialFingerprints"`
+	Message             Message           `json:"message"`
+	RuleId              string            `json:"ruleId"`
+	Level               string            `json:"level"`
+	Locations           []Locations       `json:"locations"`
+	PartialFingerPrints map[string]string `json:"partialFingerprints"`
+	Properties          ResultProperties  `json:"properties"`
 }
 
 type Runs struct {
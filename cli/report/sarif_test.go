@@ -22,6 +22,8 @@
 
 package report
 
+import "testing"
+
 const configPath = "../testdata/config/"
 
 // func TestWriteSarif(t *testing.T) {
@@ -120,3 +122,34 @@ const configPath = "../testdata/config/"
 // 		os.Remove(tmpfile.Name())
 // 	}
 // }
+
+func TestSarifResultsKeepFingerprintWhenSecretMoves(t *testing.T) {
+	finding := Finding{
+		RuleID:      "test-rule",
+		File:        "auth.py",
+		Secret:      "a secret",
+		Match:       "token = a secret",
+		StartLine:   1,
+		EndLine:     1,
+		StartColumn: 9,
+		EndColumn:   16,
+	}
+	moved := finding
+	moved.StartLine, moved.EndLine = 10, 10
+
+	results := getResults([]Finding{finding, moved})
+	if results[0].PartialFingerPrints["secretFingerprint/v1"] != results[1].PartialFingerPrints["secretFingerprint/v1"] {
+		t.Error("fingerprint changed when the secret moved to another line")
+	}
+
+	region := results[0].Locations[0].PhysicalLocation.Region
+	if region == nil || region.StartLine != 1 || region.EndColumn != 17 {
+		t.Errorf("unexpected region %+v", region)
+	}
+
+	redacted := finding
+	redacted.Redact()
+	if redacted.StableFingerprint() != finding.StableFingerprint() {
+		t.Error("fingerprint changed when the finding was redacted")
+	}
+}